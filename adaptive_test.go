@@ -0,0 +1,49 @@
+package rateflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiterShrinksOnLatency(t *testing.T) {
+	lim := NewAdaptiveLimiter(Limit(100), 10)
+
+	for i := 0; i < 50; i++ {
+		lim.Observe(10*time.Millisecond, false)
+	}
+	if limit := lim.Limit(); limit != Limit(100) {
+		t.Errorf("expected steady latency to keep the ceiling, got %v", limit)
+	}
+
+	for i := 0; i < 50; i++ {
+		lim.Observe(100*time.Millisecond, false)
+	}
+	shrunk := lim.Limit()
+	if shrunk >= Limit(100) {
+		t.Fatalf("expected rising latency to shrink the limit, got %v", shrunk)
+	}
+
+	for i := 0; i < 200; i++ {
+		lim.Observe(10*time.Millisecond, false)
+	}
+	if limit := lim.Limit(); limit <= shrunk {
+		t.Errorf("expected the limit to recover from %v, got %v", shrunk, limit)
+	}
+}
+
+func TestAdaptiveLimiterDrops(t *testing.T) {
+	lim := NewAdaptiveLimiter(Limit(100), 10)
+	lim.SetBounds(Limit(50), Limit(100))
+
+	lim.Observe(0, true)
+	if limit := lim.Limit(); limit != Limit(90) {
+		t.Errorf("expected a drop to back off to 90, got %v", limit)
+	}
+
+	for i := 0; i < 20; i++ {
+		lim.Observe(0, true)
+	}
+	if limit := lim.Limit(); limit != Limit(50) {
+		t.Errorf("expected the limit to stop at the lower bound, got %v", limit)
+	}
+}
@@ -0,0 +1,533 @@
+// Package admin serves an ops console for limiters over HTTP: the live
+// state of every limiter registered with it, and changes to their limits
+// at runtime. Mount a Console under a prefix of its own:
+//
+//	console := admin.New(admin.BearerToken(os.Getenv("RATEFLOW_ADMIN_TOKEN")))
+//	console.Register("api", api)
+//	admin.RegisterKeyed(console, "users", users, admin.KeyedOptions[string]{})
+//	http.Handle("/debug/limits/", http.StripPrefix("/debug/limits", console))
+//
+// It answers in JSON:
+//
+//	GET  /                      every limiter, and the top keys of every Keyed
+//	GET  /{name}                one limiter or Keyed; ?top=N keys, 10 if not given
+//	GET  /{name}?key=K          the limiter of one key
+//	POST /{name}/limit          {"limit": 10, "burst": 20}, either may be left out
+//	POST /{name}/reset          refills the limiter
+//	POST /{name}/purge?key=K    evicts the limiter of a key
+//
+// The changes take ?key=K to act on one key of a Keyed. Limits are
+// numbers of events per second, or "inf" for no limit. Names must not
+// contain a slash
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// Action is what a request to the Console wants to do
+type Action int
+
+const (
+	// Read lists limiters and their state
+	Read Action = iota
+	// SetLimit changes a limit or burst
+	SetLimit
+	// Reset refills a limiter
+	Reset
+	// Purge evicts the limiter of a key
+	Purge
+)
+
+// String returns the name of a
+func (a Action) String() string {
+	switch a {
+	case Read:
+		return "read"
+	case SetLimit:
+		return "set_limit"
+	case Reset:
+		return "reset"
+	case Purge:
+		return "purge"
+	}
+	return "unknown"
+}
+
+// Authorizer reports whether req may perform action
+type Authorizer func(req *http.Request, action Action) bool
+
+// ReadOnly allows reads and refuses every change. It is the Authorizer
+// of a Console created without one
+func ReadOnly(req *http.Request, action Action) bool {
+	return action == Read
+}
+
+// BearerToken allows every action to requests carrying token in an
+// Authorization: Bearer header, and nothing to the others. An empty
+// token allows nothing
+func BearerToken(token string) Authorizer {
+	return func(req *http.Request, action Action) bool {
+		got, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		return ok && token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+	}
+}
+
+// maxBody bounds the size of a change's body
+const maxBody = 1 << 16
+
+// defaultTop is how many keys of a Keyed are listed if ?top is not given
+const defaultTop = 10
+
+// Limit is a rate in a JSON answer, "inf" for rateflow.Inf
+type Limit rateflow.Limit
+
+func (l Limit) MarshalJSON() ([]byte, error) {
+	if rateflow.Limit(l) == rateflow.Inf {
+		return []byte(`"inf"`), nil
+	}
+	return json.Marshal(float64(l))
+}
+
+func (l *Limit) UnmarshalJSON(data []byte) error {
+	if string(data) == `"inf"` {
+		*l = Limit(rateflow.Inf)
+		return nil
+	}
+	var f float64
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("admin: limit must be a number or \"inf\": %w", err)
+	}
+	if f < 0 || math.IsNaN(f) {
+		return errors.New("admin: limit must not be negative")
+	}
+	*l = Limit(f)
+	return nil
+}
+
+// StatsState is the activity of a limiter, from its rateflow.Stats
+type StatsState struct {
+	Allowed uint64 `json:"allowed"`
+	Denied  uint64 `json:"denied"`
+	// Denials counts the denied calls by rateflow.DenialReason
+	Denials map[string]uint64 `json:"denials"`
+	Waiting int               `json:"waiting"`
+	// Waits is the number of Waits so far, and the others how long they
+	// took in seconds, as bucket bounds for the quantiles
+	Waits        uint64    `json:"waits"`
+	WaitMean     float64   `json:"wait_mean"`
+	WaitP50      float64   `json:"wait_p50"`
+	WaitP99      float64   `json:"wait_p99"`
+	LastDecision time.Time `json:"last_decision"`
+}
+
+// LimiterState is the live state of one limiter
+type LimiterState struct {
+	Name string `json:"name"`
+	// Key is set for the limiter of a key of a Keyed
+	Key       string     `json:"key,omitempty"`
+	Algorithm string     `json:"algorithm"`
+	Limit     Limit      `json:"limit"`
+	Burst     int        `json:"burst"`
+	Tokens    float64    `json:"tokens"`
+	Stats     StatsState `json:"stats"`
+}
+
+// KeyedState is the live state of a Keyed, with its most throttled keys
+type KeyedState struct {
+	Name    string         `json:"name"`
+	Keys    int            `json:"keys"`
+	Created uint64         `json:"created"`
+	Evicted uint64         `json:"evicted"`
+	Top     []LimiterState `json:"top"`
+}
+
+// Overview lists every limiter and Keyed of a Console, sorted by name
+type Overview struct {
+	Limiters []LimiterState `json:"limiters"`
+	Keyed    []KeyedState   `json:"keyed"`
+}
+
+// Limits is the body of a limit change; fields left out are kept
+type Limits struct {
+	Limit *Limit `json:"limit,omitempty"`
+	Burst *int   `json:"burst,omitempty"`
+}
+
+// errNoKey is returned when a key is looked up on a single limiter, or a
+// purge names none
+var errNoKey = errors.New("admin: key needed")
+
+// errNotFound is returned for unknown names and keys
+var errNotFound = errors.New("admin: not found")
+
+// keyedSource is a registered Keyed with its key type erased
+type keyedSource interface {
+	state(name string, top int) KeyedState
+	lookup(key string) (rateflow.Limiter, error)
+	purge(key string) (bool, error)
+}
+
+// Console is an http.Handler listing the limiters registered with it and
+// changing them at runtime, as its Authorizer allows
+type Console struct {
+	authorize Authorizer
+
+	mu       sync.Mutex
+	limiters map[string]rateflow.Limiter
+	keyed    map[string]keyedSource
+}
+
+// New creates an empty Console. A nil authorize is ReadOnly
+func New(authorize Authorizer) *Console {
+	if authorize == nil {
+		authorize = ReadOnly
+	}
+	return &Console{
+		authorize: authorize,
+		limiters:  make(map[string]rateflow.Limiter),
+		keyed:     make(map[string]keyedSource),
+	}
+}
+
+// Register lists lim under name, replacing anything registered under
+// name before
+func (c *Console) Register(name string, lim rateflow.Limiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.keyed, name)
+	c.limiters[name] = lim
+}
+
+// Unregister stops listing name
+func (c *Console) Unregister(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.limiters, name)
+	delete(c.keyed, name)
+}
+
+// KeyedOptions controls how the keys of a Keyed are shown and named
+type KeyedOptions[K comparable] struct {
+	// Label turns a key into text, fmt.Sprint if nil
+	Label func(key K) string
+	// Parse turns the text of ?key into a key. It may be nil for string
+	// keys; for other keys without it, keys cannot be looked up
+	Parse func(s string) (K, error)
+}
+
+// RegisterKeyed lists k under name, replacing anything registered under
+// name before
+func RegisterKeyed[K comparable](c *Console, name string, k *rateflow.Keyed[K], opts KeyedOptions[K]) {
+	if opts.Label == nil {
+		opts.Label = func(key K) string { return fmt.Sprint(key) }
+	}
+	if opts.Parse == nil {
+		opts.Parse = func(s string) (K, error) {
+			key, ok := any(s).(K)
+			if !ok {
+				return key, fmt.Errorf("admin: %s has no key parser", name)
+			}
+			return key, nil
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.limiters, name)
+	c.keyed[name] = keyed[K]{k, opts}
+}
+
+type keyed[K comparable] struct {
+	k    *rateflow.Keyed[K]
+	opts KeyedOptions[K]
+}
+
+func (kd keyed[K]) state(name string, top int) KeyedState {
+	stats := kd.k.Stats()
+	s := KeyedState{Name: name, Keys: int(stats.Active), Created: stats.Created, Evicted: stats.Evicted, Top: []LimiterState{}}
+	for _, u := range kd.k.Top(top, rateflow.ByDenied) {
+		lim, ok := kd.k.Lookup(u.Key)
+		if !ok {
+			continue
+		}
+		st := limiterState(name, lim)
+		st.Key = kd.opts.Label(u.Key)
+		s.Top = append(s.Top, st)
+	}
+	return s
+}
+
+func (kd keyed[K]) lookup(s string) (rateflow.Limiter, error) {
+	key, err := kd.opts.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	lim, ok := kd.k.Lookup(key)
+	if !ok {
+		return nil, errNotFound
+	}
+	return lim, nil
+}
+
+func (kd keyed[K]) purge(s string) (bool, error) {
+	key, err := kd.opts.Parse(s)
+	if err != nil {
+		return false, err
+	}
+	return kd.k.Purge(key), nil
+}
+
+// limiterState returns the state of lim, listed under name
+func limiterState(name string, lim rateflow.Limiter) LimiterState {
+	stats := lim.Stats()
+	denials := make(map[string]uint64)
+	for _, why := range []rateflow.DenialReason{rateflow.DeniedLimited, rateflow.DeniedExceeds, rateflow.DeniedCanceled, rateflow.DeniedStore, rateflow.DeniedShed} {
+		if n := stats.Denials.Count(why); n > 0 {
+			denials[why.String()] = n
+		}
+	}
+	return LimiterState{
+		Name:      name,
+		Algorithm: lim.Algorithm().String(),
+		Limit:     Limit(lim.Limit()),
+		Burst:     lim.Burst(),
+		Tokens:    stats.Tokens,
+		Stats: StatsState{
+			Allowed:      stats.Allowed,
+			Denied:       stats.Denied,
+			Denials:      denials,
+			Waiting:      stats.Waiting,
+			Waits:        stats.Waits.Count,
+			WaitMean:     stats.Waits.Mean().Seconds(),
+			WaitP50:      seconds(stats.Waits.Quantile(0.5)),
+			WaitP99:      seconds(stats.Waits.Quantile(0.99)),
+			LastDecision: stats.LastDecision,
+		},
+	}
+}
+
+// seconds converts d for JSON, which has no infinity, as -1 for
+// InfDuration
+func seconds(d time.Duration) float64 {
+	if d == rateflow.InfDuration {
+		return -1
+	}
+	return d.Seconds()
+}
+
+// Overview returns the state of every registered limiter, with the top
+// keys of every Keyed
+func (c *Console) Overview(top int) Overview {
+	c.mu.Lock()
+	limiters := make(map[string]rateflow.Limiter, len(c.limiters))
+	for name, lim := range c.limiters {
+		limiters[name] = lim
+	}
+	keyedSources := make(map[string]keyedSource, len(c.keyed))
+	for name, k := range c.keyed {
+		keyedSources[name] = k
+	}
+	c.mu.Unlock()
+
+	o := Overview{Limiters: []LimiterState{}, Keyed: []KeyedState{}}
+	for name, lim := range limiters {
+		o.Limiters = append(o.Limiters, limiterState(name, lim))
+	}
+	for name, k := range keyedSources {
+		o.Keyed = append(o.Keyed, k.state(name, top))
+	}
+	sort.Slice(o.Limiters, func(i, j int) bool { return o.Limiters[i].Name < o.Limiters[j].Name })
+	sort.Slice(o.Keyed, func(i, j int) bool { return o.Keyed[i].Name < o.Keyed[j].Name })
+	return o
+}
+
+// find returns the limiter or Keyed registered under name
+func (c *Console) find(name string) (rateflow.Limiter, keyedSource, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if lim, ok := c.limiters[name]; ok {
+		return lim, nil, true
+	}
+	k, ok := c.keyed[name]
+	return nil, k, ok
+}
+
+// target returns the limiter a request for name acts on: the one
+// registered under name, or the one of ?key for a Keyed
+func (c *Console) target(name string, req *http.Request) (rateflow.Limiter, error) {
+	lim, k, ok := c.find(name)
+	if !ok {
+		return nil, errNotFound
+	}
+	key, hasKey := req.URL.Query()["key"]
+	if k == nil {
+		if hasKey {
+			return nil, fmt.Errorf("admin: %s has no keys", name)
+		}
+		return lim, nil
+	}
+	if !hasKey {
+		return nil, errNoKey
+	}
+	return k.lookup(key[0])
+}
+
+// ServeHTTP serves the routes listed in the package documentation
+func (c *Console) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name, op, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+
+	action := Read
+	switch op {
+	case "":
+	case "limit":
+		action = SetLimit
+	case "reset":
+		action = Reset
+	case "purge":
+		action = Purge
+	default:
+		http.NotFound(w, req)
+		return
+	}
+	if (action == Read) != (req.Method == http.MethodGet || req.Method == http.MethodHead) {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.authorize(req, action) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch action {
+	case Read:
+		c.serveRead(w, req, name)
+	case SetLimit:
+		c.serveSetLimit(w, req, name)
+	case Reset:
+		lim, err := c.target(name, req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		lim.Reset()
+		st := limiterState(name, lim)
+		st.Key = req.URL.Query().Get("key")
+		writeJSON(w, st)
+	case Purge:
+		c.servePurge(w, req, name)
+	}
+}
+
+func (c *Console) serveRead(w http.ResponseWriter, req *http.Request, name string) {
+	top := defaultTop
+	if s := req.URL.Query().Get("top"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, "top must be a whole number", http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+	if name == "" {
+		writeJSON(w, c.Overview(top))
+		return
+	}
+
+	_, k, ok := c.find(name)
+	switch {
+	case !ok:
+		writeError(w, errNotFound)
+	case k != nil && !req.URL.Query().Has("key"):
+		writeJSON(w, k.state(name, top))
+	default:
+		lim, err := c.target(name, req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		st := limiterState(name, lim)
+		st.Key = req.URL.Query().Get("key")
+		writeJSON(w, st)
+	}
+}
+
+func (c *Console) serveSetLimit(w http.ResponseWriter, req *http.Request, name string) {
+	var in Limits
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if in.Burst != nil && *in.Burst < 0 {
+		http.Error(w, "burst must not be negative", http.StatusBadRequest)
+		return
+	}
+	lim, err := c.target(name, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if in.Limit != nil {
+		lim.SetLimit(rateflow.Limit(*in.Limit))
+	}
+	if in.Burst != nil {
+		lim.SetBurst(*in.Burst)
+	}
+	st := limiterState(name, lim)
+	st.Key = req.URL.Query().Get("key")
+	writeJSON(w, st)
+}
+
+func (c *Console) servePurge(w http.ResponseWriter, req *http.Request, name string) {
+	_, k, ok := c.find(name)
+	if !ok {
+		writeError(w, errNotFound)
+		return
+	}
+	if k == nil {
+		http.Error(w, fmt.Sprintf("admin: %s has no keys", name), http.StatusBadRequest)
+		return
+	}
+	key := req.URL.Query()["key"]
+	if len(key) == 0 {
+		writeError(w, errNoKey)
+		return
+	}
+	purged, err := k.purge(key[0])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !purged {
+		writeError(w, errNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers 404 for unknown names and keys, 400 otherwise
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
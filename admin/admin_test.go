@@ -0,0 +1,122 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func do(t *testing.T, h http.Handler, method, target, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestConsoleOverview(t *testing.T) {
+	c := New(nil)
+	api := rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Inf, 5)
+	c.Register("api", api)
+	users := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, rateflow.Every(time.Hour), 1)
+	RegisterKeyed(c, "users", users, KeyedOptions[string]{})
+	users.AllowKey("alice")
+	users.AllowKey("bob")
+	users.AllowKey("bob")
+
+	rec := do(t, c, "GET", "/", "", "")
+	var o Overview
+	if err := json.NewDecoder(rec.Body).Decode(&o); err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Limiters) != 1 || o.Limiters[0].Name != "api" || rateflow.Limit(o.Limiters[0].Limit) != rateflow.Inf {
+		t.Errorf("Limiters = %+v", o.Limiters)
+	}
+	if len(o.Keyed) != 1 || o.Keyed[0].Keys != 2 || o.Keyed[0].Top[0].Key != "bob" {
+		t.Fatalf("Keyed = %+v, want bob first", o.Keyed)
+	}
+	if d := o.Keyed[0].Top[0].Stats.Denials["limited"]; d != 1 {
+		t.Errorf("bob's limited denials = %d, want 1", d)
+	}
+
+	rec = do(t, c, "GET", "/users?key=carol", "", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown key answered %d, want 404", rec.Code)
+	}
+	if _, ok := users.Lookup("carol"); ok {
+		t.Error("expected reading a key not to create its limiter")
+	}
+	if rec := do(t, c, "GET", "/users?key=alice", "", ""); !strings.Contains(rec.Body.String(), `"key":"alice"`) {
+		t.Errorf("key answered %s", rec.Body)
+	}
+}
+
+func TestConsoleChanges(t *testing.T) {
+	c := New(BearerToken("secret"))
+	api := rateflow.NewLimiter(rateflow.TokenBucket, 1, 1)
+	c.Register("api", api)
+	users := rateflow.NewKeyedLimiter[int](rateflow.TokenBucket, 1, 1)
+	RegisterKeyed(c, "users", users, KeyedOptions[int]{})
+	users.AllowKey(7)
+
+	if rec := do(t, c, "GET", "/", "", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("read without token answered %d, want 403", rec.Code)
+	}
+	if rec := do(t, c, "POST", "/api/limit", "wrong", `{"limit": 5}`); rec.Code != http.StatusForbidden {
+		t.Errorf("change with a wrong token answered %d, want 403", rec.Code)
+	}
+	if rec := do(t, c, "GET", "/api/limit", "secret", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET of a change answered %d, want 405", rec.Code)
+	}
+
+	if rec := do(t, c, "POST", "/api/limit", "secret", `{"limt": 5}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown field answered %d, want 400", rec.Code)
+	}
+	if rec := do(t, c, "POST", "/api/limit", "secret", `{"limit": 5`+strings.Repeat(" ", maxBody)+`}`); rec.Code != http.StatusBadRequest || api.Limit() != 1 {
+		t.Errorf("oversized body answered %d", rec.Code)
+	}
+	if rec := do(t, c, "POST", "/api/limit", "secret", `{"limit": "inf", "burst": 3}`); rec.Code != http.StatusOK {
+		t.Fatalf("limit change answered %d: %s", rec.Code, rec.Body)
+	}
+	if api.Limit() != rateflow.Inf || api.Burst() != 3 {
+		t.Errorf("limit = %v, burst = %d, want inf and 3", api.Limit(), api.Burst())
+	}
+
+	api.Allow()
+	if rec := do(t, c, "POST", "/api/reset", "secret", ""); rec.Code != http.StatusOK || api.Tokens() != 3 {
+		t.Errorf("reset answered %d and left %v tokens", rec.Code, api.Tokens())
+	}
+
+	if rec := do(t, c, "POST", "/users/purge?key=7", "secret", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("purge of an int key without a parser answered %d, want 400", rec.Code)
+	}
+	RegisterKeyed(c, "users", users, KeyedOptions[int]{Parse: func(s string) (int, error) { return 7, nil }})
+	if rec := do(t, c, "POST", "/users/purge?key=7", "secret", ""); rec.Code != http.StatusNoContent || users.Len() != 0 {
+		t.Errorf("purge answered %d with %d keys left", rec.Code, users.Len())
+	}
+	if rec := do(t, c, "POST", "/users/purge?key=7", "secret", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second purge answered %d, want 404", rec.Code)
+	}
+	if rec := do(t, c, "POST", "/users/limit", "secret", `{"limit": 2}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Keyed change without a key answered %d, want 400", rec.Code)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	c := New(nil)
+	c.Register("api", rateflow.NewLimiter(rateflow.TokenBucket, 1, 1))
+	if rec := do(t, c, "POST", "/api/reset", "", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("change on a read-only console answered %d, want 403", rec.Code)
+	}
+	if rec := do(t, c, "GET", "/nope", "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown name answered %d, want 404", rec.Code)
+	}
+}
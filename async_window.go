@@ -0,0 +1,163 @@
+package rateflow
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CounterStore keeps counters shared by many processes, e.g. Redis
+// INCRBY. IncrBy adds delta to key, creating it with the given ttl if it
+// does not exist, and returns the new total
+type CounterStore interface {
+	IncrBy(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+}
+
+// AsyncWindow is a fixed window of max events shared by many replicas
+// through a CounterStore, without a store call per decision. Events are
+// counted locally and flushed to the store on an interval; between
+// flushes a replica estimates the global count as the total it last saw,
+// plus its own unflushed events, plus what the others added at the rate
+// observed over recent flushes. Each flush corrects the estimate, and the
+// error of the last prediction is reported by Drift. Windows start at
+// multiples of the window since the Unix epoch on every replica
+type AsyncWindow struct {
+	store  CounterStore
+	key    string
+	max    int64
+	window time.Duration
+
+	mu         sync.Mutex
+	start      time.Time // start of the current window
+	global     int64     // store total at the last flush
+	pending    int64     // events admitted since the last flush
+	flushedAt  time.Time
+	othersRate float64 // events per second added by other replicas
+	sampled    bool    // othersRate has been measured
+	drift      int64
+
+	flushMu sync.Mutex
+}
+
+// NewAsyncWindow creates a window of max events per window for key.
+// Call Run, or Flush periodically, to share counts with other replicas
+func NewAsyncWindow(store CounterStore, key string, max int, window time.Duration) *AsyncWindow {
+	return &AsyncWindow{store: store, key: key, max: int64(max), window: window}
+}
+
+// roll moves to the window around t, dropping the counts of the last
+// one. w.mu must be held
+func (w *AsyncWindow) roll(t time.Time) {
+	start := time.Unix(0, t.UnixNano()/int64(w.window)*int64(w.window))
+	if start.Equal(w.start) {
+		return
+	}
+	w.start, w.global, w.pending, w.flushedAt = start, 0, 0, start
+}
+
+// estimate returns the expected global count at t. w.mu must be held
+func (w *AsyncWindow) estimate(t time.Time) int64 {
+	others := w.othersRate * t.Sub(w.flushedAt).Seconds()
+	if others < 0 {
+		others = 0
+	}
+	return w.global + w.pending + int64(others)
+}
+
+// Estimate returns the expected number of events in the window around t
+// across all replicas
+func (w *AsyncWindow) Estimate(t time.Time) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.roll(t)
+	return int(w.estimate(t))
+}
+
+// Drift returns how far the last flush found the global count from the
+// estimate, positive if other replicas admitted more than predicted
+func (w *AsyncWindow) Drift() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return int(w.drift)
+}
+
+// Allow is shorthand for AllowN(time.Now(), 1)
+func (w *AsyncWindow) Allow() bool {
+	return w.AllowN(time.Now(), 1)
+}
+
+// AllowN reports whether n events may happen at t by the estimated
+// global count, without contacting the store
+func (w *AsyncWindow) AllowN(t time.Time, n int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.roll(t)
+	if w.estimate(t)+int64(n) > w.max {
+		return false
+	}
+	w.pending += int64(n)
+	return true
+}
+
+// Flush is shorthand for FlushAt(ctx, time.Now())
+func (w *AsyncWindow) Flush(ctx context.Context) error {
+	return w.FlushAt(ctx, time.Now())
+}
+
+// FlushAt adds the events admitted since the last flush to the store
+// and corrects the estimate with the total it returns. If the store
+// fails, the events are kept for the next flush
+func (w *AsyncWindow) FlushAt(ctx context.Context, t time.Time) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	w.roll(t)
+	start, delta, predicted := w.start, w.pending, w.estimate(t)
+	w.pending = 0
+	w.mu.Unlock()
+
+	idx := start.UnixNano() / int64(w.window)
+	total, err := w.store.IncrBy(ctx, w.key+":"+strconv.FormatInt(idx, 10), delta, start.Add(w.window).Sub(t))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.start.Equal(start) {
+		// The window ended during the call; its counts no longer matter
+		return err
+	}
+	if err != nil {
+		w.pending += delta
+		return err
+	}
+
+	w.drift = total - predicted
+	others := float64(total - w.global - delta)
+	if elapsed := t.Sub(w.flushedAt).Seconds(); elapsed > 0 && others >= 0 {
+		// Halve the weight of older samples so the rate follows changes
+		// in traffic within a few flushes
+		if rate := others / elapsed; w.sampled {
+			w.othersRate = (w.othersRate + rate) / 2
+		} else {
+			w.othersRate, w.sampled = rate, true
+		}
+	}
+	w.global, w.flushedAt = total, t
+	return nil
+}
+
+// Run calls Flush every interval until ctx is done, then returns
+// ctx.Err()
+func (w *AsyncWindow) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.Flush(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memCounters is a CounterStore in memory
+type memCounters struct {
+	mu     sync.Mutex
+	counts map[string]int64
+	ttls   map[string]time.Duration
+	err    error
+}
+
+func newMemCounters() *memCounters {
+	return &memCounters{counts: make(map[string]int64), ttls: make(map[string]time.Duration)}
+}
+
+func (m *memCounters) IncrBy(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return 0, m.err
+	}
+	if _, ok := m.ttls[key]; !ok {
+		m.ttls[key] = ttl
+	}
+	m.counts[key] += delta
+	return m.counts[key], nil
+}
+
+func TestAsyncWindow(t *testing.T) {
+	store := newMemCounters()
+	ctx := context.Background()
+	a := NewAsyncWindow(store, "k", 100, time.Minute)
+	b := NewAsyncWindow(store, "k", 100, time.Minute)
+	start := time.Unix(0, 0).Add(1000 * time.Minute)
+
+	// b admits 40 events in the first 10s and flushes them
+	b.AllowN(start.Add(10*time.Second), 40)
+	b.FlushAt(ctx, start.Add(10*time.Second))
+
+	a.AllowN(start.Add(10*time.Second), 10)
+	if err := a.FlushAt(ctx, start.Add(10*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Estimate(start.Add(10 * time.Second)); got != 50 {
+		t.Errorf("Estimate = %d, want the global total of 50", got)
+	}
+	if got := a.Drift(); got != 40 {
+		t.Errorf("Drift = %d, want the 40 events a did not know of", got)
+	}
+
+	// a saw others add 40 events in 10s and expects about 4 per second
+	// more until its next flush
+	if got := a.Estimate(start.Add(15 * time.Second)); got != 70 {
+		t.Errorf("Estimate = %d, want 70 from the observed rate", got)
+	}
+	if a.AllowN(start.Add(20*time.Second), 20) {
+		t.Error("the estimate should keep a from overshooting the window")
+	}
+	if !a.AllowN(start.Add(20*time.Second), 10) {
+		t.Error("a should admit what the estimate leaves room for")
+	}
+
+	next := start.Add(time.Minute)
+	if got := a.Estimate(next); got != 0 {
+		t.Errorf("Estimate = %d, want a new window to start empty", got)
+	}
+	if ttl := store.ttls["k:1000"]; ttl != 50*time.Second {
+		t.Errorf("ttl = %v, want the rest of the window", ttl)
+	}
+}
+
+func TestAsyncWindowFlushError(t *testing.T) {
+	store := newMemCounters()
+	w := NewAsyncWindow(store, "k", 10, time.Minute)
+	ctx := context.Background()
+	now := time.Unix(0, 0).Add(time.Hour)
+
+	w.AllowN(now, 3)
+	store.err = errors.New("store down")
+	if err := w.FlushAt(ctx, now); err == nil {
+		t.Fatal("expected the store error")
+	}
+	store.err = nil
+	if err := w.FlushAt(ctx, now.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.counts["k:60"]; got != 3 {
+		t.Errorf("store count = %d, want the events of the failed flush kept", got)
+	}
+	if w.AllowN(now.Add(time.Second), 8) {
+		t.Error("expected the window to be limited to 10")
+	}
+}
@@ -85,3 +85,15 @@ func BenchmarkAllAlgorithmsComparison(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkKeyedAllowParallel(b *testing.B) {
+	k := NewKeyedLimiter[int](TokenBucket, Limit(1000), 100)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			k.AllowKey(i % 1024)
+			i++
+		}
+	})
+}
@@ -0,0 +1,268 @@
+package rateflow
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// BreakerState is the state of a Breaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through to the store
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails every call at once with ErrBreakerOpen
+	BreakerOpen
+	// BreakerHalfOpen lets one probe call through to find out whether
+	// the store recovered
+	BreakerHalfOpen
+)
+
+// String returns the name of s
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerConfig configures a Breaker
+type BreakerConfig struct {
+	// Failures is the number of failed calls in a row that opens the
+	// breaker, 5 if 0
+	Failures int
+	// Timeout bounds each call to the store; calls that take longer are
+	// cancelled and count as failures. 0 leaves calls bound only by
+	// their context
+	Timeout time.Duration
+	// Cooldown is how long the breaker stays open before probing the
+	// store, 5s if 0
+	Cooldown time.Duration
+	// OnStateChange, if set, is called on every change of state, e.g. to
+	// log or alert when the store is cut off. It may call State or Stats
+	OnStateChange func(from, to BreakerState)
+	// Clock defaults to the system clock
+	Clock Clock
+}
+
+// BreakerStats counts the calls of a Breaker
+type BreakerStats struct {
+	State BreakerState
+	// Calls is the number of calls let through to the store, probes
+	// included, and Failures the number of them that failed
+	Calls    uint64
+	Failures uint64
+	// Rejected is the number of calls failed with ErrBreakerOpen
+	Rejected uint64
+	// Trips is the number of times the breaker opened
+	Trips uint64
+}
+
+// Breaker is a RemoteLimiter that stops calling a store that keeps
+// failing or timing out, so callers do not each wait out a dead store.
+// After Failures failed calls in a row it opens and fails calls with
+// ErrBreakerOpen for Cooldown, then lets a single probe through: if it
+// succeeds the breaker closes, otherwise it opens again. Wrapped in a
+// FailSafe with FailLocal, limiting goes local-only while the breaker is
+// open:
+//
+//	lim := NewFailSafe(NewBreaker(store, BreakerConfig{Timeout: 50 * time.Millisecond}), FailLocal, local)
+type Breaker struct {
+	remote RemoteLimiter
+	cfg    BreakerConfig
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int       // failed calls in a row
+	openedAt time.Time // when the breaker last opened
+	probing  bool      // a half-open probe is in flight
+
+	calls    atomic.Uint64
+	failed   atomic.Uint64
+	rejected atomic.Uint64
+	trips    atomic.Uint64
+}
+
+var _ RemoteLimiter = (*Breaker)(nil)
+
+// NewBreaker wraps remote in a closed circuit breaker
+func NewBreaker(remote RemoteLimiter, cfg BreakerConfig) *Breaker {
+	if cfg.Failures <= 0 {
+		cfg.Failures = 5
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 5 * time.Second
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = limiter.SystemClock{}
+	}
+	return &Breaker{remote: remote, cfg: cfg}
+}
+
+// State returns the current state, moving from open to half-open once
+// the cooldown is over
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && !b.cfg.Clock.Now().Before(b.openedAt.Add(b.cfg.Cooldown)) {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Stats returns the counts of calls so far
+func (b *Breaker) Stats() BreakerStats {
+	return BreakerStats{
+		State:    b.State(),
+		Calls:    b.calls.Load(),
+		Failures: b.failed.Load(),
+		Rejected: b.rejected.Load(),
+		Trips:    b.trips.Load(),
+	}
+}
+
+// stateChange is a move from one state to another, zero if there was none
+type stateChange struct {
+	from, to BreakerState
+}
+
+// setState moves to state and returns the change, to be passed to notify
+// once b.mu is released. b.mu must be held
+func (b *Breaker) setState(state BreakerState) stateChange {
+	from := b.state
+	if from == state {
+		return stateChange{}
+	}
+	b.state = state
+	if state == BreakerOpen {
+		b.openedAt = b.cfg.Clock.Now()
+		b.trips.Add(1)
+	}
+	return stateChange{from, state}
+}
+
+// notify calls OnStateChange for *c, if it is a change. It runs without
+// b.mu held so the callback may call State or Stats
+func (b *Breaker) notify(c *stateChange) {
+	if c.from != c.to && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(c.from, c.to)
+	}
+}
+
+// admit reports whether a call may go through, and whether it is the
+// probe of a half-open breaker
+func (b *Breaker) admit() (ok, probe bool) {
+	var change stateChange
+	defer b.notify(&change)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerClosed:
+		return true, false
+	case BreakerOpen:
+		if b.cfg.Clock.Now().Before(b.openedAt.Add(b.cfg.Cooldown)) {
+			return false, false
+		}
+		change = b.setState(BreakerHalfOpen)
+	}
+	if b.probing {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// done records the outcome of a call admitted by admit. Calls admitted
+// while closed that end after the breaker opened count as failures but
+// change nothing; only the probe decides when to close
+func (b *Breaker) done(err error, probe bool) {
+	var change stateChange
+	defer b.notify(&change)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+	}
+	if err != nil {
+		b.failed.Add(1)
+	}
+	switch {
+	case probe && b.state == BreakerHalfOpen:
+		b.failures = 0
+		if err != nil {
+			change = b.setState(BreakerOpen)
+		} else {
+			change = b.setState(BreakerClosed)
+		}
+	case b.state != BreakerClosed:
+	case err == nil:
+		b.failures = 0
+	default:
+		b.failures++
+		if b.failures >= b.cfg.Failures {
+			change = b.setState(BreakerOpen)
+		}
+	}
+}
+
+// release ends a call admitted by admit without recording an outcome
+func (b *Breaker) release(probe bool) {
+	if probe {
+		b.mu.Lock()
+		b.probing = false
+		b.mu.Unlock()
+	}
+}
+
+// call runs fn on the store through the breaker
+func (b *Breaker) call(ctx context.Context, fn func(ctx context.Context) error) error {
+	ok, probe := b.admit()
+	if !ok {
+		b.rejected.Add(1)
+		return ErrBreakerOpen
+	}
+	b.calls.Add(1)
+	callCtx := ctx
+	if b.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, b.cfg.Timeout)
+		defer cancel()
+	}
+	err := fn(callCtx)
+	if err != nil && ctx.Err() != nil {
+		// The caller gave up; that says nothing about the store
+		b.release(probe)
+		return err
+	}
+	b.done(err, probe)
+	return err
+}
+
+// AllowN asks the store whether n events for key may happen at t, or
+// fails with ErrBreakerOpen without calling it
+func (b *Breaker) AllowN(ctx context.Context, key string, t time.Time, n int) (ok bool, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		ok, err = b.remote.AllowN(ctx, key, t, n)
+		return err
+	})
+	return ok, err
+}
+
+// ReserveN reserves n events for key at t on the store, or fails with
+// ErrBreakerOpen without calling it
+func (b *Breaker) ReserveN(ctx context.Context, key string, t time.Time, n int) (r *RemoteReservation, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		r, err = b.remote.ReserveN(ctx, key, t, n)
+		return err
+	})
+	return r, err
+}
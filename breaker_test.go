@@ -0,0 +1,163 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	clock := newFakeClock()
+	remote := &limiterRemote{lim: NewLimiter(TokenBucket, 100, 100), err: errors.New("store down")}
+	var changes []string
+	b := NewBreaker(remote, BreakerConfig{
+		Failures: 3,
+		Cooldown: time.Second,
+		Clock:    clock,
+		OnStateChange: func(from, to BreakerState) {
+			changes = append(changes, from.String()+"->"+to.String())
+		},
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := b.AllowN(ctx, "k", clock.Now(), 1); err != remote.err {
+			t.Fatalf("call %d: err = %v, want the store error", i, err)
+		}
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("State = %v, want open after 3 failures", b.State())
+	}
+	if _, err := b.ReserveN(ctx, "k", clock.Now(), 1); err != ErrBreakerOpen {
+		t.Fatalf("err = %v, want ErrBreakerOpen", err)
+	}
+
+	// The probe fails and the breaker opens again for another cooldown
+	clock.Advance(time.Second)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("State = %v, want half-open after the cooldown", b.State())
+	}
+	if _, err := b.AllowN(ctx, "k", clock.Now(), 1); err != remote.err {
+		t.Fatalf("probe err = %v, want the store error", err)
+	}
+	if _, err := b.AllowN(ctx, "k", clock.Now(), 1); err != ErrBreakerOpen {
+		t.Fatalf("err = %v, want ErrBreakerOpen after a failed probe", err)
+	}
+
+	remote.err = nil
+	clock.Advance(time.Second)
+	if ok, err := b.AllowN(ctx, "k", clock.Now(), 1); err != nil || !ok {
+		t.Fatalf("probe = %v, %v, want admitted", ok, err)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("State = %v, want closed after a good probe", b.State())
+	}
+
+	want := BreakerStats{State: BreakerClosed, Calls: 5, Failures: 4, Rejected: 2, Trips: 2}
+	if got := b.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+	wantChanges := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if len(changes) != len(wantChanges) {
+		t.Fatalf("changes = %v, want %v", changes, wantChanges)
+	}
+	for i := range changes {
+		if changes[i] != wantChanges[i] {
+			t.Errorf("changes = %v, want %v", changes, wantChanges)
+			break
+		}
+	}
+}
+
+func TestBreakerCallbackReadsState(t *testing.T) {
+	clock := newFakeClock()
+	remote := &limiterRemote{lim: NewLimiter(TokenBucket, 100, 100), err: errors.New("store down")}
+	var (
+		b      *Breaker
+		states []BreakerState
+	)
+	b = NewBreaker(remote, BreakerConfig{
+		Failures: 1,
+		Clock:    clock,
+		OnStateChange: func(from, to BreakerState) {
+			states = append(states, b.State(), b.Stats().State)
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.AllowN(context.Background(), "k", clock.Now(), 1)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("OnStateChange calling State deadlocked")
+	}
+	if len(states) != 2 || states[0] != BreakerOpen || states[1] != BreakerOpen {
+		t.Errorf("states seen by the callback = %v, want open twice", states)
+	}
+}
+
+func TestBreakerLateSuccess(t *testing.T) {
+	clock := newFakeClock()
+	remote := &limiterRemote{lim: NewLimiter(TokenBucket, 100, 100), err: errors.New("store down")}
+	b := NewBreaker(remote, BreakerConfig{Failures: 1, Clock: clock})
+
+	// A call admitted while closed ends well after the breaker opened
+	ok, probe := b.admit()
+	if !ok || probe {
+		t.Fatalf("admit = %v, %v, want a regular call", ok, probe)
+	}
+	b.AllowN(context.Background(), "k", clock.Now(), 1)
+	b.done(nil, probe)
+	if b.State() != BreakerOpen {
+		t.Errorf("State = %v, want open until a probe succeeds", b.State())
+	}
+}
+
+// slowRemote blocks every call until its context is done
+type slowRemote struct{ RemoteLimiter }
+
+func (slowRemote) AllowN(ctx context.Context, key string, t time.Time, n int) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func TestBreakerTimeout(t *testing.T) {
+	b := NewBreaker(slowRemote{}, BreakerConfig{Failures: 1, Timeout: time.Millisecond})
+	if _, err := b.AllowN(context.Background(), "k", time.Now(), 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the timeout", err)
+	}
+	if b.State() != BreakerOpen {
+		t.Errorf("State = %v, want a slow store to open the breaker", b.State())
+	}
+
+	// Callers giving up do not count against the store
+	b = NewBreaker(slowRemote{}, BreakerConfig{Failures: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.AllowN(ctx, "k", time.Now(), 1)
+	if b.State() != BreakerClosed {
+		t.Errorf("State = %v, want closed after a cancelled call", b.State())
+	}
+}
+
+func TestBreakerFailLocal(t *testing.T) {
+	remote := &limiterRemote{lim: NewLimiter(TokenBucket, 100, 100), err: errors.New("store down")}
+	local := NewKeyedLimiter[string](TokenBucket, Every(time.Hour), 1)
+	lim := NewFailSafe(NewBreaker(remote, BreakerConfig{Failures: 1}), FailLocal, local)
+	ctx := context.Background()
+
+	now := time.Now()
+	if ok, err := lim.AllowN(ctx, "k", now, 1); err != nil || !ok {
+		t.Fatalf("AllowN = %v, %v, want admitted by the local limiter", ok, err)
+	}
+	if ok, _ := lim.AllowN(ctx, "k", now, 1); ok {
+		t.Error("expected the local limit to apply while the breaker is open")
+	}
+	if got := lim.Stats().LastError; got != ErrBreakerOpen {
+		t.Errorf("LastError = %v, want ErrBreakerOpen", got)
+	}
+}
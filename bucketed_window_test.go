@@ -0,0 +1,44 @@
+package rateflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketedSlidingWindow(t *testing.T) {
+	lim := NewBucketedSlidingWindow(10, time.Second, 10)
+	// Start on a bucket boundary so the weighting is predictable
+	start := time.Now().Truncate(100 * time.Millisecond).Add(100 * time.Millisecond)
+
+	if !lim.AllowN(start, 10) {
+		t.Fatal("expected the full window to be available")
+	}
+	if lim.AllowN(start.Add(500*time.Millisecond), 1) {
+		t.Error("expected the window to be full half a second later")
+	}
+
+	// Once the first bucket leaves the window capacity returns gradually
+	at := start.Add(1050 * time.Millisecond)
+	if tokens := lim.TokensAt(at); tokens < 4.9 || tokens > 5.1 {
+		t.Errorf("expected ~5 tokens with half the oldest bucket expired, got %f", tokens)
+	}
+	if !lim.AllowN(start.Add(1100*time.Millisecond), 10) {
+		t.Error("expected the window to be empty after a full window")
+	}
+}
+
+func TestBucketedSlidingWindowSettings(t *testing.T) {
+	lim := NewBucketedSlidingWindow(100, 10*time.Second, 20)
+
+	if lim.Window() != 10*time.Second || lim.Buckets() != 20 {
+		t.Errorf("unexpected window %v with %d buckets", lim.Window(), lim.Buckets())
+	}
+	if lim.Limit() != Limit(10) {
+		t.Errorf("expected Limit() = 10, got %v", lim.Limit())
+	}
+
+	lim.SetLimit(Limit(5))
+	if lim.Burst() != 50 || lim.Window() != 10*time.Second {
+		t.Errorf("expected SetLimit to keep the window, got %d per %v", lim.Burst(), lim.Window())
+	}
+}
@@ -0,0 +1,75 @@
+package rateflow
+
+import "math"
+
+// burstScaling shrinks per-key bursts as a global limiter fills up
+type burstScaling struct {
+	global Limiter
+	floor  float64
+}
+
+// SetBurstScaling makes every key's burst follow global's utilization:
+// full while global is idle, shrinking linearly to floor (0..1) of it
+// when global has no capacity left, and growing back as global drains.
+// Bursts go through in quiet periods without risking aggregate overload.
+// Bursts are adjusted by the per-key shortcuts as keys are used, once
+// they are a whole token off. Window algorithms keep their burst: it sets
+// their window count, and with it the window length. A nil global turns
+// scaling off and restores every key's full burst
+func (k *Keyed[K]) SetBurstScaling(global Limiter, floor float64) {
+	if global == nil {
+		k.scaling.Store(nil)
+		for _, s := range k.shards {
+			s.mu.RLock()
+			for _, e := range s.limiters {
+				if full := int(e.full.Load()); e.lim.Burst() != full {
+					e.lim.SetBurst(full)
+				}
+			}
+			s.mu.RUnlock()
+		}
+		return
+	}
+	if floor < 0 {
+		floor = 0
+	}
+	if floor > 1 {
+		floor = 1
+	}
+	k.scaling.Store(&burstScaling{global: global, floor: floor})
+}
+
+// use returns the limiter for key with its burst scaled to the current
+// global utilization
+func (k *Keyed[K]) use(key K) Limiter {
+	e := k.entry(key)
+	sc := k.scaling.Load()
+	if sc == nil {
+		return e.lim
+	}
+
+	if !scalesBurst(e.lim.Algorithm()) {
+		return e.lim
+	}
+
+	u := utilization(sc.global.Tokens(), sc.global.Burst())
+	full := float64(e.full.Load())
+	target := full * (1 - u*(1-sc.floor))
+	if target < 1 {
+		target = 1
+	}
+	// Rounding alone would flip a target near .5 back and forth
+	if math.Abs(target-float64(e.lim.Burst())) >= 1 {
+		e.lim.SetBurst(int(target + 0.5))
+	}
+	return e.lim
+}
+
+// scalesBurst reports whether SetBurstScaling adjusts limiters of algo
+func scalesBurst(algo Algorithm) bool {
+	switch algo {
+	case SlidingWindow, FixedWindow, MultiWindow, CalendarQuota:
+		return false
+	}
+	return true
+}
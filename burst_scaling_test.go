@@ -0,0 +1,48 @@
+package rateflow
+
+import "testing"
+
+func TestKeyedBurstScaling(t *testing.T) {
+	global := NewLimiter(TokenBucket, Limit(1), 10)
+	k := NewKeyedLimiter[string](TokenBucket, Limit(1), 10)
+	k.SetBurstScaling(global, 0.2)
+
+	k.AllowKey("alice")
+	if b := k.Get("alice").Burst(); b != 10 {
+		t.Errorf("expected the full burst while global is idle, got %d", b)
+	}
+
+	for global.Allow() {
+	}
+	k.AllowKey("alice")
+	if b := k.Get("alice").Burst(); b != 2 {
+		t.Errorf("expected the burst to shrink to the floor, got %d", b)
+	}
+
+	global.Reset()
+	k.AllowKey("alice")
+	if b := k.Get("alice").Burst(); b != 10 {
+		t.Errorf("expected the burst to grow back, got %d", b)
+	}
+
+	for global.Allow() {
+	}
+	k.AllowKey("alice")
+	k.SetBurstScaling(nil, 0)
+	if b := k.Get("alice").Burst(); b != 10 {
+		t.Errorf("expected the full burst once scaling is off, got %d", b)
+	}
+}
+
+func TestKeyedBurstScalingWindow(t *testing.T) {
+	global := NewLimiter(TokenBucket, Limit(1), 10)
+	k := NewKeyedLimiter[string](FixedWindow, Limit(1), 10)
+	k.SetBurstScaling(global, 0.2)
+
+	for global.Allow() {
+	}
+	k.AllowKey("alice")
+	if b := k.Get("alice").Burst(); b != 10 {
+		t.Errorf("expected a window to keep its burst, got %d", b)
+	}
+}
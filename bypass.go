@@ -0,0 +1,118 @@
+package rateflow
+
+import (
+	"net/netip"
+	"sync"
+)
+
+// KeyList is a set of keys and, for string or netip.Addr keys holding IP
+// addresses, CIDR prefixes. The zero value is an empty list. It is safe
+// for concurrent use and may be updated while a Keyed is using it
+type KeyList[K comparable] struct {
+	mu       sync.RWMutex
+	keys     map[K]struct{}
+	prefixes []netip.Prefix
+}
+
+// NewKeyList creates a list holding keys
+func NewKeyList[K comparable](keys ...K) *KeyList[K] {
+	l := &KeyList[K]{}
+	l.Set(keys...)
+	return l
+}
+
+// Set replaces the keys on the list, keeping its prefixes
+func (l *KeyList[K]) Set(keys ...K) {
+	m := make(map[K]struct{}, len(keys))
+	for _, key := range keys {
+		m[key] = struct{}{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.keys = m
+}
+
+// Add puts key on the list
+func (l *KeyList[K]) Add(key K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.keys == nil {
+		l.keys = make(map[K]struct{})
+	}
+	l.keys[key] = struct{}{}
+}
+
+// Remove takes key off the list
+func (l *KeyList[K]) Remove(key K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.keys, key)
+}
+
+// SetPrefixes replaces the CIDR prefixes on the list, e.g.
+// netip.MustParsePrefix("10.0.0.0/8")
+func (l *KeyList[K]) SetPrefixes(prefixes ...netip.Prefix) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prefixes = append([]netip.Prefix(nil), prefixes...)
+}
+
+// Contains reports whether key is on the list, either by itself or as an
+// IP address within one of its prefixes
+func (l *KeyList[K]) Contains(key K) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if _, ok := l.keys[key]; ok {
+		return true
+	}
+	if len(l.prefixes) == 0 {
+		return false
+	}
+
+	var addr netip.Addr
+	switch v := any(key).(type) {
+	case netip.Addr:
+		addr = v
+	case string:
+		a, err := netip.ParseAddr(v)
+		if err != nil {
+			return false
+		}
+		addr = a
+	default:
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range l.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAllowlist makes the per-key shortcuts admit keys on l without
+// consulting or creating their limiters, e.g. for internal services.
+// l may be updated later; nil removes the allowlist
+func (k *Keyed[K]) SetAllowlist(l *KeyList[K]) {
+	k.allowlist.Store(l)
+}
+
+// SetDenylist makes the per-key shortcuts refuse keys on l at once;
+// WaitKey returns ErrDenylisted. The denylist wins over the allowlist.
+// l may be updated later; nil removes the denylist
+func (k *Keyed[K]) SetDenylist(l *KeyList[K]) {
+	k.denylist.Store(l)
+}
+
+// listed reports whether key is on the denylist or allowlist, and if so
+// whether it is admitted
+func (k *Keyed[K]) listed(key K) (ok, listed bool) {
+	if l := k.denylist.Load(); l != nil && l.Contains(key) {
+		return false, true
+	}
+	if l := k.allowlist.Load(); l != nil && l.Contains(key) {
+		return true, true
+	}
+	return false, false
+}
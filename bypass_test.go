@@ -0,0 +1,80 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestKeyList(t *testing.T) {
+	l := NewKeyList("alice")
+	l.SetPrefixes(netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32"))
+
+	for key, want := range map[string]bool{
+		"alice":            true,
+		"bob":              false,
+		"10.1.2.3":         true,
+		"::ffff:10.1.2.3":  true,
+		"11.1.2.3":         false,
+		"2001:db8::1":      true,
+		"not an address/8": false,
+	} {
+		if got := l.Contains(key); got != want {
+			t.Errorf("Contains(%q) = %v, want %v", key, got, want)
+		}
+	}
+
+	l.Add("bob")
+	l.Remove("alice")
+	if !l.Contains("bob") || l.Contains("alice") {
+		t.Error("expected Add and Remove to update the list")
+	}
+
+	addrs := NewKeyList[netip.Addr]()
+	addrs.SetPrefixes(netip.MustParsePrefix("192.168.0.0/16"))
+	if !addrs.Contains(netip.MustParseAddr("192.168.1.1")) {
+		t.Error("expected netip.Addr keys to match prefixes")
+	}
+}
+
+func TestKeyedBypass(t *testing.T) {
+	k := NewKeyedLimiter[string](TokenBucket, Limit(1), 1)
+	allow := NewKeyList("internal")
+	deny := NewKeyList[string]()
+	k.SetAllowlist(allow)
+	k.SetDenylist(deny)
+
+	for i := 0; i < 5; i++ {
+		if !k.AllowKey("internal") {
+			t.Fatal("expected the allowlisted key to skip limiting")
+		}
+	}
+	if k.Len() != 0 {
+		t.Errorf("expected no limiter for the allowlisted key, got %d keys", k.Len())
+	}
+	if r := k.ReserveKey("internal"); !r.OK() || r.Delay() != 0 {
+		t.Error("expected an immediate reservation for the allowlisted key")
+	}
+
+	deny.Add("mallory")
+	deny.Add("internal")
+	if k.AllowKey("mallory") || k.AllowKey("internal") {
+		t.Error("expected denylisted keys to be refused")
+	}
+	if err := k.WaitKey(context.Background(), "mallory"); !errors.Is(err, ErrDenylisted) {
+		t.Errorf("expected ErrDenylisted, got %v", err)
+	}
+	if k.ReserveKey("mallory").OK() {
+		t.Error("expected no reservation for a denylisted key")
+	}
+
+	if ok, res := k.AllowDetailsKey("mallory", 1); ok || res.RetryAfter != InfDuration {
+		t.Errorf("AllowDetailsKey = %v, %+v, want a refusal for good", ok, res)
+	}
+
+	k.SetDenylist(nil)
+	if !k.AllowKey("mallory") {
+		t.Error("expected limiting to resume once the denylist is removed")
+	}
+}
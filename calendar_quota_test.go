@@ -0,0 +1,61 @@
+package rateflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalendarQuotaDaily(t *testing.T) {
+	lim := NewCalendarQuota(3, Daily, time.UTC)
+	now := time.Now()
+
+	if !lim.AllowN(now, 3) {
+		t.Fatal("expected the quota to be available")
+	}
+	if lim.AllowN(now, 1) {
+		t.Error("expected the exhausted quota to deny")
+	}
+	if r := lim.RemainingAt(now); r != 0 {
+		t.Errorf("expected 0 remaining, got %v", r)
+	}
+
+	midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	if reset := lim.ResetAt(); !reset.Equal(midnight) {
+		t.Errorf("expected reset at %v, got %v", midnight, reset)
+	}
+	if !lim.AllowN(midnight, 3) {
+		t.Error("expected the quota to reset at midnight")
+	}
+}
+
+func TestCalendarQuotaMonthlyInLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+10", 10*60*60)
+	lim := NewCalendarQuota(100, Monthly, loc)
+
+	// 23:00 UTC on Jan 31 is already February 1st in UTC+10
+	at := time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC)
+	lim.AllowN(at, 100)
+	if lim.AllowN(at.Add(59*time.Minute), 1) {
+		t.Error("expected the quota to stay exhausted within the month")
+	}
+	if lim.Period() != Monthly || lim.Period().String() != "Monthly" {
+		t.Errorf("unexpected period %v", lim.Period())
+	}
+
+	march := time.Date(2024, 3, 1, 0, 0, 0, 0, loc)
+	if lim.AllowN(march.Add(-time.Second), 1) {
+		t.Error("expected the quota to last until the end of February")
+	}
+	if !lim.AllowN(march, 100) {
+		t.Error("expected the quota to reset on March 1st local time")
+	}
+}
+
+func TestCalendarQuotaWeekly(t *testing.T) {
+	lim := NewCalendarQuota(1, Weekly, time.UTC)
+	sunday := time.Date(2024, 6, 9, 12, 0, 0, 0, time.UTC)
+	lim.AllowN(sunday, 1)
+	if !lim.AllowN(time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC), 1) {
+		t.Error("expected weekly quota to reset on Monday")
+	}
+}
@@ -0,0 +1,100 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReservationCancelRestores(t *testing.T) {
+	for _, algo := range []Algorithm{TokenBucket, LeakyBucket, SlidingWindow, FixedWindow, MultiWindow, EWMA, CalendarQuota} {
+		lim := NewLimiter(algo, Limit(1), 3)
+		now := time.Now()
+
+		r := lim.ReserveN(now, 3)
+		if !r.OK() {
+			t.Fatalf("%s: expected reservation to succeed", algo)
+		}
+		if lim.AllowN(now, 1) {
+			t.Fatalf("%s: expected the reservation to use up capacity", algo)
+		}
+
+		r.CancelAt(now)
+		if !lim.AllowN(now, 3) {
+			t.Errorf("%s: expected Cancel to restore capacity", algo)
+		}
+	}
+}
+
+func TestReservationCancelFuture(t *testing.T) {
+	lim := NewLimiter(TokenBucket, Limit(10), 1)
+	now := time.Now()
+	lim.AllowN(now, 1)
+
+	r := lim.ReserveN(now, 1)
+	if d := r.DelayFrom(now); d < 99*time.Millisecond {
+		t.Fatalf("expected a future reservation, delay %v", d)
+	}
+	r.CancelAt(now)
+	// Cancelling twice must not refund twice
+	r.CancelAt(now)
+
+	if lim.AllowN(now, 1) {
+		t.Error("expected bucket to stay empty after cancel")
+	}
+	if !lim.AllowN(now.Add(100*time.Millisecond), 1) {
+		t.Error("expected the cancelled token to be available after refill")
+	}
+	if lim.AllowN(now.Add(100*time.Millisecond), 1) {
+		t.Error("expected a double cancel to refund only once")
+	}
+}
+
+func TestReservationCancelAfterAct(t *testing.T) {
+	lim := NewLimiter(TokenBucket, Limit(1), 2)
+	now := time.Now()
+
+	r := lim.ReserveN(now, 2)
+	r.CancelAt(now.Add(time.Millisecond))
+	if lim.AllowN(now.Add(time.Millisecond), 1) {
+		t.Error("expected a reservation that already acted not to be refunded")
+	}
+}
+
+func TestReservationAct(t *testing.T) {
+	lim := NewLimiter(TokenBucket, Limit(100), 1)
+	lim.Allow()
+
+	start := time.Now()
+	if err := lim.Reserve().Act(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 5*time.Millisecond {
+		t.Errorf("expected Act to wait for the reservation, took %v", elapsed)
+	}
+
+	if err := lim.ReserveN(time.Now(), 2).Act(context.Background()); !errors.Is(err, ErrReservationNotOK) {
+		t.Errorf("expected ErrReservationNotOK, got %v", err)
+	}
+}
+
+func TestReservationActCancel(t *testing.T) {
+	lim := NewLimiter(TokenBucket, Limit(10), 1)
+	lim.Allow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := lim.Reserve()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if err := r.Act(ctx); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// The aborted reservation gave its token back
+	if r := lim.Reserve(); r.Delay() > 100*time.Millisecond {
+		t.Errorf("expected the cancelled token to be refunded, delay %v", r.Delay())
+	}
+}
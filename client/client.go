@@ -0,0 +1,267 @@
+// Package client talks to a rateflowd server, over gRPC with NewGRPC or
+// over its JSON HTTP API with New. Client implements
+// rateflow.RemoteLimiter on the server's check API, so it works anywhere a
+// store-backed limiter does, e.g. with rateflow.WaitRemote or as the
+// remote of a Hybrid.
+//
+// When the server cannot be reached, or answers with an error, decisions
+// fall back to a local keyed limiter if one is set with WithFallback, and
+// otherwise admit everything or nothing depending on WithFailOpen, the
+// policies of rateflow.FailSafe. With a fallback each replica enforces
+// the limit alone until the server is back, which lets through up to one
+// full limit per replica but keeps serving. FailureStats counts the
+// failures. WithBreaker stops calling a failing server for a while
+// instead of paying its timeout on every decision
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"github.com/mehmet-f-dogan/rateflow/server"
+	"github.com/mehmet-f-dogan/rateflow/server/rateflowpb"
+	"google.golang.org/grpc"
+)
+
+// Client checks keys against a rateflowd server
+type Client struct {
+	base     string
+	http     *http.Client
+	rpc      rateflowpb.RateFlowClient // set by NewGRPC
+	timeout  time.Duration
+	fallback *rateflow.Keyed[string]
+	failOpen bool
+	breaker  *rateflow.BreakerConfig
+
+	safe    *rateflow.FailSafe
+	tripper *rateflow.Breaker
+}
+
+var _ rateflow.RemoteLimiter = (*Client)(nil)
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client, http.DefaultClient by default
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *Client) {
+		cl.http = c
+	}
+}
+
+// WithTimeout bounds each call to the server, 1s by default, so a server
+// that hangs falls back as fast as one that is down. 0 leaves calls bound
+// only by their context
+func WithTimeout(d time.Duration) Option {
+	return func(cl *Client) {
+		cl.timeout = d
+	}
+}
+
+// WithFallback decides keys on k while the server fails, e.g. a
+// NewKeyedProvider with the server's rules scaled down to this replica's
+// share
+func WithFallback(k *rateflow.Keyed[string]) Option {
+	return func(cl *Client) {
+		cl.fallback = k
+	}
+}
+
+// WithFailOpen admits every event while the server fails and there is no
+// fallback. By default they are refused
+func WithFailOpen(open bool) Option {
+	return func(cl *Client) {
+		cl.failOpen = open
+	}
+}
+
+// WithBreaker puts a circuit breaker configured by cfg in front of the
+// server; while it is open decisions go straight to the failure policy
+func WithBreaker(cfg rateflow.BreakerConfig) Option {
+	return func(cl *Client) {
+		cl.breaker = &cfg
+	}
+}
+
+// New creates a client for the HTTP API of the server at base, e.g.
+// "http://rateflowd:8080"
+func New(base string, opts ...Option) *Client {
+	return newClient(&Client{base: strings.TrimSuffix(base, "/"), http: http.DefaultClient}, opts)
+}
+
+// NewGRPC creates a client calling the RateFlow gRPC service of the
+// server conn is connected to, e.g.
+//
+//	conn, err := grpc.NewClient("rateflowd:9090", grpc.WithTransportCredentials(insecure.NewCredentials()))
+//	c := client.NewGRPC(conn, client.WithFallback(local))
+//
+// WithHTTPClient does not apply
+func NewGRPC(conn grpc.ClientConnInterface, opts ...Option) *Client {
+	return newClient(&Client{rpc: rateflowpb.NewRateFlowClient(conn)}, opts)
+}
+
+func newClient(c *Client, opts []Option) *Client {
+	c.timeout = time.Second
+	for _, opt := range opts {
+		opt(c)
+	}
+	policy := rateflow.FailClosed
+	switch {
+	case c.fallback != nil:
+		policy = rateflow.FailLocal
+	case c.failOpen:
+		policy = rateflow.FailOpen
+	}
+	var remote rateflow.RemoteLimiter = checker{c}
+	if c.breaker != nil {
+		c.tripper = rateflow.NewBreaker(remote, *c.breaker)
+		remote = c.tripper
+	}
+	c.safe = rateflow.NewFailSafe(remote, policy, c.fallback)
+	return c
+}
+
+// FailureStats returns the number of calls and server failures so far
+func (c *Client) FailureStats() rateflow.FailureStats {
+	return c.safe.Stats()
+}
+
+// BreakerStats returns the state and counts of the breaker set with
+// WithBreaker, and false if there is none
+func (c *Client) BreakerStats() (rateflow.BreakerStats, bool) {
+	if c.tripper == nil {
+		return rateflow.BreakerStats{}, false
+	}
+	return c.tripper.Stats(), true
+}
+
+// Check asks the server whether n events of key may happen now, charging
+// them if so. Unlike the other methods it does not fall back: errors are
+// returned as they are
+func (c *Client) Check(ctx context.Context, key string, n int) (server.Decision, error) {
+	if c.rpc != nil {
+		return c.callGRPC(ctx, c.rpc.Check, key, n)
+	}
+	return c.call(ctx, "/v1/check", key, n)
+}
+
+// Report charges n events of key that already happened. It does not fall
+// back either, since a local limiter could not share them
+func (c *Client) Report(ctx context.Context, key string, n int) (server.Decision, error) {
+	if c.rpc != nil {
+		return c.callGRPC(ctx, c.rpc.Report, key, n)
+	}
+	return c.call(ctx, "/v1/report", key, n)
+}
+
+// callGRPC calls Check or Report of the gRPC service
+func (c *Client) callGRPC(ctx context.Context, method func(context.Context, *rateflowpb.CheckRequest, ...grpc.CallOption) (*rateflowpb.Decision, error), key string, n int) (server.Decision, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	d, err := method(ctx, &rateflowpb.CheckRequest{Key: key, Hits: int64(n)})
+	if err != nil {
+		return server.Decision{}, fmt.Errorf("client: %w", err)
+	}
+	return server.DecisionFromProto(d), nil
+}
+
+func (c *Client) call(ctx context.Context, path, key string, n int) (server.Decision, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	body, err := json.Marshal(server.CheckRequest{Key: key, Hits: n})
+	if err != nil {
+		return server.Decision{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.base+path, bytes.NewReader(body))
+	if err != nil {
+		return server.Decision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return server.Decision{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusTooManyRequests {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return server.Decision{}, fmt.Errorf("client: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	var d server.Decision
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return server.Decision{}, fmt.Errorf("client: decoding decision: %w", err)
+	}
+	return d, nil
+}
+
+// Allow is shorthand for AllowN(ctx, key, time.Now(), 1)
+func (c *Client) Allow(ctx context.Context, key string) bool {
+	ok, _ := c.AllowN(ctx, key, time.Now(), 1)
+	return ok
+}
+
+// AllowN reports whether n events of key may happen, consuming them if
+// so. The server decides at its own clock; t is used only by the
+// fallback. Server failures are handled as the options say and not
+// returned
+func (c *Client) AllowN(ctx context.Context, key string, t time.Time, n int) (bool, error) {
+	return c.safe.AllowN(ctx, key, t, n)
+}
+
+// ReserveN is like AllowN but reports when a refused request could be
+// retried. The server does not book events ahead, so a reservation is
+// OK only if the events may happen now. Reservations made on the
+// fallback are booked ahead like any local one and can be cancelled
+func (c *Client) ReserveN(ctx context.Context, key string, t time.Time, n int) (*rateflow.RemoteReservation, error) {
+	return c.safe.ReserveN(ctx, key, t, n)
+}
+
+// checker is the server's check API as a RemoteLimiter returning its
+// errors, for the FailSafe of a Client to handle
+type checker struct {
+	c *Client
+}
+
+func (ch checker) AllowN(ctx context.Context, key string, t time.Time, n int) (bool, error) {
+	d, err := ch.c.Check(ctx, key, n)
+	return d.Allowed, err
+}
+
+func (ch checker) ReserveN(ctx context.Context, key string, t time.Time, n int) (*rateflow.RemoteReservation, error) {
+	d, err := ch.c.Check(ctx, key, n)
+	if err != nil {
+		return nil, err
+	}
+	if d.Allowed {
+		return rateflow.NewRemoteReservation(true, t, n, nil), nil
+	}
+	var retry time.Time
+	if wait := time.Duration(d.RetryAfter); wait != rateflow.InfDuration {
+		retry = t.Add(wait)
+	}
+	return rateflow.NewRemoteReservation(false, retry, n, nil), nil
+}
+
+// Wait blocks until an event of key is allowed or ctx is done
+func (c *Client) Wait(ctx context.Context, key string) error {
+	return c.WaitN(ctx, key, 1)
+}
+
+// WaitN blocks until n events of key are allowed or ctx is done, retrying
+// when the server says they could be
+func (c *Client) WaitN(ctx context.Context, key string, n int) error {
+	return rateflow.WaitRemote(ctx, c, key, n)
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"github.com/mehmet-f-dogan/rateflow/server"
+)
+
+func newServer(t *testing.T, data string) *httptest.Server {
+	t.Helper()
+	cfg, err := server.ParseConfig([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := server.New(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient(t *testing.T) {
+	srv := newServer(t, `{"rules": {"api/*": {"limit": 20, "burst": 2}}}`)
+	c := New(srv.URL)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if !c.Allow(ctx, "api/users") {
+			t.Fatalf("Allow #%d refused", i)
+		}
+	}
+	if c.Allow(ctx, "api/users") {
+		t.Error("expected the server's limit to apply")
+	}
+	r, err := c.ReserveN(ctx, "api/users", time.Now(), 1)
+	if err != nil || r.OK() || r.RetryAt().IsZero() {
+		t.Errorf("ReserveN = %+v, %v, want refused with a retry time", r, err)
+	}
+	if err := c.Wait(ctx, "api/users"); err != nil {
+		t.Errorf("Wait = %v", err)
+	}
+
+	d, err := c.Report(ctx, "api/orders", 2)
+	if err != nil || !d.Allowed || d.Limit != 2 {
+		t.Errorf("Report = %+v, %v", d, err)
+	}
+	if d, _ := c.Check(ctx, "api/orders", 1); d.Allowed {
+		t.Error("reported events should count against checks")
+	}
+}
+
+func TestClientFallback(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "store down", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	ctx := context.Background()
+
+	if _, err := New(down.URL).Check(ctx, "k", 1); err == nil {
+		t.Error("Check should return server errors")
+	}
+	if New(down.URL).Allow(ctx, "k") {
+		t.Error("expected the client to fail closed by default")
+	}
+	if !New(down.URL, WithFailOpen(true)).Allow(ctx, "k") {
+		t.Error("expected WithFailOpen to admit")
+	}
+
+	local := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, rateflow.Every(time.Hour), 1)
+	c := New(down.URL, WithFallback(local), WithFailOpen(true))
+	if !c.Allow(ctx, "k") || c.Allow(ctx, "k") {
+		t.Error("expected the fallback to decide, once per hour")
+	}
+	if !c.Allow(ctx, "other") {
+		t.Error("the fallback should limit keys separately")
+	}
+	if st := c.FailureStats(); st.Errors != 3 || st.Admitted != 2 || st.LastError == nil {
+		t.Errorf("FailureStats = %+v, want 3 errors, 2 admitted", st)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	var rle *rateflow.RateLimitError
+	if err := c.Wait(short, "k"); !errors.As(err, &rle) {
+		t.Errorf("Wait = %v, want a RateLimitError from the fallback", err)
+	}
+}
+
+func TestClientBreaker(t *testing.T) {
+	var hits atomic.Int64
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "store down", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	c := New(down.URL, WithFailOpen(true), WithBreaker(rateflow.BreakerConfig{Failures: 2, Cooldown: time.Hour}))
+	for i := 0; i < 5; i++ {
+		if !c.Allow(context.Background(), "k") {
+			t.Fatal("expected the client to fail open")
+		}
+	}
+	if hits.Load() != 2 {
+		t.Errorf("server got %d calls, want the breaker to stop them after 2", hits.Load())
+	}
+	if st, ok := c.BreakerStats(); !ok || st.State != rateflow.BreakerOpen || st.Rejected != 3 {
+		t.Errorf("BreakerStats = %+v, %v, want open with 3 rejected", st, ok)
+	}
+	if _, ok := New(down.URL).BreakerStats(); ok {
+		t.Error("BreakerStats without WithBreaker should report none")
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	release := make(chan struct{})
+	hang := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hang.Close()
+	defer close(release)
+
+	c := New(hang.URL, WithTimeout(20*time.Millisecond), WithFailOpen(true))
+	start := time.Now()
+	if !c.Allow(context.Background(), "k") {
+		t.Error("expected to fail open")
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("waited %v for a hanging server", waited)
+	}
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"github.com/mehmet-f-dogan/rateflow/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialGRPC serves a server configured by data over gRPC and returns a
+// connection to it, and a func stopping the server
+func dialGRPC(t *testing.T, data string) (*grpc.ClientConn, func()) {
+	t.Helper()
+	cfg, err := server.ParseConfig([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := server.New(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer()
+	s.Register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, srv.Stop
+}
+
+func TestGRPCClient(t *testing.T) {
+	conn, _ := dialGRPC(t, `{"rules": {"api/*": {"limit": 20, "burst": 2}}}`)
+	c := NewGRPC(conn)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if !c.Allow(ctx, "api/users") {
+			t.Fatalf("Allow #%d refused", i)
+		}
+	}
+	if c.Allow(ctx, "api/users") {
+		t.Error("expected the server's limit to apply")
+	}
+	r, err := c.ReserveN(ctx, "api/users", time.Now(), 1)
+	if err != nil || r.OK() || r.RetryAt().IsZero() {
+		t.Errorf("ReserveN = %+v, %v, want refused with a retry time", r, err)
+	}
+	if err := c.Wait(ctx, "api/users"); err != nil {
+		t.Errorf("Wait = %v", err)
+	}
+
+	d, err := c.Report(ctx, "api/orders", 2)
+	if err != nil || !d.Allowed || d.Limit != 2 {
+		t.Errorf("Report = %+v, %v", d, err)
+	}
+	if d, _ := c.Check(ctx, "api/orders", 1); d.Allowed {
+		t.Error("reported events should count against checks")
+	}
+}
+
+func TestGRPCClientFallback(t *testing.T) {
+	conn, stop := dialGRPC(t, `{"rules": {"api/*": {"limit": 20, "burst": 2}}}`)
+	local := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, rateflow.Every(time.Hour), 1)
+	c := NewGRPC(conn, WithFallback(local), WithTimeout(100*time.Millisecond))
+	ctx := context.Background()
+	stop()
+
+	if !c.Allow(ctx, "api/users") {
+		t.Error("expected the fallback to admit the first event")
+	}
+	if c.Allow(ctx, "api/users") {
+		t.Error("expected the fallback's limit to apply")
+	}
+	if s := c.FailureStats(); s.Errors != 2 || s.LastError == nil {
+		t.Errorf("FailureStats = %+v, want 2 errors", s)
+	}
+}
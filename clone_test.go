@@ -0,0 +1,68 @@
+package rateflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClone(t *testing.T) {
+	for _, algo := range Algorithms()[:DualRate+1] {
+		lim := NewLimiterWithOptions(algo, Limit(1), 3, WithName("api"))
+		for lim.Allow() {
+		}
+
+		c := lim.Clone()
+		if c.Algorithm() != algo || c.Limit() != lim.Limit() || c.Burst() != lim.Burst() {
+			t.Errorf("%s: clone configured as %s %v/%d", algo, c.Algorithm(), c.Limit(), c.Burst())
+		}
+		if NameOf(c) != "api" {
+			t.Errorf("%s: expected clone to keep the name, got %q", algo, NameOf(c))
+		}
+		if s := c.Stats(); s.Allowed != 0 || s.Denied != 0 {
+			t.Errorf("%s: expected empty stats on clone, got %+v", algo, s)
+		}
+		if !c.AllowN(time.Now(), 3) {
+			t.Errorf("%s: expected clone to start with full capacity", algo)
+		}
+		if lim.Allow() {
+			t.Errorf("%s: expected clone to leave the original drained", algo)
+		}
+	}
+}
+
+func TestCloneIndependentConfig(t *testing.T) {
+	lim := NewMultiWindowLimiter(WindowRule{Count: 2, Window: time.Second})
+	c := lim.Clone()
+	c.SetBurst(10)
+	if lim.Burst() != 2 {
+		t.Errorf("expected SetBurst on the clone to leave the original alone, got %d", lim.Burst())
+	}
+
+	pb := NewPriorityLimiter(Limit(1), 10, 4).Clone().(*PriorityLimiter)
+	if pb.Reserved(0) != 4 {
+		t.Errorf("expected clone to keep reserved tokens, got %d", pb.Reserved(0))
+	}
+
+	sh := NewSheddingLimiter(NewLimiter(TokenBucket, Limit(1), 10), 0.5).Clone().(*SheddingLimiter)
+	if sh.Threshold() != 0.5 || sh.Burst() != 10 {
+		t.Errorf("expected shedding clone to keep threshold and burst, got %v %d", sh.Threshold(), sh.Burst())
+	}
+}
+
+func TestCloneWithState(t *testing.T) {
+	for _, algo := range Algorithms()[:DualRate+1] {
+		lim := NewLimiter(algo, Limit(1), 3)
+		lim.AllowN(time.Now(), 2)
+
+		c, err := CloneWithState(lim)
+		if err != nil {
+			t.Fatalf("%s: %v", algo, err)
+		}
+		if got, want := c.Remaining(), lim.Remaining(); got > want+0.01 || got < want-0.01 {
+			t.Errorf("%s: expected clone to keep %v remaining, got %v", algo, want, got)
+		}
+		if s := c.Stats(); s.Allowed != 0 {
+			t.Errorf("%s: expected empty stats on clone, got %+v", algo, s)
+		}
+	}
+}
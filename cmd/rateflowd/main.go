@@ -0,0 +1,146 @@
+// Command rateflowd runs the rate limiting service of package server.
+//
+//	rateflowd -config /etc/rateflowd.json
+//
+// It serves the JSON check and report API over HTTP and, with grpc_listen
+// in the config or -grpc, the RateFlow gRPC service. Sending SIGHUP
+// reloads the rules from the config file; keys keep their usage where the
+// algorithm is unchanged. SIGINT and SIGTERM stop the server gracefully
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow/redisstore"
+	"github.com/mehmet-f-dogan/rateflow/server"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	configPath := flag.String("config", "rateflowd.json", "path of the JSON config file")
+	listen := flag.String("listen", "", "HTTP address, overriding the config file")
+	grpcListen := flag.String("grpc", "", "gRPC address, overriding the config file")
+	flag.Parse()
+
+	if err := run(*configPath, *listen, *grpcListen); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(configPath, listen, grpcListen string) error {
+	cfg, err := server.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if listen != "" {
+		cfg.Listen = listen
+	}
+	if cfg.Listen == "" {
+		cfg.Listen = ":8080"
+	}
+	if grpcListen != "" {
+		cfg.GRPCListen = grpcListen
+	}
+
+	var store redisstore.Client
+	if cfg.Redis != nil {
+		rc := newRedisClient(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+		defer rc.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := rc.Do(ctx, "PING")
+		cancel()
+		if err != nil {
+			return err
+		}
+		store = rc
+	}
+
+	srv, err := server.New(cfg, store)
+	if err != nil {
+		return err
+	}
+	defer srv.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go reloadOnHangup(ctx, srv, configPath)
+
+	hs := &http.Server{Addr: cfg.Listen, Handler: srv.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	errc := make(chan error, 2)
+	go func() { errc <- hs.ListenAndServe() }()
+	log.Printf("rateflowd: listening on %s with %d rules", cfg.Listen, len(cfg.Rules))
+
+	var gs *grpc.Server
+	if cfg.GRPCListen != "" {
+		lis, err := net.Listen("tcp", cfg.GRPCListen)
+		if err != nil {
+			hs.Close()
+			return err
+		}
+		gs = grpc.NewServer()
+		srv.Register(gs)
+		go func() { errc <- gs.Serve(lis) }()
+		log.Printf("rateflowd: serving gRPC on %s", cfg.GRPCListen)
+	}
+
+	select {
+	case err := <-errc:
+		hs.Close()
+		if gs != nil {
+			gs.Stop()
+		}
+		return err
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if gs != nil {
+		stopped := make(chan struct{})
+		go func() {
+			gs.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdown.Done():
+			gs.Stop()
+		}
+	}
+	if err := hs.Shutdown(shutdown); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// reloadOnHangup reloads the config file into srv on every SIGHUP until
+// ctx is done. A bad file is logged and the running rules kept
+func reloadOnHangup(ctx context.Context, srv *server.Server, configPath string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-hup:
+			cfg, err := server.LoadConfig(configPath)
+			if err == nil {
+				err = srv.Reload(cfg)
+			}
+			if err != nil {
+				log.Printf("rateflowd: reload: %v", err)
+				continue
+			}
+			log.Printf("rateflowd: reloaded %d rules", len(cfg.Rules))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
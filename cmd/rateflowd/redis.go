@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/mehmet-f-dogan/rateflow/redisstore"
+)
+
+// redisClient is a minimal RESP2 client with a pool of idle connections,
+// enough for the scripts redisstore runs without pulling in a Redis
+// library
+type redisClient struct {
+	addr     string
+	password string
+	db       int
+	maxIdle  int
+
+	mu   sync.Mutex
+	idle []*redisConn
+}
+
+var _ redisstore.Pipeliner = (*redisClient)(nil)
+
+// redisError is an error reply, which leaves the connection usable
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func newRedisClient(addr, password string, db int) *redisClient {
+	return &redisClient{addr: addr, password: password, db: db, maxIdle: 16}
+}
+
+// conn returns an idle connection or dials a new one, authenticated and
+// on the configured database
+func (c *redisClient) conn(ctx context.Context) (*redisConn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		rc := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return rc, nil
+	}
+	c.mu.Unlock()
+
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	rc := &redisConn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	var setup [][]any
+	if c.password != "" {
+		setup = append(setup, []any{"AUTH", c.password})
+	}
+	if c.db != 0 {
+		setup = append(setup, []any{"SELECT", c.db})
+	}
+	for _, r := range rc.do(ctx, setup) {
+		if r.Err != nil {
+			nc.Close()
+			return nil, r.Err
+		}
+	}
+	return rc, nil
+}
+
+// put returns rc to the pool, or closes it after a failure that may have
+// left a reply unread
+func (c *redisClient) put(rc *redisConn, replies []redisstore.Reply) {
+	for _, r := range replies {
+		var re redisError
+		if r.Err != nil && !errors.As(r.Err, &re) {
+			rc.Close()
+			return
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.idle) >= c.maxIdle {
+		rc.Close()
+		return
+	}
+	c.idle = append(c.idle, rc)
+}
+
+// Do runs one command
+func (c *redisClient) Do(ctx context.Context, args ...any) (any, error) {
+	r := c.DoMulti(ctx, [][]any{args})[0]
+	return r.Value, r.Err
+}
+
+// DoMulti pipelines cmds on one connection
+func (c *redisClient) DoMulti(ctx context.Context, cmds [][]any) []redisstore.Reply {
+	rc, err := c.conn(ctx)
+	if err != nil {
+		replies := make([]redisstore.Reply, len(cmds))
+		for i := range replies {
+			replies[i].Err = err
+		}
+		return replies
+	}
+	replies := rc.do(ctx, cmds)
+	c.put(rc, replies)
+	return replies
+}
+
+// Close closes the idle connections
+func (c *redisClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rc := range c.idle {
+		rc.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+// do writes cmds and reads their replies, within ctx's deadline
+func (rc *redisConn) do(ctx context.Context, cmds [][]any) []redisstore.Reply {
+	replies := make([]redisstore.Reply, len(cmds))
+	fail := func(from int, err error) []redisstore.Reply {
+		for i := from; i < len(replies); i++ {
+			replies[i].Err = err
+		}
+		return replies
+	}
+	if len(cmds) == 0 {
+		return replies
+	}
+
+	deadline, _ := ctx.Deadline()
+	rc.SetDeadline(deadline)
+	for _, cmd := range cmds {
+		writeCommand(rc.w, cmd)
+	}
+	if err := rc.w.Flush(); err != nil {
+		return fail(0, err)
+	}
+	for i := range replies {
+		v, err := readReply(rc.r)
+		var re redisError
+		if err != nil && !errors.As(err, &re) {
+			return fail(i, err)
+		}
+		replies[i] = redisstore.Reply{Value: v, Err: err}
+	}
+	return replies
+}
+
+// writeCommand encodes cmd as an array of bulk strings
+func writeCommand(w *bufio.Writer, cmd []any) {
+	fmt.Fprintf(w, "*%d\r\n", len(cmd))
+	for _, arg := range cmd {
+		var s string
+		switch arg := arg.(type) {
+		case string:
+			s = arg
+		case []byte:
+			s = string(arg)
+		case int:
+			s = strconv.Itoa(arg)
+		case int64:
+			s = strconv.FormatInt(arg, 10)
+		case float64:
+			s = strconv.FormatFloat(arg, 'g', -1, 64)
+		default:
+			s = fmt.Sprint(arg)
+		}
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+	}
+}
+
+// readReply decodes one reply: strings, int64, nil or []any, and error
+// replies as a redisError
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		values := make([]any, n)
+		for i := range values {
+			// An error nested in an array is a value, not a failure of
+			// the whole reply
+			v, err := readReply(r)
+			var re redisError
+			switch {
+			case errors.As(err, &re):
+				values[i] = re
+			case err != nil:
+				return nil, err
+			default:
+				values[i] = v
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// fakeRedis answers commands over TCP with canned replies, recording the
+// commands it gets
+func fakeRedis(t *testing.T, cmds chan<- []any) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					v, err := readReply(r)
+					if err != nil {
+						return
+					}
+					cmd := v.([]any)
+					cmds <- cmd
+					switch cmd[0] {
+					case "AUTH", "PING":
+						c.Write([]byte("+OK\r\n"))
+					case "GET":
+						c.Write([]byte("$-1\r\n"))
+					case "EVALSHA":
+						c.Write([]byte("-NOSCRIPT No matching script\r\n"))
+					case "EVAL":
+						c.Write([]byte("*3\r\n:1\r\n$5\r\nhello\r\n-ERR inner\r\n"))
+					default:
+						c.Write([]byte("-ERR unknown command\r\n"))
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestRedisClient(t *testing.T) {
+	cmds := make(chan []any, 16)
+	c := newRedisClient(fakeRedis(t, cmds), "secret", 0)
+	defer c.Close()
+	ctx := context.Background()
+
+	if v, err := c.Do(ctx, "PING"); err != nil || v != "OK" {
+		t.Fatalf("PING = %v, %v", v, err)
+	}
+	if auth := <-cmds; auth[0] != "AUTH" || auth[1] != "secret" {
+		t.Errorf("first command = %v, want AUTH", auth)
+	}
+	<-cmds
+
+	if v, err := c.Do(ctx, "GET", "missing"); err != nil || v != nil {
+		t.Errorf("GET = %v, %v, want a nil reply", v, err)
+	}
+	var re redisError
+	if _, err := c.Do(ctx, "FLUSHALL"); !errors.As(err, &re) {
+		t.Errorf("err = %v, want an error reply", err)
+	}
+
+	replies := c.DoMulti(ctx, [][]any{{"EVALSHA", "abc", 1, "k"}, {"EVAL", "return 1", 1, "k", 2.5}})
+	if replies[0].Err == nil || replies[0].Err.Error() != "NOSCRIPT No matching script" {
+		t.Errorf("EVALSHA = %+v, want NOSCRIPT", replies[0])
+	}
+	values, ok := replies[1].Value.([]any)
+	if !ok || len(values) != 3 || values[0] != int64(1) || values[1] != "hello" {
+		t.Fatalf("EVAL = %+v", replies[1])
+	}
+	if _, ok := values[2].(redisError); !ok {
+		t.Errorf("nested error = %T, want a redisError value", values[2])
+	}
+	if len(c.idle) != 1 {
+		t.Errorf("idle = %d, want error replies to keep the connection", len(c.idle))
+	}
+}
@@ -0,0 +1,178 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAllowAll(t *testing.T) {
+	user := NewLimiter(TokenBucket, Limit(1), 2)
+	service := NewLimiter(TokenBucket, Limit(1), 1)
+	now := time.Now()
+
+	if !AllowAll(now, 1, user, service) {
+		t.Fatal("expected both limiters to admit the first event")
+	}
+	if AllowAll(now, 1, user, service) {
+		t.Error("expected the exhausted service limiter to refuse")
+	}
+	if tokens := user.TokensAt(now); tokens != 1 {
+		t.Errorf("expected the refused event to be refunded to user, got %v tokens", tokens)
+	}
+}
+
+func TestReserveAllCancel(t *testing.T) {
+	a := NewLimiter(TokenBucket, Limit(1), 1)
+	b := NewLimiter(LeakyBucket, Limit(1), 1)
+	now := time.Now()
+
+	r := ReserveAll(now, 1, a, b)
+	if !r.OK() || r.DelayFrom(now) != 0 {
+		t.Fatalf("expected an immediate reservation, got ok=%v delay=%v", r.OK(), r.DelayFrom(now))
+	}
+	r.CancelAt(now)
+	if !a.AllowN(now, 1) || !b.AllowN(now, 1) {
+		t.Error("expected Cancel to refund both limiters")
+	}
+
+	if r := ReserveAll(now, 2, a, b); r.OK() {
+		t.Error("expected a request over the burst to fail")
+	}
+}
+
+func TestWaitAll(t *testing.T) {
+	a := NewLimiter(TokenBucket, Limit(1), 1)
+	b := NewLimiter(TokenBucket, Limit(1), 1)
+
+	if err := WaitAll(context.Background(), 1, a, b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var rle *RateLimitError
+	if err := WaitAll(ctx, 1, a, b); !errors.As(err, &rle) {
+		t.Errorf("expected a RateLimitError, got %v", err)
+	}
+	if err := WaitAll(context.Background(), 5, a, b); !errors.Is(err, ErrExceedsBurst) {
+		t.Errorf("expected ErrExceedsBurst, got %v", err)
+	}
+}
+
+func TestKeyedParent(t *testing.T) {
+	k := NewKeyedLimiter[string](TokenBucket, Limit(1), 2)
+	k.SetParent(NewLimiter(TokenBucket, Limit(1), 3))
+
+	if !k.AllowKey("alice") || !k.AllowKey("alice") {
+		t.Fatal("expected alice's burst to be available")
+	}
+	if k.AllowKey("alice") {
+		t.Error("expected alice's own limit to apply")
+	}
+	if !k.AllowKey("bob") {
+		t.Error("expected bob to use the last of the shared budget")
+	}
+	if k.AllowKey("carol") {
+		t.Error("expected the shared budget to be exhausted")
+	}
+	if k.Get("carol").Tokens() < 1 {
+		t.Error("expected carol's own limiter to be refunded")
+	}
+}
+
+func TestKeyedParentDetails(t *testing.T) {
+	k := NewKeyedLimiter[string](TokenBucket, Limit(1), 2)
+	k.SetParent(NewLimiter(TokenBucket, Every(time.Hour), 1))
+	now := time.Now()
+
+	if ok, res := k.AllowDetailsAtKey("alice", now, 1); !ok || res.Limit != 2 || res.Remaining != 1 {
+		t.Fatalf("AllowDetailsKey = %v, %+v, want alice's bucket with one token left", ok, res)
+	}
+	ok, res := k.AllowDetailsAtKey("bob", now, 1)
+	if ok || res.RetryAfter < 59*time.Minute {
+		t.Errorf("AllowDetailsKey = %v, %+v, want the parent's hour to wait", ok, res)
+	}
+	if res.Remaining != 2 {
+		t.Errorf("Remaining = %d, want bob's tokens refunded", res.Remaining)
+	}
+	if r := k.ReserveNKey("carol", now, 1); !r.OK() || r.DelayFrom(now) < 59*time.Minute {
+		t.Errorf("ReserveNKey delay = %v, want to wait for the parent", r.DelayFrom(now))
+	}
+}
+
+func TestKeyedParentFairWaits(t *testing.T) {
+	k := NewKeyedLimiter[string](TokenBucket, Limit(1000), 100)
+	parent := NewLimiter(TokenBucket, Limit(50), 1)
+	parent.Allow()
+	k.SetParent(parent)
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	wait := func(key string) {
+		defer wg.Done()
+		if err := k.WaitKey(context.Background(), key); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		mu.Lock()
+		order = append(order, key)
+		mu.Unlock()
+	}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go wait("noisy")
+	}
+	time.Sleep(5 * time.Millisecond)
+	wg.Add(1)
+	go wait("quiet")
+	wg.Wait()
+
+	for i, key := range order {
+		if key == "quiet" && i > 2 {
+			t.Errorf("expected quiet to take turns with noisy, admitted as %d of %d: %v", i+1, len(order), order)
+		}
+	}
+}
+
+func TestKeyedParentStats(t *testing.T) {
+	for _, algo := range Algorithms()[:DualRate+1] {
+		k := NewKeyedLimiter[string](algo, Every(time.Hour), 1)
+		k.SetParent(NewLimiter(TokenBucket, Every(time.Hour), 10))
+		k.AllowKey("alice")
+		k.AllowKey("alice")
+		k.AllowKey("alice")
+		if s := k.Get("alice").Stats(); s.Allowed != 1 || s.Denied != 2 {
+			t.Errorf("%s: expected 1 allowed and 2 denied, got %d and %d", algo, s.Allowed, s.Denied)
+		}
+	}
+
+	k := NewKeyedLimiter[string](TokenBucket, Every(time.Hour), 5)
+	parent := NewLimiter(TokenBucket, Every(time.Hour), 1)
+	k.SetParent(parent)
+	k.AllowKey("alice")
+	k.AllowKey("alice")
+	if s := parent.Stats(); s.Allowed != 1 || s.Denied != 1 {
+		t.Errorf("expected the parent to count its refusal, got %+v", s)
+	}
+	if s := k.Get("alice").Stats(); s.Allowed != 1 || s.Denied != 0 {
+		t.Errorf("expected the key not to count the parent's refusal, got %+v", s)
+	}
+}
+
+func TestKeyedParentWaitRefund(t *testing.T) {
+	k := NewKeyedLimiter[string](TokenBucket, Every(time.Hour), 2)
+	parent := NewLimiter(TokenBucket, Every(time.Hour), 1)
+	parent.Allow()
+	k.SetParent(parent)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := k.WaitKey(ctx, "alice"); err == nil {
+		t.Fatal("expected the parent wait to fail")
+	}
+	if tokens := k.Get("alice").Tokens(); tokens < 2 {
+		t.Errorf("expected alice's token back after the failed parent wait, got %v", tokens)
+	}
+}
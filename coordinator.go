@@ -0,0 +1,270 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// CoordinatorConfig configures a Coordinator
+type CoordinatorConfig struct {
+	// Instance is the index of this process among Instances, from 0
+	Instance  int
+	Instances int
+	// Limit and Burst are the global limit split between the instances
+	Limit Limit
+	Burst int
+	// Interval is how often demand is reported and the split recomputed,
+	// 10s if 0. The leader's lease lasts three intervals
+	Interval time.Duration
+	// MinShare is the fraction of an even split every instance keeps
+	// however little it asked for, so an idle instance can serve its
+	// first requests before the next split. 0.1 if 0
+	MinShare float64
+}
+
+// Coordinator enforces this instance's part of a global limit that a
+// leader redistributes by demand. Decisions are local; every Interval,
+// Sync reports how many events were asked for here to a Store, and the
+// instance holding the leader lease reads every report and writes each
+// instance its share: MinShare of an even split, plus the rest of the
+// limit in proportion to demand, so quota an idle replica does not use
+// goes to the busy ones. Any instance can lead; when the leader stops
+// renewing its lease another takes over at its next Sync. Instances that
+// see no share from a leader fall back to an even split
+type Coordinator struct {
+	store Store
+	key   string
+	cfg   CoordinatorConfig
+	local Limiter
+
+	demand atomic.Int64 // events asked for since the last sync
+
+	mu     sync.Mutex
+	share  float64
+	leader bool
+
+	syncMu   sync.Mutex
+	lastSync time.Time
+}
+
+// shareScale is the fixed point unit of shares in the store, parts per
+// million of the global limit
+const shareScale = 1e6
+
+// NewCoordinator creates the coordinator of cfg.Instance for key,
+// starting from an even split. It fails for an instance out of range or
+// a limit and burst a token bucket would reject with a ConfigError
+func NewCoordinator(store Store, key string, cfg CoordinatorConfig) (*Coordinator, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.MinShare <= 0 {
+		cfg.MinShare = 0.1
+	}
+	cfg.MinShare = math.Min(cfg.MinShare, 1)
+	if err := limiter.Validate(TokenBucket, cfg.Limit, cfg.Burst); err != nil {
+		return nil, err
+	}
+	if cfg.Instance < 0 || cfg.Instance >= cfg.Instances {
+		return nil, fmt.Errorf("rateflow: instance %d is not in [0, %d)", cfg.Instance, cfg.Instances)
+	}
+	share := 1 / float64(cfg.Instances)
+	return &Coordinator{
+		store: store,
+		key:   key,
+		cfg:   cfg,
+		local: NewLimiter(TokenBucket, cfg.Limit*Limit(share), shareBurst(cfg.Burst, share)),
+		share: share,
+	}, nil
+}
+
+// Limiter returns the local limiter enforcing this instance's share
+func (c *Coordinator) Limiter() Limiter {
+	return c.local
+}
+
+// Share returns this instance's current fraction of the global limit
+func (c *Coordinator) Share() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.share
+}
+
+// Leader reports whether this instance held the lease at its last Sync
+func (c *Coordinator) Leader() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.leader
+}
+
+// Allow is shorthand for AllowN(time.Now(), 1)
+func (c *Coordinator) Allow() bool {
+	return c.AllowN(time.Now(), 1)
+}
+
+// AllowN reports whether n events may happen at t within this
+// instance's share. Refused events count as demand too
+func (c *Coordinator) AllowN(t time.Time, n int) bool {
+	c.demand.Add(int64(n))
+	return c.local.AllowN(t, n)
+}
+
+// Wait is shorthand for WaitN(ctx, 1)
+func (c *Coordinator) Wait(ctx context.Context) error {
+	return c.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n events are allowed within this instance's share
+// or ctx is done
+func (c *Coordinator) WaitN(ctx context.Context, n int) error {
+	c.demand.Add(int64(n))
+	return c.local.WaitN(ctx, n)
+}
+
+func (c *Coordinator) storeKey(kind string, instance int) string {
+	return c.key + ":" + kind + ":" + strconv.Itoa(instance)
+}
+
+// put sets key to v whatever it holds
+func (c *Coordinator) put(ctx context.Context, key string, v int64) error {
+	return storeUpdate(ctx, c.store, key, func(int64, bool) (int64, time.Duration, bool) {
+		return v, 3 * c.cfg.Interval, true
+	})
+}
+
+// lease takes or renews the leader lease and reports whether this
+// instance holds it
+func (c *Coordinator) lease(ctx context.Context) (bool, error) {
+	id := int64(c.cfg.Instance) + 1
+	ttl := 3 * c.cfg.Interval
+	holder, found, err := c.store.Get(ctx, c.key+":leader")
+	switch {
+	case err != nil:
+		return false, err
+	case !found:
+		return c.store.Add(ctx, c.key+":leader", id, ttl)
+	case holder == id:
+		return c.store.CompareAndSwap(ctx, c.key+":leader", id, id, ttl)
+	}
+	return false, nil
+}
+
+// Sync is shorthand for SyncAt(ctx, time.Now())
+func (c *Coordinator) Sync(ctx context.Context) error {
+	return c.SyncAt(ctx, time.Now())
+}
+
+// SyncAt reports the demand since the last call, redistributes the limit
+// if this instance holds the lease, and applies the share the leader gave
+// this instance. If the store fails, the demand is kept for the next call
+// and the share left as it is
+func (c *Coordinator) SyncAt(ctx context.Context, t time.Time) error {
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+
+	elapsed := t.Sub(c.lastSync)
+	if c.lastSync.IsZero() || elapsed <= 0 {
+		elapsed = c.cfg.Interval
+	}
+	asked := c.demand.Swap(0)
+	// Demand is stored in thousandths of an event per second
+	rate := int64(float64(asked) / elapsed.Seconds() * 1000)
+	if err := c.put(ctx, c.storeKey("demand", c.cfg.Instance), rate); err != nil {
+		c.demand.Add(asked)
+		return err
+	}
+	c.lastSync = t
+
+	leader, err := c.lease(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.leader = leader
+	c.mu.Unlock()
+	if leader {
+		if err := c.distribute(ctx); err != nil {
+			return err
+		}
+	}
+
+	share := 1 / float64(c.cfg.Instances)
+	v, found, err := c.store.Get(ctx, c.storeKey("share", c.cfg.Instance))
+	if err != nil {
+		return err
+	}
+	if found {
+		share = float64(v) / shareScale
+	}
+	c.mu.Lock()
+	c.share = share
+	c.mu.Unlock()
+	c.local.SetLimitAt(t, c.cfg.Limit*Limit(share))
+	c.local.SetBurstAt(t, shareBurst(c.cfg.Burst, share))
+	return nil
+}
+
+// distribute reads every instance's demand and writes its share
+func (c *Coordinator) distribute(ctx context.Context) error {
+	demand := make([]float64, c.cfg.Instances)
+	var errs []error
+	for i := range demand {
+		v, _, err := c.store.Get(ctx, c.storeKey("demand", i))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("instance %d: %w", i, err))
+		}
+		demand[i] = float64(v)
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	for i, share := range c.split(demand) {
+		if err := c.put(ctx, c.storeKey("share", i), int64(math.Round(share*shareScale))); err != nil {
+			errs = append(errs, fmt.Errorf("instance %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// split gives each instance MinShare of an even split and the rest in
+// proportion to demand, or evenly if nobody asked for anything
+func (c *Coordinator) split(demand []float64) []float64 {
+	even := 1 / float64(len(demand))
+	floor := c.cfg.MinShare * even
+	var total float64
+	for _, d := range demand {
+		total += d
+	}
+	shares := make([]float64, len(demand))
+	for i, d := range demand {
+		if total == 0 {
+			shares[i] = even
+			continue
+		}
+		shares[i] = floor + (1-c.cfg.MinShare)*d/total
+	}
+	return shares
+}
+
+// Run calls Sync every Interval until ctx is done, then returns
+// ctx.Err()
+func (c *Coordinator) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.Sync(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package rateflow
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCoordinator(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	cfg := CoordinatorConfig{Instances: 2, Limit: 100, Burst: 100}
+	quiet, err := NewCoordinator(store, "api", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Instance = 1
+	busy, _ := NewCoordinator(store, "api", cfg)
+	if quiet.Share() != 0.5 || busy.Limiter().Limit() != 50 {
+		t.Fatalf("initial share = %v, want an even split", quiet.Share())
+	}
+
+	start := time.Now()
+	for round := 0; round < 2; round++ {
+		at := start.Add(time.Duration(round) * 10 * time.Second)
+		quiet.AllowN(at, 10)
+		for i := 0; i < 1000; i++ {
+			busy.AllowN(at.Add(time.Duration(i)*10*time.Millisecond), 1)
+		}
+		for _, c := range []*Coordinator{quiet, busy} {
+			if err := c.SyncAt(ctx, at.Add(10*time.Second)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !quiet.Leader() || busy.Leader() {
+		t.Errorf("Leader = %v, %v, want the first to sync to lead", quiet.Leader(), busy.Leader())
+	}
+
+	// Each keeps a tenth of an even split, the rest goes by demand
+	want := 0.05 + 0.9*1000/1010
+	if got := busy.Share(); math.Abs(got-want) > 1e-6 {
+		t.Errorf("busy share = %v, want %v", got, want)
+	}
+	if got := quiet.Share() + busy.Share(); math.Abs(got-1) > 1e-6 {
+		t.Errorf("shares add up to %v, want the whole limit", got)
+	}
+	if got := float64(busy.Limiter().Limit()); math.Abs(got-100*want) > 1e-3 {
+		t.Errorf("busy limit = %v, want %v", got, 100*want)
+	}
+}
+
+func TestCoordinatorFailover(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	cfg := CoordinatorConfig{Instances: 2, Limit: 10, Burst: 10, Interval: 10 * time.Millisecond}
+	first, _ := NewCoordinator(store, "api", cfg)
+	cfg.Instance = 1
+	second, _ := NewCoordinator(store, "api", cfg)
+
+	first.Sync(ctx)
+	second.Sync(ctx)
+	if !first.Leader() || second.Leader() {
+		t.Fatal("expected the first instance to lead")
+	}
+
+	// The leader stops; its lease runs out after three intervals
+	time.Sleep(40 * time.Millisecond)
+	if err := second.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !second.Leader() {
+		t.Error("expected the second instance to take over the lease")
+	}
+}
+
+func TestCoordinatorConfig(t *testing.T) {
+	store := NewMemoryStore()
+	for name, cfg := range map[string]CoordinatorConfig{
+		"no instances":   {Limit: 1, Burst: 1},
+		"out of range":   {Instance: 2, Instances: 2, Limit: 1, Burst: 1},
+		"negative limit": {Instances: 1, Limit: -1, Burst: 1},
+	} {
+		if _, err := NewCoordinator(store, "k", cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
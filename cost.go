@@ -0,0 +1,63 @@
+package rateflow
+
+import "context"
+
+// CostFunc returns the number of tokens a request consumes, so expensive
+// operations can be charged more than cheap ones. Middleware and other
+// wrappers call it once per request
+type CostFunc func(request any) int
+
+// ConstantCost charges n tokens for every request
+func ConstantCost(n int) CostFunc {
+	return func(any) int { return n }
+}
+
+// ByteCost charges one token per bytesPerToken bytes of the request,
+// rounded up and at least 1. The size of []byte, string and any type with
+// a Len() int or Size() int64 method is known; anything else costs 1
+func ByteCost(bytesPerToken int) CostFunc {
+	return ByteCostOf(bytesPerToken, SizeOf)
+}
+
+// ByteCostOf is like ByteCost with the request size read by size
+func ByteCostOf(bytesPerToken int, size func(request any) int64) CostFunc {
+	if bytesPerToken < 1 {
+		bytesPerToken = 1
+	}
+	unit := int64(bytesPerToken)
+	return func(request any) int {
+		n := size(request)
+		if n <= 0 {
+			return 1
+		}
+		return int((n + unit - 1) / unit)
+	}
+}
+
+// SizeOf returns the size in bytes of a []byte, string, or value with a
+// Len() int or Size() int64 method such as *bytes.Buffer, *strings.Reader
+// or fs.FileInfo, and -1 for anything else
+func SizeOf(request any) int64 {
+	switch r := request.(type) {
+	case []byte:
+		return int64(len(r))
+	case string:
+		return int64(len(r))
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case interface{ Size() int64 }:
+		return r.Size()
+	}
+	return -1
+}
+
+// AllowCost reports whether request may happen now, consuming its cost
+func AllowCost(lim Limiter, cost CostFunc, request any) bool {
+	ok, _ := lim.AllowDetails(cost(request))
+	return ok
+}
+
+// WaitCost blocks until lim admits request at its cost
+func WaitCost(ctx context.Context, lim Limiter, cost CostFunc, request any) error {
+	return lim.WaitN(ctx, cost(request))
+}
@@ -0,0 +1,41 @@
+package rateflow
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestByteCost(t *testing.T) {
+	cost := ByteCost(1024)
+	tests := []struct {
+		request any
+		want    int
+	}{
+		{make([]byte, 10), 1},
+		{string(make([]byte, 1025)), 2},
+		{bytes.NewBuffer(make([]byte, 4096)), 4},
+		{nil, 1},
+		{struct{}{}, 1},
+	}
+	for _, tt := range tests {
+		if got := cost(tt.request); got != tt.want {
+			t.Errorf("cost(%T) = %d, want %d", tt.request, got, tt.want)
+		}
+	}
+}
+
+func TestAllowCost(t *testing.T) {
+	lim := NewLimiter(TokenBucket, Limit(1), 4)
+	cost := ByteCost(100)
+
+	if !AllowCost(lim, cost, make([]byte, 300)) {
+		t.Fatal("expected a 3 token request to be allowed")
+	}
+	if AllowCost(lim, cost, make([]byte, 200)) {
+		t.Error("expected a 2 token request to exceed the remaining token")
+	}
+	if err := WaitCost(context.Background(), lim, ConstantCost(1), nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
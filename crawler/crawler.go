@@ -0,0 +1,181 @@
+// Package crawler paces a crawler, scraper or link checker so it is
+// polite to every site it visits: requests to one host are spaced by the
+// host's crawl delay, while different hosts proceed independently.
+//
+//	c := crawler.New(crawler.Config{Delay: time.Second, MaxDelay: time.Minute})
+//	if d, ok := crawler.ParseCrawlDelay(robotsTxt, "linkbot"); ok {
+//		c.SetCrawlDelay(u.Host, d)
+//	}
+//	if err := c.Wait(ctx, u); err != nil {
+//		return err
+//	}
+//	resp, err := http.Get(u.String())
+//
+// Hosts are told apart by name and port, ignoring case.
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// Config configures a Crawler
+type Config struct {
+	// Delay is the time between requests to a host with no crawl delay
+	// of its own, 1s if 0
+	Delay time.Duration
+	// MaxDelay caps the crawl delays set per host, so a robots.txt asking
+	// for hours cannot stall the crawl. 0 does not cap them
+	MaxDelay time.Duration
+}
+
+// Crawler spaces requests to each host by its crawl delay
+type Crawler struct {
+	cfg   Config
+	hosts *rateflow.Keyed[string]
+
+	mu     sync.RWMutex
+	delays map[string]time.Duration
+}
+
+// New creates a Crawler
+func New(cfg Config) *Crawler {
+	if cfg.Delay <= 0 {
+		cfg.Delay = time.Second
+	}
+	c := &Crawler{cfg: cfg, delays: make(map[string]time.Duration)}
+	c.hosts = rateflow.NewKeyed(func(host string) rateflow.Limiter {
+		return rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Every(c.CrawlDelay(host)), 1)
+	})
+	return c
+}
+
+// Hosts returns the limiters of the hosts seen so far, e.g. to evict idle
+// hosts from a long crawl with EvictIdle or to cap the total request rate
+// with SetParent
+func (c *Crawler) Hosts() *rateflow.Keyed[string] {
+	return c.hosts
+}
+
+func normalize(host string) string {
+	return strings.ToLower(host)
+}
+
+// SetCrawlDelay sets the time between requests to host, e.g. from the
+// Crawl-delay of its robots.txt, capped at MaxDelay. A delay of 0 or less
+// restores the default
+func (c *Crawler) SetCrawlDelay(host string, d time.Duration) {
+	host = normalize(host)
+	if c.cfg.MaxDelay > 0 && d > c.cfg.MaxDelay {
+		d = c.cfg.MaxDelay
+	}
+	c.mu.Lock()
+	if d > 0 {
+		c.delays[host] = d
+	} else {
+		delete(c.delays, host)
+	}
+	c.mu.Unlock()
+	c.hosts.Get(host).SetLimit(rateflow.Every(c.CrawlDelay(host)))
+}
+
+// CrawlDelay returns the time between requests to host
+func (c *Crawler) CrawlDelay(host string) time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if d, ok := c.delays[normalize(host)]; ok {
+		return d
+	}
+	return c.cfg.Delay
+}
+
+// WaitHost blocks until a request to host may be sent or ctx is done.
+// Like Limiter.Wait it fails at once if the wait would outlast ctx's
+// deadline
+func (c *Crawler) WaitHost(ctx context.Context, host string) error {
+	return c.hosts.WaitKey(ctx, normalize(host))
+}
+
+// AllowHost reports whether a request to host may be sent now, for a
+// crawler that would rather visit another host than wait
+func (c *Crawler) AllowHost(host string) bool {
+	return c.hosts.AllowKey(normalize(host))
+}
+
+// Wait is WaitHost for the host of u
+func (c *Crawler) Wait(ctx context.Context, u *url.URL) error {
+	return c.WaitHost(ctx, u.Host)
+}
+
+// ParseCrawlDelay returns the Crawl-delay robots.txt sets for userAgent.
+// As with the other rules, the group naming the longest part of
+// userAgent applies, or else the group for every agent ("*"). It reports
+// false if the group that applies sets no delay
+func ParseCrawlDelay(robots []byte, userAgent string) (time.Duration, bool) {
+	agent := strings.ToLower(userAgent)
+	var (
+		agents  []string // agents of the current group
+		score   = -1     // how well the current group matches
+		best    = -1     // how well the group that applies matches
+		inRules bool     // whether the current group's rules have started
+		delay   time.Duration
+		found   bool
+	)
+	sc := bufio.NewScanner(bytes.NewReader(robots))
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+
+		if field == "user-agent" {
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+			continue
+		}
+		if !inRules {
+			inRules = true
+			score = match(agents, agent)
+			if score > best {
+				best, delay, found = score, 0, false
+			}
+		}
+		if field != "crawl-delay" || score < 0 || score != best {
+			continue
+		}
+		if secs, err := strconv.ParseFloat(value, 64); err == nil && secs >= 0 {
+			delay, found = time.Duration(secs*float64(time.Second)), true
+		}
+	}
+	return delay, found
+}
+
+// match returns the length of the longest of agents contained in agent,
+// 0 if only "*" matches and -1 if none does
+func match(agents []string, agent string) int {
+	score := -1
+	for _, a := range agents {
+		switch {
+		case a == "*":
+			if score < 0 {
+				score = 0
+			}
+		case a != "" && strings.Contains(agent, a) && len(a) > score:
+			score = len(a)
+		}
+	}
+	return score
+}
@@ -0,0 +1,87 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestCrawler(t *testing.T) {
+	c := New(Config{Delay: time.Hour, MaxDelay: 2 * time.Hour})
+	c.SetCrawlDelay("Slow.example", 5*time.Hour)
+	if d := c.CrawlDelay("slow.example"); d != 2*time.Hour {
+		t.Errorf("CrawlDelay = %v, want it capped at MaxDelay", d)
+	}
+	c.SetCrawlDelay("fast.example", time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	u, _ := url.Parse("https://A.example/page")
+	if err := c.Wait(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	var rle *rateflow.RateLimitError
+	if err := c.WaitHost(ctx, "a.example"); !errors.As(err, &rle) {
+		t.Errorf("second request to a.example = %v, want a RateLimitError", err)
+	}
+	if !c.AllowHost("b.example") {
+		t.Error("expected another host to proceed independently")
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := c.WaitHost(ctx, "fast.example"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Millisecond {
+		t.Errorf("3 requests took %v, want them spaced by the crawl delay", elapsed)
+	}
+
+	c.SetCrawlDelay("fast.example", 0)
+	if d := c.CrawlDelay("fast.example"); d != time.Hour {
+		t.Errorf("CrawlDelay after reset = %v, want the default", d)
+	}
+	if c.Hosts().Len() != 4 {
+		t.Errorf("Hosts().Len() = %d, want 4", c.Hosts().Len())
+	}
+}
+
+func TestParseCrawlDelay(t *testing.T) {
+	robots := []byte(`
+# comments are ignored
+User-agent: *
+Disallow: /private
+Crawl-delay: 10
+
+User-agent: LinkBot
+User-agent: otherbot
+Crawl-delay: 2.5 # seconds
+
+User-agent: quietbot
+Disallow: /
+`)
+	tests := []struct {
+		agent string
+		want  time.Duration
+		ok    bool
+	}{
+		{"Mozilla/5.0 (compatible; linkbot/1.0)", 2500 * time.Millisecond, true},
+		{"OtherBot", 2500 * time.Millisecond, true},
+		{"somebot", 10 * time.Second, true},
+		{"quietbot", 0, false},
+	}
+	for _, tt := range tests {
+		d, ok := ParseCrawlDelay(robots, tt.agent)
+		if d != tt.want || ok != tt.ok {
+			t.Errorf("ParseCrawlDelay(%q) = %v, %v, want %v, %v", tt.agent, d, ok, tt.want, tt.ok)
+		}
+	}
+	if _, ok := ParseCrawlDelay([]byte("User-agent: *\nDisallow:\n"), "bot"); ok {
+		t.Error("expected no delay from a robots.txt without Crawl-delay")
+	}
+}
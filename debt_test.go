@@ -0,0 +1,44 @@
+package rateflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketDebt(t *testing.T) {
+	lim := NewTokenBucketWithDebt(Limit(10), 10, 20)
+	now := time.Now()
+
+	if !lim.AllowN(now, 25) {
+		t.Fatal("expected request within burst plus debt to be allowed")
+	}
+	if tokens := lim.TokensAt(now); tokens != -15 {
+		t.Errorf("expected -15 tokens after overdraw, got %f", tokens)
+	}
+	if lim.AllowN(now, 6) {
+		t.Error("expected request beyond the debt limit to be denied")
+	}
+
+	// The debt is repaid at 10 tokens/s, delaying what follows
+	if lim.AllowN(now.Add(500*time.Millisecond), 11) {
+		t.Error("expected request to be denied while debt is repaid")
+	}
+	if !lim.AllowN(now.Add(2*time.Second), 5) {
+		t.Error("expected request to be allowed once the bucket refilled")
+	}
+}
+
+func TestTokenBucketDebtLimit(t *testing.T) {
+	lim := NewTokenBucketWithDebt(Limit(10), 10, 5)
+	if lim.MaxDebt() != 5 {
+		t.Errorf("expected MaxDebt() = 5, got %d", lim.MaxDebt())
+	}
+	if lim.AllowN(time.Now(), 16) {
+		t.Error("expected request beyond burst plus debt to be denied")
+	}
+
+	lim.SetMaxDebt(0)
+	if lim.AllowN(time.Now(), 11) {
+		t.Error("expected plain token bucket behavior without debt")
+	}
+}
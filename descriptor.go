@@ -0,0 +1,148 @@
+package rateflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DescriptorEntry is one dimension of a Descriptor, e.g. route=/search
+type DescriptorEntry struct {
+	Key   string
+	Value string
+}
+
+// Descriptor is an ordered list of entries, e.g. (tenant, route, method),
+// usable as a Keyed key for limits over several dimensions at once.
+// The zero value is the empty descriptor; descriptors with the same
+// entries in the same order are equal
+type Descriptor struct {
+	enc string // length-prefixed keys and values
+}
+
+// NewDescriptor returns a descriptor of the given entries in order
+func NewDescriptor(entries ...DescriptorEntry) Descriptor {
+	var d Descriptor
+	for _, e := range entries {
+		d = d.With(e.Key, e.Value)
+	}
+	return d
+}
+
+// With returns d with the entry key=value appended
+func (d Descriptor) With(key, value string) Descriptor {
+	var b strings.Builder
+	b.Grow(len(d.enc) + len(key) + len(value) + 8)
+	b.WriteString(d.enc)
+	for _, s := range [2]string{key, value} {
+		b.WriteString(strconv.Itoa(len(s)))
+		b.WriteByte(':')
+		b.WriteString(s)
+	}
+	return Descriptor{enc: b.String()}
+}
+
+// Entries returns the entries of d in order
+func (d Descriptor) Entries() []DescriptorEntry {
+	var entries []DescriptorEntry
+	for rest := d.enc; rest != ""; {
+		var key, value string
+		key, rest, _ = nextField(rest)
+		value, rest, _ = nextField(rest)
+		entries = append(entries, DescriptorEntry{Key: key, Value: value})
+	}
+	return entries
+}
+
+// nextField splits the first length-prefixed field off enc, reporting
+// whether enc starts with one as With writes it
+func nextField(enc string) (field, rest string, ok bool) {
+	i := strings.IndexByte(enc, ':')
+	if i <= 0 {
+		return "", "", false
+	}
+	n, err := strconv.Atoi(enc[:i])
+	if err != nil || n < 0 || n > len(enc)-i-1 || strconv.Itoa(n) != enc[:i] {
+		return "", "", false
+	}
+	return enc[i+1 : i+1+n], enc[i+1+n:], true
+}
+
+// Value returns the value of the first entry with the given key
+func (d Descriptor) Value(key string) (string, bool) {
+	for _, e := range d.Entries() {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return "", false
+}
+
+// String formats d as key=value pairs, e.g. "tenant=acme,route=/search"
+func (d Descriptor) String() string {
+	entries := d.Entries()
+	parts := make([]string, len(entries))
+	for i, e := range entries {
+		parts[i] = e.Key + "=" + e.Value
+	}
+	return strings.Join(parts, ",")
+}
+
+// MarshalBinary encodes d, so descriptors survive Keyed.Snapshot
+func (d Descriptor) MarshalBinary() ([]byte, error) {
+	return []byte(d.enc), nil
+}
+
+// UnmarshalBinary decodes a descriptor encoded by MarshalBinary. It
+// rejects data MarshalBinary could not have produced
+func (d *Descriptor) UnmarshalBinary(data []byte) error {
+	enc := string(data)
+	for rest := enc; rest != ""; {
+		var keyOK, valueOK bool
+		_, rest, keyOK = nextField(rest)
+		_, rest, valueOK = nextField(rest)
+		if !keyOK || !valueOK {
+			return fmt.Errorf("rateflow: malformed descriptor %q", data)
+		}
+	}
+	d.enc = enc
+	return nil
+}
+
+// DescriptorRule sets the limits for the descriptors it matches
+// A rule matches a descriptor whose leading entries have the keys of
+// Match in the same order; an empty Value in Match accepts any value, so
+// every distinct value gets its own limiter
+type DescriptorRule struct {
+	Match []DescriptorEntry
+	Spec  LimitSpec
+}
+
+// Matches reports whether r applies to d
+func (r DescriptorRule) Matches(d Descriptor) bool {
+	entries := d.Entries()
+	if len(r.Match) > len(entries) {
+		return false
+	}
+	for i, m := range r.Match {
+		if entries[i].Key != m.Key || (m.Value != "" && entries[i].Value != m.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// DescriptorRules is a LimitProvider for Descriptor keys. Rules are tried
+// in order and the first match wins, so list specific rules before
+// general ones
+type DescriptorRules []DescriptorRule
+
+// LimitFor returns the spec of the first rule matching d
+func (rs DescriptorRules) LimitFor(d Descriptor) (LimitSpec, error) {
+	for _, r := range rs {
+		if r.Matches(d) {
+			return r.Spec, nil
+		}
+	}
+	return LimitSpec{}, fmt.Errorf("%w: %s", ErrNoRuleMatches, d)
+}
@@ -0,0 +1,69 @@
+package rateflow
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDescriptor(t *testing.T) {
+	d := Descriptor{}.With("tenant", "acme").With("route", "/a:b,c")
+	if d != NewDescriptor(DescriptorEntry{"tenant", "acme"}, DescriptorEntry{"route", "/a:b,c"}) {
+		t.Error("expected descriptors with the same entries to be equal")
+	}
+	if d == (Descriptor{}).With("route", "/a:b,c").With("tenant", "acme") {
+		t.Error("expected entry order to matter")
+	}
+	if v, ok := d.Value("route"); !ok || v != "/a:b,c" {
+		t.Errorf("expected route /a:b,c, got %q %v", v, ok)
+	}
+	if s := d.String(); s != "tenant=acme,route=/a:b,c" {
+		t.Errorf("unexpected String %q", s)
+	}
+	if n := len(d.Entries()); n != 2 {
+		t.Errorf("expected 2 entries, got %d", n)
+	}
+}
+
+func TestDescriptorUnmarshalBinary(t *testing.T) {
+	d := Descriptor{}.With("tenant", "acme").With("route", "")
+	data, _ := d.MarshalBinary()
+	var got Descriptor
+	if err := got.UnmarshalBinary(data); err != nil || got != d {
+		t.Errorf("UnmarshalBinary = %v, %v, want %v", got, err, d)
+	}
+
+	for _, bad := range []string{"tenant", "6:tenant", "7:tenant4:acme", "-1:x0:", ":0:", "01:a1:b", "+1:a1:b", "99999999999999999999:a"} {
+		got := Descriptor{}.With("kept", "1")
+		if err := got.UnmarshalBinary([]byte(bad)); err == nil {
+			t.Errorf("UnmarshalBinary(%q) = nil, want an error", bad)
+		}
+		if got.String() != "kept=1" {
+			t.Errorf("UnmarshalBinary(%q) changed the descriptor to %v", bad, got)
+		}
+	}
+}
+
+func TestDescriptorRules(t *testing.T) {
+	rules := DescriptorRules{
+		{Match: []DescriptorEntry{{"user", ""}, {"route", "/search"}}, Spec: LimitSpec{Algorithm: TokenBucket, Limit: 1, Burst: 1}},
+		{Match: []DescriptorEntry{{"user", ""}}, Spec: LimitSpec{Algorithm: TokenBucket, Limit: 10, Burst: 3}},
+	}
+	k := NewKeyedProvider[Descriptor](rules, LimitSpec{Algorithm: TokenBucket, Limit: 1, Burst: 1})
+
+	search := func(user string) Descriptor {
+		return Descriptor{}.With("user", user).With("route", "/search")
+	}
+	if !k.AllowKey(search("alice")) || k.AllowKey(search("alice")) {
+		t.Error("expected alice's /search limit of 1")
+	}
+	if !k.AllowKey(search("bob")) {
+		t.Error("expected bob to get a separate /search limiter")
+	}
+	if b := k.Get(Descriptor{}.With("user", "alice").With("route", "/home")).Burst(); b != 3 {
+		t.Errorf("expected the general rule for /home, got burst %d", b)
+	}
+
+	if _, err := rules.LimitFor(Descriptor{}.With("ip", "10.0.0.1")); !errors.Is(err, ErrNoRuleMatches) {
+		t.Errorf("expected ErrNoRuleMatches, got %v", err)
+	}
+}
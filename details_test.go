@@ -0,0 +1,70 @@
+package rateflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowDetails(t *testing.T) {
+	for _, algo := range Algorithms()[:DualRate+1] {
+		lim := NewLimiter(algo, Limit(10), 5)
+		now := time.Now()
+
+		for i := 0; i < 5; i++ {
+			ok, res := lim.AllowDetailsAt(now, 1)
+			if !ok {
+				t.Fatalf("%s: request %d denied", algo, i)
+			}
+			if res.Limit != 5 || res.Remaining != 4-i || res.RetryAfter != 0 {
+				t.Errorf("%s: request %d got %+v", algo, i, res)
+			}
+			if res.ResetAt.Before(now) {
+				t.Errorf("%s: ResetAt %v is in the past", algo, res.ResetAt)
+			}
+		}
+
+		ok, res := lim.AllowDetailsAt(now, 1)
+		if ok {
+			t.Errorf("%s: expected the sixth request to be denied", algo)
+		}
+		if res.Remaining != 0 || res.RetryAfter <= 0 || res.RetryAfter == InfDuration {
+			t.Errorf("%s: denied request got %+v", algo, res)
+		}
+
+		if _, res := lim.AllowDetailsAt(now, 6); res.RetryAfter != InfDuration {
+			t.Errorf("%s: expected InfDuration for n > burst, got %v", algo, res.RetryAfter)
+		}
+	}
+}
+
+func TestAllowDetailsValues(t *testing.T) {
+	tb := NewLimiter(TokenBucket, Limit(10), 5)
+	now := time.Now()
+	tb.AllowN(now, 5)
+	_, res := tb.AllowDetailsAt(now, 3)
+	if res.RetryAfter != 300*time.Millisecond {
+		t.Errorf("token bucket: expected RetryAfter 300ms, got %v", res.RetryAfter)
+	}
+	if !res.ResetAt.Equal(now.Add(500 * time.Millisecond)) {
+		t.Errorf("token bucket: expected ResetAt %v, got %v", now.Add(500*time.Millisecond), res.ResetAt)
+	}
+
+	fw := NewWindowLimiter(FixedWindow, 2, time.Minute)
+	now = time.Now()
+	fw.AllowN(now, 2)
+	_, res = fw.AllowDetailsAt(now.Add(20*time.Second), 1)
+	if res.RetryAfter > 40*time.Second || res.RetryAfter < 39*time.Second {
+		t.Errorf("fixed window: expected RetryAfter ~40s, got %v", res.RetryAfter)
+	}
+
+	sw := NewWindowLimiter(SlidingWindow, 2, time.Minute)
+	sw.AllowN(now, 1)
+	sw.AllowN(now.Add(10*time.Second), 1)
+	_, res = sw.AllowDetailsAt(now.Add(20*time.Second), 1)
+	if res.RetryAfter != 40*time.Second {
+		t.Errorf("sliding window: expected RetryAfter 40s, got %v", res.RetryAfter)
+	}
+	if !res.ResetAt.Equal(now.Add(70 * time.Second)) {
+		t.Errorf("sliding window: expected ResetAt %v, got %v", now.Add(70*time.Second), res.ResetAt)
+	}
+}
@@ -0,0 +1,73 @@
+package envoyrls
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Register serves s on srv as envoy.service.ratelimit.v3.RateLimitService,
+// the service Envoy's ratelimit filter calls:
+//
+//	srv := grpc.NewServer()
+//	svc.Register(srv)
+//	srv.Serve(lis)
+func (s *Service) Register(srv grpc.ServiceRegistrar) {
+	rlsv3.RegisterRateLimitServiceServer(srv, rlsServer{svc: s})
+}
+
+// rlsServer adapts a Service to the generated RateLimitService
+type rlsServer struct {
+	rlsv3.UnimplementedRateLimitServiceServer
+	svc *Service
+}
+
+// ShouldRateLimit decides in with Service.ShouldRateLimit. An unknown
+// domain fails with INVALID_ARGUMENT, which Envoy treats like any other
+// error of the service, by its failure_mode_deny setting
+func (s rlsServer) ShouldRateLimit(ctx context.Context, in *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	req := &Request{Domain: in.GetDomain(), HitsAddend: in.GetHitsAddend()}
+	for _, d := range in.GetDescriptors() {
+		var desc Descriptor
+		for _, e := range d.GetEntries() {
+			desc.Entries = append(desc.Entries, Entry{Key: e.GetKey(), Value: e.GetValue()})
+		}
+		req.Descriptors = append(req.Descriptors, desc)
+	}
+
+	resp, err := s.svc.ShouldRateLimit(ctx, req)
+	if errors.Is(err, ErrUnknownDomain) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := &rlsv3.RateLimitResponse{
+		OverallCode: rlsv3.RateLimitResponse_Code(resp.OverallCode),
+		Statuses:    make([]*rlsv3.RateLimitResponse_DescriptorStatus, len(resp.Statuses)),
+	}
+	for i, st := range resp.Statuses {
+		ds := &rlsv3.RateLimitResponse_DescriptorStatus{
+			Code:           rlsv3.RateLimitResponse_Code(st.Code),
+			LimitRemaining: st.LimitRemaining,
+		}
+		if st.CurrentLimit != nil {
+			ds.CurrentLimit = &rlsv3.RateLimitResponse_RateLimit{
+				RequestsPerUnit: st.CurrentLimit.RequestsPerUnit,
+				Unit:            rlsv3.RateLimitResponse_RateLimit_Unit(st.CurrentLimit.Unit),
+			}
+		}
+		if st.DurationUntilReset > 0 {
+			ds.DurationUntilReset = durationpb.New(time.Duration(st.DurationUntilReset))
+		}
+		out.Statuses[i] = ds
+	}
+	return out, nil
+}
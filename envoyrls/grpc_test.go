@@ -0,0 +1,68 @@
+package envoyrls
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestRegister(t *testing.T) {
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer()
+	newService().Register(srv)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := rlsv3.NewRateLimitServiceClient(conn)
+	ctx := context.Background()
+
+	req := &rlsv3.RateLimitRequest{
+		Domain: "edge",
+		Descriptors: []*ratelimitv3.RateLimitDescriptor{{
+			Entries: []*ratelimitv3.RateLimitDescriptor_Entry{{Key: "path", Value: "/login"}},
+		}},
+		HitsAddend: 2,
+	}
+	resp, err := client.ShouldRateLimit(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.OverallCode != rlsv3.RateLimitResponse_OK {
+		t.Fatalf("OverallCode = %v, want OK", resp.OverallCode)
+	}
+	st := resp.Statuses[0]
+	if lim := st.CurrentLimit; lim.GetRequestsPerUnit() != 2 || lim.GetUnit() != rlsv3.RateLimitResponse_RateLimit_MINUTE {
+		t.Errorf("CurrentLimit = %v, want 2 per minute", lim)
+	}
+	if st.DurationUntilReset.AsDuration() <= 0 {
+		t.Errorf("DurationUntilReset = %v, want the time to refill", st.DurationUntilReset)
+	}
+
+	resp, err = client.ShouldRateLimit(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.OverallCode != rlsv3.RateLimitResponse_OVER_LIMIT || resp.Statuses[0].Code != rlsv3.RateLimitResponse_OVER_LIMIT {
+		t.Errorf("response = %v, want OVER_LIMIT once the burst is spent", resp)
+	}
+
+	req.Domain = "other"
+	if _, err := client.ShouldRateLimit(ctx, req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unknown domain: %v, want InvalidArgument", err)
+	}
+}
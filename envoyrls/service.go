@@ -0,0 +1,186 @@
+// Package envoyrls is an external rate limit service for Envoy and Istio
+// gateways, implementing envoy.service.ratelimit.v3.RateLimitService on
+// rateflow's keyed limiters. Each domain has its own DescriptorRules and
+// every distinct descriptor gets its own limiter.
+//
+// Register serves a Service over gRPC for Envoy's ratelimit filter, and
+// ServeHTTP serves the same messages as JSON over HTTP, like the /json
+// endpoint of Envoy's reference rate limit service. Request and Response
+// mirror the proto messages field for field. The package is a module of
+// its own, so only programs using it depend on gRPC and the Envoy protos.
+package envoyrls
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// ErrUnknownDomain is returned for requests naming a domain the service
+// has no rules for
+var ErrUnknownDomain = errors.New("envoyrls: unknown domain")
+
+type domain struct {
+	rules rateflow.DescriptorRules
+	keyed *rateflow.Keyed[rateflow.Descriptor]
+}
+
+// Service decides RateLimitRequests
+type Service struct {
+	opts []rateflow.Option
+
+	mu      sync.RWMutex
+	domains map[string]*domain
+}
+
+// NewService creates a service with rules per domain. opts apply to
+// every limiter
+func NewService(domains map[string]rateflow.DescriptorRules, opts ...rateflow.Option) *Service {
+	s := &Service{opts: opts, domains: make(map[string]*domain)}
+	for name, rules := range domains {
+		s.SetRules(name, rules)
+	}
+	return s
+}
+
+// SetRules sets the rules of a domain, adding it if it is new. Limiters
+// of existing descriptors are retuned in place like Keyed.ApplyConfig
+func (s *Service) SetRules(name string, rules rateflow.DescriptorRules) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d, ok := s.domains[name]; ok {
+		d.keyed.ApplyConfig(rules)
+		d.rules = rules
+		return
+	}
+	s.domains[name] = &domain{
+		rules: rules,
+		keyed: rateflow.NewKeyedProvider[rateflow.Descriptor](rules, rateflow.LimitSpec{}, s.opts...),
+	}
+}
+
+// Domain returns the keyed limiter of a domain, e.g. to set a maximum
+// number of descriptors or read usage
+func (s *Service) Domain(name string) (*rateflow.Keyed[rateflow.Descriptor], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.domains[name]
+	if !ok {
+		return nil, false
+	}
+	return d.keyed, true
+}
+
+// ShouldRateLimit charges every descriptor of req against its limiter.
+// Like Envoy's reference service each descriptor is charged on its own,
+// and the request is over limit if any of them is. Descriptors no rule
+// matches are OK and not limited
+func (s *Service) ShouldRateLimit(ctx context.Context, req *Request) (*Response, error) {
+	s.mu.RLock()
+	d, ok := s.domains[req.Domain]
+	rules := d.rulesOrNil()
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDomain, req.Domain)
+	}
+
+	hits := int(req.HitsAddend)
+	if hits == 0 {
+		hits = 1
+	}
+	resp := &Response{OverallCode: CodeOK, Statuses: make([]DescriptorStatus, len(req.Descriptors))}
+	for i, desc := range req.Descriptors {
+		var key rateflow.Descriptor
+		for _, e := range desc.Entries {
+			key = key.With(e.Key, e.Value)
+		}
+		spec, err := rules.LimitFor(key)
+		if err != nil {
+			resp.Statuses[i] = DescriptorStatus{Code: CodeOK}
+			continue
+		}
+
+		allowed, res := d.keyed.AllowDetailsKey(key, hits)
+		st := DescriptorStatus{Code: CodeOK, CurrentLimit: rateLimit(spec)}
+		if res.Remaining > 0 {
+			st.LimitRemaining = uint32(res.Remaining)
+		}
+		if !res.ResetAt.IsZero() {
+			if until := time.Until(res.ResetAt); until > 0 {
+				st.DurationUntilReset = Duration(until)
+			}
+		}
+		if !allowed {
+			st.Code = CodeOverLimit
+			resp.OverallCode = CodeOverLimit
+		}
+		resp.Statuses[i] = st
+	}
+	return resp, nil
+}
+
+// rulesOrNil returns d's rules, or none for a nil domain
+func (d *domain) rulesOrNil() rateflow.DescriptorRules {
+	if d == nil {
+		return nil
+	}
+	return d.rules
+}
+
+// units are the periods a limit may be reported in, shortest first
+var units = []struct {
+	unit Unit
+	secs float64
+}{
+	{UnitSecond, 1},
+	{UnitMinute, 60},
+	{UnitHour, 3600},
+	{UnitDay, 86400},
+}
+
+// rateLimit reports spec in the shortest unit that counts a whole
+// number of requests, e.g. 0.5/s as 30 per minute
+func rateLimit(spec rateflow.LimitSpec) *RateLimit {
+	if spec.Limit == rateflow.Inf {
+		return &RateLimit{RequestsPerUnit: math.MaxUint32, Unit: UnitSecond}
+	}
+	for _, u := range units {
+		n := float64(spec.Limit) * u.secs
+		if n >= 1 && math.Abs(n-math.Round(n)) < 1e-6 {
+			return &RateLimit{RequestsPerUnit: uint32(math.Round(n)), Unit: u.unit}
+		}
+	}
+	return &RateLimit{RequestsPerUnit: uint32(math.Round(float64(spec.Limit) * 86400)), Unit: UnitDay}
+}
+
+// ServeHTTP serves ShouldRateLimit as JSON: a POSTed Request gets its
+// Response, with status 429 when it is over limit
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := s.ShouldRateLimit(r.Context(), &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.OverallCode == CodeOverLimit {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,174 @@
+package envoyrls
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func newService() *Service {
+	return NewService(map[string]rateflow.DescriptorRules{
+		"edge": {
+			{
+				Match: []rateflow.DescriptorEntry{{Key: "path", Value: "/login"}},
+				Spec:  rateflow.LimitSpec{Algorithm: rateflow.TokenBucket, Limit: rateflow.PerMinute(2), Burst: 2},
+			},
+			{
+				Match: []rateflow.DescriptorEntry{{Key: "remote_address"}},
+				Spec:  rateflow.LimitSpec{Algorithm: rateflow.TokenBucket, Limit: 10, Burst: 1},
+			},
+		},
+	})
+}
+
+func desc(kv ...string) Descriptor {
+	var d Descriptor
+	for i := 0; i < len(kv); i += 2 {
+		d.Entries = append(d.Entries, Entry{Key: kv[i], Value: kv[i+1]})
+	}
+	return d
+}
+
+func TestShouldRateLimit(t *testing.T) {
+	s := newService()
+	ctx := context.Background()
+	req := &Request{Domain: "edge", Descriptors: []Descriptor{
+		desc("path", "/login"),
+		desc("remote_address", "10.0.0.1"),
+		desc("user", "alice"),
+	}}
+
+	resp, err := s.ShouldRateLimit(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.OverallCode != CodeOK {
+		t.Fatalf("OverallCode = %v, want OK", resp.OverallCode)
+	}
+	login := resp.Statuses[0]
+	if login.CurrentLimit == nil || *login.CurrentLimit != (RateLimit{RequestsPerUnit: 2, Unit: UnitMinute}) {
+		t.Errorf("CurrentLimit = %+v, want 2 per minute", login.CurrentLimit)
+	}
+	if login.LimitRemaining != 1 {
+		t.Errorf("LimitRemaining = %d, want 1", login.LimitRemaining)
+	}
+	if resp.Statuses[2].Code != CodeOK || resp.Statuses[2].CurrentLimit != nil {
+		t.Errorf("unmatched descriptor = %+v, want OK without a limit", resp.Statuses[2])
+	}
+
+	resp, _ = s.ShouldRateLimit(ctx, req)
+	if resp.OverallCode != CodeOverLimit {
+		t.Errorf("OverallCode = %v, want OVER_LIMIT once an address is over", resp.OverallCode)
+	}
+	if resp.Statuses[0].Code != CodeOK || resp.Statuses[1].Code != CodeOverLimit {
+		t.Errorf("statuses = %+v, want only the address over limit", resp.Statuses)
+	}
+	if resp.Statuses[1].DurationUntilReset <= 0 {
+		t.Error("expected a time until the address limit resets")
+	}
+
+	req = &Request{Domain: "edge", HitsAddend: 5, Descriptors: []Descriptor{desc("remote_address", "10.0.0.2")}}
+	if resp, _ := s.ShouldRateLimit(ctx, req); resp.OverallCode != CodeOverLimit {
+		t.Error("hits above the burst should be over limit")
+	}
+
+	if _, err := s.ShouldRateLimit(ctx, &Request{Domain: "other"}); !errors.Is(err, ErrUnknownDomain) {
+		t.Errorf("err = %v, want ErrUnknownDomain", err)
+	}
+	if keyed, _ := s.Domain("edge"); keyed.Len() != 3 {
+		t.Errorf("Len = %d, want one limiter per limited descriptor", keyed.Len())
+	}
+}
+
+func TestShouldRateLimitKeyedLists(t *testing.T) {
+	s := newService()
+	ctx := context.Background()
+	keyed, _ := s.Domain("edge")
+	keyed.SetDenylist(rateflow.NewKeyList(rateflow.Descriptor{}.With("remote_address", "10.0.0.9")))
+
+	req := &Request{Domain: "edge", Descriptors: []Descriptor{desc("remote_address", "10.0.0.9")}}
+	if resp, _ := s.ShouldRateLimit(ctx, req); resp.OverallCode != CodeOverLimit {
+		t.Error("a denylisted descriptor should be over limit")
+	}
+
+	keyed.SetParent(rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Every(time.Hour), 1))
+	for i, want := range []Code{CodeOK, CodeOverLimit} {
+		req := &Request{Domain: "edge", Descriptors: []Descriptor{desc("remote_address", fmt.Sprintf("10.0.1.%d", i))}}
+		if resp, _ := s.ShouldRateLimit(ctx, req); resp.OverallCode != want {
+			t.Errorf("address %d: OverallCode = %v, want %v under the parent", i, resp.OverallCode, want)
+		}
+	}
+}
+
+func TestSetRules(t *testing.T) {
+	s := newService()
+	ctx := context.Background()
+	req := &Request{Domain: "edge", Descriptors: []Descriptor{desc("remote_address", "10.0.0.1")}}
+	s.ShouldRateLimit(ctx, req)
+
+	s.SetRules("edge", rateflow.DescriptorRules{{
+		Match: []rateflow.DescriptorEntry{{Key: "remote_address"}},
+		Spec:  rateflow.LimitSpec{Algorithm: rateflow.TokenBucket, Limit: 20, Burst: 5},
+	}})
+	resp, _ := s.ShouldRateLimit(ctx, req)
+	if got := resp.Statuses[0].CurrentLimit; got == nil || got.RequestsPerUnit != 20 {
+		t.Errorf("CurrentLimit = %+v, want the new rules", got)
+	}
+	if resp.OverallCode != CodeOverLimit {
+		t.Error("the existing limiter should keep its usage")
+	}
+	s.SetRules("api", nil)
+	if _, ok := s.Domain("api"); !ok {
+		t.Error("SetRules should add new domains")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	srv := httptest.NewServer(newService())
+	defer srv.Close()
+
+	body, _ := json.Marshal(Request{Domain: "edge", Descriptors: []Descriptor{desc("remote_address", "10.0.0.1")}})
+	post := func() (*http.Response, Response) {
+		resp, err := http.Post(srv.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out Response
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		return resp, out
+	}
+
+	resp, out := post()
+	if resp.StatusCode != http.StatusOK || out.OverallCode != CodeOK {
+		t.Errorf("first request = %d %v, want 200 OK", resp.StatusCode, out.OverallCode)
+	}
+	if out.Statuses[0].CurrentLimit.Unit != UnitSecond {
+		t.Errorf("unit = %v, want SECOND", out.Statuses[0].CurrentLimit.Unit)
+	}
+	resp, out = post()
+	if resp.StatusCode != http.StatusTooManyRequests || out.OverallCode != CodeOverLimit {
+		t.Errorf("second request = %d %v, want 429 OVER_LIMIT", resp.StatusCode, out.OverallCode)
+	}
+}
+
+func TestJSONNames(t *testing.T) {
+	data, err := json.Marshal(DescriptorStatus{Code: CodeOverLimit, CurrentLimit: &RateLimit{RequestsPerUnit: 5, Unit: UnitHour}, DurationUntilReset: Duration(1500e6)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"code":"OVER_LIMIT","currentLimit":{"requestsPerUnit":5,"unit":"HOUR"},"limitRemaining":0,"durationUntilReset":"1.5s"}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
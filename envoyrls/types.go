@@ -0,0 +1,134 @@
+package envoyrls
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Code is the verdict for a request or one of its descriptors
+type Code int32
+
+const (
+	CodeUnknown   Code = 0
+	CodeOK        Code = 1
+	CodeOverLimit Code = 2
+)
+
+var codeNames = []string{"UNKNOWN", "OK", "OVER_LIMIT"}
+
+// MarshalText encodes c by its proto enum name
+func (c Code) MarshalText() ([]byte, error) {
+	if c < 0 || int(c) >= len(codeNames) {
+		return nil, fmt.Errorf("envoyrls: unknown code %d", c)
+	}
+	return []byte(codeNames[c]), nil
+}
+
+// UnmarshalText decodes c from its proto enum name
+func (c *Code) UnmarshalText(text []byte) error {
+	for i, name := range codeNames {
+		if name == string(text) {
+			*c = Code(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("envoyrls: unknown code %q", text)
+}
+
+// Unit is the period a RateLimit counts requests over
+type Unit int32
+
+const (
+	UnitUnknown Unit = 0
+	UnitSecond  Unit = 1
+	UnitMinute  Unit = 2
+	UnitHour    Unit = 3
+	UnitDay     Unit = 4
+)
+
+var unitNames = []string{"UNKNOWN", "SECOND", "MINUTE", "HOUR", "DAY"}
+
+// MarshalText encodes u by its proto enum name
+func (u Unit) MarshalText() ([]byte, error) {
+	if u < 0 || int(u) >= len(unitNames) {
+		return nil, fmt.Errorf("envoyrls: unknown unit %d", u)
+	}
+	return []byte(unitNames[u]), nil
+}
+
+// UnmarshalText decodes u from its proto enum name
+func (u *Unit) UnmarshalText(text []byte) error {
+	for i, name := range unitNames {
+		if name == string(text) {
+			*u = Unit(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("envoyrls: unknown unit %q", text)
+}
+
+// Duration is a time.Duration encoded like a protobuf Duration in JSON
+type Duration time.Duration
+
+// MarshalText encodes d as seconds with an "s" suffix, e.g. "1.5s"
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(strconv.FormatFloat(time.Duration(d).Seconds(), 'f', -1, 64) + "s"), nil
+}
+
+// UnmarshalText decodes seconds with an "s" suffix
+func (d *Duration) UnmarshalText(text []byte) error {
+	s, ok := strings.CutSuffix(string(text), "s")
+	if !ok {
+		return fmt.Errorf("envoyrls: bad duration %q", text)
+	}
+	secs, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*d = Duration(secs * float64(time.Second))
+	return nil
+}
+
+// Entry is one key/value pair of a descriptor
+type Entry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Descriptor is one descriptor of a request, e.g.
+// [("remote_address", "10.0.0.1"), ("path", "/login")]
+type Descriptor struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Request mirrors envoy.service.ratelimit.v3.RateLimitRequest
+type Request struct {
+	Domain      string       `json:"domain"`
+	Descriptors []Descriptor `json:"descriptors"`
+	// HitsAddend is the number of hits each descriptor is charged; 0
+	// means 1
+	HitsAddend uint32 `json:"hitsAddend,omitempty"`
+}
+
+// RateLimit mirrors RateLimitResponse.RateLimit
+type RateLimit struct {
+	RequestsPerUnit uint32 `json:"requestsPerUnit"`
+	Unit            Unit   `json:"unit"`
+}
+
+// DescriptorStatus mirrors RateLimitResponse.DescriptorStatus
+type DescriptorStatus struct {
+	Code Code `json:"code"`
+	// CurrentLimit is nil for descriptors no rule limits
+	CurrentLimit       *RateLimit `json:"currentLimit,omitempty"`
+	LimitRemaining     uint32     `json:"limitRemaining"`
+	DurationUntilReset Duration   `json:"durationUntilReset"`
+}
+
+// Response mirrors envoy.service.ratelimit.v3.RateLimitResponse
+type Response struct {
+	OverallCode Code               `json:"overallCode"`
+	Statuses    []DescriptorStatus `json:"statuses"`
+}
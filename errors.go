@@ -0,0 +1,65 @@
+package rateflow
+
+import (
+	"errors"
+
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+var (
+	// ErrInvalidLimit is returned for a negative or NaN limit
+	ErrInvalidLimit = limiter.ErrInvalidLimit
+	// ErrInvalidBurst is returned for a burst below 1
+	ErrInvalidBurst = limiter.ErrInvalidBurst
+	// ErrInvalidWindow is returned for a negative window
+	ErrInvalidWindow = limiter.ErrInvalidWindow
+	// ErrUnknownAlgorithm is returned for an algorithm that was never registered
+	ErrUnknownAlgorithm = limiter.ErrUnknownAlgorithm
+	// ErrInvalidSchedule is returned by NewSchedule for a spec that does
+	// not parse
+	ErrInvalidSchedule = limiter.ErrInvalidSchedule
+
+	// ErrExceedsBurst is matched by the error WaitN returns when n is
+	// larger than the limiter could ever admit at once
+	ErrExceedsBurst = limiter.ErrExceedsBurst
+
+	// ErrReservationNotOK is returned by Reservation.Act for a reservation
+	// that can never be fulfilled
+	ErrReservationNotOK = limiter.ErrReservationNotOK
+
+	// ErrStateMismatch is returned when restoring limiter state exported
+	// by a different kind of limiter, or one configured differently
+	ErrStateMismatch = limiter.ErrStateMismatch
+
+	// ErrNoRuleMatches is returned by DescriptorRules and PrefixRules for
+	// a key no rule matches
+	ErrNoRuleMatches = limiter.ErrNoRuleMatches
+
+	// ErrDenylisted is returned by Keyed.WaitKey for a key on the
+	// denylist and by Policy.Wait for a request an ActionDeny rule matches
+	ErrDenylisted = limiter.ErrDenylisted
+
+	// ErrNoProvider is returned by Keyed.ApplyConfig for a keyed limiter
+	// not created by NewKeyedProvider
+	ErrNoProvider = limiter.ErrNoProvider
+
+	// ErrContention is returned by limiters on a Store that kept changing
+	// under an update for every retry
+	ErrContention = errors.New("rateflow: store contention")
+
+	// ErrBreakerOpen is returned by a Breaker that is not letting calls
+	// through to its store
+	ErrBreakerOpen = errors.New("rateflow: circuit breaker open")
+)
+
+// RateLimitError is returned by WaitN when the wait would outlast the
+// context deadline, and by WaitMaxN when it would take longer than
+// maxWait; both fail immediately instead of sleeping until the deadline.
+// It matches context.DeadlineExceeded, and RetryAfter tells the caller how
+// long to back off
+type RateLimitError = limiter.RateLimitError
+
+// ConfigError describes a constructor argument rejected by NewLimiterE.
+// Use errors.Is with ErrInvalidLimit, ErrInvalidBurst, ErrInvalidWindow
+// or ErrUnknownAlgorithm to find out which check failed
+type ConfigError = limiter.ConfigError
@@ -0,0 +1,139 @@
+// Package etcdstore implements rateflow.RemoteLimiter on etcd, for teams
+// that already run etcd and want strongly consistent limits without
+// adding Redis. Each decision reads a key, computes its new state and
+// writes it back in a transaction guarded by the key's ModRevision, so
+// replicas never overwrite each other's updates. Keys are attached to a
+// lease, which removes the state of keys that are no longer used.
+//
+// The package does not depend on the etcd client. A Client adapts it,
+// e.g. for go.etcd.io/etcd/client/v3:
+//
+//	type client struct{ cli *clientv3.Client }
+//
+//	func (c client) Get(ctx context.Context, key string) ([]byte, int64, error) {
+//		resp, err := c.cli.Get(ctx, key)
+//		if err != nil || len(resp.Kvs) == 0 {
+//			return nil, 0, err
+//		}
+//		return resp.Kvs[0].Value, resp.Kvs[0].ModRevision, nil
+//	}
+//
+//	func (c client) PutIf(ctx context.Context, key string, value []byte, rev, lease int64) (bool, error) {
+//		resp, err := c.cli.Txn(ctx).
+//			If(clientv3.Compare(clientv3.ModRevision(key), "=", rev)).
+//			Then(clientv3.OpPut(key, string(value), clientv3.WithLease(clientv3.LeaseID(lease)))).
+//			Commit()
+//		if errors.Is(err, rpctypes.ErrLeaseNotFound) {
+//			return false, etcdstore.ErrLeaseNotFound
+//		}
+//		if err != nil {
+//			return false, err
+//		}
+//		return resp.Succeeded, nil
+//	}
+//
+//	func (c client) Grant(ctx context.Context, ttl time.Duration) (int64, error) {
+//		resp, err := c.cli.Grant(ctx, int64(ttl/time.Second))
+//		if err != nil {
+//			return 0, err
+//		}
+//		return int64(resp.ID), nil
+//	}
+package etcdstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLeaseNotFound is returned by Client.PutIf when the lease has
+	// expired or been revoked; the limiter then grants a new one
+	ErrLeaseNotFound = errors.New("etcdstore: lease not found")
+
+	// ErrContention is returned when a decision lost its transaction to
+	// other replicas more times in a row than WithMaxRetries allows
+	ErrContention = errors.New("etcdstore: too much contention")
+)
+
+// Client is the subset of an etcd client the limiters use
+type Client interface {
+	// Get returns the value of key and its ModRevision, or a nil value
+	// and revision 0 if key does not exist
+	Get(ctx context.Context, key string) (value []byte, rev int64, err error)
+	// PutIf stores value under key, attached to lease, in a transaction
+	// that only succeeds if key's ModRevision still equals rev; a rev of
+	// 0 requires key not to exist
+	PutIf(ctx context.Context, key string, value []byte, rev, lease int64) (bool, error)
+	// Grant creates a lease expiring after ttl, in whole seconds
+	Grant(ctx context.Context, ttl time.Duration) (int64, error)
+}
+
+// leaser hands out a lease shared by every key a limiter writes. A key is
+// attached to the current lease each time it changes, and a new lease is
+// granted once half of the current one's TTL has passed, so a key lives
+// at least half a TTL after its last use and at most a full one
+type leaser struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	id      int64
+	renewAt time.Time
+}
+
+// lease returns the current lease, granting a new one if it is due
+func (l *leaser) lease(ctx context.Context, c Client) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.id != 0 && time.Now().Before(l.renewAt) {
+		return l.id, nil
+	}
+	id, err := c.Grant(ctx, l.ttl)
+	if err != nil {
+		return 0, err
+	}
+	l.id, l.renewAt = id, time.Now().Add(l.ttl/2)
+	return id, nil
+}
+
+// drop forgets lease id after etcd reported it gone
+func (l *leaser) drop(id int64) {
+	l.mu.Lock()
+	if l.id == id {
+		l.id = 0
+	}
+	l.mu.Unlock()
+}
+
+// update runs one read-modify-write transaction loop on key. fn gets the
+// stored value, nil if there is none, and returns the value to store, or
+// store false to leave key alone. It is retried whenever another writer
+// got in first
+func update(ctx context.Context, c Client, l *leaser, key string, retries int, fn func(old []byte) (v []byte, store bool, err error)) error {
+	for i := 0; i <= retries; i++ {
+		old, rev, err := c.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		v, store, err := fn(old)
+		if err != nil || !store {
+			return err
+		}
+
+		lease, err := l.lease(ctx, c)
+		if err != nil {
+			return err
+		}
+		ok, err := c.PutIf(ctx, key, v, rev, lease)
+		if errors.Is(err, ErrLeaseNotFound) {
+			l.drop(lease)
+			continue
+		}
+		if err != nil || ok {
+			return err
+		}
+	}
+	return ErrContention
+}
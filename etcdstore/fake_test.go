@@ -0,0 +1,81 @@
+package etcdstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// fakeEtcd is an in-memory Client with etcd's revision and lease rules.
+// conflicts makes that many PutIf calls fail as if another replica had
+// committed first
+type fakeEtcd struct {
+	mu        sync.Mutex
+	rev       int64
+	kvs       map[string]fakeKV
+	leases    map[int64]time.Duration
+	nextLease int64
+	conflicts int
+}
+
+type fakeKV struct {
+	value []byte
+	rev   int64
+	lease int64
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{kvs: make(map[string]fakeKV), leases: make(map[int64]time.Duration)}
+}
+
+func (e *fakeEtcd) Get(ctx context.Context, key string) ([]byte, int64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	kv, ok := e.kvs[key]
+	if !ok {
+		return nil, 0, nil
+	}
+	return kv.value, kv.rev, nil
+}
+
+func (e *fakeEtcd) PutIf(ctx context.Context, key string, value []byte, rev, lease int64) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.leases[lease]; !ok {
+		return false, ErrLeaseNotFound
+	}
+	if e.conflicts > 0 {
+		e.conflicts--
+		e.rev++
+		kv := e.kvs[key]
+		kv.rev = e.rev
+		e.kvs[key] = kv
+		return false, nil
+	}
+	if e.kvs[key].rev != rev {
+		return false, nil
+	}
+	e.rev++
+	e.kvs[key] = fakeKV{value: value, rev: e.rev, lease: lease}
+	return true, nil
+}
+
+func (e *fakeEtcd) Grant(ctx context.Context, ttl time.Duration) (int64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.nextLease++
+	e.leases[e.nextLease] = ttl
+	return e.nextLease, nil
+}
+
+// revoke expires lease and deletes the keys attached to it
+func (e *fakeEtcd) revoke(lease int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.leases, lease)
+	for k, kv := range e.kvs {
+		if kv.lease == lease {
+			delete(e.kvs, k)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package etcdstore
+
+import "time"
+
+// Option configures an etcd limiter
+type Option func(*config)
+
+type config struct {
+	prefix  string
+	idleTTL time.Duration
+	retries int
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{prefix: "/rateflow/", idleTTL: time.Hour, retries: 10}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithPrefix sets the prefix of every etcd key the limiter uses,
+// "/rateflow/" by default
+func WithPrefix(prefix string) Option {
+	return func(c *config) { c.prefix = prefix }
+}
+
+// WithIdleTTL sets the TTL of the lease keys are attached to, an hour by
+// default. It is raised to at least the time a bucket takes to refill, so
+// an expired key never hands out capacity early
+func WithIdleTTL(ttl time.Duration) Option {
+	return func(c *config) { c.idleTTL = ttl }
+}
+
+// WithMaxRetries sets how many times a decision is retried after losing
+// its transaction to another replica before it fails with ErrContention.
+// It defaults to 10
+func WithMaxRetries(n int) Option {
+	return func(c *config) { c.retries = n }
+}
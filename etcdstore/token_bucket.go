@@ -0,0 +1,207 @@
+package etcdstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// bucket is the state of one key: its tokens and the time of the last
+// refill in Unix microseconds. It is stored as "tokens ts"
+type bucket struct {
+	tokens float64
+	ts     int64
+}
+
+func parseBucket(v []byte) (bucket, error) {
+	tokens, ts, ok := strings.Cut(string(v), " ")
+	if !ok {
+		return bucket{}, fmt.Errorf("etcdstore: malformed bucket %q", v)
+	}
+	var b bucket
+	var err error
+	if b.tokens, err = strconv.ParseFloat(tokens, 64); err != nil {
+		return bucket{}, err
+	}
+	if b.ts, err = strconv.ParseInt(ts, 10, 64); err != nil {
+		return bucket{}, err
+	}
+	return b, nil
+}
+
+func (b bucket) encode() []byte {
+	return []byte(strconv.FormatFloat(b.tokens, 'g', -1, 64) + " " + strconv.FormatInt(b.ts, 10))
+}
+
+// TokenBucket is a token bucket per key kept in etcd. Reservations may
+// run into debt like the local TokenBucket, so ReserveN can schedule
+// events in the future. Times come from the callers, so replicas should
+// keep their clocks in sync
+type TokenBucket struct {
+	client Client
+	cfg    config
+	leases *leaser
+	limit  rateflow.Limit
+	burst  int
+}
+
+var _ rateflow.RemoteLimiter = (*TokenBucket)(nil)
+
+// NewTokenBucket creates a token bucket refilling at r tokens per second
+// up to b, for every key
+func NewTokenBucket(c Client, r rateflow.Limit, b int, opts ...Option) *TokenBucket {
+	cfg := newConfig(opts)
+	ttl := cfg.idleTTL
+	if r > 0 {
+		if refill := time.Duration(float64(b) / float64(r) * float64(time.Second)); refill > ttl {
+			ttl = refill
+		}
+	}
+	ttl = (ttl + time.Second - 1).Truncate(time.Second)
+	return &TokenBucket{client: c, cfg: cfg, leases: &leaser{ttl: ttl}, limit: r, burst: b}
+}
+
+// Limit returns the refill rate
+func (tb *TokenBucket) Limit() rateflow.Limit {
+	return tb.limit
+}
+
+// Burst returns the bucket size
+func (tb *TokenBucket) Burst() int {
+	return tb.burst
+}
+
+// load returns key's bucket refilled up to now; a missing one is full
+func (tb *TokenBucket) load(v []byte, now int64) (bucket, error) {
+	if v == nil {
+		return bucket{tokens: float64(tb.burst), ts: now}, nil
+	}
+	b, err := parseBucket(v)
+	if err != nil {
+		return bucket{}, err
+	}
+	if now > b.ts {
+		b.tokens = math.Min(float64(tb.burst), b.tokens+float64(now-b.ts)*float64(tb.limit)/1e6)
+		b.ts = now
+	}
+	return b, nil
+}
+
+// take consumes n tokens for key at t if they are available within
+// maxWait; a negative maxWait accepts any wait. A refused take returns
+// the wait that was refused, or -1 if n can never be admitted
+func (tb *TokenBucket) take(ctx context.Context, key string, t time.Time, n int, maxWait time.Duration) (ok bool, wait time.Duration, err error) {
+	if n > tb.burst {
+		return false, -1, nil
+	}
+	now := t.UnixMicro()
+	err = update(ctx, tb.client, tb.leases, tb.cfg.prefix+key, tb.cfg.retries, func(v []byte) ([]byte, bool, error) {
+		b, err := tb.load(v, now)
+		if err != nil {
+			return nil, false, err
+		}
+		b.tokens -= float64(n)
+
+		ok, wait = true, 0
+		if b.tokens < 0 {
+			if tb.limit <= 0 {
+				ok, wait = false, -1
+				return nil, false, nil
+			}
+			wait = time.Duration(math.Ceil(-b.tokens*1e6/float64(tb.limit))) * time.Microsecond
+		}
+		if maxWait >= 0 && wait > maxWait {
+			ok = false
+			return nil, false, nil
+		}
+		return b.encode(), true, nil
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	return ok, wait, nil
+}
+
+// refund gives n tokens back to key's bucket, up to its burst
+func (tb *TokenBucket) refund(ctx context.Context, key string, t time.Time, n int) error {
+	now := t.UnixMicro()
+	return update(ctx, tb.client, tb.leases, tb.cfg.prefix+key, tb.cfg.retries, func(v []byte) ([]byte, bool, error) {
+		if v == nil {
+			return nil, false, nil
+		}
+		b, err := tb.load(v, now)
+		if err != nil {
+			return nil, false, err
+		}
+		b.tokens = math.Min(float64(tb.burst), b.tokens+float64(n))
+		return b.encode(), true, nil
+	})
+}
+
+// AllowN reports whether n events for key may happen at t
+func (tb *TokenBucket) AllowN(ctx context.Context, key string, t time.Time, n int) (bool, error) {
+	ok, _, err := tb.take(ctx, key, t, n, 0)
+	return ok, err
+}
+
+// ReserveN reserves n events for key at t, possibly in the future. It is
+// not OK only if n exceeds the burst or the rate is zero
+func (tb *TokenBucket) ReserveN(ctx context.Context, key string, t time.Time, n int) (*rateflow.RemoteReservation, error) {
+	return tb.reserve(ctx, key, t, n, -1)
+}
+
+func (tb *TokenBucket) reserve(ctx context.Context, key string, t time.Time, n int, maxWait time.Duration) (*rateflow.RemoteReservation, error) {
+	ok, wait, err := tb.take(ctx, key, t, n, maxWait)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		var retry time.Time
+		if wait >= 0 {
+			retry = t.Add(wait)
+		}
+		return rateflow.NewRemoteReservation(false, retry, n, nil), nil
+	}
+
+	timeToAct := t.Add(wait)
+	cancel := func(ctx context.Context, now time.Time) error {
+		if !now.Before(timeToAct) {
+			return nil
+		}
+		return tb.refund(ctx, key, now, n)
+	}
+	return rateflow.NewRemoteReservation(true, timeToAct, n, cancel), nil
+}
+
+// WaitN blocks until n events for key are allowed or ctx is done. It
+// fails fast, consuming nothing, when the wait would outlast ctx's
+// deadline
+func (tb *TokenBucket) WaitN(ctx context.Context, key string, n int) error {
+	maxWait := time.Duration(-1)
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = time.Until(deadline)
+		if maxWait < 0 {
+			maxWait = 0
+		}
+	}
+	now := time.Now()
+	r, err := tb.reserve(ctx, key, now, n, maxWait)
+	if err != nil {
+		return err
+	}
+	if !r.OK() {
+		if r.RetryAt().IsZero() {
+			if n > tb.burst {
+				return fmt.Errorf("%w: %d > %d", rateflow.ErrExceedsBurst, n, tb.burst)
+			}
+			return rateflow.ErrReservationNotOK
+		}
+		return &rateflow.RateLimitError{RetryAfter: r.RetryAt().Sub(now)}
+	}
+	return r.Act(ctx)
+}
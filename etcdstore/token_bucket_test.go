@@ -0,0 +1,111 @@
+package etcdstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestTokenBucket(t *testing.T) {
+	etcd := newFakeEtcd()
+	tb := NewTokenBucket(etcd, 10, 2)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, err := tb.AllowN(ctx, "alice", now, 1); err != nil || !ok {
+			t.Fatalf("AllowN #%d = %v, %v, want true", i, ok, err)
+		}
+	}
+	if ok, _ := tb.AllowN(ctx, "alice", now, 1); ok {
+		t.Error("expected the bucket to be empty")
+	}
+	if ok, _ := tb.AllowN(ctx, "bob", now, 1); !ok {
+		t.Error("expected keys to be limited separately")
+	}
+	if ok, _ := tb.AllowN(ctx, "alice", now.Add(100*time.Millisecond), 1); !ok {
+		t.Error("expected a token to refill after 100ms")
+	}
+
+	kv, ok := etcd.kvs["/rateflow/alice"]
+	if !ok {
+		t.Fatal("expected state under the default prefix")
+	}
+	if etcd.leases[kv.lease] != time.Hour {
+		t.Errorf("lease ttl = %v, want the idle TTL", etcd.leases[kv.lease])
+	}
+	if len(etcd.leases) != 1 {
+		t.Errorf("granted %d leases, want one shared by every key", len(etcd.leases))
+	}
+}
+
+func TestTokenBucketLease(t *testing.T) {
+	etcd := newFakeEtcd()
+	tb := NewTokenBucket(etcd, 1, 7200, WithIdleTTL(time.Minute))
+	ctx := context.Background()
+	now := time.Now()
+
+	if ok, err := tb.AllowN(ctx, "k", now, 1); err != nil || !ok {
+		t.Fatalf("AllowN = %v, %v, want true", ok, err)
+	}
+	lease := etcd.kvs["/rateflow/k"].lease
+	if ttl := etcd.leases[lease]; ttl != 2*time.Hour {
+		t.Errorf("lease ttl = %v, want the 2h refill time", ttl)
+	}
+
+	etcd.revoke(lease)
+	if ok, err := tb.AllowN(ctx, "k", now, 1); err != nil || !ok {
+		t.Fatalf("AllowN after the lease expired = %v, %v, want true", ok, err)
+	}
+	if got := etcd.kvs["/rateflow/k"].lease; got == lease || got == 0 {
+		t.Errorf("key attached to lease %d, want a newly granted one", got)
+	}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	tb := NewTokenBucket(newFakeEtcd(), 10, 1)
+	ctx := context.Background()
+	now := time.Now()
+
+	tb.AllowN(ctx, "k", now, 1)
+	r, err := tb.ReserveN(ctx, "k", now, 1)
+	if err != nil || !r.OK() {
+		t.Fatalf("ReserveN = %v, %v, want OK", r, err)
+	}
+	if d := r.DelayFrom(now); d != 100*time.Millisecond {
+		t.Errorf("delay = %v, want 100ms", d)
+	}
+	if err := r.CancelAt(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	r, _ = tb.ReserveN(ctx, "k", now, 1)
+	if d := r.DelayFrom(now); d != 100*time.Millisecond {
+		t.Errorf("delay after cancel = %v, want the refund to apply", d)
+	}
+
+	if err := tb.WaitN(ctx, "k", 2); !errors.Is(err, rateflow.ErrExceedsBurst) {
+		t.Errorf("WaitN = %v, want ErrExceedsBurst", err)
+	}
+}
+
+func TestTokenBucketContention(t *testing.T) {
+	etcd := newFakeEtcd()
+	tb := NewTokenBucket(etcd, 1, 5, WithMaxRetries(2), WithPrefix("x/"))
+	ctx := context.Background()
+	now := time.Now()
+
+	etcd.conflicts = 2
+	if ok, err := tb.AllowN(ctx, "k", now, 1); err != nil || !ok {
+		t.Errorf("AllowN = %v, %v, want success after retrying", ok, err)
+	}
+	etcd.conflicts = 3
+	if _, err := tb.AllowN(ctx, "k", now, 1); !errors.Is(err, ErrContention) {
+		t.Errorf("AllowN = %v, want ErrContention", err)
+	}
+	if _, ok := etcd.kvs["x/k"]; !ok {
+		t.Error("expected state under the configured prefix")
+	}
+}
@@ -0,0 +1,58 @@
+package rateflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEWMAToleratesCompliantBursts(t *testing.T) {
+	// 10/s on average, smoothed over 40 events (a 4s time constant)
+	lim := NewLimiter(EWMA, Limit(10), 40)
+	now := time.Now()
+
+	// Bursts of 8 every second stay below the limit on average
+	for sec := 0; sec < 30; sec++ {
+		at := now.Add(time.Duration(sec) * time.Second)
+		for i := 0; i < 8; i++ {
+			if !lim.AllowN(at, 1) {
+				t.Fatalf("second %d: request %d denied for compliant traffic", sec, i)
+			}
+		}
+	}
+}
+
+func TestEWMADeniesSustainedOverload(t *testing.T) {
+	lim := NewLimiter(EWMA, Limit(10), 20)
+	now := time.Now()
+
+	denied := 0
+	for sec := 0; sec < 10; sec++ {
+		at := now.Add(time.Duration(sec) * time.Second)
+		for i := 0; i < 20; i++ {
+			if !lim.AllowN(at, 1) {
+				denied++
+			}
+		}
+	}
+	if denied == 0 {
+		t.Error("expected sustained 2x overload to be denied")
+	}
+}
+
+func TestEWMAReserve(t *testing.T) {
+	lim := NewLimiter(EWMA, Limit(10), 10)
+	now := time.Now()
+
+	lim.AllowN(now, 10)
+	r := lim.ReserveN(now, 5)
+	if !r.OK() {
+		t.Fatal("expected reservation to be OK")
+	}
+	// The counter must decay from 10 to 5 with tau = 1s: ln(2) seconds
+	if d := r.DelayFrom(now); d < 690*time.Millisecond || d > 700*time.Millisecond {
+		t.Errorf("expected ~693ms delay, got %v", d)
+	}
+	if lim.ReserveN(now, 11).OK() {
+		t.Error("expected reservation above burst to fail")
+	}
+}
@@ -124,3 +124,30 @@ func ExampleCapabilities() {
 	// TokenBucket - Tokens: true, Burst: true, Reservation: true
 	// SlidingWindow - Tokens: false, Burst: false, Reservation: false
 }
+
+func ExampleNewWindowLimiter() {
+	// 100 requests per 10 seconds, counted over a sliding window
+	limiter := NewWindowLimiter(SlidingWindow, 100, 10*time.Second)
+
+	fmt.Println(limiter.Burst(), limiter.Limit())
+	// Output: 100 10
+}
+
+func ExampleNewKeyedProvider() {
+	// Logins get 3 attempts per minute in a sliding window, the API a
+	// token bucket of 10/s
+	rules := PrefixRules{
+		"login:*": {Algorithm: SlidingWindow, Limit: 3, Burst: 3, Options: []Option{WithWindow(time.Minute)}},
+		"api:*":   {Algorithm: TokenBucket, Limit: 10, Burst: 10},
+	}
+	keyed := NewKeyedProvider[string](rules, LimitSpec{Algorithm: TokenBucket, Limit: 1, Burst: 1})
+
+	for _, key := range []string{"login:alice", "api:alice"} {
+		lim := keyed.Get(key)
+		fmt.Println(key, lim.Algorithm(), lim.Burst())
+	}
+
+	// Output:
+	// login:alice SlidingWindow 3
+	// api:alice TokenBucket 10
+}
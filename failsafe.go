@@ -0,0 +1,191 @@
+package rateflow
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FailurePolicy decides what a FailSafe answers when its store fails
+type FailurePolicy int
+
+const (
+	// FailClosed refuses events while the store fails, protecting the
+	// service behind the limit at the cost of its availability
+	FailClosed FailurePolicy = iota
+	// FailOpen admits every event while the store fails, so an outage
+	// of the store lifts the limit instead of taking the service down
+	FailOpen
+	// FailLocal decides on a local keyed limiter while the store fails,
+	// so each replica still enforces a limit on its own
+	FailLocal
+)
+
+// String returns the name of p
+func (p FailurePolicy) String() string {
+	switch p {
+	case FailClosed:
+		return "FailClosed"
+	case FailOpen:
+		return "FailOpen"
+	case FailLocal:
+		return "FailLocal"
+	}
+	return "Unknown"
+}
+
+// failedRetry is when a request refused because the store failed is
+// worth retrying, giving the store time to come back
+const failedRetry = time.Second
+
+// FailureStats counts the decisions of a FailSafe
+type FailureStats struct {
+	// Calls is the number of decisions asked of the store
+	Calls uint64
+	// Errors is the number of calls the store failed
+	Errors uint64
+	// Admitted and Refused count the failed calls the policy decided
+	Admitted uint64
+	Refused  uint64
+	// Denials breaks down every refusal: Limited counts those of the
+	// store, Store those the policy made after a failure
+	Denials DenialStats
+	// LastError is the last error of the store, nil if it never failed
+	LastError error
+}
+
+// FailSafe is a RemoteLimiter that never returns store errors: when the
+// store it wraps fails, the decision is made by its FailurePolicy, and
+// the failure counted in Stats
+type FailSafe struct {
+	remote RemoteLimiter
+	policy FailurePolicy
+	local  *Keyed[string]
+
+	calls    atomic.Uint64
+	errors   atomic.Uint64
+	admitted atomic.Uint64
+	refused  atomic.Uint64
+	limited  atomic.Uint64
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+var _ RemoteLimiter = (*FailSafe)(nil)
+
+// NewFailSafe wraps remote with policy. local decides keys under
+// FailLocal, e.g. a Keyed sized to this replica's share of the limit,
+// and is ignored by the other policies. It panics if FailLocal is given
+// no local limiter
+func NewFailSafe(remote RemoteLimiter, policy FailurePolicy, local *Keyed[string]) *FailSafe {
+	if policy == FailLocal && local == nil {
+		panic("rateflow: FailLocal needs a local limiter")
+	}
+	return &FailSafe{remote: remote, policy: policy, local: local}
+}
+
+// Policy returns the failure policy of f
+func (f *FailSafe) Policy() FailurePolicy {
+	return f.policy
+}
+
+// Stats returns the counts of calls and failures so far
+func (f *FailSafe) Stats() FailureStats {
+	f.mu.Lock()
+	lastErr := f.lastErr
+	f.mu.Unlock()
+	return FailureStats{
+		Calls:    f.calls.Load(),
+		Errors:   f.errors.Load(),
+		Admitted: f.admitted.Load(),
+		Refused:  f.refused.Load(),
+		Denials: DenialStats{
+			Limited: f.limited.Load(),
+			Store:   f.refused.Load(),
+		},
+		LastError: lastErr,
+	}
+}
+
+// failed records err and counts the policy's decision ok
+func (f *FailSafe) failed(err error, ok bool) {
+	f.errors.Add(1)
+	if ok {
+		f.admitted.Add(1)
+	} else {
+		f.refused.Add(1)
+	}
+	f.mu.Lock()
+	f.lastErr = err
+	f.mu.Unlock()
+}
+
+// AllowN asks the store whether n events for key may happen at t, or the
+// policy if the store fails. The error is always nil
+func (f *FailSafe) AllowN(ctx context.Context, key string, t time.Time, n int) (bool, error) {
+	f.calls.Add(1)
+	ok, err := f.remote.AllowN(ctx, key, t, n)
+	if err == nil {
+		if !ok {
+			f.limited.Add(1)
+		}
+		return ok, nil
+	}
+
+	switch f.policy {
+	case FailOpen:
+		ok = true
+	case FailLocal:
+		ok = f.local.AllowNKey(key, t, n)
+	default:
+		ok = false
+	}
+	f.failed(err, ok)
+	return ok, nil
+}
+
+// ReserveN reserves n events for key at t on the store, or as the policy
+// says if the store fails: FailOpen reserves them at t, FailClosed
+// refuses them with a retry a second later, and FailLocal reserves them
+// on the local limiter, where they can be cancelled. The error is always
+// nil
+func (f *FailSafe) ReserveN(ctx context.Context, key string, t time.Time, n int) (*RemoteReservation, error) {
+	f.calls.Add(1)
+	r, err := f.remote.ReserveN(ctx, key, t, n)
+	if err == nil {
+		if !r.OK() {
+			f.limited.Add(1)
+		}
+		return r, nil
+	}
+
+	switch f.policy {
+	case FailOpen:
+		r = NewRemoteReservation(true, t, n, nil)
+	case FailLocal:
+		lr := f.local.Get(key).ReserveN(t, n)
+		cancel := func(ctx context.Context, now time.Time) error {
+			lr.CancelAt(now)
+			return nil
+		}
+		r = NewRemoteReservation(lr.OK(), lr.TimeToAct(), n, cancel)
+	default:
+		r = NewRemoteReservation(false, t.Add(failedRetry), n, nil)
+	}
+	f.failed(err, r.OK())
+	return r, nil
+}
+
+// Wait is shorthand for WaitN(ctx, key, 1)
+func (f *FailSafe) Wait(ctx context.Context, key string) error {
+	return f.WaitN(ctx, key, 1)
+}
+
+// WaitN blocks until n events for key are allowed or ctx is done. Under
+// FailClosed a failing store is retried every second until it recovers
+// or ctx is done
+func (f *FailSafe) WaitN(ctx context.Context, key string, n int) error {
+	return WaitRemote(ctx, f, key, n)
+}
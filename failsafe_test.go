@@ -0,0 +1,82 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFailSafe(t *testing.T) {
+	remote := &limiterRemote{lim: NewLimiter(TokenBucket, Every(time.Hour), 2), err: errors.New("store down")}
+	ctx := context.Background()
+	now := time.Now()
+
+	closed := NewFailSafe(remote, FailClosed, nil)
+	if ok, err := closed.AllowN(ctx, "k", now, 1); ok || err != nil {
+		t.Errorf("FailClosed AllowN = %v, %v, want refused without an error", ok, err)
+	}
+	r, _ := closed.ReserveN(ctx, "k", now, 1)
+	if r.OK() || r.RetryAt() != now.Add(failedRetry) {
+		t.Errorf("FailClosed RetryAt = %v, want a retry once the store may be back", r.RetryAt())
+	}
+
+	open := NewFailSafe(remote, FailOpen, nil)
+	if ok, _ := open.AllowN(ctx, "k", now, 100); !ok {
+		t.Error("FailOpen should admit anything")
+	}
+
+	local := NewKeyedLimiter[string](TokenBucket, Every(time.Hour), 1)
+	degraded := NewFailSafe(remote, FailLocal, local)
+	if ok, _ := degraded.AllowN(ctx, "k", time.Now(), 1); !ok {
+		t.Error("FailLocal should admit within the local limit")
+	}
+	if ok, _ := degraded.AllowN(ctx, "k", time.Now(), 1); ok {
+		t.Error("FailLocal should refuse past the local limit")
+	}
+	local.Get("j")
+	at := time.Now()
+	r, _ = degraded.ReserveN(ctx, "j", at, 1)
+	if !r.OK() {
+		t.Fatal("FailLocal should reserve on the local limiter")
+	}
+	r.CancelAt(ctx, at)
+	if ok, _ := degraded.AllowN(ctx, "j", time.Now(), 1); !ok {
+		t.Error("cancelling should give the local tokens back")
+	}
+
+	st := degraded.Stats()
+	if st.Calls != 4 || st.Errors != 4 || st.Admitted != 3 || st.Refused != 1 || st.LastError != remote.err {
+		t.Errorf("Stats = %+v", st)
+	}
+	if st.Denials != (DenialStats{Store: 1}) {
+		t.Errorf("Denials = %+v, want the refusal counted as a store error", st.Denials)
+	}
+
+	remote.err = nil
+	if ok, _ := degraded.AllowN(ctx, "k", time.Now(), 1); !ok {
+		t.Error("a healthy store should decide again")
+	}
+	if st := degraded.Stats(); st.Calls != 5 || st.Errors != 4 {
+		t.Errorf("Stats = %+v, want the call counted without an error", st)
+	}
+}
+
+func TestFailSafeWait(t *testing.T) {
+	remote := &limiterRemote{lim: NewLimiter(TokenBucket, Every(time.Hour), 1), err: errors.New("store down")}
+	f := NewFailSafe(remote, FailClosed, nil)
+
+	short, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var rle *RateLimitError
+	if err := f.Wait(short, "k"); !errors.As(err, &rle) {
+		t.Errorf("Wait = %v, want a RateLimitError while the store is down", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected FailLocal without a local limiter to panic")
+		}
+	}()
+	NewFailSafe(remote, FailLocal, nil)
+}
@@ -0,0 +1,194 @@
+package rateflow
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// FairQueue schedules WaitN calls from several keys (tenants, clients)
+// against one shared Limiter using weighted fair queueing. Pending waits
+// are admitted in order of their virtual finish time, so each key gets
+// capacity in proportion to its weight and a noisy key cannot starve the
+// others no matter how many waiters it queues
+type FairQueue[K comparable] struct {
+	lim Limiter
+
+	mu      sync.Mutex
+	weights map[K]float64
+	finish  map[K]float64
+	pending map[K]int
+	vtime   float64
+	queue   fairHeap[K]
+	seq     uint64
+	running bool
+}
+
+type fairWaiter[K comparable] struct {
+	ctx   context.Context
+	key   K
+	n     int
+	start float64
+	tag   float64
+	seq   uint64
+	index int
+	done  chan error
+}
+
+// NewFairQueue creates a fair queue in front of lim
+func NewFairQueue[K comparable](lim Limiter) *FairQueue[K] {
+	return &FairQueue[K]{
+		lim:     lim,
+		weights: make(map[K]float64),
+		finish:  make(map[K]float64),
+		pending: make(map[K]int),
+	}
+}
+
+// SetWeight sets the share of capacity given to key relative to other
+// keys. Keys default to a weight of 1; non-positive weights reset it
+func (q *FairQueue[K]) SetWeight(key K, weight float64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if weight <= 0 {
+		delete(q.weights, key)
+		return
+	}
+	q.weights[key] = weight
+}
+
+// Weight returns the weight of key
+func (q *FairQueue[K]) Weight(key K) float64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.weight(key)
+}
+
+func (q *FairQueue[K]) weight(key K) float64 {
+	if w, ok := q.weights[key]; ok {
+		return w
+	}
+	return 1
+}
+
+// Pending returns the number of queued waiters
+func (q *FairQueue[K]) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.queue.Len()
+}
+
+// Wait is shorthand for WaitN(ctx, key, 1)
+func (q *FairQueue[K]) Wait(ctx context.Context, key K) error {
+	return q.WaitN(ctx, key, 1)
+}
+
+// WaitN blocks until it is key's turn and n events are allowed by the
+// underlying limiter, or ctx is done
+func (q *FairQueue[K]) WaitN(ctx context.Context, key K, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	start := q.vtime
+	if f, ok := q.finish[key]; ok && f > start {
+		start = f
+	}
+	w := &fairWaiter[K]{
+		ctx:   ctx,
+		key:   key,
+		n:     n,
+		start: start,
+		tag:   start + float64(n)/q.weight(key),
+		seq:   q.seq,
+		done:  make(chan error, 1),
+	}
+	q.seq++
+	q.finish[key] = w.tag
+	q.pending[key]++
+	heap.Push(&q.queue, w)
+
+	if !q.running {
+		q.running = true
+		go q.dispatch()
+	}
+	q.mu.Unlock()
+
+	select {
+	case err := <-w.done:
+		return err
+	case <-ctx.Done():
+		q.mu.Lock()
+		if w.index >= 0 {
+			heap.Remove(&q.queue, w.index)
+			q.release(key)
+			q.mu.Unlock()
+			return ctx.Err()
+		}
+		q.mu.Unlock()
+		// Already handed to the limiter, which honors ctx itself
+		return <-w.done
+	}
+}
+
+// release forgets key's virtual time once it has nothing queued
+func (q *FairQueue[K]) release(key K) {
+	q.pending[key]--
+	if q.pending[key] <= 0 {
+		delete(q.pending, key)
+		delete(q.finish, key)
+	}
+}
+
+// dispatch admits waiters in finish-tag order until the queue is empty
+func (q *FairQueue[K]) dispatch() {
+	for {
+		q.mu.Lock()
+		if q.queue.Len() == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		w := heap.Pop(&q.queue).(*fairWaiter[K])
+		q.vtime = w.start
+		q.release(w.key)
+		q.mu.Unlock()
+
+		w.done <- q.lim.WaitN(w.ctx, w.n)
+	}
+}
+
+// fairHeap orders waiters by finish tag, then arrival
+type fairHeap[K comparable] []*fairWaiter[K]
+
+func (h fairHeap[K]) Len() int { return len(h) }
+
+func (h fairHeap[K]) Less(i, j int) bool {
+	if h[i].tag != h[j].tag {
+		return h[i].tag < h[j].tag
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h fairHeap[K]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *fairHeap[K]) Push(x any) {
+	w := x.(*fairWaiter[K])
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *fairHeap[K]) Pop() any {
+	old := *h
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*h = old[:n-1]
+	return w
+}
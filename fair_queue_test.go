@@ -0,0 +1,83 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFairQueueNoisyTenant(t *testing.T) {
+	q := NewFairQueue[string](NewLimiter(TokenBucket, Limit(200), 1))
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	run := func(key string) {
+		defer wg.Done()
+		if err := q.Wait(ctx, key); err != nil {
+			t.Errorf("%s: unexpected error: %v", key, err)
+			return
+		}
+		mu.Lock()
+		order = append(order, key)
+		mu.Unlock()
+	}
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go run("noisy")
+	}
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go run("quiet")
+	}
+	wg.Wait()
+
+	// The quiet tenant must not wait behind the noisy backlog
+	last := 0
+	for i, key := range order {
+		if key == "quiet" {
+			last = i
+		}
+	}
+	if last > 12 {
+		t.Errorf("quiet tenant finished at position %d of %d: %v", last, len(order), order)
+	}
+}
+
+func TestFairQueueWeights(t *testing.T) {
+	q := NewFairQueue[string](NewLimiter(TokenBucket, Limit(0), 0))
+	q.SetWeight("gold", 3)
+
+	if w := q.Weight("gold"); w != 3 {
+		t.Errorf("expected weight 3, got %f", w)
+	}
+	if w := q.Weight("bronze"); w != 1 {
+		t.Errorf("expected default weight 1, got %f", w)
+	}
+}
+
+func TestFairQueueCancel(t *testing.T) {
+	q := NewFairQueue[int](NewLimiter(TokenBucket, Limit(1), 1))
+	q.lim.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func(key int) { errs <- q.Wait(ctx, key) }(i)
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-errs; !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	}
+	if n := q.Pending(); n != 0 {
+		t.Errorf("expected cancelled waiters to leave the queue, %d pending", n)
+	}
+}
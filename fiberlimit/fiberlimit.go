@@ -0,0 +1,117 @@
+// Package fiberlimit is the httplimit middleware for Fiber, whose
+// fasthttp requests are not net/http ones. The package does not depend
+// on Fiber: the middleware works on Ctx, a subset of *fiber.Ctx, and is
+// mounted with a one line adapter:
+//
+//	mw := fiberlimit.New(fiberlimit.Config{
+//		Lookup: fiberlimit.PerKey(users, fiberlimit.ByIP),
+//		Policy: []httplimit.QuotaPolicy{{Limit: 100, Window: time.Minute}},
+//	})
+//	app.Use(func(c *fiber.Ctx) error { return mw(c) })
+//
+// Responses carry the same RateLimit header fields as httplimit's, and
+// httplimit.Routes declares per-route limits with RoutesLookup.
+package fiberlimit
+
+import (
+	"net/http"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"github.com/mehmet-f-dogan/rateflow/httplimit"
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// Ctx is the part of a Fiber v2 *fiber.Ctx the middleware uses
+type Ctx interface {
+	IP() string
+	Method(override ...string) string
+	Path(override ...string) string
+	Get(key string, defaultValue ...string) string
+	Set(key, val string)
+	Next() error
+	SendStatus(status int) error
+}
+
+// Lookup returns the limiter a request is admitted on, or nil to let it
+// through unlimited
+type Lookup func(c Ctx) rateflow.Limiter
+
+// KeyFunc returns the key a request is limited by
+type KeyFunc func(c Ctx) string
+
+// ByIP keys requests by client IP as Fiber reports it, which honors its
+// ProxyHeader setting
+func ByIP(c Ctx) string {
+	return c.IP()
+}
+
+// ByHeader keys requests by the value of a header, e.g. an API key
+func ByHeader(name string) KeyFunc {
+	return func(c Ctx) string { return c.Get(name) }
+}
+
+// Global admits every request on lim
+func Global(lim rateflow.Limiter) Lookup {
+	return func(Ctx) rateflow.Limiter { return lim }
+}
+
+// PerKey admits each request on the limiter of its key in k, deciding
+// through k as httplimit.KeyLimiter does
+func PerKey(k *rateflow.Keyed[string], key KeyFunc) Lookup {
+	return func(c Ctx) rateflow.Limiter { return httplimit.KeyLimiter(k, key(c)) }
+}
+
+// RoutesLookup admits each request on its route's limiter in rs for
+// key(c), matching routes by method and path. A nil key gives every
+// route a single limiter
+func RoutesLookup(rs *httplimit.Routes, key KeyFunc) Lookup {
+	return func(c Ctx) rateflow.Limiter {
+		k := ""
+		if key != nil {
+			k = key(c)
+		}
+		return rs.Limiter(c.Method(), c.Path(), k)
+	}
+}
+
+// Config configures the middleware
+type Config struct {
+	// Lookup picks the limiter of each request
+	Lookup Lookup
+	// Policy is sent as a RateLimit-Policy field if not empty
+	Policy []httplimit.QuotaPolicy
+	// Deny writes the response to a refused request, after the RateLimit
+	// fields and Retry-After are set. By default it sends a bare 429
+	Deny func(c Ctx, res rateflow.Result) error
+}
+
+// New returns a middleware admitting each request on the limiter
+// cfg.Lookup returns for it
+func New(cfg Config) func(c Ctx) error {
+	policy := ""
+	if len(cfg.Policy) > 0 {
+		policy = httplimit.Policy(cfg.Policy...)
+	}
+	deny := cfg.Deny
+	if deny == nil {
+		deny = func(c Ctx, res rateflow.Result) error {
+			return c.SendStatus(http.StatusTooManyRequests)
+		}
+	}
+	return func(c Ctx) error {
+		lim := cfg.Lookup(c)
+		if lim == nil {
+			return c.Next()
+		}
+		now := limiter.NowOf(lim)
+		ok, res := lim.AllowDetailsAt(now, 1)
+		httplimit.SetHeaders(c, res, now)
+		if policy != "" {
+			c.Set(httplimit.HeaderPolicy, policy)
+		}
+		if !ok {
+			return deny(c, res)
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,90 @@
+package fiberlimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"github.com/mehmet-f-dogan/rateflow/httplimit"
+)
+
+// fakeCtx records what the middleware does to a request
+type fakeCtx struct {
+	ip, method, path string
+	headers          map[string]string
+	status           int
+	next             bool
+}
+
+func newCtx(method, path, ip string) *fakeCtx {
+	return &fakeCtx{ip: ip, method: method, path: path, headers: make(map[string]string), status: 200}
+}
+
+func (c *fakeCtx) IP() string                         { return c.ip }
+func (c *fakeCtx) Method(...string) string            { return c.method }
+func (c *fakeCtx) Path(...string) string              { return c.path }
+func (c *fakeCtx) Get(key string, _ ...string) string { return c.headers[key] }
+func (c *fakeCtx) Set(key, val string)                { c.headers[key] = val }
+func (c *fakeCtx) Next() error                        { c.next = true; return nil }
+func (c *fakeCtx) SendStatus(status int) error        { c.status = status; return nil }
+
+func TestMiddleware(t *testing.T) {
+	users := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, rateflow.Every(time.Minute), 1)
+	mw := New(Config{
+		Lookup: PerKey(users, ByIP),
+		Policy: []httplimit.QuotaPolicy{{Limit: 1, Window: time.Minute}},
+	})
+
+	c := newCtx("GET", "/", "10.0.0.1")
+	mw(c)
+	if !c.next || c.headers[httplimit.HeaderRemaining] != "0" || c.headers[httplimit.HeaderPolicy] != "1;w=60" {
+		t.Fatalf("first request: next = %v, headers = %v", c.next, c.headers)
+	}
+	c = newCtx("GET", "/", "10.0.0.1")
+	mw(c)
+	if c.next || c.status != 429 || c.headers["Retry-After"] != "60" {
+		t.Errorf("second request: next = %v, status = %d, headers = %v, want a 429", c.next, c.status, c.headers)
+	}
+}
+
+func TestMiddlewareKeyedLists(t *testing.T) {
+	users := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, 10, 10)
+	users.SetDenylist(rateflow.NewKeyList("1.2.3.4"))
+	mw := New(Config{Lookup: PerKey(users, ByIP)})
+
+	c := newCtx("GET", "/", "1.2.3.4")
+	mw(c)
+	if c.next || c.status != 429 {
+		t.Errorf("denylisted IP: next = %v, status = %d, want a 429", c.next, c.status)
+	}
+
+	users.SetParent(rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Every(time.Hour), 1))
+	for i, want := range []bool{true, false} {
+		c := newCtx("GET", "/", "10.0.0."+string(rune('1'+i)))
+		mw(c)
+		if c.next != want {
+			t.Errorf("request %d: next = %v, want %v under the parent", i, c.next, want)
+		}
+	}
+}
+
+func TestMiddlewareRoutes(t *testing.T) {
+	routes := httplimit.NewRoutes().Limit("POST /login", rateflow.TokenBucket, rateflow.Every(time.Minute), 1)
+	denied := 0
+	mw := New(Config{
+		Lookup: RoutesLookup(routes, ByIP),
+		Deny: func(c Ctx, res rateflow.Result) error {
+			denied++
+			return c.SendStatus(503)
+		},
+	})
+	for i := 0; i < 2; i++ {
+		mw(newCtx("POST", "/login", "10.0.0.1"))
+	}
+	if c := newCtx("GET", "/", "10.0.0.1"); mw(c) != nil || !c.next || len(c.headers) != 0 {
+		t.Error("expected unmatched routes to pass unlimited")
+	}
+	if denied != 1 {
+		t.Errorf("Deny called %d times, want 1", denied)
+	}
+}
@@ -0,0 +1,203 @@
+package rateflow
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fileStoreVersion is bumped whenever the file layout changes
+const fileStoreVersion = 1
+
+// Snapshotter is state that is saved and loaded as one blob. Keyed
+// implements it, and LimiterSnapshot adapts a single limiter
+type Snapshotter interface {
+	Snapshot() ([]byte, error)
+	Restore(data []byte) error
+}
+
+type limiterSnapshot struct{ lim Limiter }
+
+// LimiterSnapshot returns a Snapshotter for the state of lim, e.g. a
+// CalendarQuota limiter, to register with a FileStore
+func LimiterSnapshot(lim Limiter) Snapshotter {
+	return limiterSnapshot{lim}
+}
+
+func (s limiterSnapshot) Snapshot() ([]byte, error) {
+	m, ok := s.lim.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T cannot export state", ErrStateMismatch, s.lim)
+	}
+	return m.MarshalBinary()
+}
+
+func (s limiterSnapshot) Restore(data []byte) error {
+	u, ok := s.lim.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return fmt.Errorf("%w: %T cannot restore state", ErrStateMismatch, s.lim)
+	}
+	return u.UnmarshalBinary(data)
+}
+
+type fileSnapshot struct {
+	Version int
+	Items   map[string][]byte
+}
+
+// FileStore keeps the state of limiters in a file so that long quotas,
+// such as daily caps, survive a restart without an external store.
+// Register everything to keep, Load once on startup, then Run to save
+// periodically and once more on shutdown. Each save writes a temporary
+// file next to path and renames it over path, so a crash leaves either
+// the old snapshot or the new one, never a torn file. Events admitted
+// after the last save are forgotten by a crash, so the save interval
+// bounds how far a restarted process can overshoot
+type FileStore struct {
+	path string
+
+	mu    sync.Mutex
+	items map[string]Snapshotter
+
+	saveMu  sync.Mutex
+	saved   time.Time
+	lastErr error
+}
+
+// NewFileStore creates a store that saves to path
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path, items: make(map[string]Snapshotter)}
+}
+
+// Register saves and loads s under name, replacing anything registered
+// under it before
+func (f *FileStore) Register(name string, s Snapshotter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items[name] = s
+}
+
+// Load restores every registered item from the file. A missing file is
+// not an error, and neither are items in the file no longer registered;
+// items that fail to restore are reported together and do not stop the
+// others
+func (f *FileStore) Load() error {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snap fileSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return fmt.Errorf("rateflow: reading %s: %w", f.path, err)
+	}
+	if snap.Version != fileStoreVersion {
+		return fmt.Errorf("%w: file version %d, want %d", ErrStateMismatch, snap.Version, fileStoreVersion)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var errs []error
+	for _, name := range sortedNames(snap.Items) {
+		s, ok := f.items[name]
+		if !ok {
+			continue
+		}
+		if err := s.Restore(snap.Items[name]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func sortedNames(items map[string][]byte) []string {
+	names := make([]string, 0, len(items))
+	for name := range items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save writes the state of every registered item to the file, replacing
+// it atomically. Nothing is written if any item fails to export
+func (f *FileStore) Save() error {
+	f.saveMu.Lock()
+	defer f.saveMu.Unlock()
+	err := f.save()
+	f.lastErr = err
+	if err == nil {
+		f.saved = time.Now()
+	}
+	return err
+}
+
+func (f *FileStore) save() error {
+	snap := fileSnapshot{Version: fileStoreVersion, Items: make(map[string][]byte)}
+	f.mu.Lock()
+	items := make(map[string]Snapshotter, len(f.items))
+	for name, s := range f.items {
+		items[name] = s
+	}
+	f.mu.Unlock()
+	for name, s := range items {
+		data, err := s.Snapshot()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		snap.Items[name] = data
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	if err := gob.NewEncoder(tmp).Encode(snap); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// LastSave returns when the file was last saved, and the error of the
+// last attempt, nil if it succeeded
+func (f *FileStore) LastSave() (time.Time, error) {
+	f.saveMu.Lock()
+	defer f.saveMu.Unlock()
+	return f.saved, f.lastErr
+}
+
+// Run calls Save every interval until ctx is done, then saves once more
+// and returns ctx.Err(). Failed saves are retried at the next tick and
+// reported by LastSave
+func (f *FileStore) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.Save()
+		case <-ctx.Done():
+			f.Save()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	now := time.Now()
+
+	daily := NewCalendarQuota(3, Daily, time.UTC)
+	users := NewKeyedLimiter[string](TokenBucket, Every(time.Hour), 2)
+	daily.AllowN(now, 3)
+	users.AllowKey("alice")
+	users.AllowKey("alice")
+
+	fs := NewFileStore(path)
+	fs.Register("daily", LimiterSnapshot(daily))
+	fs.Register("users", users)
+	if err := fs.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if saved, err := fs.LastSave(); saved.IsZero() || err != nil {
+		t.Errorf("LastSave = %v, %v, want a successful save", saved, err)
+	}
+
+	// A restarted process registers the same limiters and loads them
+	daily = NewCalendarQuota(3, Daily, time.UTC)
+	users = NewKeyedLimiter[string](TokenBucket, Every(time.Hour), 2)
+	fs = NewFileStore(path)
+	fs.Register("daily", LimiterSnapshot(daily))
+	fs.Register("users", users)
+	if err := fs.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if daily.AllowN(now, 1) {
+		t.Error("expected the daily cap to stay used up across the restart")
+	}
+	if users.AllowKey("alice") || !users.AllowKey("bob") {
+		t.Error("expected alice's usage to carry over")
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("found %d files, want the temporary file renamed", len(entries))
+	}
+}
+
+func TestFileStoreLoad(t *testing.T) {
+	dir := t.TempDir()
+	fs := NewFileStore(filepath.Join(dir, "missing"))
+	if err := fs.Load(); err != nil {
+		t.Errorf("Load of a missing file = %v, want nil", err)
+	}
+
+	path := filepath.Join(dir, "state")
+	os.WriteFile(path, []byte("not a snapshot"), 0o600)
+	if err := NewFileStore(path).Load(); err == nil {
+		t.Error("expected an error for a corrupt file")
+	}
+
+	fs = NewFileStore(path)
+	fs.Register("q", LimiterSnapshot(NewLimiter(TokenBucket, 1, 1)))
+	if err := fs.Save(); err != nil {
+		t.Fatal(err)
+	}
+	fs = NewFileStore(path)
+	fs.Register("q", LimiterSnapshot(NewLimiter(SlidingWindow, 1, 1)))
+	if err := fs.Load(); !errors.Is(err, ErrStateMismatch) {
+		t.Errorf("Load = %v, want ErrStateMismatch", err)
+	}
+}
+
+func TestFileStoreRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	fs := NewFileStore(path)
+	fs.Register("q", LimiterSnapshot(NewLimiter(TokenBucket, 1, 1)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := fs.Run(ctx, time.Hour); err != context.Canceled {
+		t.Errorf("Run = %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected a final save on shutdown: %v", err)
+	}
+}
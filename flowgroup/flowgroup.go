@@ -0,0 +1,130 @@
+// Package flowgroup runs goroutines like errgroup, each started only
+// when both a rate limiter and a cap on parallelism allow it:
+//
+//	g, ctx := flowgroup.WithContext(ctx, rateflow.NewLimiter(rateflow.TokenBucket, 10, 1), 4)
+//	for _, url := range urls {
+//		url := url
+//		g.Go(func() error { return fetch(ctx, url) })
+//	}
+//	err := g.Wait()
+//
+// starts at most 10 fetches a second with no more than 4 running at once.
+package flowgroup
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// Group is a collection of goroutines started at a limited rate and
+// parallelism, working on subtasks of a common task
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	lim    rateflow.Limiter
+	sem    chan struct{}
+
+	wg      sync.WaitGroup
+	errOnce sync.Once
+	err     error
+}
+
+// New returns a Group starting goroutines when lim admits them with at
+// most parallel running. A nil lim does not limit the rate and a
+// parallel of 0 or less does not limit parallelism
+func New(lim rateflow.Limiter, parallel int) *Group {
+	g := &Group{ctx: context.Background(), lim: lim}
+	if parallel > 0 {
+		g.sem = make(chan struct{}, parallel)
+	}
+	return g
+}
+
+// WithContext is New with a derived context that is cancelled the first
+// time a function passed to Go fails or Wait returns. Go stops starting
+// goroutines once ctx is done
+func WithContext(ctx context.Context, lim rateflow.Limiter, parallel int) (*Group, context.Context) {
+	g := New(lim, parallel)
+	g.ctx, g.cancel = context.WithCancel(ctx)
+	return g, g.ctx
+}
+
+func (g *Group) fail(err error) {
+	g.errOnce.Do(func() {
+		g.err = err
+		if g.cancel != nil {
+			g.cancel()
+		}
+	})
+}
+
+// Go blocks until a slot is free and the limiter admits one event, then
+// calls fn in a new goroutine. The first error returned by a fn, or by
+// waiting if the group's context is done first, is returned by Wait; a
+// fn that could not start is not called
+func (g *Group) Go(fn func() error) {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		case <-g.ctx.Done():
+			g.fail(g.ctx.Err())
+			return
+		}
+	}
+	if g.lim != nil {
+		if err := g.lim.Wait(g.ctx); err != nil {
+			g.done()
+			g.fail(err)
+			return
+		}
+	}
+	g.start(fn)
+}
+
+// TryGo calls fn in a new goroutine only if a slot is free and the
+// limiter admits an event right away, and reports whether it did
+func (g *Group) TryGo(fn func() error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	if g.lim != nil && !g.lim.Allow() {
+		g.done()
+		return false
+	}
+	g.start(fn)
+	return true
+}
+
+func (g *Group) start(fn func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer g.done()
+		if err := fn(); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+// done frees the slot of a goroutine
+func (g *Group) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+}
+
+// Wait blocks until every goroutine started by Go returns, then returns
+// the first error
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel()
+	}
+	return g.err
+}
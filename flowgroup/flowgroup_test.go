@@ -0,0 +1,75 @@
+package flowgroup
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestGroup(t *testing.T) {
+	g := New(rateflow.NewLimiter(rateflow.TokenBucket, 1000, 1), 2)
+	var running, peak, done atomic.Int64
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		g.Go(func() error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(2 * time.Millisecond)
+			running.Add(-1)
+			done.Add(1)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if done.Load() != 10 || peak.Load() > 2 {
+		t.Errorf("ran %d with up to %d at once, want 10 with at most 2", done.Load(), peak.Load())
+	}
+	if elapsed := time.Since(start); elapsed < 9*time.Millisecond {
+		t.Errorf("took %v, want starts paced by the limiter", elapsed)
+	}
+}
+
+func TestGroupError(t *testing.T) {
+	g, ctx := WithContext(context.Background(), rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Every(time.Hour), 1), 0)
+	boom := errors.New("boom")
+	g.Go(func() error { return boom })
+	// The second start waits for the limiter until the failure cancels it
+	g.Go(func() error { t.Error("should not start"); return nil })
+	if err := g.Wait(); err != boom {
+		t.Errorf("Wait = %v, want the first error", err)
+	}
+	if ctx.Err() == nil {
+		t.Error("expected the context to be cancelled")
+	}
+}
+
+func TestGroupTryGo(t *testing.T) {
+	g := New(rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Every(time.Hour), 2), 1)
+	release := make(chan struct{})
+	if !g.TryGo(func() error { <-release; return nil }) {
+		t.Fatal("expected the first TryGo to start")
+	}
+	if g.TryGo(func() error { return nil }) {
+		t.Error("expected TryGo to fail while the only slot is busy")
+	}
+	close(release)
+	g.Wait()
+	if !g.TryGo(func() error { return nil }) {
+		t.Error("expected TryGo to start with a free slot and a token")
+	}
+	g.Wait()
+	if g.TryGo(func() error { return nil }) {
+		t.Error("expected TryGo to fail without a token")
+	}
+}
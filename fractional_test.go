@@ -0,0 +1,55 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAllowF(t *testing.T) {
+	tb := NewLimiter(TokenBucket, Limit(1), 1).(*TokenBucketLimiter)
+	now := time.Now()
+
+	for i := 0; i < 4; i++ {
+		if !tb.AllowF(now, 0.25) {
+			t.Fatalf("expected quarter-token request %d to be allowed", i)
+		}
+	}
+	if tb.AllowF(now, 0.25) {
+		t.Error("expected the bucket to be empty after four quarters")
+	}
+	if !tb.AllowF(now.Add(500*time.Millisecond), 0.5) {
+		t.Error("expected half a token after 500ms")
+	}
+}
+
+func TestReserveFAndWaitF(t *testing.T) {
+	tb := NewLimiter(TokenBucket, Limit(10), 1).(*TokenBucketLimiter)
+	now := time.Now()
+	tb.AllowN(now, 1)
+
+	r := tb.ReserveF(now, 0.5)
+	if d := r.DelayFrom(now); d < 49*time.Millisecond || d > 51*time.Millisecond {
+		t.Errorf("expected ~50ms delay for half a token, got %v", d)
+	}
+	r.CancelAt(now)
+
+	if err := tb.WaitF(context.Background(), 0.1); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := tb.WaitF(context.Background(), 1.5); !errors.Is(err, ErrExceedsBurst) {
+		t.Errorf("expected ErrExceedsBurst, got %v", err)
+	}
+}
+
+func TestPriorityAllowF(t *testing.T) {
+	pl := NewPriorityLimiter(Limit(1), 2, 1)
+	now := time.Now()
+	if !pl.AllowF(now, 0.5) || !pl.AllowF(now, 0.5) {
+		t.Fatal("expected the unreserved token to be available in halves")
+	}
+	if pl.AllowF(now, 0.5) {
+		t.Error("expected the lowest class to stop at its reserve")
+	}
+}
@@ -0,0 +1,130 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGCRA(t *testing.T) {
+	store := NewMemoryStore()
+	g := NewGCRA(store, 10, 2)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, err := g.AllowN(ctx, "alice", now, 1); err != nil || !ok {
+			t.Fatalf("AllowN #%d = %v, %v, want true", i, ok, err)
+		}
+	}
+	if ok, _ := g.AllowN(ctx, "alice", now, 1); ok {
+		t.Error("expected the burst to be used up")
+	}
+	if ok, _ := g.AllowN(ctx, "bob", now, 1); !ok {
+		t.Error("expected keys to be limited separately")
+	}
+	if ok, _ := g.AllowN(ctx, "alice", now.Add(100*time.Millisecond), 1); !ok {
+		t.Error("expected an event to be admitted after 100ms")
+	}
+	if store.Len() != 2 {
+		t.Errorf("Len = %d, want one value per key", store.Len())
+	}
+}
+
+func TestGCRAReserve(t *testing.T) {
+	g := NewGCRA(NewMemoryStore(), 10, 1)
+	ctx := context.Background()
+	now := time.Now()
+
+	g.AllowN(ctx, "k", now, 1)
+	r, err := g.ReserveN(ctx, "k", now, 1)
+	if err != nil || !r.OK() || r.DelayFrom(now) != 100*time.Millisecond {
+		t.Fatalf("ReserveN = %v, %v, want OK in 100ms", r, err)
+	}
+	if err := r.CancelAt(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	r, _ = g.ReserveN(ctx, "k", now, 1)
+	if d := r.DelayFrom(now); d != 100*time.Millisecond {
+		t.Errorf("delay after cancel = %v, want the refund to free the slot", d)
+	}
+
+	r, _ = g.ReserveN(ctx, "k", now, 2)
+	if r.OK() || !r.RetryAt().IsZero() {
+		t.Error("n above the burst should be refused for good")
+	}
+	if err := g.WaitN(ctx, "k", 2); !errors.Is(err, ErrExceedsBurst) {
+		t.Errorf("WaitN = %v, want ErrExceedsBurst", err)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	var rle *RateLimitError
+	if err := g.WaitN(short, "k", 1); !errors.As(err, &rle) {
+		t.Errorf("WaitN = %v, want a RateLimitError", err)
+	}
+}
+
+func TestGCRAConcurrent(t *testing.T) {
+	g := NewGCRA(NewMemoryStore(), Every(time.Hour), 50)
+	ctx := context.Background()
+	now := time.Now()
+
+	var mu sync.Mutex
+	admitted := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				ok, err := g.AllowN(ctx, "k", now, 1)
+				if err != nil && !errors.Is(err, ErrContention) {
+					t.Error(err)
+				}
+				if ok {
+					mu.Lock()
+					admitted++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if admitted > 50 {
+		t.Errorf("admitted %d, want at most the burst", admitted)
+	}
+}
+
+// casLoser is a Store whose writes always lose the race
+type casLoser struct {
+	*MemoryStore
+}
+
+func (casLoser) Add(ctx context.Context, key string, value int64, ttl time.Duration) (bool, error) {
+	return false, nil
+}
+
+func TestGCRAContention(t *testing.T) {
+	g := NewGCRA(casLoser{NewMemoryStore()}, 1, 1)
+	if _, err := g.AllowN(context.Background(), "k", time.Now(), 1); !errors.Is(err, ErrContention) {
+		t.Errorf("AllowN = %v, want ErrContention", err)
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	if ok, _ := s.Add(ctx, "k", 1, time.Millisecond); !ok {
+		t.Fatal("Add should create a missing key")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", 2, 3, time.Minute); ok {
+		t.Error("CompareAndSwap should fail for another value")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if _, ok, _ := s.Get(ctx, "k"); ok {
+		t.Error("expected the key to expire")
+	}
+}
@@ -0,0 +1,294 @@
+// Package gossip enforces an approximate global limit across replicas
+// without a central store. Every node limits itself locally and
+// periodically sends what it knows about every node's demand to a few
+// random peers, memberlist-style, so the state of the cluster spreads in
+// a few rounds. Each node then takes a share of the global limit
+// weighted by its demand: idle nodes keep a small base share and busy
+// ones get most of the limit. While news spreads the cluster may exceed
+// the limit briefly, so it suits limits that protect capacity rather
+// than ones that must never be exceeded.
+package gossip
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// Transport delivers gossip messages to peers by address. Incoming
+// messages are passed to Node.Receive
+type Transport interface {
+	Send(ctx context.Context, addr string, msg []byte) error
+}
+
+// Config configures a Node
+type Config struct {
+	// ID identifies the node in the cluster; it defaults to Addr
+	ID string
+	// Addr is the address peers send gossip to
+	Addr string
+	// Limit and Burst are shared by the whole cluster
+	Limit rateflow.Limit
+	Burst int
+	// Interval is how often the node measures its demand and gossips; 0
+	// means a second
+	Interval time.Duration
+	// Fanout is the number of peers gossiped to per interval; 0 means 3
+	Fanout int
+	// DeadAfter is how long a node may go unheard of before it no longer
+	// counts towards the shares; 0 means five intervals
+	DeadAfter time.Duration
+}
+
+// entry is what a node knows about one member of the cluster
+type entry struct {
+	ID     string  `json:"id"`
+	Addr   string  `json:"addr"`
+	Seq    uint64  `json:"seq"`
+	Demand float64 `json:"demand"` // events per second offered
+
+	// seen is the tick at which Seq was last found to have grown, with
+	// seenSeq the Seq it grew to
+	seen    time.Time
+	seenSeq uint64
+}
+
+// message is the gossip exchanged between nodes
+type message struct {
+	From    string  `json:"from"`
+	Members []entry `json:"members"`
+}
+
+// Node is one member of a gossiping cluster, admitting events against
+// its local share of the global limit
+type Node struct {
+	cfg   Config
+	tr    Transport
+	local rateflow.Limiter
+
+	demand atomic.Int64 // events offered since the last tick
+
+	mu       sync.Mutex
+	self     entry
+	members  map[string]*entry
+	lastTick time.Time
+	rand     *rand.Rand
+}
+
+// NewNode creates a node sending gossip through tr. Until it hears from
+// peers the node allows the whole limit, so seed it with Join
+func NewNode(cfg Config, tr Transport) *Node {
+	if cfg.ID == "" {
+		cfg.ID = cfg.Addr
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.Fanout <= 0 {
+		cfg.Fanout = 3
+	}
+	if cfg.DeadAfter <= 0 {
+		cfg.DeadAfter = 5 * cfg.Interval
+	}
+	return &Node{
+		cfg:      cfg,
+		tr:       tr,
+		local:    rateflow.NewLimiter(rateflow.TokenBucket, cfg.Limit, cfg.Burst),
+		self:     entry{ID: cfg.ID, Addr: cfg.Addr},
+		members:  make(map[string]*entry),
+		lastTick: time.Now(),
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Limiter returns the limiter enforcing the node's share
+func (n *Node) Limiter() rateflow.Limiter {
+	return n.local
+}
+
+// Allow is shorthand for AllowN(time.Now(), 1)
+func (n *Node) Allow() bool {
+	return n.AllowN(time.Now(), 1)
+}
+
+// AllowN reports whether k events may happen at t within the node's
+// share, counting them as demand either way
+func (n *Node) AllowN(t time.Time, k int) bool {
+	n.demand.Add(int64(k))
+	return n.local.AllowN(t, k)
+}
+
+// Wait is shorthand for WaitN(ctx, 1)
+func (n *Node) Wait(ctx context.Context) error {
+	return n.WaitN(ctx, 1)
+}
+
+// WaitN blocks until k events are allowed within the node's share
+func (n *Node) WaitN(ctx context.Context, k int) error {
+	n.demand.Add(int64(k))
+	return n.local.WaitN(ctx, k)
+}
+
+// Join adds peers to gossip with by address. They count towards the
+// shares once their first gossip arrives
+func (n *Node) Join(addrs ...string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, addr := range addrs {
+		if addr != n.cfg.Addr && n.members[addr] == nil {
+			n.members[addr] = &entry{ID: addr, Addr: addr}
+		}
+	}
+}
+
+// Members returns the IDs of the nodes currently sharing the limit,
+// this one included
+func (n *Node) Members() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ids := []string{n.self.ID}
+	for _, m := range n.alive(n.lastTick) {
+		ids = append(ids, m.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// alive returns the members whose Seq grew within DeadAfter before now.
+// n.mu must be held
+func (n *Node) alive(now time.Time) []*entry {
+	var alive []*entry
+	for _, m := range n.members {
+		if m.Seq > 0 && now.Sub(m.seen) < n.cfg.DeadAfter {
+			alive = append(alive, m)
+		}
+	}
+	return alive
+}
+
+// Receive merges a gossip message from a peer
+func (n *Node) Receive(msg []byte) error {
+	var m message
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, e := range m.Members {
+		if e.ID == n.self.ID {
+			continue
+		}
+		// A peer joined by address is known by its ID from now on
+		if old := n.members[e.Addr]; old != nil && old.Seq == 0 && e.Addr != e.ID {
+			delete(n.members, e.Addr)
+		}
+		cur := n.members[e.ID]
+		if cur == nil {
+			e := e
+			n.members[e.ID] = &e
+		} else if e.Seq > cur.Seq {
+			cur.Addr, cur.Seq, cur.Demand = e.Addr, e.Seq, e.Demand
+		}
+	}
+	return nil
+}
+
+// share returns the node's part of the limit: every live node weighs its
+// demand plus an equal base, so idle nodes keep some capacity and busy
+// ones get most of it. n.mu must be held
+func (n *Node) share(alive []*entry) float64 {
+	limit := float64(n.cfg.Limit)
+	base := limit / float64(len(alive)+1)
+	total := n.self.Demand + base
+	for _, m := range alive {
+		total += m.Demand + base
+	}
+	return limit * (n.self.Demand + base) / total
+}
+
+// Tick measures the node's demand, retunes its share of the limit and
+// gossips to Fanout random peers. It is called by Run every Interval
+func (n *Node) Tick(ctx context.Context) error {
+	return n.tick(ctx, time.Now())
+}
+
+func (n *Node) tick(ctx context.Context, now time.Time) error {
+	n.mu.Lock()
+	if elapsed := now.Sub(n.lastTick).Seconds(); elapsed > 0 {
+		rate := float64(n.demand.Swap(0)) / elapsed
+		n.self.Demand = (n.self.Demand + rate) / 2
+	}
+	n.lastTick = now
+	n.self.Seq++
+
+	// Liveness is judged on this node's own ticks, so peers' clocks do
+	// not matter: a member is alive while news of it keeps arriving
+	for id, m := range n.members {
+		if m.Seq > m.seenSeq {
+			m.seen, m.seenSeq = now, m.Seq
+		}
+		if m.Seq > 0 && now.Sub(m.seen) >= 2*n.cfg.DeadAfter {
+			delete(n.members, id)
+		}
+	}
+	alive := n.alive(now)
+	if n.cfg.Limit != rateflow.Inf {
+		share := n.share(alive)
+		n.local.SetLimitAt(now, rateflow.Limit(share))
+		burst := int(float64(n.cfg.Burst)*share/float64(n.cfg.Limit) + 0.5)
+		if burst < 1 {
+			burst = 1
+		}
+		n.local.SetBurstAt(now, burst)
+	}
+
+	msg := message{From: n.self.ID, Members: []entry{n.self}}
+	targets := make([]string, 0, len(n.members))
+	for _, m := range n.members {
+		targets = append(targets, m.Addr)
+		if m.Seq > 0 {
+			msg.Members = append(msg.Members, *m)
+		}
+	}
+	sort.Strings(targets)
+	n.rand.Shuffle(len(targets), func(i, j int) { targets[i], targets[j] = targets[j], targets[i] })
+	if len(targets) > n.cfg.Fanout {
+		targets = targets[:n.cfg.Fanout]
+	}
+	n.mu.Unlock()
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, addr := range targets {
+		if err := n.tr.Send(ctx, addr, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run calls Tick every Interval until ctx is done, then returns
+// ctx.Err(). Failed sends are not retried; the next round carries the
+// same news
+func (n *Node) Run(ctx context.Context) error {
+	ticker := time.NewTicker(n.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n.Tick(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package gossip
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// memNetwork delivers gossip between nodes in memory
+type memNetwork map[string]*Node
+
+func (m memNetwork) Send(ctx context.Context, addr string, msg []byte) error {
+	n, ok := m[addr]
+	if !ok {
+		return fmt.Errorf("no node at %s", addr)
+	}
+	return n.Receive(msg)
+}
+
+func newCluster(size int, limit rateflow.Limit) (memNetwork, []*Node) {
+	net := memNetwork{}
+	nodes := make([]*Node, size)
+	for i := range nodes {
+		addr := fmt.Sprintf("node-%d", i)
+		nodes[i] = NewNode(Config{Addr: addr, Limit: limit, Burst: 100}, net)
+		net[addr] = nodes[i]
+	}
+	for _, n := range nodes[1:] {
+		n.Join("node-0")
+	}
+	return net, nodes
+}
+
+// rounds ticks every node n times, one interval apart
+func rounds(nodes []*Node, start time.Time, n int) time.Time {
+	for i := 0; i < n; i++ {
+		start = start.Add(time.Second)
+		for _, node := range nodes {
+			node.tick(context.Background(), start)
+		}
+	}
+	return start
+}
+
+func TestGossipMembership(t *testing.T) {
+	_, nodes := newCluster(5, 100)
+	rounds(nodes, time.Now(), 5)
+
+	for _, n := range nodes {
+		if got := len(n.Members()); got != 5 {
+			t.Errorf("%s knows %d members, want 5", n.cfg.ID, got)
+		}
+	}
+	// Idle nodes split the limit evenly
+	for _, n := range nodes {
+		if got := n.Limiter().Limit(); got < 19.9 || got > 20.1 {
+			t.Errorf("%s limit = %v, want 20", n.cfg.ID, got)
+		}
+	}
+}
+
+func TestGossipReceiveMembers(t *testing.T) {
+	n := NewNode(Config{Addr: "self", Limit: 90}, memNetwork{})
+	msg, _ := json.Marshal(message{From: "a", Members: []entry{
+		{ID: "a", Addr: "a", Seq: 1}, {ID: "b", Addr: "b", Seq: 1}, {ID: "c", Addr: "c", Seq: 1},
+	}})
+	if err := n.Receive(msg); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if m := n.members[id]; m == nil || m.ID != id {
+			t.Errorf("members[%s] = %+v, want its own entry", id, m)
+		}
+	}
+}
+
+func TestGossipShares(t *testing.T) {
+	_, nodes := newCluster(3, 90)
+	now := rounds(nodes, time.Now(), 3)
+
+	// node-0 gets 300 requests per second, the others none
+	for i := 0; i < 6; i++ {
+		nodes[0].demand.Add(300)
+		now = rounds(nodes, now, 1)
+	}
+
+	busy := float64(nodes[0].Limiter().Limit())
+	idle := float64(nodes[1].Limiter().Limit())
+	if busy < 70 {
+		t.Errorf("busy node limit = %v, want most of the 90", busy)
+	}
+	if idle <= 0 || idle > 15 {
+		t.Errorf("idle node limit = %v, want a small base share", idle)
+	}
+	total := busy + idle + float64(nodes[2].Limiter().Limit())
+	if total < 85 || total > 95 {
+		t.Errorf("shares add up to %v, want about the global 90", total)
+	}
+}
+
+func TestGossipDeadPeers(t *testing.T) {
+	_, nodes := newCluster(3, 90)
+	now := rounds(nodes, time.Now(), 3)
+
+	// node-2 stops; after DeadAfter the others split the limit in two
+	rounds(nodes[:2], now, 6)
+	if got := nodes[0].Members(); len(got) != 2 {
+		t.Errorf("Members = %v, want the dead node dropped", got)
+	}
+	if got := nodes[0].Limiter().Limit(); got < 44.9 || got > 45.1 {
+		t.Errorf("limit = %v, want half of 90", got)
+	}
+}
+
+func TestUDPTransport(t *testing.T) {
+	a, err := ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no UDP: %v", err)
+	}
+	b, err := ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no UDP: %v", err)
+	}
+	na := NewNode(Config{Addr: a.Addr(), Limit: 10, Burst: 10}, a)
+	nb := NewNode(Config{Addr: b.Addr(), Limit: 10, Burst: 10}, b)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 2)
+	go func() { done <- a.Serve(ctx, na) }()
+	go func() { done <- b.Serve(ctx, nb) }()
+
+	nb.Join(a.Addr())
+	for i := 0; i < 100 && len(na.Members()) < 2; i++ {
+		nb.Tick(ctx)
+		na.Tick(ctx)
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := na.Members(); len(got) != 2 {
+		t.Errorf("Members = %v, want both nodes", got)
+	}
+
+	cancel()
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != context.Canceled {
+			t.Errorf("Serve = %v, want context.Canceled", err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package gossip
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// maxMessage is the largest gossip message a UDPTransport reads. A
+// message lists every member, which keeps clusters of a few hundred
+// nodes within one datagram
+const maxMessage = 64 << 10
+
+// UDPTransport sends gossip as UDP datagrams
+type UDPTransport struct {
+	conn *net.UDPConn
+}
+
+var _ Transport = (*UDPTransport)(nil)
+
+// ListenUDP creates a transport listening on addr, e.g. ":7946"
+func ListenUDP(addr string) (*UDPTransport, error) {
+	ua, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", ua)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPTransport{conn: conn}, nil
+}
+
+// Addr returns the address the transport listens on
+func (t *UDPTransport) Addr() string {
+	return t.conn.LocalAddr().String()
+}
+
+// Send sends msg to addr
+func (t *UDPTransport) Send(ctx context.Context, addr string, msg []byte) error {
+	ua, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	_, err = t.conn.WriteToUDP(msg, ua)
+	return err
+}
+
+// Serve passes every datagram received to n until ctx is done or the
+// transport is closed. Malformed messages are dropped
+func (t *UDPTransport) Serve(ctx context.Context, n *Node) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			t.conn.Close()
+		case <-done:
+		}
+	}()
+
+	buf := make([]byte, maxMessage)
+	for {
+		size, _, err := t.conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		n.Receive(buf[:size])
+	}
+}
+
+// Close stops the transport
+func (t *UDPTransport) Close() error {
+	return t.conn.Close()
+}
@@ -0,0 +1,130 @@
+// Package gqllimit charges GraphQL operations against per-client limits
+// by their query complexity, so one deep query uses as much quota as the
+// many cheap ones it costs the server. The package does not depend on a
+// GraphQL library; with gqlgen, an extension computing the complexity
+// score mounts it:
+//
+//	type Quota struct {
+//		L  *gqllimit.Limiter
+//		es graphql.ExecutableSchema
+//	}
+//
+//	func (q *Quota) ExtensionName() string                       { return "RateflowQuota" }
+//	func (q *Quota) Validate(es graphql.ExecutableSchema) error { q.es = es; return nil }
+//
+//	func (q *Quota) MutateOperationContext(ctx context.Context, oc *graphql.OperationContext) *gqlerror.Error {
+//		score := complexity.Calculate(q.es, oc.Operation, oc.Variables)
+//		var le *gqllimit.Error
+//		if err := q.L.Check(ctx, score); errors.As(err, &le) {
+//			return &gqlerror.Error{Message: le.Error(), Extensions: le.Extensions()}
+//		}
+//		return nil
+//	}
+//
+//	srv.Use(&Quota{L: gqllimit.New(clients, clientOf, gqllimit.PointsPerToken(10))})
+package gqllimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// Error is returned by Check for a refused operation
+type Error struct {
+	// Complexity is the operation's score and Cost the tokens it needed
+	Complexity int
+	Cost       int
+	// RetryAfter is how long until the client has the tokens, or
+	// rateflow.InfDuration if the operation costs more than the burst
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.RetryAfter == rateflow.InfDuration {
+		return fmt.Sprintf("gqllimit: query complexity %d exceeds the client's limit", e.Complexity)
+	}
+	return fmt.Sprintf("gqllimit: rate limit exceeded for query complexity %d, retry in %v", e.Complexity, e.RetryAfter)
+}
+
+// Extensions returns fields for the extensions of a GraphQL error: a
+// RATE_LIMITED code, the cost, and the seconds to wait unless retrying
+// cannot help
+func (e *Error) Extensions() map[string]any {
+	ext := map[string]any{"code": "RATE_LIMITED", "complexity": e.Complexity, "cost": e.Cost}
+	if e.RetryAfter != rateflow.InfDuration {
+		ext["retryAfter"] = e.RetryAfter.Seconds()
+	}
+	return ext
+}
+
+type config struct {
+	points int
+	max    int
+}
+
+// Option configures a Limiter
+type Option func(*config)
+
+// PointsPerToken charges one token per n complexity points, rounded up,
+// so a limit can be given in requests of a typical complexity. 1 by
+// default
+func PointsPerToken(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.points = n
+		}
+	}
+}
+
+// MaxComplexity refuses operations scoring above max outright, without
+// charging them
+func MaxComplexity(max int) Option {
+	return func(c *config) {
+		c.max = max
+	}
+}
+
+// Limiter charges operations to the limiter of their client
+type Limiter struct {
+	keyed  *rateflow.Keyed[string]
+	client func(ctx context.Context) string
+	cfg    config
+}
+
+// New charges operations to the limiters of k, keyed by the client
+// client returns for an operation's context, e.g. an API key put there by
+// authentication middleware
+func New(k *rateflow.Keyed[string], client func(ctx context.Context) string, opts ...Option) *Limiter {
+	cfg := config{points: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &Limiter{keyed: k, client: client, cfg: cfg}
+}
+
+// Cost returns the tokens an operation of the given complexity costs, at
+// least 1
+func (l *Limiter) Cost(complexity int) int {
+	cost := (complexity + l.cfg.points - 1) / l.cfg.points
+	if cost < 1 {
+		return 1
+	}
+	return cost
+}
+
+// Check charges an operation of the given complexity to its client, or
+// returns an *Error if the client's limiter refuses it
+func (l *Limiter) Check(ctx context.Context, complexity int) error {
+	cost := l.Cost(complexity)
+	if l.cfg.max > 0 && complexity > l.cfg.max {
+		return &Error{Complexity: complexity, Cost: cost, RetryAfter: rateflow.InfDuration}
+	}
+	ok, res := l.keyed.AllowDetailsKey(l.client(ctx), cost)
+	if ok {
+		return nil
+	}
+	return &Error{Complexity: complexity, Cost: cost, RetryAfter: res.RetryAfter}
+}
@@ -0,0 +1,81 @@
+package gqllimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+type clientKey struct{}
+
+func clientOf(ctx context.Context) string {
+	id, _ := ctx.Value(clientKey{}).(string)
+	return id
+}
+
+func TestLimiter(t *testing.T) {
+	clients := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, rateflow.Every(time.Minute), 10)
+	l := New(clients, clientOf, PointsPerToken(10))
+	ctx := context.WithValue(context.Background(), clientKey{}, "acme")
+
+	if got := l.Cost(0); got != 1 {
+		t.Errorf("Cost(0) = %d, want at least 1", got)
+	}
+	if got := l.Cost(75); got != 8 {
+		t.Errorf("Cost(75) = %d, want 8", got)
+	}
+	if err := l.Check(ctx, 75); err != nil {
+		t.Fatal(err)
+	}
+	var le *Error
+	if err := l.Check(ctx, 30); !errors.As(err, &le) {
+		t.Fatalf("Check = %v, want an *Error once the quota is spent", err)
+	}
+	if le.Cost != 3 || le.RetryAfter <= 0 || le.Extensions()["code"] != "RATE_LIMITED" {
+		t.Errorf("error = %+v, want cost 3 and a retry time", le)
+	}
+	if err := l.Check(ctx, 10); err != nil {
+		t.Errorf("cheap query: %v, want the remaining tokens used", err)
+	}
+	if err := l.Check(context.WithValue(ctx, clientKey{}, "globex"), 100); err != nil {
+		t.Errorf("other client: %v, want its own quota", err)
+	}
+}
+
+func TestLimiterKeyedLists(t *testing.T) {
+	clients := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, 100, 100)
+	clients.SetDenylist(rateflow.NewKeyList("mallory"))
+	l := New(clients, clientOf)
+	ctx := context.Background()
+
+	var le *Error
+	if err := l.Check(context.WithValue(ctx, clientKey{}, "mallory"), 1); !errors.As(err, &le) || le.RetryAfter != rateflow.InfDuration {
+		t.Errorf("Check = %v, want a denylisted client refused for good", err)
+	}
+
+	clients.SetParent(rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Every(time.Hour), 5))
+	if err := l.Check(context.WithValue(ctx, clientKey{}, "acme"), 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Check(context.WithValue(ctx, clientKey{}, "globex"), 1); err == nil {
+		t.Error("Check should refuse once the parent is spent")
+	}
+}
+
+func TestLimiterTooComplex(t *testing.T) {
+	clients := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, 100, 100)
+	l := New(clients, clientOf, MaxComplexity(50))
+	var le *Error
+	if err := l.Check(context.Background(), 51); !errors.As(err, &le) || le.RetryAfter != rateflow.InfDuration {
+		t.Fatalf("Check = %v, want a refusal retrying cannot fix", err)
+	}
+	if _, ok := le.Extensions()["retryAfter"]; ok {
+		t.Error("expected no retryAfter for a query that is too complex")
+	}
+	if got := clients.Get("").Tokens(); got != 100 {
+		t.Errorf("Tokens = %v, want the refused query not charged", got)
+	}
+}
@@ -0,0 +1,115 @@
+// Package grpclimit limits gRPC servers by method and metadata.
+// UnaryServerInterceptor and StreamServerInterceptor refuse calls over
+// the limit with RESOURCE_EXHAUSTED and a RetryInfo detail saying when
+// to retry, as clients following the gRPC retry conventions expect:
+//
+//	users := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, 10, 20)
+//	l := grpclimit.NewLimiter(users, grpclimit.ByMetadata("x-api-key"))
+//	srv := grpc.NewServer(
+//		grpc.ChainUnaryInterceptor(grpclimit.UnaryServerInterceptor(l)),
+//		grpc.ChainStreamInterceptor(grpclimit.StreamServerInterceptor(l)),
+//	)
+//
+// A stream is checked once, when it opens. The package is a module of
+// its own, so only programs using it depend on gRPC.
+package grpclimit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Error is returned by Check for a refused call
+type Error struct {
+	// Method is the full method of the call, e.g. /pkg.Service/Method
+	Method string
+	// Key is the key the call was limited by
+	Key string
+	// RetryDelay is how long to wait before retrying, or 0 if the call
+	// can never be admitted
+	RetryDelay time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.RetryDelay == 0 {
+		return fmt.Sprintf("grpclimit: %s: rate limit exceeded for %q", e.Method, e.Key)
+	}
+	return fmt.Sprintf("grpclimit: %s: rate limit exceeded for %q, retry in %v", e.Method, e.Key, e.RetryDelay)
+}
+
+// GRPCStatus returns the status gRPC sends for e: RESOURCE_EXHAUSTED,
+// with a RetryInfo detail unless the call can never be admitted
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(codes.ResourceExhausted, e.Error())
+	if e.RetryDelay <= 0 {
+		return st
+	}
+	if withRetry, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(e.RetryDelay)}); err == nil {
+		return withRetry
+	}
+	return st
+}
+
+// KeyFunc returns the key a call is limited by from its full method and
+// incoming metadata. md is a metadata.MD, whose keys are lower case
+type KeyFunc func(method string, md map[string][]string) string
+
+// ByMethod keys calls by their full method
+func ByMethod(method string, md map[string][]string) string {
+	return method
+}
+
+// ByMetadata keys calls by the first value of a metadata key, e.g. an
+// API key or tenant
+func ByMetadata(name string) KeyFunc {
+	name = strings.ToLower(name)
+	return func(method string, md map[string][]string) string {
+		if v := md[name]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+}
+
+// ByMethodAndMetadata keys calls by their full method and the first
+// value of a metadata key, so each caller has its own limit per method
+func ByMethodAndMetadata(name string) KeyFunc {
+	byMD := ByMetadata(name)
+	return func(method string, md map[string][]string) string {
+		return method + "|" + byMD(method, md)
+	}
+}
+
+// Limiter decides gRPC calls on keyed limiters
+type Limiter struct {
+	keyed *rateflow.Keyed[string]
+	key   KeyFunc
+}
+
+// NewLimiter limits calls on the limiters of k, keyed by key
+func NewLimiter(k *rateflow.Keyed[string], key KeyFunc) *Limiter {
+	return &Limiter{keyed: k, key: key}
+}
+
+// Check admits one call to method with incoming metadata md, or returns
+// an *Error saying when to retry
+func (l *Limiter) Check(ctx context.Context, method string, md map[string][]string) error {
+	key := l.key(method, md)
+	ok, res := l.keyed.AllowDetailsKey(key, 1)
+	if ok {
+		return nil
+	}
+	delay := res.RetryAfter
+	if delay == rateflow.InfDuration {
+		delay = 0
+	}
+	return &Error{Method: method, Key: key, RetryDelay: delay}
+}
@@ -0,0 +1,151 @@
+package grpclimit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestLimiter(t *testing.T) {
+	k := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, rateflow.Every(time.Minute), 1)
+	l := NewLimiter(k, ByMethodAndMetadata("X-Tenant"))
+	ctx := context.Background()
+	acme := map[string][]string{"x-tenant": {"acme"}}
+
+	if err := l.Check(ctx, "/svc.Search/Query", acme); err != nil {
+		t.Fatal(err)
+	}
+	err := l.Check(ctx, "/svc.Search/Query", acme)
+	var le *Error
+	if !errors.As(err, &le) {
+		t.Fatalf("Check = %v, want an *Error", err)
+	}
+	if status.Code(err) != codes.ResourceExhausted || le.Key != "/svc.Search/Query|acme" {
+		t.Errorf("error = %+v, want RESOURCE_EXHAUSTED for acme's key", le)
+	}
+	if le.RetryDelay <= 0 || le.RetryDelay > time.Minute {
+		t.Errorf("RetryDelay = %v, want up to a minute", le.RetryDelay)
+	}
+
+	if err := l.Check(ctx, "/svc.Search/Index", acme); err != nil {
+		t.Errorf("other method: %v, want its own limit", err)
+	}
+	if err := l.Check(ctx, "/svc.Search/Query", map[string][]string{"x-tenant": {"globex"}}); err != nil {
+		t.Errorf("other tenant: %v, want its own limit", err)
+	}
+}
+
+func TestLimiterKeyedLists(t *testing.T) {
+	k := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, 10, 10)
+	k.SetDenylist(rateflow.NewKeyList("/svc/M"))
+	l := NewLimiter(k, ByMethod)
+	ctx := context.Background()
+
+	var le *Error
+	if err := l.Check(ctx, "/svc/M", nil); !errors.As(err, &le) || le.RetryDelay != 0 {
+		t.Errorf("Check = %v, want a denylisted key refused for good", err)
+	}
+	k.SetParent(rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Every(time.Hour), 1))
+	if l.Check(ctx, "/svc/A", nil) != nil || l.Check(ctx, "/svc/B", nil) == nil {
+		t.Error("expected the parent to limit every key together")
+	}
+}
+
+func TestLimiterNeverAdmitted(t *testing.T) {
+	k := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, 1, 1)
+	l := NewLimiter(k, ByMethod)
+	ctx := context.Background()
+	k.Get("/svc/M").SetLimit(0)
+	k.Get("/svc/M").AllowN(time.Now(), 1)
+
+	var le *Error
+	if err := l.Check(ctx, "/svc/M", nil); !errors.As(err, &le) || le.RetryDelay != 0 {
+		t.Errorf("Check = %v, want an error without a retry delay", err)
+	}
+}
+
+// dialHealth serves the health service behind the interceptors of l and
+// returns a client for it
+func dialHealth(t *testing.T, l *Limiter) healthpb.HealthClient {
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(l)),
+		grpc.StreamInterceptor(StreamServerInterceptor(l)),
+	)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	k := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, rateflow.Every(time.Minute), 1)
+	client := dialHealth(t, NewLimiter(k, ByMetadata("x-tenant")))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant", "acme")
+
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("code = %v, want ResourceExhausted", st.Code())
+	}
+	var retry *errdetails.RetryInfo
+	for _, d := range st.Details() {
+		if r, ok := d.(*errdetails.RetryInfo); ok {
+			retry = r
+		}
+	}
+	if retry == nil || retry.RetryDelay.AsDuration() <= 0 || retry.RetryDelay.AsDuration() > time.Minute {
+		t.Errorf("details = %v, want a RetryInfo of up to a minute", st.Details())
+	}
+
+	other := metadata.AppendToOutgoingContext(context.Background(), "x-tenant", "globex")
+	if _, err := client.Check(other, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("other tenant: %v, want its own limit", err)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	k := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, rateflow.Every(time.Minute), 1)
+	client := dialHealth(t, NewLimiter(k, ByMethod))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.Recv(); err != nil {
+		t.Fatalf("first stream: %v", err)
+	}
+	second, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Recv(); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second stream: %v, want ResourceExhausted", err)
+	}
+}
@@ -0,0 +1,33 @@
+package grpclimit
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryServerInterceptor checks every unary call with l before its
+// handler runs. Refused calls fail with the *Error of Check, which gRPC
+// sends as its GRPCStatus
+func UnaryServerInterceptor(l *Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if err := l.Check(ctx, info.FullMethod, md); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor checks every stream with l when it opens; the
+// messages on an admitted stream are not limited
+func StreamServerInterceptor(l *Limiter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		if err := l.Check(ss.Context(), info.FullMethod, md); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,102 @@
+package rateflow
+
+import (
+	"context"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// HierarchyLevel is one level of a Hierarchy, e.g. "tenant", "user" or
+// "endpoint"
+type HierarchyLevel struct {
+	Name string
+	// NewLimiter builds the limiter for a node, given its path from the
+	// root with one entry per level, keyed by level name
+	NewLimiter func(path Descriptor) Limiter
+}
+
+// Hierarchy is a tree of limiters, e.g. tenant→user→endpoint, where a
+// request draws from every node on its path: the tenant, the user within
+// the tenant and the endpoint for that user. A request is charged at
+// every level or none, and the level that refused is reported
+type Hierarchy struct {
+	levels []hierarchyNode
+}
+
+type hierarchyNode struct {
+	name  string
+	nodes *Keyed[Descriptor]
+}
+
+// NewHierarchy creates a hierarchy with the given levels, root first
+func NewHierarchy(levels ...HierarchyLevel) *Hierarchy {
+	h := &Hierarchy{levels: make([]hierarchyNode, len(levels))}
+	for i, l := range levels {
+		h.levels[i] = hierarchyNode{name: l.Name, nodes: NewKeyed(l.NewLimiter)}
+	}
+	return h
+}
+
+// Level returns the keyed limiters of the named level, keyed by path, e.g.
+// to bound their number or report their usage. It returns nil for an
+// unknown name
+func (h *Hierarchy) Level(name string) *Keyed[Descriptor] {
+	for _, l := range h.levels {
+		if l.name == name {
+			return l.nodes
+		}
+	}
+	return nil
+}
+
+// limiters returns the limiters on path, one per level. A path shorter
+// than the hierarchy draws from its first levels only; extra elements are
+// ignored
+func (h *Hierarchy) limiters(path []string) []Limiter {
+	if len(path) > len(h.levels) {
+		path = path[:len(h.levels)]
+	}
+	lims := make([]Limiter, len(path))
+	var d Descriptor
+	for i, p := range path {
+		d = d.With(h.levels[i].name, p)
+		lims[i] = h.levels[i].nodes.Get(d)
+	}
+	return lims
+}
+
+// Allow is shorthand for AllowN(time.Now(), 1, path...)
+func (h *Hierarchy) Allow(path ...string) (bool, string) {
+	lims := h.limiters(path)
+	if len(lims) == 0 {
+		return true, ""
+	}
+	return h.allow(limiter.NowOf(lims[0]), 1, lims)
+}
+
+// AllowN reports whether n events may happen at t for path, e.g.
+// AllowN(t, 1, tenant, user, endpoint). When refused it also returns the
+// name of the first level that had no capacity
+func (h *Hierarchy) AllowN(t time.Time, n int, path ...string) (bool, string) {
+	return h.allow(t, n, h.limiters(path))
+}
+
+func (h *Hierarchy) allow(t time.Time, n int, lims []Limiter) (bool, string) {
+	ok, i := limiter.AllowAllIndex(t, n, lims...)
+	if ok || i < 0 {
+		return ok, ""
+	}
+	return false, h.levels[i].name
+}
+
+// Wait is shorthand for WaitN(ctx, 1, path...)
+func (h *Hierarchy) Wait(ctx context.Context, path ...string) error {
+	return h.WaitN(ctx, 1, path...)
+}
+
+// WaitN blocks until n events are allowed at every level of path or ctx
+// is done
+func (h *Hierarchy) WaitN(ctx context.Context, n int, path ...string) error {
+	return WaitAll(ctx, n, h.limiters(path)...)
+}
@@ -0,0 +1,51 @@
+package rateflow
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHierarchy(t *testing.T) {
+	burst := func(b int) func(Descriptor) Limiter {
+		return func(Descriptor) Limiter { return NewLimiter(TokenBucket, Limit(1), b) }
+	}
+	h := NewHierarchy(
+		HierarchyLevel{Name: "tenant", NewLimiter: burst(3)},
+		HierarchyLevel{Name: "user", NewLimiter: burst(2)},
+		HierarchyLevel{Name: "endpoint", NewLimiter: burst(1)},
+	)
+
+	if ok, _ := h.Allow("acme", "alice", "/search"); !ok {
+		t.Fatal("expected the first request to pass")
+	}
+	if ok, level := h.Allow("acme", "alice", "/search"); ok || level != "endpoint" {
+		t.Errorf("expected the endpoint level to refuse, got %v %q", ok, level)
+	}
+	if ok, _ := h.Allow("acme", "alice", "/home"); !ok {
+		t.Fatal("expected another endpoint to pass")
+	}
+	if ok, level := h.Allow("acme", "alice", "/about"); ok || level != "user" {
+		t.Errorf("expected the user level to refuse, got %v %q", ok, level)
+	}
+	if ok, _ := h.Allow("acme", "bob", "/search"); !ok {
+		t.Fatal("expected bob's own user and endpoint limits")
+	}
+	if ok, level := h.Allow("acme", "carol"); ok || level != "tenant" {
+		t.Errorf("expected the tenant level to refuse, got %v %q", ok, level)
+	}
+	if ok, _ := h.Allow("globex", "alice", "/search"); !ok {
+		t.Error("expected alice in another tenant to be a separate node")
+	}
+
+	carol := h.Level("user").Get(Descriptor{}.With("tenant", "acme").With("user", "carol"))
+	if carol.Tokens() < 2 {
+		t.Errorf("expected the refused request to be refunded to carol, got %v", carol.Tokens())
+	}
+	if h.Level("region") != nil {
+		t.Error("expected nil for an unknown level")
+	}
+
+	if err := h.Wait(context.Background(), "initech", "dave", "/"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package httplimit
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// AdaptiveConfig configures how a Transport backs off when servers
+// answer 429 Too Many Requests
+type AdaptiveConfig struct {
+	// Factor multiplies the limit on each 429, 0.5 if 0
+	Factor float64
+	// Pause holds every request until the reset the server gave, instead
+	// of only sending slower
+	Pause bool
+	// Cooldown is how long the limit stays lowered when the response does
+	// not say when the server's limit resets, 1m if 0
+	Cooldown time.Duration
+	// Clock defaults to the system clock
+	Clock rateflow.Clock
+}
+
+// backoff is a limiter lowered after a 429
+type backoff struct {
+	limit     rateflow.Limit // the limit to restore
+	loweredAt time.Time
+	until     time.Time
+}
+
+type adaptive struct {
+	cfg AdaptiveConfig
+
+	mu     sync.Mutex
+	states map[rateflow.Limiter]*backoff
+}
+
+// Adaptive makes the Transport follow the server's view of the limit:
+// on a 429 it multiplies the limits the request waited on by Factor
+// until the reset announced by Retry-After, RateLimit-Reset or
+// X-RateLimit-Reset, then restores them. A 429 to a request sent before
+// the last cut only extends it, so a burst of rejected requests in
+// flight lowers the limit once
+func Adaptive(cfg AdaptiveConfig) TransportOption {
+	if cfg.Factor <= 0 {
+		cfg.Factor = 0.5
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = time.Minute
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = limiter.SystemClock{}
+	}
+	return func(t *Transport) {
+		t.adapt = &adaptive{cfg: cfg, states: make(map[rateflow.Limiter]*backoff)}
+	}
+}
+
+// recover restores the limiters whose backoff is over and returns when
+// the latest of the others ends
+func (a *adaptive) recover(lims []rateflow.Limiter, now time.Time) time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var until time.Time
+	for _, lim := range lims {
+		st, ok := a.states[lim]
+		if !ok {
+			continue
+		}
+		if !now.Before(st.until) {
+			lim.SetLimit(st.limit)
+			delete(a.states, lim)
+			continue
+		}
+		if st.until.After(until) {
+			until = st.until
+		}
+	}
+	return until
+}
+
+// before recovers lims and, with Pause, waits out their backoff
+func (a *adaptive) before(ctx context.Context, lims []rateflow.Limiter) error {
+	now := a.cfg.Clock.Now()
+	until := a.recover(lims, now)
+	if !a.cfg.Pause || !until.After(now) {
+		return nil
+	}
+	wait := until.Sub(now)
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(until) {
+		return &rateflow.RateLimitError{RetryAfter: wait}
+	}
+	select {
+	case <-a.cfg.Clock.After(wait):
+		a.recover(lims, a.cfg.Clock.Now())
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttled lowers lims after a 429 to a request sent at sent
+func (a *adaptive) throttled(lims []rateflow.Limiter, sent time.Time, h http.Header) {
+	now := a.cfg.Clock.Now()
+	until, ok := resetAt(h, now)
+	if !ok || !until.After(now) {
+		until = now.Add(a.cfg.Cooldown)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, lim := range lims {
+		st, ok := a.states[lim]
+		if !ok {
+			st = &backoff{limit: lim.Limit()}
+			a.states[lim] = st
+		}
+		if !ok || !sent.Before(st.loweredAt) {
+			lim.SetLimit(lim.Limit() * rateflow.Limit(a.cfg.Factor))
+			st.loweredAt = now
+		}
+		if until.After(st.until) {
+			st.until = until
+		}
+	}
+}
+
+// resetAt reads when the server's limit resets from Retry-After, in
+// seconds or as a date, RateLimit-Reset in seconds, or X-RateLimit-Reset
+// in seconds or, for values too large to be a delay, as a Unix time
+func resetAt(h http.Header, now time.Time) (time.Time, bool) {
+	if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return now.Add(time.Duration(secs) * time.Second), true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return t, true
+		}
+	}
+	if secs, err := strconv.ParseInt(h.Get(HeaderReset), 10, 64); err == nil {
+		return now.Add(time.Duration(secs) * time.Second), true
+	}
+	if secs, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		// Delays are short; a Unix time is over a billion seconds
+		if secs > 1e9 {
+			return time.Unix(secs, 0), true
+		}
+		return now.Add(time.Duration(secs) * time.Second), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,149 @@
+package httplimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.Advance(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestAdaptiveTransport(t *testing.T) {
+	var throttle atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if throttle.Load() {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	clock := &fakeClock{now: time.Now()}
+	lim := rateflow.NewLimiter(rateflow.TokenBucket, 100, 100)
+	client := &http.Client{Transport: NewTransport(nil, lim, Adaptive(AdaptiveConfig{Clock: clock}))}
+	get := func() int {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	throttle.Store(true)
+	get()
+	if lim.Limit() != 50 {
+		t.Fatalf("Limit = %v, want halved after a 429", lim.Limit())
+	}
+	get()
+	if lim.Limit() != 25 {
+		t.Fatalf("Limit = %v, want halved again", lim.Limit())
+	}
+
+	throttle.Store(false)
+	clock.Advance(29 * time.Second)
+	get()
+	if lim.Limit() != 25 {
+		t.Errorf("Limit = %v, want it kept low until the reset", lim.Limit())
+	}
+	clock.Advance(time.Second)
+	get()
+	if lim.Limit() != 100 {
+		t.Errorf("Limit = %v, want it restored after the reset", lim.Limit())
+	}
+}
+
+func TestAdaptiveTransportPause(t *testing.T) {
+	var hits atomic.Int64
+	reset := time.Now().Add(time.Hour).Unix()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	clock := &fakeClock{now: time.Now()}
+	lim := rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Inf, 1)
+	client := &http.Client{Transport: NewTransport(nil, lim, Adaptive(AdaptiveConfig{Pause: true, Clock: clock}))}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	var rle *rateflow.RateLimitError
+	if _, err := client.Do(req); !errors.As(err, &rle) {
+		t.Fatalf("Do = %v, want a RateLimitError while paused past the deadline", err)
+	}
+
+	// Without a deadline the request waits out the pause
+	resp, err = client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || hits.Load() != 2 {
+		t.Errorf("got %d after %d requests, want the paused request sent", resp.StatusCode, hits.Load())
+	}
+	if !clock.Now().After(time.Unix(reset, 0).Add(-time.Second)) {
+		t.Errorf("clock at %v, want the request held until the reset", clock.Now())
+	}
+}
+
+func TestResetAt(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name, value string
+		want        time.Time
+	}{
+		{"Retry-After", "5", now.Add(5 * time.Second)},
+		{"Retry-After", "Mon, 01 Jan 2024 00:01:00 GMT", now.Add(time.Minute)},
+		{HeaderReset, "7", now.Add(7 * time.Second)},
+		{"X-RateLimit-Reset", "10", now.Add(10 * time.Second)},
+		{"X-RateLimit-Reset", strconv.FormatInt(now.Add(time.Hour).Unix(), 10), now.Add(time.Hour)},
+	} {
+		h := http.Header{}
+		h.Set(tc.name, tc.value)
+		if got, ok := resetAt(h, now); !ok || !got.Equal(tc.want) {
+			t.Errorf("%s: %s = %v, %v, want %v", tc.name, tc.value, got, ok, tc.want)
+		}
+	}
+	if _, ok := resetAt(http.Header{}, now); ok {
+		t.Error("expected no reset without headers")
+	}
+}
@@ -0,0 +1,59 @@
+package httplimit
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// DenyHandler writes the response to a request Middleware refused. res
+// holds the limiter's state, with how long to wait in RetryAfter and the
+// quota left in Remaining; the RateLimit fields and Retry-After are set
+// on w before it is called. A handler can write any status, e.g.
+// redirect to a page explaining the limit, or hold the connection for a
+// while before answering to slow down a scraper
+type DenyHandler interface {
+	Deny(w http.ResponseWriter, r *http.Request, res rateflow.Result)
+}
+
+// DenyHandlerFunc adapts a function to a DenyHandler
+type DenyHandlerFunc func(w http.ResponseWriter, r *http.Request, res rateflow.Result)
+
+// Deny calls f(w, r, res)
+func (f DenyHandlerFunc) Deny(w http.ResponseWriter, r *http.Request, res rateflow.Result) {
+	f(w, r, res)
+}
+
+// plainDeny answers 429 with its status text, the default
+var plainDeny = DenyHandlerFunc(func(w http.ResponseWriter, r *http.Request, res rateflow.Result) {
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+})
+
+// ProblemDeny answers 429 with an RFC 9457 application/problem+json body
+// carrying the seconds to wait and the quota left
+var ProblemDeny = DenyHandlerFunc(func(w http.ResponseWriter, r *http.Request, res rateflow.Result) {
+	problem := struct {
+		Type       string `json:"type"`
+		Title      string `json:"title"`
+		Status     int    `json:"status"`
+		Detail     string `json:"detail"`
+		RetryAfter int64  `json:"retry_after,omitempty"`
+		Remaining  int    `json:"remaining"`
+	}{
+		Type:      "about:blank",
+		Title:     http.StatusText(http.StatusTooManyRequests),
+		Status:    http.StatusTooManyRequests,
+		Detail:    "rate limit exceeded",
+		Remaining: res.Remaining,
+	}
+	if res.RetryAfter != rateflow.InfDuration {
+		problem.RetryAfter = seconds(res.RetryAfter)
+	}
+	if problem.Remaining < 0 {
+		problem.Remaining = 0
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(problem)
+})
@@ -0,0 +1,29 @@
+package httplimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestProblemDeny(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ProblemDeny.Deny(rec, httptest.NewRequest("GET", "/", nil), rateflow.Result{Limit: 5, RetryAfter: 2500 * time.Millisecond})
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("got %d %q, want a 429 problem", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var body struct {
+		Status     int   `json:"status"`
+		RetryAfter int64 `json:"retry_after"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Status != 429 || body.RetryAfter != 3 {
+		t.Errorf("body = %+v, want status 429 and retry_after 3", body)
+	}
+}
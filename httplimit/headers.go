@@ -0,0 +1,96 @@
+package httplimit
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// Header names of draft-ietf-httpapi-ratelimit-headers
+const (
+	HeaderLimit     = "RateLimit-Limit"
+	HeaderRemaining = "RateLimit-Remaining"
+	HeaderReset     = "RateLimit-Reset"
+	HeaderPolicy    = "RateLimit-Policy"
+)
+
+// HeaderSetter sets a response header field. http.Header implements it,
+// and so do the contexts of routers not built on net/http, e.g. Fiber's
+type HeaderSetter interface {
+	Set(key, value string)
+}
+
+// SetHeaders sets the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset fields from res, a limiter's state read at now, and
+// Retry-After if res refused the request. Reset and Retry-After are in
+// whole seconds, rounded up so a client waiting them out is admitted.
+// Retry-After is left out for a request that can never be admitted
+func SetHeaders(h HeaderSetter, res rateflow.Result, now time.Time) {
+	h.Set(HeaderLimit, strconv.Itoa(res.Limit))
+	remaining := res.Remaining
+	if remaining < 0 {
+		remaining = 0
+	}
+	h.Set(HeaderRemaining, strconv.Itoa(remaining))
+	if !res.ResetAt.IsZero() {
+		h.Set(HeaderReset, strconv.FormatInt(seconds(res.ResetAt.Sub(now)), 10))
+	}
+	if res.RetryAfter > 0 && res.RetryAfter != rateflow.InfDuration {
+		h.Set("Retry-After", strconv.FormatInt(seconds(res.RetryAfter), 10))
+	}
+}
+
+// seconds returns d in whole seconds, rounded up, and 0 if negative
+func seconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	secs := int64(d / time.Second)
+	if d%time.Second != 0 {
+		secs++
+	}
+	return secs
+}
+
+// QuotaPolicy is one quota policy of a RateLimit-Policy field: Limit
+// events per Window, e.g. 100;w=60
+type QuotaPolicy struct {
+	Limit  int
+	Window time.Duration
+	// Comment is sent as the comment parameter if not empty
+	Comment string
+}
+
+// PolicyOf returns the quota policy of lim: its burst per the time the
+// limit takes to refill it
+func PolicyOf(lim rateflow.Limiter) QuotaPolicy {
+	p := QuotaPolicy{Limit: lim.Burst()}
+	if l := lim.Limit(); l > 0 && l != rateflow.Inf {
+		p.Window = time.Duration(float64(p.Limit) / float64(l) * float64(time.Second))
+	}
+	return p
+}
+
+// String formats p as a policy item, the window in whole seconds
+func (p QuotaPolicy) String() string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(p.Limit))
+	b.WriteString(";w=")
+	b.WriteString(strconv.FormatInt(seconds(p.Window), 10))
+	if p.Comment != "" {
+		b.WriteString(";comment=")
+		b.WriteString(strconv.Quote(p.Comment))
+	}
+	return b.String()
+}
+
+// Policy formats policies as a RateLimit-Policy field value
+func Policy(policies ...QuotaPolicy) string {
+	items := make([]string, len(policies))
+	for i, p := range policies {
+		items[i] = p.String()
+	}
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,44 @@
+package httplimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestSetHeaders(t *testing.T) {
+	now := time.Now()
+	h := make(http.Header)
+	SetHeaders(h, rateflow.Result{Limit: 10, Remaining: 0, ResetAt: now.Add(1500 * time.Millisecond), RetryAfter: 100 * time.Millisecond}, now)
+	for name, want := range map[string]string{
+		HeaderLimit:     "10",
+		HeaderRemaining: "0",
+		HeaderReset:     "2",
+		"Retry-After":   "1",
+	} {
+		if got := h.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	h = make(http.Header)
+	SetHeaders(h, rateflow.Result{Limit: 1, RetryAfter: rateflow.InfDuration}, now)
+	if h.Get("Retry-After") != "" || h.Get(HeaderReset) != "" {
+		t.Errorf("headers = %v, want no Retry-After or Reset for a limiter that never refills", h)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	got := Policy(
+		QuotaPolicy{Limit: 100, Window: time.Minute},
+		QuotaPolicy{Limit: 5000, Window: time.Hour, Comment: "hourly"},
+	)
+	if want := `100;w=60, 5000;w=3600;comment="hourly"`; got != want {
+		t.Errorf("Policy = %s, want %s", got, want)
+	}
+	if got := PolicyOf(rateflow.NewLimiter(rateflow.TokenBucket, 10, 100)).String(); got != "100;w=10" {
+		t.Errorf("PolicyOf = %s, want 100;w=10", got)
+	}
+}
@@ -0,0 +1,153 @@
+// Package httplimit limits net/http servers and clients. Middleware admits each
+// request on a limiter picked for it, answers refused requests with 429
+// Too Many Requests, and describes the limiter's state to clients with
+// the RateLimit header fields of draft-ietf-httpapi-ratelimit-headers:
+//
+//	users := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, 10, 100)
+//	mw := httplimit.Middleware(httplimit.PerKey(users, httplimit.ByIP),
+//		httplimit.WithPolicy(httplimit.QuotaPolicy{Limit: 100, Window: 10 * time.Second}))
+//	http.ListenAndServe(":8080", mw(mux))
+//
+// On the client side, Transport waits on a limiter before each request,
+// and with Adaptive slows down when servers answer 429
+package httplimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// Lookup returns the limiter a request is admitted on, or nil to let it
+// through unlimited
+type Lookup func(r *http.Request) rateflow.Limiter
+
+// Global admits every request on lim
+func Global(lim rateflow.Limiter) Lookup {
+	return func(*http.Request) rateflow.Limiter { return lim }
+}
+
+// PerKey admits each request on the limiter of its key in k, as returned
+// by KeyLimiter. The headers describe that limiter alone
+func PerKey(k *rateflow.Keyed[string], key KeyFunc) Lookup {
+	return func(r *http.Request) rateflow.Limiter { return KeyLimiter(k, key(r)) }
+}
+
+// KeyLimiter returns the limiter of key in k with its Allow, AllowDetails
+// and Wait methods deciding through k, so the allowlist, denylist, parent
+// and burst scaling set on k apply
+func KeyLimiter(k *rateflow.Keyed[string], key string) rateflow.Limiter {
+	return &keyLimiter{Limiter: k.Get(key), keyed: k, key: key}
+}
+
+type keyLimiter struct {
+	rateflow.Limiter
+	keyed *rateflow.Keyed[string]
+	key   string
+}
+
+func (l *keyLimiter) Unwrap() rateflow.Limiter {
+	return l.Limiter
+}
+
+func (l *keyLimiter) Allow() bool {
+	return l.keyed.AllowKey(l.key)
+}
+
+func (l *keyLimiter) AllowN(t time.Time, n int) bool {
+	return l.keyed.AllowNKey(l.key, t, n)
+}
+
+func (l *keyLimiter) AllowDetails(n int) (bool, rateflow.Result) {
+	return l.keyed.AllowDetailsKey(l.key, n)
+}
+
+func (l *keyLimiter) AllowDetailsAt(t time.Time, n int) (bool, rateflow.Result) {
+	return l.keyed.AllowDetailsAtKey(l.key, t, n)
+}
+
+func (l *keyLimiter) Wait(ctx context.Context) error {
+	return l.keyed.WaitKey(ctx, l.key)
+}
+
+func (l *keyLimiter) WaitN(ctx context.Context, n int) error {
+	return l.keyed.WaitNKey(ctx, l.key, n)
+}
+
+// KeyFunc returns the key a request is limited by
+type KeyFunc func(r *http.Request) string
+
+// ByIP keys requests by the host of their remote address. Behind a proxy
+// every request has the proxy's address; key by a header the proxy sets
+// instead
+func ByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ByHeader keys requests by the value of a header, e.g. an API key
+func ByHeader(name string) KeyFunc {
+	return func(r *http.Request) string { return r.Header.Get(name) }
+}
+
+type config struct {
+	policy string
+	deny   DenyHandler
+}
+
+// Option configures Middleware
+type Option func(*config)
+
+// WithPolicy sends policies in a RateLimit-Policy field with every
+// limited response, e.g. built with PolicyOf
+func WithPolicy(policies ...QuotaPolicy) Option {
+	return func(c *config) {
+		c.policy = Policy(policies...)
+	}
+}
+
+// WithDenyHandler writes refused requests' responses with h instead of
+// a plain 429
+func WithDenyHandler(h DenyHandler) Option {
+	return func(c *config) {
+		c.deny = h
+	}
+}
+
+// Middleware admits each request on the limiter lookup returns for it.
+// Admitted requests go to the next handler; refused ones get 429 with
+// Retry-After, or whatever the DenyHandler set with WithDenyHandler
+// writes. Both carry the RateLimit fields set by SetHeaders
+func Middleware(lookup Lookup, opts ...Option) func(http.Handler) http.Handler {
+	cfg := config{deny: plainDeny}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lim := lookup(r)
+			if lim == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			now := limiter.NowOf(lim)
+			ok, res := lim.AllowDetailsAt(now, 1)
+			SetHeaders(w.Header(), res, now)
+			if cfg.policy != "" {
+				w.Header().Set(HeaderPolicy, cfg.policy)
+			}
+			if !ok {
+				cfg.deny.Deny(w, r, res)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
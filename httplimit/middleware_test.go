@@ -0,0 +1,97 @@
+package httplimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestMiddleware(t *testing.T) {
+	users := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, rateflow.Every(time.Minute), 2)
+	h := Middleware(PerKey(users, ByHeader("X-Api-Key")), WithPolicy(QuotaPolicy{Limit: 2, Window: 2 * time.Minute}))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Api-Key", key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	for i, want := range []string{"1", "0"} {
+		rec := serve("alice")
+		if rec.Code != http.StatusOK || rec.Header().Get(HeaderRemaining) != want {
+			t.Fatalf("request %d: %d with %v, want 200 and %s remaining", i, rec.Code, rec.Header(), want)
+		}
+	}
+	rec := serve("alice")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "60" || rec.Header().Get(HeaderPolicy) != "2;w=120" {
+		t.Errorf("headers = %v, want Retry-After 60 and the policy", rec.Header())
+	}
+	if serve("bob").Code != http.StatusOK {
+		t.Error("expected keys to be limited separately")
+	}
+}
+
+func TestMiddlewareKeyedLists(t *testing.T) {
+	users := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, 10, 10)
+	users.SetDenylist(rateflow.NewKeyList("1.2.3.4"))
+	h := Middleware(PerKey(users, ByIP))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(ip string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if serve("1.2.3.4") != http.StatusTooManyRequests {
+		t.Error("expected a denylisted IP to be refused")
+	}
+
+	users.SetParent(rateflow.NewLimiter(rateflow.TokenBucket, 0.001, 1))
+	if serve("5.6.7.8") != http.StatusOK || serve("9.9.9.9") != http.StatusTooManyRequests {
+		t.Error("expected the parent to limit every IP together")
+	}
+}
+
+func TestMiddlewareUnlimited(t *testing.T) {
+	h := Middleware(func(*http.Request) rateflow.Limiter { return nil })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get(HeaderLimit) != "" {
+		t.Errorf("got %d with %v, want an unlimited request passed through", rec.Code, rec.Header())
+	}
+}
+
+func TestByIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "203.0.113.7:5123"
+	if got := ByIP(req); got != "203.0.113.7" {
+		t.Errorf("ByIP = %q", got)
+	}
+}
+
+func TestMiddlewareDenyHandler(t *testing.T) {
+	lim := rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Every(time.Minute), 1)
+	redirect := DenyHandlerFunc(func(w http.ResponseWriter, r *http.Request, res rateflow.Result) {
+		http.Redirect(w, r, "/slow-down", http.StatusSeeOther)
+	})
+	h := Middleware(Global(lim), WithDenyHandler(redirect))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/slow-down" {
+		t.Errorf("got %d to %q, want the deny handler's redirect", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec.Header().Get("Retry-After") != "60" {
+		t.Errorf("Retry-After = %q, want it set before the deny handler", rec.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,103 @@
+package httplimit
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// Routes gives routes their own limits from one declaration:
+//
+//	routes := httplimit.NewRoutes().
+//		Limit("POST /login", rateflow.TokenBucket, rateflow.Every(12*time.Second), 5).
+//		Limit("GET /search", rateflow.TokenBucket, 50, 50).
+//		Limit("/export", rateflow.FixedWindow, rateflow.Every(time.Minute), 1)
+//	mw := httplimit.Middleware(routes.Lookup(httplimit.ByIP))
+//
+// A request is matched by its method and route with two map lookups,
+// however many routes there are; requests no route matches are not
+// limited. Routes are matched on r.URL.Path unless WithPattern supplies
+// the router's pattern, e.g. chi's RoutePattern, so /users/{id} is one
+// route rather than one per user
+type Routes struct {
+	routes  map[string]*rateflow.Keyed[string]
+	pattern func(r *http.Request) string
+}
+
+// NewRoutes creates an empty set of routes
+func NewRoutes() *Routes {
+	return &Routes{routes: make(map[string]*rateflow.Keyed[string])}
+}
+
+// Limit limits requests to pattern, "METHOD /path" or "/path" for every
+// method, with a limiter of algo at rate r and burst b for each key.
+// A method's route wins over the route for every method. Like
+// http.ServeMux, it panics on a duplicate route or a configuration
+// rateflow would reject with a ConfigError, so mistakes surface when the
+// routes are declared
+func (rs *Routes) Limit(pattern string, algo rateflow.Algorithm, r rateflow.Limit, b int, opts ...rateflow.Option) *Routes {
+	route := routeKey(pattern)
+	if _, ok := rs.routes[route]; ok {
+		panic(fmt.Sprintf("httplimit: route %q declared twice", pattern))
+	}
+	if err := limiter.Validate(algo, r, b); err != nil {
+		panic(fmt.Sprintf("httplimit: route %q: %v", pattern, err))
+	}
+	rs.routes[route] = rateflow.NewKeyedLimiter[string](algo, r, b, opts...)
+	return rs
+}
+
+// routeKey normalizes "METHOD /path" to "METHOD /path" with one space and
+// an upper case method, and keeps "/path" as it is
+func routeKey(pattern string) string {
+	method, path, ok := strings.Cut(strings.TrimSpace(pattern), " ")
+	if !ok {
+		return method
+	}
+	return strings.ToUpper(method) + " " + strings.TrimSpace(path)
+}
+
+// WithPattern matches routes on what fn returns for a request instead of
+// its path
+func (rs *Routes) WithPattern(fn func(r *http.Request) string) *Routes {
+	rs.pattern = fn
+	return rs
+}
+
+// Route returns the keyed limiters of a declared route, e.g. to read
+// their stats, or nil
+func (rs *Routes) Route(pattern string) *rateflow.Keyed[string] {
+	return rs.routes[routeKey(pattern)]
+}
+
+// Lookup returns a Lookup admitting each request on its route's limiter
+// for key(r). A nil key gives every route a single limiter shared by all
+// clients
+func (rs *Routes) Lookup(key KeyFunc) Lookup {
+	return func(r *http.Request) rateflow.Limiter {
+		path := r.URL.Path
+		if rs.pattern != nil {
+			path = rs.pattern(r)
+		}
+		k := ""
+		if key != nil {
+			k = key(r)
+		}
+		return rs.Limiter(r.Method, path, k)
+	}
+}
+
+// Limiter returns the KeyLimiter of key on the route matching method
+// and path, or nil if no route does, for routers other than net/http
+func (rs *Routes) Limiter(method, path, key string) rateflow.Limiter {
+	k, ok := rs.routes[method+" "+path]
+	if !ok {
+		if k, ok = rs.routes[path]; !ok {
+			return nil
+		}
+	}
+	return KeyLimiter(k, key)
+}
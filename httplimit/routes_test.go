@@ -0,0 +1,72 @@
+package httplimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestRoutes(t *testing.T) {
+	routes := NewRoutes().
+		Limit("post /login", rateflow.TokenBucket, rateflow.Every(time.Minute), 1).
+		Limit("/search", rateflow.TokenBucket, rateflow.Every(time.Minute), 2)
+	h := Middleware(routes.Lookup(ByIP))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(method, path, ip string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if serve("POST", "/login", "10.0.0.1") != 200 || serve("POST", "/login", "10.0.0.1") != 429 {
+		t.Error("expected one login per minute")
+	}
+	if serve("POST", "/login", "10.0.0.2") != 200 {
+		t.Error("expected clients to be limited separately")
+	}
+	for i := 0; i < 5; i++ {
+		if serve("GET", "/login", "10.0.0.1") != 200 {
+			t.Fatal("expected GET /login not to be limited")
+		}
+	}
+	if serve("GET", "/search", "10.0.0.1") != 200 || serve("POST", "/search", "10.0.0.1") != 200 || serve("GET", "/search", "10.0.0.1") != 429 {
+		t.Error("expected every method on /search to share two requests per minute")
+	}
+	if routes.Route("POST /login").Len() != 2 {
+		t.Errorf("Route(POST /login).Len = %d, want 2 clients", routes.Route("POST /login").Len())
+	}
+}
+
+func TestRoutesPattern(t *testing.T) {
+	routes := NewRoutes().
+		Limit("GET /users/{id}", rateflow.TokenBucket, rateflow.Every(time.Minute), 1).
+		WithPattern(func(r *http.Request) string { return "/users/{id}" })
+	lookup := routes.Lookup(nil)
+	a := lookup(httptest.NewRequest("GET", "/users/1", nil))
+	b := lookup(httptest.NewRequest("GET", "/users/2", nil))
+	if a == nil || !a.Allow() || b.Allow() {
+		t.Error("expected both users to share the route's limiter")
+	}
+}
+
+func TestRoutesPanics(t *testing.T) {
+	for name, declare := range map[string]func(){
+		"duplicate": func() {
+			NewRoutes().Limit("GET /a", rateflow.TokenBucket, 1, 1).Limit("get  /a", rateflow.TokenBucket, 1, 1)
+		},
+		"bad limit": func() { NewRoutes().Limit("/a", rateflow.TokenBucket, -1, 1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			declare()
+		}()
+	}
+}
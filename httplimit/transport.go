@@ -0,0 +1,96 @@
+package httplimit
+
+import (
+	"net/http"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// Transport is an http.RoundTripper that waits on limiters before each
+// request, to stay within the limits of third-party APIs:
+//
+//	client := &http.Client{Transport: httplimit.NewTransport(nil, rateflow.NewLimiter(rateflow.TokenBucket, 10, 1))}
+type Transport struct {
+	base  http.RoundTripper
+	lim   rateflow.Limiter
+	hosts *rateflow.Keyed[string]
+	adapt *adaptive
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// TransportOption configures a Transport
+type TransportOption func(*Transport)
+
+// PerHost also waits on the limiter of each request's host in k, e.g. so
+// one slow API does not hold back calls to the others. lim may then be
+// nil to limit by host only
+func PerHost(k *rateflow.Keyed[string]) TransportOption {
+	return func(t *Transport) {
+		t.hosts = k
+	}
+}
+
+// NewTransport wraps rt, http.DefaultTransport if nil, to wait on lim
+// before each request
+func NewTransport(rt http.RoundTripper, lim rateflow.Limiter, opts ...TransportOption) *Transport {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	t := &Transport{base: rt, lim: lim}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// RoundTrip waits until the request is allowed, or its context is done,
+// then sends it on the wrapped transport
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	lims := t.limiters(req)
+	if err := t.wait(req, lims); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	if t.adapt == nil {
+		return t.base.RoundTrip(req)
+	}
+	sent := t.adapt.cfg.Clock.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		t.adapt.throttled(lims, sent, resp.Header)
+	}
+	return resp, err
+}
+
+// limiters returns the limiters req waits on
+func (t *Transport) limiters(req *http.Request) []rateflow.Limiter {
+	var lims []rateflow.Limiter
+	if t.lim != nil {
+		lims = append(lims, t.lim)
+	}
+	if t.hosts != nil {
+		lims = append(lims, t.hosts.Get(req.URL.Host))
+	}
+	return lims
+}
+
+func (t *Transport) wait(req *http.Request, lims []rateflow.Limiter) error {
+	ctx := req.Context()
+	if t.adapt != nil {
+		if err := t.adapt.before(ctx, lims); err != nil {
+			return err
+		}
+	}
+	if t.lim != nil {
+		if err := t.lim.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if t.hosts != nil {
+		return t.hosts.WaitKey(ctx, req.URL.Host)
+	}
+	return nil
+}
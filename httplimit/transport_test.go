@@ -0,0 +1,63 @@
+package httplimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestTransport(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	lim := rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Every(time.Hour), 2)
+	client := &http.Client{Transport: NewTransport(nil, lim)}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	var rle *rateflow.RateLimitError
+	if _, err := client.Do(req); !errors.As(err, &rle) {
+		t.Errorf("Do = %v, want a RateLimitError", err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("server got %d requests, want 2", hits.Load())
+	}
+}
+
+func TestTransportPerHost(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	hosts := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, rateflow.Every(time.Hour), 1)
+	client := &http.Client{Transport: NewTransport(nil, nil, PerHost(hosts))}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	if hosts.Get(req.URL.Host).Tokens() >= 1 {
+		t.Error("expected the request to use its host's token")
+	}
+	if hosts.Len() != 1 {
+		t.Errorf("Len = %d, want one limiter for the host", hosts.Len())
+	}
+}
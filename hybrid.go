@@ -0,0 +1,172 @@
+package rateflow
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// Hybrid admits events for one key against a local limiter and charges
+// them to a RemoteLimiter in the background, so the hot path never waits
+// on the store while the limit still holds across replicas over time.
+// Each Sync pushes the events admitted since the last one; if the store
+// reports the shared limit overspent, admission pauses until the debt is
+// paid back. A burst of up to the local limit per replica can get through
+// between syncs, so the limit is global only roughly
+type Hybrid struct {
+	local  Limiter
+	remote RemoteLimiter
+	key    string
+
+	pending atomic.Int64 // events admitted locally, not yet charged remotely
+	paused  atomic.Int64 // UnixNano admission is paused until, 0 if none
+
+	syncMu sync.Mutex
+}
+
+// NewHybrid creates a hybrid limiter for key. local is usually sized
+// like the shared limit, e.g. NewLimiter(TokenBucket, r, b) for a remote
+// bucket of rate r and burst b, so a replica alone can use all of it.
+// Call Run, or Sync periodically, to reconcile with remote
+func NewHybrid(local Limiter, remote RemoteLimiter, key string) *Hybrid {
+	return &Hybrid{local: local, remote: remote, key: key}
+}
+
+// Local returns the limiter that admits events between syncs
+func (h *Hybrid) Local() Limiter {
+	return h.local
+}
+
+// PausedUntil returns when admission resumes after the store reported
+// the shared limit overspent, or the zero time if it is not paused
+func (h *Hybrid) PausedUntil() time.Time {
+	if p := h.paused.Load(); p != 0 {
+		if until := time.Unix(0, p); until.After(limiter.NowOf(h.local)) {
+			return until
+		}
+	}
+	return time.Time{}
+}
+
+// Pending returns the number of events admitted since the last Sync
+func (h *Hybrid) Pending() int {
+	return int(h.pending.Load())
+}
+
+// Allow is shorthand for AllowN(time.Now(), 1), on the local limiter's
+// clock
+func (h *Hybrid) Allow() bool {
+	return h.AllowN(limiter.NowOf(h.local), 1)
+}
+
+// AllowN reports whether n events may happen at t, without contacting
+// the store
+func (h *Hybrid) AllowN(t time.Time, n int) bool {
+	if t.UnixNano() < h.paused.Load() {
+		return false
+	}
+	if !h.local.AllowN(t, n) {
+		return false
+	}
+	h.pending.Add(int64(n))
+	return true
+}
+
+// Wait is shorthand for WaitN(ctx, 1)
+func (h *Hybrid) Wait(ctx context.Context) error {
+	return h.WaitN(ctx, 1)
+}
+
+// WaitN blocks until a pause ends and the local limiter admits n events,
+// or ctx is done. It fails fast with a RateLimitError if the pause would
+// outlast ctx's deadline
+func (h *Hybrid) WaitN(ctx context.Context, n int) error {
+	if until := h.PausedUntil(); !until.IsZero() {
+		if d := until.Sub(limiter.NowOf(h.local)); d > 0 {
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+				return &RateLimitError{RetryAfter: d}
+			}
+			timer := time.NewTimer(d)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+	}
+	if err := h.local.WaitN(ctx, n); err != nil {
+		return err
+	}
+	h.pending.Add(int64(n))
+	return nil
+}
+
+// Sync charges the events admitted since the last call to the store, in
+// reservations of at most the local burst, and pauses admission if the
+// store is in debt. Events the store refuses for now, or that a failed
+// call did not charge, stay pending for the next Sync
+func (h *Hybrid) Sync(ctx context.Context) error {
+	h.syncMu.Lock()
+	defer h.syncMu.Unlock()
+
+	n := int(h.pending.Swap(0))
+	now := limiter.NowOf(h.local)
+	var until time.Time
+	var err error
+	for n > 0 {
+		chunk := n
+		if b := h.local.Burst(); b > 0 && chunk > b {
+			chunk = b
+		}
+		var r *RemoteReservation
+		if r, err = h.remote.ReserveN(ctx, h.key, now, chunk); err != nil {
+			break
+		}
+		if r.OK() {
+			if r.TimeToAct().After(until) {
+				until = r.TimeToAct()
+			}
+		} else if retry := r.RetryAt(); !retry.IsZero() {
+			// A store that cannot schedule future events takes the rest
+			// once it has room again
+			if retry.After(until) {
+				until = retry
+			}
+			break
+		}
+		// Chunks the store can never admit are dropped rather than
+		// retried forever
+		n -= chunk
+	}
+	h.pending.Add(int64(n))
+
+	if until.After(now) {
+		for {
+			p := h.paused.Load()
+			if p >= until.UnixNano() || h.paused.CompareAndSwap(p, until.UnixNano()) {
+				break
+			}
+		}
+	}
+	return err
+}
+
+// Run calls Sync every interval until ctx is done, then returns
+// ctx.Err(). Failed syncs are retried at the next tick while the local
+// limiter keeps serving, so an unreachable store only loosens the limit
+func (h *Hybrid) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			h.Sync(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
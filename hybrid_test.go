@@ -0,0 +1,150 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// limiterRemote serves a RemoteLimiter from a local limiter, standing in
+// for a store shared by several Hybrid replicas
+type limiterRemote struct {
+	lim Limiter
+	err error
+}
+
+func (s *limiterRemote) AllowN(ctx context.Context, key string, t time.Time, n int) (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+	return s.lim.AllowN(t, n), nil
+}
+
+func (s *limiterRemote) ReserveN(ctx context.Context, key string, t time.Time, n int) (*RemoteReservation, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	r := s.lim.ReserveN(t, n)
+	return NewRemoteReservation(r.OK(), r.TimeToAct(), n, nil), nil
+}
+
+func TestHybrid(t *testing.T) {
+	clock := newFakeClock()
+	remote := &limiterRemote{lim: NewLimiterWithOptions(TokenBucket, 10, 10, WithClock(clock))}
+	ctx := context.Background()
+
+	newReplica := func() *Hybrid {
+		return NewHybrid(NewLimiterWithOptions(TokenBucket, 10, 10, WithClock(clock)), remote, "k")
+	}
+	a, b := newReplica(), newReplica()
+
+	now := clock.Now()
+	for i := 0; i < 10; i++ {
+		if !a.Allow() || !b.Allow() {
+			t.Fatalf("event %d: each replica should admit its local burst", i)
+		}
+	}
+	if a.Pending() != 10 {
+		t.Errorf("Pending = %d, want 10", a.Pending())
+	}
+
+	if err := a.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if a.Pending() != 0 || b.Pending() != 0 {
+		t.Error("Sync should charge every pending event")
+	}
+	if !a.PausedUntil().IsZero() {
+		t.Error("the first replica to sync fit in the shared burst")
+	}
+	if want := now.Add(time.Second); b.PausedUntil().Sub(want).Abs() > time.Millisecond {
+		t.Errorf("PausedUntil = %v, want %v to pay back the overspent burst", b.PausedUntil(), want)
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if b.Allow() {
+		t.Error("a paused replica should refuse even with local tokens")
+	}
+	if !a.Allow() {
+		t.Error("the other replica should keep serving")
+	}
+	clock.Advance(501 * time.Millisecond)
+	if !b.Allow() {
+		t.Error("the pause should end once the debt is paid")
+	}
+	if !b.PausedUntil().IsZero() {
+		t.Errorf("PausedUntil = %v after the pause ended, want zero", b.PausedUntil())
+	}
+}
+
+func TestHybridSyncFailures(t *testing.T) {
+	clock := newFakeClock()
+	remote := &limiterRemote{lim: NewLimiterWithOptions(TokenBucket, 10, 10, WithClock(clock)), err: errors.New("store down")}
+	h := NewHybrid(NewLimiterWithOptions(TokenBucket, 100, 100, WithClock(clock)), remote, "k")
+	ctx := context.Background()
+
+	h.AllowN(clock.Now(), 25)
+	if err := h.Sync(ctx); err == nil {
+		t.Fatal("expected the store error")
+	}
+	if h.Pending() != 25 {
+		t.Errorf("Pending = %d, want the uncharged events kept", h.Pending())
+	}
+
+	// 25 events are charged in chunks of at most the local burst, which
+	// the remote bucket of 10 can never admit, so they are dropped
+	remote.err = nil
+	if err := h.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if h.Pending() != 0 {
+		t.Errorf("Pending = %d, want unadmittable chunks dropped", h.Pending())
+	}
+
+	stub := &windowStub{}
+	w := NewHybrid(NewLimiterWithOptions(TokenBucket, 100, 1, WithClock(clock)), stub, "k")
+	w.Allow()
+	w.Sync(ctx)
+	clock.Advance(10 * time.Millisecond)
+	w.Allow()
+	w.Sync(ctx)
+	if w.Pending() != 1 {
+		t.Errorf("Pending = %d, want the refused event kept for later", w.Pending())
+	}
+	if want := clock.Now().Add(10 * time.Millisecond); w.PausedUntil().Sub(want).Abs() > time.Millisecond {
+		t.Errorf("PausedUntil = %v, want %v", w.PausedUntil(), want)
+	}
+}
+
+func TestHybridWait(t *testing.T) {
+	remote := &limiterRemote{lim: NewLimiter(TokenBucket, 10, 1)}
+	h := NewHybrid(NewLimiter(TokenBucket, 1000, 1), remote, "k")
+	ctx := context.Background()
+
+	h.Allow()
+	h.Sync(ctx)
+	time.Sleep(2 * time.Millisecond)
+	h.Allow()
+	h.Sync(ctx)
+	if h.PausedUntil().IsZero() {
+		t.Fatal("expected the second event to overspend the shared limit")
+	}
+
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	var rle *RateLimitError
+	if err := h.Wait(short); !errors.As(err, &rle) {
+		t.Errorf("Wait = %v, want a RateLimitError", err)
+	}
+	start := time.Now()
+	if err := h.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("waited %v, want the pause to be honored", waited)
+	}
+}
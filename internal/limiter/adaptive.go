@@ -0,0 +1,163 @@
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// adaptiveTolerance is how much the short-term latency may exceed the
+	// long-term baseline before the limit starts shrinking
+	adaptiveTolerance = 1.5
+	// adaptiveSmoothing blends each new limit estimate into the current one
+	adaptiveSmoothing = 0.2
+	// adaptiveLongWindow is the number of samples in the baseline average
+	adaptiveLongWindow = 600
+	// adaptiveBackoff is the multiplicative decrease applied on drops
+	adaptiveBackoff = 0.9
+)
+
+// AdaptiveLimiter is a token bucket whose rate follows observed latency,
+// in the style of Netflix's concurrency-limits Gradient2 limiter
+// Each Observe call compares the sample against a long-term baseline:
+// rising latency shrinks the effective limit, steady latency lets it grow
+// back towards the configured ceiling, and drops back off multiplicatively
+type AdaptiveLimiter struct {
+	*TokenBucketLimiter
+
+	mu       sync.Mutex
+	minLimit Limit
+	maxLimit Limit
+	longRTT  float64
+}
+
+// NewAdaptive creates a new adaptive limiter. r is the ceiling and the
+// starting point of the effective limit
+func NewAdaptive(r Limit, b int) *AdaptiveLimiter {
+	minLimit := Limit(1)
+	if r < minLimit {
+		minLimit = r
+	}
+	return &AdaptiveLimiter{
+		TokenBucketLimiter: NewTokenBucket(r, b),
+		minLimit:           minLimit,
+		maxLimit:           r,
+	}
+}
+
+func (a *AdaptiveLimiter) Algorithm() Algorithm {
+	return Adaptive
+}
+
+// Bounds returns the range the effective limit is kept within
+func (a *AdaptiveLimiter) Bounds() (min, max Limit) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.minLimit, a.maxLimit
+}
+
+// SetBounds changes the range the effective limit is kept within
+func (a *AdaptiveLimiter) SetBounds(min, max Limit) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if min > max {
+		min = max
+	}
+	a.minLimit = min
+	a.maxLimit = max
+	a.apply(float64(a.TokenBucketLimiter.Limit()))
+}
+
+func (a *AdaptiveLimiter) SetLimit(newLimit Limit) {
+	a.SetLimitAt(a.now(), newLimit)
+}
+
+// SetLimitAt changes the ceiling and resets the effective limit to it
+func (a *AdaptiveLimiter) SetLimitAt(t time.Time, newLimit Limit) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.maxLimit = newLimit
+	if a.minLimit > newLimit {
+		a.minLimit = newLimit
+	}
+	a.TokenBucketLimiter.SetLimitAt(t, newLimit)
+}
+
+// RampLimit ramps the ceiling; the effective limit keeps following latency
+// within it
+func (a *AdaptiveLimiter) RampLimit(target Limit, over time.Duration) {
+	ceiling := func() Limit {
+		_, max := a.Bounds()
+		return max
+	}
+	a.rampLimit(ceiling(), target, over, ceiling, a.SetLimitAt)
+}
+
+func (a *AdaptiveLimiter) Reset() {
+	a.ResetTo(a.now())
+}
+
+// ResetTo also forgets the latency baseline and returns the effective
+// limit to the ceiling
+func (a *AdaptiveLimiter) ResetTo(t time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.longRTT = 0
+	a.TokenBucketLimiter.SetLimitAt(t, a.maxLimit)
+	a.TokenBucketLimiter.ResetTo(t)
+}
+
+// Clone starts the clone at the ceiling with no latency baseline
+func (a *AdaptiveLimiter) Clone() Limiter {
+	a.mu.Lock()
+	c := &AdaptiveLimiter{
+		TokenBucketLimiter: a.TokenBucketLimiter.clone(),
+		minLimit:           a.minLimit,
+		maxLimit:           a.maxLimit,
+	}
+	a.mu.Unlock()
+	c.Reset()
+	return c
+}
+
+// Observe feeds one latency sample into the limiter. dropped reports that
+// the request failed or timed out, which backs the limit off immediately
+func (a *AdaptiveLimiter) Observe(latency time.Duration, dropped bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	current := float64(a.TokenBucketLimiter.Limit())
+
+	if dropped {
+		a.apply(current * adaptiveBackoff)
+		return
+	}
+
+	rtt := latency.Seconds()
+	if rtt <= 0 {
+		return
+	}
+
+	if a.longRTT == 0 {
+		a.longRTT = rtt
+	} else {
+		alpha := 2.0 / (adaptiveLongWindow + 1)
+		a.longRTT = a.longRTT*(1-alpha) + rtt*alpha
+	}
+
+	// Let the baseline recover quickly once latency drops well below it
+	if a.longRTT/rtt > 2 {
+		a.longRTT *= 0.95
+	}
+
+	gradient := math.Max(0.5, math.Min(1, adaptiveTolerance*a.longRTT/rtt))
+	estimate := current*gradient + math.Sqrt(current)
+	a.apply(current*(1-adaptiveSmoothing) + estimate*adaptiveSmoothing)
+}
+
+// apply clamps next to the bounds and makes it the effective limit
+func (a *AdaptiveLimiter) apply(next float64) {
+	next = math.Max(float64(a.minLimit), math.Min(float64(a.maxLimit), next))
+	a.TokenBucketLimiter.SetLimit(Limit(next))
+}
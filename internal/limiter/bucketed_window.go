@@ -0,0 +1,420 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// BucketedWindowLimiter implements the sliding window algorithm with a
+// fixed number of sub-window counters instead of a timestamp per request
+// Memory is bounded by the bucket count; the oldest bucket is weighted by
+// how much of it still overlaps the window, so precision improves with
+// more buckets
+type BucketedWindowLimiter struct {
+	base
+	mu       sync.Mutex
+	maxCount int
+	window   time.Duration
+	width    time.Duration
+	counts   []int // ring of len(buckets)+1 slots, one past the window
+	head     int64 // index of the bucket containing the latest update
+}
+
+// NewBucketedWindow creates a sliding window of the given duration split
+// into buckets sub-windows
+func NewBucketedWindow(maxCount int, window time.Duration, buckets int) *BucketedWindowLimiter {
+	if window <= 0 {
+		window = time.Second
+	}
+	if buckets < 1 {
+		buckets = 1
+	}
+	width := window / time.Duration(buckets)
+	if width <= 0 {
+		width = 1
+	}
+
+	return &BucketedWindowLimiter{
+		maxCount: maxCount,
+		window:   window,
+		width:    width,
+		counts:   make([]int, buckets+1),
+		head:     time.Now().UnixNano() / int64(width),
+	}
+}
+
+func (bw *BucketedWindowLimiter) Algorithm() Algorithm {
+	return SlidingWindow
+}
+
+func (bw *BucketedWindowLimiter) Capabilities() Capabilities {
+	return Capabilities{
+		SupportsTokens:      false,
+		SupportsBurst:       false,
+		SupportsReservation: false,
+	}
+}
+
+func (bw *BucketedWindowLimiter) Stats() Stats {
+	return bw.stats(bw.Tokens())
+}
+
+// Configure applies cfg; a window keeps the number of buckets
+func (bw *BucketedWindowLimiter) Configure(cfg Config) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	bw.configure(cfg)
+	if cfg.Window > 0 {
+		buckets := len(bw.counts) - 1
+		bw.window = cfg.Window
+		bw.width = cfg.Window / time.Duration(buckets)
+		if bw.width <= 0 {
+			bw.width = 1
+		}
+		for i := range bw.counts {
+			bw.counts[i] = 0
+		}
+	}
+	bw.head = bw.now().UnixNano() / int64(bw.width)
+}
+
+func (bw *BucketedWindowLimiter) Reset() {
+	bw.ResetTo(bw.now())
+}
+
+func (bw *BucketedWindowLimiter) ResetTo(t time.Time) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	for i := range bw.counts {
+		bw.counts[i] = 0
+	}
+	bw.head = t.UnixNano() / int64(bw.width)
+}
+
+func (bw *BucketedWindowLimiter) Clone() Limiter {
+	bw.mu.Lock()
+	c := &BucketedWindowLimiter{
+		maxCount: bw.maxCount,
+		window:   bw.window,
+		width:    bw.width,
+		counts:   make([]int, len(bw.counts)),
+	}
+	bw.mu.Unlock()
+	c.inherit(&bw.base)
+	c.Reset()
+	return c
+}
+
+// Window returns the window duration
+func (bw *BucketedWindowLimiter) Window() time.Duration {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.window
+}
+
+// Buckets returns the number of sub-windows
+func (bw *BucketedWindowLimiter) Buckets() int {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return len(bw.counts) - 1
+}
+
+// advance rotates the ring so the head bucket contains now
+func (bw *BucketedWindowLimiter) advance(now time.Time) {
+	id := now.UnixNano() / int64(bw.width)
+	if id <= bw.head {
+		return
+	}
+
+	steps := id - bw.head
+	if steps > int64(len(bw.counts)) {
+		steps = int64(len(bw.counts))
+	}
+	for i := int64(1); i <= steps; i++ {
+		bw.counts[(bw.head+i)%int64(len(bw.counts))] = 0
+	}
+	bw.head = id
+}
+
+// count estimates the number of events in the window ending at now
+func (bw *BucketedWindowLimiter) count(now time.Time) float64 {
+	slots := int64(len(bw.counts))
+	total := 0
+	for i := int64(0); i < slots-1; i++ {
+		total += bw.counts[(bw.head-i+slots)%slots]
+	}
+
+	// Only part of the oldest bucket still overlaps the window
+	elapsed := float64(now.UnixNano()%int64(bw.width)) / float64(bw.width)
+	oldest := bw.counts[(bw.head+1)%slots]
+	return float64(total) + float64(oldest)*(1-elapsed)
+}
+
+func (bw *BucketedWindowLimiter) Allow() bool {
+	return bw.AllowN(bw.now(), 1)
+}
+
+func (bw *BucketedWindowLimiter) AllowN(t time.Time, n int) bool {
+	ok, _ := bw.AllowDetailsAt(t, n)
+	return ok
+}
+
+func (bw *BucketedWindowLimiter) AllowDetails(n int) (bool, Result) {
+	return bw.AllowDetailsAt(bw.now(), n)
+}
+
+// AllowDetailsAt reports RetryAfter as the next bucket boundary, when the
+// estimate next drops; the request may need several boundaries to pass
+func (bw *BucketedWindowLimiter) AllowDetailsAt(t time.Time, n int) (bool, Result) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	ok, res := bw.allowDetails(t, n)
+	bw.record(t, float64(n), ok, deniedAfter(res.RetryAfter))
+	return ok, res
+}
+
+// allowDetails decides n events at t without recording the decision.
+// bw.mu must be held
+func (bw *BucketedWindowLimiter) allowDetails(t time.Time, n int) (bool, Result) {
+	bw.advance(t)
+
+	ok := bw.count(t)+float64(n) <= float64(bw.maxCount)
+	if ok {
+		bw.counts[bw.head%int64(len(bw.counts))] += n
+	}
+
+	remaining, resetAt := bw.headroom(t)
+	res := Result{
+		Limit:     bw.maxCount,
+		Remaining: whole(remaining),
+		ResetAt:   resetAt,
+	}
+	if !ok {
+		if n > bw.maxCount {
+			res.RetryAfter = InfDuration
+		} else {
+			res.RetryAfter = time.Unix(0, (bw.head+1)*int64(bw.width)).Sub(t)
+		}
+	}
+	return ok, res
+}
+
+// reserveQuiet decides n events at t for reserveAll, which records the
+// decision itself
+func (bw *BucketedWindowLimiter) reserveQuiet(t time.Time, n int, maxWait time.Duration) *Reservation {
+	bw.mu.Lock()
+	ok, res := bw.allowDetails(t, n)
+	bw.mu.Unlock()
+	return decided(bw, t, n, ok, res.RetryAfter)
+}
+
+// headroom returns the estimated events left in the window at t and when
+// the newest bucket has aged out. bw.mu must be held and the ring advanced
+func (bw *BucketedWindowLimiter) headroom(t time.Time) (float64, time.Time) {
+	count := bw.count(t)
+	if count == 0 {
+		return float64(bw.maxCount), t
+	}
+	// The newest events age out one full window after their bucket ends
+	resetAt := time.Unix(0, (bw.head+1)*int64(bw.width)).Add(bw.window)
+	return math.Max(0, float64(bw.maxCount)-count), resetAt
+}
+
+func (bw *BucketedWindowLimiter) Remaining() float64 {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	now := bw.now()
+	bw.advance(now)
+	remaining, _ := bw.headroom(now)
+	return remaining
+}
+
+func (bw *BucketedWindowLimiter) ResetAt() time.Time {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	now := bw.now()
+	bw.advance(now)
+	_, resetAt := bw.headroom(now)
+	return resetAt
+}
+
+// Reserve returns a reservation that's either immediate or not OK
+func (bw *BucketedWindowLimiter) Reserve() *Reservation {
+	return bw.ReserveN(bw.now(), 1)
+}
+
+func (bw *BucketedWindowLimiter) ReserveN(t time.Time, n int) *Reservation {
+	if bw.AllowN(t, n) {
+		return &Reservation{
+			ok:        true,
+			lim:       bw,
+			tokens:    float64(n),
+			timeToAct: t,
+			limit:     bw.Limit(),
+		}
+	}
+	return &Reservation{ok: false}
+}
+
+// restore uncounts the reservation if its bucket is still in the ring
+func (bw *BucketedWindowLimiter) restore(t time.Time, r *Reservation) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	if r.tokens == 0 {
+		return
+	}
+	bw.advance(t)
+
+	slots := int64(len(bw.counts))
+	id := r.timeToAct.UnixNano() / int64(bw.width)
+	if id <= bw.head && id > bw.head-slots {
+		slot := id % slots
+		bw.counts[slot] -= int(r.tokens)
+		if bw.counts[slot] < 0 {
+			bw.counts[slot] = 0
+		}
+	}
+	r.tokens = 0
+}
+
+func (bw *BucketedWindowLimiter) Wait(ctx context.Context) error {
+	return bw.WaitN(ctx, 1)
+}
+
+func (bw *BucketedWindowLimiter) WaitN(ctx context.Context, n int) (err error) {
+	start := bw.now()
+	defer func() { bw.recordWait(start, float64(n), err) }()
+
+	bw.mu.Lock()
+	now := bw.now()
+	bw.advance(now)
+
+	if n > bw.maxCount {
+		bw.mu.Unlock()
+		return errExceeds(n, "limit", bw.maxCount)
+	}
+
+	if bw.count(now)+float64(n) > float64(bw.maxCount) {
+		// Wait for the next bucket boundary to release some capacity
+		next := time.Unix(0, (bw.head+1)*int64(bw.width))
+		bw.mu.Unlock()
+
+		if err := bw.sleep(ctx, next.Sub(now)); err != nil {
+			return err
+		}
+		return bw.WaitN(ctx, n) // Retry
+	}
+
+	bw.counts[bw.head%int64(len(bw.counts))] += n
+	bw.mu.Unlock()
+	return nil
+}
+
+func (bw *BucketedWindowLimiter) WaitMaxN(ctx context.Context, n int, maxWait time.Duration) error {
+	return waitMax(ctx, bw, n, maxWait)
+}
+
+func (bw *BucketedWindowLimiter) Limit() Limit {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return Limit(float64(bw.maxCount) / bw.window.Seconds())
+}
+
+func (bw *BucketedWindowLimiter) SetLimit(newLimit Limit) {
+	bw.SetLimitAt(bw.now(), newLimit)
+}
+
+// SetLimitAt keeps the window and adjusts the count allowed within it
+func (bw *BucketedWindowLimiter) SetLimitAt(t time.Time, newLimit Limit) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	bw.advance(t)
+	if newLimit >= 0 {
+		bw.maxCount = int(float64(newLimit) * bw.window.Seconds())
+	}
+}
+
+func (bw *BucketedWindowLimiter) RampLimit(target Limit, over time.Duration) {
+	bw.rampLimit(bw.Limit(), target, over, bw.Limit, bw.SetLimitAt)
+}
+
+func (bw *BucketedWindowLimiter) Burst() int {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.maxCount
+}
+
+func (bw *BucketedWindowLimiter) SetBurst(newBurst int) {
+	bw.SetBurstAt(bw.now(), newBurst)
+}
+
+// SetBurstAt keeps the window and changes the count allowed within it
+func (bw *BucketedWindowLimiter) SetBurstAt(t time.Time, newBurst int) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	bw.advance(t)
+	bw.maxCount = newBurst
+}
+
+// Tokens returns remaining capacity in current window
+func (bw *BucketedWindowLimiter) Tokens() float64 {
+	return bw.TokensAt(bw.now())
+}
+
+func (bw *BucketedWindowLimiter) TokensAt(t time.Time) float64 {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	bw.advance(t)
+	return float64(bw.maxCount) - bw.count(t)
+}
+
+type bucketedWindowState struct {
+	Counts []int `json:"counts"`
+	Head   int64 `json:"head"`
+	Width  int64 `json:"width"`
+}
+
+func (bw *BucketedWindowLimiter) exportState() bucketedWindowState {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bucketedWindowState{Counts: append([]int(nil), bw.counts...), Head: bw.head, Width: int64(bw.width)}
+}
+
+func (bw *BucketedWindowLimiter) importState(s bucketedWindowState) error {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	if len(s.Counts) != len(bw.counts) || s.Width != int64(bw.width) {
+		return fmt.Errorf("%w: bucket layout differs", ErrStateMismatch)
+	}
+	copy(bw.counts, s.Counts)
+	bw.head = s.Head
+	return nil
+}
+
+func (bw *BucketedWindowLimiter) MarshalJSON() ([]byte, error) {
+	return marshalState("BucketedWindow", bw.exportState())
+}
+
+func (bw *BucketedWindowLimiter) UnmarshalJSON(data []byte) error {
+	var s bucketedWindowState
+	if err := unmarshalState("BucketedWindow", data, &s); err != nil {
+		return err
+	}
+	return bw.importState(s)
+}
+
+func (bw *BucketedWindowLimiter) MarshalBinary() ([]byte, error) {
+	return encodeState("BucketedWindow", bw.exportState())
+}
+
+func (bw *BucketedWindowLimiter) UnmarshalBinary(data []byte) error {
+	var s bucketedWindowState
+	if err := decodeState("BucketedWindow", data, &s); err != nil {
+		return err
+	}
+	return bw.importState(s)
+}
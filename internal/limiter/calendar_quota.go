@@ -0,0 +1,422 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// QuotaPeriod is the calendar period after which a quota resets
+type QuotaPeriod int
+
+const (
+	Hourly QuotaPeriod = iota
+	Daily
+	Weekly
+	Monthly
+)
+
+func (p QuotaPeriod) String() string {
+	switch p {
+	case Hourly:
+		return "Hourly"
+	case Daily:
+		return "Daily"
+	case Weekly:
+		return "Weekly"
+	case Monthly:
+		return "Monthly"
+	default:
+		return "Unknown"
+	}
+}
+
+// start returns the beginning of the period containing t
+func (p QuotaPeriod) start(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch p {
+	case Hourly:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location())
+	case Weekly:
+		// Weeks start on Monday
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location())
+	case Monthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// next returns the beginning of the period following the one starting at start
+func (p QuotaPeriod) next(start time.Time) time.Time {
+	switch p {
+	case Hourly:
+		return start.Add(time.Hour)
+	case Weekly:
+		return start.AddDate(0, 0, 7)
+	case Monthly:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// CalendarQuotaLimiter implements a quota that resets on calendar
+// boundaries (midnight, first of the month, ...) in a given location
+// Unlike FixedWindow the periods follow the wall clock, so "N calls per
+// month" means the calendar month, not a rolling 30 days
+type CalendarQuotaLimiter struct {
+	base
+	mu       sync.Mutex
+	quota    int
+	period   QuotaPeriod
+	location *time.Location
+	used     int
+	start    time.Time
+	resetAt  time.Time
+}
+
+// NewCalendarQuota creates a quota of n events per calendar period in loc
+// A nil loc means UTC
+func NewCalendarQuota(n int, period QuotaPeriod, loc *time.Location) *CalendarQuotaLimiter {
+	if loc == nil {
+		loc = time.UTC
+	}
+	cq := &CalendarQuotaLimiter{
+		quota:    n,
+		period:   period,
+		location: loc,
+	}
+	cq.advance(time.Now())
+	return cq
+}
+
+func (cq *CalendarQuotaLimiter) Algorithm() Algorithm {
+	return CalendarQuota
+}
+
+func (cq *CalendarQuotaLimiter) Capabilities() Capabilities {
+	return Capabilities{
+		SupportsTokens:      false,
+		SupportsBurst:       false,
+		SupportsReservation: false,
+	}
+}
+
+func (cq *CalendarQuotaLimiter) Stats() Stats {
+	return cq.stats(cq.Tokens())
+}
+
+// Configure applies cfg and recomputes the current period
+func (cq *CalendarQuotaLimiter) Configure(cfg Config) {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+	cq.configure(cfg)
+	cq.resetAt = time.Time{}
+	cq.advance(cq.now())
+}
+
+func (cq *CalendarQuotaLimiter) Reset() {
+	cq.ResetTo(cq.now())
+}
+
+func (cq *CalendarQuotaLimiter) ResetTo(t time.Time) {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+	cq.used = 0
+	cq.advance(t)
+}
+
+// Clone starts the clone in the current period with the full quota
+func (cq *CalendarQuotaLimiter) Clone() Limiter {
+	cq.mu.Lock()
+	c := &CalendarQuotaLimiter{quota: cq.quota, period: cq.period, location: cq.location}
+	cq.mu.Unlock()
+	c.inherit(&cq.base)
+	c.Reset()
+	return c
+}
+
+// advance starts a new period if now falls outside the current one
+func (cq *CalendarQuotaLimiter) advance(now time.Time) {
+	if !now.Before(cq.start) && now.Before(cq.resetAt) {
+		return
+	}
+	cq.used = 0
+	cq.start = cq.period.start(now.In(cq.location))
+	cq.resetAt = cq.period.next(cq.start)
+}
+
+// Period returns the calendar period of the quota
+func (cq *CalendarQuotaLimiter) Period() QuotaPeriod {
+	return cq.period
+}
+
+// Location returns the time zone in which periods are computed
+func (cq *CalendarQuotaLimiter) Location() *time.Location {
+	return cq.location
+}
+
+// Remaining returns the quota left in the current period
+func (cq *CalendarQuotaLimiter) Remaining() float64 {
+	return cq.RemainingAt(cq.now())
+}
+
+func (cq *CalendarQuotaLimiter) RemainingAt(t time.Time) float64 {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+	cq.advance(t)
+	remaining, _ := cq.headroom(t)
+	return remaining
+}
+
+// ResetAt returns when the current period ends and the quota is restored,
+// or now if none of it has been used
+func (cq *CalendarQuotaLimiter) ResetAt() time.Time {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+	now := cq.now()
+	cq.advance(now)
+	_, resetAt := cq.headroom(now)
+	return resetAt
+}
+
+func (cq *CalendarQuotaLimiter) Allow() bool {
+	return cq.AllowN(cq.now(), 1)
+}
+
+func (cq *CalendarQuotaLimiter) AllowN(t time.Time, n int) bool {
+	ok, _ := cq.AllowDetailsAt(t, n)
+	return ok
+}
+
+func (cq *CalendarQuotaLimiter) AllowDetails(n int) (bool, Result) {
+	return cq.AllowDetailsAt(cq.now(), n)
+}
+
+func (cq *CalendarQuotaLimiter) AllowDetailsAt(t time.Time, n int) (bool, Result) {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+	ok, res := cq.allowDetails(t, n)
+	cq.record(t, float64(n), ok, deniedAfter(res.RetryAfter))
+	return ok, res
+}
+
+// allowDetails decides n events at t without recording the decision.
+// cq.mu must be held
+func (cq *CalendarQuotaLimiter) allowDetails(t time.Time, n int) (bool, Result) {
+	cq.advance(t)
+
+	ok := cq.used+n <= cq.quota
+	if ok {
+		cq.used += n
+	}
+
+	remaining, resetAt := cq.headroom(t)
+	res := Result{
+		Limit:     cq.quota,
+		Remaining: whole(remaining),
+		ResetAt:   resetAt,
+	}
+	if !ok {
+		if n > cq.quota {
+			res.RetryAfter = InfDuration
+		} else {
+			res.RetryAfter = cq.resetAt.Sub(t)
+		}
+	}
+	return ok, res
+}
+
+// reserveQuiet decides n events at t for reserveAll, which records the
+// decision itself
+func (cq *CalendarQuotaLimiter) reserveQuiet(t time.Time, n int, maxWait time.Duration) *Reservation {
+	cq.mu.Lock()
+	ok, res := cq.allowDetails(t, n)
+	cq.mu.Unlock()
+	return decided(cq, t, n, ok, res.RetryAfter)
+}
+
+// headroom returns the quota left at t and when it is restored, or t for
+// an unused quota. cq.mu must be held and the period advanced to t
+func (cq *CalendarQuotaLimiter) headroom(t time.Time) (float64, time.Time) {
+	if cq.used == 0 {
+		return float64(cq.quota), t
+	}
+	return math.Max(0, float64(cq.quota-cq.used)), cq.resetAt
+}
+
+// Reserve returns a reservation that's either immediate or not OK
+func (cq *CalendarQuotaLimiter) Reserve() *Reservation {
+	return cq.ReserveN(cq.now(), 1)
+}
+
+func (cq *CalendarQuotaLimiter) ReserveN(t time.Time, n int) *Reservation {
+	if cq.AllowN(t, n) {
+		return &Reservation{
+			ok:        true,
+			lim:       cq,
+			tokens:    float64(n),
+			timeToAct: t,
+			limit:     cq.Limit(),
+		}
+	}
+	return &Reservation{ok: false}
+}
+
+// restore returns the reservation to the quota if its period is current
+func (cq *CalendarQuotaLimiter) restore(t time.Time, r *Reservation) {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+
+	if r.tokens == 0 {
+		return
+	}
+	cq.advance(t)
+
+	if !r.timeToAct.Before(cq.start) {
+		cq.used -= int(r.tokens)
+		if cq.used < 0 {
+			cq.used = 0
+		}
+	}
+	r.tokens = 0
+}
+
+func (cq *CalendarQuotaLimiter) Wait(ctx context.Context) error {
+	return cq.WaitN(ctx, 1)
+}
+
+func (cq *CalendarQuotaLimiter) WaitN(ctx context.Context, n int) (err error) {
+	start := cq.now()
+	defer func() { cq.recordWait(start, float64(n), err) }()
+
+	cq.mu.Lock()
+	now := cq.now()
+	cq.advance(now)
+
+	if n > cq.quota {
+		cq.mu.Unlock()
+		return errExceeds(n, "quota", cq.quota)
+	}
+
+	if cq.used+n > cq.quota {
+		// Wait for the next period
+		resetAt := cq.resetAt
+		cq.mu.Unlock()
+
+		if err := cq.sleep(ctx, resetAt.Sub(now)); err != nil {
+			return err
+		}
+		return cq.WaitN(ctx, n) // Retry in new period
+	}
+
+	cq.used += n
+	cq.mu.Unlock()
+	return nil
+}
+
+func (cq *CalendarQuotaLimiter) WaitMaxN(ctx context.Context, n int, maxWait time.Duration) error {
+	return waitMax(ctx, cq, n, maxWait)
+}
+
+// Limit returns the average rate allowed over the current period
+func (cq *CalendarQuotaLimiter) Limit() Limit {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+	return Limit(float64(cq.quota) / cq.resetAt.Sub(cq.start).Seconds())
+}
+
+func (cq *CalendarQuotaLimiter) SetLimit(newLimit Limit) {
+	cq.SetLimitAt(cq.now(), newLimit)
+}
+
+// SetLimitAt sets the quota to the number of events newLimit allows over
+// the current period
+func (cq *CalendarQuotaLimiter) SetLimitAt(t time.Time, newLimit Limit) {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+	cq.advance(t)
+	if newLimit >= 0 {
+		cq.quota = int(float64(newLimit)*cq.resetAt.Sub(cq.start).Seconds() + 0.5)
+	}
+}
+
+func (cq *CalendarQuotaLimiter) RampLimit(target Limit, over time.Duration) {
+	cq.rampLimit(cq.Limit(), target, over, cq.Limit, cq.SetLimitAt)
+}
+
+// Burst returns the quota per period
+func (cq *CalendarQuotaLimiter) Burst() int {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+	return cq.quota
+}
+
+func (cq *CalendarQuotaLimiter) SetBurst(newBurst int) {
+	cq.SetBurstAt(cq.now(), newBurst)
+}
+
+// SetBurstAt sets the quota per period
+func (cq *CalendarQuotaLimiter) SetBurstAt(t time.Time, newBurst int) {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+	cq.advance(t)
+	cq.quota = newBurst
+}
+
+// Tokens returns the quota left in the current period
+func (cq *CalendarQuotaLimiter) Tokens() float64 {
+	return cq.TokensAt(cq.now())
+}
+
+func (cq *CalendarQuotaLimiter) TokensAt(t time.Time) float64 {
+	return cq.RemainingAt(t)
+}
+
+type calendarQuotaState struct {
+	Used    int       `json:"used"`
+	Start   time.Time `json:"start"`
+	ResetAt time.Time `json:"reset_at"`
+}
+
+func (cq *CalendarQuotaLimiter) exportState() calendarQuotaState {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+	return calendarQuotaState{Used: cq.used, Start: cq.start, ResetAt: cq.resetAt}
+}
+
+func (cq *CalendarQuotaLimiter) importState(s calendarQuotaState) error {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+	cq.used = s.Used
+	cq.start = s.Start
+	cq.resetAt = s.ResetAt
+	return nil
+}
+
+func (cq *CalendarQuotaLimiter) MarshalJSON() ([]byte, error) {
+	return marshalState("CalendarQuota", cq.exportState())
+}
+
+func (cq *CalendarQuotaLimiter) UnmarshalJSON(data []byte) error {
+	var s calendarQuotaState
+	if err := unmarshalState("CalendarQuota", data, &s); err != nil {
+		return err
+	}
+	return cq.importState(s)
+}
+
+func (cq *CalendarQuotaLimiter) MarshalBinary() ([]byte, error) {
+	return encodeState("CalendarQuota", cq.exportState())
+}
+
+func (cq *CalendarQuotaLimiter) UnmarshalBinary(data []byte) error {
+	var s calendarQuotaState
+	if err := decodeState("CalendarQuota", data, &s); err != nil {
+		return err
+	}
+	return cq.importState(s)
+}
@@ -0,0 +1,134 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// ReserveAll reserves n events at t on every limiter as one reservation
+// that may act once the slowest of them allows. If any limiter cannot
+// reserve, the reservations already made are cancelled and the result is
+// not OK, so either all limiters are charged or none is
+func ReserveAll(t time.Time, n int, lims ...Limiter) *Reservation {
+	r, _ := reserveAll(t, n, InfDuration, lims)
+	return r
+}
+
+// AllowAll reports whether n events may happen at t on every limiter,
+// consuming them from all of them or from none
+func AllowAll(t time.Time, n int, lims ...Limiter) bool {
+	ok, _ := AllowAllIndex(t, n, lims...)
+	return ok
+}
+
+// AllowAllIndex is AllowAll also returning the index of the first limiter
+// that refused, or -1 when all of them admitted the events
+func AllowAllIndex(t time.Time, n int, lims ...Limiter) (bool, int) {
+	ok, i, _ := AllowAllRetry(t, n, lims...)
+	return ok, i
+}
+
+// AllowAllRetry is AllowAllIndex also returning how long the limiter that
+// refused would have made the caller wait, InfDuration if it can never
+// admit n events
+func AllowAllRetry(t time.Time, n int, lims ...Limiter) (bool, int, time.Duration) {
+	r, failed := reserveAll(t, n, 0, lims)
+	if r.OK() {
+		return true, -1, 0
+	}
+	retryAfter := InfDuration
+	if !r.timeToAct.IsZero() {
+		retryAfter = r.timeToAct.Sub(t)
+	}
+	for i, lim := range lims {
+		if lim == failed {
+			return false, i, retryAfter
+		}
+	}
+	return false, -1, retryAfter
+}
+
+// WaitAll blocks until n events are allowed on every limiter or ctx is
+// done. Like WaitN it fails fast when the wait would outlast ctx's deadline
+func WaitAll(ctx context.Context, n int, lims ...Limiter) error {
+	if len(lims) == 0 {
+		return nil
+	}
+
+	now := nowOf(lims[0])
+	r, failed := reserveAll(now, n, waitBudget(ctx), lims)
+	if !r.OK() {
+		if r.timeToAct.IsZero() {
+			return errExceeds(n, "burst", failed.Burst())
+		}
+		return &RateLimitError{RetryAfter: r.timeToAct.Sub(now)}
+	}
+	return r.Act(ctx)
+}
+
+// quietReserver is implemented by the built-in limiters, which can
+// reserve without recording the decision. reserveAll records it once it
+// knows the outcome, so a reservation it takes back is not counted as
+// admitted
+type quietReserver interface {
+	reserveQuiet(t time.Time, n int, maxWait time.Duration) *Reservation
+	record(t time.Time, n float64, ok bool, why DenialReason)
+}
+
+// reserveAll reserves n events on each limiter in order, refusing if any
+// would make the caller wait longer than maxWait. A refused reservation
+// carries the time to act of the limiter that refused, zero if it can
+// never admit n events, and that limiter is returned. Only the limiter
+// that refused counts a denial
+func reserveAll(t time.Time, n int, maxWait time.Duration, lims []Limiter) (*Reservation, Limiter) {
+	joint := &Reservation{ok: true, tokens: float64(n), timeToAct: t}
+	for _, lim := range lims {
+		q, quiet := lim.(quietReserver)
+		var r *Reservation
+		if quiet {
+			r = q.reserveQuiet(t, n, maxWait)
+		} else {
+			r = lim.ReserveN(t, n)
+		}
+		if !r.OK() || r.DelayFrom(t) > maxWait {
+			timeToAct := r.timeToAct
+			r.CancelAt(t)
+			joint.CancelAt(t)
+			if quiet {
+				why := DeniedLimited
+				if timeToAct.IsZero() {
+					why = DeniedExceeds
+				}
+				q.record(t, float64(n), false, why)
+			}
+			return &Reservation{ok: false, timeToAct: timeToAct}, lim
+		}
+		if joint.lim == nil {
+			joint.lim, joint.limit = r.lim, r.limit
+		}
+		if r.timeToAct.After(joint.timeToAct) {
+			joint.timeToAct = r.timeToAct
+		}
+		joint.linked = append(joint.linked, r)
+	}
+	for _, lim := range lims {
+		if q, ok := lim.(quietReserver); ok {
+			q.record(t, float64(n), true, NotDenied)
+		}
+	}
+	return joint, nil
+}
+
+// decided returns the reservation of an immediate decision made by lim,
+// for the algorithms that cannot reserve ahead. A refusal keeps its
+// retry time as the time to act, zero if n can never pass
+func decided(lim Limiter, t time.Time, n int, ok bool, retryAfter time.Duration) *Reservation {
+	if !ok {
+		r := &Reservation{ok: false}
+		if retryAfter != InfDuration {
+			r.timeToAct = t.Add(retryAfter)
+		}
+		return r
+	}
+	return &Reservation{ok: true, lim: lim, tokens: float64(n), timeToAct: t, limit: lim.Limit()}
+}
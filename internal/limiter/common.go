@@ -9,21 +9,19 @@ const (
 	LeakyBucket
 	SlidingWindow
 	FixedWindow
+	PriorityBucket
+	MultiWindow
+	EWMA
+	Adaptive
+	CalendarQuota
+	DualRate
 )
 
 func (a Algorithm) String() string {
-	switch a {
-	case TokenBucket:
-		return "TokenBucket"
-	case LeakyBucket:
-		return "LeakyBucket"
-	case SlidingWindow:
-		return "SlidingWindow"
-	case FixedWindow:
-		return "FixedWindow"
-	default:
-		return "Unknown"
+	if e, ok := entry(a); ok {
+		return e.name
 	}
+	return "Unknown"
 }
 
 type Capabilities struct {
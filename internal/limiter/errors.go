@@ -0,0 +1,110 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	// ErrInvalidLimit is returned for a negative or NaN limit
+	ErrInvalidLimit = errors.New("rate: invalid limit")
+	// ErrInvalidBurst is returned for a burst below 1
+	ErrInvalidBurst = errors.New("rate: invalid burst")
+	// ErrInvalidWindow is returned for a negative window
+	ErrInvalidWindow = errors.New("rate: invalid window")
+	// ErrUnknownAlgorithm is returned for an algorithm that was never registered
+	ErrUnknownAlgorithm = errors.New("rate: unknown algorithm")
+	// ErrInvalidSchedule is returned for a schedule spec that does not parse
+	ErrInvalidSchedule = errors.New("rate: invalid schedule")
+)
+
+// ErrExceedsBurst is matched by the error WaitN returns when n is larger
+// than the limiter could ever admit at once
+var ErrExceedsBurst = errors.New("rate: requested tokens exceed burst")
+
+// ErrReservationNotOK is returned by Act for a reservation that can never
+// be fulfilled
+var ErrReservationNotOK = errors.New("rate: reservation not OK")
+
+// ErrStateMismatch is returned when restoring state exported by a
+// different kind of limiter, or one configured differently
+var ErrStateMismatch = errors.New("rate: state does not match limiter")
+
+// ErrNoRuleMatches is returned when no descriptor rule matches a descriptor
+var ErrNoRuleMatches = errors.New("rate: no rule matches descriptor")
+
+// ErrDenylisted is returned when waiting for a key on a denylist
+var ErrDenylisted = errors.New("rate: key is denylisted")
+
+// ErrNoProvider is returned when reconfiguring a keyed limiter that does
+// not take its settings from a provider
+var ErrNoProvider = errors.New("rate: keyed limiter has no provider")
+
+// exceedsError reports a request larger than the limiter's capacity
+type exceedsError struct {
+	n     int
+	what  string
+	limit int
+}
+
+// errExceeds returns an error matching ErrExceedsBurst; what names the
+// capacity in the message, e.g. "burst" or "quota"
+func errExceeds(n int, what string, limit int) error {
+	return &exceedsError{n: n, what: what, limit: limit}
+}
+
+func (e *exceedsError) Error() string {
+	return fmt.Sprintf("rate: requested tokens (%d) exceeds %s (%d)", e.n, e.what, e.limit)
+}
+
+func (e *exceedsError) Is(target error) bool {
+	return target == ErrExceedsBurst
+}
+
+// RateLimitError is returned by WaitN when the wait would outlast the
+// context deadline or WaitMaxN's maxWait. It matches context.DeadlineExceeded
+type RateLimitError struct {
+	// RetryAfter is how long the caller would have had to wait
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate: wait of %v would exceed context deadline", e.RetryAfter)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// ConfigError describes a rejected constructor argument. It wraps one of
+// the Err* validation errors
+type ConfigError struct {
+	Field string
+	Value interface{}
+	Err   error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%v: %s = %v", e.Err, e.Field, e.Value)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks the arguments New would be called with
+func Validate(algo Algorithm, r Limit, b int) error {
+	if _, ok := entry(algo); !ok {
+		return &ConfigError{Field: "algorithm", Value: int(algo), Err: ErrUnknownAlgorithm}
+	}
+	if r < 0 || math.IsNaN(float64(r)) {
+		return &ConfigError{Field: "limit", Value: float64(r), Err: ErrInvalidLimit}
+	}
+	if b < 1 {
+		return &ConfigError{Field: "burst", Value: b, Err: ErrInvalidBurst}
+	}
+	return nil
+}
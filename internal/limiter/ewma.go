@@ -0,0 +1,405 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// EWMALimiter implements an exponentially weighted moving average limiter
+// Arrivals are accumulated in a counter that decays with time constant
+// burst/limit, so the smoothed rate is counter*limit/burst. Requests are
+// denied only when they would push the smoothed rate above the limit,
+// which tolerates bursty traffic that is compliant on average
+type EWMALimiter struct {
+	base
+	mu          sync.Mutex
+	limit       Limit
+	burst       int
+	count       float64
+	lastUpdated time.Time
+}
+
+// NewEWMA creates a new EWMA limiter
+func NewEWMA(r Limit, b int) *EWMALimiter {
+	return &EWMALimiter{
+		limit:       r,
+		burst:       b,
+		lastUpdated: time.Now(),
+	}
+}
+
+func (e *EWMALimiter) Algorithm() Algorithm {
+	return EWMA
+}
+
+func (e *EWMALimiter) Capabilities() Capabilities {
+	return Capabilities{
+		SupportsTokens:      true,
+		SupportsBurst:       true,
+		SupportsReservation: true,
+	}
+}
+
+func (e *EWMALimiter) Stats() Stats {
+	return e.stats(e.Tokens())
+}
+
+// Configure applies cfg and restarts the decay clock
+func (e *EWMALimiter) Configure(cfg Config) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.configure(cfg)
+	e.lastUpdated = e.now()
+}
+
+func (e *EWMALimiter) Reset() {
+	e.ResetTo(e.now())
+}
+
+func (e *EWMALimiter) ResetTo(t time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.count = 0
+	e.lastUpdated = t
+}
+
+func (e *EWMALimiter) Clone() Limiter {
+	e.mu.Lock()
+	c := &EWMALimiter{limit: e.limit, burst: e.burst}
+	e.mu.Unlock()
+	c.inherit(&e.base)
+	c.Reset()
+	return c
+}
+
+// advance decays the counter based on elapsed time
+func (e *EWMALimiter) advance(now time.Time) {
+	elapsed := now.Sub(e.lastUpdated)
+	if elapsed < 0 {
+		return
+	}
+	e.lastUpdated = now
+
+	if e.limit == Limit(math.MaxFloat64) {
+		e.count = 0
+		return
+	}
+	if e.burst <= 0 || e.limit <= 0 {
+		return
+	}
+	e.count *= math.Exp(-elapsed.Seconds() * float64(e.limit) / float64(e.burst))
+}
+
+// RateAt returns the smoothed arrival rate at time t in events per second
+func (e *EWMALimiter) RateAt(t time.Time) float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.advance(t)
+	if e.burst <= 0 {
+		return 0
+	}
+	return e.count * float64(e.limit) / float64(e.burst)
+}
+
+func (e *EWMALimiter) Allow() bool {
+	return e.AllowN(e.now(), 1)
+}
+
+func (e *EWMALimiter) AllowN(t time.Time, n int) bool {
+	ok, _ := e.AllowDetailsAt(t, n)
+	return ok
+}
+
+func (e *EWMALimiter) AllowDetails(n int) (bool, Result) {
+	return e.AllowDetailsAt(e.now(), n)
+}
+
+func (e *EWMALimiter) AllowDetailsAt(t time.Time, n int) (bool, Result) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.advance(t)
+
+	if e.limit == Limit(math.MaxFloat64) {
+		e.record(t, float64(n), true, NotDenied)
+		return true, Result{Limit: e.burst, Remaining: e.burst, ResetAt: t}
+	}
+
+	ok := e.count+float64(n) <= float64(e.burst)
+	if ok {
+		e.count += float64(n)
+	}
+
+	remaining, resetAt := e.headroom(t)
+	res := Result{
+		Limit:     e.burst,
+		Remaining: whole(remaining),
+		ResetAt:   resetAt,
+	}
+	if !ok {
+		if n > e.burst {
+			res.RetryAfter = InfDuration
+		} else {
+			res.RetryAfter = e.decayDelay(float64(e.burst - n))
+		}
+	}
+	e.record(t, float64(n), ok, deniedAfter(res.RetryAfter))
+	return ok, res
+}
+
+// headroom returns the events the counter has room for at t and when it
+// decays below one event, as it never reaches zero. e.mu must be held and
+// the counter advanced to t
+func (e *EWMALimiter) headroom(t time.Time) (float64, time.Time) {
+	if e.limit == Limit(math.MaxFloat64) {
+		return float64(e.burst), t
+	}
+	return math.Max(0, float64(e.burst)-e.count), resetAfter(t, e.decayDelay(1))
+}
+
+func (e *EWMALimiter) Remaining() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	e.advance(now)
+	remaining, _ := e.headroom(now)
+	return remaining
+}
+
+func (e *EWMALimiter) ResetAt() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	e.advance(now)
+	_, resetAt := e.headroom(now)
+	return resetAt
+}
+
+// decayDelay returns how long until the counter decays to target
+func (e *EWMALimiter) decayDelay(target float64) time.Duration {
+	if e.count <= target {
+		return 0
+	}
+	if e.limit <= 0 || e.burst <= 0 {
+		return InfDuration
+	}
+	if target < 1e-6 {
+		target = 1e-6
+	}
+	tau := float64(e.burst) / float64(e.limit)
+	return time.Duration(tau*math.Log(e.count/target)*float64(time.Second)) + time.Nanosecond
+}
+
+func (e *EWMALimiter) Reserve() *Reservation {
+	return e.ReserveN(e.now(), 1)
+}
+
+func (e *EWMALimiter) ReserveN(t time.Time, n int) *Reservation {
+	r := e.reserveN(t, n, InfDuration)
+	e.record(t, float64(n), r.OK(), DeniedExceeds)
+	return r
+}
+
+// reserveQuiet is reserveN for reserveAll, which records the decision
+// itself
+func (e *EWMALimiter) reserveQuiet(t time.Time, n int, maxWait time.Duration) *Reservation {
+	return e.reserveN(t, n, maxWait)
+}
+
+// reserveN reserves n events, refusing with the time to act set if that
+// would mean waiting longer than maxWait
+func (e *EWMALimiter) reserveN(t time.Time, n int, maxWait time.Duration) *Reservation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.advance(t)
+
+	if e.limit == Limit(math.MaxFloat64) {
+		return &Reservation{ok: true, lim: e, tokens: float64(n), timeToAct: t, limit: e.limit}
+	}
+	if n > e.burst {
+		return &Reservation{ok: false}
+	}
+
+	// Wait until the counter has decayed enough to take n more events
+	waitDuration := time.Duration(0)
+	headroom := float64(e.burst - n)
+	if e.count > headroom {
+		if e.limit <= 0 {
+			return &Reservation{ok: false}
+		}
+		// The counter never decays to exactly zero; treat a negligible
+		// remainder as empty when the request needs the whole burst
+		if headroom < 1e-6 {
+			headroom = 1e-6
+		}
+		tau := float64(e.burst) / float64(e.limit)
+		seconds := tau * math.Log(e.count/headroom)
+		waitDuration = time.Duration(seconds*float64(time.Second)) + time.Nanosecond
+	}
+
+	if waitDuration > maxWait {
+		return &Reservation{ok: false, timeToAct: t.Add(waitDuration)}
+	}
+
+	// An event at t+wait weighs exp(wait/tau) as seen from t
+	weight := 1.0
+	if waitDuration > 0 {
+		weight = math.Exp(waitDuration.Seconds() * float64(e.limit) / float64(e.burst))
+	}
+	e.count += float64(n) * weight
+
+	return &Reservation{
+		ok:        true,
+		lim:       e,
+		tokens:    float64(n),
+		timeToAct: t.Add(waitDuration),
+		limit:     e.limit,
+	}
+}
+
+// restore takes a reservation that has not acted yet back out of the
+// counter. It was added with weight exp(wait/tau), which has decayed to
+// exp((timeToAct-t)/tau) by t
+func (e *EWMALimiter) restore(t time.Time, r *Reservation) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if r.tokens == 0 || r.timeToAct.Before(t) {
+		return
+	}
+	e.advance(t)
+	if e.limit > 0 && e.limit != Limit(math.MaxFloat64) && e.burst > 0 {
+		weight := math.Exp(r.timeToAct.Sub(t).Seconds() * float64(e.limit) / float64(e.burst))
+		e.count = math.Max(0, e.count-r.tokens*weight)
+	}
+	r.tokens = 0
+}
+
+func (e *EWMALimiter) Wait(ctx context.Context) error {
+	return e.WaitN(ctx, 1)
+}
+
+func (e *EWMALimiter) WaitN(ctx context.Context, n int) (err error) {
+	start := e.now()
+	defer func() { e.recordWait(start, float64(n), err) }()
+
+	now := e.now()
+	r := e.reserveN(now, n, waitBudget(ctx))
+	if !r.OK() {
+		if r.timeToAct.IsZero() {
+			return errExceeds(n, "burst", e.Burst())
+		}
+		return &RateLimitError{RetryAfter: r.timeToAct.Sub(now)}
+	}
+
+	delay := r.DelayFrom(e.now())
+	if delay == 0 {
+		return nil
+	}
+
+	if err := e.sleep(ctx, delay); err != nil {
+		r.Cancel()
+		return err
+	}
+	return nil
+}
+
+func (e *EWMALimiter) WaitMaxN(ctx context.Context, n int, maxWait time.Duration) error {
+	return waitMax(ctx, e, n, maxWait)
+}
+
+func (e *EWMALimiter) Limit() Limit {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.limit
+}
+
+func (e *EWMALimiter) SetLimit(newLimit Limit) {
+	e.SetLimitAt(e.now(), newLimit)
+}
+
+func (e *EWMALimiter) SetLimitAt(t time.Time, newLimit Limit) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.advance(t)
+	e.limit = newLimit
+}
+
+func (e *EWMALimiter) RampLimit(target Limit, over time.Duration) {
+	e.rampLimit(e.Limit(), target, over, e.Limit, e.SetLimitAt)
+}
+
+func (e *EWMALimiter) Burst() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.burst
+}
+
+func (e *EWMALimiter) SetBurst(newBurst int) {
+	e.SetBurstAt(e.now(), newBurst)
+}
+
+func (e *EWMALimiter) SetBurstAt(t time.Time, newBurst int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.advance(t)
+	e.burst = newBurst
+}
+
+// Tokens returns how many events fit before the smoothed rate exceeds the limit
+func (e *EWMALimiter) Tokens() float64 {
+	return e.TokensAt(e.now())
+}
+
+func (e *EWMALimiter) TokensAt(t time.Time) float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.advance(t)
+	return float64(e.burst) - e.count
+}
+
+type ewmaState struct {
+	Count   float64   `json:"count"`
+	Updated time.Time `json:"updated"`
+}
+
+func (e *EWMALimiter) exportState() ewmaState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return ewmaState{Count: e.count, Updated: e.lastUpdated}
+}
+
+func (e *EWMALimiter) importState(s ewmaState) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.count = s.Count
+	e.lastUpdated = s.Updated
+	return nil
+}
+
+func (e *EWMALimiter) MarshalJSON() ([]byte, error) {
+	return marshalState("EWMA", e.exportState())
+}
+
+func (e *EWMALimiter) UnmarshalJSON(data []byte) error {
+	var s ewmaState
+	if err := unmarshalState("EWMA", data, &s); err != nil {
+		return err
+	}
+	return e.importState(s)
+}
+
+func (e *EWMALimiter) MarshalBinary() ([]byte, error) {
+	return encodeState("EWMA", e.exportState())
+}
+
+func (e *EWMALimiter) UnmarshalBinary(data []byte) error {
+	var s ewmaState
+	if err := decodeState("EWMA", data, &s); err != nil {
+		return err
+	}
+	return e.importState(s)
+}
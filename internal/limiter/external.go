@@ -0,0 +1,267 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Backend is a rate limiter implemented outside rateflow, such as the one
+// in golang.org/x/time/rate, that an ExternalLimiter puts behind Limiter
+type Backend interface {
+	AllowN(t time.Time, n int) bool
+	// ReserveN reserves n events at t and reports when they may happen;
+	// cancel gives them back. A reservation that is not OK has no cancel
+	ReserveN(t time.Time, n int) (ok bool, timeToAct time.Time, cancel func(t time.Time))
+	Limit() Limit
+	SetLimitAt(t time.Time, newLimit Limit)
+	Burst() int
+	SetBurstAt(t time.Time, newBurst int)
+	TokensAt(t time.Time) float64
+}
+
+// ExternalLimiter makes every decision through a Backend and adds what
+// the backend lacks: the clock, Stats, listeners, ramps and Reset, which
+// replaces the backend with a fresh one from the factory
+type ExternalLimiter struct {
+	base
+	mu      sync.Mutex
+	kind    Algorithm
+	backend Backend
+	factory func(Limit, int) Backend
+}
+
+// NewExternal creates a limiter reporting itself as algo, backed by
+// factory(r, b)
+func NewExternal(algo Algorithm, r Limit, b int, factory func(Limit, int) Backend) *ExternalLimiter {
+	return &ExternalLimiter{kind: algo, backend: factory(r, b), factory: factory}
+}
+
+// Backend returns the backend currently making the decisions. Reset
+// replaces it
+func (x *ExternalLimiter) Backend() Backend {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.backend
+}
+
+func (x *ExternalLimiter) Algorithm() Algorithm {
+	return x.kind
+}
+
+func (x *ExternalLimiter) Capabilities() Capabilities {
+	return Capabilities{
+		SupportsTokens:      true,
+		SupportsBurst:       true,
+		SupportsReservation: true,
+	}
+}
+
+func (x *ExternalLimiter) Stats() Stats {
+	return x.stats(x.Tokens())
+}
+
+// Configure applies cfg
+func (x *ExternalLimiter) Configure(cfg Config) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.configure(cfg)
+}
+
+func (x *ExternalLimiter) Reset() {
+	x.ResetTo(x.now())
+}
+
+func (x *ExternalLimiter) ResetTo(t time.Time) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.backend = x.factory(x.backend.Limit(), x.backend.Burst())
+}
+
+func (x *ExternalLimiter) Clone() Limiter {
+	x.mu.Lock()
+	c := NewExternal(x.kind, x.backend.Limit(), x.backend.Burst(), x.factory)
+	x.mu.Unlock()
+	c.inherit(&x.base)
+	return c
+}
+
+func (x *ExternalLimiter) Allow() bool {
+	return x.AllowN(x.now(), 1)
+}
+
+func (x *ExternalLimiter) AllowN(t time.Time, n int) bool {
+	ok, _ := x.AllowDetailsAt(t, n)
+	return ok
+}
+
+func (x *ExternalLimiter) AllowDetails(n int) (bool, Result) {
+	return x.AllowDetailsAt(x.now(), n)
+}
+
+func (x *ExternalLimiter) AllowDetailsAt(t time.Time, n int) (bool, Result) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	ok := x.backend.AllowN(t, n)
+
+	burst, limit := x.backend.Burst(), x.backend.Limit()
+	tokens := x.backend.TokensAt(t)
+	res := Result{
+		Limit:     burst,
+		Remaining: whole(tokens),
+		ResetAt:   resetAfter(t, tokenDelay(float64(burst)-tokens, limit)),
+	}
+	if !ok {
+		if n > burst && limit != Limit(math.MaxFloat64) {
+			res.RetryAfter = InfDuration
+		} else {
+			res.RetryAfter = tokenDelay(float64(n)-tokens, limit)
+		}
+	}
+	x.record(t, float64(n), ok, deniedAfter(res.RetryAfter))
+	return ok, res
+}
+
+func (x *ExternalLimiter) Remaining() float64 {
+	return math.Max(0, x.Tokens())
+}
+
+func (x *ExternalLimiter) ResetAt() time.Time {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	now := x.now()
+	tokens := x.backend.TokensAt(now)
+	return resetAfter(now, tokenDelay(float64(x.backend.Burst())-tokens, x.backend.Limit()))
+}
+
+func (x *ExternalLimiter) Reserve() *Reservation {
+	return x.ReserveN(x.now(), 1)
+}
+
+func (x *ExternalLimiter) ReserveN(t time.Time, n int) *Reservation {
+	r := x.reserveN(t, n)
+	x.record(t, float64(n), r.OK(), DeniedExceeds)
+	return r
+}
+
+// reserveQuiet is reserveN for reserveAll, which records the decision
+// itself and cancels reservations that would wait longer than maxWait
+func (x *ExternalLimiter) reserveQuiet(t time.Time, n int, maxWait time.Duration) *Reservation {
+	return x.reserveN(t, n)
+}
+
+func (x *ExternalLimiter) reserveN(t time.Time, n int) *Reservation {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	ok, timeToAct, cancel := x.backend.ReserveN(t, n)
+	if !ok {
+		return &Reservation{ok: false}
+	}
+	return &Reservation{
+		ok:        true,
+		lim:       x,
+		tokens:    float64(n),
+		timeToAct: timeToAct,
+		limit:     x.backend.Limit(),
+		refund:    cancel,
+	}
+}
+
+// restore hands a cancelled reservation back to the backend that made
+// it, which decides what to refund
+func (x *ExternalLimiter) restore(t time.Time, r *Reservation) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if r.tokens == 0 || r.refund == nil {
+		return
+	}
+	r.refund(t)
+	r.tokens = 0
+}
+
+func (x *ExternalLimiter) Wait(ctx context.Context) error {
+	return x.WaitN(ctx, 1)
+}
+
+func (x *ExternalLimiter) WaitN(ctx context.Context, n int) (err error) {
+	start := x.now()
+	defer func() { x.recordWait(start, float64(n), err) }()
+
+	now := x.now()
+	r := x.reserveN(now, n)
+	if !r.OK() {
+		if burst := x.Burst(); n > burst {
+			return errExceeds(n, "burst", burst)
+		}
+		return &RateLimitError{RetryAfter: InfDuration}
+	}
+
+	delay := r.DelayFrom(x.now())
+	if delay > waitBudget(ctx) {
+		r.CancelAt(now)
+		return &RateLimitError{RetryAfter: delay}
+	}
+	if delay == 0 {
+		return nil
+	}
+
+	if err := x.sleep(ctx, delay); err != nil {
+		r.Cancel()
+		return err
+	}
+	return nil
+}
+
+func (x *ExternalLimiter) WaitMaxN(ctx context.Context, n int, maxWait time.Duration) error {
+	return waitMax(ctx, x, n, maxWait)
+}
+
+func (x *ExternalLimiter) Limit() Limit {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.backend.Limit()
+}
+
+func (x *ExternalLimiter) SetLimit(newLimit Limit) {
+	x.SetLimitAt(x.now(), newLimit)
+}
+
+func (x *ExternalLimiter) SetLimitAt(t time.Time, newLimit Limit) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.backend.SetLimitAt(t, newLimit)
+}
+
+func (x *ExternalLimiter) RampLimit(target Limit, over time.Duration) {
+	x.rampLimit(x.Limit(), target, over, x.Limit, x.SetLimitAt)
+}
+
+func (x *ExternalLimiter) Burst() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.backend.Burst()
+}
+
+func (x *ExternalLimiter) SetBurst(newBurst int) {
+	x.SetBurstAt(x.now(), newBurst)
+}
+
+func (x *ExternalLimiter) SetBurstAt(t time.Time, newBurst int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.backend.SetBurstAt(t, newBurst)
+}
+
+func (x *ExternalLimiter) Tokens() float64 {
+	return x.TokensAt(x.now())
+}
+
+func (x *ExternalLimiter) TokensAt(t time.Time) float64 {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.backend.TokensAt(t)
+}
@@ -0,0 +1,150 @@
+package limiter
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// observeBuffer is how many events Observe holds for a limiter it can
+// only follow through Subscribe
+const observeBuffer = 64
+
+// Feed fans values out to subscribers. The zero Feed has none, and
+// publishing to it costs one atomic load
+type Feed[T any] struct {
+	mu   sync.Mutex
+	subs atomic.Pointer[[]*feedSub[T]] // replaced whole on every change
+}
+
+type feedSub[T any] struct {
+	fn func(T)
+}
+
+// Active reports whether f has subscribers
+func (f *Feed[T]) Active() bool {
+	return f.subs.Load() != nil
+}
+
+// Publish passes v to every subscriber
+func (f *Feed[T]) Publish(v T) {
+	subs := f.subs.Load()
+	if subs == nil {
+		return
+	}
+	for _, s := range *subs {
+		s.fn(v)
+	}
+}
+
+// Observe calls fn with every value published until cancel is called.
+// fn runs synchronously in Publish and must be quick
+func (f *Feed[T]) Observe(fn func(T)) (cancel func()) {
+	sub := &feedSub[T]{fn: fn}
+	f.mu.Lock()
+	var subs []*feedSub[T]
+	if old := f.subs.Load(); old != nil {
+		subs = append(subs, *old...)
+	}
+	subs = append(subs, sub)
+	f.subs.Store(&subs)
+	f.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { f.remove(sub) })
+	}
+}
+
+func (f *Feed[T]) remove(sub *feedSub[T]) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	old := f.subs.Load()
+	if old == nil {
+		return
+	}
+	subs := make([]*feedSub[T], 0, len(*old))
+	for _, s := range *old {
+		if s != sub {
+			subs = append(subs, s)
+		}
+	}
+	if len(subs) == 0 {
+		f.subs.Store(nil)
+		return
+	}
+	f.subs.Store(&subs)
+}
+
+// Subscribe returns a channel receiving every value published, holding
+// up to buffer of them for a slow reader. Values that find the buffer
+// full are dropped rather than blocking the publisher. cancel stops the
+// subscription and closes the channel
+func (f *Feed[T]) Subscribe(buffer int) (<-chan T, func()) {
+	if buffer < 0 {
+		buffer = 0
+	}
+	ch := make(chan T, buffer)
+	var (
+		mu     sync.Mutex
+		closed bool
+	)
+	unobserve := f.Observe(func(v T) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- v:
+		default:
+		}
+	})
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			unobserve()
+			mu.Lock()
+			closed = true
+			close(ch)
+			mu.Unlock()
+		})
+	}
+}
+
+// Subscribe returns a channel receiving an Event for every decision of
+// the limiter. See Feed.Subscribe
+func (b *base) Subscribe(buffer int) (<-chan Event, func()) {
+	return b.feed.Subscribe(buffer)
+}
+
+// observe calls fn with every event of the limiter until cancel is
+// called
+func (b *base) observe(fn func(Event)) (cancel func()) {
+	return b.feed.Observe(fn)
+}
+
+// Observe calls fn with every event lim emits until cancel is called.
+// Built-in limiters call fn synchronously, with their lock possibly
+// held, so it must be quick and must not call back into lim. Other
+// limiters are followed through Subscribe, dropping events fn falls
+// behind on
+func Observe(lim Limiter, fn func(Event)) (cancel func()) {
+	for {
+		if o, ok := lim.(interface{ observe(func(Event)) func() }); ok {
+			return o.observe(fn)
+		}
+		u, ok := lim.(interface{ Unwrap() Limiter })
+		if !ok {
+			break
+		}
+		lim = u.Unwrap()
+	}
+	ch, cancel := lim.Subscribe(observeBuffer)
+	go func() {
+		for e := range ch {
+			fn(e)
+		}
+	}()
+	return cancel
+}
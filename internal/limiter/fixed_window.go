@@ -2,7 +2,7 @@ package limiter
 
 import (
 	"context"
-	"fmt"
+	"math"
 	"sync"
 	"time"
 )
@@ -10,12 +10,15 @@ import (
 // FixedWindowLimiter implements the fixed window algorithm
 // Resets counter at fixed time intervals
 type FixedWindowLimiter struct {
+	base
 	mu           sync.Mutex
 	limit        Limit
 	maxCount     int
 	window       time.Duration
 	currentCount int
 	windowStart  time.Time
+	alignment    Alignment
+	waiters      waitQueue
 }
 
 // NewFixedWindow creates a new fixed window limiter
@@ -25,8 +28,16 @@ func NewFixedWindow(r Limit, maxCount int) *FixedWindowLimiter {
 		window = time.Duration(float64(time.Second) * float64(maxCount) / float64(r))
 	}
 
+	fw := NewFixedWindowDuration(maxCount, window)
+	fw.limit = r
+	return fw
+}
+
+// NewFixedWindowDuration creates a fixed window limiter allowing maxCount
+// events per window of the given duration
+func NewFixedWindowDuration(maxCount int, window time.Duration) *FixedWindowLimiter {
 	return &FixedWindowLimiter{
-		limit:        r,
+		limit:        Limit(float64(maxCount) / window.Seconds()),
 		maxCount:     maxCount,
 		window:       window,
 		currentCount: 0,
@@ -34,6 +45,13 @@ func NewFixedWindow(r Limit, maxCount int) *FixedWindowLimiter {
 	}
 }
 
+// Window returns the window duration
+func (fw *FixedWindowLimiter) Window() time.Duration {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.window
+}
+
 func (fw *FixedWindowLimiter) Algorithm() Algorithm {
 	return FixedWindow
 }
@@ -46,33 +64,160 @@ func (fw *FixedWindowLimiter) Capabilities() Capabilities {
 	}
 }
 
+func (fw *FixedWindowLimiter) Stats() Stats {
+	return fw.stats(fw.Tokens())
+}
+
+// Configure applies cfg; a window replaces the one derived from the limit
+func (fw *FixedWindowLimiter) Configure(cfg Config) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.configure(cfg)
+	if cfg.Window > 0 {
+		fw.window = cfg.Window
+		fw.limit = Limit(float64(fw.maxCount) / cfg.Window.Seconds())
+	}
+	fw.alignment = cfg.Alignment
+	fw.windowStart = fw.now()
+	if fw.alignment == AlignWallClock {
+		fw.windowStart = fw.windowStart.Truncate(fw.window)
+	}
+}
+
+func (fw *FixedWindowLimiter) Reset() {
+	fw.ResetTo(fw.now())
+}
+
+func (fw *FixedWindowLimiter) ResetTo(t time.Time) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.currentCount = 0
+	fw.windowStart = t
+	if fw.alignment == AlignWallClock {
+		fw.windowStart = t.Truncate(fw.window)
+	}
+}
+
+// Clone keeps the window alignment; the clone's first window starts now
+func (fw *FixedWindowLimiter) Clone() Limiter {
+	fw.mu.Lock()
+	c := &FixedWindowLimiter{limit: fw.limit, maxCount: fw.maxCount, window: fw.window, alignment: fw.alignment}
+	fw.mu.Unlock()
+	c.inherit(&fw.base)
+	c.Reset()
+	return c
+}
+
+// idle reports whether a zero limit or count has paused the window, in
+// which case it admits nothing. fw.mu must be held
+func (fw *FixedWindowLimiter) idle() bool {
+	return fw.limit == 0 || fw.maxCount == 0
+}
+
 // resetIfNeeded resets the counter if we're in a new window
 func (fw *FixedWindowLimiter) resetIfNeeded(now time.Time) {
 	if now.Sub(fw.windowStart) >= fw.window {
 		fw.currentCount = 0
-		fw.windowStart = now.Truncate(fw.window)
+		if fw.alignment == AlignFirstEvent {
+			fw.windowStart = now
+		} else {
+			fw.windowStart = now.Truncate(fw.window)
+		}
 	}
 }
 
 func (fw *FixedWindowLimiter) Allow() bool {
-	return fw.AllowN(time.Now(), 1)
+	return fw.AllowN(fw.now(), 1)
 }
 
 func (fw *FixedWindowLimiter) AllowN(t time.Time, n int) bool {
+	ok, _ := fw.AllowDetailsAt(t, n)
+	return ok
+}
+
+func (fw *FixedWindowLimiter) AllowDetails(n int) (bool, Result) {
+	return fw.AllowDetailsAt(fw.now(), n)
+}
+
+func (fw *FixedWindowLimiter) AllowDetailsAt(t time.Time, n int) (bool, Result) {
 	fw.mu.Lock()
 	defer fw.mu.Unlock()
+	ok, res := fw.allowDetails(t, n)
+	fw.record(t, float64(n), ok, deniedAfter(res.RetryAfter))
+	return ok, res
+}
 
+// allowDetails decides n events at t without recording the decision.
+// fw.mu must be held
+func (fw *FixedWindowLimiter) allowDetails(t time.Time, n int) (bool, Result) {
 	fw.resetIfNeeded(t)
 
-	if fw.currentCount+n <= fw.maxCount {
+	ok := !fw.idle() && fw.currentCount+n <= fw.maxCount
+	if ok {
 		fw.currentCount += n
-		return true
 	}
-	return false
+
+	remaining, resetAt := fw.headroom(t)
+	res := Result{
+		Limit:     fw.maxCount,
+		Remaining: whole(remaining),
+		ResetAt:   resetAt,
+	}
+	if !ok {
+		if fw.idle() || n > fw.maxCount {
+			res.RetryAfter = InfDuration
+		} else {
+			res.RetryAfter = fw.windowStart.Add(fw.window).Sub(t)
+		}
+	}
+	return ok, res
+}
+
+// reserveQuiet decides n events at t for reserveAll, which records the
+// decision itself
+func (fw *FixedWindowLimiter) reserveQuiet(t time.Time, n int, maxWait time.Duration) *Reservation {
+	fw.mu.Lock()
+	ok, res := fw.allowDetails(t, n)
+	fw.mu.Unlock()
+	return decided(fw, t, n, ok, res.RetryAfter)
+}
+
+// headroom returns the events left in the current window and when it
+// ends, or t for an unused window. fw.mu must be held and the window
+// reset if needed
+func (fw *FixedWindowLimiter) headroom(t time.Time) (float64, time.Time) {
+	if fw.idle() {
+		if fw.currentCount == 0 {
+			return 0, t
+		}
+		return 0, fw.windowStart.Add(fw.window)
+	}
+	if fw.currentCount == 0 {
+		return float64(fw.maxCount), t
+	}
+	return math.Max(0, float64(fw.maxCount-fw.currentCount)), fw.windowStart.Add(fw.window)
+}
+
+func (fw *FixedWindowLimiter) Remaining() float64 {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	now := fw.now()
+	fw.resetIfNeeded(now)
+	remaining, _ := fw.headroom(now)
+	return remaining
+}
+
+func (fw *FixedWindowLimiter) ResetAt() time.Time {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	now := fw.now()
+	fw.resetIfNeeded(now)
+	_, resetAt := fw.headroom(now)
+	return resetAt
 }
 
 func (fw *FixedWindowLimiter) Reserve() *Reservation {
-	return fw.ReserveN(time.Now(), 1)
+	return fw.ReserveN(fw.now(), 1)
 }
 
 func (fw *FixedWindowLimiter) ReserveN(t time.Time, n int) *Reservation {
@@ -80,7 +225,7 @@ func (fw *FixedWindowLimiter) ReserveN(t time.Time, n int) *Reservation {
 		return &Reservation{
 			ok:        true,
 			lim:       fw,
-			tokens:    n,
+			tokens:    float64(n),
 			timeToAct: t,
 			limit:     fw.limit,
 		}
@@ -88,36 +233,107 @@ func (fw *FixedWindowLimiter) ReserveN(t time.Time, n int) *Reservation {
 	return &Reservation{ok: false}
 }
 
+// restore uncounts the reservation if its window has not ended
+func (fw *FixedWindowLimiter) restore(t time.Time, r *Reservation) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if r.tokens == 0 {
+		return
+	}
+	fw.resetIfNeeded(t)
+
+	if !r.timeToAct.Before(fw.windowStart) {
+		fw.currentCount -= int(r.tokens)
+		if fw.currentCount < 0 {
+			fw.currentCount = 0
+		}
+	}
+	r.tokens = 0
+}
+
 func (fw *FixedWindowLimiter) Wait(ctx context.Context) error {
 	return fw.WaitN(ctx, 1)
 }
 
-func (fw *FixedWindowLimiter) WaitN(ctx context.Context, n int) error {
+// WaitN admits blocked callers in arrival order; a caller never jumps
+// ahead of one that is already waiting. While the window is paused the
+// caller at the front waits for SetLimit or SetBurst
+func (fw *FixedWindowLimiter) WaitN(ctx context.Context, n int) (err error) {
+	start := fw.now()
+	defer func() { fw.recordWait(start, float64(n), err) }()
+
 	fw.mu.Lock()
-	now := time.Now()
+	now := fw.now()
 	fw.resetIfNeeded(now)
 
-	if n > fw.maxCount {
+	if !fw.idle() && n > fw.maxCount {
 		fw.mu.Unlock()
-		return fmt.Errorf("rate: requested tokens (%d) exceeds limit (%d)", n, fw.maxCount)
+		return errExceeds(n, "limit", fw.maxCount)
 	}
 
-	if fw.currentCount+n > fw.maxCount {
+	fits := !fw.idle() && fw.currentCount+n <= fw.maxCount
+	if fits && !fw.waiters.busy {
+		fw.currentCount += n
+		fw.mu.Unlock()
+		return nil
+	}
+	if !fits && !fw.idle() {
+		// Nothing can be admitted before the next window
+		if d := fw.windowStart.Add(fw.window).Sub(now); d > waitBudget(ctx) {
+			fw.mu.Unlock()
+			return &RateLimitError{RetryAfter: d}
+		}
+	}
+
+	turn := fw.waiters.join()
+	fw.mu.Unlock()
+
+	if err := fw.awaitTurn(ctx, turn); err != nil {
+		fw.mu.Lock()
+		fw.waiters.leave(turn)
+		fw.mu.Unlock()
+		return err
+	}
+
+	for {
+		fw.mu.Lock()
+		if err := fw.awaitActive(ctx, &fw.mu, fw.idle); err != nil {
+			fw.mu.Lock()
+			fw.waiters.next()
+			fw.mu.Unlock()
+			return err
+		}
+		now := fw.now()
+		fw.resetIfNeeded(now)
+
+		if n > fw.maxCount {
+			fw.waiters.next()
+			fw.mu.Unlock()
+			return errExceeds(n, "limit", fw.maxCount)
+		}
+		if fw.currentCount+n <= fw.maxCount {
+			fw.currentCount += n
+			fw.waiters.next()
+			fw.mu.Unlock()
+			return nil
+		}
+
 		// Wait for next window
 		nextWindow := fw.windowStart.Add(fw.window)
 		fw.mu.Unlock()
 
-		select {
-		case <-time.After(time.Until(nextWindow)):
-			return fw.WaitN(ctx, n) // Retry in new window
-		case <-ctx.Done():
-			return ctx.Err()
+		if err := fw.sleep(ctx, nextWindow.Sub(now)); err != nil {
+			fw.mu.Lock()
+			fw.waiters.next()
+			fw.mu.Unlock()
+			return err
 		}
 	}
+}
 
-	fw.currentCount += n
-	fw.mu.Unlock()
-	return nil
+func (fw *FixedWindowLimiter) WaitMaxN(ctx context.Context, n int, maxWait time.Duration) error {
+	return waitMax(ctx, fw, n, maxWait)
 }
 
 func (fw *FixedWindowLimiter) Limit() Limit {
@@ -127,7 +343,7 @@ func (fw *FixedWindowLimiter) Limit() Limit {
 }
 
 func (fw *FixedWindowLimiter) SetLimit(newLimit Limit) {
-	fw.SetLimitAt(time.Now(), newLimit)
+	fw.SetLimitAt(fw.now(), newLimit)
 }
 
 func (fw *FixedWindowLimiter) SetLimitAt(t time.Time, newLimit Limit) {
@@ -138,6 +354,11 @@ func (fw *FixedWindowLimiter) SetLimitAt(t time.Time, newLimit Limit) {
 	if newLimit > 0 {
 		fw.window = time.Duration(float64(time.Second) * float64(fw.maxCount) / float64(newLimit))
 	}
+	fw.resume()
+}
+
+func (fw *FixedWindowLimiter) RampLimit(target Limit, over time.Duration) {
+	fw.rampLimit(fw.Limit(), target, over, fw.Limit, fw.SetLimitAt)
 }
 
 func (fw *FixedWindowLimiter) Burst() int {
@@ -147,7 +368,7 @@ func (fw *FixedWindowLimiter) Burst() int {
 }
 
 func (fw *FixedWindowLimiter) SetBurst(newBurst int) {
-	fw.SetBurstAt(time.Now(), newBurst)
+	fw.SetBurstAt(fw.now(), newBurst)
 }
 
 func (fw *FixedWindowLimiter) SetBurstAt(t time.Time, newBurst int) {
@@ -158,11 +379,12 @@ func (fw *FixedWindowLimiter) SetBurstAt(t time.Time, newBurst int) {
 	if fw.limit > 0 {
 		fw.window = time.Duration(float64(time.Second) * float64(newBurst) / float64(fw.limit))
 	}
+	fw.resume()
 }
 
 // Tokens returns remaining capacity in current window
 func (fw *FixedWindowLimiter) Tokens() float64 {
-	return fw.TokensAt(time.Now())
+	return fw.TokensAt(fw.now())
 }
 
 func (fw *FixedWindowLimiter) TokensAt(t time.Time) float64 {
@@ -171,3 +393,46 @@ func (fw *FixedWindowLimiter) TokensAt(t time.Time) float64 {
 	fw.resetIfNeeded(t)
 	return float64(fw.maxCount - fw.currentCount)
 }
+
+type fixedWindowState struct {
+	Count       int       `json:"count"`
+	WindowStart time.Time `json:"window_start"`
+}
+
+func (fw *FixedWindowLimiter) exportState() fixedWindowState {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fixedWindowState{Count: fw.currentCount, WindowStart: fw.windowStart}
+}
+
+func (fw *FixedWindowLimiter) importState(s fixedWindowState) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.currentCount = s.Count
+	fw.windowStart = s.WindowStart
+	return nil
+}
+
+func (fw *FixedWindowLimiter) MarshalJSON() ([]byte, error) {
+	return marshalState("FixedWindow", fw.exportState())
+}
+
+func (fw *FixedWindowLimiter) UnmarshalJSON(data []byte) error {
+	var s fixedWindowState
+	if err := unmarshalState("FixedWindow", data, &s); err != nil {
+		return err
+	}
+	return fw.importState(s)
+}
+
+func (fw *FixedWindowLimiter) MarshalBinary() ([]byte, error) {
+	return encodeState("FixedWindow", fw.exportState())
+}
+
+func (fw *FixedWindowLimiter) UnmarshalBinary(data []byte) error {
+	var s fixedWindowState
+	if err := decodeState("FixedWindow", data, &s); err != nil {
+		return err
+	}
+	return fw.importState(s)
+}
@@ -2,7 +2,6 @@ package limiter
 
 import (
 	"context"
-	"fmt"
 	"math"
 	"sync"
 	"time"
@@ -11,6 +10,7 @@ import (
 // LeakyBucketLimiter implements the leaky bucket algorithm
 // Requests are queued and processed at a constant rate
 type LeakyBucketLimiter struct {
+	base
 	mu           sync.Mutex
 	limit        Limit
 	capacity     int
@@ -40,6 +40,44 @@ func (lb *LeakyBucketLimiter) Capabilities() Capabilities {
 	}
 }
 
+func (lb *LeakyBucketLimiter) Stats() Stats {
+	return lb.stats(lb.Tokens())
+}
+
+// Configure applies cfg and restarts the leak clock
+func (lb *LeakyBucketLimiter) Configure(cfg Config) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.configure(cfg)
+	lb.lastLeakTime = lb.now()
+}
+
+func (lb *LeakyBucketLimiter) Reset() {
+	lb.ResetTo(lb.now())
+}
+
+func (lb *LeakyBucketLimiter) ResetTo(t time.Time) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.queue = nil
+	lb.lastLeakTime = t
+}
+
+func (lb *LeakyBucketLimiter) Clone() Limiter {
+	lb.mu.Lock()
+	c := &LeakyBucketLimiter{limit: lb.limit, capacity: lb.capacity}
+	lb.mu.Unlock()
+	c.inherit(&lb.base)
+	c.Reset()
+	return c
+}
+
+// idle reports whether a zero limit or capacity has paused the bucket,
+// in which case it admits nothing. lb.mu must be held
+func (lb *LeakyBucketLimiter) idle() bool {
+	return lb.limit == 0 || lb.capacity == 0
+}
+
 // leak removes expired items from the queue
 func (lb *LeakyBucketLimiter) leak(now time.Time) {
 	if lb.limit == Limit(math.MaxFloat64) || len(lb.queue) == 0 {
@@ -58,35 +96,105 @@ func (lb *LeakyBucketLimiter) leak(now time.Time) {
 }
 
 func (lb *LeakyBucketLimiter) Allow() bool {
-	return lb.AllowN(time.Now(), 1)
+	return lb.AllowN(lb.now(), 1)
 }
 
 func (lb *LeakyBucketLimiter) AllowN(t time.Time, n int) bool {
+	ok, _ := lb.AllowDetailsAt(t, n)
+	return ok
+}
+
+func (lb *LeakyBucketLimiter) AllowDetails(n int) (bool, Result) {
+	return lb.AllowDetailsAt(lb.now(), n)
+}
+
+func (lb *LeakyBucketLimiter) AllowDetailsAt(t time.Time, n int) (bool, Result) {
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
 
 	lb.leak(t)
 
-	if len(lb.queue)+n <= lb.capacity {
+	ok := !lb.idle() && len(lb.queue)+n <= lb.capacity
+	if ok {
 		for i := 0; i < n; i++ {
 			lb.queue = append(lb.queue, t)
 		}
-		return true
 	}
-	return false
+
+	remaining, resetAt := lb.headroom(t)
+	res := Result{
+		Limit:     lb.capacity,
+		Remaining: whole(remaining),
+		ResetAt:   resetAt,
+	}
+	if !ok {
+		if lb.idle() || n > lb.capacity {
+			res.RetryAfter = InfDuration
+		} else {
+			res.RetryAfter = tokenDelay(float64(len(lb.queue)+n-lb.capacity), lb.limit)
+		}
+	}
+	lb.record(t, float64(n), ok, deniedAfter(res.RetryAfter))
+	return ok, res
+}
+
+// headroom returns the free queue slots at t and when the queue has
+// drained. lb.mu must be held and the queue leaked up to t
+func (lb *LeakyBucketLimiter) headroom(t time.Time) (float64, time.Time) {
+	resetAt := resetAfter(t, tokenDelay(float64(len(lb.queue)), lb.limit))
+	if lb.idle() {
+		return 0, resetAt
+	}
+	return math.Max(0, float64(lb.capacity-len(lb.queue))), resetAt
+}
+
+func (lb *LeakyBucketLimiter) Remaining() float64 {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	now := lb.now()
+	lb.leak(now)
+	remaining, _ := lb.headroom(now)
+	return remaining
+}
+
+func (lb *LeakyBucketLimiter) ResetAt() time.Time {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	now := lb.now()
+	lb.leak(now)
+	_, resetAt := lb.headroom(now)
+	return resetAt
 }
 
 func (lb *LeakyBucketLimiter) Reserve() *Reservation {
-	return lb.ReserveN(time.Now(), 1)
+	return lb.ReserveN(lb.now(), 1)
 }
 
 func (lb *LeakyBucketLimiter) ReserveN(t time.Time, n int) *Reservation {
+	r := lb.reserveN(t, n, InfDuration)
+	lb.record(t, float64(n), r.OK(), DeniedExceeds)
+	return r
+}
+
+// reserveQuiet is reserveN for reserveAll, which records the decision
+// itself
+func (lb *LeakyBucketLimiter) reserveQuiet(t time.Time, n int, maxWait time.Duration) *Reservation {
+	return lb.reserveN(t, n, maxWait)
+}
+
+// reserveN reserves n events, refusing with the time to act set if that
+// would mean waiting longer than maxWait
+func (lb *LeakyBucketLimiter) reserveN(t time.Time, n int, maxWait time.Duration) *Reservation {
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
+	return lb.reserveLocked(t, n, maxWait)
+}
 
+// reserveLocked is reserveN with lb.mu already held
+func (lb *LeakyBucketLimiter) reserveLocked(t time.Time, n int, maxWait time.Duration) *Reservation {
 	lb.leak(t)
 
-	if n > lb.capacity {
+	if lb.idle() || n > lb.capacity {
 		return &Reservation{ok: false}
 	}
 
@@ -98,6 +206,10 @@ func (lb *LeakyBucketLimiter) ReserveN(t time.Time, n int) *Reservation {
 		}
 	}
 
+	if waitDuration > maxWait {
+		return &Reservation{ok: false, timeToAct: t.Add(waitDuration)}
+	}
+
 	for i := 0; i < n; i++ {
 		lb.queue = append(lb.queue, t)
 	}
@@ -105,34 +217,69 @@ func (lb *LeakyBucketLimiter) ReserveN(t time.Time, n int) *Reservation {
 	return &Reservation{
 		ok:        true,
 		lim:       lb,
-		tokens:    n,
+		tokens:    float64(n),
 		timeToAct: t.Add(waitDuration),
 		limit:     lb.limit,
 	}
 }
 
+// restore removes a reservation that has not acted yet from the queue
+func (lb *LeakyBucketLimiter) restore(t time.Time, r *Reservation) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if r.tokens == 0 || r.timeToAct.Before(t) {
+		return
+	}
+	lb.leak(t)
+	// Queued slots are interchangeable, so drop from the back
+	n := int(r.tokens)
+	if n > len(lb.queue) {
+		n = len(lb.queue)
+	}
+	lb.queue = lb.queue[:len(lb.queue)-n]
+	r.tokens = 0
+}
+
 func (lb *LeakyBucketLimiter) Wait(ctx context.Context) error {
 	return lb.WaitN(ctx, 1)
 }
 
-func (lb *LeakyBucketLimiter) WaitN(ctx context.Context, n int) error {
-	r := lb.ReserveN(time.Now(), n)
+// WaitN waits for SetLimit or SetBurst while the bucket is paused
+func (lb *LeakyBucketLimiter) WaitN(ctx context.Context, n int) (err error) {
+	start := lb.now()
+	defer func() { lb.recordWait(start, float64(n), err) }()
+
+	lb.mu.Lock()
+	if err := lb.awaitActive(ctx, &lb.mu, lb.idle); err != nil {
+		return err
+	}
+	now := lb.now()
+	r := lb.reserveLocked(now, n, waitBudget(ctx))
+	capacity := lb.capacity
+	lb.mu.Unlock()
+
 	if !r.OK() {
-		return fmt.Errorf("rate: requested tokens (%d) exceeds capacity (%d)", n, lb.Burst())
+		if r.timeToAct.IsZero() {
+			return errExceeds(n, "capacity", capacity)
+		}
+		return &RateLimitError{RetryAfter: r.timeToAct.Sub(now)}
 	}
 
-	delay := r.Delay()
+	delay := r.DelayFrom(lb.now())
 	if delay == 0 {
 		return nil
 	}
 
-	select {
-	case <-time.After(delay):
-		return nil
-	case <-ctx.Done():
+	if err := lb.sleep(ctx, delay); err != nil {
 		r.Cancel()
-		return ctx.Err()
+		return err
 	}
+	return nil
+}
+
+func (lb *LeakyBucketLimiter) WaitMaxN(ctx context.Context, n int, maxWait time.Duration) error {
+	return waitMax(ctx, lb, n, maxWait)
 }
 
 func (lb *LeakyBucketLimiter) Limit() Limit {
@@ -142,7 +289,7 @@ func (lb *LeakyBucketLimiter) Limit() Limit {
 }
 
 func (lb *LeakyBucketLimiter) SetLimit(newLimit Limit) {
-	lb.SetLimitAt(time.Now(), newLimit)
+	lb.SetLimitAt(lb.now(), newLimit)
 }
 
 func (lb *LeakyBucketLimiter) SetLimitAt(t time.Time, newLimit Limit) {
@@ -150,6 +297,11 @@ func (lb *LeakyBucketLimiter) SetLimitAt(t time.Time, newLimit Limit) {
 	defer lb.mu.Unlock()
 	lb.leak(t)
 	lb.limit = newLimit
+	lb.resume()
+}
+
+func (lb *LeakyBucketLimiter) RampLimit(target Limit, over time.Duration) {
+	lb.rampLimit(lb.Limit(), target, over, lb.Limit, lb.SetLimitAt)
 }
 
 func (lb *LeakyBucketLimiter) Burst() int {
@@ -159,7 +311,7 @@ func (lb *LeakyBucketLimiter) Burst() int {
 }
 
 func (lb *LeakyBucketLimiter) SetBurst(newBurst int) {
-	lb.SetBurstAt(time.Now(), newBurst)
+	lb.SetBurstAt(lb.now(), newBurst)
 }
 
 func (lb *LeakyBucketLimiter) SetBurstAt(t time.Time, newBurst int) {
@@ -170,11 +322,12 @@ func (lb *LeakyBucketLimiter) SetBurstAt(t time.Time, newBurst int) {
 	if len(lb.queue) > newBurst {
 		lb.queue = lb.queue[:newBurst]
 	}
+	lb.resume()
 }
 
 // Tokens returns remaining capacity (not true tokens)
 func (lb *LeakyBucketLimiter) Tokens() float64 {
-	return lb.TokensAt(time.Now())
+	return lb.TokensAt(lb.now())
 }
 
 func (lb *LeakyBucketLimiter) TokensAt(t time.Time) float64 {
@@ -183,3 +336,49 @@ func (lb *LeakyBucketLimiter) TokensAt(t time.Time) float64 {
 	lb.leak(t)
 	return float64(lb.capacity - len(lb.queue))
 }
+
+type leakyBucketState struct {
+	Queued   []time.Time `json:"queued"`
+	LastLeak time.Time   `json:"last_leak"`
+}
+
+func (lb *LeakyBucketLimiter) exportState() leakyBucketState {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return leakyBucketState{Queued: append([]time.Time(nil), lb.queue...), LastLeak: lb.lastLeakTime}
+}
+
+func (lb *LeakyBucketLimiter) importState(s leakyBucketState) error {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.queue = append([]time.Time(nil), s.Queued...)
+	if len(lb.queue) > lb.capacity {
+		lb.queue = lb.queue[:lb.capacity]
+	}
+	lb.lastLeakTime = s.LastLeak
+	return nil
+}
+
+func (lb *LeakyBucketLimiter) MarshalJSON() ([]byte, error) {
+	return marshalState("LeakyBucket", lb.exportState())
+}
+
+func (lb *LeakyBucketLimiter) UnmarshalJSON(data []byte) error {
+	var s leakyBucketState
+	if err := unmarshalState("LeakyBucket", data, &s); err != nil {
+		return err
+	}
+	return lb.importState(s)
+}
+
+func (lb *LeakyBucketLimiter) MarshalBinary() ([]byte, error) {
+	return encodeState("LeakyBucket", lb.exportState())
+}
+
+func (lb *LeakyBucketLimiter) UnmarshalBinary(data []byte) error {
+	var s leakyBucketState
+	if err := decodeState("LeakyBucket", data, &s); err != nil {
+		return err
+	}
+	return lb.importState(s)
+}
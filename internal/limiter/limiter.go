@@ -12,18 +12,48 @@ type Limiter interface {
 	Wait(ctx context.Context) error
 	WaitN(ctx context.Context, n int) error
 
-	// Configuration methods
+	// WaitMaxN is like WaitN but fails with a RateLimitError, without
+	// consuming capacity, if the wait would be longer than maxWait
+	WaitMaxN(ctx context.Context, n int, maxWait time.Duration) error
+
+	// AllowDetails is like AllowN but also reports the limiter's state,
+	// read atomically with the decision
+	AllowDetails(n int) (bool, Result)
+	AllowDetailsAt(t time.Time, n int) (bool, Result)
+
+	// Configuration methods. A zero limit or burst pauses the token
+	// bucket, leaky bucket and both windows: Allow fails and Wait blocks
+	// until SetLimit or SetBurst lifts the pause or ctx is done
 	Limit() Limit
 	SetLimit(newLimit Limit)
 	SetLimitAt(t time.Time, newLimit Limit)
+	// RampLimit moves the limit to target in small steps spread over the
+	// given duration instead of all at once. A SetLimit or another
+	// RampLimit call made during the ramp takes over from it
+	RampLimit(target Limit, over time.Duration)
 	Burst() int
 	SetBurst(newBurst int)
 	SetBurstAt(t time.Time, newBurst int)
 
+	// Reset restores the limiter to its freshly constructed state: a full
+	// bucket, an empty queue or window. Configuration and Stats are kept
+	Reset()
+	ResetTo(t time.Time)
+
 	// Token methods - behavior varies by algorithm
 	Tokens() float64
 	TokensAt(t time.Time) float64
 
+	// Remaining is the number of events that would be admitted right now,
+	// never negative. ResetAt is when the limiter is back to full
+	// capacity: now if it already is, the zero time if it never refills
+	Remaining() float64
+	ResetAt() time.Time
+
+	// Clone returns a new limiter with the same configuration, clock,
+	// jitter and name, in its freshly constructed state with empty Stats
+	Clone() Limiter
+
 	// Reservation methods - not all algorithms support this
 	Reserve() *Reservation
 	ReserveN(t time.Time, n int) *Reservation
@@ -31,4 +61,12 @@ type Limiter interface {
 	// Metadata
 	Algorithm() Algorithm
 	Capabilities() Capabilities
+	Stats() Stats
+
+	// Subscribe returns a channel receiving an Event for every decision,
+	// holding up to buffer of them; events that find it full are dropped
+	// so a slow reader never holds up the limiter. cancel ends the
+	// subscription and closes the channel. Clones start without
+	// subscribers
+	Subscribe(buffer int) (<-chan Event, func())
 }
@@ -0,0 +1,66 @@
+package limiter
+
+import "time"
+
+// Event describes one decision made by a limiter
+type Event struct {
+	// Name is the limiter's name, if it was given one
+	Name      string
+	Algorithm Algorithm
+	Time      time.Time
+	// N is the number of events or tokens asked for
+	N       float64
+	Allowed bool
+	// Delay is how long a Wait blocked; zero for Allow and Reserve
+	Delay time.Duration
+	// Err is why a Wait failed
+	Err error
+	// Reason classifies a denial or failed Wait, NotDenied otherwise
+	Reason DenialReason
+}
+
+// Listener receives an event for every decision a limiter makes: OnAllow
+// and OnDeny for Allow and Reserve calls, OnWait when a Wait returns
+// Listeners are called synchronously, possibly with the limiter's lock
+// held, so they must be quick and must not call back into the limiter
+type Listener interface {
+	OnAllow(e Event)
+	OnDeny(e Event)
+	OnWait(e Event)
+}
+
+// ListenerFuncs is a Listener built from functions; nil ones are skipped
+type ListenerFuncs struct {
+	Allow func(Event)
+	Deny  func(Event)
+	Wait  func(Event)
+}
+
+func (l ListenerFuncs) OnAllow(e Event) {
+	if l.Allow != nil {
+		l.Allow(e)
+	}
+}
+
+func (l ListenerFuncs) OnDeny(e Event) {
+	if l.Deny != nil {
+		l.Deny(e)
+	}
+}
+
+func (l ListenerFuncs) OnWait(e Event) {
+	if l.Wait != nil {
+		l.Wait(e)
+	}
+}
+
+// event fills in an Event for a decision about n events at t
+func (b *base) event(t time.Time, n float64, ok bool) Event {
+	return Event{
+		Name:      b.name,
+		Algorithm: b.algo,
+		Time:      t,
+		N:         n,
+		Allowed:   ok,
+	}
+}
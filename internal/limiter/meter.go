@@ -0,0 +1,506 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Verdict is the color a MeterLimiter assigns to an event
+type Verdict int
+
+const (
+	// Conform (green) events are within the committed rate
+	Conform Verdict = iota
+	// Exceed (yellow) events are above the committed rate but within the
+	// peak or excess allowance; callers may serve them in degraded form
+	Exceed
+	// Violate (red) events are above every allowance
+	Violate
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Conform:
+		return "conform"
+	case Exceed:
+		return "exceed"
+	case Violate:
+		return "violate"
+	default:
+		return "unknown"
+	}
+}
+
+// MeterLimiter implements the single-rate (RFC 2697) and two-rate
+// (RFC 2698) three color markers. Events are marked conform, exceed or
+// violate rather than just allowed or denied; Allow admits everything
+// that does not violate
+type MeterLimiter struct {
+	base
+	mu          sync.Mutex
+	twoRate     bool
+	cir         Limit // committed rate
+	cbs         int   // committed burst
+	pir         Limit // peak rate (two-rate only)
+	pbs         int   // peak burst, or excess burst for single-rate
+	tc          float64
+	tp          float64 // peak bucket, or excess bucket for single-rate
+	lastUpdated time.Time
+}
+
+// NewSingleRateMeter creates a single-rate three color marker: tokens
+// arrive at the committed rate cir into a committed bucket of size cbs,
+// overflowing into an excess bucket of size ebs
+func NewSingleRateMeter(cir Limit, cbs, ebs int) *MeterLimiter {
+	return &MeterLimiter{
+		cir:         cir,
+		cbs:         cbs,
+		pbs:         ebs,
+		tc:          float64(cbs),
+		tp:          float64(ebs),
+		lastUpdated: time.Now(),
+	}
+}
+
+// NewTwoRateMeter creates a two-rate three color marker with a committed
+// bucket (cir, cbs) and a peak bucket (pir, pbs)
+func NewTwoRateMeter(cir Limit, cbs int, pir Limit, pbs int) *MeterLimiter {
+	if pir < cir {
+		pir = cir
+	}
+	return &MeterLimiter{
+		twoRate:     true,
+		cir:         cir,
+		cbs:         cbs,
+		pir:         pir,
+		pbs:         pbs,
+		tc:          float64(cbs),
+		tp:          float64(pbs),
+		lastUpdated: time.Now(),
+	}
+}
+
+func (m *MeterLimiter) Algorithm() Algorithm {
+	return DualRate
+}
+
+func (m *MeterLimiter) Capabilities() Capabilities {
+	return Capabilities{
+		SupportsTokens:      true,
+		SupportsBurst:       true,
+		SupportsReservation: false,
+	}
+}
+
+func (m *MeterLimiter) Stats() Stats {
+	return m.stats(m.Tokens())
+}
+
+// Configure applies cfg and restarts the refill clock
+func (m *MeterLimiter) Configure(cfg Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.configure(cfg)
+	m.lastUpdated = m.now()
+}
+
+func (m *MeterLimiter) Reset() {
+	m.ResetTo(m.now())
+}
+
+func (m *MeterLimiter) ResetTo(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tc = float64(m.cbs)
+	m.tp = float64(m.pbs)
+	m.lastUpdated = t
+}
+
+func (m *MeterLimiter) Clone() Limiter {
+	m.mu.Lock()
+	c := &MeterLimiter{twoRate: m.twoRate, cir: m.cir, cbs: m.cbs, pir: m.pir, pbs: m.pbs}
+	m.mu.Unlock()
+	c.inherit(&m.base)
+	c.Reset()
+	return c
+}
+
+// refill returns the tokens a bucket of rate r gains over elapsed
+func refill(r Limit, elapsed time.Duration) float64 {
+	if r == Limit(math.MaxFloat64) {
+		return math.Inf(1)
+	}
+	return float64(r) * elapsed.Seconds()
+}
+
+// advance refills both buckets based on elapsed time
+func (m *MeterLimiter) advance(now time.Time) {
+	elapsed := now.Sub(m.lastUpdated)
+	if elapsed < 0 {
+		return
+	}
+	m.lastUpdated = now
+
+	if m.twoRate {
+		m.tc = math.Min(m.tc+refill(m.cir, elapsed), float64(m.cbs))
+		m.tp = math.Min(m.tp+refill(m.pir, elapsed), float64(m.pbs))
+		return
+	}
+
+	// Single-rate: committed tokens overflow into the excess bucket
+	add := refill(m.cir, elapsed)
+	room := float64(m.cbs) - m.tc
+	if add <= room {
+		m.tc += add
+		return
+	}
+	m.tc = float64(m.cbs)
+	m.tp = math.Min(m.tp+add-room, float64(m.pbs))
+}
+
+// mark colors n events and consumes the matching tokens
+func (m *MeterLimiter) mark(n int) Verdict {
+	size := float64(n)
+	if m.twoRate {
+		if m.tp < size {
+			return Violate
+		}
+		m.tp -= size
+		if m.tc < size {
+			return Exceed
+		}
+		m.tc -= size
+		return Conform
+	}
+
+	if m.tc >= size {
+		m.tc -= size
+		return Conform
+	}
+	if m.tp >= size {
+		m.tp -= size
+		return Exceed
+	}
+	return Violate
+}
+
+// Mark is shorthand for MarkN(time.Now(), 1)
+func (m *MeterLimiter) Mark() Verdict {
+	return m.MarkN(m.now(), 1)
+}
+
+// MarkN colors n events happening at time t
+func (m *MeterLimiter) MarkN(t time.Time, n int) Verdict {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.advance(t)
+	v := m.mark(n)
+	why := DeniedLimited
+	if n > m.largest() {
+		why = DeniedExceeds
+	}
+	m.record(t, float64(n), v != Violate, why)
+	return v
+}
+
+func (m *MeterLimiter) Allow() bool {
+	return m.AllowN(m.now(), 1)
+}
+
+// AllowN admits n events unless they violate; exceeding events are let
+// through, use MarkN to tell them apart
+func (m *MeterLimiter) AllowN(t time.Time, n int) bool {
+	return m.MarkN(t, n) != Violate
+}
+
+func (m *MeterLimiter) AllowDetails(n int) (bool, Result) {
+	return m.AllowDetailsAt(m.now(), n)
+}
+
+// reserveQuiet colors n events at t for reserveAll, which records the
+// decision itself
+func (m *MeterLimiter) reserveQuiet(t time.Time, n int, maxWait time.Duration) *Reservation {
+	m.mu.Lock()
+	m.advance(t)
+	ok := m.mark(n) != Violate
+	retryAfter := time.Duration(0)
+	if !ok {
+		retryAfter = InfDuration
+		if n <= m.largest() {
+			retryAfter = m.violationDelay(n)
+		}
+	}
+	m.mu.Unlock()
+	return decided(m, t, n, ok, retryAfter)
+}
+
+// largest returns the most events the meter can admit at once. m.mu
+// must be held
+func (m *MeterLimiter) largest() int {
+	if !m.twoRate && m.cbs > m.pbs {
+		return m.cbs
+	}
+	return m.pbs
+}
+
+// AllowDetailsAt reports the state of the bucket that decides violations:
+// the peak bucket of a two-rate meter, both buckets of a single-rate one
+func (m *MeterLimiter) AllowDetailsAt(t time.Time, n int) (bool, Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.advance(t)
+	ok := m.mark(n) != Violate
+	largest := m.largest()
+
+	remaining, resetAt := m.headroom(t)
+	res := Result{
+		Limit:     largest,
+		Remaining: whole(remaining),
+		ResetAt:   resetAt,
+	}
+	if !ok {
+		if n > largest {
+			res.RetryAfter = InfDuration
+		} else {
+			res.RetryAfter = m.violationDelay(n)
+		}
+	}
+	m.record(t, float64(n), ok, deniedAfter(res.RetryAfter))
+	return ok, res
+}
+
+// headroom returns the largest request that would not violate at t and
+// when both buckets are full. m.mu must be held and the buckets advanced
+func (m *MeterLimiter) headroom(t time.Time) (float64, time.Time) {
+	if m.twoRate {
+		reset := tokenDelay(float64(m.cbs)-m.tc, m.cir)
+		if d := tokenDelay(float64(m.pbs)-m.tp, m.pir); d > reset {
+			reset = d
+		}
+		return math.Max(0, m.tp), resetAfter(t, reset)
+	}
+	return math.Max(0, math.Max(m.tc, m.tp)), resetAfter(t, tokenDelay(float64(m.cbs)-m.tc+float64(m.pbs)-m.tp, m.cir))
+}
+
+func (m *MeterLimiter) Remaining() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.advance(now)
+	remaining, _ := m.headroom(now)
+	return remaining
+}
+
+func (m *MeterLimiter) ResetAt() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.advance(now)
+	_, resetAt := m.headroom(now)
+	return resetAt
+}
+
+// Reserve returns a reservation that's either immediate or not OK
+func (m *MeterLimiter) Reserve() *Reservation {
+	return m.ReserveN(m.now(), 1)
+}
+
+func (m *MeterLimiter) ReserveN(t time.Time, n int) *Reservation {
+	if m.AllowN(t, n) {
+		return &Reservation{
+			ok:        true,
+			lim:       m,
+			tokens:    float64(n),
+			timeToAct: t,
+			limit:     m.Limit(),
+		}
+	}
+	return &Reservation{ok: false}
+}
+
+func (m *MeterLimiter) Wait(ctx context.Context) error {
+	return m.WaitN(ctx, 1)
+}
+
+func (m *MeterLimiter) WaitN(ctx context.Context, n int) (err error) {
+	start := m.now()
+	defer func() { m.recordWait(start, float64(n), err) }()
+
+	m.mu.Lock()
+	now := m.now()
+	m.advance(now)
+
+	largest := m.pbs
+	if !m.twoRate && m.cbs > largest {
+		largest = m.cbs
+	}
+	if n > largest {
+		m.mu.Unlock()
+		return errExceeds(n, "burst", largest)
+	}
+
+	if m.mark(n) == Violate {
+		delay := m.violationDelay(n)
+		m.mu.Unlock()
+
+		if err := m.sleep(ctx, delay); err != nil {
+			return err
+		}
+		return m.WaitN(ctx, n) // Retry
+	}
+
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MeterLimiter) WaitMaxN(ctx context.Context, n int, maxWait time.Duration) error {
+	return waitMax(ctx, m, n, maxWait)
+}
+
+// violationDelay estimates how long until n events would no longer violate
+func (m *MeterLimiter) violationDelay(n int) time.Duration {
+	size := float64(n)
+	var needed float64
+	var rate Limit
+	if m.twoRate {
+		needed, rate = size-m.tp, m.pir
+	} else {
+		rate = m.cir
+		if size <= float64(m.cbs) {
+			needed = size - m.tc
+		} else {
+			needed = float64(m.cbs) - m.tc + size - m.tp
+		}
+	}
+	if rate <= 0 {
+		return time.Second
+	}
+	return time.Duration(needed/float64(rate)*float64(time.Second)) + time.Nanosecond
+}
+
+// Limit returns the committed rate
+func (m *MeterLimiter) Limit() Limit {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cir
+}
+
+func (m *MeterLimiter) SetLimit(newLimit Limit) {
+	m.SetLimitAt(m.now(), newLimit)
+}
+
+// SetLimitAt changes the committed rate; the peak rate never drops below it
+func (m *MeterLimiter) SetLimitAt(t time.Time, newLimit Limit) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.advance(t)
+	m.cir = newLimit
+	if m.twoRate && m.pir < newLimit {
+		m.pir = newLimit
+	}
+}
+
+func (m *MeterLimiter) RampLimit(target Limit, over time.Duration) {
+	m.rampLimit(m.Limit(), target, over, m.Limit, m.SetLimitAt)
+}
+
+// Burst returns the committed burst
+func (m *MeterLimiter) Burst() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cbs
+}
+
+func (m *MeterLimiter) SetBurst(newBurst int) {
+	m.SetBurstAt(m.now(), newBurst)
+}
+
+// SetBurstAt changes the committed burst
+func (m *MeterLimiter) SetBurstAt(t time.Time, newBurst int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.advance(t)
+	m.cbs = newBurst
+	if m.tc > float64(newBurst) {
+		m.tc = float64(newBurst)
+	}
+}
+
+// PeakLimit returns the peak rate of a two-rate meter, or the committed
+// rate of a single-rate meter
+func (m *MeterLimiter) PeakLimit() Limit {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.twoRate {
+		return m.pir
+	}
+	return m.cir
+}
+
+// PeakBurst returns the peak burst of a two-rate meter, or the excess
+// burst of a single-rate meter
+func (m *MeterLimiter) PeakBurst() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pbs
+}
+
+// Tokens returns the tokens in the committed bucket
+func (m *MeterLimiter) Tokens() float64 {
+	return m.TokensAt(m.now())
+}
+
+func (m *MeterLimiter) TokensAt(t time.Time) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.advance(t)
+	return m.tc
+}
+
+type meterState struct {
+	Committed float64   `json:"committed"`
+	Peak      float64   `json:"peak"`
+	Updated   time.Time `json:"updated"`
+}
+
+func (m *MeterLimiter) exportState() meterState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return meterState{Committed: m.tc, Peak: m.tp, Updated: m.lastUpdated}
+}
+
+func (m *MeterLimiter) importState(s meterState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tc = math.Min(s.Committed, float64(m.cbs))
+	m.tp = math.Min(s.Peak, float64(m.pbs))
+	m.lastUpdated = s.Updated
+	return nil
+}
+
+func (m *MeterLimiter) MarshalJSON() ([]byte, error) {
+	return marshalState("Meter", m.exportState())
+}
+
+func (m *MeterLimiter) UnmarshalJSON(data []byte) error {
+	var s meterState
+	if err := unmarshalState("Meter", data, &s); err != nil {
+		return err
+	}
+	return m.importState(s)
+}
+
+func (m *MeterLimiter) MarshalBinary() ([]byte, error) {
+	return encodeState("Meter", m.exportState())
+}
+
+func (m *MeterLimiter) UnmarshalBinary(data []byte) error {
+	var s meterState
+	if err := decodeState("Meter", data, &s); err != nil {
+		return err
+	}
+	return m.importState(s)
+}
@@ -0,0 +1,494 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WindowRule allows Count events per Window
+type WindowRule struct {
+	Count  int
+	Window time.Duration
+}
+
+// Limit returns the long-term rate allowed by the rule
+func (r WindowRule) Limit() Limit {
+	if r.Window <= 0 {
+		return Limit(math.MaxFloat64)
+	}
+	return Limit(float64(r.Count) / r.Window.Seconds())
+}
+
+func (r WindowRule) String() string {
+	return fmt.Sprintf("%d/%s", r.Count, r.Window)
+}
+
+// MultiWindowLimiter enforces several (count, window) rules at once,
+// e.g. 10/s AND 1000/h. Each rule is metered as a token bucket refilling
+// Count tokens per Window, so Reserve and Wait account for every rule
+// The rule with the shortest window is the primary rule reported by
+// Limit and Burst
+type MultiWindowLimiter struct {
+	base
+	mu          sync.Mutex
+	rules       []WindowRule
+	tokens      []float64
+	lastUpdated time.Time
+}
+
+// NewMultiWindow creates a new multi-window limiter from the given rules
+func NewMultiWindow(rules ...WindowRule) *MultiWindowLimiter {
+	sorted := make([]WindowRule, len(rules))
+	copy(sorted, rules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Window < sorted[j].Window
+	})
+
+	tokens := make([]float64, len(sorted))
+	for i, rule := range sorted {
+		tokens[i] = float64(rule.Count)
+	}
+
+	return &MultiWindowLimiter{
+		rules:       sorted,
+		tokens:      tokens,
+		lastUpdated: time.Now(),
+	}
+}
+
+func (mw *MultiWindowLimiter) Algorithm() Algorithm {
+	return MultiWindow
+}
+
+func (mw *MultiWindowLimiter) Capabilities() Capabilities {
+	return Capabilities{
+		SupportsTokens:      true,
+		SupportsBurst:       true,
+		SupportsReservation: true,
+	}
+}
+
+func (mw *MultiWindowLimiter) Stats() Stats {
+	return mw.stats(mw.Tokens())
+}
+
+// Configure applies cfg and restarts the refill clock
+func (mw *MultiWindowLimiter) Configure(cfg Config) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	mw.configure(cfg)
+	mw.lastUpdated = mw.now()
+}
+
+func (mw *MultiWindowLimiter) Reset() {
+	mw.ResetTo(mw.now())
+}
+
+func (mw *MultiWindowLimiter) ResetTo(t time.Time) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	for i, rule := range mw.rules {
+		mw.tokens[i] = float64(rule.Count)
+	}
+	mw.lastUpdated = t
+}
+
+func (mw *MultiWindowLimiter) Clone() Limiter {
+	mw.mu.Lock()
+	c := &MultiWindowLimiter{
+		rules:  append([]WindowRule(nil), mw.rules...),
+		tokens: make([]float64, len(mw.rules)),
+	}
+	mw.mu.Unlock()
+	c.inherit(&mw.base)
+	c.Reset()
+	return c
+}
+
+// Rules returns the rules enforced by the limiter, shortest window first
+func (mw *MultiWindowLimiter) Rules() []WindowRule {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	rules := make([]WindowRule, len(mw.rules))
+	copy(rules, mw.rules)
+	return rules
+}
+
+// advance refills every rule based on elapsed time
+func (mw *MultiWindowLimiter) advance(now time.Time) {
+	elapsed := now.Sub(mw.lastUpdated)
+	if elapsed < 0 {
+		return
+	}
+	mw.lastUpdated = now
+
+	for i, rule := range mw.rules {
+		limit := rule.Limit()
+		if limit == Limit(math.MaxFloat64) {
+			mw.tokens[i] = float64(rule.Count)
+			continue
+		}
+		delta := float64(limit) * elapsed.Seconds()
+		mw.tokens[i] = math.Min(mw.tokens[i]+delta, float64(rule.Count))
+	}
+}
+
+func (mw *MultiWindowLimiter) Allow() bool {
+	return mw.AllowN(mw.now(), 1)
+}
+
+func (mw *MultiWindowLimiter) AllowN(t time.Time, n int) bool {
+	ok, _ := mw.AllowNRule(t, n)
+	return ok
+}
+
+// AllowNRule is like AllowN but also reports the index (into Rules) of the
+// first rule that denied the request, or -1 if it was allowed
+func (mw *MultiWindowLimiter) AllowNRule(t time.Time, n int) (bool, int) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	return mw.allowNRule(t, n)
+}
+
+func (mw *MultiWindowLimiter) allowNRule(t time.Time, n int) (bool, int) {
+	mw.advance(t)
+
+	for i := range mw.rules {
+		if mw.tokens[i] < float64(n) {
+			why := DeniedLimited
+			for _, rule := range mw.rules {
+				if n > rule.Count {
+					why = DeniedExceeds
+				}
+			}
+			mw.record(t, float64(n), false, why)
+			return false, i
+		}
+	}
+	for i := range mw.rules {
+		mw.tokens[i] -= float64(n)
+	}
+	mw.record(t, float64(n), true, NotDenied)
+	return true, -1
+}
+
+func (mw *MultiWindowLimiter) AllowDetails(n int) (bool, Result) {
+	return mw.AllowDetailsAt(mw.now(), n)
+}
+
+// AllowDetailsAt reports Limit and Remaining for the rule with the least
+// capacity left; ResetAt and RetryAfter cover all rules
+func (mw *MultiWindowLimiter) AllowDetailsAt(t time.Time, n int) (bool, Result) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	ok, _ := mw.allowNRule(t, n)
+
+	var res Result
+	if len(mw.rules) == 0 {
+		return ok, res
+	}
+
+	tightest := 0
+	for i, rule := range mw.rules {
+		if mw.tokens[i] < mw.tokens[tightest] {
+			tightest = i
+		}
+		if !ok {
+			wait := tokenDelay(float64(n)-mw.tokens[i], rule.Limit())
+			if n > rule.Count {
+				wait = InfDuration
+			}
+			if wait > res.RetryAfter {
+				res.RetryAfter = wait
+			}
+		}
+	}
+	remaining, resetAt := mw.headroom(t)
+	res.Limit = mw.rules[tightest].Count
+	res.Remaining = whole(remaining)
+	res.ResetAt = resetAt
+	return ok, res
+}
+
+// headroom returns the events every rule allows at t and when all rules
+// are full again. mw.mu must be held and the buckets advanced to t
+func (mw *MultiWindowLimiter) headroom(t time.Time) (float64, time.Time) {
+	if len(mw.rules) == 0 {
+		return 0, t
+	}
+	remaining := math.Inf(1)
+	var reset time.Duration
+	for i, rule := range mw.rules {
+		remaining = math.Min(remaining, mw.tokens[i])
+		if d := tokenDelay(float64(rule.Count)-mw.tokens[i], rule.Limit()); d > reset {
+			reset = d
+		}
+	}
+	return math.Max(0, remaining), resetAfter(t, reset)
+}
+
+func (mw *MultiWindowLimiter) Remaining() float64 {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	now := mw.now()
+	mw.advance(now)
+	remaining, _ := mw.headroom(now)
+	return remaining
+}
+
+func (mw *MultiWindowLimiter) ResetAt() time.Time {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	now := mw.now()
+	mw.advance(now)
+	_, resetAt := mw.headroom(now)
+	return resetAt
+}
+
+func (mw *MultiWindowLimiter) Reserve() *Reservation {
+	return mw.ReserveN(mw.now(), 1)
+}
+
+func (mw *MultiWindowLimiter) ReserveN(t time.Time, n int) *Reservation {
+	r := mw.reserveN(t, n, InfDuration)
+	mw.record(t, float64(n), r.OK(), DeniedExceeds)
+	return r
+}
+
+// reserveQuiet is reserveN for reserveAll, which records the decision
+// itself
+func (mw *MultiWindowLimiter) reserveQuiet(t time.Time, n int, maxWait time.Duration) *Reservation {
+	return mw.reserveN(t, n, maxWait)
+}
+
+// reserveN reserves n events, refusing with the time to act set if that
+// would mean waiting longer than maxWait
+func (mw *MultiWindowLimiter) reserveN(t time.Time, n int, maxWait time.Duration) *Reservation {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	mw.advance(t)
+
+	// The longest wait across all rules decides when the event may happen
+	waitDuration := time.Duration(0)
+	for i, rule := range mw.rules {
+		if n > rule.Count {
+			return &Reservation{ok: false}
+		}
+		if mw.tokens[i] >= float64(n) {
+			continue
+		}
+		limit := rule.Limit()
+		if limit <= 0 {
+			return &Reservation{ok: false}
+		}
+		needed := float64(n) - mw.tokens[i]
+		wait := time.Duration(needed/float64(limit)*float64(time.Second)) + time.Nanosecond
+		if wait > waitDuration {
+			waitDuration = wait
+		}
+	}
+
+	if waitDuration > maxWait {
+		return &Reservation{ok: false, timeToAct: t.Add(waitDuration)}
+	}
+
+	for i := range mw.rules {
+		mw.tokens[i] -= float64(n)
+	}
+
+	return &Reservation{
+		ok:        true,
+		lim:       mw,
+		tokens:    float64(n),
+		timeToAct: t.Add(waitDuration),
+		limit:     mw.primaryLimit(),
+	}
+}
+
+// restore refunds a reservation that has not acted yet to every rule
+func (mw *MultiWindowLimiter) restore(t time.Time, r *Reservation) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	if r.tokens == 0 || r.timeToAct.Before(t) {
+		return
+	}
+	mw.advance(t)
+	for i, rule := range mw.rules {
+		mw.tokens[i] = math.Min(mw.tokens[i]+r.tokens, float64(rule.Count))
+	}
+	r.tokens = 0
+}
+
+func (mw *MultiWindowLimiter) Wait(ctx context.Context) error {
+	return mw.WaitN(ctx, 1)
+}
+
+func (mw *MultiWindowLimiter) WaitN(ctx context.Context, n int) (err error) {
+	start := mw.now()
+	defer func() { mw.recordWait(start, float64(n), err) }()
+
+	now := mw.now()
+	r := mw.reserveN(now, n, waitBudget(ctx))
+	if !r.OK() {
+		if r.timeToAct.IsZero() {
+			return errExceeds(n, "burst", mw.Burst())
+		}
+		return &RateLimitError{RetryAfter: r.timeToAct.Sub(now)}
+	}
+
+	delay := r.DelayFrom(mw.now())
+	if delay == 0 {
+		return nil
+	}
+
+	if err := mw.sleep(ctx, delay); err != nil {
+		r.Cancel()
+		return err
+	}
+	return nil
+}
+
+func (mw *MultiWindowLimiter) WaitMaxN(ctx context.Context, n int, maxWait time.Duration) error {
+	return waitMax(ctx, mw, n, maxWait)
+}
+
+// primaryLimit returns the rate of the primary rule
+func (mw *MultiWindowLimiter) primaryLimit() Limit {
+	if len(mw.rules) == 0 {
+		return Limit(math.MaxFloat64)
+	}
+	return mw.rules[0].Limit()
+}
+
+// Limit returns the rate of the primary rule
+func (mw *MultiWindowLimiter) Limit() Limit {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	return mw.primaryLimit()
+}
+
+func (mw *MultiWindowLimiter) SetLimit(newLimit Limit) {
+	mw.SetLimitAt(mw.now(), newLimit)
+}
+
+// SetLimitAt changes the rate of the primary rule, keeping its count
+func (mw *MultiWindowLimiter) SetLimitAt(t time.Time, newLimit Limit) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	mw.advance(t)
+	if len(mw.rules) == 0 || newLimit <= 0 {
+		return
+	}
+	mw.rules[0].Window = time.Duration(float64(time.Second) * float64(mw.rules[0].Count) / float64(newLimit))
+}
+
+func (mw *MultiWindowLimiter) RampLimit(target Limit, over time.Duration) {
+	mw.rampLimit(mw.Limit(), target, over, mw.Limit, mw.SetLimitAt)
+}
+
+// Burst returns the count of the primary rule
+func (mw *MultiWindowLimiter) Burst() int {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	if len(mw.rules) == 0 {
+		return 0
+	}
+	return mw.rules[0].Count
+}
+
+func (mw *MultiWindowLimiter) SetBurst(newBurst int) {
+	mw.SetBurstAt(mw.now(), newBurst)
+}
+
+// SetBurstAt changes the count of the primary rule, keeping its rate
+func (mw *MultiWindowLimiter) SetBurstAt(t time.Time, newBurst int) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	mw.advance(t)
+	if len(mw.rules) == 0 {
+		return
+	}
+	limit := mw.rules[0].Limit()
+	mw.rules[0].Count = newBurst
+	if limit > 0 && limit != Limit(math.MaxFloat64) {
+		mw.rules[0].Window = time.Duration(float64(time.Second) * float64(newBurst) / float64(limit))
+	}
+	if mw.tokens[0] > float64(newBurst) {
+		mw.tokens[0] = float64(newBurst)
+	}
+}
+
+// Tokens returns the tokens available under the most constrained rule
+func (mw *MultiWindowLimiter) Tokens() float64 {
+	return mw.TokensAt(mw.now())
+}
+
+func (mw *MultiWindowLimiter) TokensAt(t time.Time) float64 {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	mw.advance(t)
+
+	tokens := math.Inf(1)
+	for _, tok := range mw.tokens {
+		tokens = math.Min(tokens, tok)
+	}
+	if math.IsInf(tokens, 1) {
+		return 0
+	}
+	return tokens
+}
+
+type multiWindowState struct {
+	Tokens  []float64 `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+func (mw *MultiWindowLimiter) exportState() multiWindowState {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	return multiWindowState{Tokens: append([]float64(nil), mw.tokens...), Updated: mw.lastUpdated}
+}
+
+func (mw *MultiWindowLimiter) importState(s multiWindowState) error {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	if len(s.Tokens) != len(mw.rules) {
+		return fmt.Errorf("%w: %d rules, state has %d", ErrStateMismatch, len(mw.rules), len(s.Tokens))
+	}
+	for i, rule := range mw.rules {
+		mw.tokens[i] = math.Min(s.Tokens[i], float64(rule.Count))
+	}
+	mw.lastUpdated = s.Updated
+	return nil
+}
+
+func (mw *MultiWindowLimiter) MarshalJSON() ([]byte, error) {
+	return marshalState("MultiWindow", mw.exportState())
+}
+
+func (mw *MultiWindowLimiter) UnmarshalJSON(data []byte) error {
+	var s multiWindowState
+	if err := unmarshalState("MultiWindow", data, &s); err != nil {
+		return err
+	}
+	return mw.importState(s)
+}
+
+func (mw *MultiWindowLimiter) MarshalBinary() ([]byte, error) {
+	return encodeState("MultiWindow", mw.exportState())
+}
+
+func (mw *MultiWindowLimiter) UnmarshalBinary(data []byte) error {
+	var s multiWindowState
+	if err := decodeState("MultiWindow", data, &s); err != nil {
+		return err
+	}
+	return mw.importState(s)
+}
@@ -0,0 +1,264 @@
+package limiter
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock provides the current time and timers to a limiter
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock of the time package, for types outside this
+// package that default to it
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time                         { return time.Now() }
+func (SystemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Alignment controls where fixed windows start
+type Alignment int
+
+const (
+	// AlignDefault keeps each algorithm's own behavior
+	AlignDefault Alignment = iota
+	// AlignWallClock starts windows at multiples of the window duration
+	AlignWallClock
+	// AlignFirstEvent starts a window at the first event after the last one expired
+	AlignFirstEvent
+)
+
+// Config holds optional settings applied to a limiter after construction
+type Config struct {
+	Clock     Clock
+	Window    time.Duration
+	Alignment Alignment
+	Jitter    time.Duration
+	Name      string
+	Listener  Listener
+
+	// Algorithm is the algorithm the limiter was created as, reported in
+	// listener events
+	Algorithm Algorithm
+}
+
+// Configurable is implemented by limiters that accept a Config
+// Configure must be called before the limiter is shared
+type Configurable interface {
+	Configure(cfg Config)
+}
+
+// base holds the settings shared by every limiter
+type base struct {
+	clock    Clock
+	jitter   time.Duration
+	name     string
+	listener Listener
+	algo     Algorithm
+
+	// Activity counters reported by Stats
+	allowed atomic.Uint64
+	denied  atomic.Uint64
+	waiting atomic.Int64
+	last    atomic.Int64 // UnixNano of the last decision, 0 if none
+	denials [numReasons]atomic.Uint64
+	waits   [WaitBuckets]atomic.Uint64
+	waitSum atomic.Int64 // nanoseconds
+
+	ramps atomic.Uint64 // generation of the latest RampLimit
+
+	// feed passes every decision to Subscribe channels
+	feed Feed[Event]
+
+	// resumeCh is closed when a paused limiter's limit or burst changes.
+	// It is guarded by the limiter's own mutex
+	resumeCh chan struct{}
+}
+
+// configure applies the shared settings from cfg
+func (b *base) configure(cfg Config) {
+	if cfg.Clock != nil {
+		b.clock = cfg.Clock
+	}
+	if cfg.Jitter > 0 {
+		b.jitter = cfg.Jitter
+	}
+	if cfg.Name != "" {
+		b.name = cfg.Name
+	}
+	if cfg.Listener != nil {
+		b.listener = cfg.Listener
+		b.algo = cfg.Algorithm
+	}
+}
+
+// inherit copies the shared settings of from, leaving the counters at zero
+func (b *base) inherit(from *base) {
+	b.clock = from.clock
+	b.jitter = from.jitter
+	b.name = from.name
+	b.listener = from.listener
+	b.algo = from.algo
+}
+
+// Name returns the name given to the limiter, if any
+func (b *base) Name() string {
+	return b.name
+}
+
+// now returns the current time according to the limiter's clock
+func (b *base) now() time.Time {
+	if b.clock == nil {
+		return time.Now()
+	}
+	return b.clock.Now()
+}
+
+// after returns a channel that fires once d has passed on the limiter's clock
+func (b *base) after(d time.Duration) <-chan time.Time {
+	if b.clock == nil {
+		return time.After(d)
+	}
+	return b.clock.After(d)
+}
+
+// resume wakes the goroutines blocked in awaitActive so they check the
+// limiter again. The limiter's mutex must be held
+func (b *base) resume() {
+	if b.resumeCh != nil {
+		close(b.resumeCh)
+		b.resumeCh = nil
+	}
+}
+
+// awaitActive blocks while idle reports that a zero limit or burst has
+// paused the limiter, until a SetLimit or SetBurst lifts the pause or ctx
+// is done. mu must be held and is held again when awaitActive returns nil
+func (b *base) awaitActive(ctx context.Context, mu *sync.Mutex, idle func() bool) error {
+	for idle() {
+		if b.resumeCh == nil {
+			b.resumeCh = make(chan struct{})
+		}
+		resumed := b.resumeCh
+		mu.Unlock()
+
+		b.waiting.Add(1)
+		select {
+		case <-resumed:
+			b.waiting.Add(-1)
+		case <-ctx.Done():
+			b.waiting.Add(-1)
+			return ctx.Err()
+		}
+		mu.Lock()
+	}
+	return nil
+}
+
+// sleep blocks for d plus a random jitter, or until ctx is done. It fails
+// fast with a RateLimitError if d would outlast ctx's deadline
+func (b *base) sleep(ctx context.Context, d time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return &RateLimitError{RetryAfter: d}
+	}
+	if d > 0 && b.jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(b.jitter)))
+	}
+
+	b.waiting.Add(1)
+	defer b.waiting.Add(-1)
+
+	var after <-chan time.Time
+	if b.clock == nil {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		after = timer.C
+	} else {
+		after = b.clock.After(d)
+	}
+
+	select {
+	case <-after:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitBudget returns how long ctx allows a wait to last. A deadline
+// already reached leaves no time rather than a negative one, so events
+// available at once still pass, as they do with WaitMaxN(ctx, n, 0)
+func waitBudget(ctx context.Context) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		if d := time.Until(deadline); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return InfDuration
+}
+
+// waitMax runs lim.WaitN with maxWait as an extra deadline. WaitN refuses
+// up front to wait past a deadline, so nothing is consumed when it fails
+func waitMax(ctx context.Context, lim Limiter, n int, maxWait time.Duration) error {
+	if maxWait < 0 {
+		maxWait = 0
+	}
+	ctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+	return lim.WaitN(ctx, n)
+}
+
+// WaitTimed runs lim.WaitN and reports how long it blocked, measured on
+// lim's clock, whether or not the wait succeeded
+func WaitTimed(ctx context.Context, lim Limiter, n int) (time.Duration, error) {
+	start := nowOf(lim)
+	err := lim.WaitN(ctx, n)
+	return nowOf(lim).Sub(start), err
+}
+
+// nowOf returns the current time according to lim's clock, or that of
+// the limiter it wraps
+func nowOf(lim Limiter) time.Time {
+	for {
+		if c, ok := lim.(interface{ now() time.Time }); ok {
+			return c.now()
+		}
+		u, ok := lim.(interface{ Unwrap() Limiter })
+		if !ok {
+			return time.Now()
+		}
+		lim = u.Unwrap()
+	}
+}
+
+// NowOf returns the current time according to lim's clock
+func NowOf(lim Limiter) time.Time {
+	return nowOf(lim)
+}
+
+// afterOf is time.After on lim's clock
+func afterOf(lim Limiter, d time.Duration) <-chan time.Time {
+	if c, ok := lim.(interface {
+		after(time.Duration) <-chan time.Time
+	}); ok {
+		return c.after(d)
+	}
+	return time.After(d)
+}
+
+// sleepOf sleeps for d using lim's clock and jitter
+func sleepOf(ctx context.Context, lim Limiter, d time.Duration) error {
+	if s, ok := lim.(interface {
+		sleep(context.Context, time.Duration) error
+	}); ok {
+		return s.sleep(ctx, d)
+	}
+	var b base
+	return b.sleep(ctx, d)
+}
@@ -0,0 +1,158 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Priority identifies a traffic class sharing a PriorityBucketLimiter
+// Higher values may dig deeper into the shared bucket
+type Priority int
+
+// PriorityBucketLimiter implements a token bucket shared by several
+// priority classes. Each class holds back a reserved number of tokens it
+// cannot consume, so higher classes keep being served after lower ones
+// are cut off
+type PriorityBucketLimiter struct {
+	*TokenBucketLimiter
+	reserved []int
+}
+
+// NewPriorityBucket creates a new priority bucket limiter.
+// reserved[p] is the number of tokens class p must leave in the bucket;
+// classes beyond the end of reserved may drain the bucket completely
+func NewPriorityBucket(r Limit, b int, reserved ...int) *PriorityBucketLimiter {
+	floors := make([]int, len(reserved))
+	for i, res := range reserved {
+		if res < 0 {
+			res = 0
+		}
+		if res > b {
+			res = b
+		}
+		// A higher class never has less access than a lower one
+		if i > 0 && res > floors[i-1] {
+			res = floors[i-1]
+		}
+		floors[i] = res
+	}
+
+	return &PriorityBucketLimiter{
+		TokenBucketLimiter: NewTokenBucket(r, b),
+		reserved:           floors,
+	}
+}
+
+func (pb *PriorityBucketLimiter) Algorithm() Algorithm {
+	return PriorityBucket
+}
+
+func (pb *PriorityBucketLimiter) Clone() Limiter {
+	return &PriorityBucketLimiter{
+		TokenBucketLimiter: pb.TokenBucketLimiter.clone(),
+		reserved:           append([]int(nil), pb.reserved...),
+	}
+}
+
+// floor returns the number of tokens reserved away from class p
+func (pb *PriorityBucketLimiter) floor(p Priority) float64 {
+	if p < 0 {
+		p = 0
+	}
+	if int(p) >= len(pb.reserved) {
+		return 0
+	}
+	return float64(pb.reserved[p])
+}
+
+// Reserved returns the number of tokens class p cannot consume
+func (pb *PriorityBucketLimiter) Reserved(p Priority) int {
+	return int(pb.floor(p))
+}
+
+// Allow, AllowN, AllowF, AllowDetails, Reserve, ReserveN, ReserveF, Wait,
+// WaitN and WaitF act on the lowest class
+
+func (pb *PriorityBucketLimiter) Allow() bool {
+	return pb.AllowNPriority(pb.now(), 1, 0)
+}
+
+func (pb *PriorityBucketLimiter) AllowN(t time.Time, n int) bool {
+	return pb.AllowNPriority(t, n, 0)
+}
+
+func (pb *PriorityBucketLimiter) AllowDetails(n int) (bool, Result) {
+	return pb.AllowDetailsAt(pb.now(), n)
+}
+
+func (pb *PriorityBucketLimiter) AllowDetailsAt(t time.Time, n int) (bool, Result) {
+	return pb.allowDetails(t, float64(n), pb.floor(0))
+}
+
+func (pb *PriorityBucketLimiter) Reserve() *Reservation {
+	return pb.ReserveNPriority(pb.now(), 1, 0)
+}
+
+func (pb *PriorityBucketLimiter) ReserveN(t time.Time, n int) *Reservation {
+	return pb.ReserveNPriority(t, n, 0)
+}
+
+func (pb *PriorityBucketLimiter) Wait(ctx context.Context) error {
+	return pb.WaitNPriority(ctx, 1, 0)
+}
+
+func (pb *PriorityBucketLimiter) WaitN(ctx context.Context, n int) error {
+	return pb.WaitNPriority(ctx, n, 0)
+}
+
+func (pb *PriorityBucketLimiter) WaitMaxN(ctx context.Context, n int, maxWait time.Duration) error {
+	return waitMax(ctx, pb, n, maxWait)
+}
+
+func (pb *PriorityBucketLimiter) AllowF(t time.Time, n float64) bool {
+	return pb.allowN(t, n, pb.floor(0))
+}
+
+func (pb *PriorityBucketLimiter) ReserveF(t time.Time, n float64) *Reservation {
+	r := pb.reserveN(t, n, pb.floor(0), InfDuration)
+	pb.record(t, n, r.OK(), DeniedExceeds)
+	return r
+}
+
+// reserveQuiet reserves for the lowest class, like ReserveN, without
+// recording the decision
+func (pb *PriorityBucketLimiter) reserveQuiet(t time.Time, n int, maxWait time.Duration) *Reservation {
+	return pb.reserveN(t, float64(n), pb.floor(0), maxWait)
+}
+
+func (pb *PriorityBucketLimiter) WaitF(ctx context.Context, n float64) error {
+	return pb.waitN(ctx, n, pb.floor(0))
+}
+
+// Remaining leaves out the tokens reserved away from the lowest class
+func (pb *PriorityBucketLimiter) Remaining() float64 {
+	return math.Max(0, pb.TokenBucketLimiter.Remaining()-pb.floor(0))
+}
+
+// AllowPriority is shorthand for AllowNPriority(time.Now(), 1, p)
+func (pb *PriorityBucketLimiter) AllowPriority(p Priority) bool {
+	return pb.AllowNPriority(pb.now(), 1, p)
+}
+
+// AllowNPriority reports whether n events of class p may happen at time t
+func (pb *PriorityBucketLimiter) AllowNPriority(t time.Time, n int, p Priority) bool {
+	return pb.allowN(t, float64(n), pb.floor(p))
+}
+
+// ReserveNPriority reserves n tokens for class p
+func (pb *PriorityBucketLimiter) ReserveNPriority(t time.Time, n int, p Priority) *Reservation {
+	r := pb.reserveN(t, float64(n), pb.floor(p), InfDuration)
+	pb.record(t, float64(n), r.OK(), DeniedExceeds)
+	return r
+}
+
+// WaitNPriority blocks until n events of class p are allowed
+func (pb *PriorityBucketLimiter) WaitNPriority(ctx context.Context, n int, p Priority) error {
+	return pb.waitN(ctx, float64(n), pb.floor(p))
+}
@@ -0,0 +1,49 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+// rampSteps is the number of SetLimit calls a ramp is spread over
+const rampSteps = 20
+
+// rampLimit moves the limit from from to to in rampSteps even steps over
+// the given duration, on a goroutine driven by the limiter's clock
+// set applies one step; current reads back the limit it controls so the
+// ramp can stop once someone else has changed it. A newer ramp on the
+// same limiter also stops this one
+func (b *base) rampLimit(from, to Limit, over time.Duration, current func() Limit, set func(time.Time, Limit)) {
+	gen := b.ramps.Add(1)
+
+	inf := Limit(math.MaxFloat64)
+	if over <= 0 || from == to || from == inf || to == inf {
+		set(b.now(), to)
+		return
+	}
+
+	start := b.now()
+	step := over / rampSteps
+	if step <= 0 {
+		step = 1
+	}
+
+	go func() {
+		last := current()
+		for {
+			<-b.after(step)
+
+			if b.ramps.Load() != gen || current() != last {
+				return
+			}
+
+			t := b.now()
+			frac := math.Min(1, float64(t.Sub(start))/float64(over))
+			set(t, from+Limit(frac)*(to-from))
+			last = current()
+			if frac >= 1 {
+				return
+			}
+		}
+	}()
+}
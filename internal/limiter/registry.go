@@ -0,0 +1,158 @@
+package limiter
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Factory creates a limiter with rate r and burst b
+type Factory func(r Limit, b int) Limiter
+
+type algorithmEntry struct {
+	name    string
+	factory Factory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []algorithmEntry
+	byName     = make(map[string]Algorithm)
+)
+
+func init() {
+	Register("TokenBucket", func(r Limit, b int) Limiter { return NewTokenBucket(r, b) })
+	Register("LeakyBucket", func(r Limit, b int) Limiter { return NewLeakyBucket(r, b) })
+	Register("SlidingWindow", func(r Limit, b int) Limiter { return NewSlidingWindow(r, b) })
+	Register("FixedWindow", func(r Limit, b int) Limiter { return NewFixedWindow(r, b) })
+	Register("PriorityBucket", func(r Limit, b int) Limiter { return NewPriorityBucket(r, b) })
+	Register("MultiWindow", func(r Limit, b int) Limiter {
+		return NewMultiWindow(WindowRule{Count: b, Window: windowFor(r, b)})
+	})
+	Register("EWMA", func(r Limit, b int) Limiter { return NewEWMA(r, b) })
+	Register("Adaptive", func(r Limit, b int) Limiter { return NewAdaptive(r, b) })
+	Register("CalendarQuota", func(r Limit, b int) Limiter { return NewCalendarQuota(b, Daily, time.UTC) })
+	Register("DualRate", func(r Limit, b int) Limiter { return NewTwoRateMeter(r, b, r, b) })
+}
+
+// Register adds an algorithm under the given name and returns its
+// Algorithm value. Built-in algorithms are registered in declaration order,
+// so their constants match the values returned here.
+// It panics if name is empty or already registered, or factory is nil
+func Register(name string, factory Factory) Algorithm {
+	if name == "" {
+		panic("rate: RegisterAlgorithm with empty name")
+	}
+	if factory == nil {
+		panic("rate: RegisterAlgorithm factory is nil")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, dup := byName[name]; dup {
+		panic("rate: RegisterAlgorithm called twice for " + name)
+	}
+
+	algo := Algorithm(len(registry))
+	registry = append(registry, algorithmEntry{name: name, factory: factory})
+	byName[name] = algo
+	return algo
+}
+
+// Lookup returns the algorithm registered under name
+func Lookup(name string) (Algorithm, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	algo, ok := byName[name]
+	return algo, ok
+}
+
+// Parse returns the algorithm registered under name. Case, dashes,
+// underscores and spaces are ignored, so "token-bucket" and "TOKEN_BUCKET"
+// both name TokenBucket
+func Parse(name string) (Algorithm, error) {
+	if algo, ok := Lookup(name); ok {
+		return algo, nil
+	}
+
+	want := foldName(name)
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for i, e := range registry {
+		if foldName(e.name) == want {
+			return Algorithm(i), nil
+		}
+	}
+	return 0, &ConfigError{Field: "algorithm", Value: name, Err: ErrUnknownAlgorithm}
+}
+
+// foldName reduces an algorithm name to its lower case letters and digits
+func foldName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', '_', ' ':
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, name)
+}
+
+// MarshalText encodes a registered algorithm as its name
+func (a Algorithm) MarshalText() ([]byte, error) {
+	e, ok := entry(a)
+	if !ok {
+		return nil, &ConfigError{Field: "algorithm", Value: int(a), Err: ErrUnknownAlgorithm}
+	}
+	return []byte(e.name), nil
+}
+
+// UnmarshalText decodes an algorithm name as accepted by Parse
+func (a *Algorithm) UnmarshalText(text []byte) error {
+	algo, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*a = algo
+	return nil
+}
+
+// Algorithms returns all registered algorithms in registration order
+func Algorithms() []Algorithm {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	algos := make([]Algorithm, len(registry))
+	for i := range registry {
+		algos[i] = Algorithm(i)
+	}
+	return algos
+}
+
+// entry returns the registry entry for algo
+func entry(algo Algorithm) (algorithmEntry, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if algo < 0 || int(algo) >= len(registry) {
+		return algorithmEntry{}, false
+	}
+	return registry[algo], true
+}
+
+// New creates a limiter using the factory registered for algo,
+// falling back to a token bucket for unknown algorithms
+func New(algo Algorithm, r Limit, b int) Limiter {
+	if e, ok := entry(algo); ok {
+		return e.factory(r, b)
+	}
+	return NewTokenBucket(r, b)
+}
+
+// windowFor returns the window in which b events happen at rate r
+func windowFor(r Limit, b int) time.Duration {
+	if r <= 0 || r == Limit(math.MaxFloat64) {
+		return 0
+	}
+	return time.Duration(float64(time.Second) * float64(b) / float64(r))
+}
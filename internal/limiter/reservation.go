@@ -1,14 +1,33 @@
 package limiter
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Reservation holds information about a reserved rate limit event
 type Reservation struct {
 	ok        bool
 	lim       Limiter
-	tokens    int
+	tokens    float64
 	timeToAct time.Time
 	limit     Limit
+
+	// linked holds the reservations a ReserveAll reservation is made of
+	linked []*Reservation
+	// refund gives back a reservation an ExternalLimiter's backend made
+	refund func(t time.Time)
+}
+
+// NotOK returns a reservation that can never be fulfilled
+func NotOK() *Reservation {
+	return &Reservation{ok: false}
+}
+
+// Granted returns an OK reservation acting at t that no limiter backs,
+// so cancelling it gives nothing back
+func Granted(t time.Time) *Reservation {
+	return &Reservation{ok: true, timeToAct: t}
 }
 
 // OK returns whether the reservation is valid
@@ -16,9 +35,24 @@ func (r *Reservation) OK() bool {
 	return r.ok
 }
 
+// TimeToAct returns when the reserved event may happen, or the zero time
+// for a reservation that is not OK
+func (r *Reservation) TimeToAct() time.Time {
+	if !r.ok {
+		return time.Time{}
+	}
+	return r.timeToAct
+}
+
+// Tokens returns the number of tokens the reservation holds. It drops to
+// zero once a cancellation has refunded them
+func (r *Reservation) Tokens() float64 {
+	return r.tokens
+}
+
 // Delay returns how long to wait before the reserved event
 func (r *Reservation) Delay() time.Duration {
-	return r.DelayFrom(time.Now())
+	return r.DelayFrom(nowOf(r.lim))
 }
 
 // DelayFrom returns the delay from the given time
@@ -33,16 +67,53 @@ func (r *Reservation) DelayFrom(t time.Time) time.Duration {
 	return delay
 }
 
-// Cancel cancels the reservation (best effort)
+// Act blocks until the reservation's time to act. If ctx is done first,
+// or its deadline falls before that time, the reservation is cancelled
+// and the error returned
+func (r *Reservation) Act(ctx context.Context) error {
+	if !r.ok {
+		return ErrReservationNotOK
+	}
+
+	delay := r.Delay()
+	if delay == 0 {
+		return nil
+	}
+	if err := sleepOf(ctx, r.lim, delay); err != nil {
+		r.Cancel()
+		return err
+	}
+	return nil
+}
+
+// restorer is implemented by limiters that can give back the capacity
+// held by a cancelled reservation. restore must zero r.tokens under the
+// limiter's lock so a reservation is only refunded once
+type restorer interface {
+	restore(t time.Time, r *Reservation)
+}
+
+// Cancel cancels the reservation, returning its capacity to the limiter
 func (r *Reservation) Cancel() {
-	r.CancelAt(time.Now())
+	r.CancelAt(nowOf(r.lim))
 }
 
-// CancelAt cancels the reservation at the given time (best effort)
+// CancelAt cancels the reservation at the given time. Token and leaky
+// buckets refund reservations that have not acted yet; window algorithms
+// refund events while they still count against the window. Meters cannot
+// tell which bucket an event drew from and ignore cancellation
 func (r *Reservation) CancelAt(t time.Time) {
 	if !r.ok {
 		return
 	}
-	// Note: Not all algorithms can properly restore tokens
-	// This is a best-effort operation
+	if r.linked != nil {
+		for _, l := range r.linked {
+			l.CancelAt(t)
+		}
+		r.linked, r.tokens = nil, 0
+		return
+	}
+	if res, ok := r.lim.(restorer); ok {
+		res.restore(t, r)
+	}
 }
@@ -0,0 +1,55 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+// InfDuration is the RetryAfter of a request that can never be admitted
+const InfDuration = time.Duration(math.MaxInt64)
+
+// Result describes a limiter's state right after an AllowDetails call
+type Result struct {
+	// Limit is the number of events the limiter admits at once: its burst,
+	// window count or quota
+	Limit int
+	// Remaining is the number of events that would still be admitted now
+	Remaining int
+	// ResetAt is when the limiter is back to full capacity, or the zero
+	// time if it never refills
+	ResetAt time.Time
+	// RetryAfter is how long to wait before the request would be admitted:
+	// zero if it was allowed, InfDuration if it never can be
+	RetryAfter time.Duration
+}
+
+// tokenDelay returns how long rate r takes to produce tokens
+func tokenDelay(tokens float64, r Limit) time.Duration {
+	if tokens <= 0 || r == Limit(math.MaxFloat64) {
+		return 0
+	}
+	if r <= 0 {
+		return InfDuration
+	}
+	d := math.Ceil(tokens / float64(r) * float64(time.Second))
+	if d >= float64(InfDuration) {
+		return InfDuration
+	}
+	return time.Duration(d)
+}
+
+// resetAfter returns t+d, or the zero time if d is InfDuration
+func resetAfter(t time.Time, d time.Duration) time.Time {
+	if d == InfDuration {
+		return time.Time{}
+	}
+	return t.Add(d)
+}
+
+// whole returns the number of complete events in tokens
+func whole(tokens float64) int {
+	if tokens <= 0 {
+		return 0
+	}
+	return int(tokens)
+}
@@ -0,0 +1,271 @@
+package limiter
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronHorizon is how many days a schedule is searched for a firing time
+// Five years covers every spec that can fire at all, e.g. February 29th
+// falling on a Monday
+const cronHorizon = 5 * 366
+
+// ScheduleRule sets a limiter's Limit and Burst each time Spec fires,
+// where they stay until another rule fires
+// Spec is either a time of day ("22:30") or a five field cron expression
+// ("minute hour day-of-month month day-of-week", e.g. "0 1 * * 1-5")
+// A Burst of 0 leaves the burst unchanged
+type ScheduleRule struct {
+	Spec  string
+	Limit Limit
+	Burst int
+}
+
+// Schedule switches a limiter between rules by time of day
+type Schedule struct {
+	rules    []ScheduleRule
+	specs    []*cronSpec
+	location *time.Location
+}
+
+// NewSchedule parses rules into a schedule evaluated in loc
+// A nil loc means UTC
+func NewSchedule(loc *time.Location, rules ...ScheduleRule) (*Schedule, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	s := &Schedule{
+		rules:    append([]ScheduleRule(nil), rules...),
+		specs:    make([]*cronSpec, len(rules)),
+		location: loc,
+	}
+	for i, rule := range rules {
+		spec, err := parseSpec(rule.Spec)
+		if err != nil {
+			return nil, err
+		}
+		s.specs[i] = spec
+	}
+	return s, nil
+}
+
+// At returns the rule in force at t: the one that fired most recently
+// Later rules win ties. ok is false if no rule has ever fired
+func (s *Schedule) At(t time.Time) (rule ScheduleRule, ok bool) {
+	i, _ := s.active(t)
+	if i < 0 {
+		return ScheduleRule{}, false
+	}
+	return s.rules[i], true
+}
+
+// Next returns the first time after t at which a rule fires, or the zero
+// time if none ever does
+func (s *Schedule) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, spec := range s.specs {
+		if at, ok := spec.next(t.In(s.location)); ok && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+	return next
+}
+
+// active returns the index of the rule in force at t and when it fired,
+// or -1 if none has
+func (s *Schedule) active(t time.Time) (int, time.Time) {
+	idx, fired := -1, time.Time{}
+	for i, spec := range s.specs {
+		if at, ok := spec.prev(t.In(s.location)); ok && (idx < 0 || !at.Before(fired)) {
+			idx, fired = i, at
+		}
+	}
+	return idx, fired
+}
+
+// Run applies the schedule to lim until ctx is done, reading time from
+// lim's clock. The rule in force is applied straight away and again each
+// time a rule fires. Run returns ctx.Err()
+func (s *Schedule) Run(ctx context.Context, lim Limiter) error {
+	var applied time.Time
+	for {
+		now := nowOf(lim)
+		if i, fired := s.active(now); i >= 0 && !fired.Equal(applied) {
+			rule := s.rules[i]
+			lim.SetLimitAt(now, rule.Limit)
+			if rule.Burst > 0 {
+				lim.SetBurstAt(now, rule.Burst)
+			}
+			applied = fired
+		}
+
+		var wake <-chan time.Time
+		if next := s.Next(now); !next.IsZero() {
+			wake = afterOf(lim, next.Sub(now))
+		}
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// cronSpec is a parsed cron expression, one bit per allowed value
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+
+	// A restricted day of month and day of week match if either does;
+	// when one of them is "*" both must match
+	domStar, dowStar bool
+}
+
+func parseSpec(spec string) (*cronSpec, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 1 {
+		// Time of day shorthand for a daily rule
+		hh, mm, ok := strings.Cut(fields[0], ":")
+		if !ok {
+			return nil, &ConfigError{Field: "spec", Value: spec, Err: ErrInvalidSchedule}
+		}
+		fields = []string{mm, hh, "*", "*", "*"}
+	}
+	if len(fields) != 5 {
+		return nil, &ConfigError{Field: "spec", Value: spec, Err: ErrInvalidSchedule}
+	}
+
+	var c cronSpec
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	dst := [5]*uint64{&c.minute, &c.hour, &c.dom, &c.month, &c.dow}
+	for i, f := range fields {
+		bits, ok := parseField(f, bounds[i][0], bounds[i][1])
+		if !ok {
+			return nil, &ConfigError{Field: "spec", Value: spec, Err: ErrInvalidSchedule}
+		}
+		*dst[i] = bits
+	}
+	// Both 0 and 7 mean Sunday
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = strings.HasPrefix(fields[2], "*")
+	c.dowStar = strings.HasPrefix(fields[4], "*")
+	return &c, nil
+}
+
+// parseField parses a comma separated list of values, ranges ("1-5") and
+// steps ("*/15", "0-30/10") within [lo, hi]
+func parseField(f string, lo, hi int) (uint64, bool) {
+	var bits uint64
+	for _, part := range strings.Split(f, ",") {
+		step := 1
+		if rng, s, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				return 0, false
+			}
+			part, step = rng, n
+		}
+
+		first, last := lo, hi
+		if part != "*" {
+			a, b, isRange := strings.Cut(part, "-")
+			var err error
+			if first, err = strconv.Atoi(a); err != nil {
+				return 0, false
+			}
+			switch {
+			case isRange:
+				if last, err = strconv.Atoi(b); err != nil {
+					return 0, false
+				}
+			case step == 1:
+				last = first
+			}
+		}
+		if first < lo || last > hi || first > last {
+			return 0, false
+		}
+
+		for v := first; v <= last; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, true
+}
+
+func (c *cronSpec) matchDay(t time.Time) bool {
+	if c.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// prev returns the latest firing time at or before t
+func (c *cronSpec) prev(t time.Time) (time.Time, bool) {
+	y, mo, d := t.Date()
+	for i := 0; i < cronHorizon; i++ {
+		day := time.Date(y, mo, d-i, 0, 0, 0, 0, t.Location())
+		if !c.matchDay(day) {
+			continue
+		}
+		lastHour := 23
+		if i == 0 {
+			lastHour = t.Hour()
+		}
+		for h := lastHour; h >= 0; h-- {
+			if c.hour&(1<<h) == 0 {
+				continue
+			}
+			lastMinute := 59
+			if i == 0 && h == t.Hour() {
+				lastMinute = t.Minute()
+			}
+			for m := lastMinute; m >= 0; m-- {
+				if c.minute&(1<<m) != 0 {
+					return time.Date(y, mo, d-i, h, m, 0, 0, t.Location()), true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// next returns the earliest firing time after t
+func (c *cronSpec) next(t time.Time) (time.Time, bool) {
+	y, mo, d := t.Date()
+	start := time.Date(y, mo, d, t.Hour(), t.Minute(), 0, 0, t.Location()).Add(time.Minute)
+	y, mo, d = start.Date()
+	for i := 0; i < cronHorizon; i++ {
+		day := time.Date(y, mo, d+i, 0, 0, 0, 0, t.Location())
+		if !c.matchDay(day) {
+			continue
+		}
+		firstHour := 0
+		if i == 0 {
+			firstHour = start.Hour()
+		}
+		for h := firstHour; h < 24; h++ {
+			if c.hour&(1<<h) == 0 {
+				continue
+			}
+			firstMinute := 0
+			if i == 0 && h == start.Hour() {
+				firstMinute = start.Minute()
+			}
+			for m := firstMinute; m < 60; m++ {
+				if c.minute&(1<<m) != 0 {
+					return time.Date(y, mo, d+i, h, m, 0, 0, t.Location()), true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
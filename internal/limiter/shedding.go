@@ -0,0 +1,134 @@
+package limiter
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// SheddingLimiter wraps a Limiter and rejects a growing fraction of Allow
+// calls as utilization approaches the limit (RED-style early shedding)
+// Below the threshold every call is passed through; from there the drop
+// probability rises linearly to 1 when no capacity remains
+type SheddingLimiter struct {
+	Limiter
+	threshold float64
+	random    func() float64
+}
+
+// NewShedding wraps lim with probabilistic early shedding starting at the
+// given utilization threshold (0..1)
+func NewShedding(lim Limiter, threshold float64) *SheddingLimiter {
+	if threshold < 0 {
+		threshold = 0
+	}
+	if threshold > 1 {
+		threshold = 1
+	}
+	return &SheddingLimiter{
+		Limiter:   lim,
+		threshold: threshold,
+		random:    rand.Float64,
+	}
+}
+
+// Threshold returns the utilization at which shedding starts
+func (s *SheddingLimiter) Threshold() float64 {
+	return s.threshold
+}
+
+// Clone wraps a clone of the wrapped limiter with the same threshold
+func (s *SheddingLimiter) Clone() Limiter {
+	c := NewShedding(s.Limiter.Clone(), s.threshold)
+	c.random = s.random
+	return c
+}
+
+// DropProbability returns the chance that a call at time t is shed
+func (s *SheddingLimiter) DropProbability(t time.Time) float64 {
+	burst := s.Limiter.Burst()
+	if burst <= 0 || s.threshold >= 1 {
+		return 0
+	}
+
+	utilization := 1 - s.Limiter.TokensAt(t)/float64(burst)
+	if utilization <= s.threshold {
+		return 0
+	}
+	p := (utilization - s.threshold) / (1 - s.threshold)
+	if p > 1 {
+		return 1
+	}
+	return p
+}
+
+func (s *SheddingLimiter) Allow() bool {
+	return s.AllowN(nowOf(s.Limiter), 1)
+}
+
+// AllowN sheds the call with DropProbability before consulting the wrapped
+// limiter. Shed calls consume no capacity and count in the wrapped
+// limiter's Stats as DeniedShed
+func (s *SheddingLimiter) AllowN(t time.Time, n int) bool {
+	if s.shed(t) {
+		recordOn(s.Limiter, t, float64(n), false, DeniedShed)
+		return false
+	}
+	return s.Limiter.AllowN(t, n)
+}
+
+// shed reports whether a call at t is dropped early
+func (s *SheddingLimiter) shed(t time.Time) bool {
+	p := s.DropProbability(t)
+	return p > 0 && s.random() < p
+}
+
+func (s *SheddingLimiter) AllowDetails(n int) (bool, Result) {
+	return s.AllowDetailsAt(nowOf(s.Limiter), n)
+}
+
+// AllowDetailsAt sheds like AllowN. A shed call reports the wrapped
+// limiter's state with no RetryAfter, as shedding is not tied to a refill
+func (s *SheddingLimiter) AllowDetailsAt(t time.Time, n int) (bool, Result) {
+	if s.shed(t) {
+		res := Result{Limit: s.Limiter.Burst(), ResetAt: s.Limiter.ResetAt()}
+		if tokens := s.Limiter.TokensAt(t); tokens > 0 {
+			res.Remaining = int(tokens)
+		}
+		recordOn(s.Limiter, t, float64(n), false, DeniedShed)
+		return false, res
+	}
+	return s.Limiter.AllowDetailsAt(t, n)
+}
+
+// The shedder keeps no state of its own; these export the wrapped limiter's
+
+func (s *SheddingLimiter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Limiter)
+}
+
+func (s *SheddingLimiter) UnmarshalJSON(data []byte) error {
+	u, ok := s.Limiter.(json.Unmarshaler)
+	if !ok {
+		return fmt.Errorf("%w: %T cannot restore state", ErrStateMismatch, s.Limiter)
+	}
+	return u.UnmarshalJSON(data)
+}
+
+func (s *SheddingLimiter) MarshalBinary() ([]byte, error) {
+	m, ok := s.Limiter.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T cannot export state", ErrStateMismatch, s.Limiter)
+	}
+	return m.MarshalBinary()
+}
+
+func (s *SheddingLimiter) UnmarshalBinary(data []byte) error {
+	u, ok := s.Limiter.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return fmt.Errorf("%w: %T cannot restore state", ErrStateMismatch, s.Limiter)
+	}
+	return u.UnmarshalBinary(data)
+}
@@ -2,7 +2,7 @@ package limiter
 
 import (
 	"context"
-	"fmt"
+	"math"
 	"sync"
 	"time"
 )
@@ -10,11 +10,13 @@ import (
 // SlidingWindowLimiter implements the sliding window algorithm
 // Tracks requests within a rolling time window
 type SlidingWindowLimiter struct {
+	base
 	mu         sync.Mutex
 	limit      Limit
 	maxCount   int
 	window     time.Duration
 	timestamps []time.Time
+	waiters    waitQueue
 }
 
 // NewSlidingWindow creates a new sliding window limiter
@@ -24,14 +26,29 @@ func NewSlidingWindow(r Limit, maxCount int) *SlidingWindowLimiter {
 		window = time.Duration(float64(time.Second) * float64(maxCount) / float64(r))
 	}
 
+	sw := NewSlidingWindowDuration(maxCount, window)
+	sw.limit = r
+	return sw
+}
+
+// NewSlidingWindowDuration creates a sliding window limiter allowing
+// maxCount events in any window of the given duration
+func NewSlidingWindowDuration(maxCount int, window time.Duration) *SlidingWindowLimiter {
 	return &SlidingWindowLimiter{
-		limit:      r,
+		limit:      Limit(float64(maxCount) / window.Seconds()),
 		maxCount:   maxCount,
 		window:     window,
 		timestamps: make([]time.Time, 0, maxCount),
 	}
 }
 
+// Window returns the window duration
+func (sw *SlidingWindowLimiter) Window() time.Duration {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.window
+}
+
 func (sw *SlidingWindowLimiter) Algorithm() Algorithm {
 	return SlidingWindow
 }
@@ -44,6 +61,45 @@ func (sw *SlidingWindowLimiter) Capabilities() Capabilities {
 	}
 }
 
+func (sw *SlidingWindowLimiter) Stats() Stats {
+	return sw.stats(sw.Tokens())
+}
+
+// Configure applies cfg; a window replaces the one derived from the limit
+func (sw *SlidingWindowLimiter) Configure(cfg Config) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.configure(cfg)
+	if cfg.Window > 0 {
+		sw.window = cfg.Window
+		sw.limit = Limit(float64(sw.maxCount) / cfg.Window.Seconds())
+	}
+}
+
+func (sw *SlidingWindowLimiter) Reset() {
+	sw.ResetTo(sw.now())
+}
+
+func (sw *SlidingWindowLimiter) ResetTo(t time.Time) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.timestamps = nil
+}
+
+func (sw *SlidingWindowLimiter) Clone() Limiter {
+	sw.mu.Lock()
+	c := &SlidingWindowLimiter{limit: sw.limit, maxCount: sw.maxCount, window: sw.window}
+	sw.mu.Unlock()
+	c.inherit(&sw.base)
+	return c
+}
+
+// idle reports whether a zero limit or count has paused the window, in
+// which case it admits nothing. sw.mu must be held
+func (sw *SlidingWindowLimiter) idle() bool {
+	return sw.limit == 0 || sw.maxCount == 0
+}
+
 // cleanup removes timestamps outside the current window
 func (sw *SlidingWindowLimiter) cleanup(now time.Time) {
 	cutoff := now.Add(-sw.window)
@@ -55,28 +111,98 @@ func (sw *SlidingWindowLimiter) cleanup(now time.Time) {
 }
 
 func (sw *SlidingWindowLimiter) Allow() bool {
-	return sw.AllowN(time.Now(), 1)
+	return sw.AllowN(sw.now(), 1)
 }
 
 func (sw *SlidingWindowLimiter) AllowN(t time.Time, n int) bool {
+	ok, _ := sw.AllowDetailsAt(t, n)
+	return ok
+}
+
+func (sw *SlidingWindowLimiter) AllowDetails(n int) (bool, Result) {
+	return sw.AllowDetailsAt(sw.now(), n)
+}
+
+func (sw *SlidingWindowLimiter) AllowDetailsAt(t time.Time, n int) (bool, Result) {
 	sw.mu.Lock()
 	defer sw.mu.Unlock()
+	ok, res := sw.allowDetails(t, n)
+	sw.record(t, float64(n), ok, deniedAfter(res.RetryAfter))
+	return ok, res
+}
 
+// allowDetails decides n events at t without recording the decision.
+// sw.mu must be held
+func (sw *SlidingWindowLimiter) allowDetails(t time.Time, n int) (bool, Result) {
 	sw.cleanup(t)
 
-	if len(sw.timestamps)+n <= sw.maxCount {
-		for i := 0; i < n; i++ {
-			sw.timestamps = append(sw.timestamps, t)
+	ok := !sw.idle() && len(sw.timestamps)+n <= sw.maxCount
+	if ok {
+		sw.take(t, n)
+	}
+
+	remaining, resetAt := sw.headroom(t)
+	res := Result{
+		Limit:     sw.maxCount,
+		Remaining: whole(remaining),
+		ResetAt:   resetAt,
+	}
+	if !ok {
+		if sw.idle() || n > sw.maxCount {
+			res.RetryAfter = InfDuration
+		} else {
+			// Room opens up once enough of the oldest events expire
+			expiring := sw.timestamps[len(sw.timestamps)+n-sw.maxCount-1]
+			res.RetryAfter = expiring.Add(sw.window).Sub(t)
 		}
-		return true
 	}
-	return false
+	return ok, res
+}
+
+// reserveQuiet decides n events at t for reserveAll, which records the
+// decision itself
+func (sw *SlidingWindowLimiter) reserveQuiet(t time.Time, n int, maxWait time.Duration) *Reservation {
+	sw.mu.Lock()
+	ok, res := sw.allowDetails(t, n)
+	sw.mu.Unlock()
+	return decided(sw, t, n, ok, res.RetryAfter)
+}
+
+// headroom returns the events left in the window at t and when the newest
+// one expires. sw.mu must be held and the window cleaned up to t
+func (sw *SlidingWindowLimiter) headroom(t time.Time) (float64, time.Time) {
+	remaining := math.Max(0, float64(sw.maxCount-len(sw.timestamps)))
+	if sw.idle() {
+		remaining = 0
+	}
+	if len(sw.timestamps) == 0 {
+		return remaining, t
+	}
+	return remaining, sw.timestamps[len(sw.timestamps)-1].Add(sw.window)
+}
+
+func (sw *SlidingWindowLimiter) Remaining() float64 {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	now := sw.now()
+	sw.cleanup(now)
+	remaining, _ := sw.headroom(now)
+	return remaining
+}
+
+func (sw *SlidingWindowLimiter) ResetAt() time.Time {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	now := sw.now()
+	sw.cleanup(now)
+	_, resetAt := sw.headroom(now)
+	return resetAt
 }
 
 // Reserve returns a reservation that's either immediate or not OK
 // (sliding window can't predict future availability)
 func (sw *SlidingWindowLimiter) Reserve() *Reservation {
-	return sw.ReserveN(time.Now(), 1)
+	return sw.ReserveN(sw.now(), 1)
 }
 
 func (sw *SlidingWindowLimiter) ReserveN(t time.Time, n int) *Reservation {
@@ -84,7 +210,7 @@ func (sw *SlidingWindowLimiter) ReserveN(t time.Time, n int) *Reservation {
 		return &Reservation{
 			ok:        true,
 			lim:       sw,
-			tokens:    n,
+			tokens:    float64(n),
 			timeToAct: t,
 			limit:     sw.limit,
 		}
@@ -92,45 +218,127 @@ func (sw *SlidingWindowLimiter) ReserveN(t time.Time, n int) *Reservation {
 	return &Reservation{ok: false}
 }
 
+// restore forgets the reservation's events if they are still in the window
+func (sw *SlidingWindowLimiter) restore(t time.Time, r *Reservation) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if r.tokens == 0 {
+		return
+	}
+	sw.cleanup(t)
+
+	n := int(r.tokens)
+	for i := len(sw.timestamps) - 1; i >= 0 && n > 0; i-- {
+		if sw.timestamps[i].Equal(r.timeToAct) {
+			sw.timestamps = append(sw.timestamps[:i], sw.timestamps[i+1:]...)
+			n--
+		}
+	}
+	r.tokens = 0
+}
+
 func (sw *SlidingWindowLimiter) Wait(ctx context.Context) error {
 	return sw.WaitN(ctx, 1)
 }
 
-func (sw *SlidingWindowLimiter) WaitN(ctx context.Context, n int) error {
+// WaitN admits blocked callers in arrival order; a caller never jumps
+// ahead of one that is already waiting. While the window is paused the
+// caller at the front waits for SetLimit or SetBurst
+func (sw *SlidingWindowLimiter) WaitN(ctx context.Context, n int) (err error) {
+	start := sw.now()
+	defer func() { sw.recordWait(start, float64(n), err) }()
+
 	sw.mu.Lock()
-	now := time.Now()
+	now := sw.now()
 	sw.cleanup(now)
 
-	if n > sw.maxCount {
+	if !sw.idle() && n > sw.maxCount {
 		sw.mu.Unlock()
-		return fmt.Errorf("rate: requested tokens (%d) exceeds limit (%d)", n, sw.maxCount)
+		return errExceeds(n, "limit", sw.maxCount)
 	}
 
-	// Calculate wait time if needed
-	if len(sw.timestamps)+n > sw.maxCount {
-		// Need to wait for oldest requests to expire
-		needToExpire := len(sw.timestamps) + n - sw.maxCount
-		if needToExpire > len(sw.timestamps) {
-			needToExpire = len(sw.timestamps)
+	fits := !sw.idle() && len(sw.timestamps)+n <= sw.maxCount
+	if fits && !sw.waiters.busy {
+		sw.take(now, n)
+		sw.mu.Unlock()
+		return nil
+	}
+	if !fits && !sw.idle() {
+		if d := sw.expiryDelay(now, n); d > waitBudget(ctx) {
+			sw.mu.Unlock()
+			return &RateLimitError{RetryAfter: d}
 		}
-		oldestToKeep := sw.timestamps[needToExpire-1]
-		waitUntil := oldestToKeep.Add(sw.window).Add(time.Millisecond)
+	}
+
+	turn := sw.waiters.join()
+	sw.mu.Unlock()
+
+	if err := sw.awaitTurn(ctx, turn); err != nil {
+		sw.mu.Lock()
+		sw.waiters.leave(turn)
 		sw.mu.Unlock()
+		return err
+	}
+
+	for {
+		sw.mu.Lock()
+		if err := sw.awaitActive(ctx, &sw.mu, sw.idle); err != nil {
+			sw.mu.Lock()
+			sw.waiters.next()
+			sw.mu.Unlock()
+			return err
+		}
+		now := sw.now()
+		sw.cleanup(now)
 
-		select {
-		case <-time.After(time.Until(waitUntil)):
-			return sw.WaitN(ctx, n) // Retry
-		case <-ctx.Done():
-			return ctx.Err()
+		if n > sw.maxCount {
+			sw.waiters.next()
+			sw.mu.Unlock()
+			return errExceeds(n, "limit", sw.maxCount)
+		}
+		if len(sw.timestamps)+n <= sw.maxCount {
+			sw.take(now, n)
+			sw.waiters.next()
+			sw.mu.Unlock()
+			return nil
+		}
+
+		delay := sw.expiryDelay(now, n)
+		sw.mu.Unlock()
+
+		if err := sw.sleep(ctx, delay); err != nil {
+			sw.mu.Lock()
+			sw.waiters.next()
+			sw.mu.Unlock()
+			return err
 		}
 	}
+}
 
-	// We have capacity
+// take records n events at now
+func (sw *SlidingWindowLimiter) take(now time.Time, n int) {
 	for i := 0; i < n; i++ {
 		sw.timestamps = append(sw.timestamps, now)
 	}
-	sw.mu.Unlock()
-	return nil
+}
+
+// expiryDelay returns how long until enough of the oldest events expire
+// to make room for n more
+func (sw *SlidingWindowLimiter) expiryDelay(now time.Time, n int) time.Duration {
+	needToExpire := len(sw.timestamps) + n - sw.maxCount
+	if needToExpire <= 0 {
+		return 0
+	}
+	if needToExpire > len(sw.timestamps) {
+		needToExpire = len(sw.timestamps)
+	}
+	oldestToKeep := sw.timestamps[needToExpire-1]
+	return oldestToKeep.Add(sw.window).Add(time.Millisecond).Sub(now)
+}
+
+func (sw *SlidingWindowLimiter) WaitMaxN(ctx context.Context, n int, maxWait time.Duration) error {
+	return waitMax(ctx, sw, n, maxWait)
 }
 
 func (sw *SlidingWindowLimiter) Limit() Limit {
@@ -140,7 +348,7 @@ func (sw *SlidingWindowLimiter) Limit() Limit {
 }
 
 func (sw *SlidingWindowLimiter) SetLimit(newLimit Limit) {
-	sw.SetLimitAt(time.Now(), newLimit)
+	sw.SetLimitAt(sw.now(), newLimit)
 }
 
 func (sw *SlidingWindowLimiter) SetLimitAt(t time.Time, newLimit Limit) {
@@ -151,6 +359,11 @@ func (sw *SlidingWindowLimiter) SetLimitAt(t time.Time, newLimit Limit) {
 	if newLimit > 0 {
 		sw.window = time.Duration(float64(time.Second) * float64(sw.maxCount) / float64(newLimit))
 	}
+	sw.resume()
+}
+
+func (sw *SlidingWindowLimiter) RampLimit(target Limit, over time.Duration) {
+	sw.rampLimit(sw.Limit(), target, over, sw.Limit, sw.SetLimitAt)
 }
 
 func (sw *SlidingWindowLimiter) Burst() int {
@@ -160,7 +373,7 @@ func (sw *SlidingWindowLimiter) Burst() int {
 }
 
 func (sw *SlidingWindowLimiter) SetBurst(newBurst int) {
-	sw.SetBurstAt(time.Now(), newBurst)
+	sw.SetBurstAt(sw.now(), newBurst)
 }
 
 func (sw *SlidingWindowLimiter) SetBurstAt(t time.Time, newBurst int) {
@@ -171,11 +384,12 @@ func (sw *SlidingWindowLimiter) SetBurstAt(t time.Time, newBurst int) {
 	if sw.limit > 0 {
 		sw.window = time.Duration(float64(time.Second) * float64(newBurst) / float64(sw.limit))
 	}
+	sw.resume()
 }
 
 // Tokens returns remaining capacity in current window
 func (sw *SlidingWindowLimiter) Tokens() float64 {
-	return sw.TokensAt(time.Now())
+	return sw.TokensAt(sw.now())
 }
 
 func (sw *SlidingWindowLimiter) TokensAt(t time.Time) float64 {
@@ -184,3 +398,44 @@ func (sw *SlidingWindowLimiter) TokensAt(t time.Time) float64 {
 	sw.cleanup(t)
 	return float64(sw.maxCount - len(sw.timestamps))
 }
+
+type slidingWindowState struct {
+	Timestamps []time.Time `json:"timestamps"`
+}
+
+func (sw *SlidingWindowLimiter) exportState() slidingWindowState {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return slidingWindowState{Timestamps: append([]time.Time(nil), sw.timestamps...)}
+}
+
+func (sw *SlidingWindowLimiter) importState(s slidingWindowState) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.timestamps = append([]time.Time(nil), s.Timestamps...)
+	return nil
+}
+
+func (sw *SlidingWindowLimiter) MarshalJSON() ([]byte, error) {
+	return marshalState("SlidingWindow", sw.exportState())
+}
+
+func (sw *SlidingWindowLimiter) UnmarshalJSON(data []byte) error {
+	var s slidingWindowState
+	if err := unmarshalState("SlidingWindow", data, &s); err != nil {
+		return err
+	}
+	return sw.importState(s)
+}
+
+func (sw *SlidingWindowLimiter) MarshalBinary() ([]byte, error) {
+	return encodeState("SlidingWindow", sw.exportState())
+}
+
+func (sw *SlidingWindowLimiter) UnmarshalBinary(data []byte) error {
+	var s slidingWindowState
+	if err := decodeState("SlidingWindow", data, &s); err != nil {
+		return err
+	}
+	return sw.importState(s)
+}
@@ -0,0 +1,80 @@
+package limiter
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+// Every limiter exports its dynamic state (tokens, counters, timestamps)
+// through MarshalJSON and MarshalBinary, and restores it through the
+// matching Unmarshal method. Configuration is not part of the state: it
+// is restored into a limiter constructed with the same parameters
+
+// envelope tags exported state with the kind of limiter it came from, so
+// state is never restored into a limiter that reads it differently
+type envelope[S any] struct {
+	Kind  string `json:"kind"`
+	State S      `json:"state"`
+}
+
+func marshalState[S any](kind string, s S) ([]byte, error) {
+	return json.Marshal(envelope[S]{Kind: kind, State: s})
+}
+
+func unmarshalState[S any](kind string, data []byte, s *S) error {
+	var env envelope[S]
+	if err := json.Unmarshal(data, &env); err != nil {
+		return err
+	}
+	if env.Kind != kind {
+		return fmt.Errorf("%w: cannot restore %s state into %s", ErrStateMismatch, env.Kind, kind)
+	}
+	*s = env.State
+	return nil
+}
+
+func encodeState[S any](kind string, s S) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(envelope[S]{Kind: kind, State: s}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeState[S any](kind string, data []byte, s *S) error {
+	var env envelope[S]
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&env); err != nil {
+		return err
+	}
+	if env.Kind != kind {
+		return fmt.Errorf("%w: cannot restore %s state into %s", ErrStateMismatch, env.Kind, kind)
+	}
+	*s = env.State
+	return nil
+}
+
+// CloneWithState is like lim.Clone but also carries over lim's current
+// state through its binary encoding. Stats still start empty
+func CloneWithState(lim Limiter) (Limiter, error) {
+	m, ok := lim.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T cannot export state", ErrStateMismatch, lim)
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	c := lim.Clone()
+	u, ok := c.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T cannot restore state", ErrStateMismatch, c)
+	}
+	if err := u.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
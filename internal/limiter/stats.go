@@ -0,0 +1,309 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Stats is a snapshot of a limiter's activity since it was created
+type Stats struct {
+	// Allowed counts Allow, Reserve and Wait calls that were admitted
+	Allowed uint64
+	// Denied counts calls that were rejected or whose wait failed
+	Denied uint64
+	// Denials breaks Denied down by reason
+	Denials DenialStats
+	// Waits is how long Wait calls blocked, admitted or not
+	Waits WaitHistogram
+	// Waiting is the number of goroutines currently blocked in Wait
+	Waiting int
+	// Tokens is the limiter's current Tokens value; for the leaky bucket
+	// it is the free space left in the queue
+	Tokens float64
+	// LastDecision is the time of the latest admit or reject, or the zero
+	// time if there has been none
+	LastDecision time.Time
+}
+
+// DenialReason is why a call was denied
+type DenialReason int
+
+const (
+	// NotDenied is the reason of an admitted call
+	NotDenied DenialReason = iota
+	// DeniedLimited means the limit had no room left at the time, e.g.
+	// an empty bucket or a full window; the call may pass later
+	DeniedLimited
+	// DeniedExceeds means the call asked for more than the limiter can
+	// ever admit at once, e.g. more than the burst, or the limiter was
+	// paused
+	DeniedExceeds
+	// DeniedCanceled means a Wait's context ended before its turn came
+	DeniedCanceled
+	// DeniedStore means the store deciding for the limiter failed
+	DeniedStore
+	// DeniedShed means a SheddingLimiter dropped the call early, before
+	// the limit was reached
+	DeniedShed
+
+	numReasons = iota
+)
+
+// String returns a short name for r, suitable as a metric label
+func (r DenialReason) String() string {
+	switch r {
+	case NotDenied:
+		return "none"
+	case DeniedLimited:
+		return "limited"
+	case DeniedExceeds:
+		return "exceeds_burst"
+	case DeniedCanceled:
+		return "canceled"
+	case DeniedStore:
+		return "store_error"
+	case DeniedShed:
+		return "shed"
+	}
+	return "unknown"
+}
+
+// DenialStats counts denied calls by DenialReason
+type DenialStats struct {
+	Limited  uint64
+	Exceeds  uint64
+	Canceled uint64
+	Store    uint64
+	Shed     uint64
+}
+
+// Count returns the number of calls denied for reason r
+func (d DenialStats) Count(r DenialReason) uint64 {
+	switch r {
+	case DeniedLimited:
+		return d.Limited
+	case DeniedExceeds:
+		return d.Exceeds
+	case DeniedCanceled:
+		return d.Canceled
+	case DeniedStore:
+		return d.Store
+	case DeniedShed:
+		return d.Shed
+	}
+	return 0
+}
+
+// Add counts one more call denied for reason r
+func (d *DenialStats) Add(r DenialReason) {
+	switch r {
+	case DeniedLimited:
+		d.Limited++
+	case DeniedExceeds:
+		d.Exceeds++
+	case DeniedCanceled:
+		d.Canceled++
+	case DeniedStore:
+		d.Store++
+	case DeniedShed:
+		d.Shed++
+	}
+}
+
+// waitBounds are the upper bounds of the buckets of a WaitHistogram; a
+// last bucket holds the longer waits
+var waitBounds = [...]time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// WaitBuckets is the number of buckets of a WaitHistogram
+const WaitBuckets = len(waitBounds) + 1
+
+// WaitHistogram counts waits in buckets of 1ms, 10ms, 100ms, 1s, 10s and
+// longer
+type WaitHistogram struct {
+	// Counts holds the waits per bucket, not cumulative
+	Counts [WaitBuckets]uint64
+	// Count and Sum are the number of waits and the time they took
+	Count uint64
+	Sum   time.Duration
+}
+
+// Bound returns the upper bound of bucket i, InfDuration for the last
+func (h WaitHistogram) Bound(i int) time.Duration {
+	if i >= len(waitBounds) {
+		return InfDuration
+	}
+	return waitBounds[i]
+}
+
+// Mean returns the average wait, 0 if there was none
+func (h WaitHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns the upper bound of the bucket holding the q quantile
+// of the waits, e.g. 0.99 for an estimate of p99, or 0 if there was none
+func (h WaitHistogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(h.Count))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, c := range h.Counts {
+		seen += c
+		if seen >= rank {
+			return h.Bound(i)
+		}
+	}
+	return InfDuration
+}
+
+// waitBucket returns the bucket of a wait of d
+func waitBucket(d time.Duration) int {
+	for i, bound := range waitBounds {
+		if d <= bound {
+			return i
+		}
+	}
+	return len(waitBounds)
+}
+
+// deniedAfter is the reason of a call denied with the given RetryAfter:
+// one that can never pass asked for more than the limiter holds
+func deniedAfter(retryAfter time.Duration) DenialReason {
+	if retryAfter == InfDuration {
+		return DeniedExceeds
+	}
+	return DeniedLimited
+}
+
+// waitDenial is the reason of a Wait that failed with err
+func waitDenial(err error) DenialReason {
+	switch {
+	case errors.Is(err, ErrExceedsBurst):
+		return DeniedExceeds
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return DeniedCanceled
+	}
+	return DeniedLimited
+}
+
+// count adds one decision made at t to the counters, denied for why
+// unless it is NotDenied
+func (b *base) count(t time.Time, why DenialReason) {
+	if why == NotDenied {
+		b.allowed.Add(1)
+	} else {
+		b.denied.Add(1)
+		b.denials[why].Add(1)
+	}
+	b.last.Store(t.UnixNano())
+}
+
+// record counts an Allow or Reserve decision for n events made at t,
+// denied for why unless ok, and passes it to the listener and subscribers
+func (b *base) record(t time.Time, n float64, ok bool, why DenialReason) {
+	if ok {
+		why = NotDenied
+	}
+	b.count(t, why)
+	if b.listener == nil && !b.feed.Active() {
+		return
+	}
+	e := b.event(t, n, ok)
+	e.Reason = why
+	switch {
+	case b.listener == nil:
+	case ok:
+		b.listener.OnAllow(e)
+	default:
+		b.listener.OnDeny(e)
+	}
+	b.feed.Publish(e)
+}
+
+// recordWait counts a wait for n events that began at start and ended
+// now with err, and passes it to the listener and subscribers
+func (b *base) recordWait(start time.Time, n float64, err error) {
+	t := b.now()
+	why := NotDenied
+	if err != nil {
+		why = waitDenial(err)
+	}
+	b.count(t, why)
+	delay := t.Sub(start)
+	if delay < 0 {
+		delay = 0
+	}
+	b.waits[waitBucket(delay)].Add(1)
+	b.waitSum.Add(int64(delay))
+	if b.listener == nil && !b.feed.Active() {
+		return
+	}
+	e := b.event(t, n, err == nil)
+	e.Delay = delay
+	e.Err = err
+	e.Reason = why
+	if b.listener != nil {
+		b.listener.OnWait(e)
+	}
+	b.feed.Publish(e)
+}
+
+// recordOn records a decision made by a wrapper of lim, e.g. a call it
+// refused, with the built-in limiter lim is or wraps. Other limiters keep
+// no counters for it to add to
+func recordOn(lim Limiter, t time.Time, n float64, ok bool, why DenialReason) {
+	for {
+		if r, isRecorder := lim.(interface {
+			record(time.Time, float64, bool, DenialReason)
+		}); isRecorder {
+			r.record(t, n, ok, why)
+			return
+		}
+		u, isWrapper := lim.(interface{ Unwrap() Limiter })
+		if !isWrapper {
+			return
+		}
+		lim = u.Unwrap()
+	}
+}
+
+// stats returns a snapshot of the counters with the given token count
+func (b *base) stats(tokens float64) Stats {
+	s := Stats{
+		Allowed: b.allowed.Load(),
+		Denied:  b.denied.Load(),
+		Denials: DenialStats{
+			Limited:  b.denials[DeniedLimited].Load(),
+			Exceeds:  b.denials[DeniedExceeds].Load(),
+			Canceled: b.denials[DeniedCanceled].Load(),
+			Store:    b.denials[DeniedStore].Load(),
+			Shed:     b.denials[DeniedShed].Load(),
+		},
+		Waiting: int(b.waiting.Load()),
+		Tokens:  tokens,
+	}
+	for i := range b.waits {
+		c := b.waits[i].Load()
+		s.Waits.Counts[i] = c
+		s.Waits.Count += c
+	}
+	s.Waits.Sum = time.Duration(b.waitSum.Load())
+	if last := b.last.Load(); last != 0 {
+		s.LastDecision = time.Unix(0, last)
+	}
+	return s
+}
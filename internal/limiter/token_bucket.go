@@ -2,7 +2,6 @@ package limiter
 
 import (
 	"context"
-	"fmt"
 	"math"
 	"sync"
 	"time"
@@ -11,10 +10,12 @@ import (
 // TokenBucketLimiter implements the token bucket algorithm
 // Fully compatible with stdlib rate limiter
 type TokenBucketLimiter struct {
+	base
 	mu          sync.Mutex
 	limit       Limit
 	burst       int
 	tokens      float64
+	maxDebt     float64
 	lastUpdated time.Time
 }
 
@@ -40,9 +41,57 @@ func (tb *TokenBucketLimiter) Capabilities() Capabilities {
 	}
 }
 
+func (tb *TokenBucketLimiter) Stats() Stats {
+	return tb.stats(tb.Tokens())
+}
+
+// Configure applies cfg and restarts the refill clock
+func (tb *TokenBucketLimiter) Configure(cfg Config) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.configure(cfg)
+	tb.lastUpdated = tb.now()
+}
+
+func (tb *TokenBucketLimiter) Reset() {
+	tb.ResetTo(tb.now())
+}
+
+func (tb *TokenBucketLimiter) ResetTo(t time.Time) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.tokens = float64(tb.burst)
+	tb.lastUpdated = t
+}
+
+func (tb *TokenBucketLimiter) Clone() Limiter {
+	return tb.clone()
+}
+
+// clone returns a full bucket with the same rate, burst and debt allowance
+func (tb *TokenBucketLimiter) clone() *TokenBucketLimiter {
+	tb.mu.Lock()
+	c := &TokenBucketLimiter{limit: tb.limit, burst: tb.burst, maxDebt: tb.maxDebt}
+	tb.mu.Unlock()
+	c.inherit(&tb.base)
+	c.Reset()
+	return c
+}
+
+// idle reports whether a zero limit or burst has paused the bucket, in
+// which case it admits nothing. tb.mu must be held
+func (tb *TokenBucketLimiter) idle() bool {
+	return tb.limit == 0 || tb.burst == 0
+}
+
 // advance updates the token count based on elapsed time
 func (tb *TokenBucketLimiter) advance(now time.Time) {
 	elapsed := now.Sub(tb.lastUpdated)
+	if elapsed < 0 {
+		// A time before the last update, e.g. taken by a caller before
+		// the limiter was created, refills nothing
+		return
+	}
 	tb.lastUpdated = now
 
 	if tb.limit == Limit(math.MaxFloat64) {
@@ -56,80 +105,221 @@ func (tb *TokenBucketLimiter) advance(now time.Time) {
 }
 
 func (tb *TokenBucketLimiter) Allow() bool {
-	return tb.AllowN(time.Now(), 1)
+	return tb.AllowN(tb.now(), 1)
 }
 
 func (tb *TokenBucketLimiter) AllowN(t time.Time, n int) bool {
+	return tb.allowN(t, float64(n), 0)
+}
+
+// AllowF is like AllowN for a fractional number of tokens, so cheap
+// requests can cost less than one
+func (tb *TokenBucketLimiter) AllowF(t time.Time, n float64) bool {
+	return tb.allowN(t, n, 0)
+}
+
+func (tb *TokenBucketLimiter) AllowDetails(n int) (bool, Result) {
+	return tb.AllowDetailsAt(tb.now(), n)
+}
+
+func (tb *TokenBucketLimiter) AllowDetailsAt(t time.Time, n int) (bool, Result) {
+	return tb.allowDetails(t, float64(n), 0)
+}
+
+// allowN consumes n tokens if that leaves at least floor tokens in the bucket
+func (tb *TokenBucketLimiter) allowN(t time.Time, n float64, floor float64) bool {
+	ok, _ := tb.allowDetails(t, n, floor)
+	return ok
+}
+
+func (tb *TokenBucketLimiter) allowDetails(t time.Time, n float64, floor float64) (bool, Result) {
 	tb.mu.Lock()
 	defer tb.mu.Unlock()
 
 	tb.advance(t)
 
-	if tb.tokens >= float64(n) {
-		tb.tokens -= float64(n)
-		return true
+	// Debt only extends the bottom of the bucket, never a priority reserve
+	reserve := floor
+	if floor == 0 {
+		floor = -tb.maxDebt
+	}
+
+	ok := !tb.idle() && tb.tokens-n >= floor
+	if ok {
+		tb.tokens -= n
+	}
+
+	remaining, resetAt := tb.headroom(t)
+	res := Result{
+		Limit:     tb.burst - int(reserve),
+		Remaining: whole(remaining - reserve),
+		ResetAt:   resetAt,
+	}
+	if !ok {
+		if tb.idle() || n > float64(tb.burst)-floor {
+			res.RetryAfter = InfDuration
+		} else {
+			res.RetryAfter = tokenDelay(n-(tb.tokens-floor), tb.limit)
+		}
 	}
-	return false
+	tb.record(t, n, ok, deniedAfter(res.RetryAfter))
+	return ok, res
+}
+
+// headroom returns the tokens available at t and when the bucket is full
+// again. tb.mu must be held and the bucket advanced to t
+func (tb *TokenBucketLimiter) headroom(t time.Time) (float64, time.Time) {
+	resetAt := resetAfter(t, tokenDelay(float64(tb.burst)-tb.tokens, tb.limit))
+	if tb.idle() {
+		return 0, resetAt
+	}
+	return math.Max(0, tb.tokens), resetAt
+}
+
+func (tb *TokenBucketLimiter) Remaining() float64 {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	now := tb.now()
+	tb.advance(now)
+	remaining, _ := tb.headroom(now)
+	return remaining
+}
+
+func (tb *TokenBucketLimiter) ResetAt() time.Time {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	now := tb.now()
+	tb.advance(now)
+	_, resetAt := tb.headroom(now)
+	return resetAt
 }
 
 func (tb *TokenBucketLimiter) Reserve() *Reservation {
-	return tb.ReserveN(time.Now(), 1)
+	return tb.ReserveN(tb.now(), 1)
 }
 
 func (tb *TokenBucketLimiter) ReserveN(t time.Time, n int) *Reservation {
+	return tb.ReserveF(t, float64(n))
+}
+
+// ReserveF is like ReserveN for a fractional number of tokens
+func (tb *TokenBucketLimiter) ReserveF(t time.Time, n float64) *Reservation {
+	r := tb.reserveN(t, n, 0, InfDuration)
+	tb.record(t, n, r.OK(), DeniedExceeds)
+	return r
+}
+
+// reserveQuiet is reserveN for reserveAll, which records the decision
+// itself
+func (tb *TokenBucketLimiter) reserveQuiet(t time.Time, n int, maxWait time.Duration) *Reservation {
+	return tb.reserveN(t, float64(n), 0, maxWait)
+}
+
+// reserveN reserves n tokens, delaying the reservation until the bucket
+// would hold at least floor tokens after they are taken. A reservation
+// that would have to wait longer than maxWait is refused with its time to
+// act set
+func (tb *TokenBucketLimiter) reserveN(t time.Time, n float64, floor float64, maxWait time.Duration) *Reservation {
 	tb.mu.Lock()
 	defer tb.mu.Unlock()
+	return tb.reserveLocked(t, n, floor, maxWait)
+}
 
+// reserveLocked is reserveN with tb.mu already held
+func (tb *TokenBucketLimiter) reserveLocked(t time.Time, n float64, floor float64, maxWait time.Duration) *Reservation {
 	tb.advance(t)
 
-	if n > tb.burst {
+	if tb.idle() || n+floor > float64(tb.burst) {
 		return &Reservation{ok: false}
 	}
 
 	// Calculate wait time
-	tokens := tb.tokens
+	tokens := tb.tokens - floor
 	waitDuration := time.Duration(0)
 
-	if tokens < float64(n) {
-		needed := float64(n) - tokens
+	if tokens < n {
+		needed := n - tokens
 		if tb.limit > 0 {
 			waitDuration = time.Duration(needed/float64(tb.limit)*float64(time.Second)) + time.Nanosecond
 		}
 	}
+	if waitDuration > maxWait {
+		return &Reservation{ok: false, timeToAct: t.Add(waitDuration)}
+	}
 
-	tb.tokens -= float64(n)
+	tb.tokens -= n
 
 	return &Reservation{
 		ok:        true,
 		lim:       tb,
-		tokens:    n,
+		tokens:    float64(n),
 		timeToAct: t.Add(waitDuration),
 		limit:     tb.limit,
 	}
 }
 
+// restore refunds a reservation that has not acted yet
+func (tb *TokenBucketLimiter) restore(t time.Time, r *Reservation) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	if r.tokens == 0 || r.timeToAct.Before(t) {
+		return
+	}
+	tb.advance(t)
+	tb.tokens = math.Min(tb.tokens+r.tokens, float64(tb.burst))
+	r.tokens = 0
+}
+
 func (tb *TokenBucketLimiter) Wait(ctx context.Context) error {
 	return tb.WaitN(ctx, 1)
 }
 
 func (tb *TokenBucketLimiter) WaitN(ctx context.Context, n int) error {
-	r := tb.ReserveN(time.Now(), n)
+	return tb.waitN(ctx, float64(n), 0)
+}
+
+// WaitF is like WaitN for a fractional number of tokens
+func (tb *TokenBucketLimiter) WaitF(ctx context.Context, n float64) error {
+	return tb.waitN(ctx, n, 0)
+}
+
+func (tb *TokenBucketLimiter) WaitMaxN(ctx context.Context, n int, maxWait time.Duration) error {
+	return waitMax(ctx, tb, n, maxWait)
+}
+
+// waitN blocks until n tokens can be taken without dropping below floor
+// While the bucket is paused it waits for SetLimit or SetBurst instead
+func (tb *TokenBucketLimiter) waitN(ctx context.Context, n float64, floor float64) (err error) {
+	start := tb.now()
+	defer func() { tb.recordWait(start, n, err) }()
+
+	tb.mu.Lock()
+	if err := tb.awaitActive(ctx, &tb.mu, tb.idle); err != nil {
+		return err
+	}
+	now := tb.now()
+	r := tb.reserveLocked(now, n, floor, waitBudget(ctx))
+	burst := tb.burst
+	tb.mu.Unlock()
+
 	if !r.OK() {
-		return fmt.Errorf("rate: requested tokens (%d) exceeds burst (%d)", n, tb.Burst())
+		if r.timeToAct.IsZero() {
+			return errExceeds(int(math.Ceil(n)), "burst", burst-int(floor))
+		}
+		return &RateLimitError{RetryAfter: r.timeToAct.Sub(now)}
 	}
 
-	delay := r.Delay()
+	delay := r.DelayFrom(tb.now())
 	if delay == 0 {
 		return nil
 	}
 
-	select {
-	case <-time.After(delay):
-		return nil
-	case <-ctx.Done():
+	if err := tb.sleep(ctx, delay); err != nil {
 		r.Cancel()
-		return ctx.Err()
+		return err
 	}
+	return nil
 }
 
 func (tb *TokenBucketLimiter) Limit() Limit {
@@ -139,7 +329,7 @@ func (tb *TokenBucketLimiter) Limit() Limit {
 }
 
 func (tb *TokenBucketLimiter) SetLimit(newLimit Limit) {
-	tb.SetLimitAt(time.Now(), newLimit)
+	tb.SetLimitAt(tb.now(), newLimit)
 }
 
 func (tb *TokenBucketLimiter) SetLimitAt(t time.Time, newLimit Limit) {
@@ -147,6 +337,11 @@ func (tb *TokenBucketLimiter) SetLimitAt(t time.Time, newLimit Limit) {
 	defer tb.mu.Unlock()
 	tb.advance(t)
 	tb.limit = newLimit
+	tb.resume()
+}
+
+func (tb *TokenBucketLimiter) RampLimit(target Limit, over time.Duration) {
+	tb.rampLimit(tb.Limit(), target, over, tb.Limit, tb.SetLimitAt)
 }
 
 func (tb *TokenBucketLimiter) Burst() int {
@@ -156,7 +351,7 @@ func (tb *TokenBucketLimiter) Burst() int {
 }
 
 func (tb *TokenBucketLimiter) SetBurst(newBurst int) {
-	tb.SetBurstAt(time.Now(), newBurst)
+	tb.SetBurstAt(tb.now(), newBurst)
 }
 
 func (tb *TokenBucketLimiter) SetBurstAt(t time.Time, newBurst int) {
@@ -167,10 +362,31 @@ func (tb *TokenBucketLimiter) SetBurstAt(t time.Time, newBurst int) {
 	if tb.tokens > float64(newBurst) {
 		tb.tokens = float64(newBurst)
 	}
+	tb.resume()
 }
 
+// MaxDebt returns how far below zero AllowN may drive the bucket
+func (tb *TokenBucketLimiter) MaxDebt() int {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	return int(tb.maxDebt)
+}
+
+// SetMaxDebt lets AllowN admit requests that overdraw the bucket by up to
+// debt tokens. The debt is paid back by refill before later requests are
+// admitted, so a large request now delays the ones that follow
+func (tb *TokenBucketLimiter) SetMaxDebt(debt int) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	if debt < 0 {
+		debt = 0
+	}
+	tb.maxDebt = float64(debt)
+}
+
+// Tokens returns the tokens in the bucket; negative while in debt
 func (tb *TokenBucketLimiter) Tokens() float64 {
-	return tb.TokensAt(time.Now())
+	return tb.TokensAt(tb.now())
 }
 
 func (tb *TokenBucketLimiter) TokensAt(t time.Time) float64 {
@@ -179,3 +395,46 @@ func (tb *TokenBucketLimiter) TokensAt(t time.Time) float64 {
 	tb.advance(t)
 	return tb.tokens
 }
+
+type tokenBucketState struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+func (tb *TokenBucketLimiter) exportState() tokenBucketState {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	return tokenBucketState{Tokens: tb.tokens, Updated: tb.lastUpdated}
+}
+
+func (tb *TokenBucketLimiter) importState(s tokenBucketState) error {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.tokens = math.Min(s.Tokens, float64(tb.burst))
+	tb.lastUpdated = s.Updated
+	return nil
+}
+
+func (tb *TokenBucketLimiter) MarshalJSON() ([]byte, error) {
+	return marshalState("TokenBucket", tb.exportState())
+}
+
+func (tb *TokenBucketLimiter) UnmarshalJSON(data []byte) error {
+	var s tokenBucketState
+	if err := unmarshalState("TokenBucket", data, &s); err != nil {
+		return err
+	}
+	return tb.importState(s)
+}
+
+func (tb *TokenBucketLimiter) MarshalBinary() ([]byte, error) {
+	return encodeState("TokenBucket", tb.exportState())
+}
+
+func (tb *TokenBucketLimiter) UnmarshalBinary(data []byte) error {
+	var s tokenBucketState
+	if err := decodeState("TokenBucket", data, &s); err != nil {
+		return err
+	}
+	return tb.importState(s)
+}
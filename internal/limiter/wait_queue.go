@@ -0,0 +1,69 @@
+package limiter
+
+import "context"
+
+// waitQueue orders blocked WaitN calls so they are admitted first in,
+// first out. Only the waiter at the head sleeps on the limiter; the rest
+// stay parked until it hands over, so a window boundary wakes one
+// goroutine instead of all of them.
+// Its methods must be called with the owning limiter's mutex held
+type waitQueue struct {
+	busy    bool
+	waiters []chan struct{}
+}
+
+// join enqueues a waiter and returns a channel closed when it is its turn
+func (q *waitQueue) join() chan struct{} {
+	turn := make(chan struct{})
+	if !q.busy {
+		q.busy = true
+		close(turn)
+		return turn
+	}
+	q.waiters = append(q.waiters, turn)
+	return turn
+}
+
+// next passes the turn to the following waiter
+func (q *waitQueue) next() {
+	if len(q.waiters) == 0 {
+		q.busy = false
+		return
+	}
+	close(q.waiters[0])
+	q.waiters[0] = nil
+	q.waiters = q.waiters[1:]
+}
+
+// leave removes a waiter that gave up. If it had already been handed the
+// turn, the turn moves on
+func (q *waitQueue) leave(turn chan struct{}) {
+	for i, w := range q.waiters {
+		if w == turn {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return
+		}
+	}
+	q.next()
+}
+
+// size returns the number of goroutines blocked in WaitN
+func (q *waitQueue) size() int {
+	if !q.busy {
+		return 0
+	}
+	return len(q.waiters) + 1
+}
+
+// awaitTurn blocks until turn is closed or ctx is done
+func (b *base) awaitTurn(ctx context.Context, turn chan struct{}) error {
+	b.waiting.Add(1)
+	defer b.waiting.Add(-1)
+
+	select {
+	case <-turn:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,87 @@
+package rateflow
+
+import (
+	"context"
+	"io"
+)
+
+// Reader is an io.Reader whose reads wait on a limiter of one event per
+// byte, e.g. to throttle an upload or a backup
+type Reader struct {
+	r   io.Reader
+	lim Limiter
+	ctx context.Context
+}
+
+// NewReader returns a Reader of r at lim's rate in bytes per second
+func NewReader(r io.Reader, lim Limiter) *Reader {
+	return NewReaderContext(context.Background(), r, lim)
+}
+
+// NewReaderContext is NewReader with reads failing once ctx is done
+func NewReaderContext(ctx context.Context, r io.Reader, lim Limiter) *Reader {
+	return &Reader{r: r, lim: lim, ctx: ctx}
+}
+
+// Read reads at most a burst of bytes from the underlying reader, then
+// waits until the limiter admits them
+func (r *Reader) Read(p []byte) (int, error) {
+	if len(p) > chunk(r.lim) {
+		p = p[:chunk(r.lim)]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.lim.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// Writer is an io.Writer whose writes wait on a limiter of one event
+// per byte, e.g. to throttle log shipping
+type Writer struct {
+	w   io.Writer
+	lim Limiter
+	ctx context.Context
+}
+
+// NewWriter returns a Writer to w at lim's rate in bytes per second
+func NewWriter(w io.Writer, lim Limiter) *Writer {
+	return NewWriterContext(context.Background(), w, lim)
+}
+
+// NewWriterContext is NewWriter with writes failing once ctx is done
+func NewWriterContext(ctx context.Context, w io.Writer, lim Limiter) *Writer {
+	return &Writer{w: w, lim: lim, ctx: ctx}
+}
+
+// Write writes p in chunks of at most a burst of bytes, waiting for the
+// limiter to admit each before writing it
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		c := p
+		if len(c) > chunk(w.lim) {
+			c = c[:chunk(w.lim)]
+		}
+		if err := w.lim.WaitN(w.ctx, len(c)); err != nil {
+			return written, err
+		}
+		n, err := w.w.Write(c)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// chunk returns the most bytes lim can admit at once, at least 1
+func chunk(lim Limiter) int {
+	if b := lim.Burst(); b > 0 {
+		return b
+	}
+	return 1
+}
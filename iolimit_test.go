@@ -0,0 +1,50 @@
+package rateflow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReader(t *testing.T) {
+	lim := NewLimiter(TokenBucket, 10000, 100)
+	data := strings.Repeat("x", 300)
+	start := time.Now()
+	got, err := io.ReadAll(NewReader(strings.NewReader(data), lim))
+	if err != nil || string(got) != data {
+		t.Fatalf("ReadAll = %d bytes, %v", len(got), err)
+	}
+	// The burst covers 100 bytes, the other 200 take 20ms
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("read in %v, want about 20ms", elapsed)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	lim := NewLimiter(TokenBucket, 10000, 100)
+	var buf bytes.Buffer
+	start := time.Now()
+	n, err := NewWriter(&buf, lim).Write(make([]byte, 300))
+	if err != nil || n != 300 || buf.Len() != 300 {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("written in %v, want about 20ms", elapsed)
+	}
+}
+
+func TestWriterContext(t *testing.T) {
+	lim := NewLimiter(TokenBucket, Every(time.Hour), 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var buf bytes.Buffer
+	n, err := NewWriterContext(ctx, &buf, lim).Write(make([]byte, 25))
+	var rle *RateLimitError
+	if !errors.As(err, &rle) || n != 10 {
+		t.Errorf("Write = %d, %v, want the first chunk written, then a RateLimitError", n, err)
+	}
+}
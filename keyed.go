@@ -0,0 +1,642 @@
+package rateflow
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// keyedShards is the number of independently locked maps a Keyed spreads
+// its keys over by default
+const keyedShards = 32
+
+// Keyed holds one Limiter per key (user ID, client IP, API key), created
+// on first use by a factory, so each client gets its own limit
+// Keys are spread over lock-sharded maps, so lookups for different keys
+// rarely contend
+type Keyed[K comparable] struct {
+	newLimiter func(key K) Limiter
+	resolve    func(key K) LimitSpec // set by NewKeyedProvider
+	provider   atomic.Pointer[LimitProvider[K]]
+	shards     []*keyedShard[K]
+	seed       maphash.Seed
+	count      atomic.Int64
+	created    atomic.Uint64
+	evicted    atomic.Uint64
+	maxKeys    atomic.Int64
+	parent     atomic.Pointer[keyedParent[K]] // shared by every key, nil if unset
+	allowlist  atomic.Pointer[KeyList[K]]
+	denylist   atomic.Pointer[KeyList[K]]
+	scaling    atomic.Pointer[burstScaling]
+
+	// events passes the decisions of every key to Subscribe channels.
+	// Once watching is set, every key's limiter is observed
+	events   limiter.Feed[KeyEvent[K]]
+	watching atomic.Bool
+
+	mu      sync.Mutex // guards the settings below
+	onEvict func(key K, lim Limiter)
+	ttl     time.Duration
+	stop    chan struct{} // closed to stop the idle sweeper
+}
+
+// keyedParent is a limiter shared by all keys, with a fair queue so
+// waiters from different keys take turns on it
+type keyedParent[K comparable] struct {
+	lim  Limiter
+	fair *FairQueue[K]
+}
+
+type keyedShard[K comparable] struct {
+	mu       sync.RWMutex
+	limiters map[K]*keyedEntry[K]
+	lru      *list.List // of *keyedEntry[K], most recently used first
+}
+
+type keyedEntry[K comparable] struct {
+	key  K
+	lim  Limiter
+	elem *list.Element
+	used atomic.Int64 // UnixNano of the latest Get
+	full atomic.Int64 // burst before any scaling
+
+	// unobserve stops passing the limiter's events to the Keyed's
+	// subscribers, nil while it is not observed. Guarded by the shard
+	unobserve func()
+}
+
+// NewKeyed creates a keyed limiter that builds the limiter for a key with
+// newLimiter the first time the key is seen
+func NewKeyed[K comparable](newLimiter func(key K) Limiter) *Keyed[K] {
+	return NewKeyedSharded(keyedShards, newLimiter)
+}
+
+// NewKeyedSharded is like NewKeyed with the number of lock shards given
+// More shards reduce contention between goroutines using different keys
+func NewKeyedSharded[K comparable](shards int, newLimiter func(key K) Limiter) *Keyed[K] {
+	if shards < 1 {
+		shards = 1
+	}
+	k := &Keyed[K]{
+		newLimiter: newLimiter,
+		shards:     make([]*keyedShard[K], shards),
+		seed:       maphash.MakeSeed(),
+	}
+	for i := range k.shards {
+		k.shards[i] = &keyedShard[K]{
+			limiters: make(map[K]*keyedEntry[K]),
+			lru:      list.New(),
+		}
+	}
+	return k
+}
+
+// NewKeyedLimiter creates a keyed limiter giving every key a limiter of
+// the same algorithm, rate, burst and options
+func NewKeyedLimiter[K comparable](algo Algorithm, r Limit, b int, opts ...Option) *Keyed[K] {
+	return NewKeyed(func(K) Limiter { return NewLimiterWithOptions(algo, r, b, opts...) })
+}
+
+// shard returns the shard holding key
+func (k *Keyed[K]) shard(key K) *keyedShard[K] {
+	if len(k.shards) == 1 {
+		return k.shards[0]
+	}
+
+	var h uint64
+	switch v := any(key).(type) {
+	case string:
+		h = maphash.String(k.seed, v)
+	case int:
+		h = mixKey(uint64(v))
+	case int64:
+		h = mixKey(uint64(v))
+	case uint64:
+		h = mixKey(v)
+	case int32:
+		h = mixKey(uint64(v))
+	case uint32:
+		h = mixKey(uint64(v))
+	default:
+		// Equal comparable values format the same
+		h = maphash.String(k.seed, fmt.Sprintf("%#v", v))
+	}
+	return k.shards[h%uint64(len(k.shards))]
+}
+
+// mixKey spreads consecutive integer keys over the shards
+func mixKey(v uint64) uint64 {
+	v *= 0x9e3779b97f4a7c15
+	return v ^ v>>32
+}
+
+// Get returns the limiter for key, creating it if needed
+func (k *Keyed[K]) Get(key K) Limiter {
+	return k.entry(key).lim
+}
+
+// Lookup returns the limiter for key if there is one, without creating
+// it or counting as a use of key
+func (k *Keyed[K]) Lookup(key K) (Limiter, bool) {
+	s := k.shard(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.limiters[key]
+	if !ok {
+		return nil, false
+	}
+	return e.lim, true
+}
+
+// entry returns the entry for key, creating it if needed
+func (k *Keyed[K]) entry(key K) *keyedEntry[K] {
+	now := time.Now().UnixNano()
+	s := k.shard(key)
+	bounded := k.maxKeys.Load() > 0
+
+	// Without a capacity bound recency only matters to the idle sweeper,
+	// which reads the timestamp, so hits can share the read lock
+	if !bounded {
+		s.mu.RLock()
+		e, ok := s.limiters[key]
+		if ok {
+			e.used.Store(now)
+		}
+		s.mu.RUnlock()
+		if ok {
+			return e
+		}
+	}
+
+	s.mu.Lock()
+	if e, ok := s.limiters[key]; ok {
+		e.used.Store(now)
+		s.lru.MoveToFront(e.elem)
+		s.mu.Unlock()
+		return e
+	}
+	e := &keyedEntry[K]{key: key, lim: k.newLimiter(key)}
+	e.used.Store(now)
+	e.full.Store(int64(e.lim.Burst()))
+	e.elem = s.lru.PushFront(e)
+	s.limiters[key] = e
+	if k.watching.Load() {
+		k.observe(e)
+	}
+	s.mu.Unlock()
+	k.count.Add(1)
+	k.created.Add(1)
+
+	if bounded {
+		k.trim()
+	}
+	return e
+}
+
+// Delete drops the limiter for key; the next use starts a fresh one
+// OnEvict is not called for deleted keys
+func (k *Keyed[K]) Delete(key K) {
+	s := k.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.limiters[key]; ok {
+		k.remove(s, e)
+	}
+}
+
+// Len returns the number of keys with a limiter
+func (k *Keyed[K]) Len() int {
+	return int(k.count.Load())
+}
+
+// SetMaxKeys bounds the number of keys kept at once. Creating a limiter
+// beyond the bound evicts the least recently used key, capping memory
+// when clients make up keys faster than they expire. 0 removes the bound
+func (k *Keyed[K]) SetMaxKeys(n int) {
+	if n < 0 {
+		n = 0
+	}
+	if n > 0 && k.maxKeys.Load() == 0 {
+		// Unbounded hits do not reorder the lists, so rebuild them from
+		// the last use times
+		for _, s := range k.shards {
+			s.reorder()
+		}
+	}
+	k.maxKeys.Store(int64(n))
+	k.trim()
+}
+
+// OnEvict registers fn to be called with every key and limiter dropped by
+// the capacity bound or the idle sweeper, e.g. to persist its state with
+// MarshalBinary or log it. fn runs without any Keyed lock held
+func (k *Keyed[K]) OnEvict(fn func(key K, lim Limiter)) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.onEvict = fn
+}
+
+// remove drops e from s. s.mu must be held
+func (k *Keyed[K]) remove(s *keyedShard[K], e *keyedEntry[K]) {
+	if e.unobserve != nil {
+		e.unobserve()
+		e.unobserve = nil
+	}
+	delete(s.limiters, e.key)
+	s.lru.Remove(e.elem)
+	k.count.Add(-1)
+}
+
+// trim evicts least recently used keys until the bound is met. The least
+// recently used key of each shard is at the back of its list, so the
+// oldest of those is the oldest overall
+func (k *Keyed[K]) trim() {
+	var evicted []*keyedEntry[K]
+	for max := k.maxKeys.Load(); max > 0 && k.count.Load() > max; {
+		var victim *keyedShard[K]
+		oldest := int64(math.MaxInt64)
+		for _, s := range k.shards {
+			s.mu.RLock()
+			if back := s.lru.Back(); back != nil {
+				if used := back.Value.(*keyedEntry[K]).used.Load(); used < oldest {
+					victim, oldest = s, used
+				}
+			}
+			s.mu.RUnlock()
+		}
+		if victim == nil {
+			break
+		}
+
+		victim.mu.Lock()
+		if back := victim.lru.Back(); back != nil {
+			e := back.Value.(*keyedEntry[K])
+			k.remove(victim, e)
+			evicted = append(evicted, e)
+		}
+		victim.mu.Unlock()
+	}
+	k.notifyEvicted(evicted)
+}
+
+// reorder sorts the recency list by last use
+func (s *keyedShard[K]) reorder() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]*keyedEntry[K], 0, len(s.limiters))
+	for _, e := range s.limiters {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].used.Load() > entries[j].used.Load()
+	})
+	s.lru.Init()
+	for _, e := range entries {
+		e.elem = s.lru.PushBack(e)
+	}
+}
+
+// notifyEvicted counts evicted entries and passes them to OnEvict
+func (k *Keyed[K]) notifyEvicted(evicted []*keyedEntry[K]) {
+	if len(evicted) == 0 {
+		return
+	}
+	k.evicted.Add(uint64(len(evicted)))
+	k.mu.Lock()
+	onEvict := k.onEvict
+	k.mu.Unlock()
+	if onEvict == nil {
+		return
+	}
+	for _, e := range evicted {
+		onEvict(e.key, e.lim)
+	}
+}
+
+// EvictIdle starts a background sweeper that drops the limiter of every
+// key not used for ttl, so keys seen once (e.g. scanning IPs) do not stay
+// in memory forever. Keys with goroutines blocked in Wait are kept.
+// Calling it again replaces the ttl; a ttl of 0 stops evicting
+func (k *Keyed[K]) EvictIdle(ttl time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.stopSweeper()
+	k.ttl = ttl
+	if ttl <= 0 {
+		return
+	}
+	k.stop = make(chan struct{})
+	go k.sweepEvery(ttl/2, k.stop)
+}
+
+// Stop stops the idle sweeper started by EvictIdle. Limiters already
+// created stay usable
+func (k *Keyed[K]) Stop() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.stopSweeper()
+}
+
+// stopSweeper stops the running sweeper, if any. k.mu must be held
+func (k *Keyed[K]) stopSweeper() {
+	if k.stop != nil {
+		close(k.stop)
+		k.stop = nil
+	}
+}
+
+func (k *Keyed[K]) sweepEvery(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			k.sweep(now)
+		}
+	}
+}
+
+// sweep drops the limiters idle for longer than the ttl at now
+func (k *Keyed[K]) sweep(now time.Time) {
+	k.mu.Lock()
+	ttl := k.ttl
+	k.mu.Unlock()
+	if ttl <= 0 {
+		return
+	}
+
+	var evicted []*keyedEntry[K]
+	cutoff := now.Add(-ttl).UnixNano()
+	for _, s := range k.shards {
+		s.mu.Lock()
+		for _, e := range s.limiters {
+			if e.used.Load() < cutoff && e.lim.Stats().Waiting == 0 {
+				k.remove(s, e)
+				evicted = append(evicted, e)
+			}
+		}
+		s.mu.Unlock()
+	}
+	k.notifyEvicted(evicted)
+}
+
+// Purge evicts the limiter for key like the capacity bound would,
+// calling OnEvict, and reports whether there was one
+func (k *Keyed[K]) Purge(key K) bool {
+	s := k.shard(key)
+	s.mu.Lock()
+	e, ok := s.limiters[key]
+	if ok {
+		k.remove(s, e)
+	}
+	s.mu.Unlock()
+	if ok {
+		k.notifyEvicted([]*keyedEntry[K]{e})
+	}
+	return ok
+}
+
+// PurgeWhere evicts every key for which match returns true, calling
+// OnEvict, and returns how many it evicted. match runs with the key's
+// shard locked and must not use the Keyed
+func (k *Keyed[K]) PurgeWhere(match func(key K, lim Limiter) bool) int {
+	var evicted []*keyedEntry[K]
+	for _, s := range k.shards {
+		s.mu.Lock()
+		for _, e := range s.limiters {
+			if match(e.key, e.lim) {
+				k.remove(s, e)
+				evicted = append(evicted, e)
+			}
+		}
+		s.mu.Unlock()
+	}
+	k.notifyEvicted(evicted)
+	return len(evicted)
+}
+
+// KeyEvent is a decision of the limiter of Key
+type KeyEvent[K comparable] struct {
+	Key K
+	Event
+}
+
+// Subscribe returns a channel receiving a KeyEvent for every decision of
+// every key, holding up to buffer of them; events that find it full are
+// dropped. cancel ends the subscription and closes the channel. Events
+// of the parent limiter are not included
+func (k *Keyed[K]) Subscribe(buffer int) (<-chan KeyEvent[K], func()) {
+	ch, cancel := k.events.Subscribe(buffer)
+	if !k.watching.Swap(true) {
+		for _, s := range k.shards {
+			s.mu.Lock()
+			for _, e := range s.limiters {
+				if e.unobserve == nil {
+					k.observe(e)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+	return ch, cancel
+}
+
+// observe passes the events of e's limiter to the subscribers. The
+// shard of e must be locked
+func (k *Keyed[K]) observe(e *keyedEntry[K]) {
+	key := e.key
+	e.unobserve = limiter.Observe(e.lim, func(ev Event) {
+		k.events.Publish(KeyEvent[K]{Key: key, Event: ev})
+	})
+}
+
+// KeyedStats counts the limiters a Keyed has managed, for dashboards
+type KeyedStats struct {
+	// Created counts limiters built for new keys
+	Created uint64
+	// Evicted counts limiters dropped by the capacity bound, the idle
+	// sweeper or Purge; Delete is not counted
+	Evicted uint64
+	// Active is the number of keys with a limiter now
+	Active int
+}
+
+// Stats returns the Keyed's counters
+func (k *Keyed[K]) Stats() KeyedStats {
+	return KeyedStats{
+		Created: k.created.Load(),
+		Evicted: k.evicted.Load(),
+		Active:  k.Len(),
+	}
+}
+
+// SetParent makes every key also draw from parent, e.g. "each user 10/s
+// and the whole service 1000/s". AllowKey and ReserveKey charge both
+// limiters or neither. WaitKey reserves on the key's own limiter and
+// queues for the parent while the reservation comes due, where keys take
+// turns round-robin, so a key with many blocked goroutines cannot take
+// all the capacity the parent frees. If the parent wait fails, the key's
+// reservation is cancelled. Get still returns the per-key limiter alone. A nil parent
+// removes it
+func (k *Keyed[K]) SetParent(parent Limiter) {
+	if parent == nil {
+		k.parent.Store(nil)
+		return
+	}
+	k.parent.Store(&keyedParent[K]{lim: parent, fair: NewFairQueue[K](parent)})
+}
+
+// limitersFor returns the limiters a request for key draws from
+func (k *Keyed[K]) limitersFor(key K) []Limiter {
+	if parent := k.parent.Load(); parent != nil {
+		return []Limiter{k.use(key), parent.lim}
+	}
+	return []Limiter{k.use(key)}
+}
+
+// AllowKey is shorthand for AllowNKey(key, time.Now(), 1)
+func (k *Keyed[K]) AllowKey(key K) bool {
+	if ok, listed := k.listed(key); listed {
+		return ok
+	}
+	lims := k.limitersFor(key)
+	return allowAll(limiter.NowOf(lims[0]), 1, lims)
+}
+
+// AllowNKey reports whether n events for key may happen at time t
+func (k *Keyed[K]) AllowNKey(key K, t time.Time, n int) bool {
+	if ok, listed := k.listed(key); listed {
+		return ok
+	}
+	return allowAll(t, n, k.limitersFor(key))
+}
+
+// AllowDetailsKey is AllowDetailsAtKey at the current time of the key's
+// limiter
+func (k *Keyed[K]) AllowDetailsKey(key K, n int) (bool, Result) {
+	if ok, listed := k.listed(key); listed {
+		return listedDetails(ok)
+	}
+	lims := k.limitersFor(key)
+	return allowDetails(limiter.NowOf(lims[0]), n, lims)
+}
+
+// AllowDetailsAtKey is AllowNKey also describing the key's limiter as
+// AllowDetailsAt does. A denylisted key gets an empty Result that never
+// retries, an allowlisted one an empty Result. With a parent, RetryAfter
+// is the wait of whichever limiter refused
+func (k *Keyed[K]) AllowDetailsAtKey(key K, t time.Time, n int) (bool, Result) {
+	if ok, listed := k.listed(key); listed {
+		return listedDetails(ok)
+	}
+	return allowDetails(t, n, k.limitersFor(key))
+}
+
+// listedDetails is the decision on a key of the allowlist or denylist
+func listedDetails(ok bool) (bool, Result) {
+	if !ok {
+		return false, Result{RetryAfter: InfDuration}
+	}
+	return true, Result{}
+}
+
+// allowDetails is allowAll describing the first limiter, the key's
+func allowDetails(t time.Time, n int, lims []Limiter) (bool, Result) {
+	if len(lims) == 1 {
+		return lims[0].AllowDetailsAt(t, n)
+	}
+	ok, _, retryAfter := limiter.AllowAllRetry(t, n, lims...)
+	lim := lims[0]
+	res := Result{Limit: lim.Burst(), ResetAt: lim.ResetAt(), RetryAfter: retryAfter}
+	if tokens := lim.TokensAt(t); tokens > 0 {
+		res.Remaining = int(tokens)
+	}
+	return ok, res
+}
+
+// allowAll is AllowAll skipping the reservation for a single limiter
+func allowAll(t time.Time, n int, lims []Limiter) bool {
+	if len(lims) == 1 {
+		return lims[0].AllowN(t, n)
+	}
+	return AllowAll(t, n, lims...)
+}
+
+// WaitKey is shorthand for WaitNKey(ctx, key, 1)
+func (k *Keyed[K]) WaitKey(ctx context.Context, key K) error {
+	return k.WaitNKey(ctx, key, 1)
+}
+
+// WaitNKey blocks until n events for key are allowed or ctx is done
+func (k *Keyed[K]) WaitNKey(ctx context.Context, key K, n int) error {
+	if ok, listed := k.listed(key); listed {
+		if !ok {
+			return ErrDenylisted
+		}
+		return nil
+	}
+	lim := k.use(key)
+	parent := k.parent.Load()
+	if parent == nil {
+		return lim.WaitN(ctx, n)
+	}
+
+	// Reserve on the key first and hand the reservation back if the
+	// parent wait fails, so a cancelled wait does not use up the key
+	now := limiter.NowOf(lim)
+	r := lim.ReserveN(now, n)
+	if !r.OK() {
+		return fmt.Errorf("%w: %d > %d", ErrExceedsBurst, n, lim.Burst())
+	}
+	if deadline, ok := ctx.Deadline(); ok && r.TimeToAct().After(deadline) {
+		r.CancelAt(now)
+		return &RateLimitError{RetryAfter: r.TimeToAct().Sub(now)}
+	}
+	if err := parent.fair.WaitN(ctx, key, n); err != nil {
+		// Cancel as of the reservation, which a bucket would otherwise
+		// consider acted on once its time passed
+		r.CancelAt(now)
+		return err
+	}
+	return r.Act(ctx)
+}
+
+// ReserveKey reserves one event for key
+func (k *Keyed[K]) ReserveKey(key K) *Reservation {
+	if ok, listed := k.listed(key); listed {
+		if !ok {
+			return limiter.NotOK()
+		}
+		return ReserveAll(time.Now(), 1)
+	}
+	lims := k.limitersFor(key)
+	if len(lims) == 1 {
+		return lims[0].Reserve()
+	}
+	return ReserveAll(limiter.NowOf(lims[0]), 1, lims...)
+}
+
+// ReserveNKey reserves n events for key at time t
+func (k *Keyed[K]) ReserveNKey(key K, t time.Time, n int) *Reservation {
+	if ok, listed := k.listed(key); listed {
+		if !ok {
+			return limiter.NotOK()
+		}
+		return ReserveAll(t, n)
+	}
+	lims := k.limitersFor(key)
+	if len(lims) == 1 {
+		return lims[0].ReserveN(t, n)
+	}
+	return ReserveAll(t, n, lims...)
+}
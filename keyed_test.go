@@ -0,0 +1,203 @@
+package rateflow
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyed(t *testing.T) {
+	k := NewKeyedLimiter[string](TokenBucket, Limit(1), 2)
+
+	if !k.AllowKey("alice") || !k.AllowKey("alice") {
+		t.Fatal("expected alice's burst to be available")
+	}
+	if k.AllowKey("alice") {
+		t.Error("expected alice to be limited")
+	}
+	if !k.AllowKey("bob") {
+		t.Error("expected bob to get a separate limiter")
+	}
+	if k.Len() != 2 {
+		t.Errorf("expected 2 keys, got %d", k.Len())
+	}
+
+	k.Delete("alice")
+	if !k.AllowKey("alice") {
+		t.Error("expected a fresh limiter after Delete")
+	}
+}
+
+func TestKeyedFactory(t *testing.T) {
+	k := NewKeyed(func(tier int) Limiter { return NewLimiter(TokenBucket, Limit(1), tier) })
+	if b := k.Get(5).Burst(); b != 5 {
+		t.Errorf("expected the factory to see the key, got burst %d", b)
+	}
+	if err := k.WaitNKey(context.Background(), 5, 5); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestKeyedTimeBeforeKey(t *testing.T) {
+	// AllowNKey creates the key's limiter after the caller took t
+	k := NewKeyedLimiter[string](TokenBucket, Every(time.Hour), 1)
+	if !k.AllowNKey("alice", time.Now().Add(-time.Millisecond), 1) {
+		t.Error("a new key should admit its burst at a time taken before it existed")
+	}
+}
+
+func TestKeyedConcurrentGet(t *testing.T) {
+	k := NewKeyedLimiter[int](TokenBucket, Limit(1), 1)
+
+	var wg sync.WaitGroup
+	limiters := make([]Limiter, 50)
+	for i := range limiters {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			limiters[i] = k.Get(1)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, lim := range limiters {
+		if lim != limiters[0] {
+			t.Fatal("expected every caller to get the same limiter")
+		}
+	}
+}
+
+func TestKeyedEvictIdle(t *testing.T) {
+	k := NewKeyedLimiter[string](TokenBucket, Limit(1), 1)
+	k.EvictIdle(time.Minute)
+	defer k.Stop()
+
+	k.AllowKey("stale")
+	k.AllowKey("fresh")
+	k.shard("stale").limiters["stale"].used.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+
+	k.sweep(time.Now())
+	if k.Len() != 1 {
+		t.Errorf("expected the stale key to be evicted, got %d keys", k.Len())
+	}
+	if k.AllowKey("fresh") {
+		t.Error("expected the fresh key to keep its limiter")
+	}
+}
+
+func TestKeyedEvictIdleSweeper(t *testing.T) {
+	k := NewKeyedLimiter[int](TokenBucket, Limit(1), 1)
+	k.EvictIdle(10 * time.Millisecond)
+	defer k.Stop()
+
+	k.AllowKey(1)
+	eventually(func() bool { return k.Len() == 0 })
+	if k.Len() != 0 {
+		t.Error("expected the sweeper to evict the idle key")
+	}
+}
+
+func TestKeyedMaxKeys(t *testing.T) {
+	k := NewKeyedLimiter[int](TokenBucket, Limit(1), 1)
+	var evicted []int
+	k.OnEvict(func(key int, lim Limiter) { evicted = append(evicted, key) })
+	k.SetMaxKeys(2)
+
+	k.AllowKey(1)
+	k.AllowKey(2)
+	k.Get(1) // 2 is now the least recently used
+	k.AllowKey(3)
+
+	if k.Len() != 2 {
+		t.Errorf("expected 2 keys, got %d", k.Len())
+	}
+	if len(evicted) != 1 || evicted[0] != 2 {
+		t.Errorf("expected key 2 to be evicted, got %v", evicted)
+	}
+	if k.AllowKey(1) {
+		t.Error("expected key 1 to keep its limiter")
+	}
+}
+
+func TestKeyedMaxKeysShrink(t *testing.T) {
+	k := NewKeyedLimiter[int](TokenBucket, Limit(1), 1)
+	for i := 0; i < 5; i++ {
+		k.Get(i)
+		time.Sleep(time.Millisecond)
+	}
+	k.Get(0)
+
+	var evicted []int
+	k.OnEvict(func(key int, lim Limiter) { evicted = append(evicted, key) })
+	k.SetMaxKeys(2)
+
+	if k.Len() != 2 || len(evicted) != 3 {
+		t.Fatalf("expected 3 of 5 keys evicted, got %d left and %v", k.Len(), evicted)
+	}
+	for _, key := range evicted {
+		if key == 0 || key == 4 {
+			t.Errorf("evicted recently used key %d", key)
+		}
+	}
+}
+
+func TestKeyedSharded(t *testing.T) {
+	type route struct {
+		tenant string
+		path   string
+	}
+	k := NewKeyedSharded(8, func(route) Limiter { return NewLimiter(TokenBucket, Limit(1), 1) })
+
+	used := make(map[*keyedShard[route]]bool)
+	for i := 0; i < 64; i++ {
+		key := route{tenant: "t", path: string(rune('a' + i))}
+		if k.Get(key) != k.Get(key) {
+			t.Fatalf("expected the same limiter for %v", key)
+		}
+		used[k.shard(key)] = true
+	}
+	if len(used) < 2 {
+		t.Errorf("expected keys spread over several shards, got %d", len(used))
+	}
+	if k.Len() != 64 {
+		t.Errorf("expected 64 keys, got %d", k.Len())
+	}
+
+	k.Delete(route{tenant: "t", path: "a"})
+	if k.Len() != 63 {
+		t.Errorf("expected 63 keys after Delete, got %d", k.Len())
+	}
+}
+
+func TestKeyedPurge(t *testing.T) {
+	k := NewKeyedLimiter[int](TokenBucket, Limit(1), 1)
+	var evicted []int
+	k.OnEvict(func(key int, lim Limiter) { evicted = append(evicted, key) })
+	for i := 0; i < 10; i++ {
+		k.AllowKey(i)
+	}
+	k.Delete(9)
+
+	if _, ok := k.Lookup(0); !ok {
+		t.Error("expected Lookup to find key 0")
+	}
+	if !k.Purge(0) || k.Purge(0) {
+		t.Error("expected Purge to report whether the key had a limiter")
+	}
+	if n := k.PurgeWhere(func(key int, lim Limiter) bool { return key%2 == 1 }); n != 4 {
+		t.Errorf("expected 4 odd keys purged, got %d", n)
+	}
+	if len(evicted) != 5 {
+		t.Errorf("expected OnEvict for every purged key, got %v", evicted)
+	}
+
+	if _, ok := k.Lookup(0); ok {
+		t.Error("expected Lookup not to find a purged key")
+	}
+
+	want := KeyedStats{Created: 10, Evicted: 5, Active: 4}
+	if s := k.Stats(); s != want {
+		t.Errorf("expected %+v, got %+v", want, s)
+	}
+}
@@ -0,0 +1,163 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// ErrQueueClosed is returned when submitting to a closed LeakyQueue
+var ErrQueueClosed = errors.New("rate: queue closed")
+
+// LeakyQueue is a leaky bucket that queues work instead of only metering it.
+// Submitted items are buffered up to the queue capacity and a background
+// drainer hands them to a callback at a constant rate until Close is called
+type LeakyQueue[T any] struct {
+	items    chan T
+	handle   func(T)
+	limit    Limit
+	interval time.Duration
+	clock    Clock
+
+	mu      sync.Mutex
+	closed  bool
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewLeakyQueue creates a queue holding up to capacity items that are
+// passed to handle at rate r, one at a time, and starts its drainer. Of
+// opts only WithClock applies
+func NewLeakyQueue[T any](r Limit, capacity int, handle func(T), opts ...Option) *LeakyQueue[T] {
+	if capacity < 0 {
+		capacity = 0
+	}
+	var cfg limiter.Config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = limiter.SystemClock{}
+	}
+
+	interval := time.Duration(0)
+	if r > 0 && r != Inf {
+		interval = time.Duration(float64(time.Second) / float64(r))
+	}
+
+	q := &LeakyQueue[T]{
+		items:    make(chan T, capacity),
+		handle:   handle,
+		limit:    r,
+		interval: interval,
+		clock:    cfg.Clock,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go q.drain()
+	return q
+}
+
+// drain invokes the callback for queued items, spacing calls by interval
+func (q *LeakyQueue[T]) drain() {
+	defer close(q.stopped)
+
+	// A zero rate never leaks; wait for Close
+	if q.limit <= 0 {
+		<-q.done
+		return
+	}
+
+	var next time.Time
+	for {
+		if wait := next.Sub(q.clock.Now()); wait > 0 {
+			select {
+			case <-q.clock.After(wait):
+			case <-q.done:
+				return
+			}
+		}
+
+		select {
+		case item := <-q.items:
+			q.handle(item)
+			next = q.clock.Now().Add(q.interval)
+		case <-q.done:
+			return
+		}
+	}
+}
+
+// Submit enqueues item without blocking. It returns false if the queue is
+// full or closed
+func (q *LeakyQueue[T]) Submit(item T) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return false
+	}
+
+	select {
+	case q.items <- item:
+		return true
+	default:
+		return false
+	}
+}
+
+// SubmitWait enqueues item, blocking until there is room in the queue,
+// the queue is closed or ctx is done. An item that got in as the queue
+// was closing is discarded with the rest and reported as ErrQueueClosed
+func (q *LeakyQueue[T]) SubmitWait(ctx context.Context, item T) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrQueueClosed
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.items <- item:
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if q.closed {
+			return ErrQueueClosed
+		}
+		return nil
+	case <-q.done:
+		return ErrQueueClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Len returns the number of items waiting to be drained
+func (q *LeakyQueue[T]) Len() int {
+	return len(q.items)
+}
+
+// Cap returns the capacity of the queue
+func (q *LeakyQueue[T]) Cap() int {
+	return cap(q.items)
+}
+
+// Limit returns the drain rate
+func (q *LeakyQueue[T]) Limit() Limit {
+	return q.limit
+}
+
+// Close stops the drainer and waits for an in-flight callback to return.
+// Items still queued are discarded
+func (q *LeakyQueue[T]) Close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.done)
+	}
+	q.mu.Unlock()
+	<-q.stopped
+}
@@ -0,0 +1,92 @@
+package rateflow
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLeakyQueueDrainsAtRate(t *testing.T) {
+	var handled atomic.Int32
+	done := make(chan struct{}, 10)
+	q := NewLeakyQueue(Limit(100), 10, func(int) {
+		handled.Add(1)
+		done <- struct{}{}
+	})
+	defer q.Close()
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if !q.Submit(i) {
+			t.Fatalf("expected Submit(%d) to be accepted", i)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		<-done
+	}
+
+	// 5 items at 100/s: the first leaks immediately, the rest 10ms apart
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("queue drained too fast: %v", elapsed)
+	}
+	if n := handled.Load(); n != 5 {
+		t.Errorf("expected 5 handled items, got %d", n)
+	}
+}
+
+func TestLeakyQueueFull(t *testing.T) {
+	q := NewLeakyQueue(Limit(0), 2, func(int) {})
+	defer q.Close()
+
+	if !q.Submit(1) || !q.Submit(2) {
+		t.Fatal("expected items to fit in the queue")
+	}
+	if q.Submit(3) {
+		t.Error("expected Submit to fail on a full queue")
+	}
+	if q.Len() != 2 {
+		t.Errorf("expected Len() = 2, got %d", q.Len())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.SubmitWait(ctx, 3); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestLeakyQueueClose(t *testing.T) {
+	q := NewLeakyQueue(Limit(10), 2, func(int) {})
+	q.Close()
+	q.Close() // Must be idempotent
+
+	if q.Submit(1) {
+		t.Error("expected Submit to fail after Close")
+	}
+	if err := q.SubmitWait(context.Background(), 1); err != ErrQueueClosed {
+		t.Errorf("expected ErrQueueClosed, got %v", err)
+	}
+}
+
+func TestLeakyQueueClock(t *testing.T) {
+	clock := newFakeClock()
+	handled := make(chan int, 3)
+	q := NewLeakyQueue(Limit(1), 3, func(i int) { handled <- i }, WithClock(clock))
+	defer q.Close()
+
+	for i := 0; i < 3; i++ {
+		q.Submit(i)
+	}
+	<-handled
+	clock.blockUntil(1)
+	select {
+	case i := <-handled:
+		t.Fatalf("item %d leaked before the clock moved", i)
+	default:
+	}
+	clock.Advance(time.Second)
+	if i := <-handled; i != 1 {
+		t.Errorf("expected item 1 after a second, got %d", i)
+	}
+}
@@ -0,0 +1,187 @@
+package rateflow
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LeasePolicy configures Leased
+type LeasePolicy struct {
+	// Batch is the number of tokens fetched from the store at a time
+	Batch int
+	// LowWater is the number of leased tokens left at which the next
+	// batch is fetched in the background, so callers rarely wait on the
+	// store. 0 means a fifth of Batch; a negative value only fetches
+	// when callers run out
+	LowWater int
+	// TTL is how long leased tokens stay usable, so a replica that goes
+	// quiet does not sit on capacity others could use. Every fetch
+	// renews the TTL of the tokens left; 0 means they never expire
+	TTL time.Duration
+}
+
+// Leased admits events for one key from tokens leased in batches from a
+// RemoteLimiter, e.g. 100 at a time, cutting store round trips by the
+// batch size at high rates. Tokens a replica leased but did not use are
+// lost to the others until they expire, so the batch trades accuracy of
+// the shared limit for fewer round trips
+type Leased struct {
+	remote RemoteLimiter
+	key    string
+	policy LeasePolicy
+
+	mu       sync.Mutex
+	tokens   int
+	expires  time.Time
+	fetching bool // a background fetch is scheduled
+
+	// fetchMu serializes fetches, so callers running out together wait
+	// for one batch instead of each fetching their own
+	fetchMu sync.Mutex
+	fetches atomic.Uint64
+}
+
+// NewLeased creates a limiter for key leasing tokens from remote
+func NewLeased(remote RemoteLimiter, key string, p LeasePolicy) *Leased {
+	if p.Batch < 1 {
+		p.Batch = 1
+	}
+	if p.LowWater == 0 {
+		p.LowWater = p.Batch / 5
+	}
+	return &Leased{remote: remote, key: key, policy: p}
+}
+
+// Tokens returns the number of leased tokens left at t
+func (l *Leased) Tokens(t time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire(t)
+	return l.tokens
+}
+
+// Fetches returns the number of times tokens were requested from the
+// store
+func (l *Leased) Fetches() uint64 {
+	return l.fetches.Load()
+}
+
+// expire drops the leased tokens if their TTL has passed at t. l.mu must
+// be held
+func (l *Leased) expire(t time.Time) {
+	if l.policy.TTL > 0 && !t.Before(l.expires) {
+		l.tokens = 0
+	}
+}
+
+// take consumes n leased tokens at t if there are enough, scheduling a
+// background fetch when the lease runs low. l.mu must be held
+func (l *Leased) take(t time.Time, n int) bool {
+	l.expire(t)
+	if l.tokens < n {
+		return false
+	}
+	l.tokens -= n
+	if l.policy.LowWater >= 0 && l.tokens <= l.policy.LowWater && !l.fetching {
+		l.fetching = true
+		go l.prefetch(t)
+	}
+	return true
+}
+
+// fetch leases n more tokens at t, reporting whether the store granted
+// them
+func (l *Leased) fetch(ctx context.Context, t time.Time, n int) (bool, error) {
+	l.fetches.Add(1)
+	ok, err := l.remote.AllowN(ctx, l.key, t, n)
+	if !ok || err != nil {
+		return false, err
+	}
+	l.mu.Lock()
+	l.expire(t)
+	l.tokens += n
+	l.expires = t.Add(l.policy.TTL)
+	l.mu.Unlock()
+	return true, nil
+}
+
+// prefetch fetches a batch in the background if the lease is still low
+// once earlier fetches are done
+func (l *Leased) prefetch(t time.Time) {
+	l.fetchMu.Lock()
+	defer l.fetchMu.Unlock()
+
+	l.mu.Lock()
+	l.expire(t)
+	low := l.tokens <= l.policy.LowWater
+	l.mu.Unlock()
+	if low {
+		l.fetch(context.Background(), t, l.policy.Batch)
+	}
+
+	l.mu.Lock()
+	l.fetching = false
+	l.mu.Unlock()
+}
+
+// Allow is shorthand for AllowN(ctx, time.Now(), 1)
+func (l *Leased) Allow(ctx context.Context) (bool, error) {
+	return l.AllowN(ctx, time.Now(), 1)
+}
+
+// AllowN reports whether n events may happen at t. It only contacts the
+// store when the lease cannot cover n, fetching a batch, or just the
+// tokens missing if the store refuses a whole batch
+func (l *Leased) AllowN(ctx context.Context, t time.Time, n int) (bool, error) {
+	l.mu.Lock()
+	ok := l.take(t, n)
+	l.mu.Unlock()
+	if ok {
+		return true, nil
+	}
+
+	l.fetchMu.Lock()
+	defer l.fetchMu.Unlock()
+
+	l.mu.Lock()
+	if l.take(t, n) {
+		l.mu.Unlock()
+		return true, nil
+	}
+	short := n - l.tokens
+	l.mu.Unlock()
+
+	want := l.policy.Batch
+	if want < short {
+		want = short
+	}
+	ok, err := l.fetch(ctx, t, want)
+	if !ok && err == nil && want > short {
+		ok, err = l.fetch(ctx, t, short)
+	}
+	if !ok {
+		return false, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.take(t, n), nil
+}
+
+// Wait is shorthand for WaitN(ctx, 1)
+func (l *Leased) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+// WaitN takes n events from the lease, or waits for the store to admit
+// them directly with WaitRemote when the lease and a new batch cannot
+// cover them
+func (l *Leased) WaitN(ctx context.Context, n int) error {
+	ok, err := l.AllowN(ctx, time.Now(), n)
+	if ok || err != nil {
+		return err
+	}
+	return WaitRemote(ctx, l.remote, l.key, n)
+}
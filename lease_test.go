@@ -0,0 +1,87 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLeased(t *testing.T) {
+	clock := newFakeClock()
+	remote := &limiterRemote{lim: NewLimiterWithOptions(TokenBucket, 100, 250, WithClock(clock))}
+	l := NewLeased(remote, "k", LeasePolicy{Batch: 100, LowWater: -1})
+	ctx := context.Background()
+	now := clock.Now()
+
+	for i := 0; i < 250; i++ {
+		if ok, err := l.AllowN(ctx, now, 1); err != nil || !ok {
+			t.Fatalf("AllowN #%d = %v, %v, want true", i, ok, err)
+		}
+	}
+	// The third batch was refused whole, so only the token missing was
+	// leased, one at a time, for the last 50 events
+	if got := l.Fetches(); got != 2+2*50 || l.Tokens(now) != 0 {
+		t.Errorf("Fetches = %d, Tokens = %d, want two batches, then single tokens", got, l.Tokens(now))
+	}
+	if ok, _ := l.AllowN(ctx, now, 1); ok {
+		t.Error("expected the shared bucket to be empty")
+	}
+}
+
+func TestLeasedTTL(t *testing.T) {
+	clock := newFakeClock()
+	remote := &limiterRemote{lim: NewLimiterWithOptions(TokenBucket, Every(time.Hour), 10, WithClock(clock))}
+	l := NewLeased(remote, "k", LeasePolicy{Batch: 10, LowWater: -1, TTL: time.Second})
+	ctx := context.Background()
+
+	l.AllowN(ctx, clock.Now(), 1)
+	if got := l.Tokens(clock.Now()); got != 9 {
+		t.Fatalf("Tokens = %d, want 9", got)
+	}
+	clock.Advance(time.Second)
+	if got := l.Tokens(clock.Now()); got != 0 {
+		t.Errorf("Tokens = %d, want the lease expired", got)
+	}
+	if ok, _ := l.AllowN(ctx, clock.Now(), 1); ok {
+		t.Error("expired tokens should not be used")
+	}
+}
+
+func TestLeasedPrefetch(t *testing.T) {
+	remote := &limiterRemote{lim: NewLimiter(TokenBucket, Every(time.Hour), 100)}
+	l := NewLeased(remote, "k", LeasePolicy{Batch: 10})
+	ctx := context.Background()
+
+	for i := 0; i < 8; i++ {
+		l.Allow(ctx)
+	}
+	eventually(func() bool { return l.Fetches() == 2 })
+	if l.Fetches() != 2 {
+		t.Fatalf("Fetches = %d, want a background fetch at the low water mark", l.Fetches())
+	}
+	l.fetchMu.Lock()
+	l.fetchMu.Unlock()
+	if got := l.Tokens(time.Now()); got != 12 {
+		t.Errorf("Tokens = %d, want 12", got)
+	}
+}
+
+func TestLeasedWait(t *testing.T) {
+	remote := &limiterRemote{lim: NewLimiter(TokenBucket, Every(time.Hour), 1), err: errors.New("store down")}
+	l := NewLeased(remote, "k", LeasePolicy{Batch: 5})
+	if err := l.Wait(context.Background()); err == nil {
+		t.Error("expected the store error")
+	}
+
+	remote.err = nil
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	short, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var rle *RateLimitError
+	if err := l.Wait(short); !errors.As(err, &rle) {
+		t.Errorf("Wait = %v, want a RateLimitError once the store is out", err)
+	}
+}
@@ -150,6 +150,17 @@ func TestTokenBucketTokens(t *testing.T) {
 	}
 }
 
+func TestTokenBucketPastTime(t *testing.T) {
+	before := time.Now()
+	lim := NewLimiter(TokenBucket, Every(time.Hour), 1)
+	if !lim.AllowN(before, 1) {
+		t.Error("a time taken before the limiter was created should find it full")
+	}
+	if lim.AllowN(before.Add(-time.Minute), 1) {
+		t.Error("going back in time should not refill the bucket")
+	}
+}
+
 func TestReservation(t *testing.T) {
 	lim := NewLimiter(TokenBucket, Limit(10), 5)
 
@@ -171,6 +182,29 @@ func TestReservation(t *testing.T) {
 	r.Cancel()
 }
 
+func TestReservationAccessors(t *testing.T) {
+	lim := NewLimiter(TokenBucket, Limit(10), 5)
+	now := time.Now()
+	lim.AllowN(now, 5)
+
+	r := lim.ReserveN(now, 2)
+	if want := now.Add(r.DelayFrom(now)); !r.TimeToAct().Equal(want) {
+		t.Errorf("expected TimeToAct %v, got %v", want, r.TimeToAct())
+	}
+	if r.Tokens() != 2 {
+		t.Errorf("expected 2 tokens, got %v", r.Tokens())
+	}
+
+	r.CancelAt(now)
+	if r.Tokens() != 0 {
+		t.Errorf("expected a refunded reservation to hold no tokens, got %v", r.Tokens())
+	}
+
+	if r := lim.ReserveN(now, 6); !r.TimeToAct().IsZero() {
+		t.Errorf("expected no time to act for a failed reservation, got %v", r.TimeToAct())
+	}
+}
+
 func TestContextCancellation(t *testing.T) {
 	lim := NewLimiter(TokenBucket, Limit(1), 1)
 	lim.Allow() // Exhaust
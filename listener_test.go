@@ -0,0 +1,72 @@
+package rateflow
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingListener struct {
+	mu     sync.Mutex
+	events map[string][]Event
+}
+
+func (l *recordingListener) add(kind string, e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.events == nil {
+		l.events = make(map[string][]Event)
+	}
+	l.events[kind] = append(l.events[kind], e)
+}
+
+func (l *recordingListener) OnAllow(e Event) { l.add("allow", e) }
+func (l *recordingListener) OnDeny(e Event)  { l.add("deny", e) }
+func (l *recordingListener) OnWait(e Event)  { l.add("wait", e) }
+
+func TestListener(t *testing.T) {
+	for _, algo := range Algorithms()[:DualRate+1] {
+		var l recordingListener
+		lim := NewLimiterWithOptions(algo, Limit(1), 2, WithListener(&l), WithName("api"))
+
+		lim.AllowN(time.Now(), 2)
+		lim.Allow()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		lim.Wait(ctx)
+
+		if len(l.events["allow"]) != 1 || len(l.events["deny"]) != 1 || len(l.events["wait"]) != 1 {
+			t.Errorf("%s: unexpected events %v", algo, l.events)
+			continue
+		}
+		e := l.events["allow"][0]
+		if e.Algorithm != algo || e.Name != "api" || e.N != 2 || !e.Allowed {
+			t.Errorf("%s: unexpected allow event %+v", algo, e)
+		}
+		if w := l.events["wait"][0]; w.Allowed || w.Err == nil {
+			t.Errorf("%s: expected a failed wait event, got %+v", algo, w)
+		}
+	}
+}
+
+func TestListenerWaitDelay(t *testing.T) {
+	clock := newFakeClock()
+	var waits []Event
+	lim := NewLimiterWithOptions(TokenBucket, Limit(1), 1, WithClock(clock),
+		WithListener(ListenerFuncs{Wait: func(e Event) { waits = append(waits, e) }}))
+
+	lim.Allow()
+	done := make(chan error)
+	go func() { done <- lim.Wait(context.Background()) }()
+	clock.blockUntil(1)
+	clock.Advance(2 * time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(waits) != 1 || waits[0].Delay != 2*time.Second || waits[0].Err != nil {
+		t.Errorf("unexpected wait events %+v", waits)
+	}
+}
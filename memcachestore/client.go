@@ -0,0 +1,127 @@
+// Package memcachestore implements rateflow.RemoteLimiter on memcached,
+// for deployments that run memcached but not Redis. memcached has no
+// scripting, so every decision is a gets/cas loop: read the state with
+// its CAS token, compute the new state locally and store it only if no
+// other replica changed it in between.
+//
+// The package does not depend on a memcached client. A Client adapts
+// one, e.g. github.com/bradfitz/gomemcache, whose items carry their CAS
+// token:
+//
+//	type client struct{ mc *memcache.Client }
+//
+//	func (c client) Gets(ctx context.Context, key string) ([]byte, any, error) {
+//		it, err := c.mc.Get(key)
+//		if errors.Is(err, memcache.ErrCacheMiss) {
+//			return nil, nil, memcachestore.ErrCacheMiss
+//		}
+//		if err != nil {
+//			return nil, nil, err
+//		}
+//		return it.Value, it, nil
+//	}
+//
+//	func (c client) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+//		err := c.mc.Add(&memcache.Item{Key: key, Value: value, Expiration: int32(ttl / time.Second)})
+//		if errors.Is(err, memcache.ErrNotStored) {
+//			return memcachestore.ErrNotStored
+//		}
+//		return err
+//	}
+//
+//	func (c client) CompareAndSwap(ctx context.Context, key string, value []byte, cas any, ttl time.Duration) error {
+//		it := cas.(*memcache.Item)
+//		it.Value, it.Expiration = value, int32(ttl/time.Second)
+//		switch err := c.mc.CompareAndSwap(it); {
+//		case errors.Is(err, memcache.ErrCASConflict):
+//			return memcachestore.ErrCASConflict
+//		case errors.Is(err, memcache.ErrNotStored):
+//			return memcachestore.ErrNotStored
+//		default:
+//			return err
+//		}
+//	}
+//
+// memcached keys are limited to 250 bytes without spaces or control
+// characters, which applies to the keys passed to the limiters too
+package memcachestore
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrCacheMiss is returned by Client.Gets for a key that is not stored
+	ErrCacheMiss = errors.New("memcachestore: cache miss")
+
+	// ErrNotStored is returned by Client.Add for a key that already
+	// exists, and by Client.CompareAndSwap for one that no longer does
+	ErrNotStored = errors.New("memcachestore: item not stored")
+
+	// ErrCASConflict is returned by Client.CompareAndSwap when the item
+	// changed since it was read
+	ErrCASConflict = errors.New("memcachestore: compare-and-swap conflict")
+
+	// ErrContention is returned when a decision lost its compare-and-swap
+	// race more times in a row than WithMaxRetries allows
+	ErrContention = errors.New("memcachestore: too much contention")
+)
+
+// Client is the subset of a memcached client the limiters use. ttl is a
+// whole number of seconds, at least one
+type Client interface {
+	// Gets returns the value of key and an opaque CAS token for it, or
+	// ErrCacheMiss
+	Gets(ctx context.Context, key string) (value []byte, cas any, err error)
+	// Add stores value under key unless it exists, or returns ErrNotStored
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// CompareAndSwap stores value under key if it is unchanged since the
+	// Gets that returned cas, or returns ErrCASConflict or ErrNotStored
+	CompareAndSwap(ctx context.Context, key string, value []byte, cas any, ttl time.Duration) error
+}
+
+// update runs one gets/cas loop on key. fn gets the stored integer, if
+// any, and returns the one to store with its ttl, or store false to
+// leave key alone. It is retried whenever another writer got in first
+func update(ctx context.Context, c Client, key string, retries int, fn func(old int64, found bool) (v int64, ttl time.Duration, store bool)) error {
+	for i := 0; i <= retries; i++ {
+		raw, cas, err := c.Gets(ctx, key)
+		found := err == nil
+		if err != nil && !errors.Is(err, ErrCacheMiss) {
+			return err
+		}
+		var old int64
+		if found {
+			if old, err = strconv.ParseInt(string(raw), 10, 64); err != nil {
+				return err
+			}
+		}
+
+		v, ttl, store := fn(old, found)
+		if !store {
+			return nil
+		}
+		value := []byte(strconv.FormatInt(v, 10))
+		if found {
+			err = c.CompareAndSwap(ctx, key, value, cas, ttl)
+		} else {
+			err = c.Add(ctx, key, value, ttl)
+		}
+		if errors.Is(err, ErrCASConflict) || errors.Is(err, ErrNotStored) {
+			continue
+		}
+		return err
+	}
+	return ErrContention
+}
+
+// expiry rounds d up to the whole seconds memcached expirations use
+func expiry(d time.Duration) time.Duration {
+	if d < time.Second {
+		return time.Second
+	}
+	return (d + time.Second - 1).Truncate(time.Second)
+}
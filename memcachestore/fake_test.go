@@ -0,0 +1,72 @@
+package memcachestore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// fakeMemcached is an in-memory Client with memcached's add and cas
+// semantics. conflicts makes that many CompareAndSwap calls fail as if
+// another replica got in first
+type fakeMemcached struct {
+	mu        sync.Mutex
+	items     map[string]fakeItem
+	seq       uint64
+	conflicts int
+	casCalls  int
+}
+
+type fakeItem struct {
+	value []byte
+	cas   uint64
+	ttl   time.Duration
+}
+
+func newFakeMemcached() *fakeMemcached {
+	return &fakeMemcached{items: make(map[string]fakeItem)}
+}
+
+func (m *fakeMemcached) Gets(ctx context.Context, key string) ([]byte, any, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[key]
+	if !ok {
+		return nil, nil, ErrCacheMiss
+	}
+	return it.value, it.cas, nil
+}
+
+func (m *fakeMemcached) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[key]; ok {
+		return ErrNotStored
+	}
+	m.seq++
+	m.items[key] = fakeItem{value: value, cas: m.seq, ttl: ttl}
+	return nil
+}
+
+func (m *fakeMemcached) CompareAndSwap(ctx context.Context, key string, value []byte, cas any, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.casCalls++
+	it, ok := m.items[key]
+	if !ok {
+		return ErrNotStored
+	}
+	if m.conflicts > 0 {
+		m.conflicts--
+		m.seq++
+		it.cas = m.seq
+		m.items[key] = it
+		return ErrCASConflict
+	}
+	if it.cas != cas.(uint64) {
+		return ErrCASConflict
+	}
+	m.seq++
+	m.items[key] = fakeItem{value: value, cas: m.seq, ttl: ttl}
+	return nil
+}
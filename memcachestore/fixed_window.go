@@ -0,0 +1,108 @@
+package memcachestore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// FixedWindow admits at most max events per key in each window, counted
+// in one memcached item per key and window that expires with it. Windows
+// start at multiples of the window duration since the Unix epoch, so all
+// replicas agree on them. ReserveN either admits at once or is refused
+// with the start of the next window
+type FixedWindow struct {
+	client Client
+	cfg    config
+	max    int
+	window time.Duration
+}
+
+var _ rateflow.RemoteLimiter = (*FixedWindow)(nil)
+
+// NewFixedWindow creates a limiter allowing max events per window for
+// every key
+func NewFixedWindow(c Client, max int, window time.Duration, opts ...Option) *FixedWindow {
+	return &FixedWindow{client: c, cfg: newConfig(opts), max: max, window: window}
+}
+
+// Window returns the window duration
+func (fw *FixedWindow) Window() time.Duration {
+	return fw.window
+}
+
+// Burst returns the number of events allowed per window
+func (fw *FixedWindow) Burst() int {
+	return fw.max
+}
+
+// bounds returns the item counting key's window around t and the end of
+// that window
+func (fw *FixedWindow) bounds(key string, t time.Time) (string, time.Time) {
+	idx := t.UnixNano() / int64(fw.window)
+	end := time.Unix(0, (idx+1)*int64(fw.window))
+	return fw.cfg.prefix + key + ":" + strconv.FormatInt(idx, 10), end
+}
+
+// add adds delta events to key's window around t, refusing to go past max
+func (fw *FixedWindow) add(ctx context.Context, key string, t time.Time, delta int64) (bool, time.Time, error) {
+	item, end := fw.bounds(key, t)
+	ok := false
+	err := update(ctx, fw.client, item, fw.cfg.retries, func(count int64, found bool) (int64, time.Duration, bool) {
+		count += delta
+		if count > int64(fw.max) || (delta < 0 && !found) {
+			ok = false
+			return 0, 0, false
+		}
+		if count < 0 {
+			count = 0
+		}
+		ok = true
+		return count, expiry(end.Sub(t)), true
+	})
+	return ok, end, err
+}
+
+// AllowN reports whether n events for key may happen at t
+func (fw *FixedWindow) AllowN(ctx context.Context, key string, t time.Time, n int) (bool, error) {
+	if n > fw.max {
+		return false, nil
+	}
+	ok, _, err := fw.add(ctx, key, t, int64(n))
+	return ok, err
+}
+
+// ReserveN admits n events for key at t or refuses them with the start
+// of the next window
+func (fw *FixedWindow) ReserveN(ctx context.Context, key string, t time.Time, n int) (*rateflow.RemoteReservation, error) {
+	if n > fw.max {
+		return rateflow.NewRemoteReservation(false, time.Time{}, n, nil), nil
+	}
+	ok, end, err := fw.add(ctx, key, t, int64(n))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return rateflow.NewRemoteReservation(false, end, n, nil), nil
+	}
+
+	cancel := func(ctx context.Context, now time.Time) error {
+		if !now.Before(end) {
+			return nil
+		}
+		_, _, err := fw.add(ctx, key, t, -int64(n))
+		return err
+	}
+	return rateflow.NewRemoteReservation(true, t, n, cancel), nil
+}
+
+// WaitN blocks until n events for key are allowed or ctx is done
+func (fw *FixedWindow) WaitN(ctx context.Context, key string, n int) error {
+	if n > fw.max {
+		return fmt.Errorf("%w: %d > %d", rateflow.ErrExceedsBurst, n, fw.max)
+	}
+	return rateflow.WaitRemote(ctx, fw, key, n)
+}
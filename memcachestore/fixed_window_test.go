@@ -0,0 +1,59 @@
+package memcachestore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFixedWindow(t *testing.T) {
+	mc := newFakeMemcached()
+	fw := NewFixedWindow(mc, 2, time.Minute, WithPrefix("test:"))
+	ctx := context.Background()
+	start := time.Now().Truncate(time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if ok, err := fw.AllowN(ctx, "k", start.Add(time.Second), 1); err != nil || !ok {
+			t.Fatalf("AllowN #%d = %v, %v, want true", i, ok, err)
+		}
+	}
+	r, err := fw.ReserveN(ctx, "k", start.Add(30*time.Second), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.OK() || !r.RetryAt().Equal(start.Add(time.Minute)) {
+		t.Errorf("ReserveN = %v retry at %v, want a refusal until the next window", r.OK(), r.RetryAt())
+	}
+	if ok, _ := fw.AllowN(ctx, "k", start.Add(time.Minute), 2); !ok {
+		t.Error("expected the next window to start empty")
+	}
+	if len(mc.items) != 2 {
+		t.Errorf("got %d items, want one per window", len(mc.items))
+	}
+	for key, it := range mc.items {
+		if it.ttl < time.Second || it.ttl > time.Minute+time.Second {
+			t.Errorf("%s ttl = %v, want it to expire with its window", key, it.ttl)
+		}
+	}
+}
+
+func TestFixedWindowCancel(t *testing.T) {
+	fw := NewFixedWindow(newFakeMemcached(), 1, time.Minute)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Minute)
+
+	r, err := fw.ReserveN(ctx, "k", now, 1)
+	if err != nil || !r.OK() {
+		t.Fatalf("ReserveN = %v, %v, want OK", r, err)
+	}
+	if err := r.CancelAt(ctx, now.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := fw.AllowN(ctx, "k", now.Add(2*time.Second), 1); !ok {
+		t.Error("expected the cancelled event to be given back")
+	}
+
+	if ok, _ := fw.AllowN(ctx, "k", now, 2); ok {
+		t.Error("n above max should never be admitted")
+	}
+}
@@ -0,0 +1,161 @@
+package memcachestore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// GCRA is a generic cell rate algorithm limiter per key kept in
+// memcached. It behaves like a token bucket refilling at the limit up to
+// the burst, but stores a single number per key: the theoretical arrival
+// time of the next event, in Unix microseconds. Reservations may be
+// scheduled in the future. Times come from the callers, so replicas
+// should keep their clocks in sync
+type GCRA struct {
+	client Client
+	cfg    config
+	limit  rateflow.Limit
+	burst  int
+}
+
+var _ rateflow.RemoteLimiter = (*GCRA)(nil)
+
+// NewGCRA creates a limiter allowing r events per second with bursts of
+// up to b, for every key. A zero r refuses everything
+func NewGCRA(c Client, r rateflow.Limit, b int, opts ...Option) *GCRA {
+	return &GCRA{client: c, cfg: newConfig(opts), limit: r, burst: b}
+}
+
+// Limit returns the rate of events per second
+func (g *GCRA) Limit() rateflow.Limit {
+	return g.limit
+}
+
+// Burst returns the largest number of events admitted at once
+func (g *GCRA) Burst() int {
+	return g.burst
+}
+
+// interval returns the time one event costs, in microseconds
+func (g *GCRA) interval() float64 {
+	if g.limit == rateflow.Inf {
+		return 0
+	}
+	return 1e6 / float64(g.limit)
+}
+
+// take admits n events for key at t if they may act within maxWait; a
+// negative maxWait accepts any wait. A refused take returns the wait that
+// was refused, or -1 if n can never be admitted
+func (g *GCRA) take(ctx context.Context, key string, t time.Time, n int, maxWait time.Duration) (ok bool, wait time.Duration, err error) {
+	if n > g.burst || g.limit <= 0 {
+		return false, -1, nil
+	}
+	now := t.UnixMicro()
+	interval := g.interval()
+
+	err = update(ctx, g.client, g.cfg.prefix+key, g.cfg.retries, func(tat int64, found bool) (int64, time.Duration, bool) {
+		if !found || tat < now {
+			tat = now
+		}
+		next := tat + int64(math.Ceil(float64(n)*interval))
+		allowAt := next - int64(float64(g.burst)*interval)
+
+		wait = 0
+		if allowAt > now {
+			wait = time.Duration(allowAt-now) * time.Microsecond
+		}
+		if maxWait >= 0 && wait > maxWait {
+			ok = false
+			return 0, 0, false
+		}
+		ok = true
+		return next, expiry(time.Duration(next-now) * time.Microsecond), true
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	return ok, wait, nil
+}
+
+// refund gives back n events reserved for key, as of t
+func (g *GCRA) refund(ctx context.Context, key string, t time.Time, n int) error {
+	now := t.UnixMicro()
+	return update(ctx, g.client, g.cfg.prefix+key, g.cfg.retries, func(tat int64, found bool) (int64, time.Duration, bool) {
+		if !found || tat <= now {
+			return 0, 0, false
+		}
+		tat -= int64(float64(n) * g.interval())
+		if tat < now {
+			tat = now
+		}
+		return tat, expiry(time.Duration(tat-now) * time.Microsecond), true
+	})
+}
+
+// AllowN reports whether n events for key may happen at t
+func (g *GCRA) AllowN(ctx context.Context, key string, t time.Time, n int) (bool, error) {
+	ok, _, err := g.take(ctx, key, t, n, 0)
+	return ok, err
+}
+
+// ReserveN reserves n events for key at t, possibly in the future. It is
+// not OK only if n exceeds the burst or the rate is zero
+func (g *GCRA) ReserveN(ctx context.Context, key string, t time.Time, n int) (*rateflow.RemoteReservation, error) {
+	return g.reserve(ctx, key, t, n, -1)
+}
+
+func (g *GCRA) reserve(ctx context.Context, key string, t time.Time, n int, maxWait time.Duration) (*rateflow.RemoteReservation, error) {
+	ok, wait, err := g.take(ctx, key, t, n, maxWait)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		var retry time.Time
+		if wait >= 0 {
+			retry = t.Add(wait)
+		}
+		return rateflow.NewRemoteReservation(false, retry, n, nil), nil
+	}
+
+	timeToAct := t.Add(wait)
+	cancel := func(ctx context.Context, now time.Time) error {
+		if !now.Before(timeToAct) {
+			return nil
+		}
+		return g.refund(ctx, key, now, n)
+	}
+	return rateflow.NewRemoteReservation(true, timeToAct, n, cancel), nil
+}
+
+// WaitN blocks until n events for key are allowed or ctx is done. It
+// fails fast, consuming nothing, when the wait would outlast ctx's
+// deadline
+func (g *GCRA) WaitN(ctx context.Context, key string, n int) error {
+	maxWait := time.Duration(-1)
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = time.Until(deadline)
+		if maxWait < 0 {
+			maxWait = 0
+		}
+	}
+	now := time.Now()
+	r, err := g.reserve(ctx, key, now, n, maxWait)
+	if err != nil {
+		return err
+	}
+	if !r.OK() {
+		if r.RetryAt().IsZero() {
+			if n > g.burst {
+				return fmt.Errorf("%w: %d > %d", rateflow.ErrExceedsBurst, n, g.burst)
+			}
+			return rateflow.ErrReservationNotOK
+		}
+		return &rateflow.RateLimitError{RetryAfter: r.RetryAt().Sub(now)}
+	}
+	return r.Act(ctx)
+}
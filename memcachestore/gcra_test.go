@@ -0,0 +1,103 @@
+package memcachestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestGCRA(t *testing.T) {
+	mc := newFakeMemcached()
+	g := NewGCRA(mc, 10, 2)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, err := g.AllowN(ctx, "alice", now, 1); err != nil || !ok {
+			t.Fatalf("AllowN #%d = %v, %v, want true", i, ok, err)
+		}
+	}
+	if ok, _ := g.AllowN(ctx, "alice", now, 1); ok {
+		t.Error("expected the burst to be used up")
+	}
+	if ok, _ := g.AllowN(ctx, "bob", now, 1); !ok {
+		t.Error("expected keys to be limited separately")
+	}
+	if ok, _ := g.AllowN(ctx, "alice", now.Add(100*time.Millisecond), 1); !ok {
+		t.Error("expected an event to be admitted after 100ms")
+	}
+
+	it, ok := mc.items["rateflow:alice"]
+	if !ok {
+		t.Fatal("expected state under the default prefix")
+	}
+	if it.ttl < time.Second || it.ttl%time.Second != 0 {
+		t.Errorf("ttl = %v, want whole seconds", it.ttl)
+	}
+}
+
+func TestGCRAReserve(t *testing.T) {
+	g := NewGCRA(newFakeMemcached(), 10, 1)
+	ctx := context.Background()
+	now := time.Now()
+
+	g.AllowN(ctx, "k", now, 1)
+	r, err := g.ReserveN(ctx, "k", now, 1)
+	if err != nil || !r.OK() {
+		t.Fatalf("ReserveN = %v, %v, want OK", r, err)
+	}
+	if d := r.DelayFrom(now); d != 100*time.Millisecond {
+		t.Errorf("delay = %v, want 100ms", d)
+	}
+
+	if err := r.CancelAt(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	r, _ = g.ReserveN(ctx, "k", now, 1)
+	if d := r.DelayFrom(now); d != 100*time.Millisecond {
+		t.Errorf("delay after cancel = %v, want the refund to free the slot", d)
+	}
+
+	r, _ = g.ReserveN(ctx, "k", now, 2)
+	if r.OK() || !r.RetryAt().IsZero() {
+		t.Error("n above the burst should be refused for good")
+	}
+	if err := g.WaitN(ctx, "k", 2); !errors.Is(err, rateflow.ErrExceedsBurst) {
+		t.Errorf("WaitN = %v, want ErrExceedsBurst", err)
+	}
+}
+
+func TestGCRAWaitDeadline(t *testing.T) {
+	g := NewGCRA(newFakeMemcached(), 1, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := g.WaitN(ctx, "k", 1); err != nil {
+		t.Fatal(err)
+	}
+	var rle *rateflow.RateLimitError
+	if err := g.WaitN(ctx, "k", 1); !errors.As(err, &rle) {
+		t.Errorf("WaitN = %v, want a RateLimitError", err)
+	}
+}
+
+func TestCASContention(t *testing.T) {
+	mc := newFakeMemcached()
+	g := NewGCRA(mc, 1, 5, WithMaxRetries(2))
+	ctx := context.Background()
+	now := time.Now()
+
+	g.AllowN(ctx, "k", now, 1)
+	mc.conflicts = 2
+	if ok, err := g.AllowN(ctx, "k", now, 1); err != nil || !ok {
+		t.Errorf("AllowN = %v, %v, want success after retrying", ok, err)
+	}
+
+	mc.conflicts = 3
+	if _, err := g.AllowN(ctx, "k", now, 1); !errors.Is(err, ErrContention) {
+		t.Errorf("AllowN = %v, want ErrContention", err)
+	}
+}
@@ -0,0 +1,30 @@
+package memcachestore
+
+// Option configures a memcached limiter
+type Option func(*config)
+
+type config struct {
+	prefix  string
+	retries int
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{prefix: "rateflow:", retries: 10}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithPrefix sets the prefix of every memcached key the limiter uses,
+// "rateflow:" by default, so several limiters can share a server
+func WithPrefix(prefix string) Option {
+	return func(c *config) { c.prefix = prefix }
+}
+
+// WithMaxRetries sets how many times a decision is retried after losing
+// a compare-and-swap race to another replica before it fails with
+// ErrContention. It defaults to 10
+func WithMaxRetries(n int) Option {
+	return func(c *config) { c.retries = n }
+}
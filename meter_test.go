@@ -0,0 +1,61 @@
+package rateflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTwoRateMeter(t *testing.T) {
+	lim := NewTwoRateMeter(Limit(10), 5, Limit(20), 8)
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		if v := lim.MarkN(now, 1); v != Conform {
+			t.Fatalf("event %d: expected conform, got %s", i, v)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if v := lim.MarkN(now, 1); v != Exceed {
+			t.Fatalf("event %d: expected exceed, got %s", i, v)
+		}
+	}
+	if v := lim.MarkN(now, 1); v != Violate {
+		t.Errorf("expected violate beyond the peak burst, got %s", v)
+	}
+	if lim.AllowN(now, 1) {
+		t.Error("expected AllowN to deny violating events")
+	}
+
+	// After 100ms the peak bucket has 2 tokens but the committed bucket 1
+	later := now.Add(100 * time.Millisecond)
+	if v := lim.MarkN(later, 1); v != Conform {
+		t.Errorf("expected conform after refill, got %s", v)
+	}
+	if v := lim.MarkN(later, 1); v != Exceed {
+		t.Errorf("expected exceed after refill, got %s", v)
+	}
+}
+
+func TestSingleRateMeter(t *testing.T) {
+	lim := NewSingleRateMeter(Limit(10), 2, 3)
+	now := time.Now()
+
+	verdicts := []Verdict{Conform, Conform, Exceed, Exceed, Exceed, Violate}
+	for i, want := range verdicts {
+		if v := lim.MarkN(now, 1); v != want {
+			t.Errorf("event %d: expected %s, got %s", i, want, v)
+		}
+	}
+
+	// Refill goes to the committed bucket first, then overflows to excess
+	later := now.Add(300 * time.Millisecond)
+	if tokens := lim.TokensAt(later); tokens != 2 {
+		t.Errorf("expected a full committed bucket, got %f", tokens)
+	}
+	verdicts = []Verdict{Conform, Conform, Exceed, Violate}
+	for i, want := range verdicts {
+		if v := lim.MarkN(later, 1); v != want {
+			t.Errorf("event %d after refill: expected %s, got %s", i, want, v)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+// Package metrics exports the activity of limiters as Prometheus metrics.
+// A Collector reads the Stats of the limiters registered with it when it
+// is scraped, and times waits through the Listener it hands out:
+//
+//	c := metrics.NewCollector()
+//	api := rateflow.NewLimiterWithOptions(rateflow.TokenBucket, 100, 10, rateflow.WithListener(c.Listener("api")))
+//	c.Register("api", api)
+//	http.Handle("/metrics", c)
+//
+// Collector is a prometheus.Collector, so it can also be registered with
+// a prometheus.Registry instead of being mounted on its own:
+//
+//	prometheus.MustRegister(c)
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// Type is the kind of a metric
+type Type int
+
+const (
+	Counter Type = iota
+	Gauge
+	Histogram
+)
+
+// String returns the name of t in the Prometheus text format
+func (t Type) String() string {
+	switch t {
+	case Counter:
+		return "counter"
+	case Gauge:
+		return "gauge"
+	case Histogram:
+		return "histogram"
+	}
+	return "untyped"
+}
+
+// Label is one label of a sample
+type Label struct {
+	Name, Value string
+}
+
+// Labels are the labels of a sample, in a fixed order per metric
+type Labels []Label
+
+// Split returns the names and the values of l
+func (l Labels) Split() (names, values []string) {
+	for _, label := range l {
+		names = append(names, label.Name)
+		values = append(values, label.Value)
+	}
+	return names, values
+}
+
+// HistogramValue is the state of a histogram. Buckets maps each upper
+// bound to the number of observations at or below it
+type HistogramValue struct {
+	Count   uint64
+	Sum     float64
+	Buckets map[float64]uint64
+}
+
+// Sample is one labelled value of a metric; Histogram is set instead of
+// Value for histograms
+type Sample struct {
+	Labels    Labels
+	Value     float64
+	Histogram *HistogramValue
+}
+
+// Family is a metric with all its samples
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// DefaultBuckets are the bounds in seconds of the wait histogram
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30}
+
+// Option configures a Collector
+type Option func(*Collector)
+
+// WithNamespace prefixes metric names with namespace instead of
+// "rateflow"
+func WithNamespace(namespace string) Option {
+	return func(c *Collector) {
+		c.namespace = namespace
+	}
+}
+
+// WithBuckets sets the bounds in seconds of the wait histogram
+func WithBuckets(bounds ...float64) Option {
+	return func(c *Collector) {
+		c.buckets = append([]float64(nil), bounds...)
+		sort.Float64s(c.buckets)
+	}
+}
+
+// source adds the samples of one registered name to a scrape
+type source interface {
+	collect(s *scrape)
+}
+
+// Collector gathers the metrics of the limiters registered with it
+type Collector struct {
+	namespace string
+	buckets   []float64
+
+	mu      sync.Mutex
+	sources map[string]source
+	waits   map[string]*histogram
+}
+
+// NewCollector creates an empty Collector
+func NewCollector(opts ...Option) *Collector {
+	c := &Collector{
+		namespace: "rateflow",
+		buckets:   DefaultBuckets,
+		sources:   make(map[string]source),
+		waits:     make(map[string]*histogram),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Register exports lim's Stats under the label limiter=name, replacing
+// anything registered under name before
+func (c *Collector) Register(name string, lim rateflow.Limiter) {
+	c.add(name, limiterSource{name, lim})
+}
+
+// Unregister stops exporting name. Its wait histogram is kept, as its
+// Listener may still be in use
+func (c *Collector) Unregister(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.sources, name)
+}
+
+func (c *Collector) add(name string, s source) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sources[name] = s
+}
+
+// Listener returns a Listener recording how long Waits take in the wait
+// histogram of name. Pass it to the limiters registered under name with
+// rateflow.WithListener; for a Keyed, to every key's limiter, which share
+// one histogram
+func (c *Collector) Listener(name string) rateflow.Listener {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, ok := c.waits[name]
+	if !ok {
+		h = newHistogram(c.buckets)
+		c.waits[name] = h
+	}
+	return rateflow.ListenerFuncs{Wait: func(e rateflow.Event) {
+		h.observe(e.Delay.Seconds())
+	}}
+}
+
+// KeyedOptions controls the per-key metrics of a Keyed
+type KeyedOptions[K comparable] struct {
+	// Keys is how many keys get metrics of their own, the most throttled
+	// first, to bound the number of series. 0 exports only the totals of
+	// the Keyed
+	Keys int
+	// Label turns a key into the value of the key label, fmt.Sprint if nil
+	Label func(key K) string
+}
+
+// RegisterKeyed exports the number of keys in k under the label
+// limiter=name and, as opts allow, the Stats of its keys as the key_
+// metrics, e.g. rateflow_key_denied_total, labelled with the key too
+func RegisterKeyed[K comparable](c *Collector, name string, k *rateflow.Keyed[K], opts KeyedOptions[K]) {
+	if opts.Label == nil {
+		opts.Label = func(key K) string { return fmt.Sprint(key) }
+	}
+	c.add(name, keyedSource[K]{name, k, opts})
+}
+
+type limiterSource struct {
+	name string
+	lim  rateflow.Limiter
+}
+
+func (s limiterSource) collect(sc *scrape) {
+	sc.stats("", Labels{{"limiter", s.name}}, s.lim.Stats())
+}
+
+type keyedSource[K comparable] struct {
+	name string
+	k    *rateflow.Keyed[K]
+	opts KeyedOptions[K]
+}
+
+func (s keyedSource[K]) collect(sc *scrape) {
+	labels := Labels{{"limiter", s.name}}
+	stats := s.k.Stats()
+	sc.add("keys", "Keys with a limiter.", Gauge, labels, float64(stats.Active))
+	sc.add("keys_created_total", "Limiters created for new keys.", Counter, labels, float64(stats.Created))
+	sc.add("keys_evicted_total", "Limiters evicted for idleness or capacity.", Counter, labels, float64(stats.Evicted))
+	if s.opts.Keys <= 0 {
+		return
+	}
+	for _, u := range s.k.Top(s.opts.Keys, rateflow.ByDenied) {
+		sc.stats("key_", Labels{{"limiter", s.name}, {"key", s.opts.Label(u.Key)}}, u.Stats)
+	}
+}
+
+// scrape collects the samples of every source into families
+type scrape struct {
+	namespace string
+	families  map[string]*Family
+	order     []string
+}
+
+func (sc *scrape) add(name, help string, typ Type, labels Labels, v float64) {
+	f := sc.family(name, help, typ)
+	f.Samples = append(f.Samples, Sample{Labels: labels, Value: v})
+}
+
+func (sc *scrape) family(name, help string, typ Type) *Family {
+	name = sc.namespace + "_" + name
+	f, ok := sc.families[name]
+	if !ok {
+		f = &Family{Name: name, Help: help, Type: typ}
+		sc.families[name] = f
+		sc.order = append(sc.order, name)
+	}
+	return f
+}
+
+// stats adds the samples of s. Per-key samples go to families of their
+// own, prefixed "key_", as a family must use the same label names for
+// every sample
+func (sc *scrape) stats(prefix string, labels Labels, s rateflow.Stats) {
+	sc.add(prefix+"allowed_total", "Events admitted.", Counter, labels, float64(s.Allowed))
+	sc.add(prefix+"denied_total", "Events rejected or whose wait failed.", Counter, labels, float64(s.Denied))
+	for _, why := range []rateflow.DenialReason{rateflow.DeniedLimited, rateflow.DeniedExceeds, rateflow.DeniedCanceled, rateflow.DeniedStore, rateflow.DeniedShed} {
+		sc.add(prefix+"denials_total", "Denied events by reason.", Counter, append(labels[:len(labels):len(labels)], Label{"reason", why.String()}), float64(s.Denials.Count(why)))
+	}
+	sc.add(prefix+"tokens", "Tokens left, or free queue space for a leaky bucket.", Gauge, labels, s.Tokens)
+	sc.add(prefix+"waiting", "Goroutines blocked in Wait.", Gauge, labels, float64(s.Waiting))
+}
+
+// Gather returns the current value of every metric, sorted by name and
+// with samples in registration name order
+func (c *Collector) Gather() []Family {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.sources))
+	for name := range c.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sources := make([]source, len(names))
+	for i, name := range names {
+		sources[i] = c.sources[name]
+	}
+	waitNames := make([]string, 0, len(c.waits))
+	for name := range c.waits {
+		waitNames = append(waitNames, name)
+	}
+	sort.Strings(waitNames)
+	waits := make([]*histogram, len(waitNames))
+	for i, name := range waitNames {
+		waits[i] = c.waits[name]
+	}
+	c.mu.Unlock()
+
+	sc := &scrape{namespace: c.namespace, families: make(map[string]*Family)}
+	for _, s := range sources {
+		s.collect(sc)
+	}
+	for i, h := range waits {
+		f := sc.family("wait_seconds", "Time spent in Wait.", Histogram)
+		f.Samples = append(f.Samples, Sample{Labels: Labels{{"limiter", waitNames[i]}}, Histogram: h.value()})
+	}
+
+	sort.Strings(sc.order)
+	families := make([]Family, len(sc.order))
+	for i, name := range sc.order {
+		families[i] = *sc.families[name]
+	}
+	return families
+}
+
+// histogram counts observations in buckets
+type histogram struct {
+	bounds []float64
+
+	mu     sync.Mutex
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *histogram) value() *HistogramValue {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	v := &HistogramValue{Count: h.count, Sum: h.sum, Buckets: make(map[float64]uint64, len(h.bounds))}
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		v.Buckets[bound] = cumulative
+	}
+	return v
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector(WithBuckets(0.01, 0.001))
+	api := rateflow.NewLimiterWithOptions(rateflow.TokenBucket, 1000, 2, rateflow.WithListener(c.Listener("api")))
+	c.Register("api", api)
+	api.Allow()
+	api.Allow()
+	api.Allow()
+	if err := api.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	users := rateflow.NewKeyedLimiter[string](rateflow.TokenBucket, rateflow.Every(time.Hour), 1)
+	RegisterKeyed(c, "users", users, KeyedOptions[string]{Keys: 1, Label: strings.ToUpper})
+	users.AllowKey("alice")
+	users.AllowKey("bob")
+	users.AllowKey("bob")
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE rateflow_allowed_total counter\n",
+		`rateflow_allowed_total{limiter="api"} 3` + "\n",
+		`rateflow_denied_total{limiter="api"} 1` + "\n",
+		`rateflow_denials_total{limiter="api",reason="limited"} 1` + "\n",
+		`rateflow_denials_total{limiter="api",reason="exceeds_burst"} 0` + "\n",
+		`rateflow_waiting{limiter="api"} 0` + "\n",
+		"# TYPE rateflow_wait_seconds histogram\n",
+		`rateflow_wait_seconds_bucket{limiter="api",le="0.01"} 1` + "\n",
+		`rateflow_wait_seconds_bucket{limiter="api",le="+Inf"} 1` + "\n",
+		`rateflow_wait_seconds_count{limiter="api"} 1` + "\n",
+		`rateflow_keys{limiter="users"} 2` + "\n",
+		`rateflow_key_denied_total{limiter="users",key="BOB"} 1` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `key="ALICE"`) {
+		t.Error("expected only the most throttled key to be exported")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{1, 2})
+	for _, v := range []float64{0.5, 1, 1.5, 3} {
+		h.observe(v)
+	}
+	v := h.value()
+	if v.Count != 4 || v.Sum != 6 || v.Buckets[1] != 2 || v.Buckets[2] != 3 {
+		t.Errorf("value = %+v, want 4 observations, 2 at or below 1 and 3 at or below 2", v)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	c := NewCollector(WithNamespace("app"))
+	c.Register("a\"b\\c\nd", rateflow.NewLimiter(rateflow.TokenBucket, 1, 1))
+	var sb strings.Builder
+	if err := c.WriteText(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if want := `app_tokens{limiter="a\"b\\c\nd"} 1`; !strings.Contains(sb.String(), want) {
+		t.Errorf("missing %q in:\n%s", want, sb.String())
+	}
+}
+
+func TestPrometheusCollector(t *testing.T) {
+	c := NewCollector()
+	api := rateflow.NewLimiterWithOptions(rateflow.TokenBucket, 1000, 1, rateflow.WithListener(c.Listener("api")))
+	c.Register("api", api)
+	api.Allow()
+	api.Allow()
+	if err := api.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]*dto.MetricFamily)
+	for _, f := range families {
+		got[f.GetName()] = f
+	}
+	if f := got["rateflow_allowed_total"]; f == nil || f.GetMetric()[0].GetCounter().GetValue() != 2 {
+		t.Errorf("rateflow_allowed_total = %v, want 2", f)
+	}
+	if f := got["rateflow_tokens"]; f == nil || f.GetType() != dto.MetricType_GAUGE {
+		t.Errorf("rateflow_tokens = %v, want a gauge", f)
+	}
+	if f := got["rateflow_wait_seconds"]; f == nil || f.GetMetric()[0].GetHistogram().GetSampleCount() != 1 {
+		t.Errorf("rateflow_wait_seconds = %v, want one wait", f)
+	}
+}
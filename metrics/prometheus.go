@@ -0,0 +1,40 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// Describe sends nothing: the metrics of a Collector come and go with
+// the limiters and keys registered, so it is an unchecked collector
+func (c *Collector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect sends the current value of every metric, so the Collector can
+// be registered with a prometheus.Registry next to the process's other
+// metrics:
+//
+//	prometheus.MustRegister(c)
+//	http.Handle("/metrics", promhttp.Handler())
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, f := range c.Gather() {
+		for _, s := range f.Samples {
+			names, values := s.Labels.Split()
+			desc := prometheus.NewDesc(f.Name, f.Help, names, nil)
+			var (
+				m   prometheus.Metric
+				err error
+			)
+			switch f.Type {
+			case Counter:
+				m, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, s.Value, values...)
+			case Gauge:
+				m, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, s.Value, values...)
+			case Histogram:
+				m, err = prometheus.NewConstHistogram(desc, s.Histogram.Count, s.Histogram.Sum, s.Histogram.Buckets, values...)
+			}
+			if err != nil {
+				m = prometheus.NewInvalidMetric(desc, err)
+			}
+			ch <- m
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// contentType is the Prometheus text exposition format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// ServeHTTP writes the metrics in the Prometheus text format, so the
+// Collector can be mounted as the /metrics handler
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	c.WriteText(w)
+}
+
+// WriteText writes the metrics to w in the Prometheus text format
+func (c *Collector) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range c.Gather() {
+		bw.WriteString("# HELP " + f.Name + " " + escape(f.Help, false) + "\n")
+		bw.WriteString("# TYPE " + f.Name + " " + f.Type.String() + "\n")
+		for _, s := range f.Samples {
+			if s.Histogram == nil {
+				writeSample(bw, f.Name, s.Labels, s.Value)
+				continue
+			}
+			h := s.Histogram
+			bounds := make([]float64, 0, len(h.Buckets))
+			for bound := range h.Buckets {
+				bounds = append(bounds, bound)
+			}
+			sort.Float64s(bounds)
+			for _, bound := range bounds {
+				writeSample(bw, f.Name+"_bucket", append(s.Labels[:len(s.Labels):len(s.Labels)], Label{"le", formatFloat(bound)}), float64(h.Buckets[bound]))
+			}
+			writeSample(bw, f.Name+"_bucket", append(s.Labels[:len(s.Labels):len(s.Labels)], Label{"le", "+Inf"}), float64(h.Count))
+			writeSample(bw, f.Name+"_sum", s.Labels, h.Sum)
+			writeSample(bw, f.Name+"_count", s.Labels, float64(h.Count))
+		}
+	}
+	return bw.Flush()
+}
+
+func writeSample(w *bufio.Writer, name string, labels Labels, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(l.Name + `="` + escape(l.Value, true) + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// escape escapes a help text, or a label value if label is set
+func escape(s string, label bool) string {
+	if label {
+		return labelEscaper.Replace(s)
+	}
+	return helpEscaper.Replace(s)
+}
@@ -0,0 +1,69 @@
+package rateflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMultiWindowLimiterRules(t *testing.T) {
+	lim := NewMultiWindowLimiter(
+		WindowRule{Count: 10, Window: time.Hour},
+		WindowRule{Count: 3, Window: time.Second},
+	)
+	now := time.Now()
+
+	rules := lim.Rules()
+	if rules[0].Window != time.Second {
+		t.Fatalf("expected rules sorted by window, got %v", rules)
+	}
+
+	for i := 0; i < 3; i++ {
+		if !lim.AllowN(now, 1) {
+			t.Fatalf("expected request %d to be allowed", i)
+		}
+	}
+	if ok, rule := lim.AllowNRule(now, 1); ok || rule != 0 {
+		t.Errorf("expected per-second rule to deny, got ok=%v rule=%d", ok, rule)
+	}
+
+	// After a second the short window has refilled but the hourly one has not
+	later := now.Add(time.Second)
+	for i := 0; i < 3; i++ {
+		lim.AllowN(later, 1)
+	}
+	later = later.Add(time.Second)
+	lim.AllowN(later, 3)
+	later = later.Add(time.Second)
+	if ok, rule := lim.AllowNRule(later, 2); ok || rule != 1 {
+		t.Errorf("expected hourly rule to deny, got ok=%v rule=%d", ok, rule)
+	}
+}
+
+func TestMultiWindowLimiterReserve(t *testing.T) {
+	lim := NewMultiWindowLimiter(
+		WindowRule{Count: 2, Window: 100 * time.Millisecond},
+		WindowRule{Count: 4, Window: time.Second},
+	)
+	now := time.Now()
+
+	lim.AllowN(now, 2)
+	r := lim.ReserveN(now, 2)
+	if !r.OK() {
+		t.Fatal("expected reservation to be OK")
+	}
+	// The short rule refills 2 tokens in 100ms
+	if d := r.DelayFrom(now); d < 99*time.Millisecond || d > 101*time.Millisecond {
+		t.Errorf("expected ~100ms delay, got %v", d)
+	}
+
+	if r := lim.ReserveN(now, 5); r.OK() {
+		t.Error("expected reservation larger than any rule to fail")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := lim.WaitN(ctx, 2); err == nil {
+		t.Error("expected wait beyond the hourly budget to time out")
+	}
+}
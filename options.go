@@ -0,0 +1,123 @@
+package rateflow
+
+import (
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// Clock provides the current time and timers to a limiter
+type Clock = limiter.Clock
+
+// Option configures a limiter created by NewLimiterWithOptions
+type Option func(*limiter.Config)
+
+// WithClock makes the limiter read time from c instead of the system clock,
+// which is mostly useful for deterministic tests
+func WithClock(c Clock) Option {
+	return func(cfg *limiter.Config) {
+		cfg.Clock = c
+	}
+}
+
+// WithWindow sets the window in which the burst may be spent, replacing
+// the rate argument with burst/window. Window algorithms use it directly
+func WithWindow(window time.Duration) Option {
+	return func(cfg *limiter.Config) {
+		cfg.Window = window
+	}
+}
+
+// WithAlignment controls where fixed windows start. Aligned windows begin
+// at multiples of the window duration on the wall clock (e.g. on the
+// minute); unaligned windows begin at the first event after the previous
+// window expired
+func WithAlignment(aligned bool) Option {
+	return func(cfg *limiter.Config) {
+		if aligned {
+			cfg.Alignment = limiter.AlignWallClock
+		} else {
+			cfg.Alignment = limiter.AlignFirstEvent
+		}
+	}
+}
+
+// WithJitter adds a random delay of up to d to every wait, spreading out
+// waiters that would otherwise wake at the same instant
+func WithJitter(d time.Duration) Option {
+	return func(cfg *limiter.Config) {
+		cfg.Jitter = d
+	}
+}
+
+// WithName names the limiter for logging and metrics
+func WithName(name string) Option {
+	return func(cfg *limiter.Config) {
+		cfg.Name = name
+	}
+}
+
+// Event describes one decision made by a limiter, as passed to a Listener
+type Event = limiter.Event
+
+// Listener receives an Event for every Allow, Reserve and Wait decision.
+// It is called synchronously, possibly with the limiter's lock held, so
+// it must be quick and must not call back into the limiter
+type Listener = limiter.Listener
+
+// ListenerFuncs is a Listener built from optional functions
+type ListenerFuncs = limiter.ListenerFuncs
+
+// WithListener sends every decision the limiter makes to l, e.g. to log
+// denials or feed metrics
+func WithListener(l Listener) Option {
+	return func(cfg *limiter.Config) {
+		cfg.Listener = l
+	}
+}
+
+// NewLimiterWithOptions creates a new rate limiter with the specified
+// algorithm, rate and burst, then applies opts. Options an algorithm has
+// no use for are ignored
+func NewLimiterWithOptions(algo Algorithm, r Limit, b int, opts ...Option) Limiter {
+	cfg := limiter.Config{Algorithm: algo}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.Window > 0 {
+		r = Limit(float64(b) / cfg.Window.Seconds())
+	}
+
+	lim := NewLimiter(algo, r, b)
+	if c, ok := lim.(limiter.Configurable); ok {
+		c.Configure(cfg)
+	}
+	return lim
+}
+
+// NewLimiterE is like NewLimiterWithOptions but rejects unknown
+// algorithms, negative limits, bursts below 1 and negative windows
+// instead of silently substituting defaults
+func NewLimiterE(algo Algorithm, r Limit, b int, opts ...Option) (Limiter, error) {
+	var cfg limiter.Config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.Window < 0 {
+		return nil, &ConfigError{Field: "window", Value: cfg.Window, Err: ErrInvalidWindow}
+	}
+	if err := limiter.Validate(algo, r, b); err != nil {
+		return nil, err
+	}
+	return NewLimiterWithOptions(algo, r, b, opts...), nil
+}
+
+// NameOf returns the name given to lim with WithName, if any
+func NameOf(lim Limiter) string {
+	if n, ok := lim.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return ""
+}
@@ -0,0 +1,178 @@
+package rateflow
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced Clock for deterministic tests
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeTimer{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !w.at.After(c.now) {
+			w.ch <- c.now
+		} else {
+			pending = append(pending, w)
+		}
+	}
+	c.waiters = pending
+}
+
+// blockUntil waits for n goroutines to be blocked on After
+func (c *fakeClock) blockUntil(n int) {
+	for {
+		c.mu.Lock()
+		waiting := len(c.waiters)
+		c.mu.Unlock()
+		if waiting >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	for _, algo := range []Algorithm{TokenBucket, LeakyBucket, SlidingWindow, FixedWindow, MultiWindow, EWMA} {
+		clock := newFakeClock()
+		lim := NewLimiterWithOptions(algo, Limit(1), 2, WithClock(clock))
+
+		if !lim.Allow() || !lim.Allow() {
+			t.Fatalf("%s: expected the burst to be available", algo)
+		}
+		if lim.Allow() {
+			t.Errorf("%s: expected the burst to be exhausted", algo)
+		}
+
+		clock.Advance(time.Hour)
+		if !lim.Allow() {
+			t.Errorf("%s: expected capacity after advancing the clock", algo)
+		}
+	}
+}
+
+func TestWithClockWait(t *testing.T) {
+	clock := newFakeClock()
+	lim := NewLimiterWithOptions(TokenBucket, Limit(1), 1, WithClock(clock))
+	lim.Allow()
+
+	done := make(chan error, 1)
+	go func() { done <- lim.Wait(context.Background()) }()
+
+	select {
+	case <-done:
+		t.Fatal("Wait returned before the clock advanced")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(2 * time.Second)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after the clock advanced")
+	}
+}
+
+func TestWithWindow(t *testing.T) {
+	for _, algo := range []Algorithm{SlidingWindow, FixedWindow, TokenBucket} {
+		lim := NewLimiterWithOptions(algo, Limit(1), 100, WithWindow(10*time.Second))
+		if lim.Limit() != Limit(10) {
+			t.Errorf("%s: expected Limit() = 10, got %v", algo, lim.Limit())
+		}
+	}
+}
+
+func TestWithAlignment(t *testing.T) {
+	clock := newFakeClock()
+	clock.Advance(30 * time.Second)
+
+	aligned := NewLimiterWithOptions(FixedWindow, Limit(1), 60, WithClock(clock), WithAlignment(true))
+	unaligned := NewLimiterWithOptions(FixedWindow, Limit(1), 60, WithClock(clock), WithAlignment(false))
+	aligned.AllowN(clock.Now(), 60)
+	unaligned.AllowN(clock.Now(), 60)
+
+	// The aligned window started on the minute and ends 30s from now
+	clock.Advance(30 * time.Second)
+	if !aligned.Allow() {
+		t.Error("expected the aligned window to reset on the minute")
+	}
+	if unaligned.Allow() {
+		t.Error("expected the unaligned window to last a full minute")
+	}
+}
+
+func TestWithJitterAndName(t *testing.T) {
+	lim := NewLimiterWithOptions(TokenBucket, Limit(100), 1, WithJitter(20*time.Millisecond), WithName("api"))
+	if NameOf(lim) != "api" {
+		t.Errorf("expected name api, got %q", NameOf(lim))
+	}
+
+	lim.Allow()
+	start := time.Now()
+	if err := lim.Wait(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("jittered wait took too long: %v", elapsed)
+	}
+}
+
+func TestWaitTimed(t *testing.T) {
+	clock := newFakeClock()
+	lim := NewLimiterWithOptions(TokenBucket, Limit(1), 1, WithClock(clock))
+
+	if d, err := WaitTimed(context.Background(), lim, 1); err != nil || d != 0 {
+		t.Fatalf("expected an immediate wait, got %v %v", d, err)
+	}
+
+	done := make(chan time.Duration)
+	go func() {
+		d, _ := WaitTimed(context.Background(), lim, 1)
+		done <- d
+	}()
+	clock.blockUntil(1)
+	clock.Advance(2 * time.Second)
+	if d := <-done; d != 2*time.Second {
+		t.Errorf("expected to have waited 2s, got %v", d)
+	}
+}
@@ -0,0 +1,196 @@
+package rateflow
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// PenaltyPolicy configures Penalty. A violation is a request the keyed
+// limiter refused, or one reported with Violation, e.g. a failed login
+type PenaltyPolicy struct {
+	// Threshold is the number of violations tolerated before the first
+	// lockout; 0 locks out on the first violation
+	Threshold int
+	// Lockout is how long the first lockout lasts
+	Lockout time.Duration
+	// Factor multiplies the lockout for every further violation; values
+	// below 1 mean 2, doubling it each time
+	Factor float64
+	// MaxLockout caps the lockout; 0 means no cap
+	MaxLockout time.Duration
+	// Decay forgets one violation for every Decay that passes without a
+	// new one; 0 never forgets
+	Decay time.Duration
+	// Clock defaults to the system clock
+	Clock Clock
+}
+
+// Penalty adds progressive lockouts on top of a keyed limiter: repeated
+// violations by a key lock it out for escalating periods, the standard
+// protection against brute-forcing logins. Good behavior lets violations
+// decay again
+type Penalty[K comparable] struct {
+	keyed  *Keyed[K]
+	policy PenaltyPolicy
+
+	mu      sync.Mutex
+	states  map[K]*penaltyState
+	pruneAt int // size of states that triggers dropping decayed ones
+}
+
+type penaltyState struct {
+	violations  int
+	last        time.Time // latest violation or decay step
+	lockedUntil time.Time
+}
+
+// NewPenalty wraps keyed with the lockouts described by policy
+func NewPenalty[K comparable](keyed *Keyed[K], policy PenaltyPolicy) *Penalty[K] {
+	if policy.Factor < 1 {
+		policy.Factor = 2
+	}
+	return &Penalty[K]{keyed: keyed, policy: policy, states: make(map[K]*penaltyState)}
+}
+
+func (p *Penalty[K]) now() time.Time {
+	if p.policy.Clock == nil {
+		return time.Now()
+	}
+	return p.policy.Clock.Now()
+}
+
+// Allow refuses key while it is locked out and otherwise asks the keyed
+// limiter, counting a refusal as a violation
+func (p *Penalty[K]) Allow(key K) bool {
+	if p.LockedFor(key) > 0 {
+		return false
+	}
+	if p.keyed.AllowKey(key) {
+		return true
+	}
+	p.Violation(key)
+	return false
+}
+
+// Wait fails with a RateLimitError while key is locked out and otherwise
+// waits on the keyed limiter, counting a failed wait as a violation
+func (p *Penalty[K]) Wait(ctx context.Context, key K) error {
+	if d := p.LockedFor(key); d > 0 {
+		return &RateLimitError{RetryAfter: d}
+	}
+	if err := p.keyed.WaitKey(ctx, key); err != nil {
+		if ctx.Err() == nil {
+			p.Violation(key)
+		}
+		return err
+	}
+	return nil
+}
+
+// Violation records a violation by key and returns how long key is now
+// locked out, 0 while it is within the threshold
+func (p *Penalty[K]) Violation(key K) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if len(p.states) >= p.pruneAt {
+		for k, s := range p.states {
+			p.decay(k, s, now)
+		}
+		p.pruneAt = 2*len(p.states) + 64
+	}
+	s := p.state(key, now)
+	s.violations++
+	s.last = now
+
+	over := s.violations - p.policy.Threshold
+	if over <= 0 {
+		return 0
+	}
+	lockout := InfDuration
+	if f := float64(p.policy.Lockout) * math.Pow(p.policy.Factor, float64(over-1)); f < float64(InfDuration) {
+		lockout = time.Duration(f)
+	}
+	if p.policy.MaxLockout > 0 && lockout > p.policy.MaxLockout {
+		lockout = p.policy.MaxLockout
+	}
+	s.lockedUntil = now.Add(lockout)
+	return lockout
+}
+
+// LockedFor returns how much longer key is locked out, 0 if it is not
+func (p *Penalty[K]) LockedFor(key K) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	s, ok := p.states[key]
+	if !ok || !now.Before(s.lockedUntil) {
+		return 0
+	}
+	return s.lockedUntil.Sub(now)
+}
+
+// Violations returns the violations currently held against key, after
+// decay
+func (p *Penalty[K]) Violations(key K) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.states[key]; ok {
+		p.decay(key, s, p.now())
+		return s.violations
+	}
+	return 0
+}
+
+// Forgive clears key's violations and lifts its lockout, e.g. after a
+// successful login
+func (p *Penalty[K]) Forgive(key K) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.states, key)
+}
+
+// state returns key's state decayed up to now, creating it if needed.
+// p.mu must be held
+func (p *Penalty[K]) state(key K, now time.Time) *penaltyState {
+	s, ok := p.states[key]
+	if !ok {
+		s = &penaltyState{last: now}
+		p.states[key] = s
+		return s
+	}
+	p.decay(key, s, now)
+	p.states[key] = s
+	return s
+}
+
+// decay forgets the violations whose Decay periods have passed and drops
+// a state with none left and no lockout. p.mu must be held
+func (p *Penalty[K]) decay(key K, s *penaltyState, now time.Time) {
+	if p.policy.Decay <= 0 || s.violations == 0 {
+		return
+	}
+	if now.Before(s.lockedUntil) {
+		return
+	}
+	// Decay starts once the lockout is over
+	from := s.last
+	if s.lockedUntil.After(from) {
+		from = s.lockedUntil
+	}
+	steps := int(now.Sub(from) / p.policy.Decay)
+	if steps <= 0 {
+		return
+	}
+	if steps >= s.violations {
+		delete(p.states, key)
+		s.violations = 0
+		return
+	}
+	s.violations -= steps
+	s.last = from.Add(time.Duration(steps) * p.policy.Decay)
+	s.lockedUntil = time.Time{}
+}
@@ -0,0 +1,77 @@
+package rateflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPenaltyEscalates(t *testing.T) {
+	clock := newFakeClock()
+	keyed := NewKeyedLimiter[string](FixedWindow, Limit(1), 1, WithClock(clock))
+	p := NewPenalty(keyed, PenaltyPolicy{
+		Threshold:  1,
+		Lockout:    time.Minute,
+		MaxLockout: 3 * time.Minute,
+		Clock:      clock,
+	})
+
+	if !p.Allow("ip") {
+		t.Fatal("expected the first request to pass")
+	}
+	if p.Allow("ip") || p.LockedFor("ip") != 0 {
+		t.Fatal("expected a refusal within the threshold without a lockout")
+	}
+
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		if d := p.Violation("ip"); d != want {
+			t.Errorf("expected a lockout of %v, got %v", want, d)
+		}
+	}
+	clock.Advance(2 * time.Minute)
+	if p.Allow("ip") {
+		t.Error("expected the key to stay locked out")
+	}
+	clock.Advance(time.Minute)
+	if !p.Allow("ip") {
+		t.Error("expected the lockout to end")
+	}
+	if !p.Allow("other") {
+		t.Error("expected other keys to be unaffected")
+	}
+}
+
+func TestPenaltyDecay(t *testing.T) {
+	clock := newFakeClock()
+	keyed := NewKeyedLimiter[string](TokenBucket, Limit(100), 100)
+	p := NewPenalty(keyed, PenaltyPolicy{
+		Threshold: 2,
+		Lockout:   time.Minute,
+		Decay:     10 * time.Minute,
+		Clock:     clock,
+	})
+
+	p.Violation("user")
+	p.Violation("user")
+	clock.Advance(10 * time.Minute)
+	if n := p.Violations("user"); n != 1 {
+		t.Errorf("expected one violation to decay, got %d left", n)
+	}
+	if d := p.Violation("user"); d != 0 {
+		t.Errorf("expected the decayed count to stay within the threshold, got %v", d)
+	}
+
+	clock.Advance(20 * time.Minute)
+	if n := p.Violations("user"); n != 0 {
+		t.Errorf("expected every violation to decay, got %d", n)
+	}
+
+	p.Violation("user")
+	p.Violation("user")
+	if d := p.Violation("user"); d != time.Minute {
+		t.Errorf("expected a lockout past the threshold, got %v", d)
+	}
+	p.Forgive("user")
+	if p.LockedFor("user") != 0 || p.Violations("user") != 0 {
+		t.Error("expected Forgive to clear the key")
+	}
+}
@@ -0,0 +1,174 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// fakeDB is a database/sql driver that understands the package's
+// queries, evaluating them in Go the way PostgreSQL would, so the
+// limiter can be tested without a server
+type fakeDB struct {
+	table string
+
+	mu      sync.Mutex
+	rows    map[string]fakeRow
+	queries []string
+}
+
+type fakeRow struct {
+	tokens float64
+	ts     int64
+}
+
+func newFakeDB(table string) (*sql.DB, *fakeDB) {
+	f := &fakeDB{table: table, rows: make(map[string]fakeRow)}
+	return sql.OpenDB(f), f
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+// count returns how many queries were run that are q for the table
+func (f *fakeDB) count(q string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	q = f.q(q)
+	n := 0
+	for _, query := range f.queries {
+		if query == q {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeDB) q(query string) string {
+	if query == lockQuery {
+		return query
+	}
+	return fmt.Sprintf(query, f.table)
+}
+
+type fakeConn struct{ f *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)           { return c, nil }
+func (c fakeConn) Commit() error                       { return nil }
+func (c fakeConn) Rollback() error                     { return nil }
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rows, err := c.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(rows.(*fakeRows).affected), nil
+}
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, named []driver.NamedValue) (driver.Rows, error) {
+	f := c.f
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries = append(f.queries, query)
+
+	args := make([]any, len(named))
+	for i, a := range named {
+		args[i] = a.Value
+	}
+	num := func(i int) float64 {
+		switch v := args[i].(type) {
+		case int64:
+			return float64(v)
+		case float64:
+			return v
+		}
+		panic(fmt.Sprintf("unexpected %T", args[i]))
+	}
+	refill := func(r fakeRow, burst float64, now int64, rate float64) fakeRow {
+		r.tokens = math.Min(burst, r.tokens+math.Max(float64(now-r.ts), 0)*rate/1e6)
+		if now > r.ts {
+			r.ts = now
+		}
+		return r
+	}
+
+	res := &fakeRows{}
+	switch query {
+	case f.q(createQuery), lockQuery:
+	case f.q(takeQuery):
+		key, now := args[0].(string), args[2].(int64)
+		r, ok := f.rows[key]
+		if !ok {
+			break
+		}
+		r = refill(r, num(1), now, num(5))
+		left := r.tokens - num(3)
+		admit := left >= -num(4)
+		if admit {
+			r.tokens = left
+		}
+		f.rows[key] = r
+		res.values = [][]driver.Value{{admit, left}}
+	case f.q(insertQuery):
+		key := args[0].(string)
+		if _, ok := f.rows[key]; !ok {
+			f.rows[key] = fakeRow{tokens: num(1), ts: args[2].(int64)}
+			res.affected = 1
+		}
+	case f.q(refundQuery):
+		key, now := args[0].(string), args[2].(int64)
+		if r, ok := f.rows[key]; ok {
+			r = refill(r, num(1), now, num(4))
+			r.tokens = math.Min(num(1), r.tokens+num(3))
+			f.rows[key] = r
+			res.affected = 1
+		}
+	case f.q(selectQuery):
+		if r, ok := f.rows[args[0].(string)]; ok {
+			res.values = [][]driver.Value{{r.tokens, r.ts}}
+		}
+	case f.q(upsertQuery):
+		f.rows[args[0].(string)] = fakeRow{tokens: num(1), ts: args[2].(int64)}
+		res.affected = 1
+	case f.q(cleanupQuery):
+		for key, r := range f.rows {
+			if r.ts < args[0].(int64) {
+				delete(f.rows, key)
+				res.affected++
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+	return res, nil
+}
+
+type fakeRows struct {
+	values   [][]driver.Value
+	affected int64
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.values) == 0 {
+		return nil
+	}
+	return make([]string, len(r.values[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
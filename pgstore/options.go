@@ -0,0 +1,34 @@
+package pgstore
+
+// Option configures a PostgreSQL limiter
+type Option func(*config)
+
+type config struct {
+	table    string
+	advisory bool
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{table: "rateflow_buckets"}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithTable sets the table holding the buckets, "rateflow_buckets" by
+// default. The name is used in queries as is, so it may be schema
+// qualified but must come from configuration, not from users
+func WithTable(name string) Option {
+	return func(c *config) { c.table = name }
+}
+
+// WithAdvisoryLock makes every decision a transaction that takes a
+// transaction-level advisory lock on the key, reads the bucket, computes
+// its new state in Go and writes it back, instead of a single UPDATE.
+// It costs more round trips but only needs plain SELECT and INSERT ...
+// ON CONFLICT, for PostgreSQL-compatible databases that do not support
+// row locks in an UPDATE's subquery
+func WithAdvisoryLock() Option {
+	return func(c *config) { c.advisory = true }
+}
@@ -0,0 +1,302 @@
+// Package pgstore implements rateflow.RemoteLimiter on PostgreSQL, so
+// small deployments can share limits between replicas through the
+// database they already run. Each decision is a single UPDATE ...
+// RETURNING on one row per key, which PostgreSQL serializes per row.
+//
+// The package uses database/sql and works with any PostgreSQL driver,
+// e.g. github.com/jackc/pgx/v5/stdlib or github.com/lib/pq:
+//
+//	db, err := sql.Open("pgx", dsn)
+//	...
+//	tb := pgstore.NewTokenBucket(db, 10, 20)
+//	if err := tb.CreateTable(ctx); err != nil {
+//		...
+//	}
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// Queries of the token bucket, formatted with the table name. Times are
+// Unix microseconds and refill happens in SQL, so the row is never read
+// and written back from Go outside the advisory lock mode
+const (
+	createQuery = `CREATE TABLE IF NOT EXISTS %s (
+	key text PRIMARY KEY,
+	tokens double precision NOT NULL,
+	ts bigint NOT NULL
+)`
+
+	// takeQuery refills the bucket of $1 to time $3 and takes $4 tokens
+	// if that leaves at most $5 tokens of debt. It returns whether the
+	// tokens were taken and the tokens left after taking them
+	takeQuery = `UPDATE %[1]s AS b
+SET tokens = CASE WHEN s.avail - $4 >= -$5 THEN s.avail - $4 ELSE s.avail END, ts = s.refilled
+FROM (
+	SELECT key, LEAST($2, tokens + GREATEST($3 - ts, 0) * $6 / 1e6) AS avail, GREATEST(ts, $3) AS refilled
+	FROM %[1]s WHERE key = $1 FOR UPDATE
+) AS s
+WHERE b.key = s.key
+RETURNING s.avail - $4 >= -$5, s.avail - $4`
+
+	insertQuery = `INSERT INTO %s (key, tokens, ts) VALUES ($1, $2, $3) ON CONFLICT (key) DO NOTHING`
+
+	// refundQuery refills the bucket of $1 to time $3 and gives back $4
+	// tokens, up to the burst
+	refundQuery = `UPDATE %s
+SET tokens = LEAST($2, tokens + GREATEST($3 - ts, 0) * $5 / 1e6 + $4), ts = GREATEST(ts, $3)
+WHERE key = $1`
+
+	lockQuery   = `SELECT pg_advisory_xact_lock(hashtext($1))`
+	selectQuery = `SELECT tokens, ts FROM %s WHERE key = $1`
+	upsertQuery = `INSERT INTO %s (key, tokens, ts) VALUES ($1, $2, $3)
+ON CONFLICT (key) DO UPDATE SET tokens = EXCLUDED.tokens, ts = EXCLUDED.ts`
+
+	cleanupQuery = `DELETE FROM %s WHERE ts < $1`
+)
+
+// TokenBucket is a token bucket per key kept in a PostgreSQL table.
+// Reservations may run into debt like the local TokenBucket, so ReserveN
+// can schedule events in the future. Times come from the callers, so
+// replicas should keep their clocks in sync
+type TokenBucket struct {
+	db    *sql.DB
+	cfg   config
+	limit rateflow.Limit
+	burst int
+}
+
+var _ rateflow.RemoteLimiter = (*TokenBucket)(nil)
+
+// NewTokenBucket creates a token bucket refilling at r tokens per second
+// up to b, for every key
+func NewTokenBucket(db *sql.DB, r rateflow.Limit, b int, opts ...Option) *TokenBucket {
+	return &TokenBucket{db: db, cfg: newConfig(opts), limit: r, burst: b}
+}
+
+// Limit returns the refill rate
+func (tb *TokenBucket) Limit() rateflow.Limit {
+	return tb.limit
+}
+
+// Burst returns the bucket size
+func (tb *TokenBucket) Burst() int {
+	return tb.burst
+}
+
+// CreateTable creates the bucket table if it does not exist
+func (tb *TokenBucket) CreateTable(ctx context.Context) error {
+	_, err := tb.db.ExecContext(ctx, fmt.Sprintf(createQuery, tb.cfg.table))
+	return err
+}
+
+// Cleanup deletes the buckets last used more than idle before t, which
+// have refilled and would be recreated full anyway if idle is at least
+// the time a bucket takes to refill. It returns how many were deleted
+func (tb *TokenBucket) Cleanup(ctx context.Context, t time.Time, idle time.Duration) (int64, error) {
+	res, err := tb.db.ExecContext(ctx, fmt.Sprintf(cleanupQuery, tb.cfg.table), t.Add(-idle).UnixMicro())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// rate returns the refill rate passed to queries
+func (tb *TokenBucket) rate() float64 {
+	if tb.limit < 0 {
+		return 0
+	}
+	return float64(tb.limit)
+}
+
+// take consumes n tokens for key at t if they are available within
+// maxWait; a negative maxWait accepts any wait. A refused take returns
+// the wait that was refused, or -1 if n can never be admitted
+func (tb *TokenBucket) take(ctx context.Context, key string, t time.Time, n int, maxWait time.Duration) (bool, time.Duration, error) {
+	if n > tb.burst {
+		return false, -1, nil
+	}
+	if tb.limit == rateflow.Inf {
+		return true, 0, nil
+	}
+
+	// debt is how far below zero the bucket may go for a wait of maxWait
+	debt := 0.0
+	switch {
+	case tb.limit <= 0:
+	case maxWait < 0:
+		debt = math.MaxFloat64
+	default:
+		debt = maxWait.Seconds() * tb.rate()
+	}
+
+	now := t.UnixMicro()
+	var ok bool
+	var left float64
+	var err error
+	if tb.cfg.advisory {
+		ok, left, err = tb.takeLocked(ctx, key, now, n, debt)
+	} else {
+		ok, left, err = tb.takeUpdate(ctx, key, now, n, debt)
+	}
+	if err != nil {
+		return false, 0, err
+	}
+
+	if left >= 0 {
+		return ok, 0, nil
+	}
+	if tb.limit <= 0 {
+		return false, -1, nil
+	}
+	return ok, time.Duration(math.Ceil(-left*1e6/tb.rate())) * time.Microsecond, nil
+}
+
+// takeUpdate runs takeQuery, creating key's row first if it is missing
+func (tb *TokenBucket) takeUpdate(ctx context.Context, key string, now int64, n int, debt float64) (ok bool, left float64, err error) {
+	query := fmt.Sprintf(takeQuery, tb.cfg.table)
+	for i := 0; i < 2; i++ {
+		err = tb.db.QueryRowContext(ctx, query, key, tb.burst, now, n, debt, tb.rate()).Scan(&ok, &left)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return ok, left, err
+		}
+		if _, err := tb.db.ExecContext(ctx, fmt.Sprintf(insertQuery, tb.cfg.table), key, tb.burst, now); err != nil {
+			return false, 0, err
+		}
+	}
+	return false, 0, err
+}
+
+// locked runs fn on key's bucket, refilled to now, in a transaction
+// holding an advisory lock on key, and stores the bucket fn returns
+func (tb *TokenBucket) locked(ctx context.Context, key string, now int64, fn func(tokens float64, found bool) (float64, bool)) (err error) {
+	tx, err := tb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, lockQuery, tb.cfg.table+":"+key); err != nil {
+		return err
+	}
+	var tokens float64
+	var ts int64
+	found := true
+	err = tx.QueryRowContext(ctx, fmt.Sprintf(selectQuery, tb.cfg.table), key).Scan(&tokens, &ts)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		tokens, ts, found = float64(tb.burst), now, false
+	case err != nil:
+		return err
+	}
+	if now > ts {
+		tokens = math.Min(float64(tb.burst), tokens+float64(now-ts)*tb.rate()/1e6)
+		ts = now
+	}
+
+	tokens, store := fn(tokens, found)
+	if !store {
+		return tx.Commit()
+	}
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf(upsertQuery, tb.cfg.table), key, tokens, ts); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// takeLocked is takeUpdate under an advisory lock
+func (tb *TokenBucket) takeLocked(ctx context.Context, key string, now int64, n int, debt float64) (ok bool, left float64, err error) {
+	err = tb.locked(ctx, key, now, func(tokens float64, _ bool) (float64, bool) {
+		left = tokens - float64(n)
+		ok = left >= -debt
+		return left, ok
+	})
+	return ok, left, err
+}
+
+// refund gives n tokens back to key's bucket, up to its burst
+func (tb *TokenBucket) refund(ctx context.Context, key string, t time.Time, n int) error {
+	now := t.UnixMicro()
+	if tb.cfg.advisory {
+		return tb.locked(ctx, key, now, func(tokens float64, found bool) (float64, bool) {
+			return math.Min(float64(tb.burst), tokens+float64(n)), found
+		})
+	}
+	_, err := tb.db.ExecContext(ctx, fmt.Sprintf(refundQuery, tb.cfg.table), key, tb.burst, now, n, tb.rate())
+	return err
+}
+
+// AllowN reports whether n events for key may happen at t
+func (tb *TokenBucket) AllowN(ctx context.Context, key string, t time.Time, n int) (bool, error) {
+	ok, _, err := tb.take(ctx, key, t, n, 0)
+	return ok, err
+}
+
+// ReserveN reserves n events for key at t, possibly in the future. It is
+// not OK only if n exceeds the burst or the rate is zero
+func (tb *TokenBucket) ReserveN(ctx context.Context, key string, t time.Time, n int) (*rateflow.RemoteReservation, error) {
+	return tb.reserve(ctx, key, t, n, -1)
+}
+
+func (tb *TokenBucket) reserve(ctx context.Context, key string, t time.Time, n int, maxWait time.Duration) (*rateflow.RemoteReservation, error) {
+	ok, wait, err := tb.take(ctx, key, t, n, maxWait)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		var retry time.Time
+		if wait >= 0 {
+			retry = t.Add(wait)
+		}
+		return rateflow.NewRemoteReservation(false, retry, n, nil), nil
+	}
+
+	timeToAct := t.Add(wait)
+	cancel := func(ctx context.Context, now time.Time) error {
+		if !now.Before(timeToAct) {
+			return nil
+		}
+		return tb.refund(ctx, key, now, n)
+	}
+	return rateflow.NewRemoteReservation(true, timeToAct, n, cancel), nil
+}
+
+// WaitN blocks until n events for key are allowed or ctx is done. It
+// fails fast, consuming nothing, when the wait would outlast ctx's
+// deadline
+func (tb *TokenBucket) WaitN(ctx context.Context, key string, n int) error {
+	maxWait := time.Duration(-1)
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = time.Until(deadline)
+		if maxWait < 0 {
+			maxWait = 0
+		}
+	}
+	now := time.Now()
+	r, err := tb.reserve(ctx, key, now, n, maxWait)
+	if err != nil {
+		return err
+	}
+	if !r.OK() {
+		if r.RetryAt().IsZero() {
+			if n > tb.burst {
+				return fmt.Errorf("%w: %d > %d", rateflow.ErrExceedsBurst, n, tb.burst)
+			}
+			return rateflow.ErrReservationNotOK
+		}
+		return &rateflow.RateLimitError{RetryAfter: r.RetryAt().Sub(now)}
+	}
+	return r.Act(ctx)
+}
@@ -0,0 +1,132 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestTokenBucket(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []Option
+	}{
+		{"update", nil},
+		{"advisory lock", []Option{WithAdvisoryLock()}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB("rateflow_buckets")
+			tb := NewTokenBucket(db, 10, 2, tt.opts...)
+			ctx := context.Background()
+			now := time.Now()
+
+			if err := tb.CreateTable(ctx); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				if ok, err := tb.AllowN(ctx, "alice", now, 1); err != nil || !ok {
+					t.Fatalf("AllowN #%d = %v, %v, want true", i, ok, err)
+				}
+			}
+			if ok, _ := tb.AllowN(ctx, "alice", now, 1); ok {
+				t.Error("expected the bucket to be empty")
+			}
+			if ok, _ := tb.AllowN(ctx, "bob", now, 1); !ok {
+				t.Error("expected keys to be limited separately")
+			}
+			if ok, _ := tb.AllowN(ctx, "alice", now.Add(100*time.Millisecond), 1); !ok {
+				t.Error("expected a token to refill after 100ms")
+			}
+			if _, ok := fake.rows["alice"]; !ok {
+				t.Error("expected a row per key")
+			}
+
+			r, err := tb.ReserveN(ctx, "alice", now.Add(100*time.Millisecond), 1)
+			if err != nil || !r.OK() {
+				t.Fatalf("ReserveN = %v, %v, want OK", r, err)
+			}
+			if d := r.DelayFrom(now.Add(100 * time.Millisecond)); d != 100*time.Millisecond {
+				t.Errorf("delay = %v, want 100ms", d)
+			}
+			if err := r.CancelAt(ctx, now.Add(100*time.Millisecond)); err != nil {
+				t.Fatal(err)
+			}
+			if ok, _ := tb.AllowN(ctx, "alice", now.Add(200*time.Millisecond), 1); !ok {
+				t.Error("expected the cancelled reservation to be refunded")
+			}
+		})
+	}
+}
+
+func TestTokenBucketQueries(t *testing.T) {
+	db, fake := newFakeDB("limits")
+	tb := NewTokenBucket(db, 1, 5, WithTable("limits"))
+	ctx := context.Background()
+	now := time.Now()
+
+	tb.AllowN(ctx, "k", now, 1)
+	if fake.count(insertQuery) != 1 || fake.count(takeQuery) != 2 {
+		t.Errorf("first use ran %d inserts and %d updates, want 1 and 2", fake.count(insertQuery), fake.count(takeQuery))
+	}
+	tb.AllowN(ctx, "k", now, 1)
+	if fake.count(takeQuery) != 3 || fake.count(insertQuery) != 1 {
+		t.Error("later decisions should be a single UPDATE")
+	}
+	if fake.count(lockQuery) != 0 {
+		t.Error("the UPDATE mode should not take advisory locks")
+	}
+
+	db, fake = newFakeDB("rateflow_buckets")
+	tb = NewTokenBucket(db, 1, 5, WithAdvisoryLock())
+	tb.AllowN(ctx, "k", now, 1)
+	if fake.count(lockQuery) != 1 || fake.count(upsertQuery) != 1 {
+		t.Error("the advisory lock mode should lock, then upsert")
+	}
+}
+
+func TestTokenBucketRefusals(t *testing.T) {
+	db, _ := newFakeDB("rateflow_buckets")
+	tb := NewTokenBucket(db, 1, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := tb.WaitN(ctx, "k", 2); !errors.Is(err, rateflow.ErrExceedsBurst) {
+		t.Errorf("WaitN = %v, want ErrExceedsBurst", err)
+	}
+	if err := tb.WaitN(ctx, "k", 1); err != nil {
+		t.Fatal(err)
+	}
+	var rle *rateflow.RateLimitError
+	if err := tb.WaitN(ctx, "k", 1); !errors.As(err, &rle) || rle.RetryAfter < 900*time.Millisecond {
+		t.Errorf("WaitN = %v, want a RateLimitError of about a second", err)
+	}
+
+	db, _ = newFakeDB("rateflow_buckets")
+	zero := NewTokenBucket(db, 0, 1)
+	now := time.Now()
+	zero.AllowN(context.Background(), "k", now, 1)
+	r, err := zero.ReserveN(context.Background(), "k", now, 1)
+	if err != nil || r.OK() || !r.RetryAt().IsZero() {
+		t.Errorf("ReserveN at a zero rate = %v, %v, want a permanent refusal", r, err)
+	}
+}
+
+func TestTokenBucketCleanup(t *testing.T) {
+	db, fake := newFakeDB("rateflow_buckets")
+	tb := NewTokenBucket(db, 1, 1)
+	ctx := context.Background()
+	now := time.Now()
+
+	tb.AllowN(ctx, "old", now.Add(-time.Hour), 1)
+	tb.AllowN(ctx, "new", now, 1)
+	n, err := tb.Cleanup(ctx, now, time.Minute)
+	if err != nil || n != 1 {
+		t.Fatalf("Cleanup = %d, %v, want 1", n, err)
+	}
+	if _, ok := fake.rows["new"]; !ok || len(fake.rows) != 1 {
+		t.Errorf("rows = %v, want only the recently used key", fake.rows)
+	}
+}
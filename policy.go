@@ -0,0 +1,197 @@
+package rateflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// PolicyAction is what a Policy does with a request a rule matches
+type PolicyAction int
+
+const (
+	// ActionLimit charges the request to the rule's limiter
+	ActionLimit PolicyAction = iota
+	// ActionAllow admits the request without charging any limiter
+	ActionAllow
+	// ActionDeny refuses the request
+	ActionDeny
+)
+
+// PolicyRule maps the requests it matches to an action. Requests are
+// described by a Descriptor of attributes, e.g. method, path, a header
+// value or the client ID
+type PolicyRule struct {
+	// Name identifies the rule in decisions
+	Name string
+	// Stage groups rules; every stage applies to a request, lowest first
+	Stage int
+	// Priority picks among the rules of a stage matching a request; the
+	// highest wins, then the one listed first
+	Priority int
+	// Match maps attributes to the values the rule applies to. An empty
+	// value accepts any value of a present attribute and a trailing "*"
+	// matches by prefix, e.g. "path": "/api/*"
+	Match map[string]string
+	// KeyBy lists the attributes whose values pick the limiter, e.g.
+	// "client" for one limiter per client. Without KeyBy every matching
+	// request shares one limiter. A request missing one does not match
+	KeyBy []string
+	// Action is what happens to matching requests
+	Action PolicyAction
+	// Spec describes the limiters of an ActionLimit rule
+	Spec LimitSpec
+}
+
+// matches reports whether r applies to d
+func (r *PolicyRule) matches(d Descriptor) bool {
+	for attr, want := range r.Match {
+		got, ok := d.Value(attr)
+		if !ok {
+			return false
+		}
+		if prefix, wildcard := strings.CutSuffix(want, "*"); wildcard {
+			if !strings.HasPrefix(got, prefix) {
+				return false
+			}
+		} else if want != "" && got != want {
+			return false
+		}
+	}
+	for _, attr := range r.KeyBy {
+		if _, ok := d.Value(attr); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Policy is a rules engine mapping request descriptors to limits, so a
+// policy can be declared as data instead of code around limiters
+type Policy struct {
+	stages [][]*policyRule // by stage, each sorted by priority
+}
+
+type policyRule struct {
+	PolicyRule
+	limiters *Keyed[Descriptor]
+}
+
+// NewPolicy creates a policy from rules. It fails with a ConfigError for
+// an ActionLimit rule whose Spec NewLimiterE would reject
+func NewPolicy(rules ...PolicyRule) (*Policy, error) {
+	byStage := make(map[int][]*policyRule)
+	for _, r := range rules {
+		pr := &policyRule{PolicyRule: r}
+		if r.Action == ActionLimit {
+			if err := limiter.Validate(r.Spec.Algorithm, r.Spec.Limit, r.Spec.Burst); err != nil {
+				return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+			}
+			spec := r.Spec
+			pr.limiters = NewKeyed(func(Descriptor) Limiter {
+				return NewLimiterWithOptions(spec.Algorithm, spec.Limit, spec.Burst, spec.Options...)
+			})
+		}
+		byStage[r.Stage] = append(byStage[r.Stage], pr)
+	}
+
+	stages := make([]int, 0, len(byStage))
+	for stage := range byStage {
+		stages = append(stages, stage)
+	}
+	sort.Ints(stages)
+
+	p := &Policy{}
+	for _, stage := range stages {
+		rs := byStage[stage]
+		sort.SliceStable(rs, func(i, j int) bool { return rs[i].Priority > rs[j].Priority })
+		p.stages = append(p.stages, rs)
+	}
+	return p, nil
+}
+
+// Rule returns the limiters of the named ActionLimit rule, keyed by the
+// KeyBy attributes, e.g. to report their usage. It returns nil for an
+// unknown name
+func (p *Policy) Rule(name string) *Keyed[Descriptor] {
+	for _, rs := range p.stages {
+		for _, r := range rs {
+			if r.Name == name {
+				return r.limiters
+			}
+		}
+	}
+	return nil
+}
+
+// resolve applies the winning rule of each stage to d. It returns the
+// limiters to charge and their rules, or the rule that decided the
+// request outright with whether it was admitted
+func (p *Policy) resolve(d Descriptor) (lims []Limiter, rules []string, decided *policyRule) {
+	for _, rs := range p.stages {
+		for _, r := range rs {
+			if !r.matches(d) {
+				continue
+			}
+			switch r.Action {
+			case ActionAllow, ActionDeny:
+				return nil, nil, r
+			}
+			var key Descriptor
+			for _, attr := range r.KeyBy {
+				v, _ := d.Value(attr)
+				key = key.With(attr, v)
+			}
+			lims = append(lims, r.limiters.Get(key))
+			rules = append(rules, r.Name)
+			break
+		}
+	}
+	return lims, rules, nil
+}
+
+// Allow is shorthand for AllowN(time.Now(), 1, d)
+func (p *Policy) Allow(d Descriptor) (bool, string) {
+	lims, rules, decided := p.resolve(d)
+	if len(lims) == 0 {
+		return p.decide(time.Now(), 1, lims, rules, decided)
+	}
+	return p.decide(limiter.NowOf(lims[0]), 1, lims, rules, decided)
+}
+
+// AllowN reports whether n events described by d may happen at t,
+// charging the limiters of every stage or none. It also returns the name
+// of the rule that denied the request, or of the ActionAllow rule that
+// admitted it; requests no rule matches are admitted
+func (p *Policy) AllowN(t time.Time, n int, d Descriptor) (bool, string) {
+	lims, rules, decided := p.resolve(d)
+	return p.decide(t, n, lims, rules, decided)
+}
+
+func (p *Policy) decide(t time.Time, n int, lims []Limiter, rules []string, decided *policyRule) (bool, string) {
+	if decided != nil {
+		return decided.Action == ActionAllow, decided.Name
+	}
+	ok, i := limiter.AllowAllIndex(t, n, lims...)
+	if ok || i < 0 {
+		return ok, ""
+	}
+	return false, rules[i]
+}
+
+// Wait blocks until the event described by d is allowed by every stage or
+// ctx is done. An ActionDeny rule fails it with ErrDenylisted
+func (p *Policy) Wait(ctx context.Context, d Descriptor) error {
+	lims, _, decided := p.resolve(d)
+	if decided != nil {
+		if decided.Action == ActionDeny {
+			return fmt.Errorf("rule %q: %w", decided.Name, ErrDenylisted)
+		}
+		return nil
+	}
+	return WaitAll(ctx, 1, lims...)
+}
@@ -0,0 +1,76 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	p, err := NewPolicy(
+		PolicyRule{Name: "blocked", Priority: 10, Match: map[string]string{"client": "mallory"}, Action: ActionDeny},
+		PolicyRule{Name: "health", Priority: 10, Match: map[string]string{"path": "/healthz"}, Action: ActionAllow},
+		PolicyRule{
+			Name:  "search-per-client",
+			Match: map[string]string{"method": "GET", "path": "/api/search*"},
+			KeyBy: []string{"client"},
+			Spec:  LimitSpec{Algorithm: TokenBucket, Limit: 1, Burst: 1},
+		},
+		PolicyRule{
+			Name:     "api-per-client",
+			Priority: -1,
+			Match:    map[string]string{"path": "/api/*"},
+			KeyBy:    []string{"client"},
+			Spec:     LimitSpec{Algorithm: TokenBucket, Limit: 1, Burst: 5},
+		},
+		PolicyRule{
+			Name:  "global",
+			Stage: 1,
+			Spec:  LimitSpec{Algorithm: TokenBucket, Limit: 1, Burst: 3},
+		},
+	)
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+
+	req := func(client, method, path string) Descriptor {
+		return Descriptor{}.With("client", client).With("method", method).With("path", path)
+	}
+
+	if ok, rule := p.Allow(req("alice", "GET", "/api/search?q=x")); !ok || rule != "" {
+		t.Fatalf("expected the first search to pass, got %v %q", ok, rule)
+	}
+	if ok, rule := p.Allow(req("alice", "GET", "/api/search?q=y")); ok || rule != "search-per-client" {
+		t.Errorf("expected the search rule to deny, got %v %q", ok, rule)
+	}
+	if ok, _ := p.Allow(req("alice", "POST", "/api/orders")); !ok {
+		t.Error("expected the general API rule to apply to other routes")
+	}
+	if ok, _ := p.Allow(req("bob", "GET", "/api/search")); !ok {
+		t.Error("expected bob to have a separate search limiter")
+	}
+	if ok, rule := p.Allow(req("carol", "GET", "/api/orders")); ok || rule != "global" {
+		t.Errorf("expected the global stage to deny, got %v %q", ok, rule)
+	}
+	if ok, rule := p.Allow(req("carol", "GET", "/healthz")); !ok || rule != "health" {
+		t.Errorf("expected the health check to bypass limits, got %v %q", ok, rule)
+	}
+	if ok, rule := p.Allow(req("mallory", "GET", "/healthz")); ok || rule != "blocked" {
+		t.Errorf("expected mallory to be denied, got %v %q", ok, rule)
+	}
+	if err := p.Wait(context.Background(), req("mallory", "GET", "/")); !errors.Is(err, ErrDenylisted) {
+		t.Errorf("expected ErrDenylisted, got %v", err)
+	}
+
+	carol := p.Rule("api-per-client").Get(Descriptor{}.With("client", "carol"))
+	if carol.Tokens() < 5 {
+		t.Errorf("expected carol's refused request to be refunded, got %v", carol.Tokens())
+	}
+}
+
+func TestPolicyInvalidSpec(t *testing.T) {
+	_, err := NewPolicy(PolicyRule{Name: "bad", Spec: LimitSpec{Algorithm: TokenBucket, Limit: 1}})
+	if !errors.Is(err, ErrInvalidBurst) {
+		t.Errorf("expected ErrInvalidBurst, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+// Package presets has limiters configured for the published limits of
+// well-known APIs, so a client can pace its outbound calls without
+// turning the provider's documentation into Limit math:
+//
+//	gh := presets.GitHub()
+//	if err := gh.Wait(ctx); err != nil {
+//		return err
+//	}
+//	resp, err := client.Do(req)
+//
+// Each preset is a MultiWindowLimiter enforcing every published window at
+// once. The rules are the defaults documented when the preset was added;
+// many providers raise them per account or plan, in which case build the
+// limiter from the preset's rules with the numbers scaled, or from
+// rateflow.NewMultiWindowLimiter directly. The windows are metered as
+// token buckets starting full, not aligned with the provider's own
+// clock, so leave some headroom where a 429 is costly.
+package presets
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// Preset is the published limits of one API
+type Preset struct {
+	// Name identifies the preset, e.g. "github"
+	Name string
+	// Rules are the limits, all enforced at once
+	Rules []rateflow.WindowRule
+	// Source is the provider's page documenting them
+	Source string
+}
+
+// New creates a limiter enforcing p's rules
+func (p Preset) New() *rateflow.MultiWindowLimiter {
+	return rateflow.NewMultiWindowLimiter(p.Rules...)
+}
+
+func rule(count int, window time.Duration) rateflow.WindowRule {
+	return rateflow.WindowRule{Count: count, Window: window}
+}
+
+const day = 24 * time.Hour
+
+var catalog = map[string]Preset{
+	"github": {
+		Name: "github",
+		// 5000 requests an hour for an authenticated user, and a secondary
+		// limit of 900 points a minute, one point per GET
+		Rules:  []rateflow.WindowRule{rule(900, time.Minute), rule(5000, time.Hour)},
+		Source: "https://docs.github.com/en/rest/using-the-rest-api/rate-limits-for-the-rest-api",
+	},
+	"github-unauthenticated": {
+		Name:   "github-unauthenticated",
+		Rules:  []rateflow.WindowRule{rule(60, time.Hour)},
+		Source: "https://docs.github.com/en/rest/using-the-rest-api/rate-limits-for-the-rest-api",
+	},
+	"github-search": {
+		Name:   "github-search",
+		Rules:  []rateflow.WindowRule{rule(30, time.Minute)},
+		Source: "https://docs.github.com/en/rest/search/search",
+	},
+	"stripe": {
+		Name:   "stripe",
+		Rules:  []rateflow.WindowRule{rule(100, time.Second)},
+		Source: "https://docs.stripe.com/rate-limits",
+	},
+	"stripe-test": {
+		Name:   "stripe-test",
+		Rules:  []rateflow.WindowRule{rule(25, time.Second)},
+		Source: "https://docs.stripe.com/rate-limits",
+	},
+	"ses": {
+		Name: "ses",
+		// The sending rate and daily quota an account typically gets when
+		// it leaves the sandbox
+		Rules:  []rateflow.WindowRule{rule(14, time.Second), rule(50000, day)},
+		Source: "https://docs.aws.amazon.com/ses/latest/dg/manage-sending-quotas.html",
+	},
+	"ses-sandbox": {
+		Name:   "ses-sandbox",
+		Rules:  []rateflow.WindowRule{rule(1, time.Second), rule(200, day)},
+		Source: "https://docs.aws.amazon.com/ses/latest/dg/request-production-access.html",
+	},
+	"shopify": {
+		Name: "shopify",
+		// A leaky bucket of 40 requests draining 2 a second
+		Rules:  []rateflow.WindowRule{rule(40, 20*time.Second)},
+		Source: "https://shopify.dev/docs/api/usage/rate-limits",
+	},
+	"shopify-plus": {
+		Name:   "shopify-plus",
+		Rules:  []rateflow.WindowRule{rule(400, 20*time.Second)},
+		Source: "https://shopify.dev/docs/api/usage/rate-limits",
+	},
+	"hubspot": {
+		Name: "hubspot",
+		// Private apps on the Free and Starter tiers
+		Rules:  []rateflow.WindowRule{rule(100, 10*time.Second), rule(250000, day)},
+		Source: "https://developers.hubspot.com/docs/api/usage-details",
+	},
+	"discord": {
+		Name:   "discord",
+		Rules:  []rateflow.WindowRule{rule(50, time.Second)},
+		Source: "https://discord.com/developers/docs/topics/rate-limits",
+	},
+	"slack-tier1": slackTier(1, 1),
+	"slack-tier2": slackTier(2, 20),
+	"slack-tier3": slackTier(3, 50),
+	"slack-tier4": slackTier(4, 100),
+}
+
+func slackTier(tier, perMinute int) Preset {
+	return Preset{
+		Name:   "slack-tier" + string(rune('0'+tier)),
+		Rules:  []rateflow.WindowRule{rule(perMinute, time.Minute)},
+		Source: "https://api.slack.com/apis/rate-limits",
+	}
+}
+
+// Lookup returns the preset named name, e.g. from a config file
+func Lookup(name string) (Preset, bool) {
+	p, ok := catalog[name]
+	if ok {
+		p.Rules = append([]rateflow.WindowRule(nil), p.Rules...)
+	}
+	return p, ok
+}
+
+// All returns every preset, sorted by name
+func All() []Preset {
+	all := make([]Preset, 0, len(catalog))
+	for name := range catalog {
+		p, _ := Lookup(name)
+		all = append(all, p)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+func build(name string) *rateflow.MultiWindowLimiter {
+	p, _ := Lookup(name)
+	return p.New()
+}
+
+// GitHub limits calls to GitHub's REST API as an authenticated user: 5000
+// an hour and 900 a minute
+func GitHub() *rateflow.MultiWindowLimiter { return build("github") }
+
+// GitHubUnauthenticated limits unauthenticated calls to GitHub's REST
+// API, 60 an hour per IP address
+func GitHubUnauthenticated() *rateflow.MultiWindowLimiter {
+	return build("github-unauthenticated")
+}
+
+// GitHubSearch limits authenticated calls to GitHub's search API, 30 a
+// minute, on top of GitHub
+func GitHubSearch() *rateflow.MultiWindowLimiter { return build("github-search") }
+
+// Stripe limits calls to Stripe's API in live mode, 100 a second
+func Stripe() *rateflow.MultiWindowLimiter { return build("stripe") }
+
+// StripeTest limits calls to Stripe's API in test mode, 25 a second
+func StripeTest() *rateflow.MultiWindowLimiter { return build("stripe-test") }
+
+// SES limits emails sent through Amazon SES: 14 a second and 50000 a day
+func SES() *rateflow.MultiWindowLimiter { return build("ses") }
+
+// SESSandbox limits emails sent through Amazon SES from the sandbox: 1 a
+// second and 200 a day
+func SESSandbox() *rateflow.MultiWindowLimiter { return build("ses-sandbox") }
+
+// Shopify limits calls to Shopify's REST Admin API on a standard plan: a
+// burst of 40 refilling 2 a second
+func Shopify() *rateflow.MultiWindowLimiter { return build("shopify") }
+
+// ShopifyPlus limits calls to Shopify's REST Admin API on Shopify Plus: a
+// burst of 400 refilling 20 a second
+func ShopifyPlus() *rateflow.MultiWindowLimiter { return build("shopify-plus") }
+
+// HubSpot limits calls to HubSpot's API from a private app on the Free or
+// Starter tier: 100 every 10 seconds and 250000 a day
+func HubSpot() *rateflow.MultiWindowLimiter { return build("hubspot") }
+
+// Discord limits calls to Discord's API from a bot, 50 a second globally.
+// Per-route buckets are announced in response headers and not covered
+func Discord() *rateflow.MultiWindowLimiter { return build("discord") }
+
+// Slack limits calls to a Slack Web API method of the given tier, 1 to 4,
+// per workspace: 1, 20, 50 or 100 a minute. It returns nil for any other
+// tier
+func Slack(tier int) *rateflow.MultiWindowLimiter {
+	if tier < 1 || tier > 4 {
+		return nil
+	}
+	return build(slackTier(tier, 0).Name)
+}
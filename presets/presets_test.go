@@ -0,0 +1,79 @@
+package presets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestGitHub(t *testing.T) {
+	gh := GitHub()
+	now := time.Now()
+	if !gh.AllowN(now, 900) {
+		t.Fatal("expected a minute's worth of calls to be admitted")
+	}
+	if gh.AllowN(now, 1) {
+		t.Error("expected the per-minute limit to refuse the 901st call")
+	}
+
+	// Once the initial burst is spent, the hourly cap is what holds a
+	// steady caller back
+	admitted := 0
+	for i := 1; i <= 120; i++ {
+		for gh.AllowN(now.Add(time.Duration(i)*time.Minute), 1) {
+			if i > 60 {
+				admitted++
+			}
+		}
+	}
+	if admitted < 4900 || admitted > 5100 {
+		t.Errorf("admitted %d calls in the second hour, want about 5000", admitted)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	all := All()
+	if len(all) != len(catalog) {
+		t.Fatalf("All returned %d presets, want %d", len(all), len(catalog))
+	}
+	for i, p := range all {
+		if i > 0 && all[i-1].Name >= p.Name {
+			t.Errorf("presets out of order: %s before %s", all[i-1].Name, p.Name)
+		}
+		if len(p.Rules) == 0 || p.Source == "" {
+			t.Errorf("%s: want rules and a source", p.Name)
+		}
+		if got, ok := Lookup(p.Name); !ok || got.Name != p.Name {
+			t.Errorf("Lookup(%q) = %v, %v", p.Name, got.Name, ok)
+		}
+	}
+
+	p, _ := Lookup("ses")
+	p.Rules[0].Count = 1
+	if again, _ := Lookup("ses"); again.Rules[0].Count != 14 {
+		t.Error("expected Lookup to return a copy of the rules")
+	}
+}
+
+func TestConstructors(t *testing.T) {
+	tests := []struct {
+		lim   *rateflow.MultiWindowLimiter
+		limit rateflow.Limit
+		burst int
+	}{
+		{Stripe(), 100, 100},
+		{SES(), 14, 14},
+		{Shopify(), 2, 40},
+		{HubSpot(), 10, 100},
+		{Slack(2), rateflow.PerMinute(20), 20},
+	}
+	for _, tt := range tests {
+		if l, b := tt.lim.Limit(), tt.lim.Burst(); l != tt.limit || b != tt.burst {
+			t.Errorf("%v: Limit, Burst = %v, %d, want %v, %d", tt.lim.Rules(), l, b, tt.limit, tt.burst)
+		}
+	}
+	if Slack(5) != nil {
+		t.Error("expected nil for an unknown Slack tier")
+	}
+}
@@ -0,0 +1,59 @@
+package rateflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPriorityLimiterReserved(t *testing.T) {
+	// Low priority may not touch the last 4 tokens, medium the last 2
+	lim := NewPriorityLimiter(Limit(1), 10, 4, 2)
+	now := time.Now()
+
+	if !lim.AllowNPriority(now, 6, 0) {
+		t.Fatal("expected low priority to take the unreserved tokens")
+	}
+	if lim.AllowNPriority(now, 1, 0) {
+		t.Error("expected low priority to be denied from the reserve")
+	}
+	if lim.AllowN(now, 1) {
+		t.Error("expected AllowN() to act as the lowest priority")
+	}
+	if !lim.AllowNPriority(now, 2, 1) {
+		t.Error("expected medium priority to use its share of the reserve")
+	}
+	if lim.AllowNPriority(now, 1, 1) {
+		t.Error("expected medium priority to be denied from the high reserve")
+	}
+	if !lim.AllowNPriority(now, 2, 2) {
+		t.Error("expected high priority to drain the bucket")
+	}
+	if lim.AllowNPriority(now, 1, 2) {
+		t.Error("expected empty bucket to deny high priority")
+	}
+}
+
+func TestPriorityLimiterWait(t *testing.T) {
+	lim := NewPriorityLimiter(Limit(100), 2, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := lim.WaitNPriority(ctx, 1, 0); err == nil {
+		t.Error("expected error when the request can never fit above the reserve")
+	}
+	if err := lim.WaitNPriority(ctx, 2, 1); err != nil {
+		t.Errorf("unexpected error from high priority wait: %v", err)
+	}
+}
+
+func TestPriorityLimiterAlgorithm(t *testing.T) {
+	lim := NewLimiter(PriorityBucket, Limit(10), 5)
+	if lim.Algorithm() != PriorityBucket {
+		t.Errorf("expected PriorityBucket, got %s", lim.Algorithm())
+	}
+	if lim.Algorithm().String() != "PriorityBucket" {
+		t.Errorf("unexpected name %q", lim.Algorithm().String())
+	}
+}
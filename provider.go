@@ -0,0 +1,131 @@
+package rateflow
+
+import "github.com/mehmet-f-dogan/rateflow/internal/limiter"
+
+// LimitSpec describes the limiter to build for a key. Each rule of a
+// provider may pick its own algorithm, e.g. a sliding window for logins
+// and a token bucket for the API, in one Keyed
+type LimitSpec struct {
+	Algorithm Algorithm
+	Limit     Limit
+	Burst     int
+
+	// Options apply after the ones given to NewKeyedProvider, e.g.
+	// WithWindow for a window algorithm. A retuned limiter picks up the
+	// rate a window implies; other options take effect for limiters
+	// created after the spec is resolved
+	Options []Option
+}
+
+// rate returns the rate a limiter built from s runs at: burst/window if
+// its options set a window, else s.Limit
+func (s LimitSpec) rate() Limit {
+	var cfg limiter.Config
+	for _, opt := range s.Options {
+		opt(&cfg)
+	}
+	if cfg.Window > 0 {
+		return Limit(float64(s.Burst) / cfg.Window.Seconds())
+	}
+	return s.Limit
+}
+
+// LimitProvider resolves the limiter settings for a key, e.g. from the
+// plan a customer is on (free=10/s, pro=100/s) stored in a database
+type LimitProvider[K comparable] interface {
+	LimitFor(key K) (LimitSpec, error)
+}
+
+// LimitProviderFunc adapts a function to a LimitProvider
+type LimitProviderFunc[K comparable] func(key K) (LimitSpec, error)
+
+// LimitFor calls f(key)
+func (f LimitProviderFunc[K]) LimitFor(key K) (LimitSpec, error) {
+	return f(key)
+}
+
+// NewKeyedProvider creates a keyed limiter whose settings for each key come
+// from p. A key is resolved once, when its limiter is created, and the
+// answer is kept with the limiter until Invalidate, ApplyConfig, Delete or
+// eviction. Keys p fails to resolve get fallback. opts apply to every
+// limiter
+func NewKeyedProvider[K comparable](p LimitProvider[K], fallback LimitSpec, opts ...Option) *Keyed[K] {
+	var k *Keyed[K]
+	resolve := func(key K) LimitSpec {
+		spec, err := (*k.provider.Load()).LimitFor(key)
+		if err != nil {
+			spec = fallback
+		}
+		spec.Options = append(opts[:len(opts):len(opts)], spec.Options...)
+		return spec
+	}
+	k = NewKeyed(func(key K) Limiter {
+		spec := resolve(key)
+		return NewLimiterWithOptions(spec.Algorithm, spec.Limit, spec.Burst, spec.Options...)
+	})
+	k.provider.Store(&p)
+	k.resolve = resolve
+	return k
+}
+
+// Invalidate makes the next use of key see its current settings, e.g.
+// after a customer upgrades. With a LimitProvider the key is resolved
+// again right away and its limiter retuned in place, keeping its state;
+// a change of algorithm, or a Keyed without a provider, drops the limiter
+// so the next use builds a new one
+func (k *Keyed[K]) Invalidate(key K) {
+	if k.resolve == nil {
+		k.Delete(key)
+		return
+	}
+
+	s := k.shard(key)
+	s.mu.RLock()
+	e, ok := s.limiters[key]
+	s.mu.RUnlock()
+	if ok {
+		k.retune(s, e)
+	}
+}
+
+// ApplyConfig swaps the provider of a Keyed created by NewKeyedProvider,
+// e.g. when new limits per tier or route are pushed. New keys use p at
+// once; existing keys are resolved again like Invalidate, so keys whose
+// algorithm is unchanged keep their usage instead of starting over
+func (k *Keyed[K]) ApplyConfig(p LimitProvider[K]) error {
+	if k.resolve == nil {
+		return ErrNoProvider
+	}
+	k.provider.Store(&p)
+
+	for _, s := range k.shards {
+		s.mu.RLock()
+		entries := make([]*keyedEntry[K], 0, len(s.limiters))
+		for _, e := range s.limiters {
+			entries = append(entries, e)
+		}
+		s.mu.RUnlock()
+
+		for _, e := range entries {
+			k.retune(s, e)
+		}
+	}
+	return nil
+}
+
+// retune applies the current settings for e's key to its limiter, or
+// drops it from s if the algorithm changed
+func (k *Keyed[K]) retune(s *keyedShard[K], e *keyedEntry[K]) {
+	spec := k.resolve(e.key)
+	if e.lim.Algorithm() != spec.Algorithm {
+		s.mu.Lock()
+		if s.limiters[e.key] == e {
+			k.remove(s, e)
+		}
+		s.mu.Unlock()
+		return
+	}
+	e.lim.SetLimit(spec.rate())
+	e.lim.SetBurst(spec.Burst)
+	e.full.Store(int64(spec.Burst))
+}
@@ -0,0 +1,160 @@
+package rateflow
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyedProvider(t *testing.T) {
+	var mu sync.Mutex
+	plans := map[string]string{"alice": "free", "bob": "pro"}
+	lookups := 0
+	p := LimitProviderFunc[string](func(user string) (LimitSpec, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		lookups++
+		switch plans[user] {
+		case "free":
+			return LimitSpec{Algorithm: TokenBucket, Limit: 10, Burst: 1}, nil
+		case "pro":
+			return LimitSpec{Algorithm: TokenBucket, Limit: 100, Burst: 5}, nil
+		}
+		return LimitSpec{}, errors.New("unknown user")
+	})
+	k := NewKeyedProvider[string](p, LimitSpec{Algorithm: TokenBucket, Limit: 1, Burst: 1})
+
+	if b := k.Get("alice").Burst(); b != 1 {
+		t.Errorf("expected the free burst, got %d", b)
+	}
+	if b := k.Get("bob").Burst(); b != 5 {
+		t.Errorf("expected the pro burst, got %d", b)
+	}
+	if l := k.Get("mallory").Limit(); l != 1 {
+		t.Errorf("expected the fallback limit, got %v", l)
+	}
+	k.Get("alice")
+	if lookups != 3 {
+		t.Errorf("expected one lookup per key, got %d", lookups)
+	}
+
+	alice := k.Get("alice")
+	mu.Lock()
+	plans["alice"] = "pro"
+	mu.Unlock()
+	k.Invalidate("alice")
+	if k.Get("alice") != alice {
+		t.Error("expected the limiter to be retuned in place")
+	}
+	if l, b := alice.Limit(), alice.Burst(); l != 100 || b != 5 {
+		t.Errorf("expected the pro settings after Invalidate, got %v/%d", l, b)
+	}
+}
+
+func TestKeyedProviderAlgorithmChange(t *testing.T) {
+	algo := TokenBucket
+	k := NewKeyedProvider[int](LimitProviderFunc[int](func(int) (LimitSpec, error) {
+		return LimitSpec{Algorithm: algo, Limit: 1, Burst: 1}, nil
+	}), LimitSpec{})
+
+	k.Get(1)
+	algo = SlidingWindow
+	k.Invalidate(1)
+	if got := k.Get(1).Algorithm(); got != SlidingWindow {
+		t.Errorf("expected a new SlidingWindow limiter, got %v", got)
+	}
+}
+
+func TestKeyedApplyConfigWindow(t *testing.T) {
+	k := NewKeyedProvider[string](PrefixRules{
+		"login/*": {Algorithm: SlidingWindow, Limit: 5, Burst: 5, Options: []Option{WithWindow(time.Minute)}},
+	}, LimitSpec{Algorithm: TokenBucket, Limit: 1, Burst: 1})
+	login := k.Get("login/alice")
+
+	err := k.ApplyConfig(PrefixRules{
+		"login/*": {Algorithm: SlidingWindow, Limit: 3, Burst: 3, Options: []Option{WithWindow(time.Minute)}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if k.Get("login/alice") != login {
+		t.Fatal("expected the limiter to be retuned in place")
+	}
+	if l, b := login.Limit(), login.Burst(); math.Abs(float64(l)-0.05) > 1e-9 || b != 3 {
+		t.Errorf("expected 3 per minute after ApplyConfig, got %v/%d", l, b)
+	}
+	if l := k.Get("login/bob").Limit(); l != login.Limit() {
+		t.Errorf("expected new keys to run at the retuned rate %v, got %v", login.Limit(), l)
+	}
+}
+
+func TestKeyedInvalidateWithoutProvider(t *testing.T) {
+	k := NewKeyedLimiter[string](TokenBucket, Limit(1), 1)
+	k.AllowKey("alice")
+	k.Invalidate("alice")
+	if !k.AllowKey("alice") {
+		t.Error("expected Invalidate to drop the limiter")
+	}
+}
+
+func TestKeyedApplyConfig(t *testing.T) {
+	k := NewKeyedProvider[string](PrefixRules{
+		"api/*":   {Algorithm: FixedWindow, Limit: 10, Burst: 10},
+		"admin/*": {Algorithm: TokenBucket, Limit: 1, Burst: 1},
+	}, LimitSpec{Algorithm: TokenBucket, Limit: 1, Burst: 1})
+
+	for i := 0; i < 4; i++ {
+		k.AllowKey("api/users")
+	}
+	admin := k.Get("admin/panel")
+
+	err := k.ApplyConfig(PrefixRules{
+		"api/*":   {Algorithm: FixedWindow, Limit: 5, Burst: 5},
+		"admin/*": {Algorithm: SlidingWindow, Limit: 1, Burst: 1},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	users := k.Get("api/users")
+	if users.Burst() != 5 {
+		t.Errorf("expected the new burst, got %d", users.Burst())
+	}
+	if s := users.Stats(); s.Allowed != 4 || users.Tokens() != 1 {
+		t.Errorf("expected usage to carry over, got %d allowed and %v left", s.Allowed, users.Tokens())
+	}
+	if got := k.Get("admin/panel"); got == admin || got.Algorithm() != SlidingWindow {
+		t.Errorf("expected a new SlidingWindow limiter for admin, got %v", got.Algorithm())
+	}
+	if b := k.Get("api/orders").Burst(); b != 5 {
+		t.Errorf("expected new keys to use the new config, got burst %d", b)
+	}
+
+	if err := NewKeyedLimiter[string](TokenBucket, 1, 1).ApplyConfig(PrefixRules{}); !errors.Is(err, ErrNoProvider) {
+		t.Errorf("expected ErrNoProvider, got %v", err)
+	}
+}
+
+func TestKeyedProviderSpecOptions(t *testing.T) {
+	k := NewKeyedProvider[string](PrefixRules{
+		"login:*": {Algorithm: SlidingWindow, Limit: 3, Burst: 3, Options: []Option{WithWindow(time.Minute)}},
+	}, LimitSpec{Algorithm: TokenBucket, Limit: 1, Burst: 1}, WithName("keyed"))
+
+	sw, ok := k.Get("login:alice").(interface {
+		Limiter
+		Window() time.Duration
+		Name() string
+	})
+	if !ok || sw.Algorithm() != SlidingWindow {
+		t.Fatalf("expected a sliding window, got %T", k.Get("login:alice"))
+	}
+	if sw.Window() != time.Minute || sw.Name() != "keyed" {
+		t.Errorf("expected both option sets to apply, got window %v and name %q", sw.Window(), sw.Name())
+	}
+	if _, ok := k.Get("other").(*TokenBucketLimiter); !ok {
+		t.Errorf("expected the fallback token bucket, got %T", k.Get("other"))
+	}
+}
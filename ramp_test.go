@@ -0,0 +1,71 @@
+package rateflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRampLimit(t *testing.T) {
+	clock := newFakeClock()
+	lim := NewLimiterWithOptions(TokenBucket, Limit(10), 10, WithClock(clock))
+
+	lim.RampLimit(Limit(30), 20*time.Second)
+	for i := 0; i < 10; i++ {
+		clock.blockUntil(1)
+		clock.Advance(time.Second)
+	}
+	clock.blockUntil(1)
+	if got := lim.Limit(); got != 20 {
+		t.Errorf("expected limit 20 halfway through the ramp, got %v", got)
+	}
+
+	for i := 0; i < 10; i++ {
+		clock.blockUntil(1)
+		clock.Advance(time.Second)
+	}
+	eventually(func() bool { return lim.Limit() == 30 })
+	if got := lim.Limit(); got != 30 {
+		t.Errorf("expected limit 30 at the end of the ramp, got %v", got)
+	}
+}
+
+func TestRampLimitTakeover(t *testing.T) {
+	clock := newFakeClock()
+	lim := NewLimiterWithOptions(TokenBucket, Limit(10), 10, WithClock(clock))
+
+	lim.RampLimit(Limit(30), 20*time.Second)
+	clock.blockUntil(1)
+	lim.SetLimit(Limit(5))
+	clock.Advance(time.Second)
+	time.Sleep(10 * time.Millisecond)
+	if got := lim.Limit(); got != 5 {
+		t.Errorf("expected SetLimit to stop the ramp, got %v", got)
+	}
+
+	lim.RampLimit(Limit(7), 0)
+	if got := lim.Limit(); got != 7 {
+		t.Errorf("expected a zero-length ramp to apply at once, got %v", got)
+	}
+}
+
+func TestRampLimitAdaptive(t *testing.T) {
+	clock := newFakeClock()
+	lim := NewLimiterWithOptions(Adaptive, Limit(10), 10, WithClock(clock)).(*AdaptiveLimiter)
+
+	lim.RampLimit(Limit(20), 2*time.Second)
+	for i := 0; i < 2; i++ {
+		clock.blockUntil(1)
+		clock.Advance(time.Second)
+	}
+	eventually(func() bool { _, max := lim.Bounds(); return max == 20 })
+	if _, max := lim.Bounds(); max != 20 {
+		t.Errorf("expected the ceiling to ramp to 20, got %v", max)
+	}
+}
+
+// eventually polls cond for up to a second, for state set by a goroutine
+func eventually(cond func() bool) {
+	for i := 0; i < 1000 && !cond(); i++ {
+		time.Sleep(time.Millisecond)
+	}
+}
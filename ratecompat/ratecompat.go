@@ -0,0 +1,256 @@
+// Package ratecompat is a drop-in replacement for golang.org/x/time/rate
+// backed by any rateflow algorithm. Limiter has the method set of
+// *rate.Limiter and Limit, Inf, Every and Reservation match their
+// namesakes, so existing code switches by changing one import:
+//
+//	import rate "github.com/mehmet-f-dogan/rateflow/ratecompat"
+//
+//	lim := rate.NewLimiter(rate.Every(time.Second), 5)
+//
+// NewLimiter builds a token bucket, as x/time/rate does; NewLimiterWith
+// and Wrap put any other rateflow limiter behind the same methods.
+//
+// For go.uber.org/ratelimit, NewTaker and FromTaker convert between its
+// Take-style limiter and rateflow's.
+package ratecompat
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// Limit is a rate of events per second
+type Limit = rateflow.Limit
+
+// Inf is the infinite rate limit; it allows all events
+const Inf = rateflow.Inf
+
+// Every converts a minimum time interval between events to a Limit
+func Every(interval time.Duration) Limit {
+	return rateflow.Every(interval)
+}
+
+// Reservation holds information about events permitted by a Limiter
+// that may happen after a delay
+type Reservation = rateflow.Reservation
+
+// Limiter controls how frequently events are allowed to happen. Like
+// rate.Limiter, the zero value is valid and rejects every event until
+// SetLimit and SetBurst configure it, and a nil *Limiter behaves the same
+// instead of panicking
+type Limiter struct {
+	mu    sync.Mutex
+	lim   rateflow.Limiter
+	fresh bool // lim was created by init and has not decided anything
+}
+
+// NewLimiter returns a token bucket Limiter that allows events up to
+// rate r and permits bursts of at most b tokens. As with rate.NewLimiter,
+// Inf allows every event whatever b is, and a zero r allows b events in
+// all instead of pausing the limiter the way rateflow does
+func NewLimiter(r Limit, b int) *Limiter {
+	return Wrap(rateflow.NewLimiter(rateflow.TokenBucket, r, b))
+}
+
+// NewLimiterWith returns a Limiter on any rateflow algorithm
+func NewLimiterWith(algo rateflow.Algorithm, r Limit, b int, opts ...rateflow.Option) *Limiter {
+	return Wrap(rateflow.NewLimiterWithOptions(algo, r, b, opts...))
+}
+
+// Wrap returns a Limiter deciding with lim. While lim's limit is Inf or
+// zero, l decides as rate.Limiter does rather than as lim would
+func Wrap(lim rateflow.Limiter) *Limiter {
+	return &Limiter{lim: lim}
+}
+
+// Unwrap returns the rateflow limiter behind l, nil for a zero Limiter
+// never configured
+func (l *Limiter) Unwrap() rateflow.Limiter {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lim
+}
+
+// use returns the limiter behind l for a decision
+func (l *Limiter) use() rateflow.Limiter {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fresh = false
+	return l.lim
+}
+
+// decide settles a decision for the limits x/time/rate treats apart:
+// Inf admits any n, and a zero limit spends the burst itself, which never
+// refills. done is false when lim is nil or should decide
+func (l *Limiter) decide(t time.Time, n int) (ok, done bool) {
+	if l == nil {
+		return false, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.lim == nil {
+		return false, false
+	}
+	switch l.lim.Limit() {
+	case Inf:
+		l.fresh = false
+		return true, true
+	case 0:
+		l.fresh = false
+		burst := l.lim.Burst()
+		if n > burst {
+			return false, true
+		}
+		l.lim.SetBurstAt(t, burst-n)
+		return true, true
+	}
+	return false, false
+}
+
+// init returns the limiter behind l, creating a paused token bucket for
+// a zero Limiter being configured, and whether it has decided nothing
+// yet
+func (l *Limiter) init() (rateflow.Limiter, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.lim == nil {
+		l.lim, l.fresh = rateflow.NewLimiter(rateflow.TokenBucket, 0, 0), true
+	}
+	return l.lim, l.fresh
+}
+
+// Limit returns the maximum overall event rate
+func (l *Limiter) Limit() Limit {
+	if lim := l.Unwrap(); lim != nil {
+		return lim.Limit()
+	}
+	return 0
+}
+
+// Burst returns the maximum burst size
+func (l *Limiter) Burst() int {
+	if lim := l.Unwrap(); lim != nil {
+		return lim.Burst()
+	}
+	return 0
+}
+
+// Tokens returns the token count now
+func (l *Limiter) Tokens() float64 {
+	return l.TokensAt(time.Now())
+}
+
+// TokensAt returns the token count at time t
+func (l *Limiter) TokensAt(t time.Time) float64 {
+	if lim := l.Unwrap(); lim != nil {
+		return lim.TokensAt(t)
+	}
+	return 0
+}
+
+// Allow reports whether an event may happen now
+func (l *Limiter) Allow() bool {
+	return l.AllowN(time.Now(), 1)
+}
+
+// AllowN reports whether n events may happen at time t
+func (l *Limiter) AllowN(t time.Time, n int) bool {
+	if ok, done := l.decide(t, n); done {
+		return ok
+	}
+	if lim := l.use(); lim != nil {
+		return lim.AllowN(t, n)
+	}
+	return n <= 0
+}
+
+// Reserve is shorthand for ReserveN(time.Now(), 1)
+func (l *Limiter) Reserve() *Reservation {
+	return l.ReserveN(time.Now(), 1)
+}
+
+// ReserveN returns a Reservation that indicates how long the caller must
+// wait before n events happen
+func (l *Limiter) ReserveN(t time.Time, n int) *Reservation {
+	if ok, done := l.decide(t, n); done {
+		if !ok {
+			return limiter.NotOK()
+		}
+		return limiter.Granted(t)
+	}
+	if lim := l.use(); lim != nil {
+		return lim.ReserveN(t, n)
+	}
+	return limiter.NotOK()
+}
+
+// Wait is shorthand for WaitN(ctx, 1)
+func (l *Limiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+// WaitN blocks until lim permits n events to happen. It returns an error
+// if n exceeds the Limiter's burst size, the Context is canceled, or the
+// expected wait time exceeds the Context's Deadline
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	if lim := l.Unwrap(); lim != nil && ctx.Err() == nil {
+		burst := lim.Burst()
+		if ok, done := l.decide(time.Now(), n); done {
+			if !ok {
+				return fmt.Errorf("rate: Wait(n=%d) exceeds limiter's burst %d", n, burst)
+			}
+			return nil
+		}
+	}
+	if lim := l.use(); lim != nil {
+		return lim.WaitN(ctx, n)
+	}
+	if n <= 0 {
+		return nil
+	}
+	return fmt.Errorf("rate: Wait(n=%d) exceeds limiter's burst 0", n)
+}
+
+// SetLimit is shorthand for SetLimitAt(time.Now(), newLimit)
+func (l *Limiter) SetLimit(newLimit Limit) {
+	l.SetLimitAt(time.Now(), newLimit)
+}
+
+// SetLimitAt sets a new Limit for the limiter. It does nothing on a nil
+// *Limiter
+func (l *Limiter) SetLimitAt(t time.Time, newLimit Limit) {
+	if l != nil {
+		lim, _ := l.init()
+		lim.SetLimitAt(t, newLimit)
+	}
+}
+
+// SetBurst is shorthand for SetBurstAt(time.Now(), newBurst)
+func (l *Limiter) SetBurst(newBurst int) {
+	l.SetBurstAt(time.Now(), newBurst)
+}
+
+// SetBurstAt sets a new burst size for the limiter. A zero Limiter that
+// has not decided anything yet starts full, as rate.Limiter's does. It
+// does nothing on a nil *Limiter
+func (l *Limiter) SetBurstAt(t time.Time, newBurst int) {
+	if l == nil {
+		return
+	}
+	lim, fresh := l.init()
+	lim.SetBurstAt(t, newBurst)
+	if fresh {
+		lim.ResetTo(t)
+	}
+}
@@ -0,0 +1,130 @@
+package ratecompat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// limiter is the method set of *rate.Limiter
+type rateLimiter interface {
+	Allow() bool
+	AllowN(t time.Time, n int) bool
+	Burst() int
+	Limit() Limit
+	Reserve() *Reservation
+	ReserveN(t time.Time, n int) *Reservation
+	SetBurst(newBurst int)
+	SetBurstAt(t time.Time, newBurst int)
+	SetLimit(newLimit Limit)
+	SetLimitAt(t time.Time, newLimit Limit)
+	Tokens() float64
+	TokensAt(t time.Time) float64
+	Wait(ctx context.Context) error
+	WaitN(ctx context.Context, n int) error
+}
+
+var _ rateLimiter = (*Limiter)(nil)
+
+// reservation is the method set of *rate.Reservation
+type rateReservation interface {
+	Cancel()
+	CancelAt(t time.Time)
+	Delay() time.Duration
+	DelayFrom(t time.Time) time.Duration
+	OK() bool
+}
+
+var _ rateReservation = (*Reservation)(nil)
+
+func TestLimiter(t *testing.T) {
+	lim := NewLimiter(Every(100*time.Millisecond), 2)
+	now := time.Now()
+	if !lim.AllowN(now, 2) || lim.AllowN(now, 1) {
+		t.Fatal("expected a burst of 2")
+	}
+	r := lim.ReserveN(now, 1)
+	if d := r.DelayFrom(now); !r.OK() || d < 100*time.Millisecond || d > 100*time.Millisecond+time.Microsecond {
+		t.Errorf("DelayFrom = %v, want 100ms", r.DelayFrom(now))
+	}
+	r.CancelAt(now)
+	if got := lim.TokensAt(now); got != 0 {
+		t.Errorf("TokensAt = %v, want the cancelled token back", got)
+	}
+
+	w := NewLimiterWith(rateflow.FixedWindow, 1, 1)
+	if w.Unwrap().Algorithm() != rateflow.FixedWindow || !w.Allow() || w.Allow() {
+		t.Error("expected a fixed window of one event")
+	}
+}
+
+func TestZeroLimiter(t *testing.T) {
+	var nilLim *Limiter
+	for name, lim := range map[string]*Limiter{"nil": nilLim, "zero": {}} {
+		if lim.Allow() || lim.Reserve().OK() || lim.Limit() != 0 || lim.Burst() != 0 {
+			t.Errorf("%s: expected every event rejected", name)
+		}
+		if lim.Wait(context.Background()) == nil {
+			t.Errorf("%s: expected Wait to fail", name)
+		}
+		lim.SetLimit(Inf)
+	}
+
+	var lim Limiter
+	lim.SetLimit(10)
+	lim.SetBurst(1)
+	if !lim.Allow() {
+		t.Error("expected a configured zero Limiter to allow")
+	}
+}
+
+func TestInfLimit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	for _, b := range []int{0, 1} {
+		lim := NewLimiter(Inf, b)
+		if !lim.Allow() || !lim.AllowN(now, 5) {
+			t.Errorf("burst %d: Inf should allow any n", b)
+		}
+		if r := lim.ReserveN(now, 5); !r.OK() || r.DelayFrom(now) != 0 {
+			t.Errorf("burst %d: ReserveN = %v, %v, want OK at once", b, r.OK(), r.DelayFrom(now))
+		}
+		if err := lim.WaitN(ctx, 5); err != nil {
+			t.Errorf("burst %d: WaitN = %v, want nil", b, err)
+		}
+	}
+
+	// Wrap follows x/time/rate whichever algorithm decides the rest
+	w := Wrap(rateflow.NewLimiter(rateflow.SlidingWindow, Inf, 0))
+	if !w.AllowN(now, 3) {
+		t.Error("a wrapped Inf limiter should allow any n")
+	}
+}
+
+func TestZeroLimit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	lim := NewLimiter(0, 3)
+	if !lim.AllowN(now, 2) {
+		t.Fatal("a zero limit should allow its initial burst")
+	}
+	if r := lim.ReserveN(now, 1); !r.OK() || r.DelayFrom(now) != 0 {
+		t.Errorf("ReserveN = %v, want the last token at once", r.OK())
+	}
+	if lim.Allow() || lim.ReserveN(now.Add(time.Hour), 1).OK() {
+		t.Error("a zero limit should never refill")
+	}
+	if err := lim.Wait(ctx); err == nil {
+		t.Error("Wait should fail once the burst is spent")
+	}
+
+	lim = NewLimiter(0, 1)
+	if err := lim.Wait(ctx); err != nil {
+		t.Errorf("Wait = %v, want the burst", err)
+	}
+	if lim.Burst() != 0 {
+		t.Errorf("Burst = %d, want it spent as rate.Limiter does", lim.Burst())
+	}
+}
@@ -0,0 +1,62 @@
+package ratecompat
+
+import (
+	"context"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// Taker is the interface of go.uber.org/ratelimit: Take blocks until the
+// next event may happen and returns when it was let through
+type Taker interface {
+	Take() time.Time
+}
+
+type taker struct {
+	lim rateflow.Limiter
+}
+
+// NewTaker returns a Taker waiting on lim, for code built around
+// go.uber.org/ratelimit
+func NewTaker(lim rateflow.Limiter) Taker {
+	return taker{lim}
+}
+
+// Take waits on the limiter for one event. A limiter that can never admit
+// it, e.g. with a burst of 0, returns at once, since Take cannot fail
+func (t taker) Take() time.Time {
+	t.lim.Wait(context.Background())
+	return time.Now()
+}
+
+// takerLimiter paces Wait through a Taker and answers everything else
+// from a token bucket at the same rate
+type takerLimiter struct {
+	rateflow.Limiter
+	taker Taker
+}
+
+// FromTaker wraps t, a limiter taking r events per second such as one
+// from ratelimit.New, as a rateflow.Limiter. Wait and WaitN call Take and
+// book each event on a token bucket of rate r and burst 1, which answers
+// the methods a Taker has no equivalent for, such as Allow and Reserve.
+// Take cannot be interrupted, so WaitN only checks ctx between events
+func FromTaker(t Taker, r Limit) rateflow.Limiter {
+	return &takerLimiter{Limiter: rateflow.NewLimiter(rateflow.TokenBucket, r, 1), taker: t}
+}
+
+func (l *takerLimiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+func (l *takerLimiter) WaitN(ctx context.Context, n int) error {
+	for i := 0; i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		at := l.taker.Take()
+		l.Limiter.ReserveN(at, 1)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package ratecompat
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestTaker(t *testing.T) {
+	tk := NewTaker(rateflow.NewLimiter(rateflow.TokenBucket, 1000, 1))
+	first := tk.Take()
+	last := first
+	for i := 0; i < 5; i++ {
+		last = tk.Take()
+	}
+	if gap := last.Sub(first); gap < 4*time.Millisecond {
+		t.Errorf("5 takes after the first in %v, want them paced 1ms apart", gap)
+	}
+}
+
+// countingTaker is a Taker that never blocks and counts calls
+type countingTaker struct {
+	mu    sync.Mutex
+	takes int
+}
+
+func (c *countingTaker) Take() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.takes++
+	return time.Now()
+}
+
+func TestFromTaker(t *testing.T) {
+	tk := &countingTaker{}
+	lim := FromTaker(tk, rateflow.Every(time.Hour))
+	if err := lim.WaitN(context.Background(), 3); err != nil {
+		t.Fatal(err)
+	}
+	if tk.takes != 3 {
+		t.Errorf("Take called %d times, want 3", tk.takes)
+	}
+	if lim.Allow() {
+		t.Error("expected the events taken to be booked against Allow")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := lim.Wait(ctx); err != context.Canceled || tk.takes != 3 {
+		t.Errorf("Wait = %v after %d takes, want it to stop before taking", err, tk.takes)
+	}
+}
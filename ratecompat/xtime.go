@@ -0,0 +1,80 @@
+package ratecompat
+
+import (
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// XReservation is the part of *rate.Reservation from golang.org/x/time/rate
+// that an XTimeRate limiter uses
+type XReservation interface {
+	OK() bool
+	DelayFrom(t time.Time) time.Duration
+	CancelAt(t time.Time)
+}
+
+// XLimiter is the part of *rate.Limiter that an XTimeRate limiter uses,
+// with F its rate.Limit and R its *rate.Reservation
+type XLimiter[F ~float64, R XReservation] interface {
+	AllowN(t time.Time, n int) bool
+	ReserveN(t time.Time, n int) R
+	Limit() F
+	SetLimitAt(t time.Time, newLimit F)
+	Burst() int
+	SetBurstAt(t time.Time, newBurst int)
+	TokensAt(t time.Time) float64
+}
+
+type xBackend[F ~float64, R XReservation, L XLimiter[F, R]] struct {
+	x L
+}
+
+func (b xBackend[F, R, L]) AllowN(t time.Time, n int) bool {
+	return b.x.AllowN(t, n)
+}
+
+func (b xBackend[F, R, L]) ReserveN(t time.Time, n int) (bool, time.Time, func(time.Time)) {
+	r := b.x.ReserveN(t, n)
+	if !r.OK() {
+		return false, time.Time{}, nil
+	}
+	return true, t.Add(r.DelayFrom(t)), r.CancelAt
+}
+
+func (b xBackend[F, R, L]) Limit() Limit {
+	return Limit(b.x.Limit())
+}
+
+func (b xBackend[F, R, L]) SetLimitAt(t time.Time, newLimit Limit) {
+	b.x.SetLimitAt(t, F(newLimit))
+}
+
+func (b xBackend[F, R, L]) Burst() int {
+	return b.x.Burst()
+}
+
+func (b xBackend[F, R, L]) SetBurstAt(t time.Time, newBurst int) {
+	b.x.SetBurstAt(t, newBurst)
+}
+
+func (b xBackend[F, R, L]) TokensAt(t time.Time) float64 {
+	return b.x.TokensAt(t)
+}
+
+// RegisterXTimeRate registers golang.org/x/time/rate as the "XTimeRate"
+// algorithm, so the real *rate.Limiter can be compared against rateflow's
+// algorithms by changing only the Algorithm passed to NewLimiter. rateflow
+// does not depend on x/time, so the caller passes its constructor:
+//
+//	var XTimeRate = ratecompat.RegisterXTimeRate[rate.Limit, *rate.Reservation](rate.NewLimiter)
+//
+//	lim := rateflow.NewLimiter(XTimeRate, 10, 20)
+//
+// Every decision is made by the *rate.Limiter; Reset replaces it with a
+// new one. Like RegisterAlgorithm it panics if called twice
+func RegisterXTimeRate[F ~float64, R XReservation, L XLimiter[F, R]](newLimiter func(F, int) L) rateflow.Algorithm {
+	return rateflow.RegisterBackend("XTimeRate", func(r Limit, b int) rateflow.Backend {
+		return xBackend[F, R, L]{newLimiter(F(r), b)}
+	})
+}
@@ -0,0 +1,172 @@
+package ratecompat
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// xLimit, xLimiter and xReservation mimic golang.org/x/time/rate's types
+// closely enough to stand in for them
+type xLimit float64
+
+type xLimiter struct {
+	mu     sync.Mutex
+	limit  xLimit
+	burst  int
+	tokens float64
+	last   time.Time
+	calls  int
+}
+
+func newXLimiter(r xLimit, b int) *xLimiter {
+	return &xLimiter{limit: r, burst: b, tokens: float64(b)}
+}
+
+type xReservation struct {
+	ok        bool
+	lim       *xLimiter
+	tokens    int
+	timeToAct time.Time
+}
+
+func (r *xReservation) OK() bool { return r.ok }
+
+func (r *xReservation) DelayFrom(t time.Time) time.Duration {
+	if !r.ok {
+		return math.MaxInt64
+	}
+	if d := r.timeToAct.Sub(t); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func (r *xReservation) CancelAt(t time.Time) {
+	if !r.ok || r.tokens == 0 || r.timeToAct.Before(t) {
+		return
+	}
+	r.lim.mu.Lock()
+	defer r.lim.mu.Unlock()
+	r.lim.advance(t)
+	r.lim.tokens = math.Min(r.lim.tokens+float64(r.tokens), float64(r.lim.burst))
+	r.tokens = 0
+}
+
+func (l *xLimiter) advance(t time.Time) {
+	if !l.last.IsZero() && t.After(l.last) {
+		l.tokens = math.Min(float64(l.burst), l.tokens+t.Sub(l.last).Seconds()*float64(l.limit))
+	}
+	if t.After(l.last) {
+		l.last = t
+	}
+}
+
+func (l *xLimiter) AllowN(t time.Time, n int) bool {
+	return l.ReserveN(t, n).DelayFrom(t) == 0
+}
+
+func (l *xLimiter) ReserveN(t time.Time, n int) *xReservation {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls++
+	l.advance(t)
+	if n > l.burst {
+		return &xReservation{}
+	}
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / float64(l.limit) * float64(time.Second))
+	}
+	return &xReservation{ok: true, lim: l, tokens: n, timeToAct: t.Add(wait)}
+}
+
+func (l *xLimiter) Limit() xLimit { return l.limit }
+
+func (l *xLimiter) SetLimitAt(t time.Time, r xLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(t)
+	l.limit = r
+}
+
+func (l *xLimiter) Burst() int { return l.burst }
+
+func (l *xLimiter) SetBurstAt(t time.Time, b int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(t)
+	l.burst = b
+}
+
+func (l *xLimiter) TokensAt(t time.Time) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(t)
+	return l.tokens
+}
+
+var XTimeRate = RegisterXTimeRate[xLimit, *xReservation](newXLimiter)
+
+func TestXTimeRate(t *testing.T) {
+	if algo, err := rateflow.ParseAlgorithm("xtime-rate"); err != nil || algo != XTimeRate {
+		t.Fatalf("ParseAlgorithm = %v, %v, want XTimeRate", algo, err)
+	}
+
+	lim := rateflow.NewLimiter(XTimeRate, rateflow.Every(time.Hour), 2)
+	if lim.Algorithm() != XTimeRate {
+		t.Errorf("Algorithm = %v, want XTimeRate", lim.Algorithm())
+	}
+	backend := lim.(*rateflow.ExternalLimiter).Backend().(xBackend[xLimit, *xReservation, *xLimiter]).x
+
+	now := time.Now()
+	if !lim.AllowN(now, 1) || !lim.AllowN(now, 1) {
+		t.Fatal("expected the burst of 2 to be admitted")
+	}
+	ok, res := lim.AllowDetailsAt(now, 1)
+	if ok || res.RetryAfter < 59*time.Minute {
+		t.Errorf("AllowDetailsAt = %v, %+v, want a denial for about an hour", ok, res)
+	}
+	if backend.calls != 3 {
+		t.Errorf("backend saw %d calls, want every decision made by it", backend.calls)
+	}
+	if s := lim.Stats(); s.Allowed != 2 || s.Denied != 1 {
+		t.Errorf("Stats = %+v, want 2 allowed and 1 denied", s)
+	}
+
+	lim.Reset()
+	r := lim.ReserveN(now, 2)
+	if !r.OK() || r.DelayFrom(now) != 0 {
+		t.Fatalf("ReserveN after Reset = %v, %v, want a full burst", r.OK(), r.DelayFrom(now))
+	}
+	r.CancelAt(now)
+	if got := lim.TokensAt(now); got != 2 {
+		t.Errorf("TokensAt after cancel = %v, want the reservation refunded", got)
+	}
+}
+
+func TestXTimeRateWait(t *testing.T) {
+	lim := rateflow.NewLimiter(XTimeRate, rateflow.Every(time.Hour), 1)
+	if err := lim.WaitN(context.Background(), 2); !errors.Is(err, rateflow.ErrExceedsBurst) {
+		t.Errorf("WaitN over the burst = %v, want ErrExceedsBurst", err)
+	}
+	if err := lim.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var rle *rateflow.RateLimitError
+	if err := lim.Wait(ctx); !errors.As(err, &rle) {
+		t.Errorf("Wait past the deadline = %v, want a RateLimitError", err)
+	}
+	if got := lim.Tokens(); got < -0.01 {
+		t.Errorf("Tokens = %v, want the refused wait refunded", got)
+	}
+}
@@ -1,6 +1,7 @@
 package rateflow
 
 import (
+	"context"
 	"math"
 	"time"
 
@@ -44,29 +45,298 @@ const (
 	LeakyBucket   Algorithm = limiter.LeakyBucket
 	SlidingWindow Algorithm = limiter.SlidingWindow
 	FixedWindow   Algorithm = limiter.FixedWindow
+
+	PriorityBucket Algorithm = limiter.PriorityBucket
+	MultiWindow    Algorithm = limiter.MultiWindow
+	EWMA           Algorithm = limiter.EWMA
+	Adaptive       Algorithm = limiter.Adaptive
+	CalendarQuota  Algorithm = limiter.CalendarQuota
+	DualRate       Algorithm = limiter.DualRate
 )
 
 // Capabilities describes what features an algorithm supports
 type Capabilities = limiter.Capabilities
 
-// Limiter is the main interface compatible with golang.org/x/time/rate
+// Limiter is the main interface compatible with golang.org/x/time/rate.
+// Every built-in limiter also implements json.Marshaler, json.Unmarshaler,
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler for its state, so
+// it can be saved on shutdown and restored into a limiter constructed with
+// the same parameters on restart
 type Limiter = limiter.Limiter
 
 // Reservation holds information about a reserved rate limit event
 type Reservation = limiter.Reservation
 
+// Stats is a snapshot of a limiter's activity, returned by Limiter.Stats
+type Stats = limiter.Stats
+
+// DenialReason is why a limiter denied a call, reported in Event.Reason
+type DenialReason = limiter.DenialReason
+
+const (
+	NotDenied      = limiter.NotDenied
+	DeniedLimited  = limiter.DeniedLimited
+	DeniedExceeds  = limiter.DeniedExceeds
+	DeniedCanceled = limiter.DeniedCanceled
+	DeniedStore    = limiter.DeniedStore
+	DeniedShed     = limiter.DeniedShed
+)
+
+// DenialStats counts denied calls by reason, in Stats.Denials
+type DenialStats = limiter.DenialStats
+
+// WaitHistogram counts how long Waits blocked in fixed buckets, in
+// Stats.Waits
+type WaitHistogram = limiter.WaitHistogram
+
+// Result describes a limiter's state after an AllowDetails call, with
+// everything needed for RateLimit-* and Retry-After headers
+type Result = limiter.Result
+
+// InfDuration is the RetryAfter of a request that can never be admitted,
+// e.g. one larger than the burst
+const InfDuration = limiter.InfDuration
+
 // NewLimiter creates a new rate limiter with the specified algorithm
 func NewLimiter(algo Algorithm, r Limit, b int) Limiter {
+	return limiter.New(algo, r, b)
+}
+
+// CloneWithState returns lim.Clone() with lim's current tokens, counters
+// and timestamps copied over, e.g. to fork a limiter for a new tenant
+// without granting it a fresh burst
+func CloneWithState(lim Limiter) (Limiter, error) {
+	return limiter.CloneWithState(lim)
+}
+
+// WaitTimed is like lim.WaitN but also returns how long the caller was
+// held, for recording queueing delay next to request latency. The
+// duration is reported on failure too, e.g. the time spent before ctx
+// was cancelled
+func WaitTimed(ctx context.Context, lim Limiter, n int) (time.Duration, error) {
+	return limiter.WaitTimed(ctx, lim, n)
+}
+
+// ReserveAll reserves n events at t on every limiter at once, e.g. a
+// per-user limiter and a service-wide one. The reservation acts when the
+// slowest limiter allows and cancelling it refunds them all; if any
+// limiter refuses, none is charged and the reservation is not OK
+func ReserveAll(t time.Time, n int, lims ...Limiter) *Reservation {
+	return limiter.ReserveAll(t, n, lims...)
+}
+
+// AllowAll reports whether n events may happen at t on every limiter,
+// charging all of them or none
+func AllowAll(t time.Time, n int, lims ...Limiter) bool {
+	return limiter.AllowAll(t, n, lims...)
+}
+
+// WaitAll blocks until n events are allowed on every limiter or ctx is
+// done, charging all of them or none
+func WaitAll(ctx context.Context, n int, lims ...Limiter) error {
+	return limiter.WaitAll(ctx, n, lims...)
+}
+
+// NewWindowLimiter creates a limiter allowing maxCount events per window,
+// e.g. NewWindowLimiter(SlidingWindow, 100, 10*time.Second) for "100
+// requests per 10 seconds". SlidingWindow and FixedWindow use the window
+// directly; other algorithms get a rate of maxCount/window and a burst of
+// maxCount
+func NewWindowLimiter(algo Algorithm, maxCount int, window time.Duration) Limiter {
 	switch algo {
-	case TokenBucket:
-		return limiter.NewTokenBucket(r, b)
-	case LeakyBucket:
-		return limiter.NewLeakyBucket(r, b)
 	case SlidingWindow:
-		return limiter.NewSlidingWindow(r, b)
+		return limiter.NewSlidingWindowDuration(maxCount, window)
 	case FixedWindow:
-		return limiter.NewFixedWindow(r, b)
+		return limiter.NewFixedWindowDuration(maxCount, window)
+	case MultiWindow:
+		return limiter.NewMultiWindow(WindowRule{Count: maxCount, Window: window})
 	default:
-		return limiter.NewTokenBucket(r, b)
+		return NewLimiter(algo, Limit(float64(maxCount)/window.Seconds()), maxCount)
 	}
 }
+
+// RegisterAlgorithm makes a custom algorithm available to NewLimiter under
+// the returned Algorithm value. It is meant to be called from an init
+// function and panics if name is empty or already registered
+func RegisterAlgorithm(name string, factory func(Limit, int) Limiter) Algorithm {
+	return limiter.Register(name, factory)
+}
+
+// Backend is a limiter from another library that RegisterBackend turns
+// into an algorithm. The backend makes every decision; rateflow adds the
+// clock, Stats, listeners, ramps and the rest of Limiter around it
+type Backend = limiter.Backend
+
+// ExternalLimiter is the Limiter built for a registered Backend
+type ExternalLimiter = limiter.ExternalLimiter
+
+// RegisterBackend is RegisterAlgorithm for a Backend: NewLimiter(algo, r,
+// b) builds factory(r, b) and wraps it. Reset replaces the backend with a
+// fresh one from factory
+func RegisterBackend(name string, factory func(Limit, int) Backend) Algorithm {
+	var algo Algorithm
+	algo = limiter.Register(name, func(r Limit, b int) Limiter {
+		return limiter.NewExternal(algo, r, b, factory)
+	})
+	return algo
+}
+
+// LookupAlgorithm returns the algorithm registered under name
+func LookupAlgorithm(name string) (Algorithm, bool) {
+	return limiter.Lookup(name)
+}
+
+// ParseAlgorithm returns the algorithm registered under name, ignoring
+// case, dashes, underscores and spaces, for algorithms read from config
+// files and flags. Algorithm also implements encoding.TextMarshaler and
+// encoding.TextUnmarshaler with the same names, so it can be used
+// directly in JSON or YAML config structs
+func ParseAlgorithm(name string) (Algorithm, error) {
+	return limiter.Parse(name)
+}
+
+// Algorithms returns every registered algorithm, built-ins first
+func Algorithms() []Algorithm {
+	return limiter.Algorithms()
+}
+
+// TokenBucketLimiter is the token bucket implementation behind TokenBucket.
+// Besides Limiter it accepts fractional costs through AllowF, ReserveF and
+// WaitF, e.g. half a token for a cheap request
+type TokenBucketLimiter = limiter.TokenBucketLimiter
+
+// NewTokenBucketWithDebt creates a token bucket whose AllowN may overdraw
+// the bucket by up to maxDebt tokens. Large requests are admitted now and
+// paid back by refill before later requests get through
+func NewTokenBucketWithDebt(r Limit, b int, maxDebt int) *TokenBucketLimiter {
+	tb := limiter.NewTokenBucket(r, b)
+	tb.SetMaxDebt(maxDebt)
+	return tb
+}
+
+// Priority identifies a traffic class of a PriorityLimiter
+type Priority = limiter.Priority
+
+// PriorityLimiter is a token bucket shared by several priority classes
+type PriorityLimiter = limiter.PriorityBucketLimiter
+
+// NewPriorityLimiter creates a token bucket shared by priority classes.
+// reserved[p] is the number of tokens that class p must leave for higher
+// classes; classes past the end of reserved may use the whole bucket
+func NewPriorityLimiter(r Limit, b int, reserved ...int) *PriorityLimiter {
+	return limiter.NewPriorityBucket(r, b, reserved...)
+}
+
+// SheddingLimiter rejects a growing fraction of Allow calls as the wrapped
+// limiter approaches its limit
+type SheddingLimiter = limiter.SheddingLimiter
+
+// NewSheddingLimiter wraps lim with RED-style early shedding. Once
+// utilization passes threshold (0..1), Allow rejects requests with a
+// probability that grows linearly to 1 as capacity runs out
+func NewSheddingLimiter(lim Limiter, threshold float64) *SheddingLimiter {
+	return limiter.NewShedding(lim, threshold)
+}
+
+// WindowRule allows Count events per Window
+type WindowRule = limiter.WindowRule
+
+// MultiWindowLimiter enforces several (count, window) rules at once
+type MultiWindowLimiter = limiter.MultiWindowLimiter
+
+// NewMultiWindowLimiter creates a limiter that admits an event only if
+// every rule allows it, e.g. 10 per second and 1000 per hour:
+//
+//	NewMultiWindowLimiter(
+//		WindowRule{Count: 10, Window: time.Second},
+//		WindowRule{Count: 1000, Window: time.Hour},
+//	)
+func NewMultiWindowLimiter(rules ...WindowRule) *MultiWindowLimiter {
+	return limiter.NewMultiWindow(rules...)
+}
+
+// AdaptiveLimiter adjusts its effective limit from observed latency
+type AdaptiveLimiter = limiter.AdaptiveLimiter
+
+// NewAdaptiveLimiter creates a latency-gradient adaptive limiter. r is the
+// ceiling of the effective limit, which shrinks as latency samples passed
+// to Observe rise above their long-term baseline and grows back when
+// latency recovers
+func NewAdaptiveLimiter(r Limit, b int) *AdaptiveLimiter {
+	return limiter.NewAdaptive(r, b)
+}
+
+// BucketedWindowLimiter is a sliding window kept as per-bucket counters
+type BucketedWindowLimiter = limiter.BucketedWindowLimiter
+
+// NewBucketedSlidingWindow creates a sliding window limiter allowing
+// maxCount events per window, tracked in the given number of sub-window
+// buckets. More buckets give a more precise window at the cost of memory;
+// SetLimit and SetBurst keep the window and change maxCount
+func NewBucketedSlidingWindow(maxCount int, window time.Duration, buckets int) *BucketedWindowLimiter {
+	return limiter.NewBucketedWindow(maxCount, window, buckets)
+}
+
+// QuotaPeriod is the calendar period after which a quota resets
+type QuotaPeriod = limiter.QuotaPeriod
+
+const (
+	Hourly  QuotaPeriod = limiter.Hourly
+	Daily   QuotaPeriod = limiter.Daily
+	Weekly  QuotaPeriod = limiter.Weekly
+	Monthly QuotaPeriod = limiter.Monthly
+)
+
+// CalendarQuotaLimiter is a quota that resets on calendar boundaries
+type CalendarQuotaLimiter = limiter.CalendarQuotaLimiter
+
+// NewCalendarQuota creates a quota of n events per calendar period, with
+// periods computed in loc (UTC if nil). Weeks start on Monday.
+// NewLimiter(CalendarQuota, r, b) creates a daily UTC quota of b events
+func NewCalendarQuota(n int, period QuotaPeriod, loc *time.Location) *CalendarQuotaLimiter {
+	return limiter.NewCalendarQuota(n, period, loc)
+}
+
+// ScheduleRule sets a limiter's Limit and Burst from the moment its Spec
+// fires until another rule of the same Schedule fires
+type ScheduleRule = limiter.ScheduleRule
+
+// Schedule switches a limiter between rules by time of day, e.g. a lower
+// limit during a nightly batch window:
+//
+//	sched, err := NewSchedule(nil,
+//		ScheduleRule{Spec: "08:00", Limit: 100, Burst: 20},
+//		ScheduleRule{Spec: "0 1 * * 1-5", Limit: 10, Burst: 5},
+//	)
+//	go sched.Run(ctx, lim)
+type Schedule = limiter.Schedule
+
+// NewSchedule parses rules into a schedule evaluated in loc (UTC if nil).
+// A spec is a time of day ("22:30") or a five field cron expression
+func NewSchedule(loc *time.Location, rules ...ScheduleRule) (*Schedule, error) {
+	return limiter.NewSchedule(loc, rules...)
+}
+
+// Verdict is the color a MeterLimiter assigns to an event
+type Verdict = limiter.Verdict
+
+const (
+	Conform Verdict = limiter.Conform
+	Exceed  Verdict = limiter.Exceed
+	Violate Verdict = limiter.Violate
+)
+
+// MeterLimiter is a three color marker returning a Verdict per event
+type MeterLimiter = limiter.MeterLimiter
+
+// NewSingleRateMeter creates an RFC 2697 single-rate three color marker
+// with committed rate cir, committed burst cbs and excess burst ebs
+func NewSingleRateMeter(cir Limit, cbs, ebs int) *MeterLimiter {
+	return limiter.NewSingleRateMeter(cir, cbs, ebs)
+}
+
+// NewTwoRateMeter creates an RFC 2698 two-rate three color marker with
+// committed rate and burst (cir, cbs) and peak rate and burst (pir, pbs)
+func NewTwoRateMeter(cir Limit, cbs int, pir Limit, pbs int) *MeterLimiter {
+	return limiter.NewTwoRateMeter(cir, cbs, pir, pbs)
+}
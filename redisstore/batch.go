@@ -0,0 +1,160 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// BatchLimiter is a limiter of this package that can be checked in a
+// Batch: TokenBucket or SlidingWindow
+type BatchLimiter interface {
+	// batchCalls returns the call taking n events for key at t and the
+	// one giving them back
+	batchCalls(key string, t time.Time, n int) (take, refund call)
+}
+
+var (
+	_ BatchLimiter = (*TokenBucket)(nil)
+	_ BatchLimiter = (*SlidingWindow)(nil)
+)
+
+func (tb *TokenBucket) batchCalls(key string, t time.Time, n int) (take, refund call) {
+	keys := []string{tb.cfg.key(key)}
+	rate := formatFloat(float64(tb.limit))
+	take = call{tokenBucketScript, keys, []any{rate, tb.burst, t.UnixMicro(), n, 0, tb.ttl()}}
+	refund = call{tokenBucketRefundScript, keys, []any{rate, tb.burst, t.UnixMicro(), n, tb.ttl()}}
+	return take, refund
+}
+
+func (sw *SlidingWindow) batchCalls(key string, t time.Time, n int) (take, refund call) {
+	keys := []string{sw.cfg.key(key)}
+	id := sw.id(t)
+	take = call{slidingWindowScript, keys, []any{sw.window.Microseconds(), sw.max, t.UnixMicro(), n, id}}
+	refund = call{slidingWindowRefundScript, keys, []any{n, id}}
+	return take, refund
+}
+
+// BatchResult is the outcome of one check of a Batch
+type BatchResult struct {
+	OK bool
+	// RetryAfter is how long until a refused check could pass, or -1 if
+	// it never can
+	RetryAfter time.Duration
+	// Err is the error of the check's script, if it failed
+	Err error
+}
+
+// Batch checks keys of several limiters in one pipeline, e.g. the
+// per-user, per-route and service-wide limits of one gateway request,
+// paying one round trip instead of one per limit. Each check is a script
+// of its own, so on Redis Cluster the keys may live on different nodes
+type Batch struct {
+	client Client
+	checks []batchCheck
+}
+
+type batchCheck struct {
+	lim BatchLimiter
+	key string
+	n   int
+}
+
+// NewBatch creates an empty batch sent through c, which must reach the
+// Redis of the limiters added to it. A Pipeliner sends the whole batch
+// at once; other clients send it one command at a time
+func NewBatch(c Client) *Batch {
+	return &Batch{client: c}
+}
+
+// Add adds a check of n events for key on lim and returns b
+func (b *Batch) Add(lim BatchLimiter, key string, n int) *Batch {
+	b.checks = append(b.checks, batchCheck{lim: lim, key: key, n: n})
+	return b
+}
+
+// Len returns the number of checks in b
+func (b *Batch) Len() int {
+	return len(b.checks)
+}
+
+// calls returns the take and refund calls of every check at t
+func (b *Batch) calls(t time.Time) (takes, refunds []call) {
+	takes = make([]call, len(b.checks))
+	refunds = make([]call, len(b.checks))
+	for i, c := range b.checks {
+		takes[i], refunds[i] = c.lim.batchCalls(c.key, t, c.n)
+	}
+	return takes, refunds
+}
+
+// AllowN runs every check at t, admitting or refusing each on its own.
+// The errors of failed checks are also returned joined
+func (b *Batch) AllowN(ctx context.Context, t time.Time) ([]BatchResult, error) {
+	takes, _ := b.calls(t)
+	return b.results(runCalls(ctx, b.client, takes))
+}
+
+func (b *Batch) results(replies []Reply) ([]BatchResult, error) {
+	results := make([]BatchResult, len(replies))
+	var errs []error
+	for i, r := range replies {
+		res := &results[i]
+		res.Err = r.Err
+		if res.Err == nil {
+			res.OK, res.RetryAfter, res.Err = takeReply(r.Value)
+		}
+		if res.OK {
+			res.RetryAfter = 0
+		}
+		if res.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", b.checks[i].key, res.Err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// AllowAll reports whether every check passes at t, charging all of them
+// or none: if any is refused or fails, the events the others took are
+// given back in a second pipeline. Between the two, other callers may
+// see those events as taken. When refused, retryAfter is the longest
+// wait of the refused checks, -1 if one can never pass
+func (b *Batch) AllowAll(ctx context.Context, t time.Time) (ok bool, retryAfter time.Duration, err error) {
+	takes, refunds := b.calls(t)
+	results, err := b.results(runCalls(ctx, b.client, takes))
+
+	ok = err == nil
+	var back []call
+	for i, r := range results {
+		switch {
+		case r.OK:
+			back = append(back, refunds[i])
+		case r.Err != nil:
+		case r.RetryAfter < 0 || retryAfter < 0:
+			ok, retryAfter = false, -1
+		default:
+			ok = false
+			if r.RetryAfter > retryAfter {
+				retryAfter = r.RetryAfter
+			}
+		}
+	}
+	if ok {
+		return true, 0, nil
+	}
+
+	var errs []error
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(back) == 0 {
+		return false, retryAfter, errors.Join(errs...)
+	}
+	for _, r := range runCalls(ctx, b.client, back) {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("refund: %w", r.Err))
+		}
+	}
+	return false, retryAfter, errors.Join(errs...)
+}
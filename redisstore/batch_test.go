@@ -0,0 +1,79 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// pipelined counts the pipelines sent to a fakeRedis
+type pipelined struct {
+	*fakeRedis
+	pipelines int
+}
+
+func (p *pipelined) DoMulti(ctx context.Context, cmds [][]any) []Reply {
+	p.pipelines++
+	replies := make([]Reply, len(cmds))
+	for i, cmd := range cmds {
+		replies[i].Value, replies[i].Err = p.Do(ctx, cmd...)
+	}
+	return replies
+}
+
+func TestBatchAllowN(t *testing.T) {
+	c := &pipelined{fakeRedis: newFakeRedis()}
+	user := NewTokenBucket(c, rateflow.Every(time.Minute), 2)
+	route := NewSlidingWindow(c, 1, time.Minute)
+	ctx := context.Background()
+	now := time.Now()
+
+	b := NewBatch(c).Add(user, "alice", 1).Add(route, "/search", 1)
+	if b.Len() != 2 {
+		t.Fatalf("Len = %d", b.Len())
+	}
+	results, err := b.AllowN(ctx, now)
+	if err != nil || !results[0].OK || !results[1].OK {
+		t.Fatalf("AllowN = %+v, %v, want both admitted", results, err)
+	}
+	results, _ = b.AllowN(ctx, now)
+	if !results[0].OK || results[1].OK {
+		t.Errorf("AllowN = %+v, want only the route refused", results)
+	}
+	if results[1].RetryAfter <= 0 || results[1].RetryAfter > time.Minute+time.Millisecond {
+		t.Errorf("RetryAfter = %v, want up to a minute", results[1].RetryAfter)
+	}
+	if c.pipelines != 3 {
+		t.Errorf("sent %d pipelines, want one per run after loading the scripts", c.pipelines)
+	}
+}
+
+func TestBatchAllowAll(t *testing.T) {
+	c := newFakeRedis()
+	user := NewTokenBucket(c, rateflow.Every(time.Minute), 2)
+	route := NewSlidingWindow(c, 1, time.Minute)
+	ctx := context.Background()
+	now := time.Now()
+
+	b := NewBatch(c).Add(user, "alice", 1).Add(route, "/search", 1)
+	if ok, _, err := b.AllowAll(ctx, now); !ok || err != nil {
+		t.Fatalf("AllowAll = %v, %v, want admitted", ok, err)
+	}
+	ok, retry, err := b.AllowAll(ctx, now)
+	if ok || err != nil || retry <= 0 {
+		t.Errorf("AllowAll = %v, %v, %v, want refused with a retry", ok, retry, err)
+	}
+	if ok, _ := user.AllowN(ctx, "alice", now, 1); !ok {
+		t.Error("the refused batch should have given the user's token back")
+	}
+
+	b = NewBatch(c).Add(user, "bob", 5).Add(route, "/home", 1)
+	if ok, retry, _ := b.AllowAll(ctx, now); ok || retry != -1 {
+		t.Errorf("AllowAll = %v, %v, want never for a check above the burst", ok, retry)
+	}
+	if ok, _ := route.AllowN(ctx, "/home", now, 1); !ok {
+		t.Error("the window event should have been given back")
+	}
+}
@@ -0,0 +1,223 @@
+// Package redisstore implements rateflow.RemoteLimiter on Redis, so
+// replicas of a service share one limit per key. Every decision is a
+// single Lua script, atomic on the server.
+//
+// The package does not depend on a Redis client. Any client that can run
+// a raw command works through ClientFunc, e.g. go-redis:
+//
+//	redisstore.ClientFunc(func(ctx context.Context, args ...any) (any, error) {
+//		return rdb.Do(ctx, args...).Result()
+//	})
+//
+// or redigo:
+//
+//	redisstore.ClientFunc(func(ctx context.Context, args ...any) (any, error) {
+//		conn := pool.Get()
+//		defer conn.Close()
+//		return redis.DoContext(conn, ctx, args[0].(string), args[1:]...)
+//	})
+//
+// Keys are stored as prefix{key}, so on Redis Cluster everything kept for
+// one key hashes to one slot. Cluster-aware clients follow MOVED and ASK
+// redirects themselves; plain per-node clients can be combined with
+// NewCluster instead.
+//
+// A Batch checks several limits at once, e.g. every descriptor of a
+// gateway request, in one round trip
+package redisstore
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Client runs one Redis command, given as its name followed by its
+// arguments, and returns the reply
+type Client interface {
+	Do(ctx context.Context, args ...any) (any, error)
+}
+
+// ClientFunc adapts a function to a Client
+type ClientFunc func(ctx context.Context, args ...any) (any, error)
+
+// Do calls f(ctx, args...)
+func (f ClientFunc) Do(ctx context.Context, args ...any) (any, error) {
+	return f(ctx, args...)
+}
+
+// script is a Lua script run by its SHA1, loading it on first use
+type script struct {
+	src string
+	sha string
+}
+
+func newScript(src string) *script {
+	sum := sha1.Sum([]byte(src))
+	return &script{src: src, sha: hex.EncodeToString(sum[:])}
+}
+
+// run runs s with EVALSHA, falling back to EVAL when the server does not
+// have it cached yet
+func (s *script) run(ctx context.Context, c Client, keys []string, args ...any) (any, error) {
+	cmd := s.command(keys, args)
+	reply, err := c.Do(ctx, cmd...)
+	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		cmd[0], cmd[1] = "EVAL", s.src
+		reply, err = c.Do(ctx, cmd...)
+	}
+	return reply, err
+}
+
+// Replies differ between clients: go-redis returns strings where redigo
+// returns []byte
+
+func replyInts(reply any, n int) ([]int64, error) {
+	values, ok := reply.([]any)
+	if !ok || len(values) < n {
+		return nil, fmt.Errorf("redisstore: unexpected reply %v", reply)
+	}
+	ints := make([]int64, n)
+	for i := range ints {
+		v, err := replyInt(values[i])
+		if err != nil {
+			return nil, err
+		}
+		ints[i] = v
+	}
+	return ints, nil
+}
+
+func replyInt(v any) (int64, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	}
+	return 0, fmt.Errorf("redisstore: unexpected reply value %v", v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Reply is the outcome of one pipelined command
+type Reply struct {
+	Value any
+	Err   error
+}
+
+// Pipeliner is implemented by clients that can send several commands in
+// one round trip, returning one reply per command. With go-redis:
+//
+//	func (p pipeliner) DoMulti(ctx context.Context, cmds [][]any) []redisstore.Reply {
+//		pipe := p.rdb.Pipeline()
+//		results := make([]*redis.Cmd, len(cmds))
+//		for i, cmd := range cmds {
+//			results[i] = pipe.Do(ctx, cmd...)
+//		}
+//		pipe.Exec(ctx)
+//		replies := make([]redisstore.Reply, len(cmds))
+//		for i, r := range results {
+//			replies[i].Value, replies[i].Err = r.Result()
+//		}
+//		return replies
+//	}
+type Pipeliner interface {
+	Client
+	DoMulti(ctx context.Context, cmds [][]any) []Reply
+}
+
+// doMulti pipelines cmds if c supports it and sends them one by one
+// otherwise
+func doMulti(ctx context.Context, c Client, cmds [][]any) []Reply {
+	if p, ok := c.(Pipeliner); ok {
+		return p.DoMulti(ctx, cmds)
+	}
+	replies := make([]Reply, len(cmds))
+	for i, cmd := range cmds {
+		replies[i].Value, replies[i].Err = c.Do(ctx, cmd...)
+	}
+	return replies
+}
+
+// command returns the EVALSHA command running s
+func (s *script) command(keys []string, args []any) []any {
+	cmd := make([]any, 0, 3+len(keys)+len(args))
+	cmd = append(cmd, "EVALSHA", s.sha, len(keys))
+	for _, k := range keys {
+		cmd = append(cmd, k)
+	}
+	return append(cmd, args...)
+}
+
+// call is one run of a script
+type call struct {
+	script *script
+	keys   []string
+	args   []any
+}
+
+// runMulti runs s once per element of keys and args in one pipeline
+func (s *script) runMulti(ctx context.Context, c Client, keys [][]string, args [][]any) []Reply {
+	calls := make([]call, len(keys))
+	for i := range keys {
+		calls[i] = call{script: s, keys: keys[i], args: args[i]}
+	}
+	return runCalls(ctx, c, calls)
+}
+
+// runCalls runs calls in one pipeline, resending with EVAL the calls the
+// server had no cached script for
+func runCalls(ctx context.Context, c Client, calls []call) []Reply {
+	cmds := make([][]any, len(calls))
+	for i, cl := range calls {
+		cmds[i] = cl.script.command(cl.keys, cl.args)
+	}
+	replies := doMulti(ctx, c, cmds)
+
+	var retry []int
+	for i, r := range replies {
+		if r.Err != nil && strings.HasPrefix(r.Err.Error(), "NOSCRIPT") {
+			retry = append(retry, i)
+		}
+	}
+	if len(retry) == 0 {
+		return replies
+	}
+	again := make([][]any, len(retry))
+	for j, i := range retry {
+		cmd := append([]any(nil), cmds[i]...)
+		cmd[0], cmd[1] = "EVAL", calls[i].script.src
+		again[j] = cmd
+	}
+	for j, r := range doMulti(ctx, c, again) {
+		replies[retry[j]] = r
+	}
+	return replies
+}
+
+// allowed decodes pipelined {admitted, wait} replies for keys
+func allowed(keys []string, replies []Reply) ([]bool, error) {
+	ok := make([]bool, len(replies))
+	var errs []error
+	for i, r := range replies {
+		err := r.Err
+		if err == nil {
+			ok[i], _, err = takeReply(r.Value)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", keys[i], err))
+		}
+	}
+	return ok, errors.Join(errs...)
+}
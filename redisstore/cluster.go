@@ -0,0 +1,195 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// slotCount is the number of hash slots in a Redis Cluster
+const slotCount = 16384
+
+// maxRedirects bounds the MOVED/ASK redirects followed for one command
+const maxRedirects = 5
+
+// Slot returns the Redis Cluster hash slot of key, honoring {hash tags}.
+// Every key a limiter uses for one rate-limited key carries that key as
+// its hash tag, so they all live in the same slot
+func Slot(key string) int {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			key = key[i+1 : i+1+j]
+		}
+	}
+	return int(crc16(key) % slotCount)
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum Redis Cluster uses
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for b := 0; b < 8; b++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// Cluster is a Client for Redis Cluster built from plain per-node
+// clients. It routes each command to the node owning its key's slot and
+// follows MOVED and ASK redirects, learning the slot map as it goes.
+// Cluster-aware clients such as go-redis's ClusterClient already do this
+// and can be used directly instead
+type Cluster struct {
+	seed Client
+	dial func(addr string) Client
+
+	mu    sync.RWMutex
+	nodes map[string]Client
+	slots map[int]string // slot to node address, as learned from MOVED
+}
+
+var (
+	_ Client    = (*Cluster)(nil)
+	_ Pipeliner = (*Cluster)(nil)
+)
+
+// NewCluster creates a cluster client sending commands for unknown slots
+// to seed and reaching other nodes through dial. For ASK redirects to
+// work the client dial returns must run ASKING and the next command on the
+// same connection
+func NewCluster(seed Client, dial func(addr string) Client) *Cluster {
+	return &Cluster{
+		seed:  seed,
+		dial:  dial,
+		nodes: make(map[string]Client),
+		slots: make(map[int]string),
+	}
+}
+
+// commandKey returns the first key of a command, if any
+func commandKey(args []any) (string, bool) {
+	if len(args) < 2 {
+		return "", false
+	}
+	name := strings.ToUpper(fmt.Sprint(args[0]))
+	if name == "EVAL" || name == "EVALSHA" {
+		if len(args) < 4 || fmt.Sprint(args[2]) == "0" {
+			return "", false
+		}
+		return fmt.Sprint(args[3]), true
+	}
+	return fmt.Sprint(args[1]), true
+}
+
+// node returns the client for addr, dialing it on first use
+func (c *Cluster) node(addr string) Client {
+	c.mu.RLock()
+	n, ok := c.nodes[addr]
+	c.mu.RUnlock()
+	if ok {
+		return n
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n, ok := c.nodes[addr]; ok {
+		return n
+	}
+	n = c.dial(addr)
+	c.nodes[addr] = n
+	return n
+}
+
+// route returns the client believed to own the slot of args' key
+func (c *Cluster) route(args []any) Client {
+	key, ok := commandKey(args)
+	if !ok {
+		return c.seed
+	}
+	c.mu.RLock()
+	addr, ok := c.slots[Slot(key)]
+	c.mu.RUnlock()
+	if !ok {
+		return c.seed
+	}
+	return c.node(addr)
+}
+
+// redirect parses a MOVED or ASK error into its slot and address
+func redirect(err error) (kind string, slot int, addr string, ok bool) {
+	fields := strings.Fields(err.Error())
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return "", 0, "", false
+	}
+	slot, perr := strconv.Atoi(fields[1])
+	if perr != nil {
+		return "", 0, "", false
+	}
+	return fields[0], slot, fields[2], true
+}
+
+// Do sends args to the node owning its key, following redirects
+func (c *Cluster) Do(ctx context.Context, args ...any) (any, error) {
+	return c.follow(ctx, c.route(args), args)
+}
+
+// follow sends args to n and follows the redirects it answers with
+func (c *Cluster) follow(ctx context.Context, n Client, args []any) (any, error) {
+	reply, err := n.Do(ctx, args...)
+	for i := 0; err != nil && i < maxRedirects; i++ {
+		kind, slot, addr, ok := redirect(err)
+		if !ok {
+			break
+		}
+		n = c.node(addr)
+		if kind == "MOVED" {
+			c.mu.Lock()
+			c.slots[slot] = addr
+			c.mu.Unlock()
+		} else if _, err = n.Do(ctx, "ASKING"); err != nil {
+			return nil, err
+		}
+		reply, err = n.Do(ctx, args...)
+	}
+	return reply, err
+}
+
+// DoMulti sends cmds to their nodes, pipelined per node where the node
+// client supports it, and follows redirects one command at a time
+func (c *Cluster) DoMulti(ctx context.Context, cmds [][]any) []Reply {
+	replies := make([]Reply, len(cmds))
+	groups := make(map[Client][]int)
+	var order []Client
+	for i, cmd := range cmds {
+		n := c.route(cmd)
+		if _, ok := groups[n]; !ok {
+			order = append(order, n)
+		}
+		groups[n] = append(groups[n], i)
+	}
+
+	for _, n := range order {
+		idx := groups[n]
+		batch := make([][]any, len(idx))
+		for j, i := range idx {
+			batch[j] = cmds[i]
+		}
+		for j, r := range doMulti(ctx, n, batch) {
+			if r.Err != nil {
+				if _, _, _, ok := redirect(r.Err); ok {
+					r.Value, r.Err = c.follow(ctx, n, cmds[idx[j]])
+				}
+			}
+			replies[idx[j]] = r
+		}
+	}
+	return replies
+}
@@ -0,0 +1,238 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCluster serves a shared fakeRedis from several nodes, each owning
+// some slots and redirecting commands for the others
+type fakeCluster struct {
+	backend *fakeRedis
+
+	mu        sync.Mutex
+	owner     map[int]string // slot to owning node, "a" if absent
+	migrating map[int]string // slot to the node it is moving to
+	nodes     map[string]*fakeNode
+}
+
+type fakeNode struct {
+	addr   string
+	c      *fakeCluster
+	asking bool
+	calls  int
+	multi  int
+}
+
+func newFakeCluster() *fakeCluster {
+	c := &fakeCluster{
+		backend:   newFakeRedis(),
+		owner:     make(map[int]string),
+		migrating: make(map[int]string),
+		nodes:     make(map[string]*fakeNode),
+	}
+	for _, addr := range []string{"a", "b"} {
+		c.nodes[addr] = &fakeNode{addr: addr, c: c}
+	}
+	return c
+}
+
+func (c *fakeCluster) dial(addr string) Client {
+	return c.nodes[addr]
+}
+
+func (n *fakeNode) Do(ctx context.Context, args ...any) (any, error) {
+	n.c.mu.Lock()
+	n.calls++
+	if args[0] == "ASKING" {
+		n.asking = true
+		n.c.mu.Unlock()
+		return "OK", nil
+	}
+	asking := n.asking
+	n.asking = false
+
+	key, _ := commandKey(args)
+	slot := Slot(key)
+	owner, ok := n.c.owner[slot]
+	if !ok {
+		owner = "a"
+	}
+	to, migrating := n.c.migrating[slot]
+	n.c.mu.Unlock()
+
+	switch {
+	case owner == n.addr && migrating:
+		return nil, fmt.Errorf("ASK %d %s", slot, to)
+	case owner != n.addr && !(migrating && to == n.addr && asking):
+		return nil, fmt.Errorf("MOVED %d %s", slot, owner)
+	}
+	return n.c.backend.Do(ctx, args...)
+}
+
+func (n *fakeNode) DoMulti(ctx context.Context, cmds [][]any) []Reply {
+	n.c.mu.Lock()
+	n.multi++
+	n.c.mu.Unlock()
+	replies := make([]Reply, len(cmds))
+	for i, cmd := range cmds {
+		replies[i].Value, replies[i].Err = n.Do(ctx, cmd...)
+	}
+	return replies
+}
+
+func TestSlot(t *testing.T) {
+	if got := Slot("foo"); got != 12182 {
+		t.Errorf("Slot(foo) = %d, want 12182", got)
+	}
+	if Slot("rateflow:{user:1}") != Slot("user:1") {
+		t.Error("the hash tag should decide the slot")
+	}
+	if Slot("{}x") != Slot("{}x") || Slot("{}x") == Slot("") {
+		t.Error("an empty hash tag should hash the whole key")
+	}
+
+	cfg := newConfig(nil)
+	if got := cfg.key("user:1"); got != "rateflow:{user:1}" {
+		t.Errorf("key = %q, want the key as hash tag", got)
+	}
+}
+
+func TestClusterMoved(t *testing.T) {
+	fc := newFakeCluster()
+	key := newConfig(nil).key("user:1")
+	fc.owner[Slot(key)] = "b"
+
+	c := NewCluster(fc.nodes["a"], fc.dial)
+	tb := NewTokenBucket(c, 1, 2)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, err := tb.AllowN(ctx, "user:1", now, 1); err != nil || !ok {
+			t.Fatalf("AllowN #%d = %v, %v, want true", i, ok, err)
+		}
+	}
+	if ok, _ := tb.AllowN(ctx, "user:1", now, 1); ok {
+		t.Error("the bucket should be empty on the owning node")
+	}
+	if fc.nodes["a"].calls != 1 {
+		t.Errorf("seed got %d calls, want 1 before the slot was learned", fc.nodes["a"].calls)
+	}
+}
+
+func TestClusterAsk(t *testing.T) {
+	fc := newFakeCluster()
+	key := newConfig(nil).key("user:1")
+	fc.migrating[Slot(key)] = "b"
+
+	c := NewCluster(fc.nodes["a"], fc.dial)
+	tb := NewTokenBucket(c, 1, 5)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if ok, err := tb.AllowN(ctx, "user:1", time.Now(), 1); err != nil || !ok {
+			t.Fatalf("AllowN #%d = %v, %v, want true", i, ok, err)
+		}
+	}
+	// ASK is a one-off redirect: the slot stays with a, which is asked
+	// again every time
+	if fc.nodes["a"].calls < 2 {
+		t.Errorf("a got %d calls, want every command to try it first", fc.nodes["a"].calls)
+	}
+	if len(c.slots) != 0 {
+		t.Errorf("ASK should not update the slot map, got %v", c.slots)
+	}
+
+	delete(fc.migrating, Slot(key))
+	fc.owner[Slot(key)] = "b"
+	if ok, err := tb.AllowN(ctx, "user:1", time.Now(), 1); err != nil || !ok {
+		t.Fatalf("AllowN after migration = %v, %v, want true", ok, err)
+	}
+}
+
+func TestClusterTooManyRedirects(t *testing.T) {
+	fc := newFakeCluster()
+	key := newConfig(nil).key("user:1")
+	fc.owner[Slot(key)] = "b"
+
+	// b keeps pointing back at a
+	c := NewCluster(fc.nodes["a"], func(string) Client {
+		return ClientFunc(func(ctx context.Context, args ...any) (any, error) {
+			return nil, fmt.Errorf("MOVED %d a", Slot(key))
+		})
+	})
+	if _, err := NewTokenBucket(c, 1, 1).AllowN(context.Background(), "user:1", time.Now(), 1); err == nil {
+		t.Error("AllowN should give up after too many redirects")
+	}
+}
+
+func TestAllowNKeys(t *testing.T) {
+	fc := newFakeCluster()
+	c := NewCluster(fc.nodes["a"], fc.dial)
+	tb := NewTokenBucket(c, 1, 1)
+	ctx := context.Background()
+	now := time.Now()
+
+	keys := []string{"a", "b", "a"}
+	got, err := tb.AllowNKeys(ctx, keys, now, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{true, true, false}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("AllowNKeys = %v, want %v", got, want)
+	}
+	// The first pipeline finds no cached script and is resent with EVAL
+	if fc.nodes["a"].multi != 2 {
+		t.Errorf("got %d pipelines, want 2", fc.nodes["a"].multi)
+	}
+
+	got, err = tb.AllowNKeys(ctx, []string{"c", "a"}, now.Add(time.Second), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{true, true}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("AllowNKeys after refill = %v, want %v", got, want)
+	}
+	if fc.nodes["a"].multi != 3 {
+		t.Errorf("got %d pipelines, want one more", fc.nodes["a"].multi)
+	}
+}
+
+func TestAllowNKeysAcrossNodes(t *testing.T) {
+	fc := newFakeCluster()
+	cfg := newConfig(nil)
+	fc.owner[Slot(cfg.key("b"))] = "b"
+
+	c := NewCluster(fc.nodes["a"], fc.dial)
+	sw := NewSlidingWindow(c, 1, time.Minute)
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := sw.AllowNKeys(ctx, []string{"a", "b"}, now, 1); err != nil {
+		t.Fatal(err)
+	}
+	got, err := sw.AllowNKeys(ctx, []string{"a", "b", "c"}, now, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{false, false, true}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("AllowNKeys = %v, want %v", got, want)
+	}
+	if fc.nodes["b"].multi == 0 {
+		t.Error("keys of b's slots should be pipelined to b once learned")
+	}
+}
+
+func TestAllowNKeysErrors(t *testing.T) {
+	c := ClientFunc(func(ctx context.Context, args ...any) (any, error) {
+		return nil, fmt.Errorf("ERR down")
+	})
+	got, err := NewTokenBucket(c, 1, 1).AllowNKeys(context.Background(), []string{"a", "b"}, time.Now(), 1)
+	if err == nil || len(got) != 2 || got[0] || got[1] {
+		t.Errorf("AllowNKeys = %v, %v, want refusals and an error", got, err)
+	}
+}
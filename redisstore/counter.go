@@ -0,0 +1,46 @@
+package redisstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// counterScript adds ARGV[1] to a counter, setting its TTL in
+// milliseconds when the counter is created, and returns the new total
+var counterScript = newScript(`
+local total = redis.call('INCRBY', KEYS[1], ARGV[1])
+if redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return total
+`)
+
+// Counter is a rateflow.CounterStore on Redis counters, for
+// rateflow.AsyncWindow
+type Counter struct {
+	client Client
+	cfg    config
+}
+
+var _ rateflow.CounterStore = (*Counter)(nil)
+
+// NewCounter creates a counter store. WithIdleTTL does not apply; every
+// counter expires after the ttl given when it is created
+func NewCounter(c Client, opts ...Option) *Counter {
+	return &Counter{client: c, cfg: newConfig(opts)}
+}
+
+// IncrBy adds delta to key and returns the new total
+func (c *Counter) IncrBy(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	ms := ttl.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	reply, err := counterScript.run(ctx, c.client, []string{c.cfg.key(key)}, delta, ms)
+	if err != nil {
+		return 0, err
+	}
+	return replyInt(reply)
+}
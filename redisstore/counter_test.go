@@ -0,0 +1,35 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestCounter(t *testing.T) {
+	redis := newFakeRedis()
+	c := NewCounter(redis)
+	ctx := context.Background()
+
+	if total, err := c.IncrBy(ctx, "k", 3, time.Minute); err != nil || total != 3 {
+		t.Fatalf("IncrBy = %d, %v, want 3", total, err)
+	}
+	if total, _ := c.IncrBy(ctx, "k", 2, time.Second); total != 5 {
+		t.Errorf("IncrBy = %d, want 5", total)
+	}
+	if ttl := redis.ttls["rateflow:{k}"]; ttl != 60000 {
+		t.Errorf("ttl = %dms, want the one set on creation", ttl)
+	}
+
+	w := rateflow.NewAsyncWindow(c, "api", 5, time.Minute)
+	now := time.Now()
+	w.AllowN(now, 1)
+	if err := w.FlushAt(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Estimate(now); got != 1 {
+		t.Errorf("Estimate = %d, want 1", got)
+	}
+}
@@ -0,0 +1,204 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// fakeRedis runs the package's scripts with Go ports of their Lua, so
+// the client side can be tested without a server
+type fakeRedis struct {
+	mu      sync.Mutex
+	hashes  map[string]map[string]string
+	zsets   map[string]map[string]float64
+	counts  map[string]int64
+	strs    map[string]int64
+	ttls    map[string]int64
+	loaded  map[string]bool
+	scripts map[string]func(r *fakeRedis, keys []string, args []string) any
+	calls   int
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{
+		hashes: make(map[string]map[string]string),
+		zsets:  make(map[string]map[string]float64),
+		counts: make(map[string]int64),
+		strs:   make(map[string]int64),
+		ttls:   make(map[string]int64),
+		loaded: make(map[string]bool),
+		scripts: map[string]func(*fakeRedis, []string, []string) any{
+			tokenBucketScript.sha:         (*fakeRedis).tokenBucket,
+			tokenBucketRefundScript.sha:   (*fakeRedis).tokenBucketRefund,
+			slidingWindowScript.sha:       (*fakeRedis).slidingWindow,
+			slidingWindowRefundScript.sha: (*fakeRedis).slidingWindowRefund,
+			counterScript.sha:             (*fakeRedis).counter,
+			storeGetScript.sha:            (*fakeRedis).storeGet,
+			storeAddScript.sha:            (*fakeRedis).storeAdd,
+			storeCASScript.sha:            (*fakeRedis).storeCAS,
+		},
+	}
+}
+
+func (r *fakeRedis) Do(ctx context.Context, args ...any) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+
+	strs := make([]string, len(args))
+	for i, a := range args {
+		strs[i] = fmt.Sprint(a)
+	}
+	sha := strs[1]
+	switch strs[0] {
+	case "EVALSHA":
+		if !r.loaded[sha] {
+			return nil, errors.New("NOSCRIPT No matching script. Please use EVAL.")
+		}
+	case "EVAL":
+		sha = newScript(strs[1]).sha
+		r.loaded[sha] = true
+	default:
+		return nil, fmt.Errorf("ERR unknown command %q", strs[0])
+	}
+
+	numKeys, _ := strconv.Atoi(strs[2])
+	run, ok := r.scripts[sha]
+	if !ok {
+		return nil, errors.New("ERR unknown script")
+	}
+	return run(r, strs[3:3+numKeys], strs[3+numKeys:]), nil
+}
+
+func num(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// bucket loads and refills the bucket at key as the scripts do
+func (r *fakeRedis) bucket(key string, rate, burst, now float64) (tokens, ts float64, ok bool) {
+	h, ok := r.hashes[key]
+	if !ok {
+		return burst, now, false
+	}
+	tokens, ts = num(h["tokens"]), num(h["ts"])
+	if now > ts {
+		tokens = math.Min(burst, tokens+(now-ts)*rate/1e6)
+		ts = now
+	}
+	return tokens, ts, true
+}
+
+func (r *fakeRedis) tokenBucket(keys, args []string) any {
+	rate, burst, now, n, maxWait := num(args[0]), num(args[1]), num(args[2]), num(args[3]), num(args[4])
+	tokens, ts, _ := r.bucket(keys[0], rate, burst, now)
+	if n > burst {
+		return []any{int64(0), int64(-1)}
+	}
+	left := tokens - n
+	wait := 0.0
+	if left < 0 {
+		if rate <= 0 {
+			return []any{int64(0), int64(-1)}
+		}
+		wait = math.Ceil(-left * 1e6 / rate)
+	}
+	if maxWait >= 0 && wait > maxWait {
+		return []any{int64(0), int64(wait)}
+	}
+	r.hashes[keys[0]] = map[string]string{"tokens": fmt.Sprint(left), "ts": fmt.Sprint(ts)}
+	r.ttls[keys[0]] = bucketExpiry(rate, burst, left, num(args[5]))
+	return []any{int64(1), []byte(strconv.FormatInt(int64(wait), 10))}
+}
+
+func (r *fakeRedis) tokenBucketRefund(keys, args []string) any {
+	rate, burst, now, n := num(args[0]), num(args[1]), num(args[2]), num(args[3])
+	tokens, ts, ok := r.bucket(keys[0], rate, burst, now)
+	if !ok {
+		return int64(0)
+	}
+	tokens = math.Min(burst, tokens+n)
+	r.hashes[keys[0]] = map[string]string{"tokens": fmt.Sprint(tokens), "ts": fmt.Sprint(ts)}
+	r.ttls[keys[0]] = bucketExpiry(rate, burst, tokens, num(args[4]))
+	return int64(1)
+}
+
+// bucketExpiry is the expiry the bucket scripts set, in milliseconds
+func bucketExpiry(rate, burst, tokens, ttl float64) int64 {
+	if rate <= 0 {
+		return int64(ttl)
+	}
+	return int64(math.Ceil((burst-tokens)*1000/rate)) + 1000
+}
+
+func (r *fakeRedis) slidingWindow(keys, args []string) any {
+	window, max, now, n, id := num(args[0]), int(num(args[1])), num(args[2]), int(num(args[3])), args[4]
+	if n > max {
+		return []any{int64(0), int64(-1)}
+	}
+	z := r.zsets[keys[0]]
+	if z == nil {
+		z = make(map[string]float64)
+		r.zsets[keys[0]] = z
+	}
+	var scores []float64
+	for m, score := range z {
+		if score <= now-window {
+			delete(z, m)
+			continue
+		}
+		scores = append(scores, score)
+	}
+	sort.Float64s(scores)
+	if count := len(scores); count+n > max {
+		return []any{int64(0), int64(scores[count+n-max-1] + window - now + 1)}
+	}
+	for i := 1; i <= n; i++ {
+		z[id+":"+strconv.Itoa(i)] = now
+	}
+	return []any{int64(1), int64(0)}
+}
+
+func (r *fakeRedis) slidingWindowRefund(keys, args []string) any {
+	n, id := int(num(args[0])), args[1]
+	for i := 1; i <= n; i++ {
+		delete(r.zsets[keys[0]], id+":"+strconv.Itoa(i))
+	}
+	return int64(1)
+}
+
+func (r *fakeRedis) counter(keys, args []string) any {
+	r.counts[keys[0]] += int64(num(args[0]))
+	if _, ok := r.ttls[keys[0]]; !ok {
+		r.ttls[keys[0]] = int64(num(args[1]))
+	}
+	return r.counts[keys[0]]
+}
+
+func (r *fakeRedis) storeGet(keys, args []string) any {
+	if v, ok := r.strs[keys[0]]; ok {
+		return []any{int64(1), strconv.FormatInt(v, 10)}
+	}
+	return []any{int64(0), int64(0)}
+}
+
+func (r *fakeRedis) storeAdd(keys, args []string) any {
+	if _, ok := r.strs[keys[0]]; ok {
+		return int64(0)
+	}
+	r.strs[keys[0]], r.ttls[keys[0]] = int64(num(args[0])), int64(num(args[1]))
+	return int64(1)
+}
+
+func (r *fakeRedis) storeCAS(keys, args []string) any {
+	if v, ok := r.strs[keys[0]]; !ok || strconv.FormatInt(v, 10) != args[0] {
+		return int64(0)
+	}
+	r.strs[keys[0]], r.ttls[keys[0]] = int64(num(args[1])), int64(num(args[2]))
+	return int64(1)
+}
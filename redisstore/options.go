@@ -0,0 +1,47 @@
+package redisstore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Option configures a Redis limiter
+type Option func(*config)
+
+type config struct {
+	prefix  string
+	idleTTL time.Duration
+
+	// instance tells apart state written by different processes
+	instance string
+}
+
+func newConfig(opts []Option) config {
+	var id [8]byte
+	rand.Read(id[:])
+	cfg := config{prefix: "rateflow:", idleTTL: time.Hour, instance: hex.EncodeToString(id[:])}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// key returns the Redis key holding the state for key. key is the hash
+// tag, so on Redis Cluster all state for one key shares a slot
+func (c config) key(key string) string {
+	return c.prefix + "{" + key + "}"
+}
+
+// WithPrefix sets the prefix of every Redis key the limiter uses,
+// "rateflow:" by default, so several limiters can share a database
+func WithPrefix(prefix string) Option {
+	return func(c *config) { c.prefix = prefix }
+}
+
+// WithIdleTTL sets how long state that never expires by itself, e.g. a
+// bucket with a zero rate, is kept after its last use. It defaults to an
+// hour
+func WithIdleTTL(ttl time.Duration) Option {
+	return func(c *config) { c.idleTTL = ttl }
+}
@@ -0,0 +1,147 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// slidingWindowScript keeps a sorted set of event times in microseconds
+// and admits n more if fewer than max-n fall inside the window. Members
+// carry a unique suffix so events at the same time are all kept. It
+// returns {admitted, microseconds until enough events expire}; the wait
+// is -1 if n can never fit
+var slidingWindowScript = newScript(`
+local window = tonumber(ARGV[1])
+local max = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local id = ARGV[5]
+
+if n > max then
+	return {0, -1}
+end
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+if count + n > max then
+	local oldest = redis.call('ZRANGE', KEYS[1], count + n - max - 1, count + n - max - 1, 'WITHSCORES')
+	return {0, tonumber(oldest[2]) + window - now + 1}
+end
+
+for i = 1, n do
+	redis.call('ZADD', KEYS[1], now, id .. ':' .. i)
+end
+redis.call('PEXPIRE', KEYS[1], math.ceil(window / 1000))
+return {1, 0}
+`)
+
+// slidingWindowRefundScript removes the events added under one id
+var slidingWindowRefundScript = newScript(`
+local n = tonumber(ARGV[1])
+local id = ARGV[2]
+for i = 1, n do
+	redis.call('ZREM', KEYS[1], id .. ':' .. i)
+end
+return 1
+`)
+
+// SlidingWindow admits at most max events per key in any window of time,
+// keeping a log of event times in a Redis sorted set. Like the local
+// SlidingWindow it cannot schedule future events: ReserveN either admits
+// at once or is refused with the time a retry could succeed
+type SlidingWindow struct {
+	client Client
+	cfg    config
+	max    int
+	window time.Duration
+	seq    atomic.Uint64
+}
+
+var _ rateflow.RemoteLimiter = (*SlidingWindow)(nil)
+
+// NewSlidingWindow creates a limiter allowing max events per window for
+// every key
+func NewSlidingWindow(c Client, max int, window time.Duration, opts ...Option) *SlidingWindow {
+	return &SlidingWindow{client: c, cfg: newConfig(opts), max: max, window: window}
+}
+
+// Window returns the window duration
+func (sw *SlidingWindow) Window() time.Duration {
+	return sw.window
+}
+
+// Burst returns the number of events allowed per window
+func (sw *SlidingWindow) Burst() int {
+	return sw.max
+}
+
+// take runs the window script for n events tagged with id
+func (sw *SlidingWindow) take(ctx context.Context, key string, t time.Time, n int, id string) (bool, time.Duration, error) {
+	reply, err := slidingWindowScript.run(ctx, sw.client, []string{sw.cfg.key(key)},
+		sw.window.Microseconds(), sw.max, t.UnixMicro(), n, id)
+	if err != nil {
+		return false, 0, err
+	}
+	return takeReply(reply)
+}
+
+// id returns a member prefix unique to this event batch
+func (sw *SlidingWindow) id(t time.Time) string {
+	return fmt.Sprintf("%d-%s-%d", t.UnixNano(), sw.cfg.instance, sw.seq.Add(1))
+}
+
+// AllowN reports whether n events for key may happen at t
+func (sw *SlidingWindow) AllowN(ctx context.Context, key string, t time.Time, n int) (bool, error) {
+	ok, _, err := sw.take(ctx, key, t, n, sw.id(t))
+	return ok, err
+}
+
+// AllowNKeys is AllowN for many keys in one pipeline. Each key is
+// admitted or refused independently; keys whose script failed report
+// false and their errors are joined
+func (sw *SlidingWindow) AllowNKeys(ctx context.Context, keys []string, t time.Time, n int) ([]bool, error) {
+	redisKeys := make([][]string, len(keys))
+	args := make([][]any, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = []string{sw.cfg.key(key)}
+		args[i] = []any{sw.window.Microseconds(), sw.max, t.UnixMicro(), n, sw.id(t)}
+	}
+	return allowed(keys, slidingWindowScript.runMulti(ctx, sw.client, redisKeys, args))
+}
+
+// ReserveN admits n events for key at t or refuses them with the time
+// enough events expire for a retry
+func (sw *SlidingWindow) ReserveN(ctx context.Context, key string, t time.Time, n int) (*rateflow.RemoteReservation, error) {
+	id := sw.id(t)
+	ok, wait, err := sw.take(ctx, key, t, n, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		var retry time.Time
+		if wait >= 0 {
+			retry = t.Add(wait)
+		}
+		return rateflow.NewRemoteReservation(false, retry, n, nil), nil
+	}
+
+	cancel := func(ctx context.Context, now time.Time) error {
+		if !now.Before(t.Add(sw.window)) {
+			return nil
+		}
+		_, err := slidingWindowRefundScript.run(ctx, sw.client, []string{sw.cfg.key(key)}, n, id)
+		return err
+	}
+	return rateflow.NewRemoteReservation(true, t, n, cancel), nil
+}
+
+// WaitN blocks until n events for key are allowed or ctx is done
+func (sw *SlidingWindow) WaitN(ctx context.Context, key string, n int) error {
+	if n > sw.max {
+		return fmt.Errorf("%w: %d > %d", rateflow.ErrExceedsBurst, n, sw.max)
+	}
+	return rateflow.WaitRemote(ctx, sw, key, n)
+}
@@ -0,0 +1,57 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	sw := NewSlidingWindow(newFakeRedis(), 2, time.Second)
+	now := time.Now()
+
+	if ok, _ := sw.AllowN(ctx, "k", now, 1); !ok {
+		t.Fatal("expected the first event to pass")
+	}
+	if ok, _ := sw.AllowN(ctx, "k", now.Add(500*time.Millisecond), 1); !ok {
+		t.Fatal("expected the second event to pass")
+	}
+	r, err := sw.ReserveN(ctx, "k", now.Add(900*time.Millisecond), 1)
+	if err != nil || r.OK() {
+		t.Fatalf("expected the window to be full, got %v %v", r.OK(), err)
+	}
+	if retry := r.RetryAt().Sub(now); retry < time.Second || retry > time.Second+time.Millisecond {
+		t.Errorf("expected a retry once the first event expires, got %v", retry)
+	}
+	if ok, _ := sw.AllowN(ctx, "k", now.Add(time.Second+time.Millisecond), 1); !ok {
+		t.Error("expected room once the first event left the window")
+	}
+	if ok, _ := sw.AllowN(ctx, "other", now, 2); !ok {
+		t.Error("expected other keys to have separate windows")
+	}
+}
+
+func TestSlidingWindowCancel(t *testing.T) {
+	ctx := context.Background()
+	sw := NewSlidingWindow(newFakeRedis(), 2, time.Second)
+	now := time.Now()
+
+	r, _ := sw.ReserveN(ctx, "k", now, 2)
+	if !r.OK() {
+		t.Fatal("expected the reservation to be admitted")
+	}
+	if err := r.CancelAt(ctx, now); err != nil {
+		t.Fatalf("CancelAt: %v", err)
+	}
+	if ok, _ := sw.AllowN(ctx, "k", now, 2); !ok {
+		t.Error("expected Cancel to remove the reserved events")
+	}
+
+	if err := sw.WaitN(ctx, "k", 3); !errors.Is(err, rateflow.ErrExceedsBurst) {
+		t.Errorf("expected ErrExceedsBurst, got %v", err)
+	}
+}
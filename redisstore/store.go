@@ -0,0 +1,92 @@
+package redisstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// storeGetScript returns {found, value}. A script rather than GET, since
+// clients disagree on how to report a missing key
+var storeGetScript = newScript(`
+local v = redis.call('GET', KEYS[1])
+if v then
+	return {1, v}
+end
+return {0, 0}
+`)
+
+// storeAddScript sets a key with a TTL in milliseconds if it is missing
+var storeAddScript = newScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2], 'NX') then
+	return 1
+end
+return 0
+`)
+
+// storeCASScript sets a key with a TTL in milliseconds if it holds
+// ARGV[1]
+var storeCASScript = newScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+	return 1
+end
+return 0
+`)
+
+// Store is a rateflow.Store on Redis strings, e.g. for rateflow.GCRA.
+// Each call is one script, so it also works on Redis Cluster
+type Store struct {
+	client Client
+	cfg    config
+}
+
+var _ rateflow.Store = (*Store)(nil)
+
+// NewStore creates a store. WithIdleTTL does not apply; every key expires
+// after the ttl of its last write
+func NewStore(c Client, opts ...Option) *Store {
+	return &Store{client: c, cfg: newConfig(opts)}
+}
+
+// millis returns ttl in whole milliseconds, at least 1
+func millis(ttl time.Duration) int64 {
+	if ms := ttl.Milliseconds(); ms > 0 {
+		return ms
+	}
+	return 1
+}
+
+// Get returns the value of key
+func (s *Store) Get(ctx context.Context, key string) (int64, bool, error) {
+	reply, err := storeGetScript.run(ctx, s.client, []string{s.cfg.key(key)})
+	if err != nil {
+		return 0, false, err
+	}
+	v, err := replyInts(reply, 2)
+	if err != nil {
+		return 0, false, err
+	}
+	return v[1], v[0] == 1, nil
+}
+
+// Add sets key to value if it does not exist
+func (s *Store) Add(ctx context.Context, key string, value int64, ttl time.Duration) (bool, error) {
+	reply, err := storeAddScript.run(ctx, s.client, []string{s.cfg.key(key)}, value, millis(ttl))
+	if err != nil {
+		return false, err
+	}
+	ok, err := replyInt(reply)
+	return ok == 1, err
+}
+
+// CompareAndSwap sets key to new if it holds old
+func (s *Store) CompareAndSwap(ctx context.Context, key string, old, new int64, ttl time.Duration) (bool, error) {
+	reply, err := storeCASScript.run(ctx, s.client, []string{s.cfg.key(key)}, old, new, millis(ttl))
+	if err != nil {
+		return false, err
+	}
+	ok, err := replyInt(reply)
+	return ok == 1, err
+}
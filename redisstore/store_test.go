@@ -0,0 +1,53 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestStore(t *testing.T) {
+	r := newFakeRedis()
+	s := NewStore(r)
+	ctx := context.Background()
+
+	if _, ok, err := s.Get(ctx, "k"); ok || err != nil {
+		t.Fatalf("Get = %v, %v, want a missing key", ok, err)
+	}
+	if ok, err := s.Add(ctx, "k", 5, time.Minute); !ok || err != nil {
+		t.Fatalf("Add = %v, %v", ok, err)
+	}
+	if ok, _ := s.Add(ctx, "k", 6, time.Minute); ok {
+		t.Error("Add should not overwrite")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", 4, 7, time.Minute); ok {
+		t.Error("CompareAndSwap should fail for another value")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", 5, 7, 0); !ok {
+		t.Error("CompareAndSwap should replace the value it was given")
+	}
+	if v, ok, _ := s.Get(ctx, "k"); !ok || v != 7 {
+		t.Errorf("Get = %d, %v, want 7", v, ok)
+	}
+	if ttl := r.ttls["rateflow:{k}"]; ttl != 1 {
+		t.Errorf("ttl = %dms, want at least 1ms", ttl)
+	}
+}
+
+func TestStoreGCRA(t *testing.T) {
+	g := rateflow.NewGCRA(NewStore(newFakeRedis()), 10, 1)
+	ctx := context.Background()
+	now := time.Now()
+
+	if ok, err := g.AllowN(ctx, "k", now, 1); !ok || err != nil {
+		t.Fatalf("AllowN = %v, %v", ok, err)
+	}
+	if ok, _ := g.AllowN(ctx, "k", now, 1); ok {
+		t.Error("expected the burst to be used up")
+	}
+	if ok, _ := g.AllowN(ctx, "k", now.Add(100*time.Millisecond), 1); !ok {
+		t.Error("expected an event after 100ms")
+	}
+}
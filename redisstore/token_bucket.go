@@ -0,0 +1,234 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// tokenBucketExpiry is the Lua helper both bucket scripts use to keep a
+// bucket until it is full again, a second past the refill; a bucket that
+// never refills keeps the idle TTL
+const tokenBucketExpiry = `
+local function expiry(rate, burst, tokens, ttl)
+	if rate <= 0 then
+		return ttl
+	end
+	return string.format('%d', math.ceil((burst - tokens) * 1000 / rate) + 1000)
+end
+`
+
+// tokenBucketScript refills and consumes a bucket stored as a hash of
+// tokens and the time of the last refill in microseconds. It returns
+// {admitted, wait in microseconds}; a wait of -1 means never
+var tokenBucketScript = newScript(tokenBucketExpiry + `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local max_wait = tonumber(ARGV[5])
+local ttl = tonumber(ARGV[6])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) * rate / 1e6)
+	ts = now
+end
+
+if n > burst then
+	return {0, -1}
+end
+local left = tokens - n
+local wait = 0
+if left < 0 then
+	if rate <= 0 then
+		return {0, -1}
+	end
+	wait = math.ceil(-left * 1e6 / rate)
+end
+if max_wait >= 0 and wait > max_wait then
+	return {0, wait}
+end
+
+redis.call('HMSET', KEYS[1], 'tokens', tostring(left), 'ts', tostring(ts))
+redis.call('PEXPIRE', KEYS[1], expiry(rate, burst, left, ttl))
+return {1, wait}
+`)
+
+// tokenBucketRefundScript gives n tokens back to a bucket, up to its burst
+var tokenBucketRefundScript = newScript(tokenBucketExpiry + `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local ttl = tonumber(ARGV[5])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	return 0
+end
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) * rate / 1e6)
+	ts = now
+end
+tokens = math.min(burst, tokens + n)
+
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts))
+redis.call('PEXPIRE', KEYS[1], expiry(rate, burst, tokens, ttl))
+return 1
+`)
+
+// TokenBucket is a token bucket per key kept in Redis. Reservations may
+// run into debt like the local TokenBucket, so ReserveN can schedule
+// events in the future. Times come from the callers, so replicas should
+// keep their clocks in sync
+type TokenBucket struct {
+	client Client
+	cfg    config
+	limit  rateflow.Limit
+	burst  int
+}
+
+var _ rateflow.RemoteLimiter = (*TokenBucket)(nil)
+
+// NewTokenBucket creates a token bucket refilling at r tokens per second
+// up to b, for every key
+func NewTokenBucket(c Client, r rateflow.Limit, b int, opts ...Option) *TokenBucket {
+	return &TokenBucket{client: c, cfg: newConfig(opts), limit: r, burst: b}
+}
+
+// Limit returns the refill rate
+func (tb *TokenBucket) Limit() rateflow.Limit {
+	return tb.limit
+}
+
+// Burst returns the bucket size
+func (tb *TokenBucket) Burst() int {
+	return tb.burst
+}
+
+// ttl returns how long Redis keeps an untouched bucket that does not
+// refill, in milliseconds. The scripts keep refilling buckets until they
+// are full, debt included
+func (tb *TokenBucket) ttl() int64 {
+	return int64(tb.cfg.idleTTL / time.Millisecond)
+}
+
+// take runs the bucket script, refusing waits longer than maxWait; a
+// negative maxWait accepts any wait. A refused take returns the wait that
+// was refused, or -1 if n can never be admitted
+func (tb *TokenBucket) take(ctx context.Context, key string, t time.Time, n int, maxWait time.Duration) (bool, time.Duration, error) {
+	maxWaitUS := int64(-1)
+	if maxWait >= 0 {
+		maxWaitUS = maxWait.Microseconds()
+	}
+	reply, err := tokenBucketScript.run(ctx, tb.client, []string{tb.cfg.key(key)},
+		formatFloat(float64(tb.limit)), tb.burst, t.UnixMicro(), n, maxWaitUS, tb.ttl())
+	if err != nil {
+		return false, 0, err
+	}
+	return takeReply(reply)
+}
+
+// takeReply decodes the {admitted, wait} reply of the bucket script
+func takeReply(reply any) (bool, time.Duration, error) {
+	v, err := replyInts(reply, 2)
+	if err != nil {
+		return false, 0, err
+	}
+	wait := time.Duration(v[1]) * time.Microsecond
+	if v[1] < 0 {
+		wait = -1
+	}
+	return v[0] == 1, wait, nil
+}
+
+// AllowN reports whether n events for key may happen at t
+func (tb *TokenBucket) AllowN(ctx context.Context, key string, t time.Time, n int) (bool, error) {
+	ok, _, err := tb.take(ctx, key, t, n, 0)
+	return ok, err
+}
+
+// AllowNKeys is AllowN for many keys in one pipeline, e.g. to check a
+// batch of tenants at once. Scripts for different keys run on their own
+// and may land on different cluster nodes, so each key is admitted or
+// refused independently. Keys whose script failed report false and their
+// errors are joined
+func (tb *TokenBucket) AllowNKeys(ctx context.Context, keys []string, t time.Time, n int) ([]bool, error) {
+	redisKeys := make([][]string, len(keys))
+	args := make([][]any, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = []string{tb.cfg.key(key)}
+		args[i] = []any{formatFloat(float64(tb.limit)), tb.burst, t.UnixMicro(), n, 0, tb.ttl()}
+	}
+	return allowed(keys, tokenBucketScript.runMulti(ctx, tb.client, redisKeys, args))
+}
+
+// ReserveN reserves n events for key at t, possibly in the future. It is
+// not OK only if n exceeds the burst or the rate is zero
+func (tb *TokenBucket) ReserveN(ctx context.Context, key string, t time.Time, n int) (*rateflow.RemoteReservation, error) {
+	return tb.reserve(ctx, key, t, n, -1)
+}
+
+func (tb *TokenBucket) reserve(ctx context.Context, key string, t time.Time, n int, maxWait time.Duration) (*rateflow.RemoteReservation, error) {
+	ok, wait, err := tb.take(ctx, key, t, n, maxWait)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		var retry time.Time
+		if wait >= 0 {
+			retry = t.Add(wait)
+		}
+		return rateflow.NewRemoteReservation(false, retry, n, nil), nil
+	}
+
+	timeToAct := t.Add(wait)
+	cancel := func(ctx context.Context, now time.Time) error {
+		if !now.Before(timeToAct) {
+			return nil
+		}
+		_, err := tokenBucketRefundScript.run(ctx, tb.client, []string{tb.cfg.key(key)},
+			formatFloat(float64(tb.limit)), tb.burst, now.UnixMicro(), n, tb.ttl())
+		return err
+	}
+	return rateflow.NewRemoteReservation(true, timeToAct, n, cancel), nil
+}
+
+// WaitN blocks until n events for key are allowed or ctx is done. Like
+// the local limiters it fails fast, consuming nothing, when the wait
+// would outlast ctx's deadline
+func (tb *TokenBucket) WaitN(ctx context.Context, key string, n int) error {
+	maxWait := time.Duration(-1)
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = time.Until(deadline)
+		if maxWait < 0 {
+			maxWait = 0
+		}
+	}
+	now := time.Now()
+	r, err := tb.reserve(ctx, key, now, n, maxWait)
+	if err != nil {
+		return err
+	}
+	if !r.OK() {
+		if r.RetryAt().IsZero() {
+			if n > tb.burst {
+				return fmt.Errorf("%w: %d > %d", rateflow.ErrExceedsBurst, n, tb.burst)
+			}
+			return rateflow.ErrReservationNotOK
+		}
+		return &rateflow.RateLimitError{RetryAfter: r.RetryAt().Sub(now)}
+	}
+	return r.Act(ctx)
+}
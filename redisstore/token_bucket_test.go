@@ -0,0 +1,111 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestTokenBucket(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	tb := NewTokenBucket(redis, rateflow.Limit(10), 2)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, err := tb.AllowN(ctx, "alice", now, 1); !ok || err != nil {
+			t.Fatalf("expected the burst to be available, got %v %v", ok, err)
+		}
+	}
+	if ok, _ := tb.AllowN(ctx, "alice", now, 1); ok {
+		t.Error("expected alice to be limited")
+	}
+	if ok, _ := tb.AllowN(ctx, "bob", now, 1); !ok {
+		t.Error("expected bob to get a separate bucket")
+	}
+	if ok, _ := tb.AllowN(ctx, "alice", now.Add(100*time.Millisecond), 1); !ok {
+		t.Error("expected a token to refill after 100ms")
+	}
+	if !redis.loaded[tokenBucketScript.sha] {
+		t.Error("expected the script to be loaded after NOSCRIPT")
+	}
+	if _, ok := redis.hashes["rateflow:{alice}"]; !ok {
+		t.Error("expected state under the default prefix")
+	}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	ctx := context.Background()
+	tb := NewTokenBucket(newFakeRedis(), rateflow.Limit(10), 1, WithPrefix("test:"))
+	now := time.Now()
+
+	tb.AllowN(ctx, "k", now, 1)
+	r, err := tb.ReserveN(ctx, "k", now, 1)
+	if err != nil || !r.OK() {
+		t.Fatalf("expected a future reservation, got %v %v", r, err)
+	}
+	if d := r.DelayFrom(now); d != 100*time.Millisecond {
+		t.Errorf("expected a 100ms delay, got %v", d)
+	}
+	if err := r.CancelAt(ctx, now); err != nil {
+		t.Fatalf("CancelAt: %v", err)
+	}
+	if r, _ := tb.ReserveN(ctx, "k", now, 1); r.DelayFrom(now) != 100*time.Millisecond {
+		t.Errorf("expected Cancel to refund the reservation, got delay %v", r.DelayFrom(now))
+	}
+
+	if r, _ := tb.ReserveN(ctx, "k", now, 2); r.OK() || !r.RetryAt().IsZero() {
+		t.Error("expected a request over the burst to be refused for good")
+	}
+}
+
+func TestTokenBucketExpiry(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	tb := NewTokenBucket(redis, rateflow.Limit(1), 2)
+	now := time.Now()
+
+	tb.AllowN(ctx, "k", now, 1)
+	if ttl := redis.ttls["rateflow:{k}"]; ttl != 2000 {
+		t.Errorf("ttl = %dms, want the 1s refill plus 1s", ttl)
+	}
+	for i := 0; i < 3; i++ {
+		tb.ReserveN(ctx, "k", now, 1)
+	}
+	if ttl := redis.ttls["rateflow:{k}"]; ttl != 5000 {
+		t.Errorf("ttl = %dms, want a bucket in debt kept until it refills", ttl)
+	}
+
+	NewTokenBucket(redis, 0, 1, WithIdleTTL(time.Minute)).AllowN(ctx, "idle", now, 1)
+	if ttl := redis.ttls["rateflow:{idle}"]; ttl != 60000 {
+		t.Errorf("ttl = %dms, want the idle TTL for a zero rate", ttl)
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	tb := NewTokenBucket(newFakeRedis(), rateflow.Limit(100), 1)
+
+	if err := tb.WaitN(context.Background(), "k", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	start := time.Now()
+	if err := tb.WaitN(context.Background(), "k", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if waited := time.Since(start); waited < 5*time.Millisecond {
+		t.Errorf("expected to wait for a refill, waited %v", waited)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	var rle *rateflow.RateLimitError
+	if err := tb.WaitN(ctx, "k", 1); !errors.As(err, &rle) {
+		t.Errorf("expected a RateLimitError, got %v", err)
+	}
+	if err := tb.WaitN(context.Background(), "k", 5); !errors.Is(err, rateflow.ErrExceedsBurst) {
+		t.Errorf("expected ErrExceedsBurst, got %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// Region is one region sharing a RegionalQuota, with its weight: a
+// region of weight 2 gets twice the share of one of weight 1 while both
+// use all of it
+type Region struct {
+	Name   string
+	Weight float64
+}
+
+// RegionalConfig configures a RegionalQuota
+type RegionalConfig struct {
+	// Region is the name of the region this process runs in
+	Region string
+	// Regions lists every region, this one included
+	Regions []Region
+	// Limit and Burst are the global limit split between the regions
+	Limit Limit
+	Burst int
+	// Interval is how often usage is shared and the split recomputed,
+	// 10s if 0
+	Interval time.Duration
+	// MinShare is the fraction of its weighted share a region keeps
+	// however little it used, so a region waking up is not starved until
+	// the next reconcile. 0.1 if 0
+	MinShare float64
+}
+
+// RegionalQuota enforces this region's part of a global limit split
+// between regions by weight. Decisions are local; every Interval,
+// Reconcile publishes the events admitted here to a CounterStore shared
+// by the regions, reads what the others admitted over the last complete
+// interval, and moves the quota regions left unused to the regions that
+// used most of theirs. Every region computes the same split from the same
+// counts, so the shares keep adding up to the global limit
+type RegionalQuota struct {
+	store CounterStore
+	key   string
+	cfg   RegionalConfig
+	local Limiter
+
+	used atomic.Int64 // events admitted since the last reconcile
+
+	mu     sync.Mutex
+	shares map[string]float64
+
+	reconcileMu sync.Mutex
+	splitIdx    int64 // interval the shares were last computed from
+}
+
+// NewRegionalQuota creates the quota of cfg.Region for key, starting
+// from the split by weight. It fails for a region not in cfg.Regions, a
+// weight that is not positive, or a limit and burst a token bucket
+// would reject with a ConfigError
+func NewRegionalQuota(store CounterStore, key string, cfg RegionalConfig) (*RegionalQuota, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.MinShare <= 0 {
+		cfg.MinShare = 0.1
+	}
+	if err := limiter.Validate(TokenBucket, cfg.Limit, cfg.Burst); err != nil {
+		return nil, err
+	}
+	found := false
+	for _, r := range cfg.Regions {
+		if !(r.Weight > 0) {
+			return nil, fmt.Errorf("rateflow: region %q: weight must be positive", r.Name)
+		}
+		found = found || r.Name == cfg.Region
+	}
+	if !found {
+		return nil, fmt.Errorf("rateflow: region %q is not in Regions", cfg.Region)
+	}
+
+	q := &RegionalQuota{store: store, key: key, cfg: cfg, shares: weightShares(cfg.Regions)}
+	share := q.shares[cfg.Region]
+	q.local = NewLimiter(TokenBucket, cfg.Limit*Limit(share), shareBurst(cfg.Burst, share))
+	return q, nil
+}
+
+// weightShares returns the split of regions by weight
+func weightShares(regions []Region) map[string]float64 {
+	var total float64
+	for _, r := range regions {
+		total += r.Weight
+	}
+	shares := make(map[string]float64, len(regions))
+	for _, r := range regions {
+		shares[r.Name] = r.Weight / total
+	}
+	return shares
+}
+
+// shareBurst returns the part of burst a share gets, at least 1
+func shareBurst(burst int, share float64) int {
+	return int(math.Max(1, math.Round(float64(burst)*share)))
+}
+
+// Limiter returns the local limiter enforcing this region's share
+func (q *RegionalQuota) Limiter() Limiter {
+	return q.local
+}
+
+// Share returns this region's current fraction of the global limit
+func (q *RegionalQuota) Share() float64 {
+	return q.Shares()[q.cfg.Region]
+}
+
+// Shares returns the current fraction of the global limit of every region
+func (q *RegionalQuota) Shares() map[string]float64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	shares := make(map[string]float64, len(q.shares))
+	for name, s := range q.shares {
+		shares[name] = s
+	}
+	return shares
+}
+
+// Allow is shorthand for AllowN(time.Now(), 1)
+func (q *RegionalQuota) Allow() bool {
+	return q.AllowN(time.Now(), 1)
+}
+
+// AllowN reports whether n events may happen at t within this region's
+// share
+func (q *RegionalQuota) AllowN(t time.Time, n int) bool {
+	if !q.local.AllowN(t, n) {
+		return false
+	}
+	q.used.Add(int64(n))
+	return true
+}
+
+// Wait is shorthand for WaitN(ctx, 1)
+func (q *RegionalQuota) Wait(ctx context.Context) error {
+	return q.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n events are allowed within this region's share or
+// ctx is done
+func (q *RegionalQuota) WaitN(ctx context.Context, n int) error {
+	if err := q.local.WaitN(ctx, n); err != nil {
+		return err
+	}
+	q.used.Add(int64(n))
+	return nil
+}
+
+// counter returns the store key counting region's events in interval idx
+func (q *RegionalQuota) counter(region string, idx int64) string {
+	return q.key + ":" + region + ":" + strconv.FormatInt(idx, 10)
+}
+
+// Reconcile is shorthand for ReconcileAt(ctx, time.Now())
+func (q *RegionalQuota) Reconcile(ctx context.Context) error {
+	return q.ReconcileAt(ctx, time.Now())
+}
+
+// ReconcileAt publishes the events admitted here since the last call and,
+// once per interval, splits the limit again by what every region
+// admitted in the interval before the one around t. If the store fails,
+// the events are kept for the next call and the split left as it is
+func (q *RegionalQuota) ReconcileAt(ctx context.Context, t time.Time) error {
+	q.reconcileMu.Lock()
+	defer q.reconcileMu.Unlock()
+
+	interval := int64(q.cfg.Interval)
+	idx := t.UnixNano() / interval
+	// Counters are read one interval after they were written to
+	ttl := 3 * q.cfg.Interval
+
+	delta := q.used.Swap(0)
+	if _, err := q.store.IncrBy(ctx, q.counter(q.cfg.Region, idx), delta, ttl); err != nil {
+		q.used.Add(delta)
+		return err
+	}
+	if idx-1 == q.splitIdx {
+		// The shares were computed under the counts of that interval
+		// already; computing them again would compare the counts with
+		// shares that were not in effect when they were made
+		return nil
+	}
+
+	used := make(map[string]int64, len(q.cfg.Regions))
+	var errs []error
+	for _, r := range q.cfg.Regions {
+		n, err := q.store.IncrBy(ctx, q.counter(r.Name, idx-1), 0, ttl)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Name, err))
+		}
+		used[r.Name] = n
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	shares := q.split(used)
+	q.splitIdx = idx - 1
+	share := shares[q.cfg.Region]
+	q.mu.Lock()
+	q.shares = shares
+	q.mu.Unlock()
+	q.local.SetLimitAt(t, q.cfg.Limit*Limit(share))
+	q.local.SetBurstAt(t, shareBurst(q.cfg.Burst, share))
+	return nil
+}
+
+// hungry is the part of its share a region must use over an interval to
+// be given more
+const hungry = 0.9
+
+// split computes the shares from the events each region admitted over
+// one interval. A region that used most of its current share gets its
+// weighted share plus, by weight, what the other regions left: each of
+// those keeps twice what it used, at least MinShare and at most all of
+// its weighted share. With no hungry region the split stays by weight
+func (q *RegionalQuota) split(used map[string]int64) map[string]float64 {
+	base := weightShares(q.cfg.Regions)
+	capacity := float64(q.cfg.Limit) * q.cfg.Interval.Seconds()
+	if capacity <= 0 || math.IsInf(capacity, 0) {
+		return base
+	}
+	q.mu.Lock()
+	current := q.shares
+	q.mu.Unlock()
+
+	shares := make(map[string]float64, len(base))
+	var freed, hungryWeight float64
+	for _, r := range q.cfg.Regions {
+		demand := float64(used[r.Name]) / capacity
+		if demand >= hungry*current[r.Name] {
+			hungryWeight += r.Weight
+			continue
+		}
+		keep := math.Max(2*demand, q.cfg.MinShare*base[r.Name])
+		keep = math.Min(keep, base[r.Name])
+		shares[r.Name] = keep
+		freed += base[r.Name] - keep
+	}
+	if hungryWeight == 0 {
+		return base
+	}
+	for _, r := range q.cfg.Regions {
+		if _, ok := shares[r.Name]; !ok {
+			shares[r.Name] = base[r.Name] + freed*r.Weight/hungryWeight
+		}
+	}
+	return shares
+}
+
+// Run calls Reconcile every Interval until ctx is done, then returns
+// ctx.Err()
+func (q *RegionalQuota) Run(ctx context.Context) error {
+	ticker := time.NewTicker(q.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			q.Reconcile(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
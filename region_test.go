@@ -0,0 +1,118 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRegionalQuota(t *testing.T) {
+	store := newMemCounters()
+	ctx := context.Background()
+	cfg := RegionalConfig{
+		Regions:  []Region{{"eu", 1}, {"us", 1}},
+		Limit:    100,
+		Burst:    100,
+		Interval: 10 * time.Second,
+	}
+	cfg.Region = "eu"
+	eu, err := NewRegionalQuota(store, "api", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Region = "us"
+	us, _ := NewRegionalQuota(store, "api", cfg)
+	if eu.Share() != 0.5 || us.Limiter().Limit() != 50 || us.Limiter().Burst() != 50 {
+		t.Fatalf("initial split = %v, want by weight", eu.Shares())
+	}
+
+	interval := int64(10 * time.Second)
+	start := time.Unix(0, (time.Now().UnixNano()/interval+1)*interval)
+	admitted := 0
+	for i := 0; i < 900; i++ {
+		if us.AllowN(start.Add(time.Duration(i)*10*time.Millisecond), 1) {
+			admitted++
+		}
+	}
+	if admitted < 480 || admitted > 510 {
+		t.Fatalf("us admitted %d, want about its share of 9s", admitted)
+	}
+	eu.AllowN(start, 10)
+
+	publish := start.Add(9900 * time.Millisecond)
+	for _, q := range []*RegionalQuota{eu, us} {
+		if err := q.ReconcileAt(ctx, publish); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if eu.Share() != 0.5 {
+		t.Errorf("the split should only change once the interval is complete")
+	}
+	next := start.Add(10 * time.Second)
+	for _, q := range []*RegionalQuota{eu, us} {
+		q.ReconcileAt(ctx, next)
+	}
+
+	// eu keeps twice its usage, but at least a tenth of its share
+	if got := eu.Share(); math.Abs(got-0.05) > 1e-9 {
+		t.Errorf("eu share = %v, want 0.05", got)
+	}
+	if got := us.Shares()["us"]; math.Abs(got-0.95) > 1e-9 {
+		t.Errorf("us share = %v, want the quota eu left", got)
+	}
+	if got := us.Limiter().Limit(); math.Abs(float64(got)-95) > 1e-6 {
+		t.Errorf("us limit = %v, want 95", got)
+	}
+	if got := eu.Limiter().Burst(); got != 5 {
+		t.Errorf("eu burst = %d, want 5", got)
+	}
+
+	// Traffic moves to eu, which uses all of its small share and gets
+	// the quota back once us goes quiet
+	for i := 0; i < 900; i++ {
+		eu.AllowN(next.Add(time.Duration(i)*10*time.Millisecond), 1)
+	}
+	for _, at := range []time.Time{next.Add(9900 * time.Millisecond), next.Add(10 * time.Second)} {
+		for _, q := range []*RegionalQuota{eu, us} {
+			q.ReconcileAt(ctx, at)
+		}
+	}
+	if got := eu.Share(); math.Abs(got-0.95) > 1e-9 {
+		t.Errorf("eu share = %v, want 0.95", got)
+	}
+}
+
+func TestRegionalQuotaStoreError(t *testing.T) {
+	store := newMemCounters()
+	q, _ := NewRegionalQuota(store, "api", RegionalConfig{
+		Region: "eu", Regions: []Region{{"eu", 1}}, Limit: 10, Burst: 10,
+	})
+	now := time.Now()
+	q.AllowN(now, 3)
+
+	store.err = errors.New("store down")
+	if err := q.ReconcileAt(context.Background(), now); err == nil {
+		t.Fatal("expected the store error")
+	}
+	store.err = nil
+	q.ReconcileAt(context.Background(), now)
+	idx := now.UnixNano() / int64(10*time.Second)
+	if got := store.counts[q.counter("eu", idx)]; got != 3 {
+		t.Errorf("published %d events, want the ones kept from the failed call", got)
+	}
+}
+
+func TestRegionalQuotaConfig(t *testing.T) {
+	store := newMemCounters()
+	for name, cfg := range map[string]RegionalConfig{
+		"unknown region": {Region: "ap", Regions: []Region{{"eu", 1}}, Limit: 1, Burst: 1},
+		"zero weight":    {Region: "eu", Regions: []Region{{"eu", 0}}, Limit: 1, Burst: 1},
+		"no burst":       {Region: "eu", Regions: []Region{{"eu", 1}}, Limit: 1},
+	} {
+		if _, err := NewRegionalQuota(store, "k", cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package rateflow
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingLimiter is a custom algorithm used to exercise the registry
+type countingLimiter struct {
+	Limiter
+	calls int
+}
+
+func (c *countingLimiter) AllowN(t time.Time, n int) bool {
+	c.calls++
+	return c.Limiter.AllowN(t, n)
+}
+
+var countingAlgorithm = RegisterAlgorithm("Counting", func(r Limit, b int) Limiter {
+	return &countingLimiter{Limiter: NewLimiter(TokenBucket, r, b)}
+})
+
+func TestRegisterAlgorithm(t *testing.T) {
+	lim := NewLimiter(countingAlgorithm, Limit(10), 5)
+	if _, ok := lim.(*countingLimiter); !ok {
+		t.Fatalf("expected custom limiter, got %T", lim)
+	}
+
+	if countingAlgorithm.String() != "Counting" {
+		t.Errorf("expected name Counting, got %q", countingAlgorithm.String())
+	}
+
+	algo, ok := LookupAlgorithm("Counting")
+	if !ok || algo != countingAlgorithm {
+		t.Errorf("LookupAlgorithm returned %v, %v", algo, ok)
+	}
+}
+
+func TestRegisterAlgorithmBuiltins(t *testing.T) {
+	for _, algo := range []Algorithm{TokenBucket, LeakyBucket, SlidingWindow, FixedWindow, PriorityBucket, MultiWindow} {
+		lim := NewLimiter(algo, Limit(10), 5)
+		if lim.Algorithm() != algo {
+			t.Errorf("%s: NewLimiter built %s", algo, lim.Algorithm())
+		}
+		if found, ok := LookupAlgorithm(algo.String()); !ok || found != algo {
+			t.Errorf("%s: LookupAlgorithm returned %v, %v", algo, found, ok)
+		}
+	}
+}
+
+func TestRegisterAlgorithmDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic when registering a duplicate name")
+		}
+	}()
+	RegisterAlgorithm("TokenBucket", func(r Limit, b int) Limiter { return nil })
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	for name, want := range map[string]Algorithm{
+		"TokenBucket":    TokenBucket,
+		"token-bucket":   TokenBucket,
+		"SLIDING_WINDOW": SlidingWindow,
+		"dualrate":       DualRate,
+	} {
+		if got, err := ParseAlgorithm(name); err != nil || got != want {
+			t.Errorf("ParseAlgorithm(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+
+	if _, err := ParseAlgorithm("bogus"); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("expected ErrUnknownAlgorithm, got %v", err)
+	}
+}
+
+func TestAlgorithmText(t *testing.T) {
+	var cfg struct {
+		Algorithm Algorithm `json:"algorithm"`
+	}
+	if err := json.Unmarshal([]byte(`{"algorithm":"leaky_bucket"}`), &cfg); err != nil || cfg.Algorithm != LeakyBucket {
+		t.Fatalf("unexpected decode %v, %v", cfg.Algorithm, err)
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil || string(data) != `{"algorithm":"LeakyBucket"}` {
+		t.Errorf("unexpected encoding %s, %v", data, err)
+	}
+
+	if _, err := Algorithm(-1).MarshalText(); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("expected ErrUnknownAlgorithm for an unregistered value, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package rateflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRemainingAndResetAt(t *testing.T) {
+	for _, algo := range Algorithms()[:DualRate+1] {
+		lim := NewLimiter(algo, Limit(1), 3)
+
+		if r := lim.Remaining(); r < 2.99 {
+			t.Errorf("%s: expected 3 remaining on a fresh limiter, got %v", algo, r)
+		}
+		if reset := lim.ResetAt(); time.Until(reset) > time.Millisecond {
+			t.Errorf("%s: expected a fresh limiter to be reset already, got %v", algo, reset)
+		}
+
+		lim.AllowN(time.Now(), 3)
+		if r := lim.Remaining(); r > 0.1 {
+			t.Errorf("%s: expected nothing remaining, got %v", algo, r)
+		}
+		if reset := lim.ResetAt(); !reset.After(time.Now()) {
+			t.Errorf("%s: expected ResetAt in the future, got %v", algo, reset)
+		}
+	}
+}
+
+func TestRemainingNeverNegative(t *testing.T) {
+	lim := NewTokenBucketWithDebt(Limit(1), 2, 5)
+	lim.AllowN(time.Now(), 6)
+	if r := lim.Remaining(); r != 0 {
+		t.Errorf("expected 0 remaining while in debt, got %v", r)
+	}
+}
@@ -0,0 +1,150 @@
+package rateflow
+
+import (
+	"context"
+	"time"
+)
+
+// RemoteLimiter enforces limits shared by many processes through a store
+// such as Redis, one limit per key. Unlike Limiter every call goes to the
+// store, so calls take a context and can fail
+type RemoteLimiter interface {
+	// AllowN reports whether n events for key may happen at t,
+	// consuming them if so
+	AllowN(ctx context.Context, key string, t time.Time, n int) (bool, error)
+	// ReserveN reserves n events for key at t. The reservation is not OK
+	// if the events cannot be admitted, or, for stores that cannot
+	// schedule future events, not admitted at once
+	ReserveN(ctx context.Context, key string, t time.Time, n int) (*RemoteReservation, error)
+}
+
+// RemoteReservation is a reservation made by a RemoteLimiter
+type RemoteReservation struct {
+	ok        bool
+	timeToAct time.Time
+	tokens    int
+	cancel    func(ctx context.Context, t time.Time) error
+}
+
+// NewRemoteReservation is used by RemoteLimiter implementations to
+// report a reservation of n events that may act at timeToAct. A refused
+// reservation may carry the time a retry could succeed, or the zero time
+// if it never can. cancel gives the events back and may be nil if the
+// store cannot
+func NewRemoteReservation(ok bool, timeToAct time.Time, n int, cancel func(ctx context.Context, t time.Time) error) *RemoteReservation {
+	return &RemoteReservation{ok: ok, timeToAct: timeToAct, tokens: n, cancel: cancel}
+}
+
+// OK returns whether the reservation is valid
+func (r *RemoteReservation) OK() bool {
+	return r.ok
+}
+
+// TimeToAct returns when the reserved events may happen, or the zero time
+// for a reservation that is not OK
+func (r *RemoteReservation) TimeToAct() time.Time {
+	if !r.ok {
+		return time.Time{}
+	}
+	return r.timeToAct
+}
+
+// RetryAt returns when a refused reservation could be retried with
+// success, or the zero time if it never can or was not refused
+func (r *RemoteReservation) RetryAt() time.Time {
+	if r.ok {
+		return time.Time{}
+	}
+	return r.timeToAct
+}
+
+// Tokens returns the number of events reserved
+func (r *RemoteReservation) Tokens() int {
+	return r.tokens
+}
+
+// DelayFrom returns how long after t the reserved events may happen, or
+// -1 for a reservation that is not OK
+func (r *RemoteReservation) DelayFrom(t time.Time) time.Duration {
+	if !r.ok {
+		return -1
+	}
+	if d := r.timeToAct.Sub(t); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Cancel gives the reserved events back to the store, if it supports it
+func (r *RemoteReservation) Cancel(ctx context.Context) error {
+	return r.CancelAt(ctx, time.Now())
+}
+
+// CancelAt is like Cancel at time t
+func (r *RemoteReservation) CancelAt(ctx context.Context, t time.Time) error {
+	if !r.ok || r.cancel == nil {
+		return nil
+	}
+	cancel := r.cancel
+	r.cancel = nil
+	return cancel(ctx, t)
+}
+
+// Act blocks until the reservation's time to act. If ctx is done first,
+// or its deadline falls before that time, the reservation is cancelled
+// and the error returned
+func (r *RemoteReservation) Act(ctx context.Context) error {
+	if !r.ok {
+		return ErrReservationNotOK
+	}
+	delay := r.DelayFrom(time.Now())
+	if delay == 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		r.Cancel(context.Background())
+		return &RateLimitError{RetryAfter: delay}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel(context.Background())
+		return ctx.Err()
+	}
+}
+
+// WaitRemote blocks until lim admits n events for key or ctx is done. It
+// fails fast with a RateLimitError when the wait would outlast ctx's
+// deadline. Refused reservations that carry a time to act are retried
+// then, so stores that cannot schedule future events still wait
+func WaitRemote(ctx context.Context, lim RemoteLimiter, key string, n int) error {
+	for {
+		now := time.Now()
+		r, err := lim.ReserveN(ctx, key, now, n)
+		if err != nil {
+			return err
+		}
+		if r.OK() {
+			return r.Act(ctx)
+		}
+		if r.RetryAt().IsZero() {
+			return ErrReservationNotOK
+		}
+
+		delay := r.RetryAt().Sub(now)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return &RateLimitError{RetryAfter: delay}
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// windowStub admits one event per 20ms and cannot schedule future ones
+type windowStub struct {
+	next time.Time
+}
+
+func (s *windowStub) AllowN(ctx context.Context, key string, t time.Time, n int) (bool, error) {
+	r, err := s.ReserveN(ctx, key, t, n)
+	return r.OK(), err
+}
+
+func (s *windowStub) ReserveN(ctx context.Context, key string, t time.Time, n int) (*RemoteReservation, error) {
+	if n > 1 {
+		return NewRemoteReservation(false, time.Time{}, n, nil), nil
+	}
+	if t.Before(s.next) {
+		return NewRemoteReservation(false, s.next, n, nil), nil
+	}
+	s.next = t.Add(20 * time.Millisecond)
+	return NewRemoteReservation(true, t, n, nil), nil
+}
+
+func TestWaitRemote(t *testing.T) {
+	stub := &windowStub{}
+	ctx := context.Background()
+
+	if err := WaitRemote(ctx, stub, "k", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	start := time.Now()
+	if err := WaitRemote(ctx, stub, "k", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if waited := time.Since(start); waited < 15*time.Millisecond {
+		t.Errorf("expected to retry once the window reopened, waited %v", waited)
+	}
+
+	short, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	var rle *RateLimitError
+	if err := WaitRemote(short, stub, "k", 1); !errors.As(err, &rle) {
+		t.Errorf("expected a RateLimitError, got %v", err)
+	}
+	if err := WaitRemote(ctx, stub, "k", 2); !errors.Is(err, ErrReservationNotOK) {
+		t.Errorf("expected ErrReservationNotOK, got %v", err)
+	}
+}
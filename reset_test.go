@@ -0,0 +1,39 @@
+package rateflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReset(t *testing.T) {
+	for _, algo := range Algorithms()[:DualRate+1] {
+		lim := NewLimiter(algo, Limit(1), 3)
+		now := time.Now()
+
+		for lim.AllowN(now, 1) {
+		}
+		lim.ResetTo(now)
+
+		if !lim.AllowN(now, 3) {
+			t.Errorf("%s: expected full capacity after ResetTo", algo)
+		}
+		if s := lim.Stats(); s.Allowed == 0 {
+			t.Errorf("%s: expected Reset to keep stats, got %+v", algo, s)
+		}
+	}
+}
+
+func TestResetAdaptive(t *testing.T) {
+	lim := NewAdaptiveLimiter(Limit(100), 10)
+	for i := 0; i < 20; i++ {
+		lim.Observe(time.Second, true)
+	}
+	if lim.Limit() >= 100 {
+		t.Fatalf("expected drops to shrink the limit, got %v", lim.Limit())
+	}
+
+	lim.Reset()
+	if lim.Limit() != 100 {
+		t.Errorf("expected Reset to restore the ceiling, got %v", lim.Limit())
+	}
+}
@@ -0,0 +1,41 @@
+package rateflow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PrefixRules is a LimitProvider for hierarchical string keys such as
+// "api/v1/search" or "user:123". A pattern ending in "*" matches every key
+// starting with the rest of it, e.g. "api/v1/*"; any other pattern
+// matches only that key. The longest match wins and an exact match beats
+// a wildcard, so limits can be set for a whole route family and
+// overridden for single keys. "*" alone matches every key
+type PrefixRules map[string]LimitSpec
+
+// LimitFor returns the spec of the longest pattern matching key
+func (rs PrefixRules) LimitFor(key string) (LimitSpec, error) {
+	pattern, ok := rs.Match(key)
+	if !ok {
+		return LimitSpec{}, fmt.Errorf("%w: %s", ErrNoRuleMatches, key)
+	}
+	return rs[pattern], nil
+}
+
+// Match returns the pattern LimitFor picks for key, e.g. to keep state
+// per rule elsewhere, and false if no pattern matches
+func (rs PrefixRules) Match(key string) (string, bool) {
+	if _, ok := rs[key]; ok && !strings.HasSuffix(key, "*") {
+		return key, true
+	}
+
+	best, match := -1, ""
+	for pattern := range rs {
+		prefix, wildcard := strings.CutSuffix(pattern, "*")
+		if !wildcard || !strings.HasPrefix(key, prefix) || len(prefix) <= best {
+			continue
+		}
+		best, match = len(prefix), pattern
+	}
+	return match, best >= 0
+}
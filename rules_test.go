@@ -0,0 +1,47 @@
+package rateflow
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPrefixRules(t *testing.T) {
+	rules := PrefixRules{
+		"api/*":           {Algorithm: TokenBucket, Limit: 100, Burst: 100},
+		"api/v1/*":        {Algorithm: TokenBucket, Limit: 10, Burst: 10},
+		"api/v1/search":   {Algorithm: TokenBucket, Limit: 1, Burst: 1},
+		"user:*":          {Algorithm: SlidingWindow, Limit: 5, Burst: 5},
+		"user:123":        {Algorithm: SlidingWindow, Limit: 50, Burst: 50},
+		"api/v1/search/*": {Algorithm: TokenBucket, Limit: 2, Burst: 2},
+	}
+
+	for key, want := range map[string]int{
+		"api/v2/users":         100,
+		"api/v1/users":         10,
+		"api/v1/search":        1,
+		"api/v1/search/images": 2,
+		"user:7":               5,
+		"user:123":             50,
+	} {
+		spec, err := rules.LimitFor(key)
+		if err != nil || spec.Burst != want {
+			t.Errorf("%s: expected burst %d, got %d (%v)", key, want, spec.Burst, err)
+		}
+	}
+
+	if _, err := rules.LimitFor("admin"); !errors.Is(err, ErrNoRuleMatches) {
+		t.Errorf("expected ErrNoRuleMatches, got %v", err)
+	}
+	if pattern, ok := rules.Match("api/v1/search/images"); !ok || pattern != "api/v1/search/*" {
+		t.Errorf("Match = %q, %v, want api/v1/search/*", pattern, ok)
+	}
+	rules["*"] = LimitSpec{Algorithm: TokenBucket, Limit: 1, Burst: 3}
+	if spec, _ := rules.LimitFor("admin"); spec.Burst != 3 {
+		t.Errorf("expected the catch-all rule, got burst %d", spec.Burst)
+	}
+
+	k := NewKeyedProvider[string](rules, LimitSpec{})
+	if k.Get("api/v1/a") == k.Get("api/v1/b") {
+		t.Error("expected every key in a family to get its own limiter")
+	}
+}
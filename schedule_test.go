@@ -0,0 +1,78 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScheduleAt(t *testing.T) {
+	sched, err := NewSchedule(nil,
+		ScheduleRule{Spec: "08:00", Limit: 100, Burst: 20},
+		ScheduleRule{Spec: "0 22 * * 1-5", Limit: 10, Burst: 5},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		at    time.Time
+		limit Limit
+	}{
+		{time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), 100}, // Monday noon
+		{time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC), 10},  // Monday night
+		{time.Date(2024, 1, 2, 7, 59, 0, 0, time.UTC), 10},  // before Tuesday's switch
+		{time.Date(2024, 1, 6, 23, 0, 0, 0, time.UTC), 100}, // no batch on Saturday
+	}
+	for _, tt := range tests {
+		rule, ok := sched.At(tt.at)
+		if !ok || rule.Limit != tt.limit {
+			t.Errorf("At(%v) = %v, %v, want limit %v", tt.at, rule, ok, tt.limit)
+		}
+	}
+
+	if next := sched.Next(time.Date(2024, 1, 5, 23, 0, 0, 0, time.UTC)); !next.Equal(time.Date(2024, 1, 6, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected next switch %v", next)
+	}
+}
+
+func TestScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{"", "25:00", "noon", "* * * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := NewSchedule(nil, ScheduleRule{Spec: spec}); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("%q: expected ErrInvalidSchedule, got %v", spec, err)
+		}
+	}
+}
+
+func TestScheduleRun(t *testing.T) {
+	clock := newFakeClock() // Monday 2024-01-01 00:00 UTC
+	lim := NewLimiterWithOptions(TokenBucket, Limit(1), 1, WithClock(clock))
+	sched, err := NewSchedule(nil,
+		ScheduleRule{Spec: "08:00", Limit: 100, Burst: 20},
+		ScheduleRule{Spec: "22:00", Limit: 10},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- sched.Run(ctx, lim) }()
+
+	clock.blockUntil(1)
+	if lim.Limit() != 10 || lim.Burst() != 1 {
+		t.Errorf("expected the night rule at start, got %v/%d", lim.Limit(), lim.Burst())
+	}
+
+	clock.Advance(8 * time.Hour)
+	clock.blockUntil(1)
+	if lim.Limit() != 100 || lim.Burst() != 20 {
+		t.Errorf("expected the day rule at 08:00, got %v/%d", lim.Limit(), lim.Burst())
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected Run to stop with context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// Config is the configuration of a Server, read from a JSON file such as
+//
+//	{
+//		"listen": ":8080",
+//		"grpc_listen": ":9090",
+//		"redis": {"addr": "localhost:6379"},
+//		"max_keys": 100000,
+//		"idle_ttl": "10m",
+//		"rules": {
+//			"api/*": {"algorithm": "token_bucket", "limit": 100, "burst": 200},
+//			"login:*": {"algorithm": "sliding_window", "burst": 5, "window": "1m"}
+//		}
+//	}
+//
+// Rules are PrefixRules patterns. Keys no rule matches are not limited
+type Config struct {
+	// Listen is the HTTP address, ":8080" by default
+	Listen string `json:"listen"`
+	// GRPCListen is the gRPC address; without it rateflowd serves only
+	// HTTP
+	GRPCListen string `json:"grpc_listen,omitempty"`
+	// Redis shares the limits with every server using the same Redis.
+	// Without it each server enforces its own limits in memory
+	Redis *RedisConfig `json:"redis,omitempty"`
+	// MaxKeys bounds the keys kept in memory, 0 for no bound
+	MaxKeys int `json:"max_keys,omitempty"`
+	// IdleTTL drops the limiters of keys unused for that long, 0 to keep
+	// them until MaxKeys evicts them
+	IdleTTL Duration        `json:"idle_ttl,omitempty"`
+	Rules   map[string]Rule `json:"rules"`
+}
+
+// RedisConfig locates the Redis server holding shared limits
+type RedisConfig struct {
+	Addr     string `json:"addr"`
+	Password string `json:"password,omitempty"`
+	DB       int    `json:"db,omitempty"`
+	// Prefix defaults to "rateflow:"
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// Rule is the limit of the keys matching one pattern
+type Rule struct {
+	Algorithm rateflow.Algorithm `json:"algorithm"`
+	// Limit is the rate in events per second. With a Window it may be
+	// left out and is then Burst per Window
+	Limit float64 `json:"limit,omitempty"`
+	Burst int     `json:"burst"`
+	// Window is the window of the window algorithms
+	Window Duration `json:"window,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "1m30s"
+type Duration time.Duration
+
+// MarshalText encodes d like time.Duration.String
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText decodes d with time.ParseDuration
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// LoadConfig reads and validates the configuration file at path
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig decodes and validates a JSON configuration. Unknown fields
+// are rejected, so typos do not silently drop a limit
+func ParseConfig(data []byte) (*Config, error) {
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("server: config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate reports the first problem with c, such as a rule with no burst
+// or one Redis cannot enforce
+func (c *Config) Validate() error {
+	if c.MaxKeys < 0 || c.IdleTTL < 0 {
+		return errors.New("server: config: max_keys and idle_ttl must not be negative")
+	}
+	if c.Redis != nil && c.Redis.Addr == "" {
+		return errors.New("server: config: redis needs an addr")
+	}
+	for pattern, r := range c.Rules {
+		if err := r.validate(c.Redis != nil); err != nil {
+			return fmt.Errorf("server: config: rule %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+func (r Rule) validate(redis bool) error {
+	switch {
+	case r.Burst <= 0:
+		return errors.New("burst must be positive")
+	case r.Limit < 0 || r.Window < 0:
+		return errors.New("limit and window must not be negative")
+	case r.Limit == 0 && r.Window == 0:
+		return errors.New("needs a limit or a window")
+	}
+	if redis && r.Algorithm != rateflow.TokenBucket && r.Algorithm != rateflow.SlidingWindow {
+		return fmt.Errorf("%v is not available with redis", r.Algorithm)
+	}
+	return nil
+}
+
+// window returns the window of r, derived from its rate if not set
+func (r Rule) window() time.Duration {
+	if r.Window > 0 {
+		return time.Duration(r.Window)
+	}
+	return time.Duration(float64(r.Burst) / r.Limit * float64(time.Second))
+}
+
+// spec returns the LimitSpec of the in-memory limiters for r
+func (r Rule) spec() rateflow.LimitSpec {
+	spec := rateflow.LimitSpec{Algorithm: r.Algorithm, Limit: rateflow.Limit(r.Limit), Burst: r.Burst}
+	if r.Window > 0 {
+		spec.Options = []rateflow.Option{rateflow.WithWindow(time.Duration(r.Window))}
+		if r.Limit == 0 {
+			spec.Limit = rateflow.Limit(float64(r.Burst) / time.Duration(r.Window).Seconds())
+		}
+	}
+	return spec
+}
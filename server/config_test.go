@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+const sampleConfig = `{
+	"listen": ":9090",
+	"max_keys": 1000,
+	"idle_ttl": "10m",
+	"rules": {
+		"api/*": {"algorithm": "token_bucket", "limit": 100, "burst": 200},
+		"login:*": {"algorithm": "sliding-window", "burst": 5, "window": "1m"}
+	}
+}`
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rateflowd.json")
+	if err := os.WriteFile(path, []byte(sampleConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Listen != ":9090" || cfg.MaxKeys != 1000 || time.Duration(cfg.IdleTTL) != 10*time.Minute {
+		t.Errorf("config = %+v", cfg)
+	}
+	login := cfg.Rules["login:*"]
+	if login.Algorithm != rateflow.SlidingWindow || time.Duration(login.Window) != time.Minute {
+		t.Errorf("login rule = %+v", login)
+	}
+	if spec := login.spec(); spec.Limit != rateflow.Limit(5.0/60) || len(spec.Options) != 1 {
+		t.Errorf("spec = %+v, want a rate of 5 per minute and the window", spec)
+	}
+
+	data, _ := json.Marshal(cfg)
+	if !strings.Contains(string(data), `"idle_ttl":"10m0s"`) {
+		t.Errorf("durations should marshal as strings: %s", data)
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"unknown field":  `{"rule": {}}`,
+		"bad duration":   `{"idle_ttl": "soon"}`,
+		"no burst":       `{"rules": {"a": {"limit": 1}}}`,
+		"no rate":        `{"rules": {"a": {"burst": 1}}}`,
+		"redis addr":     `{"redis": {}}`,
+		"redis algo":     `{"redis": {"addr": "x:6379"}, "rules": {"a": {"algorithm": "leaky_bucket", "limit": 1, "burst": 1}}}`,
+		"bad algorithm":  `{"rules": {"a": {"algorithm": "magic", "limit": 1, "burst": 1}}}`,
+		"negative limit": `{"rules": {"a": {"limit": -1, "burst": 1}}}`,
+	} {
+		if _, err := ParseConfig([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"github.com/mehmet-f-dogan/rateflow/server/rateflowpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Register serves Check and Report on srv as the rateflow.v1.RateFlow
+// gRPC service of rateflowpb. Refused hits are a Decision like over
+// HTTP, not an error; store errors fail with UNAVAILABLE so clients can
+// fall back
+func (s *Server) Register(srv grpc.ServiceRegistrar) {
+	rateflowpb.RegisterRateFlowServer(srv, grpcServer{s: s})
+}
+
+// grpcServer adapts a Server to the generated RateFlowServer
+type grpcServer struct {
+	rateflowpb.UnimplementedRateFlowServer
+	s *Server
+}
+
+func (g grpcServer) Check(ctx context.Context, in *rateflowpb.CheckRequest) (*rateflowpb.Decision, error) {
+	return serveGRPC(ctx, g.s.Check, in)
+}
+
+func (g grpcServer) Report(ctx context.Context, in *rateflowpb.CheckRequest) (*rateflowpb.Decision, error) {
+	return serveGRPC(ctx, g.s.Report, in)
+}
+
+// serveGRPC runs Check or Report for in, like serve does over HTTP
+func serveGRPC(ctx context.Context, decide func(ctx context.Context, key string, hits int) (Decision, error), in *rateflowpb.CheckRequest) (*rateflowpb.Decision, error) {
+	hits := in.GetHits()
+	if hits < 0 {
+		return nil, status.Error(codes.InvalidArgument, "hits must not be negative")
+	}
+	if hits == 0 {
+		hits = 1
+	}
+	d, err := decide(ctx, in.GetKey(), int(hits))
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return DecisionToProto(d), nil
+}
+
+// DecisionToProto converts d to its message
+func DecisionToProto(d Decision) *rateflowpb.Decision {
+	out := &rateflowpb.Decision{Allowed: d.Allowed, Limit: int64(d.Limit), Remaining: int64(d.Remaining)}
+	if !d.ResetAt.IsZero() {
+		out.ResetAt = timestamppb.New(d.ResetAt)
+	}
+	if wait := time.Duration(d.RetryAfter); wait != rateflow.InfDuration {
+		out.RetryAfter = durationpb.New(wait)
+	}
+	return out
+}
+
+// DecisionFromProto converts a message back to a Decision
+func DecisionFromProto(in *rateflowpb.Decision) Decision {
+	d := Decision{Allowed: in.GetAllowed(), Limit: int(in.GetLimit()), Remaining: int(in.GetRemaining())}
+	if in.ResetAt != nil {
+		d.ResetAt = in.ResetAt.AsTime()
+	}
+	if in.RetryAfter != nil {
+		d.RetryAfter = Duration(in.RetryAfter.AsDuration())
+	} else {
+		d.RetryAfter = Duration(rateflow.InfDuration)
+	}
+	return d
+}
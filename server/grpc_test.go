@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/mehmet-f-dogan/rateflow/server/rateflowpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func dialGRPC(t *testing.T, s *Server) rateflowpb.RateFlowClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer()
+	s.Register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return rateflowpb.NewRateFlowClient(conn)
+}
+
+func TestGRPC(t *testing.T) {
+	client := dialGRPC(t, newServer(t, `{"rules": {"api/*": {"limit": 1, "burst": 2}}}`))
+	ctx := context.Background()
+
+	d, err := client.Check(ctx, &rateflowpb.CheckRequest{Key: "api/users"})
+	if err != nil || !d.Allowed || d.Limit != 2 || d.Remaining != 1 {
+		t.Fatalf("Check = %v, %v, want allowed with 1 remaining", d, err)
+	}
+	if _, err := client.Report(ctx, &rateflowpb.CheckRequest{Key: "api/users", Hits: 2}); err != nil {
+		t.Fatal(err)
+	}
+	d, err = client.Check(ctx, &rateflowpb.CheckRequest{Key: "api/users"})
+	if err != nil || d.Allowed || d.RetryAfter.AsDuration() <= 0 {
+		t.Errorf("Check = %v, %v, want refused with a retry delay", d, err)
+	}
+	if dec := DecisionFromProto(d); dec.Allowed || dec.Limit != 2 || dec.RetryAfter <= 0 {
+		t.Errorf("DecisionFromProto = %+v", dec)
+	}
+
+	_, err = client.Check(ctx, &rateflowpb.CheckRequest{Key: "api/users", Hits: -1})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("negative hits: %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Handler serves Check and Report as JSON over HTTP, plus /healthz
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/check", s.serve(s.Check))
+	mux.HandleFunc("/v1/report", s.serve(s.Report))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// serve adapts Check or Report to an HTTP handler. Decisions that are not
+// allowed get status 429 and a Retry-After in whole seconds; store errors
+// get 503 so clients can fall back
+func (s *Server) serve(decide func(ctx context.Context, key string, hits int) (Decision, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var in CheckRequest
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if in.Hits < 0 {
+			http.Error(w, "hits must not be negative", http.StatusBadRequest)
+			return
+		}
+		if in.Hits == 0 {
+			in.Hits = 1
+		}
+
+		d, err := decide(req.Context(), in.Key, in.Hits)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !d.Allowed {
+			wait := time.Duration(d.RetryAfter)
+			secs := int64(wait / time.Second)
+			if wait%time.Second != 0 {
+				secs++
+			}
+			w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+		json.NewEncoder(w).Encode(d)
+	}
+}
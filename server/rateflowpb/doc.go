@@ -0,0 +1,5 @@
+// Package rateflowpb is the generated code of rateflow.proto, the gRPC
+// API of rateflowd
+package rateflowpb
+
+//go:generate buf generate --template buf.gen.yaml
@@ -0,0 +1,238 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: rateflow.proto
+
+package rateflowpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// CheckRequest asks for hits events of key, 1 if hits is 0
+type CheckRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Hits          int64                  `protobuf:"varint,2,opt,name=hits,proto3" json:"hits,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckRequest) Reset() {
+	*x = CheckRequest{}
+	mi := &file_rateflow_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckRequest) ProtoMessage() {}
+
+func (x *CheckRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rateflow_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckRequest.ProtoReflect.Descriptor instead.
+func (*CheckRequest) Descriptor() ([]byte, []int) {
+	return file_rateflow_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CheckRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *CheckRequest) GetHits() int64 {
+	if x != nil {
+		return x.Hits
+	}
+	return 0
+}
+
+// Decision is the answer to a check or report
+type Decision struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Allowed bool                   `protobuf:"varint,1,opt,name=allowed,proto3" json:"allowed,omitempty"`
+	// limit is the number of events the key may have at once, 0 if the key
+	// is not limited
+	Limit int64 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	// remaining is the number of events still admitted now, or -1 when the
+	// limit is kept in Redis
+	Remaining int64 `protobuf:"varint,3,opt,name=remaining,proto3" json:"remaining,omitempty"`
+	// reset_at is when the key is back to its full limit, unset if that is
+	// not known
+	ResetAt *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=reset_at,json=resetAt,proto3" json:"reset_at,omitempty"`
+	// retry_after is how long to wait before the hits would be allowed,
+	// unset if they never can be
+	RetryAfter    *durationpb.Duration `protobuf:"bytes,5,opt,name=retry_after,json=retryAfter,proto3" json:"retry_after,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Decision) Reset() {
+	*x = Decision{}
+	mi := &file_rateflow_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Decision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Decision) ProtoMessage() {}
+
+func (x *Decision) ProtoReflect() protoreflect.Message {
+	mi := &file_rateflow_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Decision.ProtoReflect.Descriptor instead.
+func (*Decision) Descriptor() ([]byte, []int) {
+	return file_rateflow_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Decision) GetAllowed() bool {
+	if x != nil {
+		return x.Allowed
+	}
+	return false
+}
+
+func (x *Decision) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *Decision) GetRemaining() int64 {
+	if x != nil {
+		return x.Remaining
+	}
+	return 0
+}
+
+func (x *Decision) GetResetAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ResetAt
+	}
+	return nil
+}
+
+func (x *Decision) GetRetryAfter() *durationpb.Duration {
+	if x != nil {
+		return x.RetryAfter
+	}
+	return nil
+}
+
+var File_rateflow_proto protoreflect.FileDescriptor
+
+const file_rateflow_proto_rawDesc = "" +
+	"\n" +
+	"\x0erateflow.proto\x12\vrateflow.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"4\n" +
+	"\fCheckRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x12\n" +
+	"\x04hits\x18\x02 \x01(\x03R\x04hits\"\xcb\x01\n" +
+	"\bDecision\x12\x18\n" +
+	"\aallowed\x18\x01 \x01(\bR\aallowed\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x03R\x05limit\x12\x1c\n" +
+	"\tremaining\x18\x03 \x01(\x03R\tremaining\x125\n" +
+	"\breset_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\aresetAt\x12:\n" +
+	"\vretry_after\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\n" +
+	"retryAfter2\x81\x01\n" +
+	"\bRateFlow\x129\n" +
+	"\x05Check\x12\x19.rateflow.v1.CheckRequest\x1a\x15.rateflow.v1.Decision\x12:\n" +
+	"\x06Report\x12\x19.rateflow.v1.CheckRequest\x1a\x15.rateflow.v1.DecisionB6Z4github.com/mehmet-f-dogan/rateflow/server/rateflowpbb\x06proto3"
+
+var (
+	file_rateflow_proto_rawDescOnce sync.Once
+	file_rateflow_proto_rawDescData []byte
+)
+
+func file_rateflow_proto_rawDescGZIP() []byte {
+	file_rateflow_proto_rawDescOnce.Do(func() {
+		file_rateflow_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_rateflow_proto_rawDesc), len(file_rateflow_proto_rawDesc)))
+	})
+	return file_rateflow_proto_rawDescData
+}
+
+var file_rateflow_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_rateflow_proto_goTypes = []any{
+	(*CheckRequest)(nil),          // 0: rateflow.v1.CheckRequest
+	(*Decision)(nil),              // 1: rateflow.v1.Decision
+	(*timestamppb.Timestamp)(nil), // 2: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),   // 3: google.protobuf.Duration
+}
+var file_rateflow_proto_depIdxs = []int32{
+	2, // 0: rateflow.v1.Decision.reset_at:type_name -> google.protobuf.Timestamp
+	3, // 1: rateflow.v1.Decision.retry_after:type_name -> google.protobuf.Duration
+	0, // 2: rateflow.v1.RateFlow.Check:input_type -> rateflow.v1.CheckRequest
+	0, // 3: rateflow.v1.RateFlow.Report:input_type -> rateflow.v1.CheckRequest
+	1, // 4: rateflow.v1.RateFlow.Check:output_type -> rateflow.v1.Decision
+	1, // 5: rateflow.v1.RateFlow.Report:output_type -> rateflow.v1.Decision
+	4, // [4:6] is the sub-list for method output_type
+	2, // [2:4] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_rateflow_proto_init() }
+func file_rateflow_proto_init() {
+	if File_rateflow_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_rateflow_proto_rawDesc), len(file_rateflow_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_rateflow_proto_goTypes,
+		DependencyIndexes: file_rateflow_proto_depIdxs,
+		MessageInfos:      file_rateflow_proto_msgTypes,
+	}.Build()
+	File_rateflow_proto = out.File
+	file_rateflow_proto_goTypes = nil
+	file_rateflow_proto_depIdxs = nil
+}
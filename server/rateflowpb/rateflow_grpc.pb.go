@@ -0,0 +1,171 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.0
+// - protoc             (unknown)
+// source: rateflow.proto
+
+package rateflowpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	RateFlow_Check_FullMethodName  = "/rateflow.v1.RateFlow/Check"
+	RateFlow_Report_FullMethodName = "/rateflow.v1.RateFlow/Report"
+)
+
+// RateFlowClient is the client API for RateFlow service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RateFlow is the check and report API of rateflowd, served by package
+// server and called by package client
+type RateFlowClient interface {
+	// Check decides whether hits for a key may happen now and charges them
+	// if so
+	Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*Decision, error)
+	// Report charges hits that already happened
+	Report(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*Decision, error)
+}
+
+type rateFlowClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRateFlowClient(cc grpc.ClientConnInterface) RateFlowClient {
+	return &rateFlowClient{cc}
+}
+
+func (c *rateFlowClient) Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*Decision, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Decision)
+	err := c.cc.Invoke(ctx, RateFlow_Check_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rateFlowClient) Report(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*Decision, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Decision)
+	err := c.cc.Invoke(ctx, RateFlow_Report_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RateFlowServer is the server API for RateFlow service.
+// All implementations must embed UnimplementedRateFlowServer
+// for forward compatibility.
+//
+// RateFlow is the check and report API of rateflowd, served by package
+// server and called by package client
+type RateFlowServer interface {
+	// Check decides whether hits for a key may happen now and charges them
+	// if so
+	Check(context.Context, *CheckRequest) (*Decision, error)
+	// Report charges hits that already happened
+	Report(context.Context, *CheckRequest) (*Decision, error)
+	mustEmbedUnimplementedRateFlowServer()
+}
+
+// UnimplementedRateFlowServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRateFlowServer struct{}
+
+func (UnimplementedRateFlowServer) Check(context.Context, *CheckRequest) (*Decision, error) {
+	return nil, status.Error(codes.Unimplemented, "method Check not implemented")
+}
+func (UnimplementedRateFlowServer) Report(context.Context, *CheckRequest) (*Decision, error) {
+	return nil, status.Error(codes.Unimplemented, "method Report not implemented")
+}
+func (UnimplementedRateFlowServer) mustEmbedUnimplementedRateFlowServer() {}
+func (UnimplementedRateFlowServer) testEmbeddedByValue()                  {}
+
+// UnsafeRateFlowServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RateFlowServer will
+// result in compilation errors.
+type UnsafeRateFlowServer interface {
+	mustEmbedUnimplementedRateFlowServer()
+}
+
+func RegisterRateFlowServer(s grpc.ServiceRegistrar, srv RateFlowServer) {
+	// If the following call panics, it indicates UnimplementedRateFlowServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RateFlow_ServiceDesc, srv)
+}
+
+func _RateFlow_Check_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RateFlowServer).Check(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RateFlow_Check_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RateFlowServer).Check(ctx, req.(*CheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RateFlow_Report_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RateFlowServer).Report(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RateFlow_Report_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RateFlowServer).Report(ctx, req.(*CheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RateFlow_ServiceDesc is the grpc.ServiceDesc for RateFlow service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RateFlow_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rateflow.v1.RateFlow",
+	HandlerType: (*RateFlowServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Check",
+			Handler:    _RateFlow_Check_Handler,
+		},
+		{
+			MethodName: "Report",
+			Handler:    _RateFlow_Report_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "rateflow.proto",
+}
@@ -0,0 +1,217 @@
+// Package server is the rate limiting service run by cmd/rateflowd: keyed
+// limiters configured by prefix rules, in memory or shared through Redis,
+// behind check and report calls.
+//
+// Check decides whether hits for a key may happen now and charges them if
+// so. Report charges hits that already happened, e.g. bytes counted once
+// a response is sent. Handler serves both as JSON over HTTP:
+//
+//	POST /v1/check  {"key": "api/users", "hits": 1}
+//	POST /v1/report {"key": "api/users", "hits": 512}
+//
+// answering with a Decision, status 429 and Retry-After when it is not
+// allowed. Register serves them over gRPC as the RateFlow service of
+// rateflowpb, whose rateflow.proto declares the messages. The package is
+// a module of its own, so only programs using it depend on gRPC.
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"github.com/mehmet-f-dogan/rateflow/redisstore"
+)
+
+// CheckRequest asks for Hits events of Key, 1 if Hits is 0
+type CheckRequest struct {
+	Key  string `json:"key"`
+	Hits int    `json:"hits,omitempty"`
+}
+
+// Decision is the answer to a check or report
+type Decision struct {
+	Allowed bool `json:"allowed"`
+	// Limit is the number of events the key may have at once, 0 if the
+	// key is not limited
+	Limit int `json:"limit"`
+	// Remaining is the number of events still admitted now, or -1 when
+	// the limit is kept in Redis, which does not report it
+	Remaining int `json:"remaining"`
+	// ResetAt is when the key is back to its full limit, or the zero time
+	// if that is not known
+	ResetAt time.Time `json:"reset_at"`
+	// RetryAfter is how long to wait before the hits would be allowed
+	RetryAfter Duration `json:"retry_after,omitempty"`
+}
+
+// Server decides checks and reports with the limits of a Config
+type Server struct {
+	store redisstore.Client
+	keyed *rateflow.Keyed[string]
+
+	mu      sync.RWMutex
+	rules   map[string]Rule
+	prefix  rateflow.PrefixRules
+	remotes map[string]rateflow.RemoteLimiter
+}
+
+// New creates a Server enforcing cfg. With cfg.Redis the limits are kept
+// in store, which must be connected to that Redis; otherwise store is
+// ignored and may be nil
+func New(cfg *Config, store redisstore.Client) (*Server, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Redis != nil && store == nil {
+		return nil, errors.New("server: redis is configured but no store was given")
+	}
+	s := &Server{keyed: rateflow.NewKeyedProvider[string](rateflow.PrefixRules{}, rateflow.LimitSpec{})}
+	if cfg.Redis != nil {
+		s.store = store
+	}
+	if err := s.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload applies the rules and key bounds of cfg. Keys whose algorithm
+// is unchanged keep their usage. Listen and Redis take effect only on a
+// new Server
+func (s *Server) Reload(cfg *Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if (cfg.Redis != nil) != (s.store != nil) {
+		return errors.New("server: reload cannot switch between memory and redis")
+	}
+
+	prefix := make(rateflow.PrefixRules, len(cfg.Rules))
+	for pattern, r := range cfg.Rules {
+		prefix[pattern] = r.spec()
+	}
+	var remotes map[string]rateflow.RemoteLimiter
+	if s.store != nil {
+		remotes = make(map[string]rateflow.RemoteLimiter, len(cfg.Rules))
+		for pattern, r := range cfg.Rules {
+			remotes[pattern] = newRemote(s.store, cfg.Redis, pattern, r)
+		}
+	}
+
+	s.mu.Lock()
+	s.rules, s.prefix, s.remotes = cfg.Rules, prefix, remotes
+	s.mu.Unlock()
+	s.keyed.SetMaxKeys(cfg.MaxKeys)
+	s.keyed.EvictIdle(time.Duration(cfg.IdleTTL))
+	return s.keyed.ApplyConfig(prefix)
+}
+
+// newRemote creates the Redis limiter of one rule. Each pattern gets its
+// own key space, so a key does not share state across rule changes that
+// move it to another pattern
+func newRemote(c redisstore.Client, cfg *RedisConfig, pattern string, r Rule) rateflow.RemoteLimiter {
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = "rateflow:"
+	}
+	opt := redisstore.WithPrefix(prefix + pattern + ":")
+	if r.Algorithm == rateflow.SlidingWindow {
+		return redisstore.NewSlidingWindow(c, r.Burst, r.window(), opt)
+	}
+	return redisstore.NewTokenBucket(c, r.spec().Limit, r.Burst, opt)
+}
+
+// Close stops the idle key sweeper
+func (s *Server) Close() {
+	s.keyed.Stop()
+}
+
+// match returns the rule for key and its Redis limiter, if any
+func (s *Server) match(key string) (Rule, rateflow.RemoteLimiter, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pattern, ok := s.prefix.Match(key)
+	if !ok {
+		return Rule{}, nil, false
+	}
+	return s.rules[pattern], s.remotes[pattern], true
+}
+
+// Check reports whether hits events of key may happen now, charging them
+// if so
+func (s *Server) Check(ctx context.Context, key string, hits int) (Decision, error) {
+	rule, remote, ok := s.match(key)
+	if !ok {
+		return Decision{Allowed: true}, nil
+	}
+	if remote != nil {
+		return checkRemote(ctx, remote, rule, key, hits, false)
+	}
+
+	ok, res := s.keyed.AllowDetailsKey(key, hits)
+	return Decision{
+		Allowed:    ok,
+		Limit:      res.Limit,
+		Remaining:  res.Remaining,
+		ResetAt:    res.ResetAt,
+		RetryAfter: Duration(res.RetryAfter),
+	}, nil
+}
+
+// Report charges hits events of key that already happened. They are
+// booked even when that takes the key over its limit, as far as its
+// algorithm can book ahead, so later checks wait for them to be paid
+// back; Allowed reports whether they were within the limit
+func (s *Server) Report(ctx context.Context, key string, hits int) (Decision, error) {
+	rule, remote, ok := s.match(key)
+	if !ok {
+		return Decision{Allowed: true}, nil
+	}
+	if remote != nil {
+		return checkRemote(ctx, remote, rule, key, hits, true)
+	}
+
+	now := time.Now()
+	r := s.keyed.ReserveNKey(key, now, hits)
+	d := Decision{Allowed: r.OK() && r.DelayFrom(now) == 0}
+	// Keys on the allowlist or denylist have no limiter to describe
+	if lim, ok := s.keyed.Lookup(key); ok {
+		d.Limit, d.Remaining, d.ResetAt = lim.Burst(), int(lim.Remaining()), lim.ResetAt()
+	}
+	if !r.OK() {
+		d.RetryAfter = Duration(rateflow.InfDuration)
+	} else {
+		d.RetryAfter = Duration(r.DelayFrom(now))
+	}
+	return d, nil
+}
+
+// checkRemote decides hits on a Redis limiter. A reservation that would
+// have to wait is given back unless keep is set, so Check never books
+// ahead
+func checkRemote(ctx context.Context, remote rateflow.RemoteLimiter, rule Rule, key string, hits int, keep bool) (Decision, error) {
+	now := time.Now()
+	r, err := remote.ReserveN(ctx, key, now, hits)
+	if err != nil {
+		return Decision{}, err
+	}
+	d := Decision{Limit: rule.Burst, Remaining: -1}
+	switch {
+	case !r.OK() && r.RetryAt().IsZero():
+		d.RetryAfter = Duration(rateflow.InfDuration)
+	case !r.OK():
+		d.RetryAfter = Duration(r.RetryAt().Sub(now))
+	default:
+		d.RetryAfter = Duration(r.DelayFrom(now))
+		d.Allowed = d.RetryAfter == 0
+		if !d.Allowed && !keep {
+			if err := r.CancelAt(ctx, now); err != nil {
+				return Decision{}, err
+			}
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"github.com/mehmet-f-dogan/rateflow/redisstore"
+)
+
+func newServer(t *testing.T, data string) *Server {
+	t.Helper()
+	cfg, err := ParseConfig([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestCheck(t *testing.T) {
+	s := newServer(t, `{"rules": {"api/*": {"limit": 1, "burst": 2}}}`)
+	ctx := context.Background()
+
+	d, err := s.Check(ctx, "api/users", 1)
+	if err != nil || !d.Allowed || d.Limit != 2 || d.Remaining != 1 {
+		t.Fatalf("Check = %+v, %v, want allowed with 1 remaining", d, err)
+	}
+	s.Check(ctx, "api/users", 1)
+	d, _ = s.Check(ctx, "api/users", 1)
+	if d.Allowed || d.RetryAfter <= 0 {
+		t.Errorf("Check = %+v, want refused with a retry", d)
+	}
+	if d, _ := s.Check(ctx, "api/orders", 1); !d.Allowed {
+		t.Error("keys should have their own limiters")
+	}
+	if d, _ := s.Check(ctx, "admin", 100); !d.Allowed || d.Limit != 0 {
+		t.Errorf("Check = %+v, want unmatched keys unlimited", d)
+	}
+}
+
+func TestReport(t *testing.T) {
+	s := newServer(t, `{"rules": {"bytes:*": {"limit": 1000, "burst": 1000}}}`)
+	ctx := context.Background()
+
+	d, _ := s.Report(ctx, "bytes:alice", 800)
+	if !d.Allowed {
+		t.Errorf("Report = %+v, want within the limit", d)
+	}
+	d, _ = s.Report(ctx, "bytes:alice", 800)
+	if d.Allowed || d.RetryAfter < Duration(500*time.Millisecond) {
+		t.Errorf("Report = %+v, want booked ahead", d)
+	}
+	if d, _ := s.Check(ctx, "bytes:alice", 1); d.Allowed {
+		t.Error("checks should wait for reported hits to be paid back")
+	}
+}
+
+func TestCheckKeyedLists(t *testing.T) {
+	s := newServer(t, `{"rules": {"api/*": {"limit": 10, "burst": 10}}}`)
+	ctx := context.Background()
+	s.keyed.SetDenylist(rateflow.NewKeyList("api/mallory"))
+
+	if d, _ := s.Check(ctx, "api/mallory", 1); d.Allowed {
+		t.Error("Check should refuse a denylisted key")
+	}
+	if d, _ := s.Report(ctx, "api/mallory", 1); d.Allowed || d.RetryAfter != Duration(rateflow.InfDuration) {
+		t.Errorf("Report = %+v, want a denylisted key refused for good", d)
+	}
+
+	s.keyed.SetParent(rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Every(time.Hour), 1))
+	if d, _ := s.Check(ctx, "api/a", 1); !d.Allowed {
+		t.Error("Check should admit within the parent")
+	}
+	if d, _ := s.Check(ctx, "api/b", 1); d.Allowed {
+		t.Error("Check should refuse once the parent is spent")
+	}
+}
+
+func TestReload(t *testing.T) {
+	s := newServer(t, `{"rules": {"api/*": {"limit": 1, "burst": 2}}}`)
+	ctx := context.Background()
+	s.Check(ctx, "api/users", 2)
+
+	cfg, _ := ParseConfig([]byte(`{"rules": {"api/*": {"limit": 1, "burst": 5}, "web/*": {"limit": 1, "burst": 1}}}`))
+	if err := s.Reload(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := s.Check(ctx, "api/users", 1); d.Allowed || d.Limit != 5 {
+		t.Errorf("Check = %+v, want the new burst with the usage kept", d)
+	}
+	if d, _ := s.Check(ctx, "web/home", 1); !d.Allowed || d.Limit != 1 {
+		t.Errorf("Check = %+v, want the new rule", d)
+	}
+
+	cfg.Redis = &RedisConfig{Addr: "localhost:6379"}
+	if err := s.Reload(cfg); err == nil {
+		t.Error("switching to redis should need a new server")
+	}
+}
+
+func TestRedisCheck(t *testing.T) {
+	var cmds [][]any
+	store := redisstore.ClientFunc(func(ctx context.Context, args ...any) (any, error) {
+		cmds = append(cmds, args)
+		if len(cmds) == 1 {
+			// admitted 2s ahead
+			return []any{int64(1), int64(2e6)}, nil
+		}
+		return int64(1), nil
+	})
+	cfg, _ := ParseConfig([]byte(`{"redis": {"addr": "x:6379"}, "rules": {"api/*": {"limit": 1, "burst": 2}}}`))
+	s, err := New(cfg, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	d, err := s.Check(context.Background(), "api/users", 1)
+	if err != nil || d.Allowed || d.RetryAfter != Duration(2*time.Second) || d.Remaining != -1 {
+		t.Errorf("Check = %+v, %v, want refused with a 2s retry", d, err)
+	}
+	if len(cmds) != 2 {
+		t.Fatalf("sent %d commands, want the reservation given back", len(cmds))
+	}
+	if key := cmds[0][3]; key != "rateflow:api/*:{api/users}" {
+		t.Errorf("key = %v, want one key space per rule", key)
+	}
+
+	if _, err := New(cfg, nil); err == nil {
+		t.Error("expected an error without a store")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := newServer(t, `{"rules": {"api/*": {"limit": 1, "burst": 1}}}`)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	post := func(path, body string) (*http.Response, Decision) {
+		resp, err := http.Post(srv.URL+path, "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var d Decision
+		json.NewDecoder(resp.Body).Decode(&d)
+		return resp, d
+	}
+
+	if resp, d := post("/v1/check", `{"key": "api/users"}`); resp.StatusCode != http.StatusOK || !d.Allowed {
+		t.Errorf("check = %d %+v, want 200 allowed", resp.StatusCode, d)
+	}
+	resp, d := post("/v1/check", `{"key": "api/users"}`)
+	if resp.StatusCode != http.StatusTooManyRequests || d.Allowed || resp.Header.Get("Retry-After") != "1" {
+		t.Errorf("check = %d %+v Retry-After %q, want 429 with 1s", resp.StatusCode, d, resp.Header.Get("Retry-After"))
+	}
+	if resp, _ := post("/v1/report", `{"key": "api/users", "hits": -1}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("report = %d, want 400 for negative hits", resp.StatusCode)
+	}
+	if resp, _ := http.Get(srv.URL + "/healthz"); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("healthz = %d", resp.StatusCode)
+	}
+}
+
+func TestHandlerStoreError(t *testing.T) {
+	store := redisstore.ClientFunc(func(ctx context.Context, args ...any) (any, error) {
+		return nil, errors.New("connection refused")
+	})
+	cfg, _ := ParseConfig([]byte(`{"redis": {"addr": "x:6379"}, "rules": {"*": {"limit": 1, "burst": 1}}}`))
+	s, _ := New(cfg, store)
+	defer s.Close()
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/check", bytes.NewBufferString(`{"key": "k"}`)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
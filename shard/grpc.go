@@ -0,0 +1,145 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"github.com/mehmet-f-dogan/rateflow/shard/shardpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Register adds the Shard service, deciding forwarded keys on this node,
+// to srv; it is the gRPC counterpart of Handler
+func (r *Router) Register(srv grpc.ServiceRegistrar) {
+	shardpb.RegisterShardServer(srv, shardServer{r: r})
+}
+
+type shardServer struct {
+	shardpb.UnimplementedShardServer
+	r *Router
+}
+
+func (s shardServer) Allow(ctx context.Context, req *shardpb.LimitRequest) (*shardpb.AllowReply, error) {
+	if req.N < 0 {
+		return nil, status.Error(codes.InvalidArgument, "bad n")
+	}
+	return &shardpb.AllowReply{Allowed: s.r.LocalAllowN(req.Key, int(req.N))}, nil
+}
+
+func (s shardServer) Wait(ctx context.Context, req *shardpb.LimitRequest) (*shardpb.WaitReply, error) {
+	if req.N < 0 {
+		return nil, status.Error(codes.InvalidArgument, "bad n")
+	}
+	err := s.r.LocalWaitN(ctx, req.Key, int(req.N))
+	var rle *rateflow.RateLimitError
+	switch {
+	case err == nil:
+		return &shardpb.WaitReply{}, nil
+	case errors.As(err, &rle):
+		st := status.New(codes.ResourceExhausted, err.Error())
+		if withRetry, derr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(rle.RetryAfter)}); derr == nil {
+			st = withRetry
+		}
+		return nil, st.Err()
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return nil, status.FromContextError(err).Err()
+	case errors.Is(err, rateflow.ErrExceedsBurst):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, rateflow.ErrDenylisted):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+}
+
+// GRPCForwarder forwards decisions to the Shard service of the owning
+// node, whose name on the ring is its gRPC target. The caller's deadline
+// travels with each call, so the owner fails fast the way a local Wait
+// would. Connections are opened on first use and kept until Close
+type GRPCForwarder struct {
+	// Options are passed to grpc.NewClient for every node; they must at
+	// least set the transport credentials
+	Options []grpc.DialOption
+
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+var _ Forwarder = (*GRPCForwarder)(nil)
+
+// client returns the Shard client of node, connecting on first use
+func (f *GRPCForwarder) client(node string) (shardpb.ShardClient, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	conn, ok := f.conns[node]
+	if !ok {
+		var err error
+		if conn, err = grpc.NewClient(node, f.Options...); err != nil {
+			return nil, err
+		}
+		if f.conns == nil {
+			f.conns = make(map[string]*grpc.ClientConn)
+		}
+		f.conns[node] = conn
+	}
+	return shardpb.NewShardClient(conn), nil
+}
+
+// AllowN asks node whether n events for key may happen now
+func (f *GRPCForwarder) AllowN(ctx context.Context, node, key string, n int) (bool, error) {
+	c, err := f.client(node)
+	if err != nil {
+		return false, err
+	}
+	reply, err := c.Allow(ctx, &shardpb.LimitRequest{Key: key, N: int64(n)})
+	if err != nil {
+		return false, err
+	}
+	return reply.Allowed, nil
+}
+
+// WaitN waits on node until n events for key are allowed. A refusal to
+// wait past ctx's deadline comes back as a rateflow.RateLimitError, and
+// refusals that no wait would end match rateflow.ErrExceedsBurst or
+// rateflow.ErrDenylisted as they do on the owner
+func (f *GRPCForwarder) WaitN(ctx context.Context, node, key string, n int) error {
+	c, err := f.client(node)
+	if err != nil {
+		return err
+	}
+	_, err = c.Wait(ctx, &shardpb.LimitRequest{Key: key, N: int64(n)})
+	switch st, _ := status.FromError(err); st.Code() {
+	case codes.ResourceExhausted:
+		rle := &rateflow.RateLimitError{}
+		for _, d := range st.Details() {
+			if retry, ok := d.(*errdetails.RetryInfo); ok {
+				rle.RetryAfter = retry.RetryDelay.AsDuration()
+			}
+		}
+		return rle
+	case codes.FailedPrecondition:
+		return fmt.Errorf("shard: %s: %w", node, rateflow.ErrExceedsBurst)
+	case codes.PermissionDenied:
+		return fmt.Errorf("shard: %s: %w", node, rateflow.ErrDenylisted)
+	}
+	return err
+}
+
+// Close closes the connections to every node
+func (f *GRPCForwarder) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var errs []error
+	for node, conn := range f.conns {
+		errs = append(errs, conn.Close())
+		delete(f.conns, node)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,83 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newGRPCNodes starts two routers serving each other over gRPC
+func newGRPCNodes(t *testing.T) (a, b *Router) {
+	lis := map[string]*bufconn.Listener{"a": bufconn.Listen(1 << 16), "b": bufconn.Listen(1 << 16)}
+	fwd := &GRPCForwarder{Options: []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return lis[addr].DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}}
+	t.Cleanup(func() { fwd.Close() })
+
+	ring := NewRing(0, "passthrough:///a", "passthrough:///b")
+	var routers []*Router
+	for _, name := range []string{"a", "b"} {
+		local := rateflow.NewKeyed(func(string) rateflow.Limiter {
+			return rateflow.NewLimiter(rateflow.TokenBucket, 10, 2)
+		})
+		r := NewRouter("passthrough:///"+name, ring, local, fwd)
+		srv := grpc.NewServer()
+		r.Register(srv)
+		go srv.Serve(lis[name])
+		t.Cleanup(srv.Stop)
+		routers = append(routers, r)
+	}
+	return routers[0], routers[1]
+}
+
+func TestGRPCForwarder(t *testing.T) {
+	a, b := newGRPCNodes(t)
+	ctx := context.Background()
+	key := keyOwnedBy(a.Ring(), b.self)
+
+	for i, r := range []*Router{a, b} {
+		if ok, err := r.Allow(ctx, key); err != nil || !ok {
+			t.Fatalf("Allow via node %d = %v, %v, want true", i, ok, err)
+		}
+	}
+	if ok, err := a.Allow(ctx, key); err != nil || ok {
+		t.Errorf("Allow via a = %v, %v, want false once the burst is used", ok, err)
+	}
+	if a.local.Len() != 0 {
+		t.Error("a should not keep state for keys it does not own")
+	}
+
+	if err := a.Wait(ctx, key); err != nil {
+		t.Fatalf("Wait = %v", err)
+	}
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	var rle *rateflow.RateLimitError
+	if err := a.Wait(short, key); !errors.As(err, &rle) || rle.RetryAfter <= 0 {
+		t.Errorf("Wait = %v, want a RateLimitError from the owner", err)
+	}
+}
+
+func TestGRPCForwarderRefusals(t *testing.T) {
+	a, b := newGRPCNodes(t)
+	ctx := context.Background()
+	key := keyOwnedBy(a.Ring(), b.self)
+
+	if err := a.WaitN(ctx, key, 5); !errors.Is(err, rateflow.ErrExceedsBurst) {
+		t.Errorf("WaitN past the burst = %v, want ErrExceedsBurst", err)
+	}
+	b.local.SetDenylist(rateflow.NewKeyList(key))
+	if err := a.Wait(ctx, key); !errors.Is(err, rateflow.ErrDenylisted) {
+		t.Errorf("Wait = %v, want ErrDenylisted from the owner", err)
+	}
+}
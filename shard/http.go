@@ -0,0 +1,157 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+const (
+	allowPath = "/rateflow/allow"
+	waitPath  = "/rateflow/wait"
+
+	// timeoutHeader carries the time left before the caller's deadline in
+	// milliseconds, so the owner fails fast the way a local Wait would
+	timeoutHeader = "Rateflow-Timeout"
+
+	// retryHeader is Retry-After in milliseconds, which the standard
+	// header is too coarse for
+	retryHeader = "Rateflow-Retry-After"
+)
+
+// Handler serves decisions forwarded by an HTTPForwarder, deciding them
+// on this node
+func (r *Router) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(allowPath, func(w http.ResponseWriter, req *http.Request) {
+		key, n, ok := parseRequest(w, req)
+		if !ok {
+			return
+		}
+		if r.LocalAllowN(key, n) {
+			io.WriteString(w, "1")
+		} else {
+			io.WriteString(w, "0")
+		}
+	})
+	mux.HandleFunc(waitPath, func(w http.ResponseWriter, req *http.Request) {
+		key, n, ok := parseRequest(w, req)
+		if !ok {
+			return
+		}
+		ctx := req.Context()
+		if ms, err := strconv.ParseInt(req.Header.Get(timeoutHeader), 10, 64); err == nil {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+			defer cancel()
+		}
+
+		err := r.LocalWaitN(ctx, key, n)
+		var rle *rateflow.RateLimitError
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.As(err, &rle):
+			secs := (rle.RetryAfter + time.Second - 1) / time.Second
+			w.Header().Set("Retry-After", strconv.FormatInt(int64(secs), 10))
+			w.Header().Set(retryHeader, strconv.FormatInt(rle.RetryAfter.Milliseconds(), 10))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		default:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}
+	})
+	return mux
+}
+
+// parseRequest reads the key and count of a forwarded decision
+func parseRequest(w http.ResponseWriter, req *http.Request) (string, int, bool) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return "", 0, false
+	}
+	q := req.URL.Query()
+	n, err := strconv.Atoi(q.Get("n"))
+	if err != nil || n < 0 {
+		http.Error(w, "bad n", http.StatusBadRequest)
+		return "", 0, false
+	}
+	return q.Get("key"), n, true
+}
+
+// HTTPForwarder forwards decisions to the Handler of the owning node,
+// whose name on the ring is its host:port
+type HTTPForwarder struct {
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+	// Scheme defaults to "http"
+	Scheme string
+}
+
+var _ Forwarder = (*HTTPForwarder)(nil)
+
+func (f *HTTPForwarder) post(ctx context.Context, node, path, key string, n int) (*http.Response, error) {
+	scheme := f.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	u := url.URL{Scheme: scheme, Host: node, Path: path,
+		RawQuery: url.Values{"key": {key}, "n": {strconv.Itoa(n)}}.Encode()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(timeoutHeader, strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// AllowN asks node whether n events for key may happen now
+func (f *HTTPForwarder) AllowN(ctx context.Context, node, key string, n int) (bool, error) {
+	resp, err := f.post(ctx, node, allowPath, key, n)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("shard: %s: %s", node, strings.TrimSpace(string(body)))
+	}
+	return string(body) == "1", nil
+}
+
+// WaitN waits on node until n events for key are allowed. A refusal to
+// wait past ctx's deadline comes back as a rateflow.RateLimitError
+func (f *HTTPForwarder) WaitN(ctx context.Context, node, key string, n int) error {
+	resp, err := f.post(ctx, node, waitPath, key, n)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusTooManyRequests:
+		ms, _ := strconv.ParseInt(resp.Header.Get(retryHeader), 10, 64)
+		return &rateflow.RateLimitError{RetryAfter: time.Duration(ms) * time.Millisecond}
+	default:
+		return fmt.Errorf("shard: %s: %s", node, strings.TrimSpace(string(body)))
+	}
+}
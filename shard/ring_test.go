@@ -0,0 +1,52 @@
+package shard
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	r := NewRing(0, "a", "b", "c")
+	if _, ok := NewRing(0).Owner("k"); ok {
+		t.Error("an empty ring should own nothing")
+	}
+
+	counts := map[string]int{}
+	owners := map[string]string{}
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		owner, ok := r.Owner(key)
+		if !ok {
+			t.Fatal("expected an owner")
+		}
+		counts[owner]++
+		owners[key] = owner
+	}
+	for node, n := range counts {
+		if n < 700 || n > 1300 {
+			t.Errorf("%s owns %d of 3000 keys, want about a third", node, n)
+		}
+	}
+
+	// Another process building the same ring agrees on every owner
+	same := NewRing(0, "c", "a", "b")
+	for key, owner := range owners {
+		if got, _ := same.Owner(key); got != owner {
+			t.Fatalf("owner of %s = %s, want %s", key, got, owner)
+		}
+	}
+
+	r.Remove("b")
+	for key, owner := range owners {
+		got, _ := r.Owner(key)
+		if owner != "b" && got != owner {
+			t.Fatalf("%s moved from %s to %s, want only b's keys to move", key, owner, got)
+		}
+		if got == "b" {
+			t.Fatalf("%s still owned by the removed node", key)
+		}
+	}
+	if got := r.Nodes(); fmt.Sprint(got) != "[a c]" {
+		t.Errorf("Nodes = %v, want [a c]", got)
+	}
+}
@@ -0,0 +1,99 @@
+// Package shard enforces exact global per-key limits by giving every key
+// one authoritative node. Nodes agree on owners through a consistent
+// hash Ring; a Router decides keys it owns with its local Keyed limiter
+// and forwards the others to their owner.
+//
+// Forwarding goes through a Forwarder. HTTPForwarder and Router.Handler
+// use net/http; GRPCForwarder and Router.Register use the Shard service
+// of shardpb:
+//
+//	fwd := &shard.GRPCForwarder{Options: []grpc.DialOption{
+//		grpc.WithTransportCredentials(insecure.NewCredentials()),
+//	}}
+//	r := shard.NewRouter("10.0.0.1:7000", ring, local, fwd)
+//	srv := grpc.NewServer()
+//	r.Register(srv)
+//
+// The package is a module of its own, so only programs using it depend
+// on gRPC.
+package shard
+
+import (
+	"context"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// Forwarder sends decisions for a key to the node owning it
+type Forwarder interface {
+	AllowN(ctx context.Context, node, key string, n int) (bool, error)
+	WaitN(ctx context.Context, node, key string, n int) error
+}
+
+// Router decides each key on its owning node
+type Router struct {
+	self  string
+	ring  *Ring
+	local *rateflow.Keyed[string]
+	fwd   Forwarder
+}
+
+// NewRouter creates a router for the node named self on ring, deciding
+// the keys it owns with local and forwarding the rest through fwd
+func NewRouter(self string, ring *Ring, local *rateflow.Keyed[string], fwd Forwarder) *Router {
+	return &Router{self: self, ring: ring, local: local, fwd: fwd}
+}
+
+// Ring returns the ring the router routes by
+func (r *Router) Ring() *Ring {
+	return r.ring
+}
+
+// owner returns the node that decides key: its owner on the ring, or
+// this node while the ring is empty
+func (r *Router) owner(key string) string {
+	if node, ok := r.ring.Owner(key); ok {
+		return node
+	}
+	return r.self
+}
+
+// Allow is shorthand for AllowN(ctx, key, 1)
+func (r *Router) Allow(ctx context.Context, key string) (bool, error) {
+	return r.AllowN(ctx, key, 1)
+}
+
+// AllowN reports whether n events for key may happen now, asking the
+// key's owner
+func (r *Router) AllowN(ctx context.Context, key string, n int) (bool, error) {
+	if node := r.owner(key); node != r.self {
+		return r.fwd.AllowN(ctx, node, key, n)
+	}
+	return r.LocalAllowN(key, n), nil
+}
+
+// Wait is shorthand for WaitN(ctx, key, 1)
+func (r *Router) Wait(ctx context.Context, key string) error {
+	return r.WaitN(ctx, key, 1)
+}
+
+// WaitN blocks until the key's owner allows n events for key
+func (r *Router) WaitN(ctx context.Context, key string, n int) error {
+	if node := r.owner(key); node != r.self {
+		return r.fwd.WaitN(ctx, node, key, n)
+	}
+	return r.LocalWaitN(ctx, key, n)
+}
+
+// LocalAllowN decides n events for key on this node without routing,
+// for the server side of a Forwarder. Routing again there could bounce
+// requests between nodes whose rings briefly disagree
+func (r *Router) LocalAllowN(key string, n int) bool {
+	return r.local.AllowNKey(key, time.Now(), n)
+}
+
+// LocalWaitN is the WaitN counterpart of LocalAllowN
+func (r *Router) LocalWaitN(ctx context.Context, key string, n int) error {
+	return r.local.WaitNKey(ctx, key, n)
+}
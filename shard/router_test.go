@@ -0,0 +1,87 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// newNodes starts two routers serving each other over HTTP
+func newNodes(t *testing.T) (a, b *Router) {
+	newKeyed := func() *rateflow.Keyed[string] {
+		return rateflow.NewKeyed(func(string) rateflow.Limiter {
+			return rateflow.NewLimiter(rateflow.TokenBucket, 10, 2)
+		})
+	}
+	sa, sb := httptest.NewUnstartedServer(nil), httptest.NewUnstartedServer(nil)
+	sa.Start()
+	sb.Start()
+	t.Cleanup(sa.Close)
+	t.Cleanup(sb.Close)
+	hostA, _ := url.Parse(sa.URL)
+	hostB, _ := url.Parse(sb.URL)
+
+	ring := NewRing(0, hostA.Host, hostB.Host)
+	a = NewRouter(hostA.Host, ring, newKeyed(), &HTTPForwarder{})
+	b = NewRouter(hostB.Host, ring, newKeyed(), &HTTPForwarder{})
+	sa.Config.Handler = a.Handler()
+	sb.Config.Handler = b.Handler()
+	return a, b
+}
+
+// keyOwnedBy returns a key the ring assigns to node
+func keyOwnedBy(r *Ring, node string) string {
+	for i := 0; ; i++ {
+		key := "key-" + string(rune('a'+i%26)) + string(rune('a'+i/26%26))
+		if owner, _ := r.Owner(key); owner == node {
+			return key
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	a, b := newNodes(t)
+	ctx := context.Background()
+	key := keyOwnedBy(a.Ring(), b.self)
+
+	// Both nodes share b's limiter for the key, so its burst of 2 holds
+	// across them
+	for i, r := range []*Router{a, b} {
+		if ok, err := r.Allow(ctx, key); err != nil || !ok {
+			t.Fatalf("Allow via node %d = %v, %v, want true", i, ok, err)
+		}
+	}
+	for i, r := range []*Router{a, b} {
+		if ok, _ := r.Allow(ctx, key); ok {
+			t.Errorf("Allow via node %d should be refused once the burst is used", i)
+		}
+	}
+	if a.local.Len() != 0 {
+		t.Error("a should not keep state for keys it does not own")
+	}
+
+	if err := a.Wait(ctx, key); err != nil {
+		t.Fatalf("Wait = %v", err)
+	}
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	var rle *rateflow.RateLimitError
+	if err := a.Wait(short, key); !errors.As(err, &rle) || rle.RetryAfter <= 0 {
+		t.Errorf("Wait = %v, want a RateLimitError from the owner", err)
+	}
+}
+
+func TestRouterEmptyRing(t *testing.T) {
+	local := rateflow.NewKeyed(func(string) rateflow.Limiter {
+		return rateflow.NewLimiter(rateflow.TokenBucket, 1, 2)
+	})
+	r := NewRouter("self", NewRing(0), local, &HTTPForwarder{})
+	if ok, err := r.Allow(context.Background(), "k"); err != nil || !ok {
+		t.Errorf("Allow = %v, %v, want a local decision", ok, err)
+	}
+}
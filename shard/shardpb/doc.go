@@ -0,0 +1,5 @@
+// Package shardpb is the generated code of shard.proto, the gRPC service
+// nodes forward decisions over
+package shardpb
+
+//go:generate buf generate --template buf.gen.yaml
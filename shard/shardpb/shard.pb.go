@@ -0,0 +1,224 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: shard.proto
+
+package shardpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type LimitRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	N             int64                  `protobuf:"varint,2,opt,name=n,proto3" json:"n,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LimitRequest) Reset() {
+	*x = LimitRequest{}
+	mi := &file_shard_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LimitRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LimitRequest) ProtoMessage() {}
+
+func (x *LimitRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shard_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LimitRequest.ProtoReflect.Descriptor instead.
+func (*LimitRequest) Descriptor() ([]byte, []int) {
+	return file_shard_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LimitRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *LimitRequest) GetN() int64 {
+	if x != nil {
+		return x.N
+	}
+	return 0
+}
+
+type AllowReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Allowed       bool                   `protobuf:"varint,1,opt,name=allowed,proto3" json:"allowed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AllowReply) Reset() {
+	*x = AllowReply{}
+	mi := &file_shard_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AllowReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AllowReply) ProtoMessage() {}
+
+func (x *AllowReply) ProtoReflect() protoreflect.Message {
+	mi := &file_shard_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AllowReply.ProtoReflect.Descriptor instead.
+func (*AllowReply) Descriptor() ([]byte, []int) {
+	return file_shard_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AllowReply) GetAllowed() bool {
+	if x != nil {
+		return x.Allowed
+	}
+	return false
+}
+
+type WaitReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WaitReply) Reset() {
+	*x = WaitReply{}
+	mi := &file_shard_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WaitReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WaitReply) ProtoMessage() {}
+
+func (x *WaitReply) ProtoReflect() protoreflect.Message {
+	mi := &file_shard_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WaitReply.ProtoReflect.Descriptor instead.
+func (*WaitReply) Descriptor() ([]byte, []int) {
+	return file_shard_proto_rawDescGZIP(), []int{2}
+}
+
+var File_shard_proto protoreflect.FileDescriptor
+
+const file_shard_proto_rawDesc = "" +
+	"\n" +
+	"\vshard.proto\x12\x11rateflow.shard.v1\".\n" +
+	"\fLimitRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\f\n" +
+	"\x01n\x18\x02 \x01(\x03R\x01n\"&\n" +
+	"\n" +
+	"AllowReply\x12\x18\n" +
+	"\aallowed\x18\x01 \x01(\bR\aallowed\"\v\n" +
+	"\tWaitReply2\x97\x01\n" +
+	"\x05Shard\x12G\n" +
+	"\x05Allow\x12\x1f.rateflow.shard.v1.LimitRequest\x1a\x1d.rateflow.shard.v1.AllowReply\x12E\n" +
+	"\x04Wait\x12\x1f.rateflow.shard.v1.LimitRequest\x1a\x1c.rateflow.shard.v1.WaitReplyB2Z0github.com/mehmet-f-dogan/rateflow/shard/shardpbb\x06proto3"
+
+var (
+	file_shard_proto_rawDescOnce sync.Once
+	file_shard_proto_rawDescData []byte
+)
+
+func file_shard_proto_rawDescGZIP() []byte {
+	file_shard_proto_rawDescOnce.Do(func() {
+		file_shard_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_shard_proto_rawDesc), len(file_shard_proto_rawDesc)))
+	})
+	return file_shard_proto_rawDescData
+}
+
+var file_shard_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_shard_proto_goTypes = []any{
+	(*LimitRequest)(nil), // 0: rateflow.shard.v1.LimitRequest
+	(*AllowReply)(nil),   // 1: rateflow.shard.v1.AllowReply
+	(*WaitReply)(nil),    // 2: rateflow.shard.v1.WaitReply
+}
+var file_shard_proto_depIdxs = []int32{
+	0, // 0: rateflow.shard.v1.Shard.Allow:input_type -> rateflow.shard.v1.LimitRequest
+	0, // 1: rateflow.shard.v1.Shard.Wait:input_type -> rateflow.shard.v1.LimitRequest
+	1, // 2: rateflow.shard.v1.Shard.Allow:output_type -> rateflow.shard.v1.AllowReply
+	2, // 3: rateflow.shard.v1.Shard.Wait:output_type -> rateflow.shard.v1.WaitReply
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_shard_proto_init() }
+func file_shard_proto_init() {
+	if File_shard_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_shard_proto_rawDesc), len(file_shard_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_shard_proto_goTypes,
+		DependencyIndexes: file_shard_proto_depIdxs,
+		MessageInfos:      file_shard_proto_msgTypes,
+	}.Build()
+	File_shard_proto = out.File
+	file_shard_proto_goTypes = nil
+	file_shard_proto_depIdxs = nil
+}
@@ -0,0 +1,173 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.0
+// - protoc             (unknown)
+// source: shard.proto
+
+package shardpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Shard_Allow_FullMethodName = "/rateflow.shard.v1.Shard/Allow"
+	Shard_Wait_FullMethodName  = "/rateflow.shard.v1.Shard/Wait"
+)
+
+// ShardClient is the client API for Shard service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Shard decides keys forwarded by the other nodes of a ring on the node
+// owning them
+type ShardClient interface {
+	// Allow reports whether n events for key may happen now
+	Allow(ctx context.Context, in *LimitRequest, opts ...grpc.CallOption) (*AllowReply, error)
+	// Wait returns once n events for key are allowed. A wait that would
+	// outlast the call's deadline fails at once with RESOURCE_EXHAUSTED and
+	// a RetryInfo detail
+	Wait(ctx context.Context, in *LimitRequest, opts ...grpc.CallOption) (*WaitReply, error)
+}
+
+type shardClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewShardClient(cc grpc.ClientConnInterface) ShardClient {
+	return &shardClient{cc}
+}
+
+func (c *shardClient) Allow(ctx context.Context, in *LimitRequest, opts ...grpc.CallOption) (*AllowReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AllowReply)
+	err := c.cc.Invoke(ctx, Shard_Allow_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shardClient) Wait(ctx context.Context, in *LimitRequest, opts ...grpc.CallOption) (*WaitReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WaitReply)
+	err := c.cc.Invoke(ctx, Shard_Wait_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ShardServer is the server API for Shard service.
+// All implementations must embed UnimplementedShardServer
+// for forward compatibility.
+//
+// Shard decides keys forwarded by the other nodes of a ring on the node
+// owning them
+type ShardServer interface {
+	// Allow reports whether n events for key may happen now
+	Allow(context.Context, *LimitRequest) (*AllowReply, error)
+	// Wait returns once n events for key are allowed. A wait that would
+	// outlast the call's deadline fails at once with RESOURCE_EXHAUSTED and
+	// a RetryInfo detail
+	Wait(context.Context, *LimitRequest) (*WaitReply, error)
+	mustEmbedUnimplementedShardServer()
+}
+
+// UnimplementedShardServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedShardServer struct{}
+
+func (UnimplementedShardServer) Allow(context.Context, *LimitRequest) (*AllowReply, error) {
+	return nil, status.Error(codes.Unimplemented, "method Allow not implemented")
+}
+func (UnimplementedShardServer) Wait(context.Context, *LimitRequest) (*WaitReply, error) {
+	return nil, status.Error(codes.Unimplemented, "method Wait not implemented")
+}
+func (UnimplementedShardServer) mustEmbedUnimplementedShardServer() {}
+func (UnimplementedShardServer) testEmbeddedByValue()               {}
+
+// UnsafeShardServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ShardServer will
+// result in compilation errors.
+type UnsafeShardServer interface {
+	mustEmbedUnimplementedShardServer()
+}
+
+func RegisterShardServer(s grpc.ServiceRegistrar, srv ShardServer) {
+	// If the following call panics, it indicates UnimplementedShardServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Shard_ServiceDesc, srv)
+}
+
+func _Shard_Allow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LimitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShardServer).Allow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shard_Allow_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShardServer).Allow(ctx, req.(*LimitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shard_Wait_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LimitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShardServer).Wait(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shard_Wait_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShardServer).Wait(ctx, req.(*LimitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Shard_ServiceDesc is the grpc.ServiceDesc for Shard service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Shard_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rateflow.shard.v1.Shard",
+	HandlerType: (*ShardServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Allow",
+			Handler:    _Shard_Allow_Handler,
+		},
+		{
+			MethodName: "Wait",
+			Handler:    _Shard_Wait_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "shard.proto",
+}
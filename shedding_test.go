@@ -0,0 +1,63 @@
+package rateflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSheddingLimiterBelowThreshold(t *testing.T) {
+	lim := NewSheddingLimiter(NewLimiter(TokenBucket, Limit(1), 100), 0.5)
+	now := time.Now()
+
+	// Up to half the bucket no request may be shed
+	for i := 0; i < 50; i++ {
+		if !lim.AllowN(now, 1) {
+			t.Fatalf("request %d shed below threshold", i)
+		}
+	}
+	if p := lim.DropProbability(now); p != 0 {
+		t.Errorf("expected drop probability 0 at threshold, got %f", p)
+	}
+}
+
+func TestSheddingLimiterRamp(t *testing.T) {
+	lim := NewSheddingLimiter(NewLimiter(TokenBucket, Limit(1), 100), 0.5)
+	now := time.Now()
+	lim.Limiter.AllowN(now, 75)
+
+	if p := lim.DropProbability(now); p < 0.49 || p > 0.51 {
+		t.Errorf("expected drop probability 0.5 at 75%% utilization, got %f", p)
+	}
+
+	allowed := 0
+	for i := 0; i < 25; i++ {
+		if lim.AllowN(now, 1) {
+			allowed++
+		}
+	}
+	if allowed == 25 {
+		t.Error("expected some requests to be shed above threshold")
+	}
+	if tokens := lim.TokensAt(now); tokens != float64(25-allowed) {
+		t.Errorf("shed requests must not consume tokens: have %f, want %d", tokens, 25-allowed)
+	}
+}
+
+func TestSheddingLimiterStats(t *testing.T) {
+	lim := NewSheddingLimiter(NewLimiter(TokenBucket, Limit(1), 10), 0)
+	now := time.Now()
+	lim.Limiter.AllowN(now, 10)
+
+	if lim.AllowN(now, 1) {
+		t.Fatal("expected a full limiter to shed every call")
+	}
+	ok, res := lim.AllowDetailsAt(now, 2)
+	if ok || res.Limit != 10 || res.Remaining != 0 || res.RetryAfter != 0 {
+		t.Errorf("expected a shed call to report the limiter's state, got %v %+v", ok, res)
+	}
+
+	s := lim.Stats()
+	if s.Allowed != 1 || s.Denied != 2 || s.Denials.Shed != 2 {
+		t.Errorf("expected 1 allowed and 2 shed, got %d allowed, %d denied, %+v", s.Allowed, s.Denied, s.Denials)
+	}
+}
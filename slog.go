@@ -0,0 +1,271 @@
+//go:build go1.21
+
+package rateflow
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow/internal/limiter"
+)
+
+// LogConfig configures a LimitLogger. The zero levels are slog.LevelInfo
+type LogConfig struct {
+	Logger *slog.Logger
+
+	// DenyLevel is the level of denied Allow and Reserve calls and of
+	// failed Waits
+	DenyLevel slog.Level
+	// WaitLevel is the level of Waits that blocked for LongWait or more.
+	// With a LongWait of 0 waits are not logged
+	WaitLevel slog.Level
+	LongWait  time.Duration
+	// ChangeLevel is the level of SetLimit, SetBurst and RampLimit calls
+	ChangeLevel slog.Level
+
+	// SampleBurst and SampleInterval bound denial and wait records to
+	// SampleBurst per SampleInterval across every limiter the LimitLogger
+	// wraps, 10 per second if 0. The next record logged after some were
+	// dropped says how many. Limit changes are never dropped
+	SampleBurst    int
+	SampleInterval time.Duration
+}
+
+// LimitLogger logs what the limiters it wraps decide to a slog.Logger,
+// with the fields algorithm, n and retry_after, plus any given to Wrap,
+// such as the key of a Keyed:
+//
+//	ll := rateflow.NewLimitLogger(rateflow.LogConfig{Logger: logger, DenyLevel: slog.LevelWarn})
+//	users := rateflow.NewKeyed(func(user string) rateflow.Limiter {
+//		return ll.Wrap(rateflow.NewLimiter(rateflow.TokenBucket, 10, 20), slog.String("key", user))
+//	})
+type LimitLogger struct {
+	cfg        LogConfig
+	sampler    Limiter
+	suppressed atomic.Int64
+}
+
+// NewLimitLogger creates a LimitLogger. A nil cfg.Logger logs to
+// slog.Default()
+func NewLimitLogger(cfg LogConfig) *LimitLogger {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if cfg.SampleBurst <= 0 {
+		cfg.SampleBurst = 10
+	}
+	if cfg.SampleInterval <= 0 {
+		cfg.SampleInterval = time.Second
+	}
+	rate := Limit(float64(cfg.SampleBurst) / cfg.SampleInterval.Seconds())
+	return &LimitLogger{cfg: cfg, sampler: NewLimiter(TokenBucket, rate, cfg.SampleBurst)}
+}
+
+// Wrap returns lim logging its decisions, with attrs added to every
+// record
+func (l *LimitLogger) Wrap(lim Limiter, attrs ...slog.Attr) *LoggedLimiter {
+	return &LoggedLimiter{Limiter: lim, log: l, attrs: attrs}
+}
+
+// log writes a record, sampled unless it reports a limit change
+func (l *LimitLogger) log(ctx context.Context, level slog.Level, sampled bool, msg string, attrs []slog.Attr) {
+	if !l.cfg.Logger.Enabled(ctx, level) {
+		return
+	}
+	if sampled {
+		if !l.sampler.Allow() {
+			l.suppressed.Add(1)
+			return
+		}
+		if n := l.suppressed.Swap(0); n > 0 {
+			attrs = append(attrs, slog.Int64("suppressed", n))
+		}
+	}
+	l.cfg.Logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// LoggedLimiter is a Limiter wrapped by a LimitLogger
+type LoggedLimiter struct {
+	Limiter
+	log   *LimitLogger
+	attrs []slog.Attr
+}
+
+// Unwrap returns the wrapped limiter
+func (ll *LoggedLimiter) Unwrap() Limiter {
+	return ll.Limiter
+}
+
+// Clone wraps a clone of the wrapped limiter with the same logger and
+// attributes
+func (ll *LoggedLimiter) Clone() Limiter {
+	return ll.log.Wrap(ll.Limiter.Clone(), ll.attrs...)
+}
+
+func (ll *LoggedLimiter) fields(n int, extra ...slog.Attr) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(ll.attrs)+2+len(extra))
+	attrs = append(attrs, ll.attrs...)
+	attrs = append(attrs, slog.String("algorithm", ll.Algorithm().String()), slog.Int("n", n))
+	return append(attrs, extra...)
+}
+
+func (ll *LoggedLimiter) denied(ctx context.Context, n int, retryAfter time.Duration, err error) {
+	var extra []slog.Attr
+	if retryAfter > 0 && retryAfter < InfDuration {
+		extra = append(extra, slog.Duration("retry_after", retryAfter))
+	}
+	if err != nil {
+		extra = append(extra, slog.Any("error", err))
+	}
+	ll.log.log(ctx, ll.log.cfg.DenyLevel, true, "rate limit denied", ll.fields(n, extra...))
+}
+
+func (ll *LoggedLimiter) Allow() bool {
+	return ll.AllowN(limiter.NowOf(ll.Limiter), 1)
+}
+
+func (ll *LoggedLimiter) AllowN(t time.Time, n int) bool {
+	ok, _ := ll.AllowDetailsAt(t, n)
+	return ok
+}
+
+func (ll *LoggedLimiter) AllowDetails(n int) (bool, Result) {
+	return ll.AllowDetailsAt(limiter.NowOf(ll.Limiter), n)
+}
+
+func (ll *LoggedLimiter) AllowDetailsAt(t time.Time, n int) (bool, Result) {
+	ok, res := ll.Limiter.AllowDetailsAt(t, n)
+	if !ok {
+		ll.denied(context.Background(), n, res.RetryAfter, nil)
+	}
+	return ok, res
+}
+
+func (ll *LoggedLimiter) Reserve() *Reservation {
+	return ll.ReserveN(limiter.NowOf(ll.Limiter), 1)
+}
+
+func (ll *LoggedLimiter) ReserveN(t time.Time, n int) *Reservation {
+	r := ll.Limiter.ReserveN(t, n)
+	if !r.OK() {
+		ll.denied(context.Background(), n, 0, nil)
+	}
+	return r
+}
+
+func (ll *LoggedLimiter) Wait(ctx context.Context) error {
+	return ll.WaitN(ctx, 1)
+}
+
+func (ll *LoggedLimiter) WaitN(ctx context.Context, n int) error {
+	return ll.logWait(ctx, n, func() error { return ll.Limiter.WaitN(ctx, n) })
+}
+
+func (ll *LoggedLimiter) WaitMaxN(ctx context.Context, n int, maxWait time.Duration) error {
+	return ll.logWait(ctx, n, func() error { return ll.Limiter.WaitMaxN(ctx, n, maxWait) })
+}
+
+// logWait runs wait, logging it if it fails or takes LongWait or more
+func (ll *LoggedLimiter) logWait(ctx context.Context, n int, wait func() error) error {
+	start := time.Now()
+	err := wait()
+	if err != nil {
+		var rle *RateLimitError
+		var retryAfter time.Duration
+		if errors.As(err, &rle) {
+			retryAfter = rle.RetryAfter
+		}
+		ll.denied(ctx, n, retryAfter, err)
+		return err
+	}
+	if long := ll.log.cfg.LongWait; long > 0 {
+		if waited := time.Since(start); waited >= long {
+			ll.log.log(ctx, ll.log.cfg.WaitLevel, true, "rate limit long wait", ll.fields(n, slog.Duration("waited", waited)))
+		}
+	}
+	return nil
+}
+
+func (ll *LoggedLimiter) changed(what string, from, to any, extra ...slog.Attr) {
+	attrs := append(ll.attrs[:len(ll.attrs):len(ll.attrs)],
+		slog.String("algorithm", ll.Algorithm().String()),
+		slog.Any("from", from), slog.Any("to", to))
+	ll.log.log(context.Background(), ll.log.cfg.ChangeLevel, false, "rate limit "+what+" changed", append(attrs, extra...))
+}
+
+// limitValue makes Inf readable in logs
+func limitValue(r Limit) any {
+	if r == Limit(math.MaxFloat64) {
+		return "inf"
+	}
+	return float64(r)
+}
+
+func (ll *LoggedLimiter) SetLimit(newLimit Limit) {
+	ll.SetLimitAt(limiter.NowOf(ll.Limiter), newLimit)
+}
+
+func (ll *LoggedLimiter) SetLimitAt(t time.Time, newLimit Limit) {
+	old := ll.Limiter.Limit()
+	ll.Limiter.SetLimitAt(t, newLimit)
+	if old != newLimit {
+		ll.changed("limit", limitValue(old), limitValue(newLimit))
+	}
+}
+
+func (ll *LoggedLimiter) RampLimit(target Limit, over time.Duration) {
+	old := ll.Limiter.Limit()
+	ll.Limiter.RampLimit(target, over)
+	if old != target {
+		ll.changed("limit", limitValue(old), limitValue(target), slog.Duration("over", over))
+	}
+}
+
+func (ll *LoggedLimiter) SetBurst(newBurst int) {
+	ll.SetBurstAt(limiter.NowOf(ll.Limiter), newBurst)
+}
+
+func (ll *LoggedLimiter) SetBurstAt(t time.Time, newBurst int) {
+	old := ll.Limiter.Burst()
+	ll.Limiter.SetBurstAt(t, newBurst)
+	if old != newBurst {
+		ll.changed("burst", old, newBurst)
+	}
+}
+
+// The logger keeps no state of its own; these export the wrapped limiter's
+
+func (ll *LoggedLimiter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ll.Limiter)
+}
+
+func (ll *LoggedLimiter) UnmarshalJSON(data []byte) error {
+	u, ok := ll.Limiter.(json.Unmarshaler)
+	if !ok {
+		return fmt.Errorf("%w: %T cannot restore state", ErrStateMismatch, ll.Limiter)
+	}
+	return u.UnmarshalJSON(data)
+}
+
+func (ll *LoggedLimiter) MarshalBinary() ([]byte, error) {
+	m, ok := ll.Limiter.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T cannot export state", ErrStateMismatch, ll.Limiter)
+	}
+	return m.MarshalBinary()
+}
+
+func (ll *LoggedLimiter) UnmarshalBinary(data []byte) error {
+	u, ok := ll.Limiter.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return fmt.Errorf("%w: %T cannot restore state", ErrStateMismatch, ll.Limiter)
+	}
+	return u.UnmarshalBinary(data)
+}
@@ -0,0 +1,96 @@
+//go:build go1.21
+
+package rateflow
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func TestLimitLogger(t *testing.T) {
+	var buf bytes.Buffer
+	ll := NewLimitLogger(LogConfig{Logger: newTestLogger(&buf), DenyLevel: slog.LevelWarn})
+	lim := ll.Wrap(NewLimiter(TokenBucket, 1, 1), slog.String("key", "alice"))
+
+	lim.Allow()
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing logged for an admitted call, got %q", buf.String())
+	}
+	lim.Allow()
+	if got, want := buf.String(), `level=WARN msg="rate limit denied" key=alice algorithm=TokenBucket n=1 retry_after=`; !strings.HasPrefix(got, want) {
+		t.Errorf("logged %q, want it to start with %q", got, want)
+	}
+
+	buf.Reset()
+	lim.SetLimit(Inf)
+	lim.SetBurst(1)
+	if got, want := buf.String(), "level=INFO msg=\"rate limit limit changed\" key=alice algorithm=TokenBucket from=1 to=inf\n"; got != want {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+
+	if _, ok := lim.Clone().(*LoggedLimiter); !ok {
+		t.Error("expected Clone to stay wrapped")
+	}
+}
+
+func TestLimitLoggerWait(t *testing.T) {
+	var buf bytes.Buffer
+	ll := NewLimitLogger(LogConfig{Logger: newTestLogger(&buf), LongWait: time.Millisecond, WaitLevel: slog.LevelDebug - 4})
+	lim := ll.Wrap(NewLimiter(TokenBucket, 200, 1))
+	lim.Wait(context.Background())
+	lim.Wait(context.Background())
+	if buf.Len() != 0 {
+		t.Errorf("expected waits below the handler's level to be skipped, got %q", buf.String())
+	}
+
+	ll = NewLimitLogger(LogConfig{Logger: newTestLogger(&buf), LongWait: time.Millisecond})
+	lim = ll.Wrap(NewLimiter(TokenBucket, 200, 1))
+	lim.Wait(context.Background())
+	lim.Wait(context.Background())
+	if !strings.Contains(buf.String(), `msg="rate limit long wait"`) || !strings.Contains(buf.String(), "waited=") {
+		t.Errorf("logged %q, want the long wait", buf.String())
+	}
+
+	buf.Reset()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	lim = ll.Wrap(NewLimiter(TokenBucket, Every(time.Hour), 1))
+	lim.Wait(ctx)
+	lim.Wait(ctx)
+	if !strings.Contains(buf.String(), `msg="rate limit denied"`) || !strings.Contains(buf.String(), "retry_after=") {
+		t.Errorf("logged %q, want the failed wait with its retry_after", buf.String())
+	}
+}
+
+func TestLimitLoggerSampling(t *testing.T) {
+	var buf bytes.Buffer
+	ll := NewLimitLogger(LogConfig{Logger: newTestLogger(&buf), SampleBurst: 2, SampleInterval: time.Hour})
+	lim := ll.Wrap(NewLimiter(TokenBucket, Every(time.Hour), 0))
+	for i := 0; i < 5; i++ {
+		lim.Allow()
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Errorf("logged %d records, want the sample burst of 2", n)
+	}
+
+	ll.sampler.SetLimit(Inf)
+	lim.Allow()
+	if !strings.Contains(buf.String(), "suppressed=3") {
+		t.Errorf("logged %q, want the 3 dropped records counted", buf.String())
+	}
+}
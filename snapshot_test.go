@@ -0,0 +1,56 @@
+package rateflow
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKeyedSnapshot(t *testing.T) {
+	k := NewKeyedLimiter[string](TokenBucket, Limit(1), 3)
+	for i := 0; i < 3; i++ {
+		k.AllowKey("alice")
+	}
+	k.AllowKey("bob")
+
+	data, err := k.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	restored := NewKeyedLimiter[string](TokenBucket, Limit(1), 3)
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if restored.Len() != 2 {
+		t.Errorf("expected 2 keys, got %d", restored.Len())
+	}
+	if restored.AllowKey("alice") {
+		t.Error("expected alice's exhausted burst to carry over")
+	}
+	if tokens := restored.Get("bob").Tokens(); tokens >= 3 {
+		t.Errorf("expected bob's usage to carry over, got %v tokens", tokens)
+	}
+
+	other := NewKeyedLimiter[string](SlidingWindow, Limit(1), 3)
+	if err := other.Restore(data); !errors.Is(err, ErrStateMismatch) {
+		t.Errorf("expected ErrStateMismatch, got %v", err)
+	}
+}
+
+func TestKeyedSnapshotDescriptor(t *testing.T) {
+	k := NewKeyedLimiter[Descriptor](FixedWindow, Limit(1), 1)
+	key := Descriptor{}.With("tenant", "acme").With("route", "/search")
+	k.AllowKey(key)
+
+	data, err := k.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	restored := NewKeyedLimiter[Descriptor](FixedWindow, Limit(1), 1)
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if restored.AllowKey(key) {
+		t.Error("expected the descriptor key's usage to carry over")
+	}
+}
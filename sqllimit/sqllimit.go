@@ -0,0 +1,301 @@
+// Package sqllimit throttles the queries a program sends through
+// database/sql, e.g. so a batch job cannot swamp a database it shares
+// with online traffic. It wraps the driver, so every Query and Exec
+// waits on a limiter first, whether it is called on the DB, a Conn, a
+// Tx or a prepared Stmt:
+//
+//	connector, err := pgx.NewConnector(dsn) // any driver.Connector
+//	...
+//	reads := rateflow.NewLimiter(rateflow.TokenBucket, 200, 20)
+//	writes := rateflow.NewLimiter(rateflow.TokenBucket, 50, 5)
+//	db := sql.OpenDB(sqllimit.NewConnector(connector, reads, writes))
+//
+// For a driver registered by name, register a wrapped copy instead:
+//
+//	sql.Register("postgres-throttled", sqllimit.Wrap(&pq.Driver{}, lim, lim))
+//
+// Queries count as reads and Execs as writes. Passing the same limiter
+// for both shares one limit between them; a nil limiter leaves that kind
+// unlimited. Connecting, beginning and committing transactions, and
+// pings are not limited.
+package sqllimit
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// limits holds the limiters of reads and writes, either of which may be nil
+type limits struct {
+	read, write rateflow.Limiter
+}
+
+func wait(ctx context.Context, lim rateflow.Limiter) error {
+	if lim == nil {
+		return nil
+	}
+	return lim.Wait(ctx)
+}
+
+type connector struct {
+	limits
+	c driver.Connector
+}
+
+// NewConnector wraps c so the queries of its connections wait on read and
+// their execs on write
+func NewConnector(c driver.Connector, read, write rateflow.Limiter) driver.Connector {
+	return &connector{limits: limits{read, write}, c: c}
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	dc, err := c.c.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{limits: c.limits, c: dc}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return &limitedDriver{limits: c.limits, d: c.c.Driver()}
+}
+
+type limitedDriver struct {
+	limits
+	d driver.Driver
+}
+
+// Wrap wraps d so the queries of its connections wait on read and their
+// execs on write
+func Wrap(d driver.Driver, read, write rateflow.Limiter) driver.Driver {
+	return &limitedDriver{limits: limits{read, write}, d: d}
+}
+
+func (d *limitedDriver) Open(name string) (driver.Conn, error) {
+	dc, err := d.d.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{limits: d.limits, c: dc}, nil
+}
+
+// conn implements every optional interface database/sql looks for,
+// falling back to what it would do itself when the driver's connection
+// does not
+type conn struct {
+	limits
+	c driver.Conn
+}
+
+var errIsolation = errors.New("sqllimit: driver does not support non-default isolation levels or read-only transactions")
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var s driver.Stmt
+	var err error
+	if p, ok := c.c.(driver.ConnPrepareContext); ok {
+		s, err = p.PrepareContext(ctx, query)
+	} else {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		s, err = c.c.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{limits: c.limits, s: s}, nil
+}
+
+func (c *conn) Close() error {
+	return c.c.Close()
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.c.Begin()
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.c.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errIsolation
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.c.Begin()
+}
+
+// ExecContext waits on the write limiter. If the connection cannot exec
+// directly it returns driver.ErrSkip without waiting, and database/sql
+// runs the statement through PrepareContext, whose Stmt waits instead
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	switch e := c.c.(type) {
+	case driver.ExecerContext:
+		if err := wait(ctx, c.write); err != nil {
+			return nil, err
+		}
+		return e.ExecContext(ctx, query, args)
+	case driver.Execer:
+		vals, err := values(args)
+		if err != nil {
+			return nil, err
+		}
+		if err := wait(ctx, c.write); err != nil {
+			return nil, err
+		}
+		return e.Exec(query, vals)
+	}
+	return nil, driver.ErrSkip
+}
+
+// QueryContext is ExecContext for reads
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch q := c.c.(type) {
+	case driver.QueryerContext:
+		if err := wait(ctx, c.read); err != nil {
+			return nil, err
+		}
+		return q.QueryContext(ctx, query, args)
+	case driver.Queryer:
+		vals, err := values(args)
+		if err != nil {
+			return nil, err
+		}
+		if err := wait(ctx, c.read); err != nil {
+			return nil, err
+		}
+		return q.Query(query, vals)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.c.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.c.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.c.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := c.c.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type stmt struct {
+	limits
+	s driver.Stmt
+}
+
+func (s *stmt) Close() error {
+	return s.s.Close()
+}
+
+func (s *stmt) NumInput() int {
+	return s.s.NumInput()
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := wait(context.Background(), s.write); err != nil {
+		return nil, err
+	}
+	return s.s.Exec(args)
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	if err := wait(context.Background(), s.read); err != nil {
+		return nil, err
+	}
+	return s.s.Query(args)
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := s.s.(driver.StmtExecContext); ok {
+		if err := wait(ctx, s.write); err != nil {
+			return nil, err
+		}
+		return e.ExecContext(ctx, args)
+	}
+	vals, err := values(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := wait(ctx, s.write); err != nil {
+		return nil, err
+	}
+	return s.s.Exec(vals)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := s.s.(driver.StmtQueryContext); ok {
+		if err := wait(ctx, s.read); err != nil {
+			return nil, err
+		}
+		return q.QueryContext(ctx, args)
+	}
+	vals, err := values(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := wait(ctx, s.read); err != nil {
+		return nil, err
+	}
+	return s.s.Query(vals)
+}
+
+// CheckNamedValue defers to the statement's own checks, which it hides
+// from database/sql by being wrapped
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	switch ch := s.s.(type) {
+	case driver.NamedValueChecker:
+		return ch.CheckNamedValue(nv)
+	case driver.ColumnConverter:
+		if nv.Ordinal < 1 || nv.Ordinal > s.s.NumInput() {
+			return driver.ErrSkip
+		}
+		v, err := ch.ColumnConverter(nv.Ordinal - 1).ConvertValue(nv.Value)
+		if err != nil {
+			return err
+		}
+		nv.Value = v
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+// values converts args for a driver without context support, which
+// cannot take named parameters
+func values(args []driver.NamedValue) ([]driver.Value, error) {
+	vals := make([]driver.Value, len(args))
+	for i, a := range args {
+		if a.Name != "" {
+			return nil, errors.New("sqllimit: driver does not support the use of named parameters")
+		}
+		vals[i] = a.Value
+	}
+	return vals, nil
+}
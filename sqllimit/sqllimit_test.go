@@ -0,0 +1,129 @@
+package sqllimit
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// fakeConn is a driver connection with only the required methods, so
+// database/sql runs everything through prepared statements
+type fakeConn struct {
+	execs, queries *atomic.Int64
+}
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return fakeStmt(c), nil }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+// fakeCtxConn also runs queries and execs directly
+type fakeCtxConn struct{ fakeConn }
+
+func (c fakeCtxConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	c.execs.Add(1)
+	return driver.RowsAffected(1), nil
+}
+
+func (c fakeCtxConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	c.queries.Add(1)
+	return fakeRows{}, nil
+}
+
+type fakeStmt fakeConn
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.execs.Add(1)
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.queries.Add(1)
+	return fakeRows{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string              { return nil }
+func (fakeRows) Close() error                   { return nil }
+func (fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+type fakeConnector struct {
+	conn driver.Conn
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return c.conn, nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+
+func TestConnector(t *testing.T) {
+	for _, direct := range []bool{true, false} {
+		base := fakeConn{execs: new(atomic.Int64), queries: new(atomic.Int64)}
+		var conn driver.Conn = base
+		if direct {
+			conn = fakeCtxConn{base}
+		}
+		reads := rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Every(time.Hour), 2)
+		writes := rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Every(time.Hour), 1)
+		db := sql.OpenDB(NewConnector(fakeConnector{conn}, reads, writes))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		for i := 0; i < 2; i++ {
+			rows, err := db.QueryContext(ctx, "SELECT 1")
+			if err != nil {
+				t.Fatalf("direct=%v: query %d: %v", direct, i, err)
+			}
+			rows.Close()
+		}
+		if _, err := db.ExecContext(ctx, "UPDATE t SET x = 1"); err != nil {
+			t.Fatalf("direct=%v: exec: %v", direct, err)
+		}
+
+		var rle *rateflow.RateLimitError
+		if _, err := db.QueryContext(ctx, "SELECT 1"); !errors.As(err, &rle) {
+			t.Errorf("direct=%v: third query = %v, want a RateLimitError", direct, err)
+		}
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE t SET x = 2"); !errors.As(err, &rle) {
+			t.Errorf("direct=%v: exec in a transaction = %v, want a RateLimitError", direct, err)
+		}
+		tx.Rollback()
+		cancel()
+
+		if q, e := base.queries.Load(), base.execs.Load(); q != 2 || e != 1 {
+			t.Errorf("direct=%v: driver ran %d queries and %d execs, want 2 and 1", direct, q, e)
+		}
+		db.Close()
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	base := fakeConn{execs: new(atomic.Int64), queries: new(atomic.Int64)}
+	writes := rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Every(time.Hour), 1)
+	db := sql.OpenDB(NewConnector(fakeConnector{fakeCtxConn{base}}, nil, writes))
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		rows, err := db.Query("SELECT 1")
+		if err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+		rows.Close()
+	}
+}
@@ -0,0 +1,71 @@
+package rateflow
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStateJSONRoundTrip(t *testing.T) {
+	for _, algo := range Algorithms()[:DualRate+1] {
+		lim := NewLimiter(algo, Limit(1), 5)
+		now := time.Now()
+		lim.AllowN(now, 4)
+
+		data, err := json.Marshal(lim)
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", algo, err)
+		}
+
+		restored := NewLimiter(algo, Limit(1), 5)
+		if err := json.Unmarshal(data, restored); err != nil {
+			t.Fatalf("%s: unmarshal: %v", algo, err)
+		}
+		if restored.AllowN(now, 2) {
+			t.Errorf("%s: restored limiter let a client burst past its quota", algo)
+		}
+		if !restored.AllowN(now, 1) {
+			t.Errorf("%s: restored limiter lost the remaining capacity", algo)
+		}
+	}
+}
+
+func TestStateBinaryRoundTrip(t *testing.T) {
+	lim := NewWindowLimiter(SlidingWindow, 3, time.Minute)
+	now := time.Now()
+	lim.AllowN(now, 3)
+
+	data, err := lim.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	restored := NewWindowLimiter(SlidingWindow, 3, time.Minute)
+	if err := restored.(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if restored.AllowN(now, 1) {
+		t.Error("expected the restored window to be full")
+	}
+	if !restored.AllowN(now.Add(time.Minute+time.Millisecond), 3) {
+		t.Error("expected the restored events to expire on schedule")
+	}
+}
+
+func TestStateMismatch(t *testing.T) {
+	data, err := json.Marshal(NewLimiter(TokenBucket, Limit(1), 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = json.Unmarshal(data, NewLimiter(FixedWindow, Limit(1), 5))
+	if !errors.Is(err, ErrStateMismatch) {
+		t.Errorf("expected ErrStateMismatch, got %v", err)
+	}
+
+	data, _ = json.Marshal(NewMultiWindowLimiter(WindowRule{Count: 1, Window: time.Second}))
+	two := NewMultiWindowLimiter(WindowRule{Count: 1, Window: time.Second}, WindowRule{Count: 5, Window: time.Minute})
+	if err := json.Unmarshal(data, two); !errors.Is(err, ErrStateMismatch) {
+		t.Errorf("expected ErrStateMismatch for a different rule count, got %v", err)
+	}
+}
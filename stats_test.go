@@ -0,0 +1,118 @@
+package rateflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	for _, algo := range Algorithms()[:DualRate+1] {
+		lim := NewLimiter(algo, Limit(1), 2)
+		if s := lim.Stats(); s.Allowed != 0 || s.Denied != 0 || !s.LastDecision.IsZero() {
+			t.Errorf("%s: expected empty stats, got %+v", algo, s)
+		}
+
+		now := time.Now()
+		lim.AllowN(now, 1)
+		lim.AllowN(now, 1)
+		lim.AllowN(now, 1)
+		lim.WaitN(context.Background(), 3)
+
+		s := lim.Stats()
+		if s.Allowed != 2 || s.Denied != 2 {
+			t.Errorf("%s: expected 2 allowed and 2 denied, got %+v", algo, s)
+		}
+		if s.LastDecision.IsZero() {
+			t.Errorf("%s: expected LastDecision to be set", algo)
+		}
+		if s.Waiting != 0 {
+			t.Errorf("%s: expected no waiters, got %d", algo, s.Waiting)
+		}
+	}
+}
+
+func TestStatsWaiting(t *testing.T) {
+	lim := NewLimiter(TokenBucket, Limit(10), 1)
+	lim.Allow()
+
+	done := make(chan struct{})
+	go func() {
+		lim.Wait(context.Background())
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	if n := lim.Stats().Waiting; n != 1 {
+		t.Errorf("expected 1 waiter, got %d", n)
+	}
+	<-done
+	if s := lim.Stats(); s.Waiting != 0 || s.Allowed != 2 {
+		t.Errorf("expected the wait to be counted as allowed, got %+v", s)
+	}
+}
+
+func TestStatsDenials(t *testing.T) {
+	for _, algo := range Algorithms()[:DualRate+1] {
+		lim := NewLimiter(algo, Every(time.Hour), 2)
+		now := time.Now()
+		lim.AllowN(now, 2)
+		lim.AllowN(now, 1)
+		lim.AllowN(now, 3)
+		lim.ReserveN(now, 3)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		lim.Wait(ctx)
+
+		d := lim.Stats().Denials
+		if d.Limited != 1 || d.Exceeds != 2 || d.Canceled != 1 || d.Store != 0 {
+			t.Errorf("%s: expected 1 limited, 2 exceeding and 1 canceled, got %+v", algo, d)
+		}
+	}
+}
+
+func TestStatsEventReason(t *testing.T) {
+	var reasons []DenialReason
+	lim := NewLimiterWithOptions(TokenBucket, Every(time.Hour), 1, WithListener(ListenerFuncs{
+		Allow: func(e Event) { reasons = append(reasons, e.Reason) },
+		Deny:  func(e Event) { reasons = append(reasons, e.Reason) },
+	}))
+	lim.Allow()
+	lim.Allow()
+	lim.AllowN(time.Now(), 2)
+	want := []DenialReason{NotDenied, DeniedLimited, DeniedExceeds}
+	if len(reasons) != len(want) {
+		t.Fatalf("got reasons %v, want %v", reasons, want)
+	}
+	for i := range want {
+		if reasons[i] != want[i] {
+			t.Errorf("reason %d = %v, want %v", i, reasons[i], want[i])
+		}
+	}
+}
+
+func TestStatsWaits(t *testing.T) {
+	lim := NewLimiter(TokenBucket, 50, 1)
+	lim.Wait(context.Background())
+	lim.Wait(context.Background())
+
+	h := lim.Stats().Waits
+	if h.Count != 2 || h.Counts[0] != 1 {
+		t.Fatalf("expected 2 waits, one immediate, got %+v", h)
+	}
+	if h.Counts[2] != 1 {
+		t.Errorf("expected the second wait of about 20ms in the 100ms bucket, got %+v", h)
+	}
+	if q := h.Quantile(0.5); q != time.Millisecond {
+		t.Errorf("Quantile(0.5) = %v, want 1ms", q)
+	}
+	if q := h.Quantile(1); q != 100*time.Millisecond {
+		t.Errorf("Quantile(1) = %v, want 100ms", q)
+	}
+	if h.Sum < 10*time.Millisecond || h.Mean() != h.Sum/2 {
+		t.Errorf("Sum = %v, Mean = %v", h.Sum, h.Mean())
+	}
+	if b := h.Bound(len(h.Counts) - 1); b != InfDuration {
+		t.Errorf("last bound = %v, want InfDuration", b)
+	}
+}
@@ -0,0 +1,129 @@
+package rateflow
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store keeps one number per key shared by many processes, updated by
+// compare-and-swap, e.g. Redis with a short script, DynamoDB conditional
+// writes or memcached gets/cas. Limiters built on it read a key, compute
+// the new value and write it back only if nobody else did in between,
+// retrying otherwise. A DynamoDB adapter maps the calls to
+//
+//	Get:            GetItem with ConsistentRead
+//	Add:            PutItem if attribute_not_exists(k)
+//	CompareAndSwap: PutItem if v = :old
+//
+// with the TTL stored for DynamoDB's expiry. Keys are passed as given, so
+// a store shared with other data should prefix them
+type Store interface {
+	// Get returns the value of key, and false if it does not exist
+	Get(ctx context.Context, key string) (value int64, ok bool, err error)
+	// Add sets key to value, expiring after ttl, if it does not exist
+	// and reports whether it did
+	Add(ctx context.Context, key string, value int64, ttl time.Duration) (bool, error)
+	// CompareAndSwap sets key to new, expiring after ttl, if its value
+	// is old and reports whether it did
+	CompareAndSwap(ctx context.Context, key string, old, new int64, ttl time.Duration) (bool, error)
+}
+
+// storeRetries is how many times an update is retried after a lost
+// compare-and-swap
+const storeRetries = 10
+
+// storeUpdate applies fn to the value of key until the write succeeds.
+// fn gets the current value, or found false, and returns the new value
+// and its ttl, or store false to leave the key as it is
+func storeUpdate(ctx context.Context, s Store, key string, fn func(old int64, found bool) (v int64, ttl time.Duration, store bool)) error {
+	for i := 0; i <= storeRetries; i++ {
+		old, found, err := s.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		v, ttl, store := fn(old, found)
+		if !store {
+			return nil
+		}
+		var ok bool
+		if found {
+			ok, err = s.CompareAndSwap(ctx, key, old, v, ttl)
+		} else {
+			ok, err = s.Add(ctx, key, v, ttl)
+		}
+		if err != nil || ok {
+			return err
+		}
+	}
+	return ErrContention
+}
+
+// MemoryStore is a Store in memory, for a single process and for tests
+type MemoryStore struct {
+	mu      sync.Mutex
+	values  map[string]int64
+	expires map[string]time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{values: make(map[string]int64), expires: make(map[string]time.Time)}
+}
+
+// get returns the value of key, dropping it if it expired. s.mu must be
+// held
+func (s *MemoryStore) get(key string) (int64, bool) {
+	v, ok := s.values[key]
+	if ok && !time.Now().Before(s.expires[key]) {
+		delete(s.values, key)
+		delete(s.expires, key)
+		return 0, false
+	}
+	return v, ok
+}
+
+// Get returns the value of key
+func (s *MemoryStore) Get(ctx context.Context, key string) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.get(key)
+	return v, ok, nil
+}
+
+// Add sets key to value if it does not exist
+func (s *MemoryStore) Add(ctx context.Context, key string, value int64, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.get(key); ok {
+		return false, nil
+	}
+	s.values[key], s.expires[key] = value, time.Now().Add(ttl)
+	return true, nil
+}
+
+// CompareAndSwap sets key to new if its value is old
+func (s *MemoryStore) CompareAndSwap(ctx context.Context, key string, old, new int64, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.get(key); !ok || v != old {
+		return false, nil
+	}
+	s.values[key], s.expires[key] = new, time.Now().Add(ttl)
+	return true, nil
+}
+
+// Len returns the number of keys that have not expired
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for key := range s.values {
+		if _, ok := s.get(key); ok {
+			n++
+		}
+	}
+	return n
+}
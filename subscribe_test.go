@@ -0,0 +1,92 @@
+package rateflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	lim := NewLimiter(TokenBucket, Every(time.Hour), 1)
+	events, cancel := lim.Subscribe(4)
+	lim.Allow()
+	lim.Allow()
+	ctx, stop := context.WithCancel(context.Background())
+	stop()
+	lim.Wait(ctx)
+
+	for i, want := range []DenialReason{NotDenied, DeniedLimited, DeniedCanceled} {
+		e := <-events
+		if e.Reason != want || e.Allowed != (want == NotDenied) || e.Algorithm != TokenBucket {
+			t.Errorf("event %d = %+v, want reason %v", i, e, want)
+		}
+	}
+
+	if sub, _ := lim.Clone().Subscribe(1); len(sub) != 0 {
+		t.Error("expected a clone to start without events")
+	}
+
+	cancel()
+	cancel()
+	lim.Allow()
+	if _, ok := <-events; ok {
+		t.Error("expected cancel to close the channel")
+	}
+}
+
+func TestSubscribeDrops(t *testing.T) {
+	lim := NewLimiter(TokenBucket, Inf, 1)
+	events, cancel := lim.Subscribe(2)
+	defer cancel()
+	for i := 0; i < 5; i++ {
+		lim.Allow()
+	}
+	if n := len(events); n != 2 {
+		t.Errorf("expected the buffer of 2 filled and the rest dropped, got %d", n)
+	}
+}
+
+func TestKeyedSubscribe(t *testing.T) {
+	k := NewKeyedLimiter[string](TokenBucket, Every(time.Hour), 1)
+	k.AllowKey("alice")
+
+	events, cancel := k.Subscribe(8)
+	defer cancel()
+	k.AllowKey("alice")
+	k.AllowKey("bob")
+
+	for _, want := range []KeyEvent[string]{
+		{Key: "alice", Event: Event{Allowed: false, Reason: DeniedLimited}},
+		{Key: "bob", Event: Event{Allowed: true}},
+	} {
+		e := <-events
+		if e.Key != want.Key || e.Allowed != want.Allowed || e.Reason != want.Reason {
+			t.Errorf("got %s %+v, want %s allowed=%v", e.Key, e.Event, want.Key, want.Allowed)
+		}
+	}
+
+	k.Purge("bob")
+	lim := k.Get("bob")
+	k.Purge("bob")
+	lim.Allow()
+	if n := len(events); n != 0 {
+		t.Errorf("expected no events from a purged key's limiter, got %d", n)
+	}
+}
+
+func TestKeyedSubscribeWrapped(t *testing.T) {
+	k := NewKeyed(func(key int) Limiter {
+		return NewSheddingLimiter(NewLimiter(TokenBucket, Inf, 1), 1)
+	})
+	events, cancel := k.Subscribe(1)
+	defer cancel()
+	k.AllowKey(1)
+	select {
+	case e := <-events:
+		if e.Key != 1 || !e.Allowed {
+			t.Errorf("got %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the event of a wrapped limiter")
+	}
+}
@@ -0,0 +1,41 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Throttle runs a consumer loop paced by lim: it waits for lim to admit
+// one event, fetches a message and handles it, until ctx is done, fetch
+// returns io.EOF, or either function fails. Waiting comes before fetching
+// so a message is never held, e.g. past an SQS visibility timeout, while
+// the loop waits its turn:
+//
+//	err := rateflow.Throttle(ctx, lim,
+//		func() (*kafka.Message, error) { return consumer.ReadMessage(-1) },
+//		func(m *kafka.Message) error { return deliver(m) })
+//
+// It returns nil after io.EOF, ctx.Err() once ctx is done, and the error
+// of fetch or handle otherwise; handle should deal with failures it wants
+// to retry or skip itself
+func Throttle[M any](ctx context.Context, lim Limiter, fetch func() (M, error), handle func(M) error) error {
+	for {
+		if err := lim.Wait(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		msg, err := fetch()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := handle(msg); err != nil {
+			return err
+		}
+	}
+}
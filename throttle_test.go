@@ -0,0 +1,51 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	lim := NewLimiter(TokenBucket, 1000, 1)
+	queue := []int{1, 2, 3, 4, 5}
+	fetch := func() (int, error) {
+		if len(queue) == 0 {
+			return 0, io.EOF
+		}
+		m := queue[0]
+		queue = queue[1:]
+		return m, nil
+	}
+	var sum int
+	start := time.Now()
+	if err := Throttle(context.Background(), lim, fetch, func(m int) error { sum += m; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if sum != 15 {
+		t.Errorf("handled sum %d, want 15", sum)
+	}
+	if elapsed := time.Since(start); elapsed < 4*time.Millisecond {
+		t.Errorf("took %v, want the messages paced a millisecond apart", elapsed)
+	}
+}
+
+func TestThrottleStops(t *testing.T) {
+	lim := NewLimiter(TokenBucket, Inf, 1)
+	boom := errors.New("boom")
+	fetch := func() (int, error) { return 1, nil }
+	if err := Throttle(context.Background(), lim, fetch, func(int) error { return boom }); err != boom {
+		t.Errorf("Throttle = %v, want the handler's error", err)
+	}
+
+	slow := NewLimiter(TokenBucket, Every(time.Hour), 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	handled := 0
+	err := Throttle(ctx, slow, fetch, func(int) error { handled++; return nil })
+	if !errors.Is(err, context.DeadlineExceeded) || handled != 1 {
+		t.Errorf("Throttle = %v after %d messages, want the deadline after one", err, handled)
+	}
+}
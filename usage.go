@@ -0,0 +1,83 @@
+package rateflow
+
+import "sort"
+
+// UsageOrder selects how Keyed.Usage sorts keys, highest first
+type UsageOrder int
+
+const (
+	// ByDenied puts the most throttled keys first
+	ByDenied UsageOrder = iota
+	// ByAllowed puts the busiest keys first
+	ByAllowed
+	// ByUtilization puts the keys closest to their limit first
+	ByUtilization
+)
+
+// KeyUsage is a snapshot of one key's activity
+type KeyUsage[K comparable] struct {
+	Key   K
+	Stats Stats
+	// Utilization is the share of the key's burst in use, from 0 (idle)
+	// to 1 (no capacity left)
+	Utilization float64
+}
+
+// Usage returns a snapshot of every key's counters and utilization sorted
+// by order, e.g. to answer "who is being throttled right now"
+func (k *Keyed[K]) Usage(order UsageOrder) []KeyUsage[K] {
+	var entries []*keyedEntry[K]
+	for _, s := range k.shards {
+		s.mu.RLock()
+		for _, e := range s.limiters {
+			entries = append(entries, e)
+		}
+		s.mu.RUnlock()
+	}
+
+	usage := make([]KeyUsage[K], len(entries))
+	for i, e := range entries {
+		stats := e.lim.Stats()
+		usage[i] = KeyUsage[K]{
+			Key:         e.key,
+			Stats:       stats,
+			Utilization: utilization(stats.Tokens, e.lim.Burst()),
+		}
+	}
+
+	sort.SliceStable(usage, func(i, j int) bool {
+		a, b := usage[i], usage[j]
+		switch order {
+		case ByAllowed:
+			return a.Stats.Allowed > b.Stats.Allowed
+		case ByUtilization:
+			return a.Utilization > b.Utilization
+		}
+		return a.Stats.Denied > b.Stats.Denied
+	})
+	return usage
+}
+
+// Top returns the first n keys of Usage(order)
+func (k *Keyed[K]) Top(n int, order UsageOrder) []KeyUsage[K] {
+	usage := k.Usage(order)
+	if n >= 0 && n < len(usage) {
+		usage = usage[:n]
+	}
+	return usage
+}
+
+// utilization returns the share of burst not covered by tokens
+func utilization(tokens float64, burst int) float64 {
+	if burst <= 0 {
+		return 1
+	}
+	u := 1 - tokens/float64(burst)
+	if u < 0 {
+		return 0
+	}
+	if u > 1 {
+		return 1
+	}
+	return u
+}
@@ -0,0 +1,31 @@
+package rateflow
+
+import "testing"
+
+func TestKeyedUsage(t *testing.T) {
+	k := NewKeyedLimiter[string](FixedWindow, Limit(2), 2)
+	for i := 0; i < 5; i++ {
+		k.AllowKey("noisy")
+	}
+	k.AllowKey("quiet")
+	k.Get("idle")
+
+	top := k.Top(2, ByDenied)
+	if len(top) != 2 || top[0].Key != "noisy" {
+		t.Fatalf("expected noisy to be the most throttled, got %+v", top)
+	}
+	if top[0].Stats.Allowed != 2 || top[0].Stats.Denied != 3 {
+		t.Errorf("expected 2 allowed and 3 denied, got %+v", top[0].Stats)
+	}
+	if top[0].Utilization != 1 {
+		t.Errorf("expected noisy to be fully utilized, got %v", top[0].Utilization)
+	}
+
+	usage := k.Usage(ByUtilization)
+	if len(usage) != 3 || usage[2].Key != "idle" || usage[2].Utilization != 0 {
+		t.Errorf("expected idle last with no utilization, got %+v", usage)
+	}
+	if usage := k.Usage(ByAllowed); usage[0].Key != "noisy" || usage[1].Key != "quiet" {
+		t.Errorf("unexpected order by allowed: %+v", usage)
+	}
+}
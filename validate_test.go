@@ -0,0 +1,147 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewLimiterE(t *testing.T) {
+	lim, err := NewLimiterE(SlidingWindow, Limit(10), 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lim.Algorithm() != SlidingWindow {
+		t.Errorf("expected SlidingWindow, got %s", lim.Algorithm())
+	}
+
+	if _, err := NewLimiterE(TokenBucket, Inf, 1); err != nil {
+		t.Errorf("expected Inf to be accepted, got %v", err)
+	}
+}
+
+func TestNewLimiterEValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		algo  Algorithm
+		r     Limit
+		b     int
+		opts  []Option
+		want  error
+		field string
+	}{
+		{"negative limit", TokenBucket, Limit(-1), 5, nil, ErrInvalidLimit, "limit"},
+		{"zero burst", TokenBucket, Limit(10), 0, nil, ErrInvalidBurst, "burst"},
+		{"unknown algorithm", Algorithm(1000), Limit(10), 5, nil, ErrUnknownAlgorithm, "algorithm"},
+		{"negative window", FixedWindow, Limit(10), 5, []Option{WithWindow(-time.Second)}, ErrInvalidWindow, "window"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lim, err := NewLimiterE(tt.algo, tt.r, tt.b, tt.opts...)
+			if lim != nil {
+				t.Error("expected no limiter on error")
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+			var cfgErr *ConfigError
+			if !errors.As(err, &cfgErr) || cfgErr.Field != tt.field {
+				t.Errorf("expected ConfigError for %s, got %#v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestWaitErrors(t *testing.T) {
+	for _, algo := range Algorithms()[:DualRate+1] {
+		lim := NewLimiter(algo, Limit(1), 2)
+
+		err := lim.WaitN(context.Background(), 3)
+		if !errors.Is(err, ErrExceedsBurst) {
+			t.Errorf("%s: expected ErrExceedsBurst, got %v", algo, err)
+		}
+	}
+}
+
+func TestWaitRateLimitError(t *testing.T) {
+	lim := NewLimiter(TokenBucket, Limit(1), 1)
+	lim.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := lim.Wait(ctx)
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("expected Wait to fail fast, took %v", elapsed)
+	}
+
+	var rlErr *RateLimitError
+	if !errors.As(err, &rlErr) {
+		t.Fatalf("expected RateLimitError, got %v", err)
+	}
+	if rlErr.RetryAfter < 900*time.Millisecond || rlErr.RetryAfter > time.Second {
+		t.Errorf("expected RetryAfter ~1s, got %v", rlErr.RetryAfter)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("expected RateLimitError to match context.DeadlineExceeded")
+	}
+}
+
+func TestWaitDeadlineFailsFast(t *testing.T) {
+	for _, algo := range Algorithms()[:DualRate+1] {
+		lim := NewLimiter(algo, Limit(1), 1)
+		lim.Allow()
+		before := lim.Tokens()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		start := time.Now()
+		err := lim.Wait(ctx)
+		cancel()
+
+		if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+			t.Errorf("%s: expected Wait to fail fast, took %v", algo, elapsed)
+		}
+		var rlErr *RateLimitError
+		if !errors.As(err, &rlErr) || rlErr.RetryAfter <= 50*time.Millisecond {
+			t.Errorf("%s: expected RateLimitError beyond the deadline, got %v", algo, err)
+		}
+		if after := lim.Tokens(); after < before-0.01 {
+			t.Errorf("%s: failed Wait consumed capacity: %v -> %v", algo, before, after)
+		}
+	}
+}
+
+func TestWaitMaxN(t *testing.T) {
+	for _, algo := range Algorithms()[:DualRate+1] {
+		lim := NewLimiter(algo, Limit(1), 1)
+		lim.Allow()
+
+		start := time.Now()
+		err := lim.WaitMaxN(context.Background(), 1, 10*time.Millisecond)
+		if elapsed := time.Since(start); elapsed > 5*time.Millisecond {
+			t.Errorf("%s: expected WaitMaxN to give up at once, took %v", algo, elapsed)
+		}
+		var rlErr *RateLimitError
+		if !errors.As(err, &rlErr) {
+			t.Errorf("%s: expected RateLimitError, got %v", algo, err)
+		}
+	}
+
+	lim := NewLimiter(TokenBucket, Limit(100), 1)
+	lim.Allow()
+	if err := lim.WaitMaxN(context.Background(), 1, 100*time.Millisecond); err != nil {
+		t.Errorf("expected a short wait within maxWait to succeed, got %v", err)
+	}
+}
+
+func TestWaitMaxNZero(t *testing.T) {
+	for _, algo := range Algorithms()[:DualRate+1] {
+		lim := NewLimiter(algo, Limit(1), 5)
+		if err := lim.WaitMaxN(context.Background(), 1, 0); err != nil {
+			t.Errorf("%s: WaitMaxN with no wait allowed = %v, want the available event", algo, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package rateflow
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWindowWaitFIFO(t *testing.T) {
+	for _, algo := range []Algorithm{FixedWindow, SlidingWindow} {
+		lim := NewWindowLimiter(algo, 1, 30*time.Millisecond)
+		lim.Allow()
+
+		var mu sync.Mutex
+		var order []int
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if err := lim.Wait(context.Background()); err != nil {
+					t.Errorf("%s: waiter %d: %v", algo, i, err)
+					return
+				}
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+			}(i)
+			// Give each waiter time to queue before the next arrives
+			time.Sleep(2 * time.Millisecond)
+		}
+		wg.Wait()
+
+		for i, got := range order {
+			if got != i {
+				t.Errorf("%s: expected FIFO admission, got %v", algo, order)
+				break
+			}
+		}
+	}
+}
+
+func TestWindowWaitCancelledWaiterLeavesQueue(t *testing.T) {
+	lim := NewWindowLimiter(FixedWindow, 1, 50*time.Millisecond)
+	lim.Allow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- lim.Wait(ctx) }()
+	time.Sleep(5 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() { done <- lim.Wait(context.Background()) }()
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the waiter behind a cancelled one was never admitted")
+	}
+}
@@ -0,0 +1,37 @@
+package rateflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewWindowLimiter(t *testing.T) {
+	for _, algo := range []Algorithm{SlidingWindow, FixedWindow, TokenBucket, MultiWindow} {
+		lim := NewWindowLimiter(algo, 100, 10*time.Second)
+
+		if lim.Algorithm() != algo {
+			t.Errorf("%s: built %s", algo, lim.Algorithm())
+		}
+		if lim.Burst() != 100 {
+			t.Errorf("%s: expected Burst() = 100, got %d", algo, lim.Burst())
+		}
+		if lim.Limit() != Limit(10) {
+			t.Errorf("%s: expected Limit() = 10, got %v", algo, lim.Limit())
+		}
+
+		now := time.Now()
+		if !lim.AllowN(now, 100) {
+			t.Errorf("%s: expected the full window to be available", algo)
+		}
+		if lim.AllowN(now.Add(time.Second), 50) {
+			t.Errorf("%s: expected the window to be exhausted", algo)
+		}
+	}
+}
+
+func TestWindowLimiterWindow(t *testing.T) {
+	sw := NewWindowLimiter(SlidingWindow, 5, time.Minute).(interface{ Window() time.Duration })
+	if sw.Window() != time.Minute {
+		t.Errorf("expected a one minute window, got %v", sw.Window())
+	}
+}
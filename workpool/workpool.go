@@ -0,0 +1,172 @@
+// Package workpool runs jobs from a channel on a pool of workers, started
+// no faster than a rate limiter allows, for background processors whose
+// jobs write to a rate-limited target:
+//
+//	err := workpool.Run(ctx, lim, jobs, func(ctx context.Context, e Email) error {
+//		return ses.Send(ctx, e)
+//	}, workpool.Config[Email]{
+//		Workers:      8,
+//		Cost:         func(e Email) int { return len(e.To) },
+//		DrainTimeout: 30 * time.Second,
+//		OnError:      func(e Email, err error) { log.Printf("sending %s: %v", e.ID, err) },
+//	})
+//
+// Run returns once jobs is closed and every job has finished. Cancelling
+// ctx drains the pool instead: no more jobs are taken, and the ones
+// running get DrainTimeout to finish before their context is cancelled.
+package workpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// Config configures Run for jobs of type J
+type Config[J any] struct {
+	// Workers is the most jobs running at once, 1 if 0
+	Workers int
+	// Cost returns how many events of the limiter a job takes, e.g. the
+	// number of recipients of an email. Every job costs 1 if nil
+	Cost func(job J) int
+	// DrainTimeout is how long running jobs may go on once ctx is done
+	// before their context is cancelled. 0 lets them finish however long
+	// they take
+	DrainTimeout time.Duration
+	// OnError is called with each job that failed, or could not be started
+	// because its cost exceeds the limiter's burst. If nil, the first
+	// failure drains the pool and Run returns it
+	OnError func(job J, err error)
+	// OnDrop is called with a job taken from the channel but never started
+	// because the pool was draining, e.g. to put it back on the queue
+	OnDrop func(job J)
+}
+
+// Run takes jobs from the channel and calls handle for each in a worker
+// goroutine, once a worker is free and lim admits the job's cost. A nil
+// lim does not limit the rate. handle's context carries ctx's values but
+// is only cancelled when a drain runs out of time.
+//
+// Run returns nil once jobs is closed and every job has finished, the
+// first failure if OnError is nil, and otherwise ctx.Err() after draining
+// on cancellation, or a RateLimitError if ctx's deadline comes before the
+// next job's turn
+func Run[J any](ctx context.Context, lim rateflow.Limiter, jobs <-chan J, handle func(ctx context.Context, job J) error, cfg Config[J]) error {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	stop, cancelStop := context.WithCancel(ctx)
+	defer cancelStop()
+	work, cancelWork := context.WithCancel(detached{ctx})
+	defer cancelWork()
+
+	var (
+		wg      sync.WaitGroup
+		errOnce sync.Once
+		failure error
+	)
+	fail := func(job J, err error) {
+		if cfg.OnError != nil {
+			cfg.OnError(job, err)
+			return
+		}
+		errOnce.Do(func() {
+			failure = err
+			cancelStop()
+		})
+	}
+
+	slots := make(chan struct{}, cfg.Workers)
+	err := dispatch(stop, lim, jobs, slots, cfg, fail, func(job J) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := handle(work, job); err != nil {
+				fail(job, err)
+			}
+		}()
+	})
+
+	if err != nil && cfg.DrainTimeout > 0 {
+		timer := time.AfterFunc(cfg.DrainTimeout, cancelWork)
+		defer timer.Stop()
+	}
+	wg.Wait()
+
+	if failure != nil {
+		return failure
+	}
+	return err
+}
+
+// dispatch takes jobs and starts them until jobs is closed, when it
+// returns nil, or stop is done. A worker slot is taken before a job, so no
+// job is held while every worker is busy
+func dispatch[J any](stop context.Context, lim rateflow.Limiter, jobs <-chan J, slots chan struct{}, cfg Config[J], fail func(J, error), start func(J)) error {
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-stop.Done():
+			return stop.Err()
+		}
+
+		var job J
+		var ok bool
+		select {
+		case job, ok = <-jobs:
+		case <-stop.Done():
+			<-slots
+			return stop.Err()
+		}
+		if !ok {
+			<-slots
+			return nil
+		}
+		if err := stop.Err(); err != nil {
+			// The select picked the job over a stop that was also ready
+			<-slots
+			if cfg.OnDrop != nil {
+				cfg.OnDrop(job)
+			}
+			return err
+		}
+
+		if lim != nil {
+			n := 1
+			if cfg.Cost != nil {
+				n = cfg.Cost(job)
+			}
+			if err := lim.WaitN(stop, n); err != nil {
+				<-slots
+				if errors.Is(err, rateflow.ErrExceedsBurst) {
+					fail(job, err)
+					continue
+				}
+				// stop is done, or its deadline comes before the job's turn
+				if cfg.OnDrop != nil {
+					cfg.OnDrop(job)
+				}
+				return err
+			}
+		}
+		start(job)
+	}
+}
+
+// detached keeps the values of a context but not its cancellation, so
+// running jobs outlive the context that stops the pool
+type detached struct {
+	parent context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
+
+func (d detached) Value(key any) any {
+	return d.parent.Value(key)
+}
@@ -0,0 +1,133 @@
+package workpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestRun(t *testing.T) {
+	jobs := make(chan int, 10)
+	for i := 1; i <= 10; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	var running, peak, sum atomic.Int64
+	lim := rateflow.NewLimiter(rateflow.TokenBucket, 2000, 1)
+	start := time.Now()
+	err := Run(context.Background(), lim, jobs, func(ctx context.Context, job int) error {
+		if n := running.Add(1); n > peak.Load() {
+			peak.Store(n)
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		sum.Add(int64(job))
+		return nil
+	}, Config[int]{Workers: 3, Cost: func(job int) int { return 1 }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Load() != 55 {
+		t.Errorf("jobs summed to %d, want every job handled once", sum.Load())
+	}
+	if peak.Load() > 3 {
+		t.Errorf("%d jobs ran at once, want at most 3", peak.Load())
+	}
+	if elapsed := time.Since(start); elapsed < 4*time.Millisecond {
+		t.Errorf("10 jobs took %v, want them paced at 2000/s", elapsed)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	jobs := make(chan int, 3)
+	jobs <- 1
+	jobs <- 5 // costs more than the burst
+	jobs <- 2
+	close(jobs)
+
+	var mu sync.Mutex
+	failed := map[int]error{}
+	boom := errors.New("boom")
+	err := Run(context.Background(), rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Inf, 3), jobs,
+		func(ctx context.Context, job int) error {
+			if job == 2 {
+				return boom
+			}
+			return nil
+		}, Config[int]{
+			Cost: func(job int) int { return job },
+			OnError: func(job int, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				failed[job] = err
+			},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(failed[5], rateflow.ErrExceedsBurst) || failed[2] != boom || len(failed) != 2 {
+		t.Errorf("failures = %v, want job 5 over the burst and job 2 failing", failed)
+	}
+
+	// Without OnError the first failure stops the pool
+	jobs = make(chan int)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case jobs <- i:
+			case <-time.After(100 * time.Millisecond):
+				return
+			}
+		}
+	}()
+	err = Run(context.Background(), nil, jobs, func(ctx context.Context, job int) error {
+		if job == 3 {
+			return boom
+		}
+		return nil
+	}, Config[int]{})
+	if err != boom {
+		t.Errorf("Run = %v, want the handler's error", err)
+	}
+}
+
+func TestRunDrain(t *testing.T) {
+	jobs := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		jobs <- i
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var started, finished, dropped atomic.Int64
+	lim := rateflow.NewLimiter(rateflow.TokenBucket, rateflow.Every(time.Hour), 2)
+	err := Run(ctx, lim, jobs, func(jctx context.Context, job int) error {
+		if started.Add(1) == 2 {
+			cancel()
+		}
+		select {
+		case <-time.After(20 * time.Millisecond):
+			finished.Add(1)
+		case <-jctx.Done():
+		}
+		return nil
+	}, Config[int]{
+		Workers:      4,
+		DrainTimeout: time.Second,
+		OnDrop:       func(int) { dropped.Add(1) },
+	})
+	if err != context.Canceled {
+		t.Errorf("Run = %v, want context.Canceled", err)
+	}
+	if finished.Load() != 2 {
+		t.Errorf("%d running jobs finished, want both let through the drain", finished.Load())
+	}
+	if left := int64(len(jobs)); started.Load()+dropped.Load()+left != 10 {
+		t.Errorf("started %d, dropped %d and left %d jobs, want all 10 accounted for", started.Load(), dropped.Load(), left)
+	}
+}
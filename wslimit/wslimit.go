@@ -0,0 +1,175 @@
+// Package wslimit limits the messages a client sends over one
+// connection of a message-based protocol such as WebSocket. Each
+// connection gets its own Conn, asked for every message the read loop
+// receives; what happens to messages over the limit is the Config's
+// OnViolation. With gorilla/websocket:
+//
+//	lim := wslimit.New(wslimit.Config{Limit: 10, Burst: 20, OnViolation: wslimit.Drop, MaxViolations: 100})
+//	for {
+//		_, msg, err := ws.ReadMessage()
+//		if err != nil {
+//			return
+//		}
+//		ok, err := lim.Message(ctx)
+//		var ce *wslimit.CloseError
+//		if errors.As(err, &ce) {
+//			ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(ce.Code, ce.Reason), time.Now().Add(time.Second))
+//			return
+//		}
+//		if ok {
+//			handle(msg)
+//		}
+//	}
+//
+// nhooyr.io/websocket closes with ws.Close(websocket.StatusCode(ce.Code), ce.Reason).
+package wslimit
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+// ClosePolicyViolation is the WebSocket close code for a peer breaking
+// the server's policy, the default CloseCode
+const ClosePolicyViolation = 1008
+
+// Action is what happens to a message over the limit
+type Action int
+
+const (
+	// Drop discards the message and keeps the connection
+	Drop Action = iota
+	// Delay holds the message until the limit admits it, slowing the
+	// read loop and with it, through flow control, the client
+	Delay
+	// Close ends the connection with CloseCode
+	Close
+)
+
+// String returns the name of a
+func (a Action) String() string {
+	switch a {
+	case Drop:
+		return "drop"
+	case Delay:
+		return "delay"
+	case Close:
+		return "close"
+	}
+	return "unknown"
+}
+
+// Config configures the limit of one connection
+type Config struct {
+	// Algorithm, Limit and Burst configure the connection's limiter;
+	// the zero Algorithm is TokenBucket
+	Algorithm rateflow.Algorithm
+	Limit     rateflow.Limit
+	Burst     int
+	// OnViolation is what happens to messages over the limit
+	OnViolation Action
+	// MaxDelay bounds how long Delay holds a message; a message that would
+	// wait longer closes the connection. 0 waits as long as the context
+	MaxDelay time.Duration
+	// MaxViolations closes the connection at that many messages over the
+	// limit, whatever OnViolation is. 0 never does
+	MaxViolations int
+	// CloseCode and CloseReason are sent when closing,
+	// ClosePolicyViolation and "rate limit exceeded" if empty
+	CloseCode   int
+	CloseReason string
+}
+
+// CloseError is returned when a connection should be closed, with the
+// close frame to send
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("wslimit: closing connection: %d %s", e.Code, e.Reason)
+}
+
+// Conn limits the messages of one connection
+type Conn struct {
+	lim        rateflow.Limiter
+	cfg        Config
+	violations atomic.Int64
+}
+
+// New creates the limiter of a connection
+func New(cfg Config) *Conn {
+	if cfg.CloseCode == 0 {
+		cfg.CloseCode = ClosePolicyViolation
+	}
+	if cfg.CloseReason == "" {
+		cfg.CloseReason = "rate limit exceeded"
+	}
+	return &Conn{lim: rateflow.NewLimiter(cfg.Algorithm, cfg.Limit, cfg.Burst), cfg: cfg}
+}
+
+// Limiter returns the connection's limiter, e.g. to raise the limit of a
+// trusted client
+func (c *Conn) Limiter() rateflow.Limiter {
+	return c.lim
+}
+
+// Violations returns the number of messages that were over the limit
+func (c *Conn) Violations() int {
+	return int(c.violations.Load())
+}
+
+// Message is shorthand for MessageN(ctx, 1)
+func (c *Conn) Message(ctx context.Context) (bool, error) {
+	return c.MessageN(ctx, 1)
+}
+
+// MessageN decides a message costing n, e.g. its size in KiB. It reports
+// whether to handle the message; a *CloseError means the connection
+// should be closed, and with Delay, an error from the context means it
+// was done before the message was admitted
+func (c *Conn) MessageN(ctx context.Context, n int) (bool, error) {
+	if c.lim.AllowN(time.Now(), n) {
+		return true, nil
+	}
+	if v := c.violations.Add(1); c.cfg.MaxViolations > 0 && v >= int64(c.cfg.MaxViolations) {
+		return false, c.closeError()
+	}
+	switch c.cfg.OnViolation {
+	case Drop:
+		return false, nil
+	case Delay:
+		return c.delay(ctx, n)
+	}
+	return false, c.closeError()
+}
+
+// delay waits for n to be admitted, closing the connection if that
+// takes longer than MaxDelay
+func (c *Conn) delay(ctx context.Context, n int) (bool, error) {
+	if c.cfg.MaxDelay <= 0 {
+		err := c.lim.WaitN(ctx, n)
+		return err == nil, err
+	}
+	limit := time.Now().Add(c.cfg.MaxDelay)
+	wctx, cancel := context.WithDeadline(ctx, limit)
+	defer cancel()
+	err := c.lim.WaitN(wctx, n)
+	if err == nil {
+		return true, nil
+	}
+	if deadline, ok := ctx.Deadline(); ctx.Err() != nil || ok && deadline.Before(limit) {
+		// The caller's context ran out first, not MaxDelay
+		return false, err
+	}
+	return false, c.closeError()
+}
+
+func (c *Conn) closeError() error {
+	return &CloseError{Code: c.cfg.CloseCode, Reason: c.cfg.CloseReason}
+}
@@ -0,0 +1,67 @@
+package wslimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mehmet-f-dogan/rateflow"
+)
+
+func TestDrop(t *testing.T) {
+	c := New(Config{Limit: rateflow.Every(time.Hour), Burst: 2, OnViolation: Drop, MaxViolations: 3})
+	ctx := context.Background()
+	var handled int
+	for i := 0; i < 4; i++ {
+		ok, err := c.Message(ctx)
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if ok {
+			handled++
+		}
+	}
+	if handled != 2 || c.Violations() != 2 {
+		t.Errorf("handled %d with %d violations, want 2 and 2", handled, c.Violations())
+	}
+	var ce *CloseError
+	if _, err := c.Message(ctx); !errors.As(err, &ce) || ce.Code != ClosePolicyViolation {
+		t.Errorf("Message = %v, want a close at MaxViolations", err)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	c := New(Config{Limit: 100, Burst: 1, OnViolation: Delay, MaxDelay: time.Second})
+	ctx := context.Background()
+	c.Message(ctx)
+	start := time.Now()
+	if ok, err := c.Message(ctx); !ok || err != nil {
+		t.Fatalf("Message = %v, %v, want it delayed then handled", ok, err)
+	}
+	if waited := time.Since(start); waited < 5*time.Millisecond {
+		t.Errorf("waited %v, want about 10ms", waited)
+	}
+
+	slow := New(Config{Limit: rateflow.Every(time.Hour), Burst: 1, OnViolation: Delay, MaxDelay: 10 * time.Millisecond, CloseCode: 4000})
+	slow.Message(ctx)
+	var ce *CloseError
+	if _, err := slow.Message(ctx); !errors.As(err, &ce) || ce.Code != 4000 {
+		t.Errorf("Message = %v, want a close with code 4000 past MaxDelay", err)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	if _, err := slow.Message(short); err == nil || errors.As(err, &ce) {
+		t.Errorf("Message = %v, want the caller's deadline error rather than a close", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	c := New(Config{Limit: rateflow.Every(time.Hour), Burst: 1, OnViolation: Close, CloseReason: "slow down"})
+	c.Message(context.Background())
+	var ce *CloseError
+	if _, err := c.Message(context.Background()); !errors.As(err, &ce) || ce.Reason != "slow down" {
+		t.Errorf("Message = %v, want a close", err)
+	}
+}
@@ -0,0 +1,80 @@
+package rateflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var zeroAlgorithms = []Algorithm{TokenBucket, LeakyBucket, SlidingWindow, FixedWindow}
+
+func TestZeroLimitDenies(t *testing.T) {
+	for _, algo := range zeroAlgorithms {
+		for _, c := range []struct {
+			r Limit
+			b int
+		}{{0, 5}, {1, 0}, {0, 0}} {
+			lim := NewLimiter(algo, c.r, c.b)
+			if lim.Allow() {
+				t.Errorf("%s %v/%d: expected Allow to be false", algo, c.r, c.b)
+			}
+			if ok, res := lim.AllowDetails(1); ok || res.RetryAfter != InfDuration || res.Remaining != 0 {
+				t.Errorf("%s %v/%d: unexpected details %v %+v", algo, c.r, c.b, ok, res)
+			}
+			if lim.Reserve().OK() {
+				t.Errorf("%s %v/%d: expected Reserve to fail", algo, c.r, c.b)
+			}
+			if lim.Remaining() != 0 {
+				t.Errorf("%s %v/%d: expected nothing remaining, got %v", algo, c.r, c.b, lim.Remaining())
+			}
+		}
+	}
+}
+
+func TestZeroLimitWaitBlocks(t *testing.T) {
+	for _, algo := range zeroAlgorithms {
+		lim := NewLimiter(algo, Limit(0), 5)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := lim.Wait(ctx)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: expected Wait to block until the deadline, got %v", algo, err)
+		}
+	}
+}
+
+func TestZeroLimitWaitResumes(t *testing.T) {
+	for _, algo := range zeroAlgorithms {
+		for _, c := range []struct {
+			r      Limit
+			b      int
+			resume func(Limiter)
+		}{
+			{0, 5, func(lim Limiter) { lim.SetLimit(Limit(100)) }},
+			{100, 0, func(lim Limiter) { lim.SetBurst(5) }},
+		} {
+			lim := NewLimiter(algo, c.r, c.b)
+
+			done := make(chan error, 1)
+			go func() { done <- lim.Wait(context.Background()) }()
+
+			select {
+			case err := <-done:
+				t.Fatalf("%s %v/%d: expected Wait to block while paused, got %v", algo, c.r, c.b, err)
+			case <-time.After(20 * time.Millisecond):
+			}
+
+			c.resume(lim)
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("%s %v/%d: unexpected error after resuming: %v", algo, c.r, c.b, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s %v/%d: Wait did not resume", algo, c.r, c.b)
+			}
+		}
+	}
+}